@@ -243,6 +243,7 @@ type Client struct {
 	config             *Config
 	token              string
 	wrappingLookupFunc WrappingLookupFunc
+	policyOverride     bool
 }
 
 // NewClient returns a new client for the given configuration.
@@ -333,6 +334,12 @@ func (c *Client) SetWrappingLookupFunc(lookupFunc WrappingLookupFunc) {
 	c.wrappingLookupFunc = lookupFunc
 }
 
+// SetPolicyOverride sets whether requests override failed soft-mandatory
+// expression policies
+func (c *Client) SetPolicyOverride(override bool) {
+	c.policyOverride = override
+}
+
 // Token returns the access token being used by this client. It will
 // return the empty string if there is no token set.
 func (c *Client) Token() string {
@@ -350,9 +357,16 @@ func (c *Client) ClearToken() {
 	c.token = ""
 }
 
-// Clone creates a copy of this client.
+// Clone creates a copy of this client. The copy shares the configuration,
+// and so the underlying HTTP client, and the address of this client, but
+// none of its other settings: it has no token, wrapping lookup function or
+// policy override. Unlike NewClient, Clone does not read the environment, so
+// the copy does not pick up `VAULT_TOKEN`; call `SetToken()` on it instead.
 func (c *Client) Clone() (*Client, error) {
-	return NewClient(c.config)
+	return &Client{
+		addr:   c.addr,
+		config: c.config,
+	}, nil
 }
 
 // NewRequest creates a new raw request object to query the Vault server
@@ -378,8 +392,9 @@ func (c *Client) NewRequest(method, requestPath string) *Request {
 			Host:   host,
 			Path:   path.Join(c.addr.Path, requestPath),
 		},
-		ClientToken: c.token,
-		Params:      make(map[string][]string),
+		ClientToken:    c.token,
+		PolicyOverride: c.policyOverride,
+		Params:         make(map[string][]string),
 	}
 
 	var lookupPath string
@@ -86,6 +86,40 @@ func TestClientToken(t *testing.T) {
 	}
 }
 
+func TestClientClone(t *testing.T) {
+	handler := func(w http.ResponseWriter, req *http.Request) {}
+
+	config, ln := testHTTPServer(t, http.HandlerFunc(handler))
+	defer ln.Close()
+
+	client, err := NewClient(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	client.SetToken("foo")
+
+	clone, err := client.Clone()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if v := clone.Address(); v != client.Address() {
+		t.Fatalf("bad: %s", v)
+	}
+
+	// Only the configuration is copied, not the token
+	if v := clone.Token(); v != "" {
+		t.Fatalf("bad: %s", v)
+	}
+	clone.SetToken("bar")
+	if v := client.Token(); v != "foo" {
+		t.Fatalf("bad: %s", v)
+	}
+
+	if _, err := clone.Clone(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
 func TestClientRedirect(t *testing.T) {
 	primary := func(w http.ResponseWriter, req *http.Request) {
 		w.Write([]byte("test"))
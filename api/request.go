@@ -11,15 +11,16 @@ import (
 // Request is a raw request configuration structure used to initiate
 // API requests to the Vault server.
 type Request struct {
-	Method      string
-	URL         *url.URL
-	Params      url.Values
-	Headers     http.Header
-	ClientToken string
-	WrapTTL     string
-	Obj         interface{}
-	Body        io.Reader
-	BodySize    int64
+	Method         string
+	URL            *url.URL
+	Params         url.Values
+	Headers        http.Header
+	ClientToken    string
+	WrapTTL        string
+	PolicyOverride bool
+	Obj            interface{}
+	Body           io.Reader
+	BodySize       int64
 }
 
 // SetJSONBody is used to set a request body that is a JSON-encoded value.
@@ -77,5 +78,9 @@ func (r *Request) ToHTTP() (*http.Request, error) {
 		req.Header.Set("X-Vault-Wrap-TTL", r.WrapTTL)
 	}
 
+	if r.PolicyOverride {
+		req.Header.Set("X-Vault-Policy-Override", "true")
+	}
+
 	return req, nil
 }
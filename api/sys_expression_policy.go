@@ -0,0 +1,77 @@
+package api
+
+import "fmt"
+
+func (c *Sys) ListExpressionPolicies() ([]string, error) {
+	r := c.c.NewRequest("LIST", "/v1/sys/expression-policy")
+	resp, err := c.c.RawRequest(r)
+	if resp != nil {
+		defer resp.Body.Close()
+		if resp.StatusCode == 404 {
+			return nil, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var result listExpressionPoliciesResp
+	err = resp.DecodeJSON(&result)
+	return result.Keys, err
+}
+
+func (c *Sys) GetExpressionPolicy(name string) (*ExpressionPolicy, error) {
+	r := c.c.NewRequest("GET", fmt.Sprintf("/v1/sys/expression-policy/%s", name))
+	resp, err := c.c.RawRequest(r)
+	if resp != nil {
+		defer resp.Body.Close()
+		if resp.StatusCode == 404 {
+			return nil, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var result ExpressionPolicy
+	err = resp.DecodeJSON(&result)
+	return &result, err
+}
+
+func (c *Sys) PutExpressionPolicy(policy *ExpressionPolicy) error {
+	r := c.c.NewRequest("PUT", fmt.Sprintf("/v1/sys/expression-policy/%s", policy.Name))
+	if err := r.SetJSONBody(policy); err != nil {
+		return err
+	}
+
+	resp, err := c.c.RawRequest(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return nil
+}
+
+func (c *Sys) DeleteExpressionPolicy(name string) error {
+	r := c.c.NewRequest("DELETE", fmt.Sprintf("/v1/sys/expression-policy/%s", name))
+	resp, err := c.c.RawRequest(r)
+	if err == nil {
+		defer resp.Body.Close()
+	}
+	return err
+}
+
+// ExpressionPolicy is a policy evaluated after the ACL allows a request. It
+// applies to requests on any of its paths, and to requests made with tokens
+// carrying a policy of the same name.
+type ExpressionPolicy struct {
+	Name             string   `json:"name"`
+	Expression       string   `json:"expression"`
+	EnforcementLevel string   `json:"enforcement_level"`
+	Paths            []string `json:"paths"`
+}
+
+type listExpressionPoliciesResp struct {
+	Keys []string `json:"keys"`
+}
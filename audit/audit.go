@@ -9,6 +9,10 @@ import (
 // mechanism to be made available. Audit backends can be enabled to
 // sink information to different backends such as logs, file, databases,
 // or other external services.
+//
+// Backends that hold resources, such as background goroutines, can also
+// implement io.Closer to have them released when the backend is disabled or
+// Vault is sealed.
 type Backend interface {
 	// LogRequest is used to synchronously log a request. This is done after the
 	// request is authorized but before the request is executed. The arguments
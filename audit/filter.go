@@ -0,0 +1,86 @@
+package audit
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/vault/helper/policyexpr"
+)
+
+// FilterInput holds the properties of an audit entry that filters select on
+type FilterInput struct {
+	MountPoint string
+	MountType  string
+	Namespace  string
+	Operation  string
+	Path       string
+	Policies   []string
+	Error      bool
+}
+
+// filterVariables are the properties of an entry filters can refer to
+var filterVariables = map[string]bool{
+	"mount_point": true,
+	"mount_type":  true,
+	"namespace":   true,
+	"operation":   true,
+	"path":        true,
+	"policies":    true,
+	"error":       true,
+}
+
+// Filter is an expression of the policyexpr package selecting the entries an
+// audit device receives, such as:
+//
+//	mount_type == "transit" && !(operation == "update" && !error)
+//
+// The expression refers to the properties of an entry as variables:
+//
+//	mount_point, mount_type, namespace, operation, path: strings
+//	policies: the list of policies of the client token
+//	error: a bool
+//
+// and must result in a bool.
+type Filter struct {
+	expr *policyexpr.Expression
+}
+
+// ParseFilter parses a filter expression
+func ParseFilter(raw string) (*Filter, error) {
+	expr, err := policyexpr.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range expr.Variables() {
+		if !filterVariables[name] {
+			return nil, fmt.Errorf("unknown property %q", name)
+		}
+	}
+
+	return &Filter{
+		expr: expr,
+	}, nil
+}
+
+// Evaluate returns whether the entry matches the filter. If the expression
+// fails to evaluate, such as when it does not result in a bool, the entry
+// is reported as matching along with the error, so that it is not dropped.
+func (f *Filter) Evaluate(in *FilterInput) (bool, error) {
+	matched, err := f.expr.EvalBool(map[string]interface{}{
+		"mount_point": in.MountPoint,
+		"mount_type":  in.MountType,
+		"namespace":   in.Namespace,
+		"operation":   in.Operation,
+		"path":        in.Path,
+		"policies":    in.Policies,
+		"error":       in.Error,
+	})
+	if err != nil {
+		return true, err
+	}
+	return matched, nil
+}
+
+func (f *Filter) String() string {
+	return f.expr.String()
+}
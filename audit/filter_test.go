@@ -0,0 +1,103 @@
+package audit
+
+import (
+	"testing"
+)
+
+func TestFilter_Evaluate(t *testing.T) {
+	in := &FilterInput{
+		MountPoint: "transit/",
+		MountType:  "transit",
+		Operation:  "update",
+		Path:       "transit/encrypt/payments",
+		Policies:   []string{"default", "payments"},
+	}
+
+	cases := []struct {
+		filter   string
+		expected bool
+	}{
+		{`mount_type == "transit"`, true},
+		{`mount_type != "transit"`, false},
+		{`mount_point == "transit/"`, true},
+		{`namespace == ""`, true},
+		{`operation == "read"`, false},
+		{`path.matches("^transit/encrypt/")`, true},
+		{`path.startsWith("sys/")`, false},
+		{`"payments" in policies`, true},
+		{`"root" in policies`, false},
+		{`error == false`, true},
+		{`error != false`, false},
+		{`error`, false},
+		{`!error`, true},
+		{`mount_type == "transit" && operation == "read"`, false},
+		{`mount_type == "transit" || operation == "read"`, true},
+		{`operation in ["read", "list"] || "payments" in policies`, true},
+		{`!(mount_type == "transit" && path.matches("/encrypt/"))`, false},
+		{`mount_type != "pki" && (operation == "update" || error)`, true},
+		{`path == "transit/encrypt/\"quoted\""`, false},
+		{`policies.exists(p, p.startsWith("pay"))`, true},
+	}
+
+	for _, c := range cases {
+		f, err := ParseFilter(c.filter)
+		if err != nil {
+			t.Fatalf("%s: err: %v", c.filter, err)
+		}
+		actual, err := f.Evaluate(in)
+		if err != nil {
+			t.Fatalf("%s: err: %v", c.filter, err)
+		}
+		if actual != c.expected {
+			t.Fatalf("%s: expected %t, got %t", c.filter, c.expected, actual)
+		}
+		if f.String() != c.filter {
+			t.Fatalf("bad: %s", f.String())
+		}
+	}
+
+	in.Error = true
+	f, err := ParseFilter(`error && mount_type == "transit"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if matched, err := f.Evaluate(in); err != nil || !matched {
+		t.Fatalf("expected a match, err: %v", err)
+	}
+
+	// Entries are not dropped when the filter fails to evaluate
+	f, err = ParseFilter(`path`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	matched, err := f.Evaluate(in)
+	if err == nil {
+		t.Fatal("expected an error for a filter that is not a bool")
+	}
+	if !matched {
+		t.Fatal("expected a match for a filter that failed to evaluate")
+	}
+}
+
+func TestParseFilter_Invalid(t *testing.T) {
+	for _, filter := range []string{
+		``,
+		`   `,
+		`mount_type ==`,
+		`mount_type = "transit"`,
+		`mount_type == transit`,
+		`unknown == "value"`,
+		`policies.exists(p, p == request.path)`,
+		`(mount_type == "transit"`,
+		`mount_type == "transit")`,
+		`mount_type == "transit" &&`,
+		`mount_type == "transit" operation == "read"`,
+		`path == "unterminated`,
+		`mount_type == "transit" & operation == "read"`,
+		`mount_type == "transit" and operation == "read"`,
+	} {
+		if _, err := ParseFilter(filter); err == nil {
+			t.Fatalf("expected an error parsing %q", filter)
+		}
+	}
+}
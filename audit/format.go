@@ -19,6 +19,36 @@ type AuditFormatWriter interface {
 	Salt() (*salt.Salt, error)
 }
 
+// NewAuditFormatWriter returns the AuditFormatWriter for the format option of
+// an audit backend: json, jsonx, cef or ocsf. The prefix is written before
+// each entry.
+func NewAuditFormatWriter(format, prefix string, saltFunc func() (*salt.Salt, error)) (AuditFormatWriter, error) {
+	switch format {
+	case "json":
+		return &JSONFormatWriter{
+			Prefix:   prefix,
+			SaltFunc: saltFunc,
+		}, nil
+	case "jsonx":
+		return &JSONxFormatWriter{
+			Prefix:   prefix,
+			SaltFunc: saltFunc,
+		}, nil
+	case "cef":
+		return &CEFFormatWriter{
+			Prefix:   prefix,
+			SaltFunc: saltFunc,
+		}, nil
+	case "ocsf":
+		return &OCSFFormatWriter{
+			Prefix:   prefix,
+			SaltFunc: saltFunc,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unknown format type %s", format)
+}
+
 // AuditFormatter implements the Formatter interface, and allows the underlying
 // marshaller to be swapped out
 type AuditFormatter struct {
@@ -135,6 +165,7 @@ func (f *AuditFormatter) FormatRequest(
 			Data:                req.Data,
 			RemoteAddr:          getRemoteAddr(req),
 			ReplicationCluster:  req.ReplicationCluster,
+			PolicyOverride:      req.PolicyOverride,
 			Headers:             req.Headers,
 		},
 	}
@@ -326,6 +357,7 @@ func (f *AuditFormatter) FormatResponse(
 			Data:                req.Data,
 			RemoteAddr:          getRemoteAddr(req),
 			ReplicationCluster:  req.ReplicationCluster,
+			PolicyOverride:      req.PolicyOverride,
 			Headers:             req.Headers,
 		},
 
@@ -378,6 +410,7 @@ type AuditRequest struct {
 	Data                map[string]interface{} `json:"data"`
 	RemoteAddr          string                 `json:"remote_address"`
 	WrapTTL             int                    `json:"wrap_ttl"`
+	PolicyOverride      bool                   `json:"policy_override,omitempty"`
 	Headers             map[string][]string    `json:"headers"`
 }
 
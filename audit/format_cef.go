@@ -0,0 +1,110 @@
+package audit
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/helper/salt"
+	"github.com/hashicorp/vault/version"
+)
+
+var (
+	cefHeaderEscaper    = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r", " ", "\n", " ")
+	cefExtensionEscaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r", `\r`, "\n", `\n`)
+)
+
+// CEFFormatWriter is an AuditFormatWriter implementation that structures data
+// into the ArcSight Common Event Format. Each entry is a single line with a
+// header identifying the kind of event and flat key=value extensions.
+//
+// Request and response data are not included, since CEF has no way to
+// represent nested values.
+type CEFFormatWriter struct {
+	Prefix   string
+	SaltFunc func() (*salt.Salt, error)
+}
+
+func (f *CEFFormatWriter) WriteRequest(w io.Writer, req *AuditRequestEntry) error {
+	if req == nil {
+		return fmt.Errorf("request entry was nil, cannot encode")
+	}
+
+	return f.write(w, req.Type, req.Time, &req.Auth, &req.Request, req.Error)
+}
+
+func (f *CEFFormatWriter) WriteResponse(w io.Writer, resp *AuditResponseEntry) error {
+	if resp == nil {
+		return fmt.Errorf("response entry was nil, cannot encode")
+	}
+
+	return f.write(w, resp.Type, resp.Time, &resp.Auth, &resp.Request, resp.Error)
+}
+
+func (f *CEFFormatWriter) write(w io.Writer, typ, entryTime string, auth *AuditAuth, req *AuditRequest, errString string) error {
+	severity, outcome := 3, "success"
+	if errString != "" {
+		severity, outcome = 7, "failure"
+	}
+
+	var line []byte
+	line = append(line, f.Prefix...)
+	line = append(line, "CEF:0|HashiCorp|Vault|"...)
+	line = append(line, cefHeaderEscaper.Replace(version.GetVersion().VersionNumber())...)
+	line = append(line, '|')
+	line = append(line, cefHeaderEscaper.Replace(typ+"-"+string(req.Operation))...)
+	line = append(line, '|')
+	line = append(line, cefHeaderEscaper.Replace("Vault "+typ+" "+string(req.Operation))...)
+	line = append(line, '|')
+	line = strconv.AppendInt(line, int64(severity), 10)
+	line = append(line, '|')
+
+	first := true
+	ext := func(key, value string) {
+		if value == "" {
+			return
+		}
+		if !first {
+			line = append(line, ' ')
+		}
+		first = false
+		line = append(line, key...)
+		line = append(line, '=')
+		line = append(line, cefExtensionEscaper.Replace(value)...)
+	}
+
+	if entryTime != "" {
+		if t, err := time.Parse(time.RFC3339, entryTime); err == nil {
+			ext("rt", strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10))
+		}
+	}
+	ext("externalId", req.ID)
+	ext("requestMethod", string(req.Operation))
+	ext("request", req.Path)
+	ext("src", req.RemoteAddr)
+	ext("suser", auth.DisplayName)
+	ext("outcome", outcome)
+	ext("reason", errString)
+	if req.ClientTokenAccessor != "" {
+		ext("cs1Label", "client_token_accessor")
+		ext("cs1", req.ClientTokenAccessor)
+	}
+	if len(auth.Policies) > 0 {
+		ext("cs2Label", "policies")
+		ext("cs2", strings.Join(auth.Policies, ","))
+	}
+	if req.ReplicationCluster != "" {
+		ext("cs3Label", "replication_cluster")
+		ext("cs3", req.ReplicationCluster)
+	}
+	line = append(line, '\n')
+
+	_, err := w.Write(line)
+	return err
+}
+
+func (f *CEFFormatWriter) Salt() (*salt.Salt, error) {
+	return f.SaltFunc()
+}
@@ -0,0 +1,132 @@
+package audit
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/helper/salt"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/version"
+)
+
+func TestFormatCEF_formatRequest(t *testing.T) {
+	salter, err := salt.NewSalt(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saltFunc := func() (*salt.Salt, error) {
+		return salter, nil
+	}
+
+	header := "CEF:0|HashiCorp|Vault|" + version.GetVersion().VersionNumber() + "|"
+
+	cases := map[string]struct {
+		Auth     *logical.Auth
+		Req      *logical.Request
+		Err      error
+		Prefix   string
+		Expected []string
+	}{
+		"request": {
+			&logical.Auth{ClientToken: "foo", DisplayName: "testtoken", Policies: []string{"default", "root"}},
+			&logical.Request{
+				ID:                  "abc",
+				Operation:           logical.UpdateOperation,
+				Path:                "secret/foo",
+				ClientTokenAccessor: "bar",
+				Connection: &logical.Connection{
+					RemoteAddr: "127.0.0.1",
+				},
+			},
+			nil,
+			"",
+			[]string{
+				header + "request-update|Vault request update|3|",
+				"externalId=abc requestMethod=update request=secret/foo src=127.0.0.1 suser=testtoken outcome=success",
+				"cs1Label=client_token_accessor cs1=bar",
+				"cs2Label=policies cs2=default,root",
+			},
+		},
+		"error with escaping": {
+			&logical.Auth{ClientToken: "foo"},
+			&logical.Request{
+				Operation: logical.ReadOperation,
+				Path:      `secret/a=b\c`,
+			},
+			errors.New("permission denied\nline"),
+			"@cee: ",
+			[]string{
+				"@cee: " + header + "request-read|Vault request read|7|",
+				`request=secret/a\=b\\c`,
+				`outcome=failure reason=permission denied\nline`,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		var buf bytes.Buffer
+		formatter := AuditFormatter{
+			AuditFormatWriter: &CEFFormatWriter{
+				Prefix:   tc.Prefix,
+				SaltFunc: saltFunc,
+			},
+		}
+		config := FormatterConfig{
+			HMACAccessor: false,
+		}
+		if err := formatter.FormatRequest(&buf, config, tc.Auth, tc.Req, tc.Err); err != nil {
+			t.Fatalf("bad: %s\nerr: %s", name, err)
+		}
+
+		line := buf.String()
+		if strings.Count(line, "\n") != 1 || !strings.HasSuffix(line, "\n") {
+			t.Fatalf("bad: %s\nexpected a single line, got: %q", name, line)
+		}
+		if !strings.HasPrefix(line, tc.Expected[0]) {
+			t.Fatalf("bad: %s\nexpected prefix %q, got: %q", name, tc.Expected[0], line)
+		}
+		for _, expected := range tc.Expected[1:] {
+			if !strings.Contains(line, expected) {
+				t.Fatalf("bad: %s\nexpected %q in: %q", name, expected, line)
+			}
+		}
+	}
+}
+
+func TestFormatCEF_formatResponse(t *testing.T) {
+	salter, err := salt.NewSalt(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	formatter := AuditFormatter{
+		AuditFormatWriter: &CEFFormatWriter{
+			SaltFunc: func() (*salt.Salt, error) {
+				return salter, nil
+			},
+		},
+	}
+	req := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "secret/foo",
+	}
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"password": "hunter2",
+		},
+	}
+	if err := formatter.FormatResponse(&buf, FormatterConfig{}, nil, req, resp, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	line := buf.String()
+	if !strings.Contains(line, "|response-read|Vault response read|3|") {
+		t.Fatalf("bad: %q", line)
+	}
+	if strings.Contains(line, "hunter2") {
+		t.Fatalf("response data should not be included: %q", line)
+	}
+}
@@ -0,0 +1,252 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/hashicorp/vault/helper/salt"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/version"
+)
+
+// OCSF API Activity class and the activities requests are mapped to
+const (
+	ocsfVersion = "1.0.0"
+
+	ocsfCategoryUID  = 6
+	ocsfCategoryName = "Application Activity"
+	ocsfClassUID     = 6003
+	ocsfClassName    = "API Activity"
+
+	ocsfActivityCreate = 1
+	ocsfActivityRead   = 2
+	ocsfActivityUpdate = 3
+	ocsfActivityDelete = 4
+	ocsfActivityOther  = 99
+)
+
+// OCSFFormatWriter is an AuditFormatWriter implementation that structures
+// data as JSON events of the Open Cybersecurity Schema Framework API Activity
+// class. The common fields are mapped to their OCSF attributes, and the full
+// request and response are kept under unmapped.
+type OCSFFormatWriter struct {
+	Prefix   string
+	SaltFunc func() (*salt.Salt, error)
+}
+
+// OCSFEvent is the structure of an OCSF API Activity event
+type OCSFEvent struct {
+	CategoryUID  int    `json:"category_uid"`
+	CategoryName string `json:"category_name"`
+	ClassUID     int    `json:"class_uid"`
+	ClassName    string `json:"class_name"`
+	ActivityID   int    `json:"activity_id"`
+	ActivityName string `json:"activity_name"`
+	TypeUID      int    `json:"type_uid"`
+	SeverityID   int    `json:"severity_id"`
+	Severity     string `json:"severity"`
+	StatusID     int    `json:"status_id"`
+	Status       string `json:"status"`
+	StatusDetail string `json:"status_detail,omitempty"`
+	Time         int64  `json:"time,omitempty"`
+
+	Metadata    OCSFMetadata       `json:"metadata"`
+	Actor       OCSFActor          `json:"actor"`
+	API         OCSFAPI            `json:"api"`
+	SrcEndpoint *OCSFEndpoint      `json:"src_endpoint,omitempty"`
+	Resources   []OCSFResource     `json:"resources"`
+	Unmapped    OCSFUnmappedFields `json:"unmapped"`
+}
+
+type OCSFMetadata struct {
+	Version string      `json:"version"`
+	LogName string      `json:"log_name"`
+	Product OCSFProduct `json:"product"`
+}
+
+type OCSFProduct struct {
+	Name       string `json:"name"`
+	VendorName string `json:"vendor_name"`
+	Version    string `json:"version"`
+}
+
+type OCSFActor struct {
+	User           OCSFUser            `json:"user"`
+	Authorizations []OCSFAuthorization `json:"authorizations,omitempty"`
+}
+
+type OCSFUser struct {
+	Name string `json:"name,omitempty"`
+	UID  string `json:"uid,omitempty"`
+}
+
+type OCSFAuthorization struct {
+	Policy OCSFPolicy `json:"policy"`
+}
+
+type OCSFPolicy struct {
+	Name string `json:"name"`
+}
+
+type OCSFAPI struct {
+	Operation string           `json:"operation"`
+	Request   OCSFAPIRequest   `json:"request"`
+	Response  *OCSFAPIResponse `json:"response,omitempty"`
+}
+
+type OCSFAPIRequest struct {
+	UID string `json:"uid"`
+}
+
+type OCSFAPIResponse struct {
+	Error string `json:"error,omitempty"`
+}
+
+type OCSFEndpoint struct {
+	IP string `json:"ip"`
+}
+
+type OCSFResource struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// OCSFUnmappedFields holds the entry in Vault's own structure
+type OCSFUnmappedFields struct {
+	Auth     AuditAuth      `json:"auth"`
+	Request  AuditRequest   `json:"request"`
+	Response *AuditResponse `json:"response,omitempty"`
+}
+
+func (f *OCSFFormatWriter) WriteRequest(w io.Writer, req *AuditRequestEntry) error {
+	if req == nil {
+		return fmt.Errorf("request entry was nil, cannot encode")
+	}
+
+	event := newOCSFEvent(req.Type, req.Time, &req.Auth, &req.Request, req.Error)
+	return f.write(w, event)
+}
+
+func (f *OCSFFormatWriter) WriteResponse(w io.Writer, resp *AuditResponseEntry) error {
+	if resp == nil {
+		return fmt.Errorf("response entry was nil, cannot encode")
+	}
+
+	event := newOCSFEvent(resp.Type, resp.Time, &resp.Auth, &resp.Request, resp.Error)
+	event.API.Response = &OCSFAPIResponse{
+		Error: resp.Error,
+	}
+	event.Unmapped.Response = &resp.Response
+	return f.write(w, event)
+}
+
+func (f *OCSFFormatWriter) write(w io.Writer, event *OCSFEvent) error {
+	if len(f.Prefix) > 0 {
+		_, err := w.Write([]byte(f.Prefix))
+		if err != nil {
+			return err
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	return enc.Encode(event)
+}
+
+func (f *OCSFFormatWriter) Salt() (*salt.Salt, error) {
+	return f.SaltFunc()
+}
+
+func newOCSFEvent(typ, entryTime string, auth *AuditAuth, req *AuditRequest, errString string) *OCSFEvent {
+	activityID, activityName := ocsfActivity(req.Operation)
+
+	event := &OCSFEvent{
+		CategoryUID:  ocsfCategoryUID,
+		CategoryName: ocsfCategoryName,
+		ClassUID:     ocsfClassUID,
+		ClassName:    ocsfClassName,
+		ActivityID:   activityID,
+		ActivityName: activityName,
+		TypeUID:      ocsfClassUID*100 + activityID,
+		SeverityID:   1,
+		Severity:     "Informational",
+		StatusID:     1,
+		Status:       "Success",
+
+		Metadata: OCSFMetadata{
+			Version: ocsfVersion,
+			LogName: typ,
+			Product: OCSFProduct{
+				Name:       "Vault",
+				VendorName: "HashiCorp",
+				Version:    version.GetVersion().VersionNumber(),
+			},
+		},
+		Actor: OCSFActor{
+			User: OCSFUser{
+				Name: auth.DisplayName,
+				UID:  req.ClientTokenAccessor,
+			},
+		},
+		API: OCSFAPI{
+			Operation: string(req.Operation),
+			Request: OCSFAPIRequest{
+				UID: req.ID,
+			},
+		},
+		Resources: []OCSFResource{
+			{
+				Name: req.Path,
+				Type: "path",
+			},
+		},
+		Unmapped: OCSFUnmappedFields{
+			Auth:    *auth,
+			Request: *req,
+		},
+	}
+
+	if errString != "" {
+		event.StatusID = 2
+		event.Status = "Failure"
+		event.StatusDetail = errString
+	}
+
+	if entryTime != "" {
+		if t, err := time.Parse(time.RFC3339, entryTime); err == nil {
+			event.Time = t.UnixNano() / int64(time.Millisecond)
+		}
+	}
+
+	if req.RemoteAddr != "" {
+		event.SrcEndpoint = &OCSFEndpoint{
+			IP: req.RemoteAddr,
+		}
+	}
+
+	for _, policy := range auth.Policies {
+		event.Actor.Authorizations = append(event.Actor.Authorizations, OCSFAuthorization{
+			Policy: OCSFPolicy{
+				Name: policy,
+			},
+		})
+	}
+
+	return event
+}
+
+// ocsfActivity maps a request operation to an API Activity activity
+func ocsfActivity(op logical.Operation) (int, string) {
+	switch op {
+	case logical.CreateOperation:
+		return ocsfActivityCreate, "Create"
+	case logical.ReadOperation, logical.ListOperation:
+		return ocsfActivityRead, "Read"
+	case logical.UpdateOperation:
+		return ocsfActivityUpdate, "Update"
+	case logical.DeleteOperation:
+		return ocsfActivityDelete, "Delete"
+	}
+	return ocsfActivityOther, "Other"
+}
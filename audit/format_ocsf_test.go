@@ -0,0 +1,131 @@
+package audit
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/helper/jsonutil"
+	"github.com/hashicorp/vault/helper/salt"
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestFormatOCSF_formatRequest(t *testing.T) {
+	salter, err := salt.NewSalt(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	formatter := AuditFormatter{
+		AuditFormatWriter: &OCSFFormatWriter{
+			Prefix: "@cee: ",
+			SaltFunc: func() (*salt.Salt, error) {
+				return salter, nil
+			},
+		},
+	}
+	auth := &logical.Auth{ClientToken: "foo", DisplayName: "testtoken", Policies: []string{"default", "root"}}
+	req := &logical.Request{
+		ID:                  "abc",
+		Operation:           logical.DeleteOperation,
+		Path:                "secret/foo",
+		ClientTokenAccessor: "bar",
+		Connection: &logical.Connection{
+			RemoteAddr: "127.0.0.1",
+		},
+	}
+	config := FormatterConfig{
+		HMACAccessor: false,
+	}
+	if err := formatter.FormatRequest(&buf, config, auth, req, errors.New("permission denied")); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(buf.String(), "@cee: ") {
+		t.Fatalf("no prefix: %q", buf.String())
+	}
+
+	var event OCSFEvent
+	if err := jsonutil.DecodeJSON(buf.Bytes()[len("@cee: "):], &event); err != nil {
+		t.Fatal(err)
+	}
+
+	if event.ClassUID != 6003 || event.CategoryUID != 6 {
+		t.Fatalf("bad class: %#v", event)
+	}
+	if event.ActivityID != ocsfActivityDelete || event.TypeUID != 600304 {
+		t.Fatalf("bad activity: %d %d", event.ActivityID, event.TypeUID)
+	}
+	if event.Status != "Failure" || event.StatusDetail != "permission denied" {
+		t.Fatalf("bad status: %q %q", event.Status, event.StatusDetail)
+	}
+	if event.Metadata.LogName != "request" {
+		t.Fatalf("bad log name: %q", event.Metadata.LogName)
+	}
+	if event.Actor.User.Name != "testtoken" || event.Actor.User.UID != "bar" {
+		t.Fatalf("bad user: %#v", event.Actor.User)
+	}
+	if len(event.Actor.Authorizations) != 2 || event.Actor.Authorizations[1].Policy.Name != "root" {
+		t.Fatalf("bad authorizations: %#v", event.Actor.Authorizations)
+	}
+	if event.API.Operation != "delete" || event.API.Request.UID != "abc" || event.API.Response != nil {
+		t.Fatalf("bad api: %#v", event.API)
+	}
+	if event.SrcEndpoint == nil || event.SrcEndpoint.IP != "127.0.0.1" {
+		t.Fatalf("bad src endpoint: %#v", event.SrcEndpoint)
+	}
+	if len(event.Resources) != 1 || event.Resources[0].Name != "secret/foo" {
+		t.Fatalf("bad resources: %#v", event.Resources)
+	}
+	if event.Unmapped.Auth.ClientToken != salter.GetIdentifiedHMAC("foo") {
+		t.Fatalf("client token not hashed: %q", event.Unmapped.Auth.ClientToken)
+	}
+	if event.Time == 0 {
+		t.Fatal("time not set")
+	}
+}
+
+func TestFormatOCSF_formatResponse(t *testing.T) {
+	salter, err := salt.NewSalt(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	formatter := AuditFormatter{
+		AuditFormatWriter: &OCSFFormatWriter{
+			SaltFunc: func() (*salt.Salt, error) {
+				return salter, nil
+			},
+		},
+	}
+	req := &logical.Request{
+		Operation: logical.ListOperation,
+		Path:      "secret/",
+	}
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"keys": []string{"foo"},
+		},
+	}
+	if err := formatter.FormatResponse(&buf, FormatterConfig{Raw: true}, nil, req, resp, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var event OCSFEvent
+	if err := jsonutil.DecodeJSON(buf.Bytes(), &event); err != nil {
+		t.Fatal(err)
+	}
+
+	if event.ActivityID != ocsfActivityRead || event.Status != "Success" {
+		t.Fatalf("bad event: %#v", event)
+	}
+	if event.Metadata.LogName != "response" || event.API.Response == nil {
+		t.Fatalf("bad event: %#v", event)
+	}
+	if event.Unmapped.Response == nil || event.Unmapped.Response.Data["keys"] == nil {
+		t.Fatalf("response not included: %#v", event.Unmapped.Response)
+	}
+}
@@ -0,0 +1,118 @@
+package audit
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+)
+
+const (
+	// hashChainField is inserted at the start of each JSON entry of a hash
+	// chained log
+	hashChainField = `"prev_hmac":"`
+
+	hashChainPrefix = "hmac-sha256:"
+)
+
+// HashChain links the entries of an audit log so that changing, removing or
+// reordering an entry can be detected. Each entry carries, in its prev_hmac
+// field, the HMAC of the previous entry including that entry's own prev_hmac
+// field. The first entry of a chain has an empty prev_hmac.
+//
+// Only JSON entries can be chained. A HashChain is not safe for concurrent
+// use.
+type HashChain struct {
+	key  []byte
+	prev string
+
+	// started is set once the chain has an entry to link to
+	started bool
+}
+
+// NewHashChain returns a chain using the given HMAC key. If last is not
+// empty, it is the last entry of an existing log that the chain continues.
+func NewHashChain(key []byte, last []byte) *HashChain {
+	c := &HashChain{
+		key: key,
+	}
+	if len(bytes.TrimSpace(last)) > 0 {
+		c.prev = c.hmac(last)
+		c.started = true
+	}
+	return c
+}
+
+// Link returns the entry with its prev_hmac field set. The chain is only
+// advanced by Commit, once the entry was written.
+func (c *HashChain) Link(entry []byte) ([]byte, error) {
+	start := bytes.IndexByte(entry, '{')
+	if start == -1 {
+		return nil, fmt.Errorf("hash chaining requires JSON entries")
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(len(entry) + len(hashChainField) + len(c.prev) + 2)
+	buf.Write(entry[:start+1])
+	buf.WriteString(hashChainField)
+	buf.WriteString(c.prev)
+	buf.WriteString(`",`)
+	buf.Write(entry[start+1:])
+	return buf.Bytes(), nil
+}
+
+// Commit advances the chain past an entry returned by Link
+func (c *HashChain) Commit(linked []byte) {
+	c.prev = c.hmac(linked)
+	c.started = true
+}
+
+// Verify checks that the prev_hmac field of an entry read back from a log
+// matches the previous entry, and advances the chain past it. The first
+// entry verified by a chain created without a last entry is trusted, since
+// its predecessor is unknown.
+func (c *HashChain) Verify(entry []byte) error {
+	entry = bytes.TrimRight(entry, "\r\n")
+
+	start := bytes.Index(entry, []byte("{"+hashChainField))
+	if start == -1 {
+		return fmt.Errorf("entry has no prev_hmac field")
+	}
+	value := entry[start+1+len(hashChainField):]
+	end := bytes.IndexByte(value, '"')
+	if end == -1 {
+		return fmt.Errorf("entry has a malformed prev_hmac field")
+	}
+	value = value[:end]
+
+	if c.started && !hmac.Equal(value, []byte(c.prev)) {
+		return fmt.Errorf("prev_hmac does not match the previous entry")
+	}
+
+	c.prev = c.hmac(entry)
+	c.started = true
+	return nil
+}
+
+// ReadHashChainKey reads a hash chain key from a file. Surrounding whitespace
+// is ignored so that the key can be written with a trailing newline.
+func ReadHashChainKey(path string) ([]byte, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading hash chain key: %v", err)
+	}
+	key := bytes.TrimSpace(raw)
+	if len(key) == 0 {
+		return nil, fmt.Errorf("hash chain key file %s is empty", path)
+	}
+	return key, nil
+}
+
+// hmac returns the HMAC of an entry, ignoring the trailing newline
+func (c *HashChain) hmac(entry []byte) string {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write(bytes.TrimRight(entry, "\r\n"))
+	return hashChainPrefix + hex.EncodeToString(mac.Sum(nil))
+}
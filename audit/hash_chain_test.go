@@ -0,0 +1,83 @@
+package audit
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestHashChain(t *testing.T) {
+	key := []byte("chain-key")
+	writer := NewHashChain(key, nil)
+
+	var log [][]byte
+	for _, entry := range []string{
+		`{"type":"request","n":1}` + "\n",
+		`@cee: {"type":"response","n":2}` + "\n",
+		`{"type":"request","n":3}` + "\n",
+	} {
+		linked, err := writer.Link([]byte(entry))
+		if err != nil {
+			t.Fatal(err)
+		}
+		writer.Commit(linked)
+		log = append(log, linked)
+	}
+
+	if !bytes.HasPrefix(log[0], []byte(`{"prev_hmac":"",`)) {
+		t.Fatalf("first entry should start the chain: %s", log[0])
+	}
+	if !bytes.HasPrefix(log[1], []byte(`@cee: {"prev_hmac":"hmac-sha256:`)) {
+		t.Fatalf("prefix should be kept before the entry: %s", log[1])
+	}
+
+	verify := func(log [][]byte, key []byte) error {
+		chain := NewHashChain(key, nil)
+		for _, entry := range log {
+			if err := chain.Verify(entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := verify(log, key); err != nil {
+		t.Fatal(err)
+	}
+
+	// A log starting at a later entry is verified from there
+	if err := verify(log[1:], key); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := verify(log, []byte("other-key")); err == nil {
+		t.Fatal("expected an error with the wrong key")
+	}
+
+	tampered := append([][]byte(nil), log...)
+	tampered[1] = bytes.Replace(log[1], []byte(`"n":2`), []byte(`"n":5`), 1)
+	if err := verify(tampered, key); err == nil {
+		t.Fatal("expected an error for a modified entry")
+	}
+
+	if err := verify([][]byte{log[0], log[2]}, key); err == nil {
+		t.Fatal("expected an error for a removed entry")
+	}
+
+	if err := verify([][]byte{log[0], log[2], log[1]}, key); err == nil {
+		t.Fatal("expected an error for reordered entries")
+	}
+
+	// A chain continuing an existing log links to its last entry
+	next := NewHashChain(key, log[2])
+	linked, err := next.Link([]byte(`{"type":"request","n":4}` + "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := verify(append(log, linked), key); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := writer.Link([]byte("not json\n")); err == nil {
+		t.Fatal("expected an error for a non-JSON entry")
+	}
+}
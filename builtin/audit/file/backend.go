@@ -1,13 +1,17 @@
 package file
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/hashicorp/vault/audit"
+	"github.com/hashicorp/vault/helper/parseutil"
 	"github.com/hashicorp/vault/helper/salt"
 	"github.com/hashicorp/vault/logical"
 )
@@ -32,11 +36,6 @@ func Factory(conf *audit.BackendConfig) (audit.Backend, error) {
 	if !ok {
 		format = "json"
 	}
-	switch format {
-	case "json", "jsonx":
-	default:
-		return nil, fmt.Errorf("unknown format type %s", format)
-	}
 
 	// Check if hashing of accessor is disabled
 	hmacAccessor := true
@@ -68,28 +67,81 @@ func Factory(conf *audit.BackendConfig) (audit.Backend, error) {
 		mode = os.FileMode(m)
 	}
 
+	var rotateBytes int64
+	if raw, ok := conf.Config["rotate_bytes"]; ok {
+		value, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || value < 0 {
+			return nil, fmt.Errorf("rotate_bytes must be a non-negative integer")
+		}
+		rotateBytes = value
+	}
+
+	var rotateDuration time.Duration
+	if raw, ok := conf.Config["rotate_duration"]; ok {
+		value, err := parseutil.ParseDurationSecond(raw)
+		if err != nil {
+			return nil, err
+		}
+		rotateDuration = value
+	}
+
+	var rotateMaxFiles int
+	if raw, ok := conf.Config["rotate_max_files"]; ok {
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < 0 {
+			return nil, fmt.Errorf("rotate_max_files must be a non-negative integer")
+		}
+		rotateMaxFiles = value
+	}
+
+	rotateCompress := false
+	if raw, ok := conf.Config["rotate_compress"]; ok {
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, err
+		}
+		rotateCompress = value
+	}
+
 	b := &Backend{
-		path:       path,
-		mode:       mode,
-		saltConfig: conf.SaltConfig,
-		saltView:   conf.SaltView,
+		path:           path,
+		mode:           mode,
+		rotateBytes:    rotateBytes,
+		rotateDuration: rotateDuration,
+		rotateMaxFiles: rotateMaxFiles,
+		rotateCompress: rotateCompress,
+		saltConfig:     conf.SaltConfig,
+		saltView:       conf.SaltView,
 		formatConfig: audit.FormatterConfig{
 			Raw:          logRaw,
 			HMACAccessor: hmacAccessor,
 		},
 	}
 
-	switch format {
-	case "json":
-		b.formatter.AuditFormatWriter = &audit.JSONFormatWriter{
-			Prefix:   conf.Config["prefix"],
-			SaltFunc: b.Salt,
+	writer, err := audit.NewAuditFormatWriter(format, conf.Config["prefix"], b.Salt)
+	if err != nil {
+		return nil, err
+	}
+	b.formatter.AuditFormatWriter = writer
+
+	// Set up hash chaining, continuing from the last entry of the file if
+	// it already exists
+	if keyFile, ok := conf.Config["hash_chain_key_file"]; ok {
+		if format != "json" && format != "ocsf" {
+			return nil, fmt.Errorf("hash chaining requires the json or ocsf format")
+		}
+		if strings.Contains(conf.Config["prefix"], "{") {
+			return nil, fmt.Errorf("hash chaining requires a prefix without \"{\"")
+		}
+		key, err := audit.ReadHashChainKey(keyFile)
+		if err != nil {
+			return nil, err
 		}
-	case "jsonx":
-		b.formatter.AuditFormatWriter = &audit.JSONxFormatWriter{
-			Prefix:   conf.Config["prefix"],
-			SaltFunc: b.Salt,
+		last, err := lastLine(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading the last entry of %s: %v", path, err)
 		}
+		b.chain = audit.NewHashChain(key, last)
 	}
 
 	// Ensure that the file can be successfully opened for writing;
@@ -102,11 +154,9 @@ func Factory(conf *audit.BackendConfig) (audit.Backend, error) {
 	return b, nil
 }
 
-// Backend is the audit backend for the file-based audit store.
-//
-// NOTE: This audit backend is currently very simple: it appends to a file.
-// It doesn't do anything more at the moment to assist with rotation
-// or reset the write cursor, this should be done in the future.
+// Backend is the audit backend for the file-based audit store. It appends
+// to a file which it can rotate by size or age, optionally compressing and
+// pruning the rotated files, and can link the entries with a hash chain.
 type Backend struct {
 	path string
 
@@ -117,6 +167,22 @@ type Backend struct {
 	f        *os.File
 	mode     os.FileMode
 
+	// size and openedAt describe the open file, for rotation
+	size     int64
+	openedAt time.Time
+
+	rotateBytes    int64
+	rotateDuration time.Duration
+	rotateMaxFiles int
+	rotateCompress bool
+
+	// rotateWG tracks the compression and pruning of rotated files
+	rotateWG sync.WaitGroup
+
+	// chain is set if hash chaining is enabled. The file lock must be held
+	// to use it.
+	chain *audit.HashChain
+
 	saltMutex  sync.RWMutex
 	salt       *salt.Salt
 	saltConfig *salt.Config
@@ -152,26 +218,12 @@ func (b *Backend) GetHash(data string) (string, error) {
 }
 
 func (b *Backend) LogRequest(auth *logical.Auth, req *logical.Request, outerErr error) error {
-	b.fileLock.Lock()
-	defer b.fileLock.Unlock()
-
-	if err := b.open(); err != nil {
+	var buf bytes.Buffer
+	if err := b.formatter.FormatRequest(&buf, b.formatConfig, auth, req, outerErr); err != nil {
 		return err
 	}
 
-	if err := b.formatter.FormatRequest(b.f, b.formatConfig, auth, req, outerErr); err == nil {
-		return nil
-	}
-
-	// Opportunistically try to re-open the FD, once per call
-	b.f.Close()
-	b.f = nil
-
-	if err := b.open(); err != nil {
-		return err
-	}
-
-	return b.formatter.FormatRequest(b.f, b.formatConfig, auth, req, outerErr)
+	return b.write(buf.Bytes())
 }
 
 func (b *Backend) LogResponse(
@@ -180,26 +232,54 @@ func (b *Backend) LogResponse(
 	resp *logical.Response,
 	err error) error {
 
+	var buf bytes.Buffer
+	if err := b.formatter.FormatResponse(&buf, b.formatConfig, auth, req, resp, err); err != nil {
+		return err
+	}
+
+	return b.write(buf.Bytes())
+}
+
+func (b *Backend) write(entry []byte) error {
 	b.fileLock.Lock()
 	defer b.fileLock.Unlock()
 
+	if b.chain != nil {
+		linked, err := b.chain.Link(entry)
+		if err != nil {
+			return err
+		}
+		entry = linked
+	}
+
 	if err := b.open(); err != nil {
 		return err
 	}
 
-	if err := b.formatter.FormatResponse(b.f, b.formatConfig, auth, req, resp, err); err == nil {
-		return nil
+	if err := b.rotateIfNeeded(len(entry)); err != nil {
+		return err
 	}
 
-	// Opportunistically try to re-open the FD, once per call
-	b.f.Close()
-	b.f = nil
+	if _, err := b.f.Write(entry); err != nil {
+		// Opportunistically try to re-open the FD, once per call
+		b.f.Close()
+		b.f = nil
 
-	if err := b.open(); err != nil {
-		return err
+		if err := b.open(); err != nil {
+			return err
+		}
+
+		if _, err := b.f.Write(entry); err != nil {
+			return err
+		}
+	}
+
+	b.size += int64(len(entry))
+	if b.chain != nil {
+		b.chain.Commit(entry)
 	}
 
-	return b.formatter.FormatResponse(b.f, b.formatConfig, auth, req, resp, err)
+	return nil
 }
 
 // The file lock must be held before calling this
@@ -228,6 +308,13 @@ func (b *Backend) open() error {
 		}
 	}
 
+	info, err := b.f.Stat()
+	if err != nil {
+		return err
+	}
+	b.size = info.Size()
+	b.openedAt = time.Now()
+
 	return nil
 }
 
@@ -250,6 +337,62 @@ func (b *Backend) Reload() error {
 	return b.open()
 }
 
+// Close closes the file once the rotated files are compressed and pruned
+func (b *Backend) Close() error {
+	b.fileLock.Lock()
+	defer b.fileLock.Unlock()
+
+	b.rotateWG.Wait()
+
+	if b.f == nil {
+		return nil
+	}
+	err := b.f.Close()
+	b.f = nil
+	return err
+}
+
+// lastLine returns the last line of the file at path, or nil if it does not
+// exist or is empty
+func lastLine(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, nil
+	}
+
+	// Read backwards in growing chunks until the start of the last line is
+	// found, ignoring the newline that ends it
+	size := info.Size()
+	for chunk := int64(64 * 1024); ; chunk *= 2 {
+		if chunk > size {
+			chunk = size
+		}
+		buf := make([]byte, chunk)
+		if _, err := f.ReadAt(buf, size-chunk); err != nil {
+			return nil, err
+		}
+		buf = bytes.TrimRight(buf, "\n")
+		if i := bytes.LastIndexByte(buf, '\n'); i != -1 {
+			return buf[i+1:], nil
+		}
+		if chunk == size {
+			return buf, nil
+		}
+	}
+}
+
 func (b *Backend) Invalidate() {
 	b.saltMutex.Lock()
 	defer b.saltMutex.Unlock()
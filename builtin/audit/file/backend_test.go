@@ -1,11 +1,15 @@
 package file
 
 import (
+	"compress/gzip"
+	"encoding/json"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/vault/audit"
 	"github.com/hashicorp/vault/helper/salt"
@@ -89,3 +93,192 @@ func TestAuditFile_fileModeExisting(t *testing.T) {
 		t.Fatalf("File mode does not match.")
 	}
 }
+
+func testBackend(t *testing.T, config map[string]string) *Backend {
+	b, err := Factory(&audit.BackendConfig{
+		SaltConfig: &salt.Config{},
+		SaltView:   &logical.InmemStorage{},
+		Config:     config,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b.(*Backend)
+}
+
+func TestAuditFile_rotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-test_audit_file-rotation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "audit.log")
+	b := testBackend(t, map[string]string{
+		"path":             path,
+		"rotate_bytes":     "1024",
+		"rotate_max_files": "2",
+		"rotate_compress":  "true",
+	})
+
+	req := &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "secret/foo",
+	}
+	for i := 0; i < 20; i++ {
+		if err := b.LogRequest(nil, req, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() > 1024 {
+		t.Fatalf("file was not rotated, size is %d", info.Size())
+	}
+
+	rotated, err := b.rotatedFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rotated) != 2 {
+		t.Fatalf("expected 2 rotated files to be kept, got %v", rotated)
+	}
+	for _, file := range rotated {
+		if !strings.HasSuffix(file, ".log.gz") {
+			t.Fatalf("expected rotated file to be compressed: %s", file)
+		}
+
+		f, err := os.Open(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents, err := ioutil.ReadAll(gz)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(contents), "secret/foo") {
+			t.Fatalf("unexpected contents of %s: %s", file, contents)
+		}
+	}
+}
+
+func TestAuditFile_rotationDuration(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-test_audit_file-rotation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "audit.log")
+	b := testBackend(t, map[string]string{
+		"path":            path,
+		"rotate_duration": "1h",
+	})
+	defer b.Close()
+
+	req := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "secret/foo",
+	}
+	if err := b.LogRequest(nil, req, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Pretend the file was opened long ago
+	b.fileLock.Lock()
+	b.openedAt = time.Now().Add(-2 * time.Hour)
+	b.fileLock.Unlock()
+
+	if err := b.LogRequest(nil, req, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, err := b.rotatedFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rotated) != 1 {
+		t.Fatalf("expected 1 rotated file, got %v", rotated)
+	}
+}
+
+func TestAuditFile_hashChain(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-test_audit_file-hash_chain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	keyFile := filepath.Join(dir, "key")
+	if err := ioutil.WriteFile(keyFile, []byte("chain-key\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "audit.log")
+	config := map[string]string{
+		"path":                path,
+		"hash_chain_key_file": keyFile,
+	}
+
+	req := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "secret/foo",
+	}
+
+	// The chain continues across restarts of the backend
+	for i := 0; i < 2; i++ {
+		b := testBackend(t, config)
+		if err := b.LogRequest(nil, req, nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := b.LogResponse(nil, req, &logical.Response{}, nil); err != nil {
+			t.Fatal(err)
+		}
+		b.Close()
+	}
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(strings.TrimSpace(string(contents)), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected 4 entries, got %d", len(lines))
+	}
+
+	chain := audit.NewHashChain([]byte("chain-key"), nil)
+	for i, line := range lines {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("entry %d is not JSON: %v", i, err)
+		}
+		if _, ok := entry["prev_hmac"]; !ok {
+			t.Fatalf("entry %d has no prev_hmac: %s", i, line)
+		}
+		if err := chain.Verify([]byte(line)); err != nil {
+			t.Fatalf("entry %d: %v", i, err)
+		}
+	}
+
+	// Hash chaining is only supported with JSON entries
+	config["format"] = "jsonx"
+	_, err = Factory(&audit.BackendConfig{
+		SaltConfig: &salt.Config{},
+		SaltView:   &logical.InmemStorage{},
+		Config:     config,
+	})
+	if err == nil {
+		t.Fatal("expected an error for jsonx with hash chaining")
+	}
+}
@@ -0,0 +1,171 @@
+package file
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// rotatedTimeFormat is the timestamp inserted in the name of rotated files,
+// chosen so that the names sort chronologically
+const rotatedTimeFormat = "2006-01-02T15-04-05.000"
+
+// rotateIfNeeded rotates the file if writing an entry of the given size would
+// exceed rotate_bytes, or if it has been open for longer than
+// rotate_duration. The file lock must be held and the file open.
+func (b *Backend) rotateIfNeeded(size int) error {
+	if b.size == 0 || b.path == "/dev/null" {
+		return nil
+	}
+
+	switch {
+	case b.rotateBytes > 0 && b.size+int64(size) > b.rotateBytes:
+	case b.rotateDuration > 0 && time.Since(b.openedAt) >= b.rotateDuration:
+	default:
+		return nil
+	}
+
+	return b.rotate()
+}
+
+// rotate renames the file with a timestamp and opens a new one. Compressing
+// and pruning the rotated files happens in the background. The file lock must
+// be held.
+func (b *Backend) rotate() error {
+	if err := b.f.Close(); err != nil {
+		return err
+	}
+	b.f = nil
+
+	// Rotations within the same millisecond get the next free timestamp
+	now := time.Now()
+	rotated := b.rotatedPath(now)
+	for exists(rotated) || exists(rotated+".gz") {
+		now = now.Add(time.Millisecond)
+		rotated = b.rotatedPath(now)
+	}
+	if err := os.Rename(b.path, rotated); err != nil {
+		return err
+	}
+
+	if err := b.open(); err != nil {
+		return err
+	}
+
+	b.rotateWG.Add(1)
+	go func() {
+		defer b.rotateWG.Done()
+
+		// Errors are ignored since the rotated file is still intact, and
+		// pruning picks up files left behind on the next rotation
+		if b.rotateCompress {
+			if err := compressFile(rotated, b.mode); err != nil {
+				return
+			}
+		}
+		b.prune()
+	}()
+
+	return nil
+}
+
+// rotatedPath returns the name of the file rotated at the given time, such
+// as audit-2017-06-01T10-00-00.000.log for audit.log
+func (b *Backend) rotatedPath(t time.Time) string {
+	ext := filepath.Ext(b.path)
+	base := strings.TrimSuffix(b.path, ext)
+	return base + "-" + t.UTC().Format(rotatedTimeFormat) + ext
+}
+
+// rotatedFiles returns the rotated files, oldest first
+func (b *Backend) rotatedFiles() ([]string, error) {
+	ext := filepath.Ext(b.path)
+	base := strings.TrimSuffix(b.path, ext)
+
+	matches, err := filepath.Glob(base + "-*" + ext + "*")
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, match := range matches {
+		name := strings.TrimSuffix(match, ".gz")
+		if !strings.HasSuffix(name, ext) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, base+"-"), ext)
+		if _, err := time.Parse(rotatedTimeFormat, stamp); err != nil {
+			continue
+		}
+		files = append(files, match)
+	}
+
+	// Compare without the .gz extension so that compressed and uncompressed
+	// files sort together
+	sort.Slice(files, func(i, j int) bool {
+		return strings.TrimSuffix(files[i], ".gz") < strings.TrimSuffix(files[j], ".gz")
+	})
+	return files, nil
+}
+
+// prune removes the oldest rotated files beyond rotate_max_files
+func (b *Backend) prune() {
+	if b.rotateMaxFiles == 0 {
+		return
+	}
+
+	files, err := b.rotatedFiles()
+	if err != nil {
+		return
+	}
+	for len(files) > b.rotateMaxFiles {
+		os.Remove(files[0])
+		files = files[1:]
+	}
+}
+
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// compressFile replaces the file at path with a gzipped copy ending in .gz
+func compressFile(path string, mode os.FileMode) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmpPath := path + ".gz.tmp"
+	out, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(out)
+	if _, err := io.Copy(gz, in); err != nil {
+		out.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		out.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := os.Rename(tmpPath, path+".gz"); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Remove(path)
+}
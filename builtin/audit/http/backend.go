@@ -0,0 +1,234 @@
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-cleanhttp"
+	"github.com/hashicorp/vault/audit"
+	"github.com/hashicorp/vault/helper/parseutil"
+	"github.com/hashicorp/vault/helper/salt"
+	"github.com/hashicorp/vault/helper/spool"
+	"github.com/hashicorp/vault/logical"
+)
+
+func Factory(conf *audit.BackendConfig) (audit.Backend, error) {
+	if conf.SaltConfig == nil {
+		return nil, fmt.Errorf("nil salt config")
+	}
+	if conf.SaltView == nil {
+		return nil, fmt.Errorf("nil salt view")
+	}
+
+	address, ok := conf.Config["url"]
+	if !ok {
+		return nil, fmt.Errorf("url is required")
+	}
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %v", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("url must use the http or https scheme")
+	}
+
+	spoolConfig, err := spool.ParseConfig(conf.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	requestTimeoutRaw, ok := conf.Config["request_timeout"]
+	if !ok {
+		requestTimeoutRaw = "5s"
+	}
+	requestTimeout, err := parseutil.ParseDurationSecond(requestTimeoutRaw)
+	if err != nil {
+		return nil, err
+	}
+
+	// Entries are sent as a JSON array, so only the JSON formats are
+	// supported
+	format, ok := conf.Config["format"]
+	if !ok {
+		format = "json"
+	}
+	switch format {
+	case "json", "ocsf":
+	default:
+		return nil, fmt.Errorf("unsupported format type %s, only json and ocsf can be used", format)
+	}
+
+	// Check if hashing of accessor is disabled
+	hmacAccessor := true
+	if hmacAccessorRaw, ok := conf.Config["hmac_accessor"]; ok {
+		value, err := strconv.ParseBool(hmacAccessorRaw)
+		if err != nil {
+			return nil, err
+		}
+		hmacAccessor = value
+	}
+
+	// Check if raw logging is enabled
+	logRaw := false
+	if raw, ok := conf.Config["log_raw"]; ok {
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, err
+		}
+		logRaw = b
+	}
+
+	s, err := spool.New(spoolConfig.Dir, spoolConfig.MaxBytes)
+	if err != nil {
+		return nil, fmt.Errorf("error opening spool: %v", err)
+	}
+
+	client := cleanhttp.DefaultPooledClient()
+	client.Timeout = requestTimeout
+
+	b := &Backend{
+		saltConfig: conf.SaltConfig,
+		saltView:   conf.SaltView,
+		formatConfig: audit.FormatterConfig{
+			Raw:          logRaw,
+			HMACAccessor: hmacAccessor,
+		},
+
+		writeDuration: spoolConfig.WriteTimeout,
+		url:           address,
+		client:        client,
+		spool:         s,
+	}
+	writer, err := audit.NewAuditFormatWriter(format, "", b.Salt)
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	b.formatter.AuditFormatWriter = writer
+	b.sender = spool.NewSender(s, b.post, spoolConfig.Sender)
+
+	return b, nil
+}
+
+// Backend is the audit backend for the http audit transport. Entries are
+// queued in a spool and POSTed to the collector in batches from a background
+// goroutine, so requests are only blocked when the spool is full.
+type Backend struct {
+	formatter    audit.AuditFormatter
+	formatConfig audit.FormatterConfig
+
+	writeDuration time.Duration
+	url           string
+	client        *http.Client
+
+	spool  *spool.Spool
+	sender *spool.Sender
+
+	saltMutex  sync.RWMutex
+	salt       *salt.Salt
+	saltConfig *salt.Config
+	saltView   logical.Storage
+}
+
+func (b *Backend) GetHash(data string) (string, error) {
+	salt, err := b.Salt()
+	if err != nil {
+		return "", err
+	}
+	return audit.HashString(salt, data), nil
+}
+
+func (b *Backend) LogRequest(auth *logical.Auth, req *logical.Request, outerErr error) error {
+	var buf bytes.Buffer
+	if err := b.formatter.FormatRequest(&buf, b.formatConfig, auth, req, outerErr); err != nil {
+		return err
+	}
+
+	return b.queue(buf.Bytes())
+}
+
+func (b *Backend) LogResponse(auth *logical.Auth, req *logical.Request,
+	resp *logical.Response, outerErr error) error {
+	var buf bytes.Buffer
+	if err := b.formatter.FormatResponse(&buf, b.formatConfig, auth, req, resp, outerErr); err != nil {
+		return err
+	}
+
+	return b.queue(buf.Bytes())
+}
+
+func (b *Backend) queue(entry []byte) error {
+	err := b.spool.Append(bytes.TrimSpace(entry), b.writeDuration)
+	if err == spool.ErrFull {
+		if lastErr := b.sender.LastError(); lastErr != nil {
+			return fmt.Errorf("%v, last delivery error: %v", err, lastErr)
+		}
+	}
+	return err
+}
+
+// post sends a batch of entries to the collector as a JSON array
+func (b *Backend) post(batch [][]byte) error {
+	var body bytes.Buffer
+	body.WriteByte('[')
+	body.Write(bytes.Join(batch, []byte{','}))
+	body.WriteByte(']')
+
+	resp, err := b.client.Post(b.url, "application/json", &body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Drain the body so the connection can be reused
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %q from %s", resp.Status, b.url)
+	}
+	return nil
+}
+
+func (b *Backend) Reload() error {
+	return nil
+}
+
+// Close stops delivering entries. Entries that were not yet delivered are
+// kept in the spool directory, if one is configured.
+func (b *Backend) Close() error {
+	b.sender.Stop()
+	return b.spool.Close()
+}
+
+func (b *Backend) Salt() (*salt.Salt, error) {
+	b.saltMutex.RLock()
+	if b.salt != nil {
+		defer b.saltMutex.RUnlock()
+		return b.salt, nil
+	}
+	b.saltMutex.RUnlock()
+	b.saltMutex.Lock()
+	defer b.saltMutex.Unlock()
+	if b.salt != nil {
+		return b.salt, nil
+	}
+	salt, err := salt.NewSalt(b.saltView, b.saltConfig)
+	if err != nil {
+		return nil, err
+	}
+	b.salt = salt
+	return salt, nil
+}
+
+func (b *Backend) Invalidate() {
+	b.saltMutex.Lock()
+	defer b.saltMutex.Unlock()
+	b.salt = nil
+}
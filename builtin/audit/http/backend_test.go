@@ -0,0 +1,254 @@
+package http
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/audit"
+	"github.com/hashicorp/vault/helper/salt"
+	"github.com/hashicorp/vault/logical"
+)
+
+// collector is a webhook endpoint recording the batches it receives. It
+// fails the first requests to exercise retries.
+type collector struct {
+	sync.Mutex
+	failures int
+	batches  [][]map[string]interface{}
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.Lock()
+	defer c.Unlock()
+
+	if r.Method != "POST" || r.Header.Get("Content-Type") != "application/json" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if c.failures > 0 {
+		c.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	var batch []map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	c.batches = append(c.batches, batch)
+}
+
+func (c *collector) entries() []map[string]interface{} {
+	c.Lock()
+	defer c.Unlock()
+	var out []map[string]interface{}
+	for _, batch := range c.batches {
+		out = append(out, batch...)
+	}
+	return out
+}
+
+func testBackend(t *testing.T, config map[string]string) *Backend {
+	b, err := Factory(&audit.BackendConfig{
+		SaltConfig: &salt.Config{},
+		SaltView:   &logical.InmemStorage{},
+		Config:     config,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b.(*Backend)
+}
+
+func waitForEntries(t *testing.T, c *collector, n int) []map[string]interface{} {
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		if entries := c.entries(); len(entries) >= n {
+			return entries
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("collector did not receive %d entries, got %d", n, len(c.entries()))
+	return nil
+}
+
+func TestAuditHTTP_batches(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-test_audit_http")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := &collector{failures: 2}
+	server := httptest.NewServer(c)
+	defer server.Close()
+
+	b := testBackend(t, map[string]string{
+		"url":            server.URL,
+		"spool_dir":      dir,
+		"batch_size":     "3",
+		"batch_interval": "100ms",
+		"max_backoff":    "100ms",
+	})
+	defer b.Close()
+
+	req := &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "secret/foo",
+	}
+	for i := 0; i < 4; i++ {
+		if err := b.LogRequest(nil, req, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.LogResponse(nil, req, &logical.Response{}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := waitForEntries(t, c, 5)
+	if len(entries) != 5 {
+		t.Fatalf("expected 5 entries, got %d", len(entries))
+	}
+	for i, entry := range entries {
+		expected := "request"
+		if i == 4 {
+			expected = "response"
+		}
+		if entry["type"] != expected {
+			t.Fatalf("bad: entry %d: %#v", i, entry)
+		}
+		if entry["request"].(map[string]interface{})["path"] != "secret/foo" {
+			t.Fatalf("bad: entry %d: %#v", i, entry)
+		}
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	if len(c.batches) != 2 || len(c.batches[0]) != 3 {
+		t.Fatalf("expected a batch of 3 and a batch of 2, got %d batches", len(c.batches))
+	}
+}
+
+func TestAuditHTTP_spool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-test_audit_http")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Nothing is listening on the address of a closed server
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	config := map[string]string{
+		"url":             down.URL,
+		"spool_dir":       dir,
+		"spool_max_bytes": "2048",
+		"write_timeout":   "50ms",
+		"batch_interval":  "0",
+		"max_backoff":     "50ms",
+	}
+	b := testBackend(t, config)
+
+	// Entries are accepted until the spool fills up, after which requests
+	// fail with the delivery error
+	req := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "secret/foo",
+	}
+	var spooled int
+	for ; spooled < 100; spooled++ {
+		err := b.LogRequest(nil, req, nil)
+		if err == nil {
+			continue
+		}
+		if !strings.Contains(err.Error(), "spool is full") {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		break
+	}
+	if spooled == 0 || spooled == 100 {
+		t.Fatalf("expected the spool to fill up, spooled %d entries", spooled)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The spooled entries are delivered once a collector is available
+	c := &collector{}
+	server := httptest.NewServer(c)
+	defer server.Close()
+
+	config["url"] = server.URL
+	b = testBackend(t, config)
+	defer b.Close()
+
+	entries := waitForEntries(t, c, spooled)
+	if len(entries) != spooled {
+		t.Fatalf("expected %d entries, got %d", spooled, len(entries))
+	}
+}
+
+func TestAuditHTTP_sharedSpoolDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-test_audit_http")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := map[string]string{
+		"url":       "http://localhost",
+		"spool_dir": dir,
+	}
+	b := testBackend(t, config)
+
+	_, err = Factory(&audit.BackendConfig{
+		SaltConfig: &salt.Config{},
+		SaltView:   &logical.InmemStorage{},
+		Config:     config,
+	})
+	if err == nil || !strings.Contains(err.Error(), "already in use") {
+		t.Fatalf("expected the spool directory to be rejected, got: %v", err)
+	}
+
+	// The directory can be used again once the backend is closed
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	b = testBackend(t, config)
+	b.Close()
+}
+
+func TestAuditHTTP_config(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-test_audit_http")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, config := range []map[string]string{
+		{},
+		{"url": "http://localhost"},
+		{"url": "ftp://localhost", "spool_dir": dir},
+		{"url": "http://localhost", "spool_dir": dir, "format": "jsonx"},
+		{"url": "http://localhost", "spool_dir": dir, "batch_size": "0"},
+		{"url": "http://localhost", "spool_dir": dir, "spool_max_bytes": "-1"},
+	} {
+		_, err := Factory(&audit.BackendConfig{
+			SaltConfig: &salt.Config{},
+			SaltView:   &logical.InmemStorage{},
+			Config:     config,
+		})
+		if err == nil {
+			t.Fatalf("expected an error for config %v", config)
+		}
+	}
+}
@@ -0,0 +1,318 @@
+package kafka
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/audit"
+	"github.com/hashicorp/vault/helper/parseutil"
+	"github.com/hashicorp/vault/helper/salt"
+	"github.com/hashicorp/vault/helper/spool"
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/helper/tlsutil"
+	"github.com/hashicorp/vault/logical"
+)
+
+func Factory(conf *audit.BackendConfig) (audit.Backend, error) {
+	if conf.SaltConfig == nil {
+		return nil, fmt.Errorf("nil salt config")
+	}
+	if conf.SaltView == nil {
+		return nil, fmt.Errorf("nil salt view")
+	}
+
+	brokers := strutil.ParseDedupAndSortStrings(conf.Config["brokers"], ",")
+	if len(brokers) == 0 {
+		return nil, fmt.Errorf("brokers is required")
+	}
+
+	topic, ok := conf.Config["topic"]
+	if !ok || topic == "" {
+		return nil, fmt.Errorf("topic is required")
+	}
+
+	partition := int32(0)
+	if raw, ok := conf.Config["partition"]; ok {
+		value, err := strconv.ParseInt(raw, 10, 32)
+		if err != nil || value < 0 {
+			return nil, fmt.Errorf("partition must be a non-negative integer")
+		}
+		partition = int32(value)
+	}
+
+	acks := int16(-1)
+	if raw, ok := conf.Config["required_acks"]; ok {
+		value, err := strconv.ParseInt(raw, 10, 16)
+		if err != nil || value < -1 {
+			return nil, fmt.Errorf("required_acks must be -1, 0 or a number of replicas")
+		}
+		acks = int16(value)
+	}
+
+	requestTimeoutRaw, ok := conf.Config["request_timeout"]
+	if !ok {
+		requestTimeoutRaw = "5s"
+	}
+	requestTimeout, err := parseutil.ParseDurationSecond(requestTimeoutRaw)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig, err := parseTLSConfig(conf.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	// SASL PLAIN sends the password as is, so it is only allowed over TLS
+	saslUsername := conf.Config["sasl_username"]
+	saslPassword := conf.Config["sasl_password"]
+	if saslUsername != "" || saslPassword != "" {
+		if saslUsername == "" || saslPassword == "" {
+			return nil, fmt.Errorf("sasl_username and sasl_password must be set together")
+		}
+		if tlsConfig == nil {
+			return nil, fmt.Errorf("sasl_username requires tls to be enabled")
+		}
+	}
+
+	spoolConfig, err := spool.ParseConfig(conf.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	format, ok := conf.Config["format"]
+	if !ok {
+		format = "json"
+	}
+
+	// Check if hashing of accessor is disabled
+	hmacAccessor := true
+	if hmacAccessorRaw, ok := conf.Config["hmac_accessor"]; ok {
+		value, err := strconv.ParseBool(hmacAccessorRaw)
+		if err != nil {
+			return nil, err
+		}
+		hmacAccessor = value
+	}
+
+	// Check if raw logging is enabled
+	logRaw := false
+	if raw, ok := conf.Config["log_raw"]; ok {
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, err
+		}
+		logRaw = b
+	}
+	if logRaw && tlsConfig == nil {
+		return nil, fmt.Errorf("log_raw requires tls to be enabled, since entries would be sent to the brokers in plaintext")
+	}
+
+	s, err := spool.New(spoolConfig.Dir, spoolConfig.MaxBytes)
+	if err != nil {
+		return nil, fmt.Errorf("error opening spool: %v", err)
+	}
+
+	b := &Backend{
+		saltConfig: conf.SaltConfig,
+		saltView:   conf.SaltView,
+		formatConfig: audit.FormatterConfig{
+			Raw:          logRaw,
+			HMACAccessor: hmacAccessor,
+		},
+
+		writeDuration: spoolConfig.WriteTimeout,
+		producer: &producer{
+			brokers:   brokers,
+			topic:     topic,
+			partition: partition,
+			acks:      acks,
+			timeout:   requestTimeout,
+
+			tlsConfig:    tlsConfig,
+			saslUsername: saslUsername,
+			saslPassword: saslPassword,
+		},
+		spool: s,
+	}
+
+	writer, err := audit.NewAuditFormatWriter(format, conf.Config["prefix"], b.Salt)
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	b.formatter.AuditFormatWriter = writer
+
+	// The sender is the only user of the producer, so the connection needs
+	// no locking
+	b.sender = spool.NewSender(s, b.producer.produce, spoolConfig.Sender)
+
+	return b, nil
+}
+
+// Backend is the audit backend for the kafka audit transport. Each entry is
+// produced as a record to a single topic partition. Entries are queued in a
+// spool and produced in batches from a background goroutine, so requests are
+// only blocked when the spool is full.
+type Backend struct {
+	formatter    audit.AuditFormatter
+	formatConfig audit.FormatterConfig
+
+	writeDuration time.Duration
+	producer      *producer
+
+	spool  *spool.Spool
+	sender *spool.Sender
+
+	saltMutex  sync.RWMutex
+	salt       *salt.Salt
+	saltConfig *salt.Config
+	saltView   logical.Storage
+}
+
+func (b *Backend) GetHash(data string) (string, error) {
+	salt, err := b.Salt()
+	if err != nil {
+		return "", err
+	}
+	return audit.HashString(salt, data), nil
+}
+
+func (b *Backend) LogRequest(auth *logical.Auth, req *logical.Request, outerErr error) error {
+	var buf bytes.Buffer
+	if err := b.formatter.FormatRequest(&buf, b.formatConfig, auth, req, outerErr); err != nil {
+		return err
+	}
+
+	return b.queue(buf.Bytes())
+}
+
+func (b *Backend) LogResponse(auth *logical.Auth, req *logical.Request,
+	resp *logical.Response, outerErr error) error {
+	var buf bytes.Buffer
+	if err := b.formatter.FormatResponse(&buf, b.formatConfig, auth, req, resp, outerErr); err != nil {
+		return err
+	}
+
+	return b.queue(buf.Bytes())
+}
+
+func (b *Backend) queue(entry []byte) error {
+	err := b.spool.Append(bytes.TrimRight(entry, "\n"), b.writeDuration)
+	if err == spool.ErrFull {
+		if lastErr := b.sender.LastError(); lastErr != nil {
+			return fmt.Errorf("%v, last delivery error: %v", err, lastErr)
+		}
+	}
+	return err
+}
+
+func (b *Backend) Reload() error {
+	return nil
+}
+
+// Close stops producing entries. Entries that were not yet produced are
+// kept in the spool directory, if one is configured.
+func (b *Backend) Close() error {
+	b.sender.Stop()
+	b.producer.close()
+	return b.spool.Close()
+}
+
+func (b *Backend) Salt() (*salt.Salt, error) {
+	b.saltMutex.RLock()
+	if b.salt != nil {
+		defer b.saltMutex.RUnlock()
+		return b.salt, nil
+	}
+	b.saltMutex.RUnlock()
+	b.saltMutex.Lock()
+	defer b.saltMutex.Unlock()
+	if b.salt != nil {
+		return b.salt, nil
+	}
+	salt, err := salt.NewSalt(b.saltView, b.saltConfig)
+	if err != nil {
+		return nil, err
+	}
+	b.salt = salt
+	return salt, nil
+}
+
+func (b *Backend) Invalidate() {
+	b.saltMutex.Lock()
+	defer b.saltMutex.Unlock()
+	b.salt = nil
+}
+
+// parseTLSConfig returns the configuration used to connect to the brokers
+// over TLS, or nil if tls is not enabled
+func parseTLSConfig(conf map[string]string) (*tls.Config, error) {
+	enabled := false
+	if raw, ok := conf["tls"]; ok {
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("tls must be a boolean")
+		}
+		enabled = value
+	}
+	if !enabled {
+		return nil, nil
+	}
+
+	tlsMinVersionStr, ok := conf["tls_min_version"]
+	if !ok {
+		tlsMinVersionStr = "tls12"
+	}
+	tlsMinVersion, ok := tlsutil.TLSLookup[tlsMinVersionStr]
+	if !ok {
+		return nil, fmt.Errorf("invalid tls_min_version")
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion: tlsMinVersion,
+		ServerName: conf["tls_server_name"],
+	}
+
+	if raw, ok := conf["tls_skip_verify"]; ok {
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("tls_skip_verify must be a boolean")
+		}
+		tlsConfig.InsecureSkipVerify = value
+	}
+
+	certFile, okCert := conf["tls_cert_file"]
+	keyFile, okKey := conf["tls_key_file"]
+	switch {
+	case okCert && okKey:
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("client tls setup failed: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	case okCert || okKey:
+		return nil, fmt.Errorf("tls_cert_file and tls_key_file must be set together")
+	}
+
+	if caFile, ok := conf["tls_ca_file"]; ok {
+		data, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %v", err)
+		}
+
+		caPool := x509.NewCertPool()
+		if !caPool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("failed to parse CA certificate")
+		}
+		tlsConfig.RootCAs = caPool
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,266 @@
+package kafka
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/audit"
+	"github.com/hashicorp/vault/helper/salt"
+	"github.com/hashicorp/vault/logical"
+)
+
+func testBackend(t *testing.T, config map[string]string) *Backend {
+	b, err := Factory(&audit.BackendConfig{
+		SaltConfig: &salt.Config{},
+		SaltView:   &logical.InmemStorage{},
+		Config:     config,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b.(*Backend)
+}
+
+func waitForValues(t *testing.T, stub *kafkaStub, n int) []string {
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		if values := stub.produced(); len(values) >= n {
+			return values
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("broker did not receive %d records, got %d", n, len(stub.produced()))
+	return nil
+}
+
+func TestAuditKafka_produce(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-test_audit_kafka")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	stub := newKafkaStub(t, "vault-audit")
+	defer stub.close()
+
+	// The first attempt fails as if leadership moved, and is retried
+	stub.produceErrors = []int16{6}
+
+	b := testBackend(t, map[string]string{
+		"brokers":        stub.addr(),
+		"topic":          "vault-audit",
+		"spool_dir":      dir,
+		"batch_size":     "2",
+		"batch_interval": "100ms",
+		"max_backoff":    "100ms",
+	})
+	defer b.Close()
+
+	req := &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "secret/foo",
+	}
+	if err := b.LogRequest(nil, req, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.LogResponse(nil, req, &logical.Response{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.LogRequest(nil, req, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	values := waitForValues(t, stub, 3)
+	var types []string
+	for _, value := range values {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(value), &entry); err != nil {
+			t.Fatalf("record is not JSON: %q", value)
+		}
+		types = append(types, entry["type"].(string))
+	}
+	if expected := []string{"request", "response", "request"}; !reflect.DeepEqual(types, expected) {
+		t.Fatalf("bad: expected %v, got %v", expected, types)
+	}
+}
+
+func TestAuditKafka_spool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-test_audit_kafka")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Nothing is listening on the address of a closed listener
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	down := listener.Addr().String()
+	listener.Close()
+
+	config := map[string]string{
+		"brokers":         down,
+		"topic":           "vault-audit",
+		"spool_dir":       dir,
+		"spool_max_bytes": "2048",
+		"write_timeout":   "50ms",
+		"batch_interval":  "0",
+		"max_backoff":     "50ms",
+	}
+	b := testBackend(t, config)
+
+	req := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "secret/foo",
+	}
+	var spooled int
+	for ; spooled < 100; spooled++ {
+		err := b.LogRequest(nil, req, nil)
+		if err == nil {
+			continue
+		}
+		if !strings.Contains(err.Error(), "spool is full") {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		break
+	}
+	if spooled == 0 || spooled == 100 {
+		t.Fatalf("expected the spool to fill up, spooled %d entries", spooled)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	stub := newKafkaStub(t, "vault-audit")
+	defer stub.close()
+
+	config["brokers"] = stub.addr()
+	b = testBackend(t, config)
+	defer b.Close()
+
+	values := waitForValues(t, stub, spooled)
+	if len(values) != spooled {
+		t.Fatalf("expected %d records, got %d", spooled, len(values))
+	}
+}
+
+func TestAuditKafka_tlsSASL(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-test_audit_kafka")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	serverConfig, caFile := testTLSConfig(t, dir)
+	stub := newSecureKafkaStub(t, "vault-audit", serverConfig, "vault", "secret")
+	defer stub.close()
+
+	b := testBackend(t, map[string]string{
+		"brokers":        stub.addr(),
+		"topic":          "vault-audit",
+		"spool_dir":      filepath.Join(dir, "spool"),
+		"batch_interval": "0",
+		"max_backoff":    "100ms",
+		"tls":            "true",
+		"tls_ca_file":    caFile,
+		"sasl_username":  "vault",
+		"sasl_password":  "secret",
+		"log_raw":        "true",
+	})
+	defer b.Close()
+
+	req := &logical.Request{
+		Operation:   logical.UpdateOperation,
+		Path:        "secret/foo",
+		ClientToken: "client-token",
+	}
+	if err := b.LogRequest(nil, req, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	values := waitForValues(t, stub, 1)
+	if !strings.Contains(values[0], "client-token") {
+		t.Fatalf("expected the raw entry, got: %s", values[0])
+	}
+
+	// Wrong credentials are rejected by the broker
+	tlsConfig, err := parseTLSConfig(map[string]string{
+		"tls":         "true",
+		"tls_ca_file": caFile,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &producer{
+		brokers:      []string{stub.addr()},
+		topic:        "vault-audit",
+		acks:         -1,
+		timeout:      5 * time.Second,
+		tlsConfig:    tlsConfig,
+		saslUsername: "vault",
+		saslPassword: "wrong",
+	}
+	defer p.close()
+
+	err = p.produce([][]byte{[]byte("value")})
+	if err == nil || !strings.Contains(err.Error(), "sasl authentication failed") {
+		t.Fatalf("expected an authentication error, got: %v", err)
+	}
+}
+
+func TestAuditKafka_unknownTopic(t *testing.T) {
+	stub := newKafkaStub(t, "vault-audit")
+	defer stub.close()
+
+	p := &producer{
+		brokers: []string{stub.addr()},
+		topic:   "other",
+		acks:    -1,
+		timeout: 5 * time.Second,
+	}
+	defer p.close()
+
+	err := p.produce([][]byte{[]byte("value")})
+	if err == nil || !strings.Contains(err.Error(), "unknown topic or partition") {
+		t.Fatalf("expected an unknown topic error, got: %v", err)
+	}
+}
+
+func TestAuditKafka_config(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-test_audit_kafka")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, config := range []map[string]string{
+		{},
+		{"brokers": "localhost:9092", "spool_dir": dir},
+		{"brokers": "localhost:9092", "topic": "audit"},
+		{"brokers": "localhost:9092", "topic": "audit", "spool_dir": dir, "partition": "-1"},
+		{"brokers": "localhost:9092", "topic": "audit", "spool_dir": dir, "required_acks": "-2"},
+		{"brokers": "localhost:9092", "topic": "audit", "spool_dir": dir, "format": "xml"},
+		{"brokers": "localhost:9092", "topic": "audit", "spool_dir": dir, "log_raw": "true"},
+		{"brokers": "localhost:9092", "topic": "audit", "spool_dir": dir, "sasl_username": "vault", "sasl_password": "secret"},
+		{"brokers": "localhost:9092", "topic": "audit", "spool_dir": dir, "tls": "true", "sasl_username": "vault"},
+		{"brokers": "localhost:9092", "topic": "audit", "spool_dir": dir, "tls": "true", "tls_cert_file": "cert.pem"},
+		{"brokers": "localhost:9092", "topic": "audit", "spool_dir": dir, "tls": "true", "tls_min_version": "ssl3"},
+	} {
+		_, err := Factory(&audit.BackendConfig{
+			SaltConfig: &salt.Config{},
+			SaltView:   &logical.InmemStorage{},
+			Config:     config,
+		})
+		if err == nil {
+			t.Fatalf("expected an error for config %v", config)
+		}
+	}
+}
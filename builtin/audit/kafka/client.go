@@ -0,0 +1,503 @@
+package kafka
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"time"
+)
+
+// The subset of the Kafka protocol used to produce records: Metadata v1 to
+// find the leader of the partition and Produce v3, which carries v2 record
+// batches, and SaslHandshake v0 to authenticate. These are supported by Kafka
+// 0.11 and later and by Kafka-compatible brokers.
+const (
+	apiKeyProduce       = 0
+	apiKeyMetadata      = 3
+	apiKeySaslHandshake = 17
+
+	produceVersion       = 3
+	metadataVersion      = 1
+	saslHandshakeVersion = 0
+
+	saslMechanismPlain = "PLAIN"
+
+	recordBatchMagic = 2
+
+	clientID = "vault-audit"
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// kafkaError is an error code returned by a broker
+type kafkaError int16
+
+func (e kafkaError) Error() string {
+	switch e {
+	case 3:
+		return "unknown topic or partition"
+	case 5:
+		return "leader not available"
+	case 6:
+		return "not leader for partition"
+	case 7:
+		return "request timed out"
+	case 10:
+		return "message too large"
+	case 19, 20:
+		return "not enough replicas"
+	case 29:
+		return "topic authorization failed"
+	case 33:
+		return "unsupported sasl mechanism"
+	case 34:
+		return "illegal sasl state"
+	}
+	return "kafka error code " + strconv.Itoa(int(e))
+}
+
+// producer sends record batches to the leader of a single topic partition
+type producer struct {
+	brokers   []string
+	topic     string
+	partition int32
+	acks      int16
+	timeout   time.Duration
+
+	// tlsConfig, if set, is used to connect to the brokers over TLS
+	tlsConfig *tls.Config
+
+	// saslUsername and saslPassword, if set, are used to authenticate to
+	// the brokers with SASL PLAIN
+	saslUsername string
+	saslPassword string
+
+	conn          net.Conn
+	correlationID int32
+}
+
+// produce sends the values as a single record batch, connecting to the
+// partition leader first if needed
+func (p *producer) produce(values [][]byte) error {
+	if p.conn == nil {
+		if err := p.connectLeader(); err != nil {
+			return err
+		}
+	}
+
+	err := p.sendProduce(values)
+	if err != nil {
+		// Leadership may have moved, or the connection broke, so look up
+		// the leader again on the next attempt
+		p.close()
+	}
+	return err
+}
+
+func (p *producer) close() {
+	if p.conn != nil {
+		p.conn.Close()
+		p.conn = nil
+	}
+}
+
+// connectLeader asks the configured brokers for the leader of the partition
+// and connects to it
+func (p *producer) connectLeader() error {
+	var lastErr error
+	for _, broker := range p.brokers {
+		conn, err := p.dial(broker)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		leader, err := p.findLeader(conn)
+		if err != nil {
+			conn.Close()
+			lastErr = fmt.Errorf("error fetching metadata from %s: %v", broker, err)
+			continue
+		}
+
+		if leader == broker {
+			p.conn = conn
+			return nil
+		}
+		conn.Close()
+
+		p.conn, err = p.dial(leader)
+		if err != nil {
+			return fmt.Errorf("error connecting to partition leader %s: %v", leader, err)
+		}
+		return nil
+	}
+
+	return lastErr
+}
+
+// dial connects to a broker, over TLS and authenticated with SASL if they
+// are configured
+func (p *producer) dial(addr string) (net.Conn, error) {
+	conn, err := net.DialTimeout("tcp", addr, p.timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.tlsConfig != nil {
+		config := p.tlsConfig.Clone()
+		if config.ServerName == "" {
+			host, _, err := net.SplitHostPort(addr)
+			if err != nil {
+				conn.Close()
+				return nil, err
+			}
+			config.ServerName = host
+		}
+
+		tlsConn := tls.Client(conn, config)
+		if err := tlsConn.SetDeadline(time.Now().Add(p.timeout)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	if p.saslUsername != "" {
+		if err := p.authenticate(conn); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("sasl authentication failed: %v", err)
+		}
+	}
+
+	return conn, nil
+}
+
+// authenticate performs a SASL PLAIN exchange on the connection. After the
+// handshake the token is sent framed only by its length, without a request
+// header, and the broker closes the connection if it rejects the
+// credentials.
+func (p *producer) authenticate(conn net.Conn) error {
+	var body bytes.Buffer
+	writeString(&body, saslMechanismPlain)
+
+	resp, err := p.roundTrip(conn, apiKeySaslHandshake, saslHandshakeVersion, body.Bytes())
+	if err != nil {
+		return err
+	}
+	r := &reader{buf: resp}
+	if code := r.int16(); code != 0 {
+		return kafkaError(code)
+	}
+	if r.err != nil {
+		return r.err
+	}
+
+	token := "\x00" + p.saslUsername + "\x00" + p.saslPassword
+	var req bytes.Buffer
+	writeInt32(&req, int32(len(token)))
+	req.WriteString(token)
+
+	if err := conn.SetDeadline(time.Now().Add(p.timeout)); err != nil {
+		return err
+	}
+	if _, err := conn.Write(req.Bytes()); err != nil {
+		return err
+	}
+
+	var size [4]byte
+	if _, err := io.ReadFull(conn, size[:]); err != nil {
+		return err
+	}
+	_, err = io.CopyN(ioutil.Discard, conn, int64(binary.BigEndian.Uint32(size[:])))
+	return err
+}
+
+// findLeader returns the address of the leader of the partition
+func (p *producer) findLeader(conn net.Conn) (string, error) {
+	var body bytes.Buffer
+	writeInt32(&body, 1)
+	writeString(&body, p.topic)
+
+	resp, err := p.roundTrip(conn, apiKeyMetadata, metadataVersion, body.Bytes())
+	if err != nil {
+		return "", err
+	}
+
+	r := &reader{buf: resp}
+	brokers := make(map[int32]string)
+	for n := r.int32(); n > 0 && r.err == nil; n-- {
+		nodeID := r.int32()
+		host := r.string()
+		port := r.int32()
+		r.string() // rack
+		brokers[nodeID] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+	r.int32() // controller id
+
+	for n := r.int32(); n > 0 && r.err == nil; n-- {
+		topicErr := r.int16()
+		topic := r.string()
+		r.int8() // is internal
+		for m := r.int32(); m > 0 && r.err == nil; m-- {
+			partitionErr := r.int16()
+			partition := r.int32()
+			leader := r.int32()
+			r.int32Array() // replicas
+			r.int32Array() // isr
+
+			if topic != p.topic || partition != p.partition {
+				continue
+			}
+			if topicErr != 0 {
+				return "", kafkaError(topicErr)
+			}
+			if partitionErr != 0 {
+				return "", kafkaError(partitionErr)
+			}
+			addr, ok := brokers[leader]
+			if !ok {
+				return "", kafkaError(5)
+			}
+			return addr, nil
+		}
+		if topic == p.topic && topicErr != 0 {
+			return "", kafkaError(topicErr)
+		}
+	}
+	if r.err != nil {
+		return "", r.err
+	}
+
+	return "", kafkaError(3)
+}
+
+func (p *producer) sendProduce(values [][]byte) error {
+	batch := encodeRecordBatch(values, time.Now())
+
+	var body bytes.Buffer
+	writeInt16(&body, -1) // null transactional id
+	writeInt16(&body, p.acks)
+	writeInt32(&body, int32(p.timeout/time.Millisecond))
+	writeInt32(&body, 1)
+	writeString(&body, p.topic)
+	writeInt32(&body, 1)
+	writeInt32(&body, p.partition)
+	writeInt32(&body, int32(len(batch)))
+	body.Write(batch)
+
+	if p.acks == 0 {
+		// Brokers do not respond to produce requests without acks
+		return p.send(p.conn, apiKeyProduce, produceVersion, body.Bytes())
+	}
+
+	resp, err := p.roundTrip(p.conn, apiKeyProduce, produceVersion, body.Bytes())
+	if err != nil {
+		return err
+	}
+
+	r := &reader{buf: resp}
+	for n := r.int32(); n > 0 && r.err == nil; n-- {
+		r.string() // topic
+		for m := r.int32(); m > 0 && r.err == nil; m-- {
+			r.int32() // partition
+			if code := r.int16(); code != 0 {
+				return kafkaError(code)
+			}
+			r.int64() // base offset
+			r.int64() // log append time
+		}
+	}
+
+	return r.err
+}
+
+// send writes a request to the connection
+func (p *producer) send(conn net.Conn, apiKey, apiVersion int16, body []byte) error {
+	p.correlationID++
+
+	var req bytes.Buffer
+	writeInt32(&req, int32(2+2+4+2+len(clientID)+len(body)))
+	writeInt16(&req, apiKey)
+	writeInt16(&req, apiVersion)
+	writeInt32(&req, p.correlationID)
+	writeString(&req, clientID)
+	req.Write(body)
+
+	if err := conn.SetDeadline(time.Now().Add(p.timeout)); err != nil {
+		return err
+	}
+	_, err := conn.Write(req.Bytes())
+	return err
+}
+
+// roundTrip writes a request to the connection and returns the body of the
+// response
+func (p *producer) roundTrip(conn net.Conn, apiKey, apiVersion int16, body []byte) ([]byte, error) {
+	if err := p.send(conn, apiKey, apiVersion, body); err != nil {
+		return nil, err
+	}
+
+	var header [8]byte
+	if _, err := io.ReadFull(conn, header[:]); err != nil {
+		return nil, err
+	}
+	size := int32(binary.BigEndian.Uint32(header[:4]))
+	if size < 4 {
+		return nil, fmt.Errorf("invalid response size %d", size)
+	}
+	if id := int32(binary.BigEndian.Uint32(header[4:])); id != p.correlationID {
+		return nil, fmt.Errorf("unexpected correlation id %d, expected %d", id, p.correlationID)
+	}
+
+	resp := make([]byte, size-4)
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// encodeRecordBatch encodes the values as a v2 record batch without keys
+// or headers
+func encodeRecordBatch(values [][]byte, now time.Time) []byte {
+	timestamp := now.UnixNano() / int64(time.Millisecond)
+
+	var records bytes.Buffer
+	var varint [binary.MaxVarintLen64]byte
+	for i, value := range values {
+		var record bytes.Buffer
+		record.WriteByte(0)                                          // attributes
+		record.Write(varint[:binary.PutVarint(varint[:], 0)])        // timestamp delta
+		record.Write(varint[:binary.PutVarint(varint[:], int64(i))]) // offset delta
+		record.Write(varint[:binary.PutVarint(varint[:], -1)])       // null key
+		record.Write(varint[:binary.PutVarint(varint[:], int64(len(value)))])
+		record.Write(value)
+		record.Write(varint[:binary.PutVarint(varint[:], 0)]) // headers
+
+		records.Write(varint[:binary.PutVarint(varint[:], int64(record.Len()))])
+		records.Write(record.Bytes())
+	}
+
+	// The CRC covers everything from the attributes to the end of the batch
+	var crcd bytes.Buffer
+	writeInt16(&crcd, 0) // attributes
+	writeInt32(&crcd, int32(len(values)-1))
+	writeInt64(&crcd, timestamp)
+	writeInt64(&crcd, timestamp)
+	writeInt64(&crcd, -1) // producer id
+	writeInt16(&crcd, -1) // producer epoch
+	writeInt32(&crcd, -1) // base sequence
+	writeInt32(&crcd, int32(len(values)))
+	crcd.Write(records.Bytes())
+
+	var batch bytes.Buffer
+	writeInt64(&batch, 0) // base offset
+	writeInt32(&batch, int32(4+1+4+crcd.Len()))
+	writeInt32(&batch, -1) // partition leader epoch
+	batch.WriteByte(recordBatchMagic)
+	writeInt32(&batch, int32(crc32.Checksum(crcd.Bytes(), castagnoli)))
+	batch.Write(crcd.Bytes())
+
+	return batch.Bytes()
+}
+
+func writeInt16(w *bytes.Buffer, v int16) {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], uint16(v))
+	w.Write(b[:])
+}
+
+func writeInt32(w *bytes.Buffer, v int32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], uint32(v))
+	w.Write(b[:])
+}
+
+func writeInt64(w *bytes.Buffer, v int64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(v))
+	w.Write(b[:])
+}
+
+func writeString(w *bytes.Buffer, s string) {
+	writeInt16(w, int16(len(s)))
+	w.WriteString(s)
+}
+
+// reader decodes the fields of a response, recording the first error so
+// that callers only need to check it once
+type reader struct {
+	buf []byte
+	err error
+}
+
+func (r *reader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.buf) {
+		r.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+func (r *reader) int8() int8 {
+	b := r.next(1)
+	if b == nil {
+		return 0
+	}
+	return int8(b[0])
+}
+
+func (r *reader) int16() int16 {
+	b := r.next(2)
+	if b == nil {
+		return 0
+	}
+	return int16(binary.BigEndian.Uint16(b))
+}
+
+func (r *reader) int32() int32 {
+	b := r.next(4)
+	if b == nil {
+		return 0
+	}
+	return int32(binary.BigEndian.Uint32(b))
+}
+
+func (r *reader) int64() int64 {
+	b := r.next(8)
+	if b == nil {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(b))
+}
+
+// string reads a nullable string, returning "" for null
+func (r *reader) string() string {
+	n := r.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(r.next(int(n)))
+}
+
+func (r *reader) int32Array() []int32 {
+	var out []int32
+	for n := r.int32(); n > 0 && r.err == nil; n-- {
+		out = append(out, r.int32())
+	}
+	return out
+}
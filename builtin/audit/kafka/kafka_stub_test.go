@@ -0,0 +1,363 @@
+package kafka
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// kafkaStub is a broker speaking the subset of the Kafka protocol used by
+// the producer. It leads partition 0 of a single topic and records the
+// values of the records produced to it.
+type kafkaStub struct {
+	sync.Mutex
+
+	topic    string
+	listener net.Listener
+
+	// username and password, if set, must be presented with SASL PLAIN
+	// before any other request
+	username string
+	password string
+
+	// produceErrors are returned as the error codes of the next produce
+	// requests
+	produceErrors []int16
+
+	values []string
+}
+
+func newKafkaStub(t *testing.T, topic string) *kafkaStub {
+	return newSecureKafkaStub(t, topic, nil, "", "")
+}
+
+// newSecureKafkaStub returns a stub serving TLS with tlsConfig, if set, and
+// requiring SASL PLAIN authentication if username is set
+func newSecureKafkaStub(t *testing.T, topic string, tlsConfig *tls.Config, username, password string) *kafkaStub {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+
+	stub := &kafkaStub{
+		topic:    topic,
+		listener: listener,
+		username: username,
+		password: password,
+	}
+	go stub.serve()
+	return stub
+}
+
+// testTLSConfig returns a server configuration with a self-signed
+// certificate for 127.0.0.1, and writes the certificate to a CA file in dir
+func testTLSConfig(t *testing.T, dir string) (*tls.Config, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "kafka"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	caFile := filepath.Join(dir, "ca.pem")
+	if err := ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{{
+			Certificate: [][]byte{der},
+			PrivateKey:  key,
+		}},
+	}, caFile
+}
+
+func (s *kafkaStub) addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *kafkaStub) close() {
+	s.listener.Close()
+}
+
+func (s *kafkaStub) produced() []string {
+	s.Lock()
+	defer s.Unlock()
+	return append([]string(nil), s.values...)
+}
+
+func (s *kafkaStub) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *kafkaStub) handle(conn net.Conn) {
+	defer conn.Close()
+
+	authenticated := s.username == ""
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return
+		}
+		req := make([]byte, binary.BigEndian.Uint32(size[:]))
+		if _, err := io.ReadFull(conn, req); err != nil {
+			return
+		}
+
+		r := &reader{buf: req}
+		apiKey := r.int16()
+		apiVersion := r.int16()
+		correlationID := r.int32()
+		r.string() // client id
+
+		var resp bytes.Buffer
+		var err error
+		switch {
+		case !authenticated && (apiKey != apiKeySaslHandshake || apiVersion != saslHandshakeVersion):
+			err = fmt.Errorf("request before sasl authentication")
+		case apiKey == apiKeySaslHandshake && apiVersion == saslHandshakeVersion:
+			err = s.saslHandshake(r, &resp)
+		case apiKey == apiKeyMetadata && apiVersion == metadataVersion:
+			err = s.metadata(r, &resp)
+		case apiKey == apiKeyProduce && apiVersion == produceVersion:
+			err = s.produce(r, &resp)
+		default:
+			err = fmt.Errorf("unsupported api key %d version %d", apiKey, apiVersion)
+		}
+		if err != nil {
+			return
+		}
+
+		var out bytes.Buffer
+		writeInt32(&out, int32(4+resp.Len()))
+		writeInt32(&out, correlationID)
+		out.Write(resp.Bytes())
+		if _, err := conn.Write(out.Bytes()); err != nil {
+			return
+		}
+
+		if apiKey == apiKeySaslHandshake {
+			if !s.checkPlainToken(conn) {
+				return
+			}
+			authenticated = true
+		}
+	}
+}
+
+func (s *kafkaStub) saslHandshake(r *reader, resp *bytes.Buffer) error {
+	if mechanism := r.string(); mechanism != saslMechanismPlain {
+		return fmt.Errorf("unexpected mechanism %q", mechanism)
+	}
+
+	writeInt16(resp, 0)
+	writeInt32(resp, 1)
+	writeString(resp, saslMechanismPlain)
+	return r.err
+}
+
+// checkPlainToken reads the SASL PLAIN token sent after the handshake, and
+// answers with an empty token if the credentials match
+func (s *kafkaStub) checkPlainToken(conn net.Conn) bool {
+	var size [4]byte
+	if _, err := io.ReadFull(conn, size[:]); err != nil {
+		return false
+	}
+	token := make([]byte, binary.BigEndian.Uint32(size[:]))
+	if _, err := io.ReadFull(conn, token); err != nil {
+		return false
+	}
+	if string(token) != "\x00"+s.username+"\x00"+s.password {
+		return false
+	}
+
+	_, err := conn.Write([]byte{0, 0, 0, 0})
+	return err == nil
+}
+
+func (s *kafkaStub) metadata(r *reader, resp *bytes.Buffer) error {
+	host, portRaw, err := net.SplitHostPort(s.addr())
+	if err != nil {
+		return err
+	}
+	port, err := strconv.Atoi(portRaw)
+	if err != nil {
+		return err
+	}
+
+	writeInt32(resp, 1)
+	writeInt32(resp, 1) // node id
+	writeString(resp, host)
+	writeInt32(resp, int32(port))
+	writeInt16(resp, -1) // null rack
+	writeInt32(resp, 1)  // controller id
+
+	topics := r.int32()
+	writeInt32(resp, topics)
+	for ; topics > 0; topics-- {
+		topic := r.string()
+		if topic != s.topic {
+			writeInt16(resp, 3)
+			writeString(resp, topic)
+			resp.WriteByte(0)
+			writeInt32(resp, 0)
+			continue
+		}
+
+		writeInt16(resp, 0)
+		writeString(resp, topic)
+		resp.WriteByte(0)
+		writeInt32(resp, 1)
+		writeInt16(resp, 0)
+		writeInt32(resp, 0) // partition
+		writeInt32(resp, 1) // leader
+		writeInt32(resp, 1) // replicas
+		writeInt32(resp, 1)
+		writeInt32(resp, 1) // isr
+		writeInt32(resp, 1)
+	}
+
+	return r.err
+}
+
+func (s *kafkaStub) produce(r *reader, resp *bytes.Buffer) error {
+	r.string() // transactional id
+	r.int16()  // acks
+	r.int32()  // timeout
+
+	s.Lock()
+	defer s.Unlock()
+
+	var code int16
+	if len(s.produceErrors) > 0 {
+		code = s.produceErrors[0]
+		s.produceErrors = s.produceErrors[1:]
+	}
+
+	topics := r.int32()
+	writeInt32(resp, topics)
+	for ; topics > 0; topics-- {
+		writeString(resp, r.string())
+		partitions := r.int32()
+		writeInt32(resp, partitions)
+		for ; partitions > 0; partitions-- {
+			partition := r.int32()
+			batch := r.next(int(r.int32()))
+			if r.err != nil {
+				return r.err
+			}
+
+			values, err := decodeRecordBatch(batch)
+			if err != nil {
+				return err
+			}
+			if code == 0 {
+				s.values = append(s.values, values...)
+			}
+
+			writeInt32(resp, partition)
+			writeInt16(resp, code)
+			writeInt64(resp, 0)  // base offset
+			writeInt64(resp, -1) // log append time
+		}
+	}
+	writeInt32(resp, 0) // throttle time
+
+	return r.err
+}
+
+// decodeRecordBatch returns the values of a v2 record batch after checking
+// its length and CRC
+func decodeRecordBatch(batch []byte) ([]string, error) {
+	r := &reader{buf: batch}
+	r.int64() // base offset
+	length := r.int32()
+	if int(length) != len(r.buf) {
+		return nil, fmt.Errorf("batch length %d does not match %d", length, len(r.buf))
+	}
+	r.int32() // partition leader epoch
+	if magic := r.int8(); magic != recordBatchMagic {
+		return nil, fmt.Errorf("unexpected magic %d", magic)
+	}
+	crc := uint32(r.int32())
+	if r.err != nil {
+		return nil, r.err
+	}
+	if actual := crc32.Checksum(r.buf, castagnoli); actual != crc {
+		return nil, fmt.Errorf("crc mismatch")
+	}
+
+	r.int16() // attributes
+	r.int32() // last offset delta
+	r.int64() // first timestamp
+	r.int64() // max timestamp
+	r.int64() // producer id
+	r.int16() // producer epoch
+	r.int32() // base sequence
+	count := r.int32()
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	var values []string
+	rest := r.buf
+	for i := int32(0); i < count; i++ {
+		length, n := binary.Varint(rest)
+		if n <= 0 || int(length) > len(rest)-n {
+			return nil, fmt.Errorf("invalid record length")
+		}
+		record := rest[n : n+int(length)]
+		rest = rest[n+int(length):]
+
+		// Skip the attributes, then the timestamp and offset deltas and the
+		// key length, which is -1 for the null key
+		record = record[1:]
+		for j := 0; j < 3; j++ {
+			_, n := binary.Varint(record)
+			record = record[n:]
+		}
+		valueLength, n := binary.Varint(record)
+		values = append(values, string(record[n:n+int(valueLength)]))
+	}
+
+	return values, nil
+}
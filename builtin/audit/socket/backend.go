@@ -46,11 +46,6 @@ func Factory(conf *audit.BackendConfig) (audit.Backend, error) {
 	if !ok {
 		format = "json"
 	}
-	switch format {
-	case "json", "jsonx":
-	default:
-		return nil, fmt.Errorf("unknown format type %s", format)
-	}
 
 	// Check if hashing of accessor is disabled
 	hmacAccessor := true
@@ -85,18 +80,11 @@ func Factory(conf *audit.BackendConfig) (audit.Backend, error) {
 		socketType:    socketType,
 	}
 
-	switch format {
-	case "json":
-		b.formatter.AuditFormatWriter = &audit.JSONFormatWriter{
-			Prefix:   conf.Config["prefix"],
-			SaltFunc: b.Salt,
-		}
-	case "jsonx":
-		b.formatter.AuditFormatWriter = &audit.JSONxFormatWriter{
-			Prefix:   conf.Config["prefix"],
-			SaltFunc: b.Salt,
-		}
+	writer, err := audit.NewAuditFormatWriter(format, conf.Config["prefix"], b.Salt)
+	if err != nil {
+		return nil, err
 	}
+	b.formatter.AuditFormatWriter = writer
 
 	return b, nil
 }
@@ -36,11 +36,6 @@ func Factory(conf *audit.BackendConfig) (audit.Backend, error) {
 	if !ok {
 		format = "json"
 	}
-	switch format {
-	case "json", "jsonx":
-	default:
-		return nil, fmt.Errorf("unknown format type %s", format)
-	}
 
 	// Check if hashing of accessor is disabled
 	hmacAccessor := true
@@ -62,14 +57,26 @@ func Factory(conf *audit.BackendConfig) (audit.Backend, error) {
 		logRaw = b
 	}
 
-	// Get the logger
-	logger, err := gsyslog.NewLogger(gsyslog.LOG_INFO, facility, tag)
-	if err != nil {
-		return nil, err
+	// Check if RFC 5424 messages with structured data are enabled
+	rfc5424 := false
+	if raw, ok := conf.Config["rfc5424"]; ok {
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, err
+		}
+		rfc5424 = value
+	}
+
+	address := conf.Config["address"]
+	sdID, ok := conf.Config["sd_id"]
+	if !ok {
+		sdID = defaultSDID
+	}
+	if !rfc5424 && (address != "" || conf.Config["sd_id"] != "") {
+		return nil, fmt.Errorf("address and sd_id can only be used with rfc5424")
 	}
 
 	b := &Backend{
-		logger:     logger,
 		saltConfig: conf.SaltConfig,
 		saltView:   conf.SaltView,
 		formatConfig: audit.FormatterConfig{
@@ -78,17 +85,20 @@ func Factory(conf *audit.BackendConfig) (audit.Backend, error) {
 		},
 	}
 
-	switch format {
-	case "json":
-		b.formatter.AuditFormatWriter = &audit.JSONFormatWriter{
-			Prefix:   conf.Config["prefix"],
-			SaltFunc: b.Salt,
-		}
-	case "jsonx":
-		b.formatter.AuditFormatWriter = &audit.JSONxFormatWriter{
-			Prefix:   conf.Config["prefix"],
-			SaltFunc: b.Salt,
-		}
+	writer, err := audit.NewAuditFormatWriter(format, conf.Config["prefix"], b.Salt)
+	if err != nil {
+		return nil, err
+	}
+	b.formatter.AuditFormatWriter = writer
+
+	// Get the logger
+	if rfc5424 {
+		b.rfc5424, err = newRFC5424Writer(facility, tag, address, sdID)
+	} else {
+		b.logger, err = gsyslog.NewLogger(gsyslog.LOG_INFO, facility, tag)
+	}
+	if err != nil {
+		return nil, err
 	}
 
 	return b, nil
@@ -96,7 +106,8 @@ func Factory(conf *audit.BackendConfig) (audit.Backend, error) {
 
 // Backend is the audit backend for the syslog-based audit store.
 type Backend struct {
-	logger gsyslog.Syslogger
+	logger  gsyslog.Syslogger
+	rfc5424 *rfc5424Writer
 
 	formatter    audit.AuditFormatter
 	formatConfig audit.FormatterConfig
@@ -122,8 +133,7 @@ func (b *Backend) LogRequest(auth *logical.Auth, req *logical.Request, outerErr
 	}
 
 	// Write out to syslog
-	_, err := b.logger.Write(buf.Bytes())
-	return err
+	return b.write("request", req, buf.Bytes())
 }
 
 func (b *Backend) LogResponse(auth *logical.Auth, req *logical.Request, resp *logical.Response, err error) error {
@@ -133,14 +143,51 @@ func (b *Backend) LogResponse(auth *logical.Auth, req *logical.Request, resp *lo
 	}
 
 	// Write out to syslog
-	_, err = b.logger.Write(buf.Bytes())
-	return err
+	return b.write("response", req, buf.Bytes())
+}
+
+// write sends a formatted entry to syslog. RFC 5424 messages carry the
+// request ID, path, operation and accessor as structured data, so that they
+// can be filtered on without parsing the entry.
+func (b *Backend) write(typ string, req *logical.Request, entry []byte) error {
+	if b.rfc5424 == nil {
+		_, err := b.logger.Write(entry)
+		return err
+	}
+
+	var params []sdParam
+	if req != nil {
+		accessor := req.ClientTokenAccessor
+		if accessor != "" && !b.formatConfig.Raw && b.formatConfig.HMACAccessor {
+			salt, err := b.Salt()
+			if err != nil {
+				return err
+			}
+			accessor = audit.HashString(salt, accessor)
+		}
+		params = []sdParam{
+			{"request_id", req.ID},
+			{"path", req.Path},
+			{"operation", string(req.Operation)},
+			{"accessor", accessor},
+		}
+	}
+
+	return b.rfc5424.write(typ, params, entry)
 }
 
 func (b *Backend) Reload() error {
 	return nil
 }
 
+// Close closes the connection used for RFC 5424 messages
+func (b *Backend) Close() error {
+	if b.rfc5424 == nil {
+		return nil
+	}
+	return b.rfc5424.Close()
+}
+
 func (b *Backend) Salt() (*salt.Salt, error) {
 	b.saltMutex.RLock()
 	if b.salt != nil {
@@ -0,0 +1,186 @@
+package syslog
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/audit"
+	"github.com/hashicorp/vault/helper/salt"
+	"github.com/hashicorp/vault/logical"
+)
+
+var testRFC5424Regexp = regexp.MustCompile(`^<(\d+)>1 \S+Z \S+ (\S+) \d+ (\S+) \[(.*?[^\\])\] (.*)\n$`)
+
+func testBackend(t *testing.T, config map[string]string) *Backend {
+	b, err := Factory(&audit.BackendConfig{
+		SaltConfig: &salt.Config{},
+		SaltView:   &logical.InmemStorage{},
+		Config:     config,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b.(*Backend)
+}
+
+func testRequest() *logical.Request {
+	return &logical.Request{
+		ID:                  "abc",
+		Operation:           logical.UpdateOperation,
+		Path:                `secret/"foo"]`,
+		ClientTokenAccessor: "bar",
+	}
+}
+
+func TestAuditSyslog_rfc5424UDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	b := testBackend(t, map[string]string{
+		"rfc5424":  "true",
+		"address":  "udp://" + conn.LocalAddr().String(),
+		"facility": "LOCAL0",
+		"tag":      "vault-test",
+		"format":   "cef",
+	})
+	defer b.Close()
+
+	if err := b.LogRequest(&logical.Auth{ClientToken: "foo"}, testRequest(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 64*1024)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := testRFC5424Regexp.FindStringSubmatch(string(buf[:n]))
+	if m == nil {
+		t.Fatalf("bad message: %q", buf[:n])
+	}
+	// LOCAL0 is 16, info is 6
+	if m[1] != "134" || m[2] != "vault-test" || m[3] != "request" {
+		t.Fatalf("bad header: %q", buf[:n])
+	}
+
+	salter, err := b.Salt()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `vault@32473 request_id="abc" path="secret/\"foo\"\]" operation="update" accessor="` +
+		salter.GetIdentifiedHMAC("bar") + `"`
+	if m[4] != expected {
+		t.Fatalf("bad structured data:\n%s\nexpected:\n%s", m[4], expected)
+	}
+	if !strings.HasPrefix(m[5], "CEF:0|HashiCorp|Vault|") {
+		t.Fatalf("bad message: %q", m[5])
+	}
+}
+
+func TestAuditSyslog_rfc5424TCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	b := testBackend(t, map[string]string{
+		"rfc5424":       "true",
+		"address":       "tcp://" + ln.Addr().String(),
+		"sd_id":         "audit@1234",
+		"hmac_accessor": "false",
+	})
+	defer b.Close()
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	for i := 0; i < 2; i++ {
+		if err := b.LogResponse(&logical.Auth{ClientToken: "foo"}, testRequest(), &logical.Response{}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewReader(conn)
+	for i := 0; i < 2; i++ {
+		// Messages are framed by their length in octets
+		length, err := r.ReadString(' ')
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(length))
+		if err != nil {
+			t.Fatalf("bad frame: %q", length)
+		}
+		msg := make([]byte, n)
+		if _, err := io.ReadFull(r, msg); err != nil {
+			t.Fatal(err)
+		}
+
+		m := testRFC5424Regexp.FindStringSubmatch(string(msg))
+		if m == nil {
+			t.Fatalf("bad message: %q", msg)
+		}
+		// AUTH is 4, info is 6
+		if m[1] != "38" || m[2] != "vault" || m[3] != "response" {
+			t.Fatalf("bad header: %q", msg)
+		}
+		if !strings.HasPrefix(m[4], "audit@1234 ") || !strings.HasSuffix(m[4], ` accessor="bar"`) {
+			t.Fatalf("bad structured data: %q", m[4])
+		}
+		if !strings.HasPrefix(m[5], `{"time":`) {
+			t.Fatalf("bad message: %q", m[5])
+		}
+	}
+}
+
+func TestAuditSyslog_rfc5424Options(t *testing.T) {
+	cases := map[string]map[string]string{
+		"address without rfc5424": {
+			"address": "udp://127.0.0.1:514",
+		},
+		"sd_id without rfc5424": {
+			"sd_id": "audit@1234",
+		},
+		"bad scheme": {
+			"rfc5424": "true",
+			"address": "http://127.0.0.1:514",
+		},
+		"bad sd_id": {
+			"rfc5424": "true",
+			"address": "udp://127.0.0.1:514",
+			"sd_id":   "audit 1234",
+		},
+		"bad facility": {
+			"rfc5424":  "true",
+			"address":  "udp://127.0.0.1:514",
+			"facility": "NOPE",
+		},
+	}
+
+	for name, config := range cases {
+		_, err := Factory(&audit.BackendConfig{
+			SaltConfig: &salt.Config{},
+			SaltView:   &logical.InmemStorage{},
+			Config:     config,
+		})
+		if err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
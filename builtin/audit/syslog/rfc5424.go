@@ -0,0 +1,246 @@
+package syslog
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// severityInfo is the syslog severity of audit entries
+	severityInfo = 6
+
+	// defaultSDID is the ID of the structured data element. 32473 is the
+	// private enterprise number reserved for documentation by RFC 5612, so
+	// deployments with their own number can set sd_id instead.
+	defaultSDID = "vault@32473"
+
+	localDeadline  = 20 * time.Millisecond
+	remoteDeadline = 5 * time.Second
+)
+
+var facilities = map[string]int{
+	"KERN":     0,
+	"USER":     1,
+	"MAIL":     2,
+	"DAEMON":   3,
+	"AUTH":     4,
+	"SYSLOG":   5,
+	"LPR":      6,
+	"NEWS":     7,
+	"UUCP":     8,
+	"CRON":     9,
+	"AUTHPRIV": 10,
+	"FTP":      11,
+	"LOCAL0":   16,
+	"LOCAL1":   17,
+	"LOCAL2":   18,
+	"LOCAL3":   19,
+	"LOCAL4":   20,
+	"LOCAL5":   21,
+	"LOCAL6":   22,
+	"LOCAL7":   23,
+}
+
+var sdValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// sdParam is a parameter of the structured data element
+type sdParam struct {
+	name  string
+	value string
+}
+
+// rfc5424Writer sends RFC 5424 messages carrying structured data to the
+// local syslog daemon, or to a remote one over UDP or TCP
+type rfc5424Writer struct {
+	sync.Mutex
+
+	network string
+	address string
+
+	priority int
+	hostname string
+	appName  string
+	procID   string
+	sdID     string
+
+	conn net.Conn
+}
+
+// newRFC5424Writer returns a writer for the given facility and tag. The
+// address is empty for the local daemon, or a URL such as udp://host:514.
+func newRFC5424Writer(facility, tag, address, sdID string) (*rfc5424Writer, error) {
+	code, ok := facilities[strings.ToUpper(facility)]
+	if !ok {
+		return nil, fmt.Errorf("invalid syslog facility: %s", facility)
+	}
+
+	if sdID == "" || strings.ContainsAny(sdID, ` ="]`) {
+		return nil, fmt.Errorf("invalid structured data ID %q", sdID)
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	w := &rfc5424Writer{
+		priority: code*8 + severityInfo,
+		hostname: hostname,
+		appName:  tag,
+		procID:   strconv.Itoa(os.Getpid()),
+		sdID:     sdID,
+	}
+
+	if address != "" {
+		u, err := url.Parse(address)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address: %v", err)
+		}
+		switch u.Scheme {
+		case "udp", "tcp":
+		default:
+			return nil, fmt.Errorf("address must use the udp or tcp scheme")
+		}
+		w.network = u.Scheme
+		w.address = u.Host
+	}
+
+	if err := w.connect(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// connect opens the connection to the daemon. The lock must be held.
+func (w *rfc5424Writer) connect() error {
+	if w.conn != nil {
+		w.conn.Close()
+		w.conn = nil
+	}
+
+	if w.network != "" {
+		conn, err := net.DialTimeout(w.network, w.address, remoteDeadline)
+		if err != nil {
+			return err
+		}
+		w.conn = conn
+		return nil
+	}
+
+	for _, network := range []string{"unixgram", "unix"} {
+		for _, path := range []string{"/dev/log", "/var/run/syslog", "/var/run/log"} {
+			conn, err := net.DialTimeout(network, path, localDeadline)
+			if err == nil {
+				w.network = network
+				w.conn = conn
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("unable to connect to the local syslog daemon")
+}
+
+// write sends a message, reconnecting once if it fails
+func (w *rfc5424Writer) write(msgID string, params []sdParam, msg []byte) error {
+	message := w.format(time.Now(), msgID, params, msg)
+
+	w.Lock()
+	defer w.Unlock()
+
+	if w.conn != nil {
+		if err := w.send(message); err == nil {
+			return nil
+		}
+	}
+	if err := w.connect(); err != nil {
+		return err
+	}
+	return w.send(message)
+}
+
+// send writes a message to the connection. Messages over TCP are framed by
+// octet counting, as described in RFC 6587. The lock must be held.
+func (w *rfc5424Writer) send(message []byte) error {
+	if w.network == "tcp" {
+		message = append([]byte(strconv.Itoa(len(message))+" "), message...)
+	}
+	if err := w.conn.SetWriteDeadline(time.Now().Add(remoteDeadline)); err != nil {
+		return err
+	}
+	_, err := w.conn.Write(message)
+	return err
+}
+
+// format returns an RFC 5424 message such as:
+//
+//	<110>1 2017-06-01T10:00:00.000000Z host vault 1234 request [vault@32473 path="secret/foo"] {...}
+func (w *rfc5424Writer) format(t time.Time, msgID string, params []sdParam, msg []byte) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "<%d>1 %s %s %s %s %s ",
+		w.priority,
+		t.UTC().Format("2006-01-02T15:04:05.000000Z"),
+		headerField(w.hostname, 255),
+		headerField(w.appName, 48),
+		headerField(w.procID, 128),
+		headerField(msgID, 32))
+
+	buf.WriteByte('[')
+	buf.WriteString(w.sdID)
+	for _, param := range params {
+		if param.value == "" {
+			continue
+		}
+		buf.WriteByte(' ')
+		buf.WriteString(param.name)
+		buf.WriteString(`="`)
+		buf.WriteString(sdValueEscaper.Replace(param.value))
+		buf.WriteByte('"')
+	}
+	buf.WriteByte(']')
+
+	if len(msg) > 0 {
+		buf.WriteByte(' ')
+		buf.Write(bytes.TrimRight(msg, "\n"))
+	}
+	buf.WriteByte('\n')
+
+	return buf.Bytes()
+}
+
+func (w *rfc5424Writer) Close() error {
+	w.Lock()
+	defer w.Unlock()
+
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
+
+// headerField returns a header field restricted to printable ASCII without
+// spaces and truncated to max characters, or the nil value "-" if empty
+func headerField(value string, max int) string {
+	field := strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return -1
+		}
+		return r
+	}, value)
+	if len(field) > max {
+		field = field[:max]
+	}
+	if field == "" {
+		return "-"
+	}
+	return field
+}
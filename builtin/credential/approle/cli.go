@@ -0,0 +1,61 @@
+package approle
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/mitchellh/mapstructure"
+)
+
+type CLIHandler struct{}
+
+func (h *CLIHandler) Auth(c *api.Client, m map[string]string) (string, error) {
+	var data struct {
+		Mount    string `mapstructure:"mount"`
+		RoleID   string `mapstructure:"role_id"`
+		SecretID string `mapstructure:"secret_id"`
+	}
+	if err := mapstructure.WeakDecode(m, &data); err != nil {
+		return "", err
+	}
+
+	if data.RoleID == "" {
+		return "", fmt.Errorf("'role_id' must be specified")
+	}
+	if data.Mount == "" {
+		data.Mount = "approle"
+	}
+
+	options := map[string]interface{}{
+		"role_id": data.RoleID,
+	}
+	if data.SecretID != "" {
+		options["secret_id"] = data.SecretID
+	}
+
+	path := fmt.Sprintf("auth/%s/login", data.Mount)
+	secret, err := c.Logical().Write(path, options)
+	if err != nil {
+		return "", err
+	}
+	if secret == nil {
+		return "", fmt.Errorf("empty response from credential provider")
+	}
+
+	return secret.Auth.ClientToken, nil
+}
+
+func (h *CLIHandler) Help() string {
+	help := `
+The "approle" credential provider allows you to authenticate with a role ID
+and, unless the role does not require one, a secret ID.
+
+    Example: vault auth -method=approle \
+                        role_id=<role ID> \
+                        secret_id=<secret ID>
+
+	`
+
+	return strings.TrimSpace(help)
+}
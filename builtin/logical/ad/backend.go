@@ -0,0 +1,90 @@
+package ad
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/helper/locksutil"
+	"github.com/hashicorp/vault/helper/queue"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+	log "github.com/mgutz/logxi/v1"
+)
+
+func Factory(conf *logical.BackendConfig) (logical.Backend, error) {
+	b := Backend(conf)
+	if err := b.Setup(conf); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func Backend(conf *logical.BackendConfig) *backend {
+	var b backend
+	b.Backend = &framework.Backend{
+		Help: strings.TrimSpace(backendHelp),
+
+		Paths: []*framework.Path{
+			pathConfig(&b),
+			pathListRoles(&b),
+			pathRoles(&b),
+			pathCreds(&b),
+			pathRotateRole(&b),
+			pathListLibrary(&b),
+			pathLibrary(&b),
+			pathCheckOut(&b),
+			pathCheckIn(&b),
+			pathManageCheckIn(&b),
+			pathLibraryStatus(&b),
+		},
+
+		Secrets: []*framework.Secret{
+			secretCheckOut(&b),
+		},
+		PeriodicFunc:      b.periodicFunc,
+		WALRollback:       b.walRollback,
+		WALRollbackMinAge: 5 * time.Minute,
+		BackendType:       logical.TypeLogical,
+	}
+
+	b.logger = conf.Logger
+	b.client = &ldapClient{}
+	b.rotationSchedule = queue.NewScheduler()
+	b.roleLocks = locksutil.CreateLocks()
+	return &b
+}
+
+type backend struct {
+	*framework.Backend
+
+	logger log.Logger
+
+	// client manages the service accounts in Active Directory
+	client adClient
+
+	// rotationSchedule holds the names of roles, scheduled at the time their
+	// passwords are next due to be rotated
+	rotationSchedule *queue.Scheduler
+
+	// roleLocks serializes the rotation and modification of roles
+	roleLocks []*locksutil.LockEntry
+
+	// libraryLock serializes the changes to library sets and the check-outs
+	// of their service accounts
+	libraryLock sync.Mutex
+}
+
+const backendHelp = `
+The Active Directory backend manages the passwords of existing service
+accounts.
+
+Roles are bound to a service account, whose password Vault rotates every
+"ttl", keeping the previous password so that it can still be used while the new
+one propagates. Library sets hold shared service accounts that can be checked
+out by one client at a time; their passwords are rotated when they are checked
+back in.
+
+After mounting this backend, the connection to Active Directory must be
+configured at "config" before roles and library sets can be created.
+`
@@ -0,0 +1,138 @@
+package ad
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+// fakeClient is an adClient keeping the passwords of a fixed set of accounts
+// in memory
+type fakeClient struct {
+	sync.Mutex
+	passwords map[string]string
+	fail      bool
+}
+
+func newFakeClient(accounts ...string) *fakeClient {
+	c := &fakeClient{
+		passwords: make(map[string]string),
+	}
+	for _, account := range accounts {
+		c.passwords[account] = "initial"
+	}
+	return c
+}
+
+func (c *fakeClient) AccountDN(conf *configEntry, name string) (string, error) {
+	c.Lock()
+	defer c.Unlock()
+	if _, ok := c.passwords[name]; !ok {
+		return "", nil
+	}
+	return fmt.Sprintf("CN=%s,%s", name, conf.UserDN), nil
+}
+
+func (c *fakeClient) UpdatePassword(conf *configEntry, name, password string) error {
+	c.Lock()
+	defer c.Unlock()
+	if c.fail {
+		return fmt.Errorf("password update failed")
+	}
+	if _, ok := c.passwords[name]; !ok {
+		return fmt.Errorf("account %q not found", name)
+	}
+	c.passwords[name] = password
+	return nil
+}
+
+func (c *fakeClient) password(name string) string {
+	c.Lock()
+	defer c.Unlock()
+	return c.passwords[name]
+}
+
+func getBackend(t *testing.T, accounts ...string) (*backend, logical.Storage, *fakeClient) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	b := Backend(config)
+	if err := b.Setup(config); err != nil {
+		t.Fatal(err)
+	}
+	client := newFakeClient(accounts...)
+	b.client = client
+
+	return b, config.StorageView, client
+}
+
+// request handles a request as the client with the given token, failing the
+// test on errors
+func request(t *testing.T, b *backend, s logical.Storage, token string, op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+	resp, err := b.HandleRequest(&logical.Request{
+		Operation:   op,
+		Path:        path,
+		Storage:     s,
+		ClientToken: token,
+		Data:        data,
+	})
+	if err != nil {
+		t.Fatalf("%s %s: err: %s", op, path, err)
+	}
+	return resp
+}
+
+func configure(t *testing.T, b *backend, s logical.Storage) {
+	resp := request(t, b, s, "", logical.UpdateOperation, "config", map[string]interface{}{
+		"binddn":          "CN=vault,DC=example,DC=org",
+		"bindpass":        "secret",
+		"userdn":          "OU=Service Accounts,DC=example,DC=org",
+		"password_length": 20,
+	})
+	if resp != nil && resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+}
+
+func TestBackend_config(t *testing.T) {
+	b, s, _ := getBackend(t)
+
+	resp := request(t, b, s, "", logical.UpdateOperation, "roles/web", map[string]interface{}{
+		"service_account_name": "web",
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error without config, got: %#v", resp)
+	}
+
+	for _, data := range []map[string]interface{}{
+		{"userdn": "DC=example,DC=org"},
+		{"binddn": "CN=vault", "bindpass": "secret"},
+		{"binddn": "CN=vault", "bindpass": "secret", "userdn": "DC=example,DC=org", "password_length": 8},
+		{"binddn": "CN=vault", "bindpass": "secret", "userdn": "DC=example,DC=org", "ttl": 30},
+		{"binddn": "CN=vault", "bindpass": "secret", "userdn": "DC=example,DC=org", "ttl": 7200, "max_ttl": 3600},
+		{"binddn": "CN=vault", "bindpass": "secret", "userdn": "DC=example,DC=org", "certificate": "invalid"},
+	} {
+		resp := request(t, b, s, "", logical.UpdateOperation, "config", data)
+		if resp == nil || !resp.IsError() {
+			t.Fatalf("expected an error for %v, got: %#v", data, resp)
+		}
+	}
+
+	configure(t, b, s)
+
+	resp = request(t, b, s, "", logical.ReadOperation, "config", nil)
+	if resp == nil || resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+	if _, ok := resp.Data["bindpass"]; ok {
+		t.Fatal("bindpass should not be returned")
+	}
+	if resp.Data["userdn"] != "OU=Service Accounts,DC=example,DC=org" || resp.Data["password_length"] != 20 {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	if resp.Data["ttl"] != int64(defaultPasswordTTL.Seconds()) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+}
@@ -0,0 +1,111 @@
+package ad
+
+import (
+	"encoding/binary"
+	"fmt"
+	"unicode/utf16"
+
+	"github.com/go-ldap/ldap"
+)
+
+// adClient manages the passwords of Active Directory accounts
+type adClient interface {
+	// AccountDN returns the DN of the account with the given sAMAccountName
+	// or userPrincipalName, or an empty string if there is none
+	AccountDN(conf *configEntry, name string) (string, error)
+
+	// UpdatePassword sets the password of the named account
+	UpdatePassword(conf *configEntry, name, password string) error
+}
+
+// ldapClient is the adClient talking to Active Directory over LDAP
+type ldapClient struct{}
+
+// dial connects to Active Directory and binds with the configured
+// credentials
+func (c *ldapClient) dial(conf *configEntry) (*ldap.Conn, error) {
+	conn, err := conf.dialConfig().Dial()
+	if err != nil {
+		return nil, err
+	}
+	if conn == nil {
+		return nil, fmt.Errorf("invalid connection returned from LDAP dial")
+	}
+
+	if err := conn.Bind(conf.BindDN, conf.BindPassword); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("LDAP bind failed: %v", err)
+	}
+
+	return conn, nil
+}
+
+func (c *ldapClient) search(conn *ldap.Conn, conf *configEntry, name string) (string, error) {
+	filter := fmt.Sprintf("(|(sAMAccountName=%s)(userPrincipalName=%s))",
+		ldap.EscapeFilter(name), ldap.EscapeFilter(name))
+	result, err := conn.Search(&ldap.SearchRequest{
+		BaseDN:     conf.UserDN,
+		Scope:      ldap.ScopeWholeSubtree,
+		Filter:     filter,
+		Attributes: []string{"distinguishedName"},
+	})
+	if err != nil {
+		return "", fmt.Errorf("LDAP search for account %q failed: %v", name, err)
+	}
+
+	switch len(result.Entries) {
+	case 0:
+		return "", nil
+	case 1:
+		return result.Entries[0].DN, nil
+	default:
+		return "", fmt.Errorf("LDAP search for account %q returned %d accounts", name, len(result.Entries))
+	}
+}
+
+func (c *ldapClient) AccountDN(conf *configEntry, name string) (string, error) {
+	conn, err := c.dial(conf)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	return c.search(conn, conf, name)
+}
+
+func (c *ldapClient) UpdatePassword(conf *configEntry, name, password string) error {
+	conn, err := c.dial(conf)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	dn, err := c.search(conn, conf, name)
+	if err != nil {
+		return err
+	}
+	if dn == "" {
+		return fmt.Errorf("account %q not found", name)
+	}
+
+	// Active Directory only lets the password be replaced over an encrypted
+	// connection
+	req := ldap.NewModifyRequest(dn)
+	req.Replace("unicodePwd", []string{encodePassword(password)})
+	if err := conn.Modify(req); err != nil {
+		return fmt.Errorf("failed to update the password of account %q: %v", name, err)
+	}
+
+	return nil
+}
+
+// encodePassword encodes the password as Active Directory expects the value of
+// the unicodePwd attribute: quoted, in UTF-16LE
+func encodePassword(password string) string {
+	encoded := utf16.Encode([]rune("\"" + password + "\""))
+	buf := make([]byte, 2*len(encoded))
+	for i, r := range encoded {
+		binary.LittleEndian.PutUint16(buf[2*i:], r)
+	}
+	return string(buf)
+}
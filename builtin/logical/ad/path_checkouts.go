@@ -0,0 +1,322 @@
+package ad
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathCheckOut(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "library/" + framework.GenericNameRegex("name") + "/check-out$",
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the library set",
+			},
+
+			"ttl": &framework.FieldSchema{
+				Type:        framework.TypeDurationSecond,
+				Description: "TTL of the check-out, up to the set's ttl, which is the default",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathCheckOutUpdate,
+		},
+
+		HelpSynopsis:    pathCheckOutHelpSyn,
+		HelpDescription: pathCheckOutHelpDesc,
+	}
+}
+
+func pathCheckIn(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "library/" + framework.GenericNameRegex("name") + "/check-in$",
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the library set",
+			},
+
+			"service_account_names": &framework.FieldSchema{
+				Type:        framework.TypeCommaStringSlice,
+				Description: "Service accounts to check in. Optional if the client has checked out only one account of the set.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathCheckInUpdate(false),
+		},
+
+		HelpSynopsis:    pathCheckInHelpSyn,
+		HelpDescription: pathCheckInHelpDesc,
+	}
+}
+
+func pathManageCheckIn(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "library/manage/" + framework.GenericNameRegex("name") + "/check-in$",
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the library set",
+			},
+
+			"service_account_names": &framework.FieldSchema{
+				Type:        framework.TypeCommaStringSlice,
+				Description: "Service accounts to check in. Optional if only one account of the set is checked out.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathCheckInUpdate(true),
+		},
+
+		HelpSynopsis:    pathManageCheckInHelpSyn,
+		HelpDescription: pathManageCheckInHelpDesc,
+	}
+}
+
+func pathLibraryStatus(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "library/" + framework.GenericNameRegex("name") + "/status$",
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the library set",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathLibraryStatusRead,
+		},
+
+		HelpSynopsis:    pathLibraryStatusHelpSyn,
+		HelpDescription: pathLibraryStatusHelpDesc,
+	}
+}
+
+// borrowerTokenHash identifies the client of the request without storing its
+// token
+func borrowerTokenHash(clientToken string) string {
+	sum := sha256.Sum256([]byte(clientToken))
+	return hex.EncodeToString(sum[:])
+}
+
+func (b *backend) pathCheckOutUpdate(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	b.libraryLock.Lock()
+	defer b.libraryLock.Unlock()
+
+	set, err := b.Library(req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if set == nil {
+		return logical.ErrorResponse(fmt.Sprintf("unknown library set: %s", name)), nil
+	}
+
+	ttl := set.TTL
+	if requested := time.Duration(d.Get("ttl").(int)) * time.Second; requested > 0 && requested < ttl {
+		ttl = requested
+	}
+
+	for _, serviceAccountName := range sortedAccounts(set) {
+		checkOut, err := b.CheckOut(req.Storage, serviceAccountName)
+		if err != nil {
+			return nil, err
+		}
+		if checkOut == nil || !checkOut.IsAvailable {
+			continue
+		}
+
+		checkOutID, err := uuid.GenerateUUID()
+		if err != nil {
+			return nil, err
+		}
+		checkOut.IsAvailable = false
+		checkOut.CheckOutID = checkOutID
+		checkOut.BorrowerTokenHash = borrowerTokenHash(req.ClientToken)
+		if err := b.putCheckOut(req.Storage, serviceAccountName, checkOut); err != nil {
+			return nil, err
+		}
+
+		resp := b.Secret(secretCheckOutType).Response(map[string]interface{}{
+			"service_account_name": serviceAccountName,
+			"password":             checkOut.Password,
+		}, map[string]interface{}{
+			"service_account_name": serviceAccountName,
+			"set_name":             name,
+			"check_out_id":         checkOutID,
+		})
+		resp.Secret.TTL = ttl
+		resp.Secret.Renewable = true
+
+		return resp, nil
+	}
+
+	return logical.ErrorResponse(fmt.Sprintf("no service account of library set %q is available", name)), nil
+}
+
+// pathCheckInUpdate checks service accounts in. Unless managing the set, the
+// client must be the one that checked them out, if the set enforces it.
+func (b *backend) pathCheckInUpdate(manage bool) framework.OperationFunc {
+	return func(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		name := d.Get("name").(string)
+
+		conf, errResp, err := b.requireConfig(req.Storage)
+		if errResp != nil || err != nil {
+			return errResp, err
+		}
+
+		b.libraryLock.Lock()
+		defer b.libraryLock.Unlock()
+
+		set, err := b.Library(req.Storage, name)
+		if err != nil {
+			return nil, err
+		}
+		if set == nil {
+			return logical.ErrorResponse(fmt.Sprintf("unknown library set: %s", name)), nil
+		}
+
+		enforce := !manage && !set.DisableCheckInEnforcement
+		borrower := borrowerTokenHash(req.ClientToken)
+
+		// The accounts the client can check in
+		checkedOut := make(map[string]bool)
+		var candidates []string
+		for _, serviceAccountName := range sortedAccounts(set) {
+			checkOut, err := b.CheckOut(req.Storage, serviceAccountName)
+			if err != nil {
+				return nil, err
+			}
+			if checkOut == nil || checkOut.IsAvailable {
+				continue
+			}
+			if enforce && checkOut.BorrowerTokenHash != borrower {
+				continue
+			}
+			checkedOut[serviceAccountName] = true
+			candidates = append(candidates, serviceAccountName)
+		}
+
+		serviceAccountNames := d.Get("service_account_names").([]string)
+		if len(serviceAccountNames) == 0 {
+			switch len(candidates) {
+			case 0:
+				return logical.ErrorResponse("no service account of the set can be checked in"), nil
+			case 1:
+				serviceAccountNames = candidates
+			default:
+				return logical.ErrorResponse(fmt.Sprintf("service_account_names is required when more than one account can be checked in: %v", candidates)), nil
+			}
+		}
+
+		for _, serviceAccountName := range serviceAccountNames {
+			if !strutil.StrListContains(set.ServiceAccountNames, serviceAccountName) {
+				return logical.ErrorResponse(fmt.Sprintf("service account %q is not in library set %q", serviceAccountName, name)), nil
+			}
+		}
+
+		var checkIns []string
+		for _, serviceAccountName := range serviceAccountNames {
+			if !checkedOut[serviceAccountName] {
+				// Checking in an account that isn't checked out is a no-op
+				if existing, err := b.CheckOut(req.Storage, serviceAccountName); err != nil {
+					return nil, err
+				} else if existing != nil && existing.IsAvailable {
+					continue
+				}
+				return logical.ErrorResponse(fmt.Sprintf("service account %q was checked out by another client", serviceAccountName)), nil
+			}
+
+			if err := b.checkIn(req.Storage, conf, serviceAccountName); err != nil {
+				return nil, fmt.Errorf("failed to check in service account %q: %v", serviceAccountName, err)
+			}
+			checkIns = append(checkIns, serviceAccountName)
+		}
+
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"check_ins": checkIns,
+			},
+		}, nil
+	}
+}
+
+func (b *backend) pathLibraryStatusRead(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	set, err := b.Library(req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if set == nil {
+		return nil, nil
+	}
+
+	data := make(map[string]interface{}, len(set.ServiceAccountNames))
+	for _, serviceAccountName := range set.ServiceAccountNames {
+		checkOut, err := b.CheckOut(req.Storage, serviceAccountName)
+		if err != nil {
+			return nil, err
+		}
+		data[serviceAccountName] = map[string]interface{}{
+			"available": checkOut != nil && checkOut.IsAvailable,
+		}
+	}
+
+	return &logical.Response{
+		Data: data,
+	}, nil
+}
+
+const pathCheckOutHelpSyn = `
+Check out a service account of a library set.
+`
+
+const pathCheckOutHelpDesc = `
+This path checks out an available service account of the library set, and
+returns its name and password. The account stays checked out until it is
+checked in, or the lease is revoked or expires.
+`
+
+const pathCheckInHelpSyn = `
+Check in service accounts of a library set.
+`
+
+const pathCheckInHelpDesc = `
+This path checks in service accounts of the library set, rotating their
+passwords. Unless the set disables check-in enforcement, only the client that
+checked out an account can check it in.
+`
+
+const pathManageCheckInHelpSyn = `
+Check in service accounts of a library set, regardless of who checked them out.
+`
+
+const pathManageCheckInHelpDesc = `
+This path checks in service accounts of the library set, rotating their
+passwords, whichever client checked them out. It is meant for operators, for
+example when the borrower of an account is no longer available.
+`
+
+const pathLibraryStatusHelpSyn = `
+Read the check-out status of the service accounts of a library set.
+`
+
+const pathLibraryStatusHelpDesc = `
+This path returns, for each service account of the library set, whether it is
+available to be checked out.
+`
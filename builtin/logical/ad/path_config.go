@@ -0,0 +1,291 @@
+package ad
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/helper/ldaputil"
+	"github.com/hashicorp/vault/helper/random"
+	"github.com/hashicorp/vault/helper/tlsutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const (
+	configPath = "config"
+
+	// Active Directory's default maximum password age is 42 days
+	defaultPasswordTTL = 32 * 24 * time.Hour
+
+	defaultPasswordLength = 64
+	minPasswordLength     = 14
+	maxPasswordLength     = 256
+)
+
+func pathConfig(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: configPath,
+		Fields: map[string]*framework.FieldSchema{
+			"url": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Default:     "ldap://127.0.0.1",
+				Description: "LDAP URL of Active Directory (default: ldap://127.0.0.1). Multiple URLs can be specified by concatenating them with commas; they will be tried in-order.",
+			},
+
+			"binddn": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "DN of the account Vault binds as, which must be allowed to reset the passwords of the service accounts",
+			},
+
+			"bindpass": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Password of the account Vault binds as",
+			},
+
+			"userdn": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Base DN under which service accounts are searched (eg: ou=Service Accounts,dc=example,dc=org)",
+			},
+
+			"certificate": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "CA certificate to use when verifying the LDAP server certificate, must be x509 PEM encoded (optional)",
+			},
+
+			"insecure_tls": &framework.FieldSchema{
+				Type:        framework.TypeBool,
+				Description: "Skip LDAP server SSL Certificate verification - VERY insecure (optional)",
+			},
+
+			"starttls": &framework.FieldSchema{
+				Type:        framework.TypeBool,
+				Description: "Issue a StartTLS command after establishing unencrypted connection (optional)",
+			},
+
+			"tls_min_version": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Default:     "tls12",
+				Description: "Minimum TLS version to use. Accepted values are 'tls10', 'tls11' or 'tls12'. Defaults to 'tls12'",
+			},
+
+			"tls_max_version": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Default:     "tls12",
+				Description: "Maximum TLS version to use. Accepted values are 'tls10', 'tls11' or 'tls12'. Defaults to 'tls12'",
+			},
+
+			"ttl": &framework.FieldSchema{
+				Type:        framework.TypeDurationSecond,
+				Default:     int(defaultPasswordTTL.Seconds()),
+				Description: "Default period after which the passwords of roles are rotated. Defaults to 32 days.",
+			},
+
+			"max_ttl": &framework.FieldSchema{
+				Type:        framework.TypeDurationSecond,
+				Default:     int(defaultPasswordTTL.Seconds()),
+				Description: "Maximum period after which the passwords of roles are rotated. Defaults to 32 days.",
+			},
+
+			"password_length": &framework.FieldSchema{
+				Type:        framework.TypeInt,
+				Default:     defaultPasswordLength,
+				Description: fmt.Sprintf("Length of the generated passwords, from %d to %d. Defaults to %d.", minPasswordLength, maxPasswordLength, defaultPasswordLength),
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathConfigRead,
+			logical.UpdateOperation: b.pathConfigWrite,
+		},
+
+		HelpSynopsis:    pathConfigHelpSyn,
+		HelpDescription: pathConfigHelpDesc,
+	}
+}
+
+type configEntry struct {
+	URL            string        `json:"url"`
+	BindDN         string        `json:"binddn"`
+	BindPassword   string        `json:"bindpass"`
+	UserDN         string        `json:"userdn"`
+	Certificate    string        `json:"certificate"`
+	InsecureTLS    bool          `json:"insecure_tls"`
+	StartTLS       bool          `json:"starttls"`
+	TLSMinVersion  string        `json:"tls_min_version"`
+	TLSMaxVersion  string        `json:"tls_max_version"`
+	TTL            time.Duration `json:"ttl"`
+	MaxTTL         time.Duration `json:"max_ttl"`
+	PasswordLength int           `json:"password_length"`
+}
+
+// dialConfig returns the settings used to connect to Active Directory
+func (c *configEntry) dialConfig() *ldaputil.DialConfig {
+	return &ldaputil.DialConfig{
+		URL:           c.URL,
+		Certificate:   c.Certificate,
+		InsecureTLS:   c.InsecureTLS,
+		StartTLS:      c.StartTLS,
+		TLSMinVersion: c.TLSMinVersion,
+		TLSMaxVersion: c.TLSMaxVersion,
+	}
+}
+
+// generatePassword returns a new password, with characters of each class so
+// that it meets the Active Directory complexity requirements
+func (c *configEntry) generatePassword() (string, error) {
+	g, err := random.NewStringGenerator(c.PasswordLength, []random.CharsetRule{
+		{Charset: []rune(random.LowercaseCharset), MinChars: 1},
+		{Charset: []rune(random.UppercaseCharset), MinChars: 1},
+		{Charset: []rune(random.NumericCharset), MinChars: 1},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return g.Generate()
+}
+
+// Config returns the configuration, or nil if it isn't set
+func (b *backend) Config(s logical.Storage) (*configEntry, error) {
+	entry, err := s.Get(configPath)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result configEntry
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// requireConfig returns the configuration, or an error response if it isn't
+// set
+func (b *backend) requireConfig(s logical.Storage) (*configEntry, *logical.Response, error) {
+	conf, err := b.Config(s)
+	if err != nil {
+		return nil, nil, err
+	}
+	if conf == nil {
+		return nil, logical.ErrorResponse("the Active Directory connection must be configured at \"config\" first"), nil
+	}
+
+	return conf, nil, nil
+}
+
+func (b *backend) pathConfigRead(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	conf, err := b.Config(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if conf == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"url":             conf.URL,
+			"binddn":          conf.BindDN,
+			"userdn":          conf.UserDN,
+			"certificate":     conf.Certificate,
+			"insecure_tls":    conf.InsecureTLS,
+			"starttls":        conf.StartTLS,
+			"tls_min_version": conf.TLSMinVersion,
+			"tls_max_version": conf.TLSMaxVersion,
+			"ttl":             int64(conf.TTL.Seconds()),
+			"max_ttl":         int64(conf.MaxTTL.Seconds()),
+			"password_length": conf.PasswordLength,
+		},
+	}, nil
+}
+
+func (b *backend) pathConfigWrite(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	conf := &configEntry{
+		URL:            strings.ToLower(d.Get("url").(string)),
+		BindDN:         d.Get("binddn").(string),
+		BindPassword:   d.Get("bindpass").(string),
+		UserDN:         d.Get("userdn").(string),
+		Certificate:    d.Get("certificate").(string),
+		InsecureTLS:    d.Get("insecure_tls").(bool),
+		StartTLS:       d.Get("starttls").(bool),
+		TLSMinVersion:  d.Get("tls_min_version").(string),
+		TLSMaxVersion:  d.Get("tls_max_version").(string),
+		TTL:            time.Duration(d.Get("ttl").(int)) * time.Second,
+		MaxTTL:         time.Duration(d.Get("max_ttl").(int)) * time.Second,
+		PasswordLength: d.Get("password_length").(int),
+	}
+
+	if conf.BindDN == "" || conf.BindPassword == "" {
+		return logical.ErrorResponse("binddn and bindpass are required"), nil
+	}
+	if conf.UserDN == "" {
+		return logical.ErrorResponse("userdn is required"), nil
+	}
+
+	if conf.Certificate != "" {
+		block, _ := pem.Decode([]byte(conf.Certificate))
+		if block == nil || block.Type != "CERTIFICATE" {
+			return logical.ErrorResponse("failed to decode PEM block in the certificate"), nil
+		}
+		if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("failed to parse certificate %s", err)), nil
+		}
+	}
+
+	if _, ok := tlsutil.TLSLookup[conf.TLSMinVersion]; !ok {
+		return logical.ErrorResponse("invalid 'tls_min_version'"), nil
+	}
+	if _, ok := tlsutil.TLSLookup[conf.TLSMaxVersion]; !ok {
+		return logical.ErrorResponse("invalid 'tls_max_version'"), nil
+	}
+	if conf.TLSMaxVersion < conf.TLSMinVersion {
+		return logical.ErrorResponse("'tls_max_version' must be greater than or equal to 'tls_min_version'"), nil
+	}
+
+	if conf.TTL < minRotationPeriod || conf.MaxTTL < minRotationPeriod {
+		return logical.ErrorResponse(fmt.Sprintf("ttl and max_ttl must be at least %s", minRotationPeriod)), nil
+	}
+	if conf.TTL > conf.MaxTTL {
+		return logical.ErrorResponse("ttl must not be greater than max_ttl"), nil
+	}
+	if conf.PasswordLength < minPasswordLength || conf.PasswordLength > maxPasswordLength {
+		return logical.ErrorResponse(fmt.Sprintf("password_length must be from %d to %d", minPasswordLength, maxPasswordLength)), nil
+	}
+
+	entry, err := logical.StorageEntryJSON(configPath, conf)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(entry); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+const pathConfigHelpSyn = `
+Configure the connection to Active Directory.
+`
+
+const pathConfigHelpDesc = `
+This endpoint configures how Vault connects to Active Directory, the account it
+binds as, which must be allowed to reset the passwords of the service accounts,
+and the base DN under which service accounts are searched, by sAMAccountName or
+userPrincipalName.
+
+Active Directory only lets passwords be changed over an encrypted connection,
+so the URL must either use the "ldaps://" scheme, or "starttls" must be set.
+
+The "ttl" and "max_ttl" set the default and maximum rotation period of the
+passwords of roles, and "password_length" the length of the passwords Vault
+generates.
+`
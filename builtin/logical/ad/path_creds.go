@@ -0,0 +1,130 @@
+package ad
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/helper/locksutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathCreds(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "creds/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the role",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathCredsRead,
+		},
+
+		HelpSynopsis:    pathCredsHelpSyn,
+		HelpDescription: pathCredsHelpDesc,
+	}
+}
+
+func pathRotateRole(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "rotate-role/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the role",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathRotateRoleUpdate,
+		},
+
+		HelpSynopsis:    pathRotateRoleHelpSyn,
+		HelpDescription: pathRotateRoleHelpDesc,
+	}
+}
+
+func (b *backend) pathCredsRead(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	lock := locksutil.LockForKey(b.roleLocks, name)
+	lock.Lock()
+	defer lock.Unlock()
+
+	role, err := b.Role(req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return logical.ErrorResponse(fmt.Sprintf("unknown role: %s", name)), nil
+	}
+
+	// The password is rotated if Vault doesn't know it yet or it has
+	// expired, in case the periodic rotation hasn't happened yet
+	if !role.NextRotationTime().After(time.Now()) {
+		if err := b.rotateRolePassword(req.Storage, name, role); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+	}
+
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"username":         role.ServiceAccountName,
+			"current_password": role.CurrentPassword,
+			"ttl":              int64(role.NextRotationTime().Sub(time.Now()).Seconds()),
+		},
+	}
+	if role.LastPassword != "" {
+		resp.Data["last_password"] = role.LastPassword
+	}
+
+	return resp, nil
+}
+
+func (b *backend) pathRotateRoleUpdate(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	lock := locksutil.LockForKey(b.roleLocks, name)
+	lock.Lock()
+	defer lock.Unlock()
+
+	role, err := b.Role(req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return logical.ErrorResponse(fmt.Sprintf("unknown role: %s", name)), nil
+	}
+
+	if err := b.rotateRolePassword(req.Storage, name, role); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	return nil, nil
+}
+
+const pathCredsHelpSyn = `
+Read the password of the service account of a role.
+`
+
+const pathCredsHelpDesc = `
+This path returns the current password of the role's service account, and the
+previous one, which remains valid in applications caching it until they read
+the new one. The password is rotated if Vault doesn't know it yet, or if it is
+older than the role's "ttl". The returned "ttl" is the time left until the next
+rotation.
+`
+
+const pathRotateRoleHelpSyn = `
+Rotate the password of the service account of a role.
+`
+
+const pathRotateRoleHelpDesc = `
+This path rotates the password of the role's service account immediately,
+keeping the current password as the previous one.
+`
@@ -0,0 +1,397 @@
+package ad
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const (
+	libraryPath  = "library/"
+	checkOutPath = "checkout/"
+
+	defaultLendingTTL    = 24 * time.Hour
+	defaultMaxLendingTTL = 24 * time.Hour
+)
+
+func pathListLibrary(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "library/?$",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.pathLibraryList,
+		},
+
+		HelpSynopsis:    pathLibraryHelpSyn,
+		HelpDescription: pathLibraryHelpDesc,
+	}
+}
+
+func pathLibrary(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "library/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the library set",
+			},
+
+			"service_account_names": &framework.FieldSchema{
+				Type:        framework.TypeCommaStringSlice,
+				Description: "sAMAccountNames or userPrincipalNames of the existing service accounts that can be checked out",
+			},
+
+			"ttl": &framework.FieldSchema{
+				Type:        framework.TypeDurationSecond,
+				Default:     int(defaultLendingTTL.Seconds()),
+				Description: "Default and maximum TTL of check-outs. Defaults to 24 hours.",
+			},
+
+			"max_ttl": &framework.FieldSchema{
+				Type:        framework.TypeDurationSecond,
+				Default:     int(defaultMaxLendingTTL.Seconds()),
+				Description: "Maximum TTL check-outs can be renewed to. Defaults to 24 hours.",
+			},
+
+			"disable_check_in_enforcement": &framework.FieldSchema{
+				Type:        framework.TypeBool,
+				Description: "Let any client allowed to check in service accounts of the set check them in, not just the client that checked them out",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathLibraryRead,
+			logical.UpdateOperation: b.pathLibraryWrite,
+			logical.DeleteOperation: b.pathLibraryDelete,
+		},
+
+		HelpSynopsis:    pathLibraryHelpSyn,
+		HelpDescription: pathLibraryHelpDesc,
+	}
+}
+
+type libraryEntry struct {
+	ServiceAccountNames       []string      `json:"service_account_names"`
+	TTL                       time.Duration `json:"ttl"`
+	MaxTTL                    time.Duration `json:"max_ttl"`
+	DisableCheckInEnforcement bool          `json:"disable_check_in_enforcement"`
+}
+
+// checkOutEntry is the check-out status of a service account of a library
+// set. Vault knows the password of the accounts of library sets, which is
+// rotated when they are checked in.
+type checkOutEntry struct {
+	IsAvailable bool   `json:"is_available"`
+	Password    string `json:"password"`
+
+	// CheckOutID identifies the lease of the check-out, and
+	// BorrowerTokenHash the client that checked the account out
+	CheckOutID        string `json:"check_out_id"`
+	BorrowerTokenHash string `json:"borrower_token_hash"`
+}
+
+// Library returns the named library set, or nil if it doesn't exist
+func (b *backend) Library(s logical.Storage, name string) (*libraryEntry, error) {
+	entry, err := s.Get(libraryPath + name)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result libraryEntry
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// CheckOut returns the check-out status of the service account, or nil if
+// it isn't in a library set
+func (b *backend) CheckOut(s logical.Storage, serviceAccountName string) (*checkOutEntry, error) {
+	entry, err := s.Get(checkOutPath + serviceAccountName)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result checkOutEntry
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (b *backend) putCheckOut(s logical.Storage, serviceAccountName string, checkOut *checkOutEntry) error {
+	entry, err := logical.StorageEntryJSON(checkOutPath+serviceAccountName, checkOut)
+	if err != nil {
+		return err
+	}
+	return s.Put(entry)
+}
+
+// accountSet returns the name of the library set holding the service
+// account, if any
+func (b *backend) accountSet(s logical.Storage, serviceAccountName string) (string, error) {
+	names, err := s.List(libraryPath)
+	if err != nil {
+		return "", err
+	}
+
+	for _, name := range names {
+		set, err := b.Library(s, name)
+		if err != nil {
+			return "", err
+		}
+		if set != nil && strutil.StrListContains(set.ServiceAccountNames, serviceAccountName) {
+			return name, nil
+		}
+	}
+
+	return "", nil
+}
+
+// checkIn rotates the password of the checked out service account and makes
+// it available again. The caller must hold the library lock.
+func (b *backend) checkIn(s logical.Storage, conf *configEntry, serviceAccountName string) error {
+	checkOut, err := b.CheckOut(s, serviceAccountName)
+	if err != nil {
+		return err
+	}
+	var oldPassword string
+	if checkOut != nil {
+		oldPassword = checkOut.Password
+	}
+
+	password, err := conf.generatePassword()
+	if err != nil {
+		return err
+	}
+
+	// Write the new password to the WAL before changing it, so that it
+	// can be recovered if we fail to store it below
+	walID, err := framework.PutWAL(s, walCheckIn, &checkInWAL{
+		ServiceAccountName: serviceAccountName,
+		OldPassword:        oldPassword,
+		NewPassword:        password,
+	})
+	if err != nil {
+		return fmt.Errorf("error writing WAL entry: %v", err)
+	}
+
+	// Whether or not the password was changed, the WAL entry is left in
+	// place on failure for the rollback to complete the check-in
+	if err := b.setCheckInPassword(s, conf, serviceAccountName, password); err != nil {
+		return err
+	}
+
+	if err := framework.DeleteWAL(s, walID); err != nil {
+		b.logger.Warn("ad: failed to delete WAL entry for check-in", "service_account_name", serviceAccountName, "error", err)
+	}
+
+	return nil
+}
+
+// setCheckInPassword sets the password of the service account and stores it
+// as available. The caller must hold the library lock.
+func (b *backend) setCheckInPassword(s logical.Storage, conf *configEntry, serviceAccountName, password string) error {
+	if err := b.client.UpdatePassword(conf, serviceAccountName, password); err != nil {
+		return err
+	}
+
+	return b.putCheckOut(s, serviceAccountName, &checkOutEntry{
+		IsAvailable: true,
+		Password:    password,
+	})
+}
+
+func (b *backend) pathLibraryList(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	entries, err := req.Storage.List(libraryPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(entries), nil
+}
+
+func (b *backend) pathLibraryRead(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	set, err := b.Library(req.Storage, d.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+	if set == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"service_account_names":        set.ServiceAccountNames,
+			"ttl":                          int64(set.TTL.Seconds()),
+			"max_ttl":                      int64(set.MaxTTL.Seconds()),
+			"disable_check_in_enforcement": set.DisableCheckInEnforcement,
+		},
+	}, nil
+}
+
+func (b *backend) pathLibraryWrite(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	conf, errResp, err := b.requireConfig(req.Storage)
+	if errResp != nil || err != nil {
+		return errResp, err
+	}
+
+	set := &libraryEntry{
+		ServiceAccountNames:       strutil.RemoveDuplicates(d.Get("service_account_names").([]string), false),
+		TTL:                       time.Duration(d.Get("ttl").(int)) * time.Second,
+		MaxTTL:                    time.Duration(d.Get("max_ttl").(int)) * time.Second,
+		DisableCheckInEnforcement: d.Get("disable_check_in_enforcement").(bool),
+	}
+	if len(set.ServiceAccountNames) == 0 {
+		return logical.ErrorResponse("service_account_names is required"), nil
+	}
+	if set.TTL <= 0 || set.MaxTTL <= 0 {
+		return logical.ErrorResponse("ttl and max_ttl must be positive"), nil
+	}
+	if set.TTL > set.MaxTTL {
+		return logical.ErrorResponse("ttl must not be greater than max_ttl"), nil
+	}
+
+	b.libraryLock.Lock()
+	defer b.libraryLock.Unlock()
+
+	existing, err := b.Library(req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	var previousAccounts []string
+	if existing != nil {
+		previousAccounts = existing.ServiceAccountNames
+	}
+
+	// Accounts can only be removed once they are checked in
+	var removed []string
+	for _, serviceAccountName := range previousAccounts {
+		if strutil.StrListContains(set.ServiceAccountNames, serviceAccountName) {
+			continue
+		}
+		checkOut, err := b.CheckOut(req.Storage, serviceAccountName)
+		if err != nil {
+			return nil, err
+		}
+		if checkOut != nil && !checkOut.IsAvailable {
+			return logical.ErrorResponse(fmt.Sprintf("service account %q is checked out", serviceAccountName)), nil
+		}
+		removed = append(removed, serviceAccountName)
+	}
+
+	var added []string
+	for _, serviceAccountName := range set.ServiceAccountNames {
+		if strutil.StrListContains(previousAccounts, serviceAccountName) {
+			continue
+		}
+		if resp, err := b.checkAccountAvailable(req.Storage, conf, serviceAccountName, "", name); resp != nil || err != nil {
+			return resp, err
+		}
+		added = append(added, serviceAccountName)
+	}
+
+	entry, err := logical.StorageEntryJSON(libraryPath+name, set)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(entry); err != nil {
+		return nil, err
+	}
+
+	for _, serviceAccountName := range removed {
+		if err := req.Storage.Delete(checkOutPath + serviceAccountName); err != nil {
+			return nil, err
+		}
+	}
+
+	// Vault sets the passwords of the new accounts, so that it knows them
+	for _, serviceAccountName := range added {
+		if err := b.checkIn(req.Storage, conf, serviceAccountName); err != nil {
+			return nil, fmt.Errorf("failed to set the password of service account %q: %v", serviceAccountName, err)
+		}
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathLibraryDelete(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	b.libraryLock.Lock()
+	defer b.libraryLock.Unlock()
+
+	set, err := b.Library(req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if set == nil {
+		return nil, nil
+	}
+
+	for _, serviceAccountName := range set.ServiceAccountNames {
+		checkOut, err := b.CheckOut(req.Storage, serviceAccountName)
+		if err != nil {
+			return nil, err
+		}
+		if checkOut != nil && !checkOut.IsAvailable {
+			return logical.ErrorResponse(fmt.Sprintf("service account %q is checked out", serviceAccountName)), nil
+		}
+	}
+
+	if err := req.Storage.Delete(libraryPath + name); err != nil {
+		return nil, err
+	}
+	for _, serviceAccountName := range set.ServiceAccountNames {
+		if err := req.Storage.Delete(checkOutPath + serviceAccountName); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
+
+// sortedAccounts returns the service accounts of the set in a stable order
+func sortedAccounts(set *libraryEntry) []string {
+	accounts := append([]string(nil), set.ServiceAccountNames...)
+	sort.Strings(accounts)
+	return accounts
+}
+
+const pathLibraryHelpSyn = `
+Manage the library sets of shared service accounts.
+`
+
+const pathLibraryHelpDesc = `
+This path lets you manage library sets: groups of existing service accounts
+that clients check out for their exclusive use, and check back in when they are
+done. Vault sets the passwords of the accounts when they are added to a set,
+and rotates them when they are checked in, so that borrowers can't keep using
+them.
+
+Check-outs last "ttl" unless a shorter TTL is requested, and can be renewed up
+to "max_ttl". Unless "disable_check_in_enforcement" is set, only the client
+that checked out an account can check it in; "library/manage/<name>/check-in"
+checks accounts in regardless.
+
+A service account can only be in one library set, and can't be managed by a
+role. Checked out accounts can't be removed from their set.
+`
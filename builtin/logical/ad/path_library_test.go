@@ -0,0 +1,307 @@
+package ad
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func TestBackend_library(t *testing.T) {
+	b, s, client := getBackend(t, "svc1", "svc2", "web")
+	configure(t, b, s)
+
+	request(t, b, s, "", logical.UpdateOperation, "roles/web", map[string]interface{}{
+		"service_account_name": "web",
+	})
+
+	for _, data := range []map[string]interface{}{
+		{},
+		{"service_account_names": "missing"},
+		{"service_account_names": "svc1,web"},
+		{"service_account_names": "svc1", "ttl": 7200, "max_ttl": 3600},
+	} {
+		resp := request(t, b, s, "", logical.UpdateOperation, "library/accounting", data)
+		if resp == nil || !resp.IsError() {
+			t.Fatalf("expected an error for %v, got: %#v", data, resp)
+		}
+	}
+
+	resp := request(t, b, s, "", logical.UpdateOperation, "library/accounting", map[string]interface{}{
+		"service_account_names": "svc1,svc2",
+		"ttl":                   3600,
+		"max_ttl":               7200,
+	})
+	if resp != nil && resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	// Vault sets the passwords of the accounts
+	if client.password("svc1") == "initial" || client.password("svc2") == "initial" {
+		t.Fatal("passwords should have been set")
+	}
+
+	resp = request(t, b, s, "", logical.UpdateOperation, "roles/svc", map[string]interface{}{
+		"service_account_name": "svc1",
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error for an account in a library set, got: %#v", resp)
+	}
+	resp = request(t, b, s, "", logical.UpdateOperation, "library/other", map[string]interface{}{
+		"service_account_names": "svc2",
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error for an account in another set, got: %#v", resp)
+	}
+
+	resp = request(t, b, s, "", logical.ReadOperation, "library/accounting", nil)
+	if resp.Data["ttl"] != int64(3600) || resp.Data["max_ttl"] != int64(7200) || resp.Data["disable_check_in_enforcement"] != false {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	resp = request(t, b, s, "", logical.ListOperation, "library/", nil)
+	if keys := resp.Data["keys"].([]string); len(keys) != 1 || keys[0] != "accounting" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// Check out both accounts
+	resp = request(t, b, s, "alice", logical.UpdateOperation, "library/accounting/check-out", map[string]interface{}{
+		"ttl": 600,
+	})
+	if resp == nil || resp.IsError() || resp.Secret == nil {
+		t.Fatalf("bad: %#v", resp)
+	}
+	aliceAccount := resp.Data["service_account_name"].(string)
+	if resp.Data["password"] != client.password(aliceAccount) {
+		t.Fatalf("bad password: %#v", resp.Data)
+	}
+	if resp.Secret.TTL != 10*time.Minute || !resp.Secret.Renewable {
+		t.Fatalf("bad secret: %#v", resp.Secret)
+	}
+	aliceSecret := resp.Secret
+
+	resp = request(t, b, s, "bob", logical.UpdateOperation, "library/accounting/check-out", nil)
+	if resp == nil || resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+	bobAccount := resp.Data["service_account_name"].(string)
+	if bobAccount == aliceAccount {
+		t.Fatal("the same account was checked out twice")
+	}
+	if resp.Secret.TTL != time.Hour {
+		t.Fatalf("check-out should default to the set's ttl: %#v", resp.Secret)
+	}
+	bobSecret := resp.Secret
+
+	resp = request(t, b, s, "carol", logical.UpdateOperation, "library/accounting/check-out", nil)
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error with no available account, got: %#v", resp)
+	}
+
+	resp = request(t, b, s, "", logical.ReadOperation, "library/accounting/status", nil)
+	for _, account := range []string{"svc1", "svc2"} {
+		if resp.Data[account].(map[string]interface{})["available"] != false {
+			t.Fatalf("bad: %#v", resp.Data)
+		}
+	}
+
+	// Checked out accounts can't be removed
+	resp = request(t, b, s, "", logical.UpdateOperation, "library/accounting", map[string]interface{}{
+		"service_account_names": "svc1",
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error removing a checked out account, got: %#v", resp)
+	}
+	resp = request(t, b, s, "", logical.DeleteOperation, "library/accounting", nil)
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error deleting a set with checked out accounts, got: %#v", resp)
+	}
+
+	// Only the borrower can check an account in
+	resp = request(t, b, s, "bob", logical.UpdateOperation, "library/accounting/check-in", map[string]interface{}{
+		"service_account_names": aliceAccount,
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error checking in another client's account, got: %#v", resp)
+	}
+
+	alicePassword := client.password(aliceAccount)
+	resp = request(t, b, s, "alice", logical.UpdateOperation, "library/accounting/check-in", nil)
+	if resp == nil || resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+	if checkIns := resp.Data["check_ins"].([]string); len(checkIns) != 1 || checkIns[0] != aliceAccount {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	if client.password(aliceAccount) == alicePassword {
+		t.Fatal("password should have been rotated on check-in")
+	}
+
+	// The lease of a checked in account can't be renewed, and revoking it
+	// doesn't affect the account's next check-out
+	resp, err := b.HandleRequest(&logical.Request{
+		Operation: logical.RenewOperation,
+		Storage:   s,
+		Secret:    aliceSecret,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error renewing a checked in account, got: %#v", resp)
+	}
+
+	resp = request(t, b, s, "carol", logical.UpdateOperation, "library/accounting/check-out", nil)
+	if resp == nil || resp.IsError() || resp.Data["service_account_name"] != aliceAccount {
+		t.Fatalf("bad: %#v", resp)
+	}
+	carolPassword := client.password(aliceAccount)
+
+	resp, err = b.HandleRequest(&logical.Request{
+		Operation: logical.RevokeOperation,
+		Storage:   s,
+		Secret:    aliceSecret,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.password(aliceAccount) != carolPassword {
+		t.Fatal("revoking a stale lease should not check the account in")
+	}
+
+	// Bob's lease can be renewed, and revoking it checks the account in
+	bobSecret.IssueTime = time.Now()
+	resp, err = b.HandleRequest(&logical.Request{
+		Operation: logical.RenewOperation,
+		Storage:   s,
+		Secret:    bobSecret,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp == nil || resp.IsError() || resp.Secret == nil {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	bobPassword := client.password(bobAccount)
+	_, err = b.HandleRequest(&logical.Request{
+		Operation: logical.RevokeOperation,
+		Storage:   s,
+		Secret:    bobSecret,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.password(bobAccount) == bobPassword {
+		t.Fatal("password should have been rotated on revocation")
+	}
+
+	// Operators can check in any account
+	resp = request(t, b, s, "", logical.UpdateOperation, "library/manage/accounting/check-in", nil)
+	if resp == nil || resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+	if checkIns := resp.Data["check_ins"].([]string); len(checkIns) != 1 || checkIns[0] != aliceAccount {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	resp = request(t, b, s, "", logical.ReadOperation, "library/accounting/status", nil)
+	for _, account := range []string{"svc1", "svc2"} {
+		if resp.Data[account].(map[string]interface{})["available"] != true {
+			t.Fatalf("bad: %#v", resp.Data)
+		}
+	}
+
+	request(t, b, s, "", logical.DeleteOperation, "library/accounting", nil)
+	if resp := request(t, b, s, "", logical.ReadOperation, "library/accounting", nil); resp != nil {
+		t.Fatalf("set should have been deleted: %#v", resp)
+	}
+	resp = request(t, b, s, "", logical.UpdateOperation, "roles/svc", map[string]interface{}{
+		"service_account_name": "svc1",
+	})
+	if resp != nil && resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+}
+
+func TestBackend_libraryCheckInEnforcement(t *testing.T) {
+	b, s, _ := getBackend(t, "svc1")
+	configure(t, b, s)
+
+	request(t, b, s, "", logical.UpdateOperation, "library/shared", map[string]interface{}{
+		"service_account_names":        "svc1",
+		"disable_check_in_enforcement": true,
+	})
+
+	resp := request(t, b, s, "alice", logical.UpdateOperation, "library/shared/check-out", nil)
+	if resp == nil || resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	resp = request(t, b, s, "bob", logical.UpdateOperation, "library/shared/check-in", nil)
+	if resp == nil || resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+	if checkIns := resp.Data["check_ins"].([]string); len(checkIns) != 1 || checkIns[0] != "svc1" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// Checking in an available account is a no-op
+	resp = request(t, b, s, "bob", logical.UpdateOperation, "library/shared/check-in", map[string]interface{}{
+		"service_account_names": "svc1",
+	})
+	if resp == nil || resp.IsError() || len(resp.Data["check_ins"].([]string)) != 0 {
+		t.Fatalf("bad: %#v", resp)
+	}
+}
+
+func TestBackend_libraryCheckInRollback(t *testing.T) {
+	b, s, client := getBackend(t, "svc1")
+	configure(t, b, s)
+
+	// The set is stored, but setting the password of its account fails
+	client.fail = true
+	_, err := b.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "library/shared",
+		Storage:   s,
+		Data: map[string]interface{}{
+			"service_account_names": "svc1",
+		},
+	})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	client.fail = false
+
+	// A check-in that was superseded is not applied
+	if _, err := framework.PutWAL(s, walCheckIn, &checkInWAL{
+		ServiceAccountName: "svc1",
+		OldPassword:        "stale",
+		NewPassword:        "ignored",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	request(t, b, s, "", logical.RollbackOperation, "", map[string]interface{}{
+		"immediate": true,
+	})
+	walKeys, err := framework.ListWAL(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(walKeys) != 0 {
+		t.Fatalf("WAL entries should have been deleted, got %d", len(walKeys))
+	}
+
+	// The interrupted check-in was completed, so the account can be
+	// checked out with the password Vault set
+	password := client.password("svc1")
+	if password == "initial" || password == "ignored" {
+		t.Fatalf("bad password: %q", password)
+	}
+	resp := request(t, b, s, "alice", logical.UpdateOperation, "library/shared/check-out", nil)
+	if resp == nil || resp.IsError() || resp.Data["password"] != password {
+		t.Fatalf("bad: %#v", resp)
+	}
+}
@@ -0,0 +1,282 @@
+package ad
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/helper/locksutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const (
+	rolePath = "role/"
+
+	// minRotationPeriod matches the interval at which the periodic function
+	// checks for roles that are due to be rotated
+	minRotationPeriod = time.Minute
+)
+
+func pathListRoles(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "roles/?$",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.pathRoleList,
+		},
+
+		HelpSynopsis:    pathRoleHelpSyn,
+		HelpDescription: pathRoleHelpDesc,
+	}
+}
+
+func pathRoles(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "roles/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the role",
+			},
+
+			"service_account_name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "sAMAccountName or userPrincipalName of the existing service account whose password is managed by the role",
+			},
+
+			"ttl": &framework.FieldSchema{
+				Type:        framework.TypeDurationSecond,
+				Description: "Period after which the password is rotated. Defaults to the configured ttl.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathRoleRead,
+			logical.UpdateOperation: b.pathRoleWrite,
+			logical.DeleteOperation: b.pathRoleDelete,
+		},
+
+		HelpSynopsis:    pathRoleHelpSyn,
+		HelpDescription: pathRoleHelpDesc,
+	}
+}
+
+type roleEntry struct {
+	ServiceAccountName string        `json:"service_account_name"`
+	TTL                time.Duration `json:"ttl"`
+
+	// The passwords are only known once Vault has rotated them
+	CurrentPassword   string    `json:"current_password"`
+	LastPassword      string    `json:"last_password"`
+	LastVaultRotation time.Time `json:"last_vault_rotation"`
+}
+
+// NextRotationTime returns the time the password is due to be rotated, which
+// is in the past if Vault doesn't know it yet
+func (r *roleEntry) NextRotationTime() time.Time {
+	if r.CurrentPassword == "" {
+		return time.Time{}
+	}
+	return r.LastVaultRotation.Add(r.TTL)
+}
+
+// Role returns the named role, or nil if it doesn't exist
+func (b *backend) Role(s logical.Storage, name string) (*roleEntry, error) {
+	entry, err := s.Get(rolePath + name)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result roleEntry
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (b *backend) putRole(s logical.Storage, name string, role *roleEntry) error {
+	entry, err := logical.StorageEntryJSON(rolePath+name, role)
+	if err != nil {
+		return err
+	}
+	return s.Put(entry)
+}
+
+// accountRole returns the name of the role managing the service account, if
+// any
+func (b *backend) accountRole(s logical.Storage, serviceAccountName string) (string, error) {
+	names, err := s.List(rolePath)
+	if err != nil {
+		return "", err
+	}
+
+	for _, name := range names {
+		role, err := b.Role(s, name)
+		if err != nil {
+			return "", err
+		}
+		if role != nil && role.ServiceAccountName == serviceAccountName {
+			return name, nil
+		}
+	}
+
+	return "", nil
+}
+
+func (b *backend) pathRoleList(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	entries, err := req.Storage.List(rolePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(entries), nil
+}
+
+func (b *backend) pathRoleRead(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	role, err := b.Role(req.Storage, d.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, nil
+	}
+
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"service_account_name": role.ServiceAccountName,
+			"ttl":                  int64(role.TTL.Seconds()),
+		},
+	}
+	if !role.LastVaultRotation.IsZero() {
+		resp.Data["last_vault_rotation"] = role.LastVaultRotation
+	}
+
+	return resp, nil
+}
+
+func (b *backend) pathRoleWrite(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	conf, errResp, err := b.requireConfig(req.Storage)
+	if errResp != nil || err != nil {
+		return errResp, err
+	}
+
+	lock := locksutil.LockForKey(b.roleLocks, name)
+	lock.Lock()
+	defer lock.Unlock()
+
+	role, err := b.Role(req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		role = &roleEntry{}
+	}
+
+	if serviceAccountName, ok := d.GetOk("service_account_name"); ok && serviceAccountName.(string) != role.ServiceAccountName {
+		// A new account's password isn't known yet
+		role.ServiceAccountName = serviceAccountName.(string)
+		role.CurrentPassword = ""
+		role.LastPassword = ""
+		role.LastVaultRotation = time.Time{}
+
+		if resp, err := b.checkAccountAvailable(req.Storage, conf, role.ServiceAccountName, name, ""); resp != nil || err != nil {
+			return resp, err
+		}
+	}
+	if role.ServiceAccountName == "" {
+		return logical.ErrorResponse("service_account_name is required"), nil
+	}
+
+	if ttl, ok := d.GetOk("ttl"); ok {
+		role.TTL = time.Duration(ttl.(int)) * time.Second
+	}
+	if role.TTL == 0 {
+		role.TTL = conf.TTL
+	}
+	if role.TTL < minRotationPeriod {
+		return logical.ErrorResponse(fmt.Sprintf("ttl must be at least %s", minRotationPeriod)), nil
+	}
+	if role.TTL > conf.MaxTTL {
+		return logical.ErrorResponse(fmt.Sprintf("ttl must not be greater than the configured max_ttl of %s", conf.MaxTTL)), nil
+	}
+
+	if err := b.putRole(req.Storage, name, role); err != nil {
+		return nil, err
+	}
+
+	if role.CurrentPassword != "" {
+		if err := b.scheduleRotation(name, role); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathRoleDelete(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	lock := locksutil.LockForKey(b.roleLocks, name)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if err := req.Storage.Delete(rolePath + name); err != nil {
+		return nil, err
+	}
+
+	b.rotationSchedule.Unschedule(name)
+
+	return nil, nil
+}
+
+// checkAccountAvailable checks that the service account exists, and is
+// neither managed by another role nor in another library set. The role or set
+// being written is given by name.
+func (b *backend) checkAccountAvailable(s logical.Storage, conf *configEntry, serviceAccountName, roleName, setName string) (*logical.Response, error) {
+	dn, err := b.client.AccountDN(conf, serviceAccountName)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+	if dn == "" {
+		return logical.ErrorResponse(fmt.Sprintf("service account %q not found", serviceAccountName)), nil
+	}
+
+	otherRole, err := b.accountRole(s, serviceAccountName)
+	if err != nil {
+		return nil, err
+	}
+	if otherRole != "" && otherRole != roleName {
+		return logical.ErrorResponse(fmt.Sprintf("service account %q is managed by role %q", serviceAccountName, otherRole)), nil
+	}
+
+	otherSet, err := b.accountSet(s, serviceAccountName)
+	if err != nil {
+		return nil, err
+	}
+	if otherSet != "" && otherSet != setName {
+		return logical.ErrorResponse(fmt.Sprintf("service account %q is in library set %q", serviceAccountName, otherSet)), nil
+	}
+
+	return nil, nil
+}
+
+const pathRoleHelpSyn = `
+Manage the roles bound to Active Directory service accounts.
+`
+
+const pathRoleHelpDesc = `
+This path lets you manage the roles of the backend. A role is bound to an
+existing service account, whose password Vault rotates every "ttl" once it has
+been read from "creds/<name>" for the first time. The service account can't be
+managed by another role or be in a library set.
+`
@@ -0,0 +1,205 @@
+package ad
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func TestBackend_roles(t *testing.T) {
+	b, s, client := getBackend(t, "web", "db")
+	configure(t, b, s)
+
+	resp := request(t, b, s, "", logical.UpdateOperation, "roles/web", map[string]interface{}{
+		"service_account_name": "missing",
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error for an unknown account, got: %#v", resp)
+	}
+
+	resp = request(t, b, s, "", logical.UpdateOperation, "roles/web", map[string]interface{}{
+		"service_account_name": "web",
+		"ttl":                  3600,
+	})
+	if resp != nil && resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	resp = request(t, b, s, "", logical.UpdateOperation, "roles/other", map[string]interface{}{
+		"service_account_name": "web",
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error for an account managed by another role, got: %#v", resp)
+	}
+
+	resp = request(t, b, s, "", logical.ReadOperation, "roles/web", nil)
+	if resp == nil || resp.Data["service_account_name"] != "web" || resp.Data["ttl"] != int64(3600) {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	resp = request(t, b, s, "", logical.ListOperation, "roles/", nil)
+	if keys := resp.Data["keys"].([]string); len(keys) != 1 || keys[0] != "web" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// The password is unknown until it is first read
+	if client.password("web") != "initial" {
+		t.Fatal("password should not be rotated when the role is written")
+	}
+
+	resp = request(t, b, s, "", logical.ReadOperation, "creds/web", nil)
+	if resp == nil || resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+	first := resp.Data["current_password"].(string)
+	if first == "" || first != client.password("web") || len(first) != 20 {
+		t.Fatalf("bad password: %q", first)
+	}
+	if _, ok := resp.Data["last_password"]; ok {
+		t.Fatalf("unexpected last_password: %#v", resp.Data)
+	}
+	if ttl := resp.Data["ttl"].(int64); ttl <= 3500 || ttl > 3600 {
+		t.Fatalf("bad ttl: %d", ttl)
+	}
+
+	// Reading again returns the same password
+	resp = request(t, b, s, "", logical.ReadOperation, "creds/web", nil)
+	if resp.Data["current_password"] != first {
+		t.Fatalf("password should not have been rotated: %#v", resp.Data)
+	}
+
+	request(t, b, s, "", logical.UpdateOperation, "rotate-role/web", nil)
+	resp = request(t, b, s, "", logical.ReadOperation, "creds/web", nil)
+	if resp.Data["current_password"] == first || resp.Data["current_password"] != client.password("web") {
+		t.Fatalf("password should have been rotated: %#v", resp.Data)
+	}
+	if resp.Data["last_password"] != first {
+		t.Fatalf("bad last_password: %#v", resp.Data)
+	}
+
+	request(t, b, s, "", logical.DeleteOperation, "roles/web", nil)
+	if resp := request(t, b, s, "", logical.ReadOperation, "roles/web", nil); resp != nil {
+		t.Fatalf("role should have been deleted: %#v", resp)
+	}
+	if b.rotationSchedule.Len() != 0 {
+		t.Fatal("role should have been removed from the rotation schedule")
+	}
+
+	// The account can be managed by another role once it is deleted
+	resp = request(t, b, s, "", logical.UpdateOperation, "roles/other", map[string]interface{}{
+		"service_account_name": "web",
+	})
+	if resp != nil && resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+	resp = request(t, b, s, "", logical.ReadOperation, "roles/other", nil)
+	if resp.Data["ttl"] != int64(defaultPasswordTTL.Seconds()) {
+		t.Fatalf("role should default to the configured ttl: %#v", resp.Data)
+	}
+}
+
+func TestBackend_rotation(t *testing.T) {
+	b, s, client := getBackend(t, "web")
+	configure(t, b, s)
+
+	request(t, b, s, "", logical.UpdateOperation, "roles/web", map[string]interface{}{
+		"service_account_name": "web",
+		"ttl":                  60,
+	})
+	resp := request(t, b, s, "", logical.ReadOperation, "creds/web", nil)
+	first := resp.Data["current_password"].(string)
+
+	// Nothing is due yet
+	if err := b.periodicFunc(&logical.Request{Storage: s}); err != nil {
+		t.Fatal(err)
+	}
+	if client.password("web") != first {
+		t.Fatal("password should not have been rotated")
+	}
+
+	// Expire the password
+	role, err := b.Role(s, "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	role.LastVaultRotation = time.Now().Add(-2 * time.Minute)
+	if err := b.putRole(s, "web", role); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.scheduleRotation("web", role); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.periodicFunc(&logical.Request{Storage: s}); err != nil {
+		t.Fatal(err)
+	}
+	second := client.password("web")
+	if second == first {
+		t.Fatal("password should have been rotated")
+	}
+
+	resp = request(t, b, s, "", logical.ReadOperation, "creds/web", nil)
+	if resp.Data["current_password"] != second || resp.Data["last_password"] != first {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// Failed rotations are retried on the next run
+	role, err = b.Role(s, "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	role.LastVaultRotation = time.Now().Add(-2 * time.Minute)
+	if err := b.putRole(s, "web", role); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.scheduleRotation("web", role); err != nil {
+		t.Fatal(err)
+	}
+
+	client.fail = true
+	if err := b.periodicFunc(&logical.Request{Storage: s}); err != nil {
+		t.Fatal(err)
+	}
+	if client.password("web") != second || b.rotationSchedule.Len() != 1 {
+		t.Fatal("failed rotation should have stayed scheduled")
+	}
+
+	client.fail = false
+	if err := b.periodicFunc(&logical.Request{Storage: s}); err != nil {
+		t.Fatal(err)
+	}
+	third := client.password("web")
+	if third == second {
+		t.Fatal("password should have been rotated")
+	}
+
+	// The failed rotation left a WAL entry, which is discarded since the
+	// role was rotated since, while an interrupted rotation is completed
+	if _, err := framework.PutWAL(s, walRotateRolePassword, &rotateRolePasswordWAL{
+		RoleName:           "web",
+		ServiceAccountName: "web",
+		OldPassword:        third,
+		NewPassword:        "fromwal",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	request(t, b, s, "", logical.RollbackOperation, "", map[string]interface{}{
+		"immediate": true,
+	})
+	if client.password("web") != "fromwal" {
+		t.Fatalf("expected the password from the WAL entry, got %q", client.password("web"))
+	}
+	resp = request(t, b, s, "", logical.ReadOperation, "creds/web", nil)
+	if resp.Data["current_password"] != "fromwal" || resp.Data["last_password"] != third {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	walKeys, err := framework.ListWAL(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(walKeys) != 0 {
+		t.Fatalf("WAL entries should have been deleted, got %d", len(walKeys))
+	}
+}
@@ -0,0 +1,126 @@
+package ad
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/vault/helper/locksutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/mitchellh/mapstructure"
+)
+
+const (
+	walRotateRolePassword = "rotateRolePassword"
+	walCheckIn            = "checkIn"
+)
+
+// rotateRolePasswordWAL is written before the password of the service account
+// of a role is changed and deleted once the new password has been stored
+type rotateRolePasswordWAL struct {
+	RoleName           string `json:"role_name" mapstructure:"role_name"`
+	ServiceAccountName string `json:"service_account_name" mapstructure:"service_account_name"`
+	OldPassword        string `json:"old_password" mapstructure:"old_password"`
+	NewPassword        string `json:"new_password" mapstructure:"new_password"`
+}
+
+// checkInWAL is written before the password of a checked out service account
+// is changed and deleted once the account is stored as available
+type checkInWAL struct {
+	ServiceAccountName string `json:"service_account_name" mapstructure:"service_account_name"`
+	OldPassword        string `json:"old_password" mapstructure:"old_password"`
+	NewPassword        string `json:"new_password" mapstructure:"new_password"`
+}
+
+func (b *backend) walRollback(req *logical.Request, kind string, data interface{}) error {
+	switch kind {
+	case walRotateRolePassword:
+		return b.rotateRolePasswordRollback(req, data)
+	case walCheckIn:
+		return b.checkInRollback(req, data)
+	default:
+		return fmt.Errorf("unknown type to rollback")
+	}
+}
+
+// rotateRolePasswordRollback completes an interrupted rotation of the
+// password of a role. Active Directory may or may not have the new password
+// from the WAL entry, so it is set again and stored, unless the role has been
+// deleted, bound to another account or rotated since.
+func (b *backend) rotateRolePasswordRollback(req *logical.Request, data interface{}) error {
+	var entry rotateRolePasswordWAL
+	if err := mapstructure.Decode(data, &entry); err != nil {
+		return err
+	}
+
+	lock := locksutil.LockForKey(b.roleLocks, entry.RoleName)
+	lock.Lock()
+	defer lock.Unlock()
+
+	role, err := b.Role(req.Storage, entry.RoleName)
+	if err != nil {
+		return err
+	}
+	if role == nil || role.ServiceAccountName != entry.ServiceAccountName || role.CurrentPassword != entry.OldPassword {
+		return nil
+	}
+
+	conf, err := b.Config(req.Storage)
+	if err != nil {
+		return err
+	}
+	if conf == nil {
+		return fmt.Errorf("the Active Directory connection is not configured")
+	}
+
+	if err := b.setRolePassword(req.Storage, conf, entry.RoleName, role, entry.NewPassword); err != nil {
+		return fmt.Errorf("failed to set the rotated password of role %s: %v", entry.RoleName, err)
+	}
+
+	return b.scheduleRotation(entry.RoleName, role)
+}
+
+// checkInRollback completes an interrupted check-in of a service account by
+// setting the new password from the WAL entry again and storing the account
+// as available, unless it has been removed from its library set or checked in
+// since.
+func (b *backend) checkInRollback(req *logical.Request, data interface{}) error {
+	var entry checkInWAL
+	if err := mapstructure.Decode(data, &entry); err != nil {
+		return err
+	}
+
+	b.libraryLock.Lock()
+	defer b.libraryLock.Unlock()
+
+	checkOut, err := b.CheckOut(req.Storage, entry.ServiceAccountName)
+	if err != nil {
+		return err
+	}
+	switch {
+	case checkOut == nil && entry.OldPassword == "":
+		// Accounts added to a library set have no check-out status until
+		// their first check-in completes
+		setName, err := b.accountSet(req.Storage, entry.ServiceAccountName)
+		if err != nil {
+			return err
+		}
+		if setName == "" {
+			return nil
+		}
+	case checkOut == nil || checkOut.Password != entry.OldPassword:
+		return nil
+	}
+
+	conf, err := b.Config(req.Storage)
+	if err != nil {
+		return err
+	}
+	if conf == nil {
+		return fmt.Errorf("the Active Directory connection is not configured")
+	}
+
+	if err := b.setCheckInPassword(req.Storage, conf, entry.ServiceAccountName, entry.NewPassword); err != nil {
+		return fmt.Errorf("failed to check in service account %q: %v", entry.ServiceAccountName, err)
+	}
+
+	return nil
+}
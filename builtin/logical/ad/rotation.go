@@ -0,0 +1,152 @@
+package ad
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/helper/consts"
+	"github.com/hashicorp/vault/helper/locksutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+// periodicFunc is invoked by the RollbackManager, which only runs on the
+// active node, once a minute. It rotates the password of every role whose TTL
+// has elapsed. Failures are logged with the roles they concern; the roles stay
+// scheduled and are retried on the next run.
+func (b *backend) periodicFunc(req *logical.Request) error {
+	if err := b.rotateDueRoles(req.Storage); err != nil {
+		b.logger.Error("ad: failed to rotate role passwords", "error", err)
+	}
+
+	return nil
+}
+
+func (b *backend) rotateDueRoles(s logical.Storage) error {
+	// Passwords are rotated on the primary and replicated to secondaries
+	if b.System().ReplicationState() == consts.ReplicationSecondary {
+		return nil
+	}
+
+	return b.rotationSchedule.RunDue(func() error {
+		return b.loadRoles(s)
+	}, func(name string) error {
+		if err := b.rotateRole(s, name); err != nil {
+			return fmt.Errorf("failed to rotate password of role %s: %v", name, err)
+		}
+		return nil
+	})
+}
+
+// loadRoles schedules the rotation of every role whose password Vault
+// manages
+func (b *backend) loadRoles(s logical.Storage) error {
+	names, err := s.List(rolePath)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		lock := locksutil.LockForKey(b.roleLocks, name)
+		lock.RLock()
+		role, err := b.Role(s, name)
+		if err == nil && role != nil && role.CurrentPassword != "" {
+			err = b.scheduleRotation(name, role)
+		}
+		lock.RUnlock()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rotateRole rotates the password of the named role if it is due, and
+// schedules the next rotation
+func (b *backend) rotateRole(s logical.Storage, name string) error {
+	lock := locksutil.LockForKey(b.roleLocks, name)
+	lock.Lock()
+	defer lock.Unlock()
+
+	role, err := b.Role(s, name)
+	if err != nil {
+		return err
+	}
+	if role == nil {
+		// The role has been deleted
+		return nil
+	}
+
+	// The role may have been rotated or updated since it was scheduled
+	if role.NextRotationTime().Before(time.Now()) {
+		return b.rotateRolePassword(s, name, role)
+	}
+
+	return b.scheduleRotation(name, role)
+}
+
+// scheduleRotation schedules the next rotation of the role, replacing any
+// existing entry. The caller must hold the role's lock.
+func (b *backend) scheduleRotation(name string, role *roleEntry) error {
+	return b.rotationSchedule.Schedule(name, role.NextRotationTime())
+}
+
+// rotateRolePassword sets a new password for the role's service account,
+// keeps the current one as the last one, stores them and schedules the next
+// rotation. The caller must hold the role's write lock.
+func (b *backend) rotateRolePassword(s logical.Storage, name string, role *roleEntry) error {
+	conf, err := b.Config(s)
+	if err != nil {
+		return err
+	}
+	if conf == nil {
+		return fmt.Errorf("the Active Directory connection is not configured")
+	}
+
+	password, err := conf.generatePassword()
+	if err != nil {
+		return err
+	}
+
+	// Write the new password to the WAL before changing it, so that it
+	// can be recovered if we fail to store it below
+	walID, err := framework.PutWAL(s, walRotateRolePassword, &rotateRolePasswordWAL{
+		RoleName:           name,
+		ServiceAccountName: role.ServiceAccountName,
+		OldPassword:        role.CurrentPassword,
+		NewPassword:        password,
+	})
+	if err != nil {
+		return fmt.Errorf("error writing WAL entry: %v", err)
+	}
+
+	// Whether or not the password was changed, the WAL entry is left in
+	// place on failure for the rollback to set the password again
+	if err := b.setRolePassword(s, conf, name, role, password); err != nil {
+		return err
+	}
+
+	// The rotation is complete; if the WAL entry can't be removed the
+	// rollback will find that a newer password is already stored
+	if err := framework.DeleteWAL(s, walID); err != nil {
+		b.logger.Warn("ad: failed to delete WAL entry for password rotation", "role", name, "error", err)
+	}
+
+	return b.scheduleRotation(name, role)
+}
+
+// setRolePassword sets the password of the role's service account, keeps the
+// current one as the last one and stores them. The caller must hold the
+// role's write lock.
+func (b *backend) setRolePassword(s logical.Storage, conf *configEntry, name string, role *roleEntry, password string) error {
+	if err := b.client.UpdatePassword(conf, role.ServiceAccountName, password); err != nil {
+		return err
+	}
+
+	role.LastPassword = role.CurrentPassword
+	role.CurrentPassword = password
+	role.LastVaultRotation = time.Now()
+
+	return b.putRole(s, name, role)
+}
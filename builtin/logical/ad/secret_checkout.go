@@ -0,0 +1,107 @@
+package ad
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const secretCheckOutType = "check_out"
+
+func secretCheckOut(b *backend) *framework.Secret {
+	return &framework.Secret{
+		Type: secretCheckOutType,
+		Fields: map[string]*framework.FieldSchema{
+			"service_account_name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the checked out service account",
+			},
+
+			"password": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Password of the checked out service account",
+			},
+		},
+
+		Renew:  b.secretCheckOutRenew,
+		Revoke: b.secretCheckOutRevoke,
+	}
+}
+
+// leaseCheckOut returns the set and current check-out of the account of the
+// lease, or a nil check-out if the account has been checked in since
+func (b *backend) leaseCheckOut(req *logical.Request) (*libraryEntry, string, *checkOutEntry, error) {
+	serviceAccountName, ok := req.Secret.InternalData["service_account_name"].(string)
+	if !ok {
+		return nil, "", nil, fmt.Errorf("secret is missing service_account_name internal data")
+	}
+	setName, ok := req.Secret.InternalData["set_name"].(string)
+	if !ok {
+		return nil, "", nil, fmt.Errorf("secret is missing set_name internal data")
+	}
+	checkOutID, ok := req.Secret.InternalData["check_out_id"].(string)
+	if !ok {
+		return nil, "", nil, fmt.Errorf("secret is missing check_out_id internal data")
+	}
+
+	set, err := b.Library(req.Storage, setName)
+	if err != nil || set == nil {
+		return nil, serviceAccountName, nil, err
+	}
+
+	checkOut, err := b.CheckOut(req.Storage, serviceAccountName)
+	if err != nil {
+		return nil, serviceAccountName, nil, err
+	}
+	if checkOut == nil || checkOut.IsAvailable || checkOut.CheckOutID != checkOutID {
+		return set, serviceAccountName, nil, nil
+	}
+
+	return set, serviceAccountName, checkOut, nil
+}
+
+func (b *backend) secretCheckOutRenew(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	b.libraryLock.Lock()
+	defer b.libraryLock.Unlock()
+
+	set, serviceAccountName, checkOut, err := b.leaseCheckOut(req)
+	if err != nil {
+		return nil, err
+	}
+	if checkOut == nil {
+		return logical.ErrorResponse(fmt.Sprintf("service account %q has been checked in", serviceAccountName)), nil
+	}
+
+	return framework.LeaseExtend(set.TTL, set.MaxTTL, b.System())(req, d)
+}
+
+func (b *backend) secretCheckOutRevoke(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	b.libraryLock.Lock()
+	defer b.libraryLock.Unlock()
+
+	_, serviceAccountName, checkOut, err := b.leaseCheckOut(req)
+	if err != nil {
+		return nil, err
+	}
+	if checkOut == nil {
+		// Already checked in
+		return nil, nil
+	}
+
+	conf, err := b.Config(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if conf == nil {
+		return nil, fmt.Errorf("the Active Directory connection is not configured")
+	}
+
+	if err := b.checkIn(req.Storage, conf, serviceAccountName); err != nil {
+		return nil, fmt.Errorf("failed to check in service account %q: %v", serviceAccountName, err)
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,260 @@
+package aws
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+const (
+	stubRootUser      = "vault-root"
+	stubRootAccessKey = "AKIAROOTEXAMPLE01"
+)
+
+// awsStub is a local stand-in for the IAM and STS query APIs. It keeps track
+// of the IAM users it is asked to create and records every call. Requests
+// must be signed with an access key of one of the users, and the root user
+// starts with stubRootAccessKey.
+type awsStub struct {
+	sync.Mutex
+	server   *httptest.Server
+	users    map[string]*stubUser
+	calls    map[string][]url.Values
+	keyCount int
+}
+
+type stubUser struct {
+	groups   []string
+	policies []string
+	attached []string
+	keys     []string
+}
+
+func newAWSStub() *awsStub {
+	stub := &awsStub{
+		users: map[string]*stubUser{
+			stubRootUser: &stubUser{keys: []string{stubRootAccessKey}},
+		},
+		calls: make(map[string][]url.Values),
+	}
+	stub.server = httptest.NewServer(http.HandlerFunc(stub.handle))
+	return stub
+}
+
+func (s *awsStub) Close() {
+	s.server.Close()
+}
+
+// Calls returns the parameters of the calls made to the given action
+func (s *awsStub) Calls(action string) []url.Values {
+	s.Lock()
+	defer s.Unlock()
+	return s.calls[action]
+}
+
+// AccessKeys returns the access keys of the IAM user
+func (s *awsStub) AccessKeys(name string) []string {
+	s.Lock()
+	defer s.Unlock()
+	if user, ok := s.users[name]; ok {
+		return user.keys
+	}
+	return nil
+}
+
+// HasUser returns whether the IAM user exists
+func (s *awsStub) HasUser(name string) bool {
+	s.Lock()
+	defer s.Unlock()
+	_, ok := s.users[name]
+	return ok
+}
+
+func (s *awsStub) handle(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	authorization := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, "AWS4-HMAC-SHA256 Credential=") {
+		s.writeError(w, http.StatusForbidden, "MissingAuthenticationToken", "request is not signed")
+		return
+	}
+	accessKey := strings.SplitN(strings.TrimPrefix(authorization, "AWS4-HMAC-SHA256 Credential="), "/", 2)[0]
+
+	s.Lock()
+	defer s.Unlock()
+
+	caller := s.keyOwner(accessKey)
+	if caller == "" {
+		s.writeError(w, http.StatusForbidden, "InvalidClientTokenId", "The security token included in the request is invalid.")
+		return
+	}
+
+	action := r.Form.Get("Action")
+	s.calls[action] = append(s.calls[action], r.Form)
+
+	userName := r.Form.Get("UserName")
+	if userName == "" && action == "GetUser" {
+		userName = caller
+	}
+	user := s.users[userName]
+	switch action {
+	case "CreateUser", "AssumeRole", "GetFederationToken":
+	default:
+		if user == nil {
+			s.writeError(w, http.StatusNotFound, "NoSuchEntity", "The user with name "+userName+" cannot be found.")
+			return
+		}
+	}
+
+	var result string
+	switch action {
+	case "CreateUser":
+		if user != nil {
+			s.writeError(w, http.StatusConflict, "EntityAlreadyExists", "User with name "+userName+" already exists.")
+			return
+		}
+		s.users[userName] = &stubUser{}
+		path := r.Form.Get("Path")
+		if path == "" {
+			path = "/"
+		}
+		result = fmt.Sprintf("<User><UserName>%s</UserName><Path>%s</Path><UserId>AIDA%d</UserId><Arn>arn:aws:iam::123456789012:user%s%s</Arn></User>",
+			userName, path, len(s.users), path, userName)
+	case "GetUser":
+		result = fmt.Sprintf("<User><UserName>%s</UserName><Path>/</Path><UserId>AIDA0</UserId><Arn>arn:aws:iam::123456789012:user/%s</Arn></User>",
+			userName, userName)
+
+	case "AddUserToGroup":
+		user.groups = append(user.groups, r.Form.Get("GroupName"))
+	case "RemoveUserFromGroup":
+		user.groups = remove(user.groups, r.Form.Get("GroupName"))
+	case "PutUserPolicy":
+		user.policies = append(user.policies, r.Form.Get("PolicyName"))
+	case "DeleteUserPolicy":
+		user.policies = remove(user.policies, r.Form.Get("PolicyName"))
+	case "AttachUserPolicy":
+		user.attached = append(user.attached, r.Form.Get("PolicyArn"))
+	case "DetachUserPolicy":
+		user.attached = remove(user.attached, r.Form.Get("PolicyArn"))
+
+	case "CreateAccessKey":
+		s.keyCount++
+		key := fmt.Sprintf("AKIAEXAMPLE%05d", s.keyCount)
+		user.keys = append(user.keys, key)
+		result = fmt.Sprintf("<AccessKey><UserName>%s</UserName><AccessKeyId>%s</AccessKeyId><Status>Active</Status><SecretAccessKey>secret-%s</SecretAccessKey></AccessKey>",
+			userName, key, key)
+	case "DeleteAccessKey":
+		user.keys = remove(user.keys, r.Form.Get("AccessKeyId"))
+
+	case "ListGroupsForUser":
+		result = "<Groups>" + members(user.groups, "<GroupName>%s</GroupName>") + "</Groups><IsTruncated>false</IsTruncated>"
+	case "ListUserPolicies":
+		result = "<PolicyNames>" + members(user.policies, "%s") + "</PolicyNames><IsTruncated>false</IsTruncated>"
+	case "ListAttachedUserPolicies":
+		result = "<AttachedPolicies>" + members(user.attached, "<PolicyArn>%s</PolicyArn>") + "</AttachedPolicies><IsTruncated>false</IsTruncated>"
+	case "ListAccessKeys":
+		result = "<AccessKeyMetadata>" + members(user.keys, "<AccessKeyId>%s</AccessKeyId>") + "</AccessKeyMetadata><IsTruncated>false</IsTruncated>"
+
+	case "DeleteUser":
+		if len(user.groups)+len(user.policies)+len(user.attached)+len(user.keys) > 0 {
+			s.writeError(w, http.StatusConflict, "DeleteConflict", "Cannot delete entity, must remove referenced objects first.")
+			return
+		}
+		delete(s.users, userName)
+
+	case "AssumeRole", "GetFederationToken":
+		duration, _ := strconv.Atoi(r.Form.Get("DurationSeconds"))
+		expiration := time.Now().UTC().Add(time.Duration(duration) * time.Second)
+		result = fmt.Sprintf("<Credentials><AccessKeyId>ASIA%s</AccessKeyId><SecretAccessKey>secret</SecretAccessKey><SessionToken>token</SessionToken><Expiration>%s</Expiration></Credentials>",
+			action, expiration.Format("2006-01-02T15:04:05Z"))
+
+	default:
+		s.writeError(w, http.StatusBadRequest, "InvalidAction", "unknown action "+action)
+		return
+	}
+
+	fmt.Fprintf(w, "<%sResponse><%sResult>%s</%sResult><ResponseMetadata><RequestId>1</RequestId></ResponseMetadata></%sResponse>",
+		action, action, result, action, action)
+}
+
+// keyOwner returns the name of the IAM user with the access key, if any
+func (s *awsStub) keyOwner(accessKey string) string {
+	for name, user := range s.users {
+		for _, key := range user.keys {
+			if key == accessKey {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+func (s *awsStub) writeError(w http.ResponseWriter, status int, code, message string) {
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<ErrorResponse><Error><Type>Sender</Type><Code>%s</Code><Message>%s</Message></Error><RequestId>1</RequestId></ErrorResponse>",
+		code, message)
+}
+
+func members(values []string, format string) string {
+	var result string
+	for _, value := range values {
+		result += "<member>" + fmt.Sprintf(format, value) + "</member>"
+	}
+	return result
+}
+
+func remove(values []string, value string) []string {
+	var result []string
+	for _, v := range values {
+		if v != value {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// testBackendWithStub returns a backend configured to use the stub, its
+// storage, and a function handling requests with the backend
+func testBackendWithStub(t *testing.T, stub *awsStub) (*backend, logical.Storage, func(logical.Operation, string, map[string]interface{}) *logical.Response) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	b := Backend()
+	if err := b.Setup(config); err != nil {
+		t.Fatal(err)
+	}
+
+	handle := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		resp, err := b.HandleRequest(&logical.Request{
+			Operation:   op,
+			Path:        path,
+			Storage:     config.StorageView,
+			Data:        data,
+			DisplayName: "token-test",
+		})
+		if err != nil {
+			t.Fatalf("%s %s: err: %s", op, path, err)
+		}
+		return resp
+	}
+
+	handle(logical.UpdateOperation, "config/root", map[string]interface{}{
+		"access_key":   stubRootAccessKey,
+		"secret_key":   "root-secret",
+		"region":       "us-east-1",
+		"iam_endpoint": stub.server.URL,
+		"sts_endpoint": stub.server.URL,
+	})
+
+	return b, config.StorageView, handle
+}
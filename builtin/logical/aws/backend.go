@@ -2,6 +2,7 @@ package aws
 
 import (
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/hashicorp/vault/logical"
@@ -28,9 +29,10 @@ func Backend() *backend {
 		},
 
 		Paths: []*framework.Path{
-			pathConfigRoot(),
+			pathConfigRoot(&b),
+			pathConfigRotateRoot(&b),
 			pathConfigLease(&b),
-			pathRoles(),
+			pathRoles(&b),
 			pathListRoles(&b),
 			pathUser(&b),
 			pathSTS(&b),
@@ -50,6 +52,9 @@ func Backend() *backend {
 
 type backend struct {
 	*framework.Backend
+
+	// rootLock serializes the changes to the root credentials
+	rootLock sync.Mutex
 }
 
 const backendHelp = `
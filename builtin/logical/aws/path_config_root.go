@@ -1,11 +1,13 @@
 package aws
 
 import (
+	"fmt"
+
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 )
 
-func pathConfigRoot() *framework.Path {
+func pathConfigRoot(b *backend) *framework.Path {
 	return &framework.Path{
 		Pattern: "config/root",
 		Fields: map[string]*framework.FieldSchema{
@@ -23,10 +25,20 @@ func pathConfigRoot() *framework.Path {
 				Type:        framework.TypeString,
 				Description: "Region for API calls.",
 			},
+
+			"iam_endpoint": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Endpoint to use for IAM API calls, instead of the default for the region.",
+			},
+
+			"sts_endpoint": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Endpoint to use for STS API calls, instead of the default for the region.",
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
-			logical.UpdateOperation: pathConfigRootWrite,
+			logical.UpdateOperation: b.pathConfigRootWrite,
 		},
 
 		HelpSynopsis:    pathConfigRootHelpSyn,
@@ -34,14 +46,38 @@ func pathConfigRoot() *framework.Path {
 	}
 }
 
-func pathConfigRootWrite(
+// readConfigRoot returns the root configuration, or nil if it isn't set
+func readConfigRoot(s logical.Storage) (*rootConfig, error) {
+	entry, err := s.Get("config/root")
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var config rootConfig
+	if err := entry.DecodeJSON(&config); err != nil {
+		return nil, fmt.Errorf("error reading root configuration: %s", err)
+	}
+
+	return &config, nil
+}
+
+func (b *backend) pathConfigRootWrite(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.rootLock.Lock()
+	defer b.rootLock.Unlock()
+
 	region := data.Get("region").(string)
 
 	entry, err := logical.StorageEntryJSON("config/root", rootConfig{
 		AccessKey: data.Get("access_key").(string),
 		SecretKey: data.Get("secret_key").(string),
 		Region:    region,
+
+		IAMEndpoint: data.Get("iam_endpoint").(string),
+		STSEndpoint: data.Get("sts_endpoint").(string),
 	})
 	if err != nil {
 		return nil, err
@@ -58,6 +94,9 @@ type rootConfig struct {
 	AccessKey string `json:"access_key"`
 	SecretKey string `json:"secret_key"`
 	Region    string `json:"region"`
+
+	IAMEndpoint string `json:"iam_endpoint"`
+	STSEndpoint string `json:"sts_endpoint"`
 }
 
 const pathConfigRootHelpSyn = `
@@ -0,0 +1,105 @@
+package aws
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathConfigRotateRoot(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/rotate-root",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathConfigRotateRootUpdate,
+		},
+
+		HelpSynopsis:    pathConfigRotateRootHelpSyn,
+		HelpDescription: pathConfigRotateRootHelpDesc,
+	}
+}
+
+func (b *backend) pathConfigRotateRootUpdate(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.rootLock.Lock()
+	defer b.rootLock.Unlock()
+
+	config, err := readConfigRoot(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil || config.AccessKey == "" || config.SecretKey == "" {
+		return logical.ErrorResponse("Cannot rotate the root credentials: no access key and secret key are configured"), nil
+	}
+
+	client, err := clientIAM(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	// The keys belong to the user making the requests
+	userResp, err := client.GetUser(&iam.GetUserInput{})
+	if err != nil {
+		return logical.ErrorResponse(fmt.Sprintf(
+			"Error getting the root IAM user: %s", err)), nil
+	}
+	if userResp.User == nil || userResp.User.UserName == nil {
+		return nil, fmt.Errorf("AWS returned no user for the root credentials")
+	}
+	username := *userResp.User.UserName
+
+	keyResp, err := client.CreateAccessKey(&iam.CreateAccessKeyInput{
+		UserName: aws.String(username),
+	})
+	if err != nil {
+		return logical.ErrorResponse(fmt.Sprintf(
+			"Error creating access keys: %s", err)), nil
+	}
+
+	oldAccessKey := config.AccessKey
+	config.AccessKey = *keyResp.AccessKey.AccessKeyId
+	config.SecretKey = *keyResp.AccessKey.SecretAccessKey
+
+	entry, err := logical.StorageEntryJSON("config/root", config)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(entry); err != nil {
+		return nil, fmt.Errorf("error storing the new root credentials, access key %s must be deleted: %s",
+			config.AccessKey, err)
+	}
+
+	// The new key can take a few seconds to be usable, so the old one is
+	// used to delete itself
+	_, err = client.DeleteAccessKey(&iam.DeleteAccessKeyInput{
+		AccessKeyId: aws.String(oldAccessKey),
+		UserName:    aws.String(username),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error deleting the old access key %s, which must be deleted: %s",
+			oldAccessKey, err)
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"access_key": config.AccessKey,
+		},
+	}, nil
+}
+
+const pathConfigRotateRootHelpSyn = `
+Request to rotate the root credentials.
+`
+
+const pathConfigRotateRootHelpDesc = `
+This path attempts to rotate the root credentials configured at "config/root".
+A new access key is created for the IAM user the credentials belong to, and
+replaces the configured one, which is then deleted. Only the new access key ID
+is returned; the secret key is only known to Vault.
+
+IAM users can have at most two access keys, so the user must have no other
+access key than the configured one.
+`
@@ -0,0 +1,77 @@
+package aws
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestBackend_rotateRoot(t *testing.T) {
+	stub := newAWSStub()
+	defer stub.Close()
+
+	_, storage, handle := testBackendWithStub(t, stub)
+
+	resp := handle(logical.UpdateOperation, "config/rotate-root", nil)
+	if resp == nil || resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+	accessKey := resp.Data["access_key"].(string)
+	if _, ok := resp.Data["secret_key"]; ok {
+		t.Fatalf("the secret key should not be returned: %#v", resp.Data)
+	}
+
+	// The old key is deleted, and the new one is stored
+	if keys := stub.AccessKeys(stubRootUser); !reflect.DeepEqual(keys, []string{accessKey}) {
+		t.Fatalf("bad: root user keys: %v, expected %s", keys, accessKey)
+	}
+	config, err := readConfigRoot(storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.AccessKey != accessKey || config.SecretKey != "secret-"+accessKey || config.Region != "us-east-1" {
+		t.Fatalf("bad: %#v", config)
+	}
+
+	// The new credentials are used from then on
+	resp = handle(logical.UpdateOperation, "roles/user", map[string]interface{}{
+		"credential_type": "iam_user",
+		"policy_arns":     "arn:aws:iam::aws:policy/ReadOnlyAccess",
+	})
+	if resp != nil && resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+	resp = handle(logical.ReadOperation, "creds/user", nil)
+	if resp == nil || resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	resp = handle(logical.UpdateOperation, "config/rotate-root", nil)
+	if resp == nil || resp.IsError() || resp.Data["access_key"] == accessKey {
+		t.Fatalf("bad: %#v", resp)
+	}
+}
+
+func TestBackend_rotateRootWithoutKeys(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	b := Backend()
+	if err := b.Setup(config); err != nil {
+		t.Fatal(err)
+	}
+
+	// Credentials from the environment can't be rotated
+	resp, err := b.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/rotate-root",
+		Storage:   config.StorageView,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error, got: %#v", resp)
+	}
+}
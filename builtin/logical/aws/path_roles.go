@@ -4,14 +4,24 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-
-	"errors"
+	"regexp"
 	"strings"
+	"time"
 
+	"github.com/hashicorp/vault/helper/strutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 )
 
+// iamPathRegex matches the paths IAM accepts
+var iamPathRegex = regexp.MustCompile(`^/([\x21-\x7e]{0,510}/)?$`)
+
+const (
+	iamUserCred         = "iam_user"
+	assumedRoleCred     = "assumed_role"
+	federationTokenCred = "federation_token"
+)
+
 func pathListRoles(b *backend) *framework.Path {
 	return &framework.Path{
 		Pattern: "roles/?$",
@@ -25,7 +35,7 @@ func pathListRoles(b *backend) *framework.Path {
 	}
 }
 
-func pathRoles() *framework.Path {
+func pathRoles(b *backend) *framework.Path {
 	return &framework.Path{
 		Pattern: "roles/" + framework.GenericNameRegex("name"),
 		Fields: map[string]*framework.FieldSchema{
@@ -34,21 +44,74 @@ func pathRoles() *framework.Path {
 				Description: "Name of the policy",
 			},
 
+			"credential_type": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Type of credentials generated for the role:
+"iam_user", "assumed_role" or "federation_token".`,
+			},
+
+			"policy_document": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `IAM policy document. It is the inline policy of
+IAM users, and limits the permissions of assumed roles and federation tokens.`,
+			},
+
+			"policy_arns": &framework.FieldSchema{
+				Type: framework.TypeCommaStringSlice,
+				Description: `ARNs of managed policies. They are attached to IAM
+users, and limit the permissions of assumed roles and federation tokens.`,
+			},
+
+			"role_arns": &framework.FieldSchema{
+				Type:        framework.TypeCommaStringSlice,
+				Description: "ARNs of the roles that can be assumed, for the assumed_role credential type.",
+			},
+
+			"permissions_boundary_arn": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "ARN of the managed policy set as the permissions boundary of IAM users.",
+			},
+
+			"iam_groups": &framework.FieldSchema{
+				Type:        framework.TypeCommaStringSlice,
+				Description: "Names of the IAM groups IAM users are added to.",
+			},
+
+			"iam_path": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Path of the IAM users. Defaults to \"/\".",
+			},
+
+			"iam_tags": &framework.FieldSchema{
+				Type:        framework.TypeMap,
+				Description: "Tags of the IAM users, as a map of tag keys to values.",
+			},
+
+			"default_sts_ttl": &framework.FieldSchema{
+				Type:        framework.TypeDurationSecond,
+				Description: "Default lifetime of assumed role and federation token credentials.",
+			},
+
+			"max_sts_ttl": &framework.FieldSchema{
+				Type:        framework.TypeDurationSecond,
+				Description: "Maximum lifetime of assumed role and federation token credentials.",
+			},
+
 			"arn": &framework.FieldSchema{
 				Type:        framework.TypeString,
-				Description: "ARN Reference to a managed policy",
+				Description: "Deprecated; use role_arns or policy_arns instead. ARN Reference to a managed policy or a role",
 			},
 
 			"policy": &framework.FieldSchema{
 				Type:        framework.TypeString,
-				Description: "IAM policy document",
+				Description: "Deprecated; use policy_document instead. IAM policy document",
 			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
-			logical.DeleteOperation: pathRolesDelete,
-			logical.ReadOperation:   pathRolesRead,
-			logical.UpdateOperation: pathRolesWrite,
+			logical.DeleteOperation: b.pathRolesDelete,
+			logical.ReadOperation:   b.pathRolesRead,
+			logical.UpdateOperation: b.pathRolesWrite,
 		},
 
 		HelpSynopsis:    pathRolesHelpSyn,
@@ -56,96 +119,273 @@ func pathRoles() *framework.Path {
 	}
 }
 
+type awsRoleEntry struct {
+	CredentialTypes        []string          `json:"credential_types"`
+	PolicyDocument         string            `json:"policy_document"`
+	PolicyArns             []string          `json:"policy_arns"`
+	RoleArns               []string          `json:"role_arns"`
+	PermissionsBoundaryArn string            `json:"permissions_boundary_arn"`
+	IAMGroups              []string          `json:"iam_groups"`
+	IAMPath                string            `json:"iam_path"`
+	IAMTags                map[string]string `json:"iam_tags"`
+	DefaultSTSTTL          time.Duration     `json:"default_sts_ttl"`
+	MaxSTSTTL              time.Duration     `json:"max_sts_ttl"`
+}
+
+// hasCredentialType returns whether the role generates credentials of the
+// given type
+func (r *awsRoleEntry) hasCredentialType(credentialType string) bool {
+	return strutil.StrListContains(r.CredentialTypes, credentialType)
+}
+
+// validate checks that the role has the settings its credential types
+// require, and none that they don't use
+func (r *awsRoleEntry) validate() error {
+	if len(r.CredentialTypes) == 0 {
+		return fmt.Errorf("credential_type is required")
+	}
+
+	for _, credentialType := range r.CredentialTypes {
+		switch credentialType {
+		case iamUserCred:
+			if r.PolicyDocument == "" && len(r.PolicyArns) == 0 && len(r.IAMGroups) == 0 {
+				return fmt.Errorf("policy_document, policy_arns or iam_groups is required for the %s credential type", credentialType)
+			}
+		case assumedRoleCred:
+			if len(r.RoleArns) == 0 {
+				return fmt.Errorf("role_arns is required for the %s credential type", credentialType)
+			}
+		case federationTokenCred:
+			if r.PolicyDocument == "" && len(r.PolicyArns) == 0 {
+				return fmt.Errorf("policy_document or policy_arns is required for the %s credential type", credentialType)
+			}
+		default:
+			return fmt.Errorf("unknown credential_type: %s", credentialType)
+		}
+	}
+
+	if len(r.RoleArns) > 0 && !r.hasCredentialType(assumedRoleCred) {
+		return fmt.Errorf("role_arns is only valid for the %s credential type", assumedRoleCred)
+	}
+	if (r.PermissionsBoundaryArn != "" || len(r.IAMGroups) > 0 || r.IAMPath != "" || len(r.IAMTags) > 0) && !r.hasCredentialType(iamUserCred) {
+		return fmt.Errorf("permissions_boundary_arn, iam_groups, iam_path and iam_tags are only valid for the %s credential type", iamUserCred)
+	}
+	if r.IAMPath != "" && !iamPathRegex.MatchString(r.IAMPath) {
+		return fmt.Errorf("iam_path must begin and end with a slash, and be at most 512 characters")
+	}
+	if len(r.IAMTags) > 50 {
+		return fmt.Errorf("iam_tags can't have more than 50 tags")
+	}
+	for key, value := range r.IAMTags {
+		if key == "" || len(key) > 128 || len(value) > 256 {
+			return fmt.Errorf("iam_tags keys must be 1 to 128 characters, and values at most 256 characters")
+		}
+	}
+	if (r.DefaultSTSTTL > 0 || r.MaxSTSTTL > 0) && !r.hasCredentialType(assumedRoleCred) && !r.hasCredentialType(federationTokenCred) {
+		return fmt.Errorf("default_sts_ttl and max_sts_ttl are only valid for the %s and %s credential types", assumedRoleCred, federationTokenCred)
+	}
+	if r.MaxSTSTTL > 0 && r.DefaultSTSTTL > r.MaxSTSTTL {
+		return fmt.Errorf("default_sts_ttl must not be greater than max_sts_ttl")
+	}
+
+	return nil
+}
+
+// upgradeLegacyRole converts a role written before credential types existed,
+// which was stored as either an inline policy or an ARN, keeping the
+// credentials it could generate
+func upgradeLegacyRole(value string) *awsRoleEntry {
+	switch {
+	case strings.HasPrefix(value, "arn:") && strings.Contains(value, ":role/"):
+		return &awsRoleEntry{
+			CredentialTypes: []string{assumedRoleCred},
+			RoleArns:        []string{value},
+		}
+	case strings.HasPrefix(value, "arn:"):
+		return &awsRoleEntry{
+			CredentialTypes: []string{iamUserCred},
+			PolicyArns:      []string{value},
+		}
+	default:
+		return &awsRoleEntry{
+			CredentialTypes: []string{iamUserCred, federationTokenCred},
+			PolicyDocument:  value,
+		}
+	}
+}
+
+// roleRead returns the named role, or nil if it doesn't exist. Legacy roles
+// are upgraded, and are replaced in storage when the role is next written.
+func (b *backend) roleRead(s logical.Storage, name string) (*awsRoleEntry, error) {
+	entry, err := s.Get("role/" + name)
+	if err != nil {
+		return nil, err
+	}
+	if entry != nil {
+		var role awsRoleEntry
+		if err := entry.DecodeJSON(&role); err != nil {
+			return nil, err
+		}
+		return &role, nil
+	}
+
+	entry, err = s.Get("policy/" + name)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	return upgradeLegacyRole(string(entry.Value)), nil
+}
+
 func (b *backend) pathRoleList(
 	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
-	entries, err := req.Storage.List("policy/")
+	entries, err := req.Storage.List("role/")
 	if err != nil {
 		return nil, err
 	}
-	return logical.ListResponse(entries), nil
+
+	legacyEntries, err := req.Storage.List("policy/")
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(strutil.RemoveDuplicates(append(entries, legacyEntries...), false)), nil
 }
 
-func pathRolesDelete(
+func (b *backend) pathRolesDelete(
 	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
-	err := req.Storage.Delete("policy/" + d.Get("name").(string))
-	if err != nil {
+	name := d.Get("name").(string)
+
+	if err := req.Storage.Delete("role/" + name); err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Delete("policy/" + name); err != nil {
 		return nil, err
 	}
 
 	return nil, nil
 }
 
-func pathRolesRead(
+func (b *backend) pathRolesRead(
 	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
-	entry, err := req.Storage.Get("policy/" + d.Get("name").(string))
+	role, err := b.roleRead(req.Storage, d.Get("name").(string))
 	if err != nil {
 		return nil, err
 	}
-	if entry == nil {
+	if role == nil {
 		return nil, nil
 	}
 
-	val := string(entry.Value)
-	if strings.HasPrefix(val, "arn:") {
-		return &logical.Response{
-			Data: map[string]interface{}{
-				"arn": val,
-			},
-		}, nil
-	}
-	return &logical.Response{
+	resp := &logical.Response{
 		Data: map[string]interface{}{
-			"policy": val,
+			"credential_types":         role.CredentialTypes,
+			"policy_document":          role.PolicyDocument,
+			"policy_arns":              role.PolicyArns,
+			"role_arns":                role.RoleArns,
+			"permissions_boundary_arn": role.PermissionsBoundaryArn,
+			"iam_groups":               role.IAMGroups,
+			"iam_path":                 role.IAMPath,
+			"iam_tags":                 role.IAMTags,
+			"default_sts_ttl":          int64(role.DefaultSTSTTL.Seconds()),
+			"max_sts_ttl":              int64(role.MaxSTSTTL.Seconds()),
 		},
-	}, nil
-}
-
-func useInlinePolicy(d *framework.FieldData) (bool, error) {
-	bp := d.Get("policy").(string) != ""
-	ba := d.Get("arn").(string) != ""
-
-	if !bp && !ba {
-		return false, errors.New("Either policy or arn must be provided")
 	}
-	if bp && ba {
-		return false, errors.New("Only one of policy or arn should be provided")
+
+	// Roles that could have been written with the legacy parameters are
+	// also returned with them, for existing clients
+	switch {
+	case role.PolicyDocument != "":
+		resp.Data["policy"] = role.PolicyDocument
+	case len(role.PolicyArns) == 1 && len(role.RoleArns) == 0:
+		resp.Data["arn"] = role.PolicyArns[0]
+	case len(role.RoleArns) == 1 && len(role.PolicyArns) == 0:
+		resp.Data["arn"] = role.RoleArns[0]
 	}
-	return bp, nil
+
+	return resp, nil
 }
 
-func pathRolesWrite(
+func (b *backend) pathRolesWrite(
 	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
-	var buf bytes.Buffer
+	name := d.Get("name").(string)
 
-	uip, err := useInlinePolicy(d)
-	if err != nil {
-		return nil, err
+	role := &awsRoleEntry{
+		PolicyArns:             d.Get("policy_arns").([]string),
+		RoleArns:               d.Get("role_arns").([]string),
+		PermissionsBoundaryArn: d.Get("permissions_boundary_arn").(string),
+		IAMGroups:              d.Get("iam_groups").([]string),
+		IAMPath:                d.Get("iam_path").(string),
+		DefaultSTSTTL:          time.Duration(d.Get("default_sts_ttl").(int)) * time.Second,
+		MaxSTSTTL:              time.Duration(d.Get("max_sts_ttl").(int)) * time.Second,
 	}
 
-	if uip {
-		if err := json.Compact(&buf, []byte(d.Get("policy").(string))); err != nil {
-			return logical.ErrorResponse(fmt.Sprintf(
-				"Error compacting policy: %s", err)), nil
+	if tags := d.Get("iam_tags").(map[string]interface{}); len(tags) > 0 {
+		role.IAMTags = make(map[string]string, len(tags))
+		for key, value := range tags {
+			tag, ok := value.(string)
+			if !ok {
+				return logical.ErrorResponse(fmt.Sprintf("iam_tags value of %q must be a string", key)), nil
+			}
+			role.IAMTags[key] = tag
 		}
-		// Write the policy into storage
-		err := req.Storage.Put(&logical.StorageEntry{
-			Key:   "policy/" + d.Get("name").(string),
-			Value: buf.Bytes(),
-		})
-		if err != nil {
-			return nil, err
+	}
+
+	policyDocument := d.Get("policy_document").(string)
+	legacyPolicy := d.Get("policy").(string)
+	legacyArn := d.Get("arn").(string)
+
+	if credentialType := d.Get("credential_type").(string); credentialType != "" {
+		if legacyPolicy != "" || legacyArn != "" {
+			return logical.ErrorResponse("policy and arn can't be used with credential_type; use policy_document, policy_arns or role_arns instead"), nil
 		}
+		role.CredentialTypes = []string{credentialType}
 	} else {
-		// Write the arn ref into storage
-		err := req.Storage.Put(&logical.StorageEntry{
-			Key:   "policy/" + d.Get("name").(string),
-			Value: []byte(d.Get("arn").(string)),
-		})
-		if err != nil {
-			return nil, err
+		// Roles written with the legacy parameters generate the same
+		// credentials as before
+		switch {
+		case legacyPolicy != "" && legacyArn != "":
+			return logical.ErrorResponse("Only one of policy or arn should be provided"), nil
+		case legacyPolicy != "":
+			role.CredentialTypes = upgradeLegacyRole(legacyPolicy).CredentialTypes
+			policyDocument = legacyPolicy
+		case legacyArn != "" && strings.Contains(legacyArn, ":role/"):
+			role.CredentialTypes = []string{assumedRoleCred}
+			role.RoleArns = append(role.RoleArns, legacyArn)
+		case legacyArn != "":
+			role.CredentialTypes = []string{iamUserCred}
+			role.PolicyArns = append(role.PolicyArns, legacyArn)
 		}
 	}
 
+	if policyDocument != "" {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, []byte(policyDocument)); err != nil {
+			return logical.ErrorResponse(fmt.Sprintf(
+				"Error compacting policy: %s", err)), nil
+		}
+		role.PolicyDocument = buf.String()
+	}
+
+	if err := role.validate(); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	entry, err := logical.StorageEntryJSON("role/"+name, role)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(entry); err != nil {
+		return nil, err
+	}
+
+	// The role replaces the legacy entry, if any
+	if err := req.Storage.Delete("policy/" + name); err != nil {
+		return nil, err
+	}
+
 	return nil, nil
 }
 
@@ -159,16 +399,34 @@ Read, write and reference IAM policies that access keys can be made for.
 
 const pathRolesHelpDesc = `
 This path allows you to read and write roles that are used to
-create access keys. These roles are associated with IAM policies that
-map directly to the route to read the access keys. For example, if the
-backend is mounted at "aws" and you create a role at "aws/roles/deploy"
-then a user could request access credentials at "aws/creds/deploy".
-
-You can either supply a user inline policy (via the policy argument), or
-provide a reference to an existing AWS policy by supplying the full arn
-reference (via the arn argument). Inline user policies written are normal
-IAM policies. Vault will not attempt to parse these except to validate
-that they're basic JSON. No validation is performed on arn references.
+create access keys. For example, if the backend is mounted at "aws" and
+you create a role at "aws/roles/deploy" then a user could request access
+credentials at "aws/creds/deploy".
+
+The "credential_type" of a role sets the kind of credentials it generates:
+
+  * "iam_user" creates an IAM user with the policy document as its inline
+    policy and the managed policies attached, optionally in IAM groups, with a
+    permissions boundary, at an IAM path and with IAM tags. The access keys
+    are leased according to the "config/lease" settings.
+
+  * "assumed_role" assumes one of the "role_arns". If more than one role can
+    be assumed, the "role_arn" must be given when requesting credentials.
+
+  * "federation_token" gets a federation token whose permissions are those of
+    the policy document and managed policies.
+
+For the last two, the policy document and managed policies limit the
+permissions of the credentials, which are valid for "default_sts_ttl" unless
+another TTL, up to "max_sts_ttl", is requested.
+
+Vault will not attempt to parse policy documents except to validate that
+they're basic JSON. No validation is performed on ARNs.
+
+The "policy" and "arn" parameters are deprecated. A role written with an
+inline "policy" generates both "iam_user" credentials and federation tokens,
+and one written with an "arn" assumes the role or attaches the managed policy
+it references.
 
 To validate the keys, attempt to read an access key after writing the policy.
 `
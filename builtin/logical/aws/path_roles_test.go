@@ -62,3 +62,91 @@ func TestBackend_PathListRoles(t *testing.T) {
 		t.Fatalf("failed to list all 10 roles")
 	}
 }
+
+func TestBackend_roleValidation(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	b := Backend()
+	if err := b.Setup(config); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]map[string]interface{}{
+		"no credential type": {
+			"policy_arns": "arn:aws:iam::aws:policy/one",
+		},
+		"unknown credential type": {
+			"credential_type": "root",
+			"policy_arns":     "arn:aws:iam::aws:policy/one",
+		},
+		"iam_user without policies": {
+			"credential_type": "iam_user",
+		},
+		"assumed_role without role_arns": {
+			"credential_type": "assumed_role",
+		},
+		"federation_token without policies": {
+			"credential_type": "federation_token",
+		},
+		"role_arns for iam_user": {
+			"credential_type": "iam_user",
+			"policy_arns":     "arn:aws:iam::aws:policy/one",
+			"role_arns":       "arn:aws:iam::123456789012:role/one",
+		},
+		"iam_groups for federation_token": {
+			"credential_type": "federation_token",
+			"policy_arns":     "arn:aws:iam::aws:policy/one",
+			"iam_groups":      "developers",
+		},
+		"iam_path for assumed_role": {
+			"credential_type": "assumed_role",
+			"role_arns":       "arn:aws:iam::123456789012:role/one",
+			"iam_path":        "/vault/",
+		},
+		"iam_path without trailing slash": {
+			"credential_type": "iam_user",
+			"policy_arns":     "arn:aws:iam::aws:policy/one",
+			"iam_path":        "/vault",
+		},
+		"iam_tags with a non-string value": {
+			"credential_type": "iam_user",
+			"policy_arns":     "arn:aws:iam::aws:policy/one",
+			"iam_tags":        map[string]interface{}{"count": 1},
+		},
+		"sts ttl for iam_user": {
+			"credential_type": "iam_user",
+			"policy_arns":     "arn:aws:iam::aws:policy/one",
+			"default_sts_ttl": 900,
+		},
+		"default ttl over max": {
+			"credential_type": "assumed_role",
+			"role_arns":       "arn:aws:iam::123456789012:role/one",
+			"default_sts_ttl": 3600,
+			"max_sts_ttl":     900,
+		},
+		"legacy and new parameters": {
+			"credential_type": "iam_user",
+			"policy":          testPolicy,
+		},
+		"invalid policy document": {
+			"credential_type": "iam_user",
+			"policy_document": "{",
+		},
+	}
+
+	for name, data := range cases {
+		resp, err := b.HandleRequest(&logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "roles/test",
+			Storage:   config.StorageView,
+			Data:      data,
+		})
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if resp == nil || !resp.IsError() {
+			t.Fatalf("%s: expected error, got: %#v", name, resp)
+		}
+	}
+}
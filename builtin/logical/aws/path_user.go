@@ -5,6 +5,7 @@ import (
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/hashicorp/vault/helper/strutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 	"github.com/mitchellh/mapstructure"
@@ -18,10 +19,19 @@ func pathUser(b *backend) *framework.Path {
 				Type:        framework.TypeString,
 				Description: "Name of the role",
 			},
+			"role_arn": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "ARN of the role to assume, if the role can assume more than one",
+			},
+			"ttl": &framework.FieldSchema{
+				Type:        framework.TypeDurationSecond,
+				Description: "Lifetime of assumed role and federation token credentials",
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
-			logical.ReadOperation: b.pathUserRead,
+			logical.ReadOperation:   b.pathCredsRead(false),
+			logical.UpdateOperation: b.pathCredsRead(false),
 		},
 
 		HelpSynopsis:    pathUserHelpSyn,
@@ -29,23 +39,75 @@ func pathUser(b *backend) *framework.Path {
 	}
 }
 
-func (b *backend) pathUserRead(
-	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
-	policyName := d.Get("name").(string)
+// pathCredsRead returns the handler generating credentials of the role's type.
+// The "sts/" path only generates STS credentials.
+func (b *backend) pathCredsRead(sts bool) framework.OperationFunc {
+	return func(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		roleName := d.Get("name").(string)
 
-	// Read the policy
-	policy, err := req.Storage.Get("policy/" + policyName)
-	if err != nil {
-		return nil, fmt.Errorf("error retrieving role: %s", err)
-	}
-	if policy == nil {
-		return logical.ErrorResponse(fmt.Sprintf(
-			"Role '%s' not found", policyName)), nil
-	}
+		// Read the role
+		role, err := b.roleRead(req.Storage, roleName)
+		if err != nil {
+			return nil, fmt.Errorf("error retrieving role: %s", err)
+		}
+		if role == nil {
+			return logical.ErrorResponse(fmt.Sprintf(
+				"Role '%s' not found", roleName)), nil
+		}
 
-	// Use the helper to create the secret
-	return b.secretAccessKeysCreate(
-		req.Storage, req.DisplayName, policyName, string(policy.Value))
+		// Only legacy roles have several types, as their inline policy can be
+		// used for both IAM users and federation tokens
+		credentialType := role.CredentialTypes[0]
+		if sts && role.hasCredentialType(federationTokenCred) {
+			credentialType = federationTokenCred
+		}
+		if sts && credentialType == iamUserCred {
+			if role.PolicyDocument == "" {
+				return logical.ErrorResponse(
+						"Can't generate STS credentials for a managed policy; use a role to assume or an inline policy instead"),
+					logical.ErrInvalidRequest
+			}
+			return logical.ErrorResponse(
+					"Can't generate STS credentials for an iam_user role; use a role to assume or a federation token instead"),
+				logical.ErrInvalidRequest
+		}
+
+		var ttl int64
+		if credentialType != iamUserCred {
+			ttl = int64(d.Get("ttl").(int))
+			if ttl == 0 {
+				ttl = int64(role.DefaultSTSTTL.Seconds())
+			}
+			if ttl == 0 {
+				ttl = defaultSTSTTL
+			}
+			if role.MaxSTSTTL > 0 && ttl > int64(role.MaxSTSTTL.Seconds()) {
+				return logical.ErrorResponse(fmt.Sprintf(
+					"ttl of %d seconds exceeds the role's max_sts_ttl of %d seconds", ttl, int64(role.MaxSTSTTL.Seconds()))), nil
+			}
+		}
+
+		switch credentialType {
+		case iamUserCred:
+			return b.secretAccessKeysCreate(req.Storage, req.DisplayName, roleName, role)
+
+		case assumedRoleCred:
+			roleArn := d.Get("role_arn").(string)
+			switch {
+			case roleArn == "" && len(role.RoleArns) == 1:
+				roleArn = role.RoleArns[0]
+			case roleArn == "":
+				return logical.ErrorResponse("role_arn is required as the role can assume more than one role"), nil
+			case !strutil.StrListContains(role.RoleArns, roleArn):
+				return logical.ErrorResponse(fmt.Sprintf(
+					"role_arn %q is not one of the role's role_arns", roleArn)), nil
+			}
+			return b.assumeRole(req.Storage, req.DisplayName, roleName, roleArn, role, ttl)
+
+		default:
+			return b.secretTokenCreate(req.Storage, req.DisplayName, roleName, role, ttl)
+		}
+	}
 }
 
 func pathUserRollback(req *logical.Request, _kind string, data interface{}) error {
@@ -169,6 +231,10 @@ accessing AWS. The IAM policy used to back this key pair will be
 the "name" parameter. For example, if this backend is mounted at "aws",
 then "aws/creds/deploy" would generate access keys for the "deploy" role.
 
+The credentials are of the role's "credential_type". For assumed roles and
+federation tokens, a "ttl" can be given, and for roles that can assume more
+than one role, the "role_arn" to assume.
+
 The access keys will have a lease associated with them. The access keys
 can be revoked by using the lease ID.
 `
@@ -0,0 +1,226 @@
+package aws
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestBackend_credentialTypes(t *testing.T) {
+	stub := newAWSStub()
+	defer stub.Close()
+
+	b, storage, handle := testBackendWithStub(t, stub)
+
+	expectError := func(resp *logical.Response) {
+		if resp == nil || !resp.IsError() {
+			t.Fatalf("expected error, got: %#v", resp)
+		}
+	}
+	revoke := func(resp *logical.Response) {
+		_, err := b.HandleRequest(&logical.Request{
+			Operation: logical.RevokeOperation,
+			Storage:   storage,
+			Secret:    resp.Secret,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// IAM users get the role's policies, groups, permissions boundary, path
+	// and tags
+	resp := handle(logical.UpdateOperation, "roles/user", map[string]interface{}{
+		"credential_type":          "iam_user",
+		"policy_document":          testPolicy,
+		"policy_arns":              "arn:aws:iam::aws:policy/one,arn:aws:iam::aws:policy/two",
+		"iam_groups":               "developers",
+		"permissions_boundary_arn": "arn:aws:iam::123456789012:policy/boundary",
+		"iam_path":                 "/vault/",
+		"iam_tags":                 map[string]interface{}{"team": "payments"},
+	})
+	if resp != nil && resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	resp = handle(logical.ReadOperation, "creds/user", nil)
+	if resp == nil || resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+	if resp.Data["access_key"] != "AKIAEXAMPLE00001" || resp.Data["secret_key"] != "secret-AKIAEXAMPLE00001" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	username := resp.Secret.InternalData["username"].(string)
+	createUser := stub.Calls("CreateUser")[0]
+	if createUser.Get("UserName") != username ||
+		createUser.Get("PermissionsBoundary") != "arn:aws:iam::123456789012:policy/boundary" ||
+		createUser.Get("Path") != "/vault/" ||
+		createUser.Get("Tags.member.1.Key") != "team" ||
+		createUser.Get("Tags.member.1.Value") != "payments" {
+		t.Fatalf("bad CreateUser call: %v", createUser)
+	}
+	if len(stub.Calls("AttachUserPolicy")) != 2 || len(stub.Calls("PutUserPolicy")) != 1 {
+		t.Fatal("expected the policies to be attached and put")
+	}
+	if stub.Calls("AddUserToGroup")[0].Get("GroupName") != "developers" {
+		t.Fatalf("bad AddUserToGroup call: %v", stub.Calls("AddUserToGroup"))
+	}
+
+	// STS credentials can't be generated for IAM user roles
+	stsResp, err := b.HandleRequest(&logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "sts/user",
+		Storage:   storage,
+	})
+	if err != logical.ErrInvalidRequest {
+		t.Fatalf("expected an invalid request error, got: %v", err)
+	}
+	expectError(stsResp)
+
+	revoke(resp)
+	if stub.HasUser(username) {
+		t.Fatal("expected the user to be deleted")
+	}
+
+	// Assumed roles get the role's TTLs
+	resp = handle(logical.UpdateOperation, "roles/assumed", map[string]interface{}{
+		"credential_type": "assumed_role",
+		"role_arns":       "arn:aws:iam::123456789012:role/one,arn:aws:iam::123456789012:role/two",
+		"policy_arns":     "arn:aws:iam::aws:policy/limit",
+		"default_sts_ttl": "15m",
+		"max_sts_ttl":     "30m",
+	})
+	if resp != nil && resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	// The role to assume must be given, and be one of the role's
+	expectError(handle(logical.ReadOperation, "creds/assumed", nil))
+	expectError(handle(logical.UpdateOperation, "creds/assumed", map[string]interface{}{
+		"role_arn": "arn:aws:iam::123456789012:role/other",
+	}))
+
+	resp = handle(logical.UpdateOperation, "sts/assumed", map[string]interface{}{
+		"role_arn": "arn:aws:iam::123456789012:role/two",
+	})
+	if resp == nil || resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+	if resp.Data["security_token"] != "token" || resp.Secret.Renewable {
+		t.Fatalf("bad: %#v", resp)
+	}
+	if resp.Secret.TTL > 15*time.Minute || resp.Secret.TTL < 14*time.Minute {
+		t.Fatalf("bad TTL: %s", resp.Secret.TTL)
+	}
+	assumeRole := stub.Calls("AssumeRole")[0]
+	if assumeRole.Get("RoleArn") != "arn:aws:iam::123456789012:role/two" ||
+		assumeRole.Get("DurationSeconds") != "900" ||
+		assumeRole.Get("PolicyArns.member.1.arn") != "arn:aws:iam::aws:policy/limit" {
+		t.Fatalf("bad AssumeRole call: %v", assumeRole)
+	}
+
+	expectError(handle(logical.UpdateOperation, "creds/assumed", map[string]interface{}{
+		"role_arn": "arn:aws:iam::123456789012:role/one",
+		"ttl":      "1h",
+	}))
+
+	resp = handle(logical.UpdateOperation, "creds/assumed", map[string]interface{}{
+		"role_arn": "arn:aws:iam::123456789012:role/one",
+		"ttl":      "20m",
+	})
+	if resp == nil || resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+	if stub.Calls("AssumeRole")[1].Get("DurationSeconds") != "1200" {
+		t.Fatalf("bad AssumeRole call: %v", stub.Calls("AssumeRole")[1])
+	}
+
+	// Federation tokens can be limited to managed policies only
+	resp = handle(logical.UpdateOperation, "roles/federated", map[string]interface{}{
+		"credential_type": "federation_token",
+		"policy_arns":     "arn:aws:iam::aws:policy/ReadOnlyAccess",
+	})
+	if resp != nil && resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	resp = handle(logical.ReadOperation, "sts/federated", nil)
+	if resp == nil || resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+	federationToken := stub.Calls("GetFederationToken")[0]
+	if federationToken.Get("PolicyArns.member.1.arn") != "arn:aws:iam::aws:policy/ReadOnlyAccess" ||
+		federationToken.Get("DurationSeconds") != "3600" ||
+		federationToken.Get("Policy") != "" {
+		t.Fatalf("bad GetFederationToken call: %v", federationToken)
+	}
+}
+
+func TestBackend_legacyRoles(t *testing.T) {
+	stub := newAWSStub()
+	defer stub.Close()
+
+	_, storage, handle := testBackendWithStub(t, stub)
+
+	// Roles were stored as the raw policy or ARN
+	if err := storage.Put(&logical.StorageEntry{
+		Key:   "policy/legacy",
+		Value: []byte(`{"Version":"2012-10-17"}`),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := handle(logical.ReadOperation, "roles/legacy", nil)
+	if resp == nil || !reflect.DeepEqual(resp.Data["credential_types"], []string{iamUserCred, federationTokenCred}) ||
+		resp.Data["policy"] != `{"Version":"2012-10-17"}` {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	resp = handle(logical.ListOperation, "roles/", nil)
+	if !reflect.DeepEqual(resp.Data["keys"], []string{"legacy"}) {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	// The inline policy is used for both IAM users and federation tokens
+	resp = handle(logical.ReadOperation, "creds/legacy", nil)
+	if resp == nil || resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+	if stub.Calls("PutUserPolicy")[0].Get("PolicyDocument") != `{"Version":"2012-10-17"}` {
+		t.Fatalf("bad PutUserPolicy call: %v", stub.Calls("PutUserPolicy"))
+	}
+
+	resp = handle(logical.ReadOperation, "sts/legacy", nil)
+	if resp == nil || resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+	if stub.Calls("GetFederationToken")[0].Get("Policy") != `{"Version":"2012-10-17"}` {
+		t.Fatalf("bad GetFederationToken call: %v", stub.Calls("GetFederationToken"))
+	}
+
+	// Writing the role replaces the legacy entry
+	resp = handle(logical.UpdateOperation, "roles/legacy", map[string]interface{}{
+		"arn": "arn:aws:iam::123456789012:role/legacy",
+	})
+	if resp != nil && resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+	if entry, _ := storage.Get("policy/legacy"); entry != nil {
+		t.Fatal("expected the legacy entry to be deleted")
+	}
+	resp = handle(logical.ReadOperation, "roles/legacy", nil)
+	if !reflect.DeepEqual(resp.Data["role_arns"], []string{"arn:aws:iam::123456789012:role/legacy"}) ||
+		resp.Data["arn"] != "arn:aws:iam::123456789012:role/legacy" {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	resp = handle(logical.ReadOperation, "sts/legacy", nil)
+	if resp == nil || resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+	if stub.Calls("AssumeRole")[0].Get("RoleArn") != "arn:aws:iam::123456789012:role/legacy" {
+		t.Fatalf("bad AssumeRole call: %v", stub.Calls("AssumeRole"))
+	}
+}
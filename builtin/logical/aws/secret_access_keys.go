@@ -6,11 +6,8 @@ import (
 	"regexp"
 	"time"
 
-	"strings"
-
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/iam"
-	"github.com/aws/aws-sdk-go/service/sts"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 )
@@ -65,7 +62,7 @@ func genUsername(displayName, policyName, userType string) (ret string, warning
 }
 
 func (b *backend) secretTokenCreate(s logical.Storage,
-	displayName, policyName, policy string,
+	displayName, policyName string, role *awsRoleEntry,
 	lifeTimeInSeconds int64) (*logical.Response, error) {
 	STSClient, err := clientSTS(s)
 	if err != nil {
@@ -74,12 +71,15 @@ func (b *backend) secretTokenCreate(s logical.Storage,
 
 	username, usernameWarning := genUsername(displayName, policyName, "sts")
 
-	tokenResp, err := STSClient.GetFederationToken(
-		&sts.GetFederationTokenInput{
-			Name:            aws.String(username),
-			Policy:          aws.String(policy),
-			DurationSeconds: &lifeTimeInSeconds,
-		})
+	input := &getFederationTokenInput{
+		Name:            aws.String(username),
+		PolicyArns:      policyDescriptors(role.PolicyArns),
+		DurationSeconds: &lifeTimeInSeconds,
+	}
+	if role.PolicyDocument != "" {
+		input.Policy = aws.String(role.PolicyDocument)
+	}
+	tokenResp, err := getFederationToken(STSClient, input)
 
 	if err != nil {
 		return logical.ErrorResponse(fmt.Sprintf(
@@ -92,7 +92,7 @@ func (b *backend) secretTokenCreate(s logical.Storage,
 		"security_token": *tokenResp.Credentials.SessionToken,
 	}, map[string]interface{}{
 		"username": username,
-		"policy":   policy,
+		"policy":   role.PolicyDocument,
 		"is_sts":   true,
 	})
 
@@ -110,7 +110,7 @@ func (b *backend) secretTokenCreate(s logical.Storage,
 }
 
 func (b *backend) assumeRole(s logical.Storage,
-	displayName, policyName, policy string,
+	displayName, policyName, roleArn string, role *awsRoleEntry,
 	lifeTimeInSeconds int64) (*logical.Response, error) {
 	STSClient, err := clientSTS(s)
 	if err != nil {
@@ -119,12 +119,16 @@ func (b *backend) assumeRole(s logical.Storage,
 
 	username, usernameWarning := genUsername(displayName, policyName, "iam_user")
 
-	tokenResp, err := STSClient.AssumeRole(
-		&sts.AssumeRoleInput{
-			RoleSessionName: aws.String(username),
-			RoleArn:         aws.String(policy),
-			DurationSeconds: &lifeTimeInSeconds,
-		})
+	input := &assumeRoleInput{
+		RoleSessionName: aws.String(username),
+		RoleArn:         aws.String(roleArn),
+		PolicyArns:      policyDescriptors(role.PolicyArns),
+		DurationSeconds: &lifeTimeInSeconds,
+	}
+	if role.PolicyDocument != "" {
+		input.Policy = aws.String(role.PolicyDocument)
+	}
+	tokenResp, err := assumeRole(STSClient, input)
 
 	if err != nil {
 		return logical.ErrorResponse(fmt.Sprintf(
@@ -137,7 +141,7 @@ func (b *backend) assumeRole(s logical.Storage,
 		"security_token": *tokenResp.Credentials.SessionToken,
 	}, map[string]interface{}{
 		"username": username,
-		"policy":   policy,
+		"policy":   roleArn,
 		"is_sts":   true,
 	})
 
@@ -156,7 +160,7 @@ func (b *backend) assumeRole(s logical.Storage,
 
 func (b *backend) secretAccessKeysCreate(
 	s logical.Storage,
-	displayName, policyName string, policy string) (*logical.Response, error) {
+	displayName, policyName string, role *awsRoleEntry) (*logical.Response, error) {
 	client, err := clientIAM(s)
 	if err != nil {
 		return logical.ErrorResponse(err.Error()), nil
@@ -176,31 +180,52 @@ func (b *backend) secretAccessKeysCreate(
 	}
 
 	// Create the user
-	_, err = client.CreateUser(&iam.CreateUserInput{
+	createUserRequest := &createUserInput{
 		UserName: aws.String(username),
-	})
+		Tags:     iamTags(role.IAMTags),
+	}
+	if role.IAMPath != "" {
+		createUserRequest.Path = aws.String(role.IAMPath)
+	}
+	if role.PermissionsBoundaryArn != "" {
+		createUserRequest.PermissionsBoundary = aws.String(role.PermissionsBoundaryArn)
+	}
+	_, err = createUser(client, createUserRequest)
 	if err != nil {
 		return logical.ErrorResponse(fmt.Sprintf(
 			"Error creating IAM user: %s", err)), nil
 	}
 
-	if strings.HasPrefix(policy, "arn:") {
+	for _, group := range role.IAMGroups {
+		// Add the user to the group
+		_, err = client.AddUserToGroup(&iam.AddUserToGroupInput{
+			UserName:  aws.String(username),
+			GroupName: aws.String(group),
+		})
+		if err != nil {
+			return logical.ErrorResponse(fmt.Sprintf(
+				"Error adding user to group: %s", err)), nil
+		}
+	}
+
+	for _, arn := range role.PolicyArns {
 		// Attach existing policy against user
 		_, err = client.AttachUserPolicy(&iam.AttachUserPolicyInput{
 			UserName:  aws.String(username),
-			PolicyArn: aws.String(policy),
+			PolicyArn: aws.String(arn),
 		})
 		if err != nil {
 			return logical.ErrorResponse(fmt.Sprintf(
 				"Error attaching user policy: %s", err)), nil
 		}
+	}
 
-	} else {
+	if role.PolicyDocument != "" {
 		// Add new inline user policy against user
 		_, err = client.PutUserPolicy(&iam.PutUserPolicyInput{
 			UserName:       aws.String(username),
 			PolicyName:     aws.String(policyName),
-			PolicyDocument: aws.String(policy),
+			PolicyDocument: aws.String(role.PolicyDocument),
 		})
 		if err != nil {
 			return logical.ErrorResponse(fmt.Sprintf(
@@ -231,7 +256,7 @@ func (b *backend) secretAccessKeysCreate(
 		"security_token": nil,
 	}, map[string]interface{}{
 		"username": username,
-		"policy":   policy,
+		"policy":   role.PolicyDocument,
 		"is_sts":   false,
 	})
 
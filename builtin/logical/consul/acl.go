@@ -0,0 +1,154 @@
+package consul
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/hashicorp/go-cleanhttp"
+)
+
+// The vendored Consul API client only knows the legacy ACL endpoints, so the
+// tokens of the ACL system introduced in Consul 1.4 are managed with the
+// minimal client below.
+
+// aclLink references a Consul ACL policy or role by name
+type aclLink struct {
+	ID   string `json:",omitempty"`
+	Name string `json:",omitempty"`
+}
+
+func aclLinks(names []string) []*aclLink {
+	var result []*aclLink
+	for _, name := range names {
+		result = append(result, &aclLink{Name: name})
+	}
+	return result
+}
+
+type aclToken struct {
+	AccessorID  string     `json:",omitempty"`
+	SecretID    string     `json:",omitempty"`
+	Description string     `json:",omitempty"`
+	Policies    []*aclLink `json:",omitempty"`
+	Roles       []*aclLink `json:",omitempty"`
+	Local       bool       `json:",omitempty"`
+	Namespace   string     `json:",omitempty"`
+}
+
+type aclClient struct {
+	conf   *accessConfig
+	client *http.Client
+}
+
+func newACLClient(conf *accessConfig) *aclClient {
+	return &aclClient{
+		conf:   conf,
+		client: cleanhttp.DefaultClient(),
+	}
+}
+
+// do sends a request to the Consul HTTP API, with the body encoded from in,
+// and decodes the response into out, if given
+func (c *aclClient) do(method, path string, query url.Values, in, out interface{}) error {
+	u := &url.URL{
+		Scheme:   c.conf.Scheme,
+		Host:     c.conf.Address,
+		Path:     path,
+		RawQuery: query.Encode(),
+	}
+	// Like the Consul client, accept an address with a scheme
+	if parts := strings.SplitN(u.Host, "://", 2); len(parts) == 2 {
+		u.Scheme, u.Host = parts[0], parts[1]
+	}
+	if u.Scheme == "" {
+		u.Scheme = "http"
+	}
+
+	var body bytes.Buffer
+	if in != nil {
+		if err := json.NewEncoder(&body).Encode(in); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, u.String(), &body)
+	if err != nil {
+		return err
+	}
+	if c.conf.Token != "" {
+		req.Header.Set("X-Consul-Token", c.conf.Token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := ioutil.ReadAll(resp.Body)
+		return &aclStatusError{
+			StatusCode: resp.StatusCode,
+			Message:    string(bytes.TrimSpace(message)),
+		}
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// aclStatusError is returned for responses with an unexpected status code
+type aclStatusError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *aclStatusError) Error() string {
+	return fmt.Sprintf("Unexpected response code: %d (%s)", e.StatusCode, e.Message)
+}
+
+// namespaceQuery returns the query parameters selecting the Consul
+// Enterprise namespace, if any
+func namespaceQuery(namespace string) url.Values {
+	query := url.Values{}
+	if namespace != "" {
+		query.Set("ns", namespace)
+	}
+	return query
+}
+
+// Bootstrap creates the initial management token of a Consul cluster whose
+// ACL system hasn't been bootstrapped
+func (c *aclClient) Bootstrap() (*aclToken, error) {
+	var token aclToken
+	if err := c.do("PUT", "/v1/acl/bootstrap", nil, nil, &token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// TokenCreate creates the token, and returns it with its accessor and secret
+func (c *aclClient) TokenCreate(token *aclToken) (*aclToken, error) {
+	var result aclToken
+	if err := c.do("PUT", "/v1/acl/token", namespaceQuery(token.Namespace), token, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// TokenDelete deletes the token with the given accessor. Deleting a token
+// that doesn't exist, such as one deleted in Consul directly, succeeds.
+func (c *aclClient) TokenDelete(accessorID, namespace string) error {
+	err := c.do("DELETE", "/v1/acl/token/"+url.PathEscape(accessorID), namespaceQuery(namespace), nil, nil)
+	if statusErr, ok := err.(*aclStatusError); ok && statusErr.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	return err
+}
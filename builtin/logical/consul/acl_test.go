@@ -0,0 +1,250 @@
+package consul
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func testBackendWithConsulStub(t *testing.T) (logical.Backend, logical.Storage, func(logical.Operation, string, map[string]interface{}) *logical.Response) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	b, err := Factory(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handle := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		resp, err := b.HandleRequest(&logical.Request{
+			Operation:   op,
+			Path:        path,
+			Storage:     config.StorageView,
+			Data:        data,
+			DisplayName: "token-test",
+		})
+		if err != nil {
+			t.Fatalf("%s %s: err: %s", op, path, err)
+		}
+		return resp
+	}
+
+	return b, config.StorageView, handle
+}
+
+func TestBackend_bootstrap(t *testing.T) {
+	stub := newConsulStub()
+	defer stub.Close()
+
+	_, storage, handle := testBackendWithConsulStub(t)
+
+	resp := handle(logical.UpdateOperation, "config/access", map[string]interface{}{
+		"address":   stub.Address(),
+		"bootstrap": true,
+		"token":     "token",
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error, got: %#v", resp)
+	}
+
+	resp = handle(logical.UpdateOperation, "config/access", map[string]interface{}{
+		"address":   stub.Address(),
+		"bootstrap": true,
+	})
+	if resp == nil || resp.IsError() || len(resp.Warnings) == 0 {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	// Vault keeps the management token, and only returns its accessor
+	token := stub.Token(resp.Data["accessor"].(string))
+	if token == nil || !token.Management {
+		t.Fatalf("bad: accessor %v", resp.Data["accessor"])
+	}
+	conf, _, err := readConfigAccess(storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Token != token.SecretID {
+		t.Fatalf("bad: %#v", conf)
+	}
+	resp = handle(logical.ReadOperation, "config/access", nil)
+	if _, ok := resp.Data["token"]; ok {
+		t.Fatalf("token should not be set in the response")
+	}
+
+	// The cluster can only be bootstrapped once
+	resp = handle(logical.UpdateOperation, "config/access", map[string]interface{}{
+		"address":   stub.Address(),
+		"bootstrap": true,
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error, got: %#v", resp)
+	}
+}
+
+// failingPutStorage is a storage whose writes fail
+type failingPutStorage struct {
+	logical.InmemStorage
+}
+
+func (s *failingPutStorage) Put(*logical.StorageEntry) error {
+	return errors.New("storage unavailable")
+}
+
+func TestBackend_bootstrapStorageFailure(t *testing.T) {
+	stub := newConsulStub()
+	defer stub.Close()
+
+	b, err := Factory(logical.TestBackendConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := b.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/access",
+		Storage:   &failingPutStorage{},
+		Data: map[string]interface{}{
+			"address":   stub.Address(),
+			"bootstrap": true,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The management token is returned, since Vault couldn't keep it
+	if resp == nil || len(resp.Warnings) == 0 {
+		t.Fatalf("bad: %#v", resp)
+	}
+	token := stub.Token(resp.Data["accessor"].(string))
+	if token == nil || token.SecretID != resp.Data["token"] {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+}
+
+func TestBackend_aclTokens(t *testing.T) {
+	stub := newConsulStub()
+	defer stub.Close()
+
+	b, storage, handle := testBackendWithConsulStub(t)
+
+	handle(logical.UpdateOperation, "config/access", map[string]interface{}{
+		"address":   stub.Address(),
+		"bootstrap": true,
+	})
+
+	resp := handle(logical.UpdateOperation, "roles/readonly", map[string]interface{}{
+		"policies":         "readonly",
+		"consul_roles":     "ops",
+		"local":            true,
+		"consul_namespace": "team",
+		"lease":            "1h",
+	})
+	if resp != nil && resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	resp = handle(logical.ReadOperation, "roles/readonly", nil)
+	expected := map[string]interface{}{
+		"lease":            "1h0m0s",
+		"token_type":       "client",
+		"policies":         []string{"readonly"},
+		"consul_roles":     []string{"ops"},
+		"local":            true,
+		"consul_namespace": "team",
+	}
+	if !reflect.DeepEqual(resp.Data, expected) {
+		t.Fatalf("bad: expected:%#v\nactual:%#v", expected, resp.Data)
+	}
+
+	resp = handle(logical.ReadOperation, "creds/readonly", nil)
+	if resp == nil || resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+	token := stub.Token(resp.Data["accessor"].(string))
+	if token == nil || token.SecretID != resp.Data["token"] {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	if !token.Local || token.Namespace != "team" ||
+		len(token.Policies) != 1 || token.Policies[0].Name != "readonly" ||
+		len(token.Roles) != 1 || token.Roles[0].Name != "ops" {
+		t.Fatalf("bad token: %#v", token.aclToken)
+	}
+
+	// Revoking the lease deletes the token
+	_, err := b.HandleRequest(&logical.Request{
+		Operation: logical.RevokeOperation,
+		Storage:   storage,
+		Secret:    resp.Secret,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stub.Token(token.AccessorID) != nil {
+		t.Fatal("expected the token to be deleted")
+	}
+
+	// Revoking a token that was already deleted succeeds
+	_, err = b.HandleRequest(&logical.Request{
+		Operation: logical.RevokeOperation,
+		Storage:   storage,
+		Secret:    resp.Secret,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A malformed secret doesn't fail the revocation
+	resp.Secret.InternalData["accessor"] = 5
+	delete(resp.Secret.InternalData, "token")
+	_, err = b.HandleRequest(&logical.Request{
+		Operation: logical.RevokeOperation,
+		Storage:   storage,
+		Secret:    resp.Secret,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Unknown Consul policies are reported
+	handle(logical.UpdateOperation, "roles/unknown", map[string]interface{}{
+		"policies": "unknown",
+	})
+	resp = handle(logical.ReadOperation, "creds/unknown", nil)
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error, got: %#v", resp)
+	}
+}
+
+func TestBackend_aclRoleValidation(t *testing.T) {
+	_, _, handle := testBackendWithConsulStub(t)
+
+	cases := map[string]map[string]interface{}{
+		"no policy": {},
+		"policies for management tokens": {
+			"token_type": "management",
+			"policies":   "readonly",
+		},
+		"legacy and new policies": {
+			"policy":   "a2V5ICIiIHsgcG9saWN5ID0gInJlYWQiIH0=",
+			"policies": "readonly",
+		},
+		"local legacy token": {
+			"policy": "a2V5ICIiIHsgcG9saWN5ID0gInJlYWQiIH0=",
+			"local":  true,
+		},
+		"namespaced management token": {
+			"token_type":       "management",
+			"consul_namespace": "team",
+		},
+	}
+
+	for name, data := range cases {
+		resp := handle(logical.UpdateOperation, "roles/test", data)
+		if resp == nil || !resp.IsError() {
+			t.Fatalf("%s: expected error, got: %#v", name, resp)
+		}
+	}
+}
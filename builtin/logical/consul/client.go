@@ -27,3 +27,18 @@ func client(s logical.Storage) (*api.Client, error, error) {
 	client, err := api.NewClient(consulConf)
 	return client, nil, err
 }
+
+func aclClientFromStorage(s logical.Storage) (*aclClient, error, error) {
+	conf, userErr, intErr := readConfigAccess(s)
+	if intErr != nil {
+		return nil, nil, intErr
+	}
+	if userErr != nil {
+		return nil, userErr, nil
+	}
+	if conf == nil {
+		return nil, nil, fmt.Errorf("no error received but no configuration found")
+	}
+
+	return newACLClient(conf), nil, nil
+}
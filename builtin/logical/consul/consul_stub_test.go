@@ -0,0 +1,132 @@
+package consul
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// consulStub is a local stand-in for the Consul ACL API of Consul 1.4 and
+// later. It knows the "readonly" policy and the "ops" role, and keeps the
+// tokens it creates.
+type consulStub struct {
+	sync.Mutex
+	server *httptest.Server
+
+	bootstrapped bool
+	tokens       map[string]*stubToken
+	count        int
+}
+
+type stubToken struct {
+	aclToken
+	Management bool
+}
+
+func newConsulStub() *consulStub {
+	stub := &consulStub{
+		tokens: make(map[string]*stubToken),
+	}
+	stub.server = httptest.NewServer(http.HandlerFunc(stub.handle))
+	return stub
+}
+
+func (s *consulStub) Close() {
+	s.server.Close()
+}
+
+// Address returns the address of the stub, as given to config/access
+func (s *consulStub) Address() string {
+	return strings.TrimPrefix(s.server.URL, "http://")
+}
+
+// Token returns the token with the given accessor, or nil if it doesn't exist
+func (s *consulStub) Token(accessorID string) *stubToken {
+	s.Lock()
+	defer s.Unlock()
+	return s.tokens[accessorID]
+}
+
+func (s *consulStub) newID() string {
+	s.count++
+	return fmt.Sprintf("00000000-0000-0000-0000-%012d", s.count)
+}
+
+func (s *consulStub) handle(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+
+	switch {
+	case r.Method == "PUT" && r.URL.Path == "/v1/acl/bootstrap":
+		if s.bootstrapped {
+			http.Error(w, "Permission denied: ACL bootstrap no longer allowed", http.StatusForbidden)
+			return
+		}
+		s.bootstrapped = true
+		token := &stubToken{Management: true}
+		token.AccessorID = s.newID()
+		token.SecretID = s.newID()
+		token.Description = "Bootstrap Token (Global Management)"
+		s.tokens[token.AccessorID] = token
+		json.NewEncoder(w).Encode(token.aclToken)
+		return
+	}
+
+	if !s.isManagement(r.Header.Get("X-Consul-Token")) {
+		http.Error(w, "Permission denied", http.StatusForbidden)
+		return
+	}
+
+	switch {
+	case r.Method == "PUT" && r.URL.Path == "/v1/acl/token":
+		token := &stubToken{}
+		if err := json.NewDecoder(r.Body).Decode(&token.aclToken); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, policy := range token.Policies {
+			if policy.Name != "readonly" {
+				http.Error(w, "ACL policy not found: "+policy.Name, http.StatusBadRequest)
+				return
+			}
+			policy.ID = "readonly-id"
+		}
+		for _, role := range token.Roles {
+			if role.Name != "ops" {
+				http.Error(w, "ACL role not found: "+role.Name, http.StatusBadRequest)
+				return
+			}
+			role.ID = "ops-id"
+		}
+		token.Namespace = r.URL.Query().Get("ns")
+		token.AccessorID = s.newID()
+		token.SecretID = s.newID()
+		s.tokens[token.AccessorID] = token
+		json.NewEncoder(w).Encode(token.aclToken)
+
+	case r.Method == "DELETE" && strings.HasPrefix(r.URL.Path, "/v1/acl/token/"):
+		accessorID := strings.TrimPrefix(r.URL.Path, "/v1/acl/token/")
+		token, ok := s.tokens[accessorID]
+		if !ok || token.Namespace != r.URL.Query().Get("ns") {
+			http.Error(w, "ACL not found", http.StatusNotFound)
+			return
+		}
+		delete(s.tokens, accessorID)
+		w.Write([]byte("true"))
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *consulStub) isManagement(secretID string) bool {
+	for _, token := range s.tokens {
+		if token.SecretID == secretID && token.Management {
+			return true
+		}
+	}
+	return false
+}
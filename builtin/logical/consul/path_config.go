@@ -30,12 +30,22 @@ func pathConfigAccess() *framework.Path {
 				Type:        framework.TypeString,
 				Description: "Token for API calls",
 			},
+
+			"bootstrap": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `Bootstrap the ACL system of the Consul cluster,
+and use the management token it creates for API calls. Can't be used with
+"token".`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
 			logical.ReadOperation:   pathConfigAccessRead,
 			logical.UpdateOperation: pathConfigAccessWrite,
 		},
+
+		HelpSynopsis:    pathConfigAccessHelpSyn,
+		HelpDescription: pathConfigAccessHelpDesc,
 	}
 }
 
@@ -81,20 +91,52 @@ func pathConfigAccessRead(
 
 func pathConfigAccessWrite(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	entry, err := logical.StorageEntryJSON("config/access", accessConfig{
+	config := &accessConfig{
 		Address: data.Get("address").(string),
 		Scheme:  data.Get("scheme").(string),
 		Token:   data.Get("token").(string),
-	})
-	if err != nil {
-		return nil, err
 	}
 
-	if err := req.Storage.Put(entry); err != nil {
-		return nil, err
+	var resp *logical.Response
+	if data.Get("bootstrap").(bool) {
+		if config.Token != "" {
+			return logical.ErrorResponse("token can't be given when bootstrapping"), nil
+		}
+
+		token, err := newACLClient(config).Bootstrap()
+		if err != nil {
+			return logical.ErrorResponse(fmt.Sprintf(
+				"Error bootstrapping the Consul ACL system: %s", err)), nil
+		}
+		config.Token = token.SecretID
+
+		resp = &logical.Response{
+			Data: map[string]interface{}{
+				"accessor": token.AccessorID,
+			},
+		}
 	}
 
-	return nil, nil
+	entry, err := logical.StorageEntryJSON("config/access", config)
+	if err == nil {
+		err = req.Storage.Put(entry)
+	}
+	if err != nil {
+		if resp == nil {
+			return nil, err
+		}
+
+		// Consul can't be bootstrapped again, so return the management
+		// token rather than lose it
+		resp.Data["token"] = config.Token
+		resp.AddWarning(fmt.Sprintf("The Consul ACL system was bootstrapped, but the access configuration could not be stored: %s. Write it again with the returned management token.", err))
+		return resp, nil
+	}
+
+	if resp != nil {
+		resp.AddWarning("The Consul ACL system was bootstrapped, and its management token is only known to Vault. Keep a separate way to recover access to Consul.")
+	}
+	return resp, nil
 }
 
 type accessConfig struct {
@@ -102,3 +144,17 @@ type accessConfig struct {
 	Scheme  string `json:"scheme"`
 	Token   string `json:"token"`
 }
+
+const pathConfigAccessHelpSyn = `
+Configure the access information for Consul.
+`
+
+const pathConfigAccessHelpDesc = `
+This path configures the address of Consul and the token Vault uses to manage
+Consul ACL tokens. The token needs the acl = "write" permission, or must be a
+management token with the legacy ACL system.
+
+If the ACL system of the Consul cluster hasn't been bootstrapped yet, Vault can
+do it with "bootstrap=true", in place of a token. Vault then keeps the initial
+management token, whose accessor is returned, and no one else knows its secret.
+`
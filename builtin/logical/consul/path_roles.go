@@ -30,8 +30,33 @@ func pathRoles() *framework.Path {
 
 			"policy": &framework.FieldSchema{
 				Type: framework.TypeString,
-				Description: `Policy document, base64 encoded. Required
-for 'client' tokens.`,
+				Description: `Policy document, base64 encoded, for 'client'
+tokens of the legacy ACL system. Can't be used with "policies" or
+"consul_roles".`,
+			},
+
+			"policies": &framework.FieldSchema{
+				Type: framework.TypeCommaStringSlice,
+				Description: `Names of the Consul ACL policies of the tokens,
+for 'client' tokens of the ACL system of Consul 1.4 and later.`,
+			},
+
+			"consul_roles": &framework.FieldSchema{
+				Type: framework.TypeCommaStringSlice,
+				Description: `Names of the Consul ACL roles of the tokens,
+for 'client' tokens of the ACL system of Consul 1.4 and later.`,
+			},
+
+			"local": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `Create tokens that are local to the datacenter,
+instead of global tokens. Requires "policies" or "consul_roles".`,
+			},
+
+			"consul_namespace": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Consul Enterprise namespace of the tokens.
+Requires "policies" or "consul_roles".`,
 			},
 
 			"token_type": &framework.FieldSchema{
@@ -54,6 +79,9 @@ Defaults to 'client'.`,
 			logical.UpdateOperation: pathRolesWrite,
 			logical.DeleteOperation: pathRolesDelete,
 		},
+
+		HelpSynopsis:    pathRolesHelpSyn,
+		HelpDescription: pathRolesHelpDesc,
 	}
 }
 
@@ -91,8 +119,12 @@ func pathRolesRead(
 	// Generate the response
 	resp := &logical.Response{
 		Data: map[string]interface{}{
-			"lease":      result.Lease.String(),
-			"token_type": result.TokenType,
+			"lease":            result.Lease.String(),
+			"token_type":       result.TokenType,
+			"policies":         result.Policies,
+			"consul_roles":     result.ConsulRoles,
+			"local":            result.Local,
+			"consul_namespace": result.ConsulNamespace,
 		},
 	}
 	if result.Policy != "" {
@@ -115,12 +147,33 @@ func pathRolesWrite(
 
 	name := d.Get("name").(string)
 	policy := d.Get("policy").(string)
+	policies := d.Get("policies").([]string)
+	consulRoles := d.Get("consul_roles").([]string)
+	local := d.Get("local").(bool)
+	consulNamespace := d.Get("consul_namespace").(string)
+
+	// Roles with Consul policies or roles use the ACL system of Consul 1.4
+	// and later, others the legacy one
+	aclTokens := len(policies) > 0 || len(consulRoles) > 0
+	if aclTokens && tokenType == "management" {
+		return logical.ErrorResponse(
+			"policies and consul_roles can't be used with management tokens"), nil
+	}
+	if aclTokens && policy != "" {
+		return logical.ErrorResponse(
+			"policy can't be used with policies or consul_roles"), nil
+	}
+	if !aclTokens && (local || consulNamespace != "") {
+		return logical.ErrorResponse(
+			"local and consul_namespace require policies or consul_roles"), nil
+	}
+
 	var policyRaw []byte
 	var err error
-	if tokenType != "management" {
+	if tokenType != "management" && !aclTokens {
 		if policy == "" {
 			return logical.ErrorResponse(
-				"policy cannot be empty when not using management tokens"), nil
+				"policy, policies or consul_roles is required when not using management tokens"), nil
 		}
 		policyRaw, err = base64.StdEncoding.DecodeString(d.Get("policy").(string))
 		if err != nil {
@@ -140,9 +193,13 @@ func pathRolesWrite(
 	}
 
 	entry, err := logical.StorageEntryJSON("policy/"+name, roleConfig{
-		Policy:    string(policyRaw),
-		Lease:     lease,
-		TokenType: tokenType,
+		Policy:          string(policyRaw),
+		Policies:        policies,
+		ConsulRoles:     consulRoles,
+		Local:           local,
+		ConsulNamespace: consulNamespace,
+		Lease:           lease,
+		TokenType:       tokenType,
 	})
 	if err != nil {
 		return nil, err
@@ -165,7 +222,27 @@ func pathRolesDelete(
 }
 
 type roleConfig struct {
-	Policy    string        `json:"policy"`
-	Lease     time.Duration `json:"lease"`
-	TokenType string        `json:"token_type"`
+	Policy          string        `json:"policy"`
+	Policies        []string      `json:"policies"`
+	ConsulRoles     []string      `json:"consul_roles"`
+	Local           bool          `json:"local"`
+	ConsulNamespace string        `json:"consul_namespace"`
+	Lease           time.Duration `json:"lease"`
+	TokenType       string        `json:"token_type"`
 }
+
+const pathRolesHelpSyn = `
+Manage the roles that Consul tokens can be created for.
+`
+
+const pathRolesHelpDesc = `
+This path lets you manage the roles of the backend. Reading "creds/<name>"
+creates a Consul token of the named role.
+
+With the ACL system of Consul 1.4 and later, roles reference the Consul ACL
+"policies" and "consul_roles" the tokens get, and can create tokens that are
+"local" to the datacenter, or in a Consul Enterprise "consul_namespace".
+
+With the legacy ACL system, roles either create 'management' tokens, or
+'client' tokens with the base64 encoded rules given in "policy".
+`
@@ -58,6 +58,10 @@ func (b *backend) pathTokenRead(
 	// Generate a name for the token
 	tokenName := fmt.Sprintf("Vault %s %s %d", name, req.DisplayName, time.Now().UnixNano())
 
+	if len(result.Policies) > 0 || len(result.ConsulRoles) > 0 {
+		return b.aclTokenCreate(req, tokenName, &result)
+	}
+
 	// Create it
 	token, _, err := c.ACL().Create(&api.ACLEntry{
 		Name:  tokenName,
@@ -78,3 +82,39 @@ func (b *backend) pathTokenRead(
 
 	return s, nil
 }
+
+// aclTokenCreate creates a token of the ACL system of Consul 1.4 and later.
+// The accessor is kept to revoke the token.
+func (b *backend) aclTokenCreate(req *logical.Request, tokenName string, role *roleConfig) (*logical.Response, error) {
+	c, userErr, intErr := aclClientFromStorage(req.Storage)
+	if intErr != nil {
+		return nil, intErr
+	}
+	if userErr != nil {
+		return logical.ErrorResponse(userErr.Error()), nil
+	}
+
+	token, err := c.TokenCreate(&aclToken{
+		Description: tokenName,
+		Policies:    aclLinks(role.Policies),
+		Roles:       aclLinks(role.ConsulRoles),
+		Local:       role.Local,
+		Namespace:   role.ConsulNamespace,
+	})
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	s := b.Secret(SecretTokenType).Response(map[string]interface{}{
+		"token":    token.SecretID,
+		"accessor": token.AccessorID,
+		"local":    role.Local,
+	}, map[string]interface{}{
+		"token":            token.SecretID,
+		"accessor":         token.AccessorID,
+		"consul_namespace": role.ConsulNamespace,
+	})
+	s.Secret.TTL = role.Lease
+
+	return s, nil
+}
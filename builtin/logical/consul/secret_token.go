@@ -17,6 +17,11 @@ func secretToken(b *backend) *framework.Secret {
 				Type:        framework.TypeString,
 				Description: "Request token",
 			},
+
+			"accessor": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Accessor of the token, for Consul 1.4 and later",
+			},
 		},
 
 		Renew:  b.secretTokenRenew,
@@ -32,6 +37,25 @@ func (b *backend) secretTokenRenew(
 
 func secretTokenRevoke(
 	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	// Tokens of the ACL system of Consul 1.4 and later are deleted by
+	// accessor
+	if accessor, ok := req.Secret.InternalData["accessor"].(string); ok && accessor != "" {
+		c, userErr, intErr := aclClientFromStorage(req.Storage)
+		if intErr != nil {
+			return nil, intErr
+		}
+		if userErr != nil {
+			return nil, userErr
+		}
+
+		namespace, _ := req.Secret.InternalData["consul_namespace"].(string)
+		if err := c.TokenDelete(accessor, namespace); err != nil {
+			return nil, err
+		}
+
+		return nil, nil
+	}
+
 	c, userErr, intErr := client(req.Storage)
 	if intErr != nil {
 		return nil, intErr
@@ -41,7 +65,7 @@ func secretTokenRevoke(
 		return nil, userErr
 	}
 
-	tokenRaw, ok := req.Secret.InternalData["token"]
+	token, ok := req.Secret.InternalData["token"].(string)
 	if !ok {
 		// We return nil here because this is a pre-0.5.3 problem and there is
 		// nothing we can do about it. We already can't revoke the lease
@@ -50,7 +74,7 @@ func secretTokenRevoke(
 		return nil, nil
 	}
 
-	_, err := c.ACL().Destroy(tokenRaw.(string), nil)
+	_, err := c.ACL().Destroy(token, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -5,10 +5,13 @@ import (
 	"net/rpc"
 	"strings"
 	"sync"
+	"time"
 
 	log "github.com/mgutz/logxi/v1"
 
 	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+	"github.com/hashicorp/vault/helper/locksutil"
+	"github.com/hashicorp/vault/helper/queue"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 )
@@ -35,18 +38,31 @@ func Backend(conf *logical.BackendConfig) *databaseBackend {
 			pathRoles(&b),
 			pathCredsCreate(&b),
 			pathResetConnection(&b),
+			pathListStaticRoles(&b),
+			pathStaticRoles(&b),
+			pathStaticCredsRead(&b),
+			pathRotateRole(&b),
+			pathRotateRootCredentials(&b),
+			pathListPasswordPolicies(&b),
+			pathPasswordPolicies(&b),
+			pathGeneratePassword(&b),
 		},
 
 		Secrets: []*framework.Secret{
 			secretCreds(&b),
 		},
-		Clean:       b.closeAllDBs,
-		Invalidate:  b.invalidate,
-		BackendType: logical.TypeLogical,
+		Clean:             b.closeAllDBs,
+		Invalidate:        b.invalidate,
+		PeriodicFunc:      b.periodicFunc,
+		WALRollback:       b.walRollback,
+		WALRollbackMinAge: 5 * time.Minute,
+		BackendType:       logical.TypeLogical,
 	}
 
 	b.logger = conf.Logger
 	b.connections = make(map[string]dbplugin.Database)
+	b.rotationSchedule = queue.NewScheduler()
+	b.roleLocks = locksutil.CreateLocks()
 	return &b
 }
 
@@ -54,6 +70,13 @@ type databaseBackend struct {
 	connections map[string]dbplugin.Database
 	logger      log.Logger
 
+	// rotationSchedule holds the names of static roles, scheduled at the
+	// time their credentials are next due to be rotated
+	rotationSchedule *queue.Scheduler
+
+	// roleLocks serializes the rotation and modification of static roles
+	roleLocks []*locksutil.LockEntry
+
 	*framework.Backend
 	sync.RWMutex
 }
@@ -97,7 +120,13 @@ func (b *databaseBackend) createDBObj(s logical.Storage, name string) (dbplugin.
 		return nil, err
 	}
 
-	err = db.Initialize(config.ConnectionDetails, true)
+	pluginConfig, err := b.pluginConfig(s, config)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	err = db.Initialize(pluginConfig, true)
 	if err != nil {
 		return nil, err
 	}
@@ -107,6 +136,33 @@ func (b *databaseBackend) createDBObj(s logical.Storage, name string) (dbplugin.
 	return db, nil
 }
 
+// pluginConfig returns the configuration the plugin is initialized with: the
+// connection details, along with the username template and the definition of
+// the password policy if they are set.
+func (b *databaseBackend) pluginConfig(s logical.Storage, config *DatabaseConfig) (map[string]interface{}, error) {
+	pluginConfig := make(map[string]interface{}, len(config.ConnectionDetails)+2)
+	for k, v := range config.ConnectionDetails {
+		pluginConfig[k] = v
+	}
+
+	if config.UsernameTemplate != "" {
+		pluginConfig["username_template"] = config.UsernameTemplate
+	}
+
+	if config.PasswordPolicy != "" {
+		policy, err := b.PasswordPolicy(s, config.PasswordPolicy)
+		if err != nil {
+			return nil, err
+		}
+		if policy == nil {
+			return nil, fmt.Errorf("unknown password policy: %s", config.PasswordPolicy)
+		}
+		pluginConfig["password_policy"] = policy.Policy
+	}
+
+	return pluginConfig, nil
+}
+
 func (b *databaseBackend) DatabaseConfig(s logical.Storage, name string) (*DatabaseConfig, error) {
 	entry, err := s.Get(fmt.Sprintf("config/%s", name))
 	if err != nil {
@@ -141,6 +197,23 @@ func (b *databaseBackend) Role(s logical.Storage, roleName string) (*roleEntry,
 	return &result, nil
 }
 
+func (b *databaseBackend) StaticRole(s logical.Storage, roleName string) (*staticRoleEntry, error) {
+	entry, err := s.Get(staticRolePath + roleName)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result staticRoleEntry
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
 func (b *databaseBackend) invalidate(key string) {
 	b.Lock()
 	defer b.Unlock()
@@ -149,6 +222,13 @@ func (b *databaseBackend) invalidate(key string) {
 	case strings.HasPrefix(key, databaseConfigPath):
 		name := strings.TrimPrefix(key, databaseConfigPath)
 		b.clearConnection(name)
+
+	case strings.HasPrefix(key, passwordPolicyPath):
+		// The connections using the policy aren't known without reading
+		// storage, so reconnect all of them with the new definition
+		for name := range b.connections {
+			b.clearConnection(name)
+		}
 	}
 }
 
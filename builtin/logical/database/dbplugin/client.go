@@ -114,6 +114,18 @@ func (dr *databasePluginRPCClient) RevokeUser(statements Statements, username st
 	return err
 }
 
+func (dr *databasePluginRPCClient) SetCredentials(statements Statements, staticConfig StaticUserConfig) (username string, password string, err error) {
+	req := SetCredentialsRequest{
+		Statements:       statements,
+		StaticUserConfig: staticConfig,
+	}
+
+	var resp SetCredentialsResponse
+	err = dr.client.Call("Plugin.SetCredentials", req, &resp)
+
+	return resp.Username, resp.Password, err
+}
+
 func (dr *databasePluginRPCClient) Initialize(conf map[string]interface{}, verifyConnection bool) error {
 	req := InitializeRequest{
 		Config:           conf,
@@ -17,16 +17,30 @@ type Database interface {
 	RenewUser(statements Statements, username string, expiration time.Time) error
 	RevokeUser(statements Statements, username string) error
 
+	// SetCredentials sets the password of an existing user, generating a new
+	// password if one is not provided in the static config. It is used to
+	// rotate the credentials of static roles.
+	SetCredentials(statements Statements, staticConfig StaticUserConfig) (username string, password string, err error)
+
 	Initialize(config map[string]interface{}, verifyConnection bool) error
 	Close() error
 }
 
+// CredentialsConfigurer is an optional interface for databases that accept
+// settings for the credentials they generate, such as "username_template" and
+// "password_policy". Configure is called with the connection configuration
+// each time the database is initialized, before Initialize.
+type CredentialsConfigurer interface {
+	Configure(config map[string]interface{}) error
+}
+
 // Statements set in role creation and passed into the database type's functions.
 type Statements struct {
 	CreationStatements   string `json:"creation_statments" mapstructure:"creation_statements" structs:"creation_statments"`
 	RevocationStatements string `json:"revocation_statements" mapstructure:"revocation_statements" structs:"revocation_statements"`
 	RollbackStatements   string `json:"rollback_statements" mapstructure:"rollback_statements" structs:"rollback_statements"`
 	RenewStatements      string `json:"renew_statements" mapstructure:"renew_statements" structs:"renew_statements"`
+	RotationStatements   string `json:"rotation_statements" mapstructure:"rotation_statements" structs:"rotation_statements"`
 }
 
 // UsernameConfig is used to configure prefixes for the username to be
@@ -36,6 +50,13 @@ type UsernameConfig struct {
 	RoleName    string
 }
 
+// StaticUserConfig identifies an existing database user whose credentials are
+// managed by a static role. If Password is empty the plugin generates one.
+type StaticUserConfig struct {
+	Username string
+	Password string
+}
+
 // PluginFactory is used to build plugin database types. It wraps the database
 // object in a logging and metrics middleware.
 func PluginFactory(pluginName string, sys pluginutil.LookRunnerUtil, logger log.Logger) (Database, error) {
@@ -59,6 +80,7 @@ func PluginFactory(pluginName string, sys pluginutil.LookRunnerUtil, logger log.
 		if !ok {
 			return nil, fmt.Errorf("unsuported database type: %s", pluginName)
 		}
+		db = withCredentialsConfigurer(db)
 
 	} else {
 		// create a DatabasePluginClient instance
@@ -139,9 +161,19 @@ type RevokeUserRequest struct {
 	Username   string
 }
 
+type SetCredentialsRequest struct {
+	Statements       Statements
+	StaticUserConfig StaticUserConfig
+}
+
 // ---- RPC Response Args Domain ----
 
 type CreateUserResponse struct {
 	Username string
 	Password string
 }
+
+type SetCredentialsResponse struct {
+	Username string
+	Password string
+}
@@ -59,6 +59,20 @@ func (m *mockPlugin) RevokeUser(statements dbplugin.Statements, username string)
 	delete(m.users, username)
 	return nil
 }
+func (m *mockPlugin) SetCredentials(statements dbplugin.Statements, staticConfig dbplugin.StaticUserConfig) (username string, password string, err error) {
+	err = errors.New("err")
+	if _, ok := m.users[staticConfig.Username]; !ok {
+		return "", "", err
+	}
+
+	password = staticConfig.Password
+	if password == "" {
+		password = "rotated"
+	}
+	m.users[staticConfig.Username] = []string{password}
+
+	return staticConfig.Username, password, nil
+}
 func (m *mockPlugin) Initialize(conf map[string]interface{}, _ bool) error {
 	err := errors.New("err")
 	if len(conf) != 1 {
@@ -67,6 +81,13 @@ func (m *mockPlugin) Initialize(conf map[string]interface{}, _ bool) error {
 
 	return nil
 }
+func (m *mockPlugin) Configure(conf map[string]interface{}) error {
+	if conf["username_template"] == "invalid" {
+		return errors.New("invalid username_template")
+	}
+
+	return nil
+}
 func (m *mockPlugin) Close() error {
 	m.users = nil
 	return nil
@@ -129,6 +150,27 @@ func TestPlugin_Initialize(t *testing.T) {
 	}
 }
 
+func TestPlugin_Configure(t *testing.T) {
+	cluster, sys := getCluster(t)
+	defer cluster.Cleanup()
+
+	dbRaw, err := dbplugin.PluginFactory("test-plugin", sys, &log.NullLogger{})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer dbRaw.Close()
+
+	// Configure is called before Initialize, which would accept this config
+	connectionDetails := map[string]interface{}{
+		"username_template": "invalid",
+	}
+
+	err = dbRaw.Initialize(connectionDetails, true)
+	if err == nil || err.Error() != "invalid username_template" {
+		t.Fatalf("expected the Configure error, got: %v", err)
+	}
+}
+
 func TestPlugin_CreateUser(t *testing.T) {
 	cluster, sys := getCluster(t)
 	defer cluster.Cleanup()
@@ -243,3 +285,56 @@ func TestPlugin_RevokeUser(t *testing.T) {
 		t.Fatalf("err: %s", err)
 	}
 }
+
+func TestPlugin_SetCredentials(t *testing.T) {
+	cluster, sys := getCluster(t)
+	defer cluster.Cleanup()
+
+	db, err := dbplugin.PluginFactory("test-plugin", sys, &log.NullLogger{})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer db.Close()
+
+	connectionDetails := map[string]interface{}{
+		"test": 1,
+	}
+	err = db.Initialize(connectionDetails, true)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	usernameConf := dbplugin.UsernameConfig{
+		DisplayName: "test",
+		RoleName:    "test",
+	}
+
+	us, _, err := db.CreateUser(dbplugin.Statements{}, usernameConf, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Test a generated password
+	username, password, err := db.SetCredentials(dbplugin.Statements{}, dbplugin.StaticUserConfig{Username: us})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if username != us || password != "rotated" {
+		t.Fatalf("bad credentials: %s/%s", username, password)
+	}
+
+	// Test a provided password
+	_, password, err = db.SetCredentials(dbplugin.Statements{}, dbplugin.StaticUserConfig{Username: us, Password: "provided"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if password != "provided" {
+		t.Fatalf("bad password: %s", password)
+	}
+
+	// Unknown users should return an error
+	_, _, err = db.SetCredentials(dbplugin.Statements{}, dbplugin.StaticUserConfig{Username: "unknown"})
+	if err == nil {
+		t.Fatal("expected an error setting credentials of an unknown user")
+	}
+}
@@ -11,7 +11,7 @@ import (
 // RPC server.
 func Serve(db Database, tlsProvider func() (*tls.Config, error)) {
 	dbPlugin := &DatabasePlugin{
-		impl: db,
+		impl: withCredentialsConfigurer(db),
 	}
 
 	// pluginMap is the map of plugins we can dispense.
@@ -59,6 +59,13 @@ func (ds *databasePluginRPCServer) RevokeUser(args *RevokeUserRequest, _ *struct
 	return err
 }
 
+func (ds *databasePluginRPCServer) SetCredentials(args *SetCredentialsRequest, resp *SetCredentialsResponse) error {
+	var err error
+	resp.Username, resp.Password, err = ds.impl.SetCredentials(args.Statements, args.StaticUserConfig)
+
+	return err
+}
+
 func (ds *databasePluginRPCServer) Initialize(args *InitializeRequest, _ *struct{}) error {
 	err := ds.impl.Initialize(args.Config, args.VerifyConnection)
 
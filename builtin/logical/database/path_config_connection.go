@@ -6,6 +6,7 @@ import (
 
 	"github.com/fatih/structs"
 	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+	"github.com/hashicorp/vault/helper/template"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 )
@@ -23,6 +24,15 @@ type DatabaseConfig struct {
 	// by each database type.
 	ConnectionDetails map[string]interface{} `json:"connection_details" structs:"connection_details" mapstructure:"connection_details"`
 	AllowedRoles      []string               `json:"allowed_roles" structs:"allowed_roles" mapstructure:"allowed_roles"`
+
+	RootRotationStatements string `json:"root_rotation_statements" structs:"root_rotation_statements" mapstructure:"root_rotation_statements"`
+
+	// UsernameTemplate is the template the plugin generates usernames from,
+	// and PasswordPolicy the name of the password policy it generates
+	// passwords with. The plugin's default formats are used if they are not
+	// set.
+	UsernameTemplate string `json:"username_template" structs:"username_template" mapstructure:"username_template"`
+	PasswordPolicy   string `json:"password_policy" structs:"password_policy" mapstructure:"password_policy"`
 }
 
 // pathResetConnection configures a path to reset a plugin.
@@ -102,6 +112,27 @@ func pathConfigurePluginConnection(b *databaseBackend) *framework.Path {
 				allowed to get creds from this database connection. If empty no
 				roles are allowed. If "*" all roles are allowed.`,
 			},
+
+			"root_rotation_statements": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Specifies the database statements to be executed
+				to rotate the root user's credentials. If not set, the plugin's
+				default statements are used. See the plugin's API page for more
+				information on support and formatting for this parameter.`,
+			},
+
+			"username_template": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Template used to generate the usernames of
+				dynamic credentials, given the DisplayName and RoleName. If not
+				set, the plugin's default format is used.`,
+			},
+
+			"password_policy": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Name of the password policy used to generate
+				passwords. If not set, the plugin's default format is used.`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -159,6 +190,10 @@ func (b *databaseBackend) connectionReadHandler() framework.OperationFunc {
 		if err := entry.DecodeJSON(&config); err != nil {
 			return nil, err
 		}
+
+		// The root password may have been rotated by Vault, so never return it
+		delete(config.ConnectionDetails, "password")
+
 		return &logical.Response{
 			Data: structs.New(config).Map(),
 		}, nil
@@ -212,17 +247,43 @@ func (b *databaseBackend) connectionWriteHandler() framework.OperationFunc {
 
 		allowedRoles := data.Get("allowed_roles").([]string)
 
+		rootRotationStatements := data.Get("root_rotation_statements").(string)
+
+		usernameTemplate := data.Get("username_template").(string)
+		if usernameTemplate != "" {
+			if _, err := template.NewTemplate(usernameTemplate); err != nil {
+				return logical.ErrorResponse(fmt.Sprintf("invalid username_template: %s", err)), nil
+			}
+		}
+
+		passwordPolicy := data.Get("password_policy").(string)
+		if passwordPolicy != "" {
+			policy, err := b.PasswordPolicy(req.Storage, passwordPolicy)
+			if err != nil {
+				return nil, err
+			}
+			if policy == nil {
+				return logical.ErrorResponse(fmt.Sprintf("unknown password policy: %s", passwordPolicy)), nil
+			}
+		}
+
 		// Remove these entries from the data before we store it keyed under
 		// ConnectionDetails.
 		delete(data.Raw, "name")
 		delete(data.Raw, "plugin_name")
 		delete(data.Raw, "allowed_roles")
 		delete(data.Raw, "verify_connection")
+		delete(data.Raw, "root_rotation_statements")
+		delete(data.Raw, "username_template")
+		delete(data.Raw, "password_policy")
 
 		config := &DatabaseConfig{
-			ConnectionDetails: data.Raw,
-			PluginName:        pluginName,
-			AllowedRoles:      allowedRoles,
+			ConnectionDetails:      data.Raw,
+			PluginName:             pluginName,
+			AllowedRoles:           allowedRoles,
+			RootRotationStatements: rootRotationStatements,
+			UsernameTemplate:       usernameTemplate,
+			PasswordPolicy:         passwordPolicy,
 		}
 
 		db, err := dbplugin.PluginFactory(config.PluginName, b.System(), b.logger)
@@ -230,7 +291,13 @@ func (b *databaseBackend) connectionWriteHandler() framework.OperationFunc {
 			return logical.ErrorResponse(fmt.Sprintf("error creating database object: %s", err)), nil
 		}
 
-		err = db.Initialize(config.ConnectionDetails, verifyConnection)
+		pluginConfig, err := b.pluginConfig(req.Storage, config)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+
+		err = db.Initialize(pluginConfig, verifyConnection)
 		if err != nil {
 			db.Close()
 			return logical.ErrorResponse(fmt.Sprintf("error creating database object: %s", err)), nil
@@ -256,7 +323,7 @@ func (b *databaseBackend) connectionWriteHandler() framework.OperationFunc {
 		}
 
 		resp := &logical.Response{}
-		resp.AddWarning("Read access to this endpoint should be controlled via ACLs as it will return the connection details as is, including passwords, if any.")
+		resp.AddWarning("Read access to this endpoint should be controlled via ACLs as it will return the connection details as is, including passwords embedded in the connection URL, if any.")
 
 		return resp, nil
 	}
@@ -282,6 +349,15 @@ accepts:
 	* "verify_connection" (default: true) - A boolean value denoting if the plugin should verify
 	   it is able to connect to the database using the provided connection
        details.
+
+	* "root_rotation_statements" - The statements used by the "rotate-root/"
+	   path to change the password of the user in the connection details.
+
+	* "username_template" - The template usernames are generated from, given
+	   the DisplayName and RoleName.
+
+	* "password_policy" - The name of the password policy, defined under the
+	   "password-policies/" path, that passwords are generated with.
 `
 
 const pathResetConnectionHelpSyn = `
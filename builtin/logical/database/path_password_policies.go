@@ -0,0 +1,304 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/vault/helper/random"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+	"github.com/hashicorp/vault/plugins/helper/database/credsutil"
+)
+
+const passwordPolicyPath = "password-policy/"
+
+// passwordPolicyEntry is a named password policy. The definition is kept as
+// given, and is parsed by the plugins of the connections using it.
+type passwordPolicyEntry struct {
+	Policy string `json:"policy"`
+}
+
+func pathListPasswordPolicies(b *databaseBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "password-policies/?$",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.pathPasswordPolicyList(),
+		},
+
+		HelpSynopsis:    pathPasswordPolicyHelpSyn,
+		HelpDescription: pathPasswordPolicyHelpDesc,
+	}
+}
+
+func pathPasswordPolicies(b *databaseBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "password-policies/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeString,
+				Description: "Name of the password policy.",
+			},
+
+			"policy": {
+				Type: framework.TypeString,
+				Description: `The HCL definition of the password policy: its
+				length and the character sets passwords are made of.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathPasswordPolicyRead(),
+			logical.UpdateOperation: b.pathPasswordPolicyWrite(),
+			logical.DeleteOperation: b.pathPasswordPolicyDelete(),
+		},
+
+		HelpSynopsis:    pathPasswordPolicyHelpSyn,
+		HelpDescription: pathPasswordPolicyHelpDesc,
+	}
+}
+
+func pathGeneratePassword(b *databaseBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "password-policies/" + framework.GenericNameRegex("name") + "/generate$",
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeString,
+				Description: "Name of the password policy.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathGeneratePasswordRead(),
+		},
+
+		HelpSynopsis:    pathGeneratePasswordHelpSyn,
+		HelpDescription: pathGeneratePasswordHelpDesc,
+	}
+}
+
+func (b *databaseBackend) PasswordPolicy(s logical.Storage, name string) (*passwordPolicyEntry, error) {
+	entry, err := s.Get(passwordPolicyPath + name)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result passwordPolicyEntry
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// passwordGenerator returns the generator of the named password policy
+func (b *databaseBackend) passwordGenerator(s logical.Storage, name string) (*random.StringGenerator, error) {
+	policy, err := b.PasswordPolicy(s, name)
+	if err != nil {
+		return nil, err
+	}
+	if policy == nil {
+		return nil, fmt.Errorf("unknown password policy: %s", name)
+	}
+
+	return random.ParsePolicy(policy.Policy)
+}
+
+// generatePassword returns a new password for a user of the connection,
+// generated by its password policy if it has one
+func (b *databaseBackend) generatePassword(s logical.Storage, config *DatabaseConfig) (string, error) {
+	if config.PasswordPolicy == "" {
+		return credsutil.RandomAlphaNumeric(20)
+	}
+
+	generator, err := b.passwordGenerator(s, config.PasswordPolicy)
+	if err != nil {
+		return "", err
+	}
+	return generator.Generate()
+}
+
+// connectionsUsingPasswordPolicy returns the names of the connections
+// configured with the named password policy
+func (b *databaseBackend) connectionsUsingPasswordPolicy(s logical.Storage, name string) ([]string, error) {
+	connections, err := s.List("config/")
+	if err != nil {
+		return nil, err
+	}
+
+	var result []string
+	for _, connection := range connections {
+		config, err := b.DatabaseConfig(s, connection)
+		if err != nil {
+			return nil, err
+		}
+		if config.PasswordPolicy == name {
+			result = append(result, connection)
+		}
+	}
+
+	return result, nil
+}
+
+func (b *databaseBackend) pathPasswordPolicyList() framework.OperationFunc {
+	return func(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		entries, err := req.Storage.List(passwordPolicyPath)
+		if err != nil {
+			return nil, err
+		}
+
+		return logical.ListResponse(entries), nil
+	}
+}
+
+func (b *databaseBackend) pathPasswordPolicyRead() framework.OperationFunc {
+	return func(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		policy, err := b.PasswordPolicy(req.Storage, data.Get("name").(string))
+		if err != nil {
+			return nil, err
+		}
+		if policy == nil {
+			return nil, nil
+		}
+
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"policy": policy.Policy,
+			},
+		}, nil
+	}
+}
+
+func (b *databaseBackend) pathPasswordPolicyWrite() framework.OperationFunc {
+	return func(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		name := data.Get("name").(string)
+		if name == "" {
+			return logical.ErrorResponse("empty policy name attribute given"), nil
+		}
+
+		raw := data.Get("policy").(string)
+		if raw == "" {
+			return logical.ErrorResponse("empty policy attribute given"), nil
+		}
+		if _, err := random.ParsePolicy(raw); err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("invalid password policy: %s", err)), nil
+		}
+
+		// Hold the write lock so that connections can't be created with the
+		// old policy while it is replaced
+		b.Lock()
+		defer b.Unlock()
+
+		entry, err := logical.StorageEntryJSON(passwordPolicyPath+name, &passwordPolicyEntry{
+			Policy: raw,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := req.Storage.Put(entry); err != nil {
+			return nil, err
+		}
+
+		// The plugins using the policy are given the new definition when
+		// they are next run
+		connections, err := b.connectionsUsingPasswordPolicy(req.Storage, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, connection := range connections {
+			b.clearConnection(connection)
+		}
+
+		return nil, nil
+	}
+}
+
+func (b *databaseBackend) pathPasswordPolicyDelete() framework.OperationFunc {
+	return func(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		name := data.Get("name").(string)
+
+		b.Lock()
+		defer b.Unlock()
+
+		connections, err := b.connectionsUsingPasswordPolicy(req.Storage, name)
+		if err != nil {
+			return nil, err
+		}
+		if len(connections) > 0 {
+			return logical.ErrorResponse(fmt.Sprintf("password policy is in use by connections: %v", connections)), nil
+		}
+
+		if err := req.Storage.Delete(passwordPolicyPath + name); err != nil {
+			return nil, err
+		}
+
+		return nil, nil
+	}
+}
+
+func (b *databaseBackend) pathGeneratePasswordRead() framework.OperationFunc {
+	return func(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		name := data.Get("name").(string)
+
+		policy, err := b.PasswordPolicy(req.Storage, name)
+		if err != nil {
+			return nil, err
+		}
+		if policy == nil {
+			return logical.ErrorResponse(fmt.Sprintf("unknown password policy: %s", name)), nil
+		}
+
+		generator, err := random.ParsePolicy(policy.Policy)
+		if err != nil {
+			return nil, err
+		}
+
+		password, err := generator.Generate()
+		if err != nil {
+			return nil, err
+		}
+
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"password": password,
+			},
+		}, nil
+	}
+}
+
+const pathPasswordPolicyHelpSyn = `
+Manage the password policies of database connections.
+`
+
+const pathPasswordPolicyHelpDesc = `
+This path lets you manage the named password policies that database
+connections can use to generate passwords, in place of the plugin's default
+format. A policy is written in HCL, and sets the length of the passwords and
+the character sets they are made of, with the minimum number of characters
+that must be taken from each:
+
+	length = 20
+
+	rule "charset" {
+	  charset   = "abcdefghijklmnopqrstuvwxyz"
+	  min-chars = 1
+	}
+
+	rule "charset" {
+	  charset   = "0123456789"
+	  min-chars = 1
+	}
+
+A policy can't be deleted while a connection uses it.
+`
+
+const pathGeneratePasswordHelpSyn = `
+Generate a password from a password policy.
+`
+
+const pathGeneratePasswordHelpDesc = `
+This path generates a password from the named password policy, for example to
+check that the policy produces the expected passwords.
+`
@@ -0,0 +1,164 @@
+package database
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/vault"
+)
+
+func TestBackend_PasswordPolicies(t *testing.T) {
+	cluster, sys := getCluster(t)
+	defer cluster.Cleanup()
+
+	vault.TestAddTestPlugin(t, cluster.Cores[0].Core, "mock-database-plugin", "TestBackend_StaticPluginMain")
+
+	stateFile, err := ioutil.TempFile("", "vault-database-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stateFile.Close()
+	defer os.Remove(stateFile.Name())
+	if err := ioutil.WriteFile(stateFile.Name(), []byte("initial"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	config.System = sys
+	lb, err := Factory(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := lb.(*databaseBackend)
+	defer b.Cleanup()
+
+	handle := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		resp, err := b.HandleRequest(&logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   config.StorageView,
+			Data:      data,
+		})
+		if err != nil {
+			t.Fatalf("%s %s: err: %s", op, path, err)
+		}
+		return resp
+	}
+
+	policy := `
+length = 24
+
+rule "charset" {
+  charset   = "abc"
+  min-chars = 1
+}
+
+rule "charset" {
+  charset   = "123"
+  min-chars = 1
+}
+`
+	passwordRegexp := regexp.MustCompile(`^[abc123]{24}$`)
+
+	// Invalid policies are rejected
+	resp := handle(logical.UpdateOperation, "password-policies/invalid", map[string]interface{}{
+		"policy": "length = 24",
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error, got: %#v", resp)
+	}
+
+	resp = handle(logical.UpdateOperation, "password-policies/short", map[string]interface{}{
+		"policy": policy,
+	})
+	if resp != nil && resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	resp = handle(logical.ReadOperation, "password-policies/short", nil)
+	if resp == nil || resp.Data["policy"] != policy {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	resp = handle(logical.ListOperation, "password-policies/", nil)
+	if !reflect.DeepEqual(resp.Data["keys"], []string{"short"}) {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	resp = handle(logical.ReadOperation, "password-policies/short/generate", nil)
+	if resp == nil || !passwordRegexp.MatchString(resp.Data["password"].(string)) {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	resp = handle(logical.ReadOperation, "password-policies/unknown/generate", nil)
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error, got: %#v", resp)
+	}
+
+	// Connections can only use known policies and valid templates
+	for _, data := range []map[string]interface{}{
+		{"password_policy": "unknown"},
+		{"username_template": "{{.RoleName"},
+	} {
+		data["plugin_name"] = "mock-database-plugin"
+		resp = handle(logical.UpdateOperation, "config/invalid", data)
+		if resp == nil || !resp.IsError() {
+			t.Fatalf("%v: expected error, got: %#v", data, resp)
+		}
+	}
+
+	resp = handle(logical.UpdateOperation, "config/rootdb", map[string]interface{}{
+		"plugin_name":       "mock-database-plugin",
+		"username":          "root",
+		"password":          "initial",
+		"state_file":        stateFile.Name(),
+		"username_template": "{{.RoleName}}-{{random 10}}",
+		"password_policy":   "short",
+	})
+	if resp != nil && resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	resp = handle(logical.ReadOperation, "config/rootdb", nil)
+	if resp.Data["password_policy"] != "short" || resp.Data["username_template"] != "{{.RoleName}}-{{random 10}}" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	details := resp.Data["connection_details"].(map[string]interface{})
+	if _, ok := details["password_policy"]; ok {
+		t.Fatalf("settings should not be stored in the connection details: %#v", details)
+	}
+
+	// The root credentials are rotated according to the policy
+	resp = handle(logical.UpdateOperation, "rotate-root/rootdb", nil)
+	if resp != nil && resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+	password, err := ioutil.ReadFile(stateFile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !passwordRegexp.Match(password) {
+		t.Fatalf("password does not match the policy: %q", password)
+	}
+
+	// Policies in use can't be deleted
+	resp = handle(logical.DeleteOperation, "password-policies/short", nil)
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error, got: %#v", resp)
+	}
+
+	handle(logical.DeleteOperation, "config/rootdb", nil)
+
+	resp = handle(logical.DeleteOperation, "password-policies/short", nil)
+	if resp != nil && resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+	if resp = handle(logical.ReadOperation, "password-policies/short", nil); resp != nil {
+		t.Fatalf("expected policy to be deleted, got: %#v", resp)
+	}
+}
@@ -0,0 +1,177 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+	"github.com/hashicorp/vault/helper/locksutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathRotateRootCredentials(b *databaseBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "rotate-root/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeString,
+				Description: "Name of this database connection",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathRotateRootCredentialsUpdate(),
+		},
+
+		HelpSynopsis:    pathRotateRootCredentialsHelpSyn,
+		HelpDescription: pathRotateRootCredentialsHelpDesc,
+	}
+}
+
+func pathRotateRole(b *databaseBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "rotate-role/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeString,
+				Description: "Name of the static role.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathRotateRoleUpdate(),
+		},
+
+		HelpSynopsis:    pathRotateRoleHelpSyn,
+		HelpDescription: pathRotateRoleHelpDesc,
+	}
+}
+
+func (b *databaseBackend) pathRotateRoleUpdate() framework.OperationFunc {
+	return func(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		name := data.Get("name").(string)
+
+		lock := locksutil.LockForKey(b.roleLocks, name)
+		lock.Lock()
+		defer lock.Unlock()
+
+		role, err := b.StaticRole(req.Storage, name)
+		if err != nil {
+			return nil, err
+		}
+		if role == nil {
+			return logical.ErrorResponse(fmt.Sprintf("unknown static role: %s", name)), nil
+		}
+
+		if err := b.setStaticRoleCredentials(req.Storage, name, role); err != nil {
+			return nil, err
+		}
+
+		if err := b.scheduleRotation(name, role); err != nil {
+			return nil, err
+		}
+
+		return nil, nil
+	}
+}
+
+func (b *databaseBackend) pathRotateRootCredentialsUpdate() framework.OperationFunc {
+	return func(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		name := data.Get("name").(string)
+		if name == "" {
+			return logical.ErrorResponse(respErrEmptyName), nil
+		}
+
+		// Hold the write lock for the whole rotation so that no other
+		// operation uses, or replaces, the connection in the meantime
+		b.Lock()
+		defer b.Unlock()
+
+		config, err := b.DatabaseConfig(req.Storage, name)
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+
+		username, _ := config.ConnectionDetails["username"].(string)
+		oldPassword, _ := config.ConnectionDetails["password"].(string)
+		if username == "" || oldPassword == "" {
+			return logical.ErrorResponse("unable to rotate root credentials: username and password must be set in the connection details"), nil
+		}
+
+		db, err := b.createDBObj(req.Storage, name)
+		if err != nil {
+			return nil, fmt.Errorf("cound not retrieve db with name: %s, got error: %s", name, err)
+		}
+
+		newPassword, err := b.generatePassword(req.Storage, config)
+		if err != nil {
+			return nil, err
+		}
+
+		// Write the new password to the WAL before changing it, so that it
+		// can be recovered if we fail to store it below
+		walID, err := framework.PutWAL(req.Storage, walRotateRootCredentials, &rotateRootCredentialsWAL{
+			ConnectionName: name,
+			Username:       username,
+			NewPassword:    newPassword,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error writing WAL entry: %s", err)
+		}
+
+		_, _, err = db.SetCredentials(dbplugin.Statements{
+			RotationStatements: config.RootRotationStatements,
+		}, dbplugin.StaticUserConfig{
+			Username: username,
+			Password: newPassword,
+		})
+		if err != nil {
+			// Whether or not the password was changed, the WAL entry is left in
+			// place for the rollback to reconcile the stored credentials
+			b.clearConnection(name)
+			return nil, fmt.Errorf("failed to rotate root credentials: %s", err)
+		}
+
+		config.ConnectionDetails["password"] = newPassword
+		entry, err := logical.StorageEntryJSON(fmt.Sprintf("config/%s", name), config)
+		if err != nil {
+			return nil, err
+		}
+		if err := req.Storage.Put(entry); err != nil {
+			return nil, err
+		}
+
+		// The rotation is complete; if the WAL entry can't be removed the
+		// rollback will find that the new password is already stored
+		if err := framework.DeleteWAL(req.Storage, walID); err != nil {
+			b.logger.Warn("database: failed to delete WAL entry for root credential rotation", "connection", name, "error", err)
+		}
+
+		// Reconnect using the new credentials
+		b.clearConnection(name)
+
+		return nil, nil
+	}
+}
+
+const pathRotateRoleHelpSyn = `
+Request to rotate the credentials of a static role.
+`
+
+const pathRotateRoleHelpDesc = `
+This path attempts to rotate the password of the database user bound to a
+static role immediately. The next scheduled rotation happens one rotation
+period after this one.
+`
+
+const pathRotateRootCredentialsHelpSyn = `
+Request to rotate the root credentials for a certain database connection.
+`
+
+const pathRotateRootCredentialsHelpDesc = `
+This path attempts to rotate the password of the user in the connection details
+of the given database connection, and stores the new password. The connection
+details must set "username" and "password", and the plugin must use them to
+connect, for example by referencing "{{username}}" and "{{password}}" in the
+connection URL. Once rotated, the password is only known to Vault.
+`
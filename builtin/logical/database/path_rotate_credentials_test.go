@@ -0,0 +1,181 @@
+package database
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+	"github.com/hashicorp/vault/vault"
+)
+
+func TestBackend_RotateRootCredentials(t *testing.T) {
+	cluster, sys := getCluster(t)
+	defer cluster.Cleanup()
+
+	vault.TestAddTestPlugin(t, cluster.Cores[0].Core, "mock-database-plugin", "TestBackend_StaticPluginMain")
+
+	stateFile, err := ioutil.TempFile("", "vault-database-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stateFile.Close()
+	defer os.Remove(stateFile.Name())
+
+	setDatabasePassword := func(password string) {
+		if err := ioutil.WriteFile(stateFile.Name(), []byte(password), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	databasePassword := func() string {
+		password, err := ioutil.ReadFile(stateFile.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(password)
+	}
+
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	config.System = sys
+	lb, err := Factory(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := lb.(*databaseBackend)
+	defer b.Cleanup()
+
+	handle := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		resp, err := b.HandleRequest(&logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   config.StorageView,
+			Data:      data,
+		})
+		if err != nil {
+			t.Fatalf("%s %s: err: %s", op, path, err)
+		}
+		return resp
+	}
+	storedPassword := func() string {
+		dbConfig, err := b.DatabaseConfig(config.StorageView, "rootdb")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return dbConfig.ConnectionDetails["password"].(string)
+	}
+	walCount := func() int {
+		keys, err := framework.ListWAL(config.StorageView)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(keys)
+	}
+	rollback := func() *logical.Response {
+		return handle(logical.RollbackOperation, "", map[string]interface{}{
+			"immediate": true,
+		})
+	}
+
+	setDatabasePassword("initial")
+	resp := handle(logical.UpdateOperation, "config/rootdb", map[string]interface{}{
+		"plugin_name": "mock-database-plugin",
+		"username":    "root",
+		"password":    "initial",
+		"state_file":  stateFile.Name(),
+	})
+	if resp != nil && resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	// The password is not returned when reading the connection
+	resp = handle(logical.ReadOperation, "config/rootdb", nil)
+	details := resp.Data["connection_details"].(map[string]interface{})
+	if _, ok := details["password"]; ok || details["username"] != "root" {
+		t.Fatalf("bad: %#v", details)
+	}
+
+	resp = handle(logical.UpdateOperation, "rotate-root/rootdb", nil)
+	if resp != nil && resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+	rotated := databasePassword()
+	if rotated == "initial" {
+		t.Fatal("password should have been rotated")
+	}
+	if storedPassword() != rotated {
+		t.Fatalf("stored password %q does not match database password %q", storedPassword(), rotated)
+	}
+	if walCount() != 0 {
+		t.Fatal("WAL entry should have been deleted")
+	}
+
+	// The new credentials are used for subsequent connections
+	handle(logical.UpdateOperation, "reset/rootdb", nil)
+
+	// Connections without separate credentials can't be rotated
+	handle(logical.UpdateOperation, "config/nocreds", map[string]interface{}{
+		"plugin_name": "mock-database-plugin",
+	})
+	resp = handle(logical.UpdateOperation, "rotate-root/nocreds", nil)
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error, got: %#v", resp)
+	}
+
+	// The database was updated but the new password was never stored
+	if _, err := framework.PutWAL(config.StorageView, walRotateRootCredentials, &rotateRootCredentialsWAL{
+		ConnectionName: "rootdb",
+		Username:       "root",
+		NewPassword:    "fromwal",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	setDatabasePassword("fromwal")
+
+	if resp := rollback(); resp != nil && resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+	if storedPassword() != "fromwal" {
+		t.Fatalf("expected the password from the WAL to be stored, got %q", storedPassword())
+	}
+	if walCount() != 0 {
+		t.Fatal("WAL entry should have been deleted")
+	}
+
+	// The rotation never reached the database
+	if _, err := framework.PutWAL(config.StorageView, walRotateRootCredentials, &rotateRootCredentialsWAL{
+		ConnectionName: "rootdb",
+		Username:       "root",
+		NewPassword:    "unused",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if resp := rollback(); resp != nil && resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+	if storedPassword() != "fromwal" {
+		t.Fatalf("expected the stored password to be kept, got %q", storedPassword())
+	}
+	if walCount() != 0 {
+		t.Fatal("WAL entry should have been deleted")
+	}
+
+	// Neither password works, so the WAL entry is kept for a later attempt
+	if _, err := framework.PutWAL(config.StorageView, walRotateRootCredentials, &rotateRootCredentialsWAL{
+		ConnectionName: "rootdb",
+		Username:       "root",
+		NewPassword:    "unused",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	setDatabasePassword("changed-elsewhere")
+
+	if resp := rollback(); resp == nil || !resp.IsError() {
+		t.Fatalf("expected error, got: %#v", resp)
+	}
+	if walCount() != 1 {
+		t.Fatal("WAL entry should have been kept")
+	}
+}
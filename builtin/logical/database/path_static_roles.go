@@ -0,0 +1,252 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+	"github.com/hashicorp/vault/helper/locksutil"
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const (
+	staticRolePath = "static-role/"
+
+	defaultRotationPeriod = 24 * time.Hour
+
+	// minRotationPeriod matches the interval at which the periodic function
+	// checks for static roles that are due to be rotated
+	minRotationPeriod = time.Minute
+)
+
+func pathListStaticRoles(b *databaseBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "static-roles/?$",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.pathStaticRoleList(),
+		},
+
+		HelpSynopsis:    pathStaticRoleHelpSyn,
+		HelpDescription: pathStaticRoleHelpDesc,
+	}
+}
+
+func pathStaticRoles(b *databaseBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "static-roles/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeString,
+				Description: "Name of the role.",
+			},
+
+			"db_name": {
+				Type:        framework.TypeString,
+				Description: "Name of the database this role acts on.",
+			},
+			"username": {
+				Type: framework.TypeString,
+				Description: `Name of the existing database user whose
+				password is managed by this role.`,
+			},
+			"rotation_period": {
+				Type:    framework.TypeDurationSecond,
+				Default: int(defaultRotationPeriod.Seconds()),
+				Description: `Period after which the user's password is
+				rotated. Must be at least one minute.`,
+			},
+			"rotation_statements": {
+				Type: framework.TypeString,
+				Description: `Specifies the database statements to be executed
+				to set the password of the user. If not set, the plugin's
+				default statements are used. See the plugin's API page for more
+				information on support and formatting for this parameter.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathStaticRoleRead(),
+			logical.UpdateOperation: b.pathStaticRoleCreate(),
+			logical.DeleteOperation: b.pathStaticRoleDelete(),
+		},
+
+		HelpSynopsis:    pathStaticRoleHelpSyn,
+		HelpDescription: pathStaticRoleHelpDesc,
+	}
+}
+
+func (b *databaseBackend) pathStaticRoleDelete() framework.OperationFunc {
+	return func(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		name := data.Get("name").(string)
+
+		lock := locksutil.LockForKey(b.roleLocks, name)
+		lock.Lock()
+		defer lock.Unlock()
+
+		err := req.Storage.Delete(staticRolePath + name)
+		if err != nil {
+			return nil, err
+		}
+
+		b.rotationSchedule.Unschedule(name)
+
+		return nil, nil
+	}
+}
+
+func (b *databaseBackend) pathStaticRoleRead() framework.OperationFunc {
+	return func(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		role, err := b.StaticRole(req.Storage, data.Get("name").(string))
+		if err != nil {
+			return nil, err
+		}
+		if role == nil {
+			return nil, nil
+		}
+
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"db_name":             role.DBName,
+				"username":            role.Username,
+				"rotation_period":     role.RotationPeriod.Seconds(),
+				"rotation_statements": role.Statements.RotationStatements,
+				"last_vault_rotation": role.LastVaultRotation,
+			},
+		}, nil
+	}
+}
+
+func (b *databaseBackend) pathStaticRoleList() framework.OperationFunc {
+	return func(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		entries, err := req.Storage.List(staticRolePath)
+		if err != nil {
+			return nil, err
+		}
+
+		return logical.ListResponse(entries), nil
+	}
+}
+
+func (b *databaseBackend) pathStaticRoleCreate() framework.OperationFunc {
+	return func(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		name := data.Get("name").(string)
+		if name == "" {
+			return logical.ErrorResponse("empty role name attribute given"), nil
+		}
+
+		dbName := data.Get("db_name").(string)
+		if dbName == "" {
+			return logical.ErrorResponse("empty database name attribute given"), nil
+		}
+
+		username := data.Get("username").(string)
+		if username == "" {
+			return logical.ErrorResponse("empty username attribute given"), nil
+		}
+
+		rotationPeriod := time.Duration(data.Get("rotation_period").(int)) * time.Second
+		if rotationPeriod < minRotationPeriod {
+			return logical.ErrorResponse(fmt.Sprintf("rotation_period must be at least %s", minRotationPeriod)), nil
+		}
+
+		dbConfig, err := b.DatabaseConfig(req.Storage, dbName)
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+		if !strutil.StrListContains(dbConfig.AllowedRoles, "*") && !strutil.StrListContains(dbConfig.AllowedRoles, name) {
+			return logical.ErrorResponse(fmt.Sprintf("%q is not an allowed role for database %q", name, dbName)), nil
+		}
+
+		lock := locksutil.LockForKey(b.roleLocks, name)
+		lock.Lock()
+		defer lock.Unlock()
+
+		existing, err := b.StaticRole(req.Storage, name)
+		if err != nil {
+			return nil, err
+		}
+
+		role := &staticRoleEntry{
+			DBName:         dbName,
+			Username:       username,
+			RotationPeriod: rotationPeriod,
+			Statements: dbplugin.Statements{
+				RotationStatements: data.Get("rotation_statements").(string),
+			},
+		}
+
+		// Keep the current credentials if the role still refers to the same
+		// user, otherwise take ownership of the new user's password right away
+		if existing != nil && existing.DBName == dbName && existing.Username == username {
+			role.Password = existing.Password
+			role.LastVaultRotation = existing.LastVaultRotation
+
+			entry, err := logical.StorageEntryJSON(staticRolePath+name, role)
+			if err != nil {
+				return nil, err
+			}
+			if err := req.Storage.Put(entry); err != nil {
+				return nil, err
+			}
+		} else {
+			if err := b.setStaticRoleCredentials(req.Storage, name, role); err != nil {
+				return logical.ErrorResponse(fmt.Sprintf("failed to set credentials for user %q: %s", username, err)), nil
+			}
+		}
+
+		if err := b.scheduleRotation(name, role); err != nil {
+			return nil, err
+		}
+
+		return nil, nil
+	}
+}
+
+type staticRoleEntry struct {
+	DBName            string              `json:"db_name" mapstructure:"db_name" structs:"db_name"`
+	Username          string              `json:"username" mapstructure:"username" structs:"username"`
+	Password          string              `json:"password" mapstructure:"password" structs:"password"`
+	Statements        dbplugin.Statements `json:"statements" mapstructure:"statements" structs:"statements"`
+	RotationPeriod    time.Duration       `json:"rotation_period" mapstructure:"rotation_period" structs:"rotation_period"`
+	LastVaultRotation time.Time           `json:"last_vault_rotation" mapstructure:"last_vault_rotation" structs:"last_vault_rotation"`
+}
+
+// NextRotationTime returns the time at which the role's credentials are due
+// to be rotated
+func (r *staticRoleEntry) NextRotationTime() time.Time {
+	return r.LastVaultRotation.Add(r.RotationPeriod)
+}
+
+const pathStaticRoleHelpSyn = `
+Manage the static roles that can be created with this backend.
+`
+
+const pathStaticRoleHelpDesc = `
+This path lets you manage the static roles of this backend. A static role binds
+to an existing database user whose password is owned and periodically rotated
+by Vault. The current password can be read from the "static-creds/" path.
+
+The "db_name" parameter is required and configures the name of the database
+connection to use. The role's name must be in the connection's allowed roles.
+
+The "username" parameter is required and is the name of the existing database
+user. The user's password is rotated as soon as the role is created.
+
+The "rotation_period" parameter sets how often the password is rotated. It
+defaults to 24 hours and must be at least one minute.
+
+The "rotation_statements" parameter customizes the statements used to set the
+user's password. The names of the variables must be surrounded by "{{" and "}}"
+to be replaced.
+
+  * "name" - The name of the database user.
+
+  * "password" - The new password for the user.
+
+Example of a decent rotation_statements for a postgresql database plugin:
+
+	ALTER ROLE "{{name}}" WITH PASSWORD '{{password}}';
+`
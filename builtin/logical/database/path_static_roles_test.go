@@ -0,0 +1,314 @@
+package database
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+	"github.com/hashicorp/vault/helper/pluginutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+	"github.com/hashicorp/vault/plugins"
+	"github.com/hashicorp/vault/plugins/helper/database/credsutil"
+	"github.com/hashicorp/vault/vault"
+)
+
+// mockStaticPlugin is a database plugin that accepts any user other than
+// "unknown" and returns a new random password on every rotation. If the
+// connection details set "state_file", the file stands in for the database's
+// copy of the root user's password: connections are only verified if
+// "password" matches its contents, and setting the credentials of "username"
+// updates it.
+type mockStaticPlugin struct {
+	config map[string]interface{}
+}
+
+func (m *mockStaticPlugin) Type() (string, error) { return "mock", nil }
+func (m *mockStaticPlugin) CreateUser(statements dbplugin.Statements, usernameConfig dbplugin.UsernameConfig, expiration time.Time) (username string, password string, err error) {
+	return "", "", errors.New("not supported")
+}
+func (m *mockStaticPlugin) RenewUser(statements dbplugin.Statements, username string, expiration time.Time) error {
+	return errors.New("not supported")
+}
+func (m *mockStaticPlugin) RevokeUser(statements dbplugin.Statements, username string) error {
+	return errors.New("not supported")
+}
+func (m *mockStaticPlugin) SetCredentials(statements dbplugin.Statements, staticConfig dbplugin.StaticUserConfig) (username string, password string, err error) {
+	if staticConfig.Username == "unknown" {
+		return "", "", errors.New("user does not exist")
+	}
+
+	password = staticConfig.Password
+	if password == "" {
+		password, err = credsutil.RandomAlphaNumeric(20)
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	if stateFile, _ := m.config["state_file"].(string); stateFile != "" && staticConfig.Username == m.config["username"] {
+		if err := ioutil.WriteFile(stateFile, []byte(password), 0600); err != nil {
+			return "", "", err
+		}
+	}
+
+	return staticConfig.Username, password, nil
+}
+func (m *mockStaticPlugin) Initialize(conf map[string]interface{}, verifyConnection bool) error {
+	m.config = conf
+
+	if stateFile, _ := conf["state_file"].(string); verifyConnection && stateFile != "" {
+		password, err := ioutil.ReadFile(stateFile)
+		if err != nil {
+			return err
+		}
+		if string(password) != conf["password"] {
+			return errors.New("authentication failed")
+		}
+	}
+
+	return nil
+}
+func (m *mockStaticPlugin) Close() error { return nil }
+
+func TestBackend_StaticPluginMain(t *testing.T) {
+	if os.Getenv(pluginutil.PluginUnwrapTokenEnv) == "" {
+		return
+	}
+
+	caPEM := os.Getenv(pluginutil.PluginCACertPEMEnv)
+	if caPEM == "" {
+		t.Fatal("CA cert not passed in")
+	}
+
+	args := []string{"--ca-cert=" + caPEM}
+
+	apiClientMeta := &pluginutil.APIClientMeta{}
+	flags := apiClientMeta.FlagSet()
+	flags.Parse(args)
+
+	plugins.Serve(&mockStaticPlugin{}, apiClientMeta.GetTLSConfig())
+}
+
+func TestBackend_StaticRoles(t *testing.T) {
+	cluster, sys := getCluster(t)
+	defer cluster.Cleanup()
+
+	vault.TestAddTestPlugin(t, cluster.Cores[0].Core, "mock-database-plugin", "TestBackend_StaticPluginMain")
+
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	config.System = sys
+	lb, err := Factory(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := lb.(*databaseBackend)
+	defer b.Cleanup()
+
+	handle := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		resp, err := b.HandleRequest(&logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   config.StorageView,
+			Data:      data,
+		})
+		if err != nil {
+			t.Fatalf("%s %s: err: %s", op, path, err)
+		}
+		return resp
+	}
+
+	resp := handle(logical.UpdateOperation, "config/mockdb", map[string]interface{}{
+		"plugin_name":   "mock-database-plugin",
+		"allowed_roles": []string{"static", "unknown"},
+	})
+	if resp != nil && resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	// Invalid roles
+	for _, data := range []map[string]interface{}{
+		// Missing username
+		{"db_name": "mockdb"},
+		// Rotation period too short
+		{"db_name": "mockdb", "username": "vaultuser", "rotation_period": "30s"},
+		// Unknown database
+		{"db_name": "otherdb", "username": "vaultuser"},
+	} {
+		resp = handle(logical.UpdateOperation, "static-roles/static", data)
+		if resp == nil || !resp.IsError() {
+			t.Fatalf("expected error for %#v, got: %#v", data, resp)
+		}
+	}
+
+	// Role not allowed by the database
+	resp = handle(logical.UpdateOperation, "static-roles/other", map[string]interface{}{
+		"db_name":  "mockdb",
+		"username": "vaultuser",
+	})
+	if resp == nil || !resp.IsError() || !strings.Contains(resp.Data["error"].(string), "not an allowed role") {
+		t.Fatalf("expected error, got: %#v", resp)
+	}
+
+	// The initial rotation fails, so the role should not be stored
+	resp = handle(logical.UpdateOperation, "static-roles/unknown", map[string]interface{}{
+		"db_name":  "mockdb",
+		"username": "unknown",
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error, got: %#v", resp)
+	}
+	if resp = handle(logical.ReadOperation, "static-roles/unknown", nil); resp != nil {
+		t.Fatalf("expected no role, got: %#v", resp)
+	}
+
+	resp = handle(logical.UpdateOperation, "static-roles/static", map[string]interface{}{
+		"db_name":         "mockdb",
+		"username":        "vaultuser",
+		"rotation_period": "1h",
+	})
+	if resp != nil && resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	resp = handle(logical.ReadOperation, "static-roles/static", nil)
+	if resp.Data["username"] != "vaultuser" || resp.Data["rotation_period"] != float64(3600) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	if _, ok := resp.Data["password"]; ok {
+		t.Fatal("role should not return the password")
+	}
+
+	resp = handle(logical.ListOperation, "static-roles/", nil)
+	if keys := resp.Data["keys"].([]string); len(keys) != 1 || keys[0] != "static" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// The password is set when the role is created
+	resp = handle(logical.ReadOperation, "static-creds/static", nil)
+	password := resp.Data["password"].(string)
+	if resp.Data["username"] != "vaultuser" || password == "" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	if ttl := resp.Data["ttl"].(int64); ttl <= 3500 || ttl > 3600 {
+		t.Fatalf("bad ttl: %d", ttl)
+	}
+
+	// Updating the rotation period keeps the current password
+	handle(logical.UpdateOperation, "static-roles/static", map[string]interface{}{
+		"db_name":         "mockdb",
+		"username":        "vaultuser",
+		"rotation_period": "2h",
+	})
+	resp = handle(logical.ReadOperation, "static-creds/static", nil)
+	if resp.Data["password"] != password {
+		t.Fatal("password should not have changed")
+	}
+	if ttl := resp.Data["ttl"].(int64); ttl <= 3600 {
+		t.Fatalf("bad ttl: %d", ttl)
+	}
+
+	// Manual rotation
+	handle(logical.UpdateOperation, "rotate-role/static", nil)
+	resp = handle(logical.ReadOperation, "static-creds/static", nil)
+	newPassword := resp.Data["password"].(string)
+	if newPassword == password {
+		t.Fatal("password should have been rotated")
+	}
+	password = newPassword
+
+	resp = handle(logical.UpdateOperation, "rotate-role/missing", nil)
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error, got: %#v", resp)
+	}
+
+	// Nothing is due yet, so the periodic function should not rotate
+	if err := b.rotateDueStaticRoles(config.StorageView); err != nil {
+		t.Fatal(err)
+	}
+	resp = handle(logical.ReadOperation, "static-creds/static", nil)
+	if resp.Data["password"] != password {
+		t.Fatal("password should not have been rotated")
+	}
+
+	// Move the last rotation into the past and reschedule the role
+	role, err := b.StaticRole(config.StorageView, "static")
+	if err != nil {
+		t.Fatal(err)
+	}
+	role.LastVaultRotation = time.Now().Add(-3 * time.Hour)
+	entry, err := logical.StorageEntryJSON(staticRolePath+"static", role)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := config.StorageView.Put(entry); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.scheduleRotation("static", role); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.rotateDueStaticRoles(config.StorageView); err != nil {
+		t.Fatal(err)
+	}
+	resp = handle(logical.ReadOperation, "static-creds/static", nil)
+	if resp.Data["password"] == password {
+		t.Fatal("password should have been rotated")
+	}
+	if ttl := resp.Data["ttl"].(int64); ttl <= 7000 {
+		t.Fatalf("bad ttl: %d", ttl)
+	}
+	if b.rotationSchedule.Len() != 1 {
+		t.Fatalf("bad schedule length: %d", b.rotationSchedule.Len())
+	}
+
+	// Only the failed rotation of the unknown role left a WAL entry behind
+	walKeys, err := framework.ListWAL(config.StorageView)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(walKeys) != 1 {
+		t.Fatalf("expected 1 WAL entry, got %d", len(walKeys))
+	}
+
+	// An interrupted rotation is completed with the password from the WAL
+	// entry, unless the role was rotated since or was never stored
+	password = resp.Data["password"].(string)
+	for _, wal := range []*rotateStaticRoleWAL{
+		{RoleName: "static", Username: "vaultuser", OldPassword: "stale", NewPassword: "ignored"},
+		{RoleName: "static", Username: "vaultuser", OldPassword: password, NewPassword: "fromwal"},
+	} {
+		if _, err := framework.PutWAL(config.StorageView, walRotateStaticRole, wal); err != nil {
+			t.Fatal(err)
+		}
+	}
+	handle(logical.RollbackOperation, "", map[string]interface{}{
+		"immediate": true,
+	})
+	resp = handle(logical.ReadOperation, "static-creds/static", nil)
+	if resp.Data["password"] != "fromwal" {
+		t.Fatalf("expected the password from the WAL entry, got %q", resp.Data["password"])
+	}
+	walKeys, err = framework.ListWAL(config.StorageView)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(walKeys) != 0 {
+		t.Fatalf("WAL entries should have been deleted, got %d", len(walKeys))
+	}
+
+	// Deleting the role removes it from the schedule
+	handle(logical.DeleteOperation, "static-roles/static", nil)
+	if b.rotationSchedule.Len() != 0 {
+		t.Fatalf("bad schedule length: %d", b.rotationSchedule.Len())
+	}
+	resp = handle(logical.ReadOperation, "static-creds/static", nil)
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error, got: %#v", resp)
+	}
+}
@@ -0,0 +1,145 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+	"github.com/hashicorp/vault/helper/locksutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/mitchellh/mapstructure"
+)
+
+const (
+	walRotateRootCredentials = "rotateRootCredentials"
+	walRotateStaticRole      = "rotateStaticRole"
+)
+
+// rotateRootCredentialsWAL is written before the root password of a
+// connection is changed and deleted once the new password has been stored
+type rotateRootCredentialsWAL struct {
+	ConnectionName string `json:"connection_name" mapstructure:"connection_name"`
+	Username       string `json:"username" mapstructure:"username"`
+	NewPassword    string `json:"new_password" mapstructure:"new_password"`
+}
+
+// rotateStaticRoleWAL is written before the password of the user of a static
+// role is changed and deleted once the new password has been stored
+type rotateStaticRoleWAL struct {
+	RoleName    string `json:"role_name" mapstructure:"role_name"`
+	Username    string `json:"username" mapstructure:"username"`
+	OldPassword string `json:"old_password" mapstructure:"old_password"`
+	NewPassword string `json:"new_password" mapstructure:"new_password"`
+}
+
+func (b *databaseBackend) walRollback(req *logical.Request, kind string, data interface{}) error {
+	switch kind {
+	case walRotateRootCredentials:
+		return b.rotateRootCredentialsRollback(req, data)
+	case walRotateStaticRole:
+		return b.rotateStaticRoleRollback(req, data)
+	default:
+		return fmt.Errorf("unknown type to rollback")
+	}
+}
+
+// rotateRootCredentialsRollback reconciles the stored root credentials of a
+// connection with the database after an interrupted rotation. If the stored
+// credentials no longer work but the new password from the WAL entry does,
+// the rotation reached the database, and the new password is stored.
+func (b *databaseBackend) rotateRootCredentialsRollback(req *logical.Request, data interface{}) error {
+	var entry rotateRootCredentialsWAL
+	if err := mapstructure.Decode(data, &entry); err != nil {
+		return err
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	configEntry, err := req.Storage.Get(fmt.Sprintf("config/%s", entry.ConnectionName))
+	if err != nil {
+		return err
+	}
+	if configEntry == nil {
+		// The connection has been deleted
+		return nil
+	}
+
+	var config DatabaseConfig
+	if err := configEntry.DecodeJSON(&config); err != nil {
+		return err
+	}
+
+	if config.ConnectionDetails["password"] == entry.NewPassword {
+		// The rotation completed
+		return nil
+	}
+
+	if err := b.verifyConnectionDetails(config.PluginName, config.ConnectionDetails); err == nil {
+		// The stored credentials are still valid
+		return nil
+	}
+
+	newDetails := make(map[string]interface{}, len(config.ConnectionDetails))
+	for k, v := range config.ConnectionDetails {
+		newDetails[k] = v
+	}
+	newDetails["password"] = entry.NewPassword
+
+	if err := b.verifyConnectionDetails(config.PluginName, newDetails); err != nil {
+		return fmt.Errorf("unable to connect to database %s with either the stored or rotated root credentials: %s", entry.ConnectionName, err)
+	}
+
+	config.ConnectionDetails = newDetails
+	newEntry, err := logical.StorageEntryJSON(fmt.Sprintf("config/%s", entry.ConnectionName), &config)
+	if err != nil {
+		return err
+	}
+	if err := req.Storage.Put(newEntry); err != nil {
+		return err
+	}
+
+	b.clearConnection(entry.ConnectionName)
+
+	return nil
+}
+
+// rotateStaticRoleRollback completes an interrupted rotation of the password
+// of a static role. The database may or may not have the new password from
+// the WAL entry, so it is set again and stored, unless the role has been
+// deleted, bound to another user or rotated since.
+func (b *databaseBackend) rotateStaticRoleRollback(req *logical.Request, data interface{}) error {
+	var entry rotateStaticRoleWAL
+	if err := mapstructure.Decode(data, &entry); err != nil {
+		return err
+	}
+
+	lock := locksutil.LockForKey(b.roleLocks, entry.RoleName)
+	lock.Lock()
+	defer lock.Unlock()
+
+	role, err := b.StaticRole(req.Storage, entry.RoleName)
+	if err != nil {
+		return err
+	}
+	if role == nil || role.Username != entry.Username || role.Password != entry.OldPassword {
+		return nil
+	}
+
+	if err := b.setStaticRolePassword(req.Storage, entry.RoleName, role, entry.NewPassword); err != nil {
+		return fmt.Errorf("failed to set the rotated password of static role %s: %s", entry.RoleName, err)
+	}
+
+	return b.scheduleRotation(entry.RoleName, role)
+}
+
+// verifyConnectionDetails runs a new instance of the plugin and checks that it
+// is able to connect to the database with the given connection details
+func (b *databaseBackend) verifyConnectionDetails(pluginName string, connectionDetails map[string]interface{}) error {
+	db, err := dbplugin.PluginFactory(pluginName, b.System(), b.logger)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.Initialize(connectionDetails, true)
+}
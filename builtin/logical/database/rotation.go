@@ -0,0 +1,184 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+	"github.com/hashicorp/vault/helper/consts"
+	"github.com/hashicorp/vault/helper/locksutil"
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+// periodicFunc is invoked by the RollbackManager, which only runs on the
+// active node, once a minute. It rotates the credentials of every static role
+// whose rotation period has elapsed. Failures are logged rather than returned,
+// since an error from the periodic function prevents WAL rollbacks.
+func (b *databaseBackend) periodicFunc(req *logical.Request) error {
+	if err := b.rotateDueStaticRoles(req.Storage); err != nil {
+		b.logger.Error("database: failed to rotate static role credentials", "error", err)
+	}
+
+	return nil
+}
+
+func (b *databaseBackend) rotateDueStaticRoles(s logical.Storage) error {
+	// Credentials are rotated on the primary and replicated to secondaries
+	if b.System().ReplicationState() == consts.ReplicationSecondary {
+		return nil
+	}
+
+	return b.rotationSchedule.RunDue(func() error {
+		return b.loadStaticRoles(s)
+	}, func(name string) error {
+		if err := b.rotateStaticRole(s, name); err != nil {
+			return fmt.Errorf("failed to rotate credentials of static role %s: %v", name, err)
+		}
+		return nil
+	})
+}
+
+// loadStaticRoles schedules the rotation of every static role in storage
+func (b *databaseBackend) loadStaticRoles(s logical.Storage) error {
+	names, err := s.List(staticRolePath)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		lock := locksutil.LockForKey(b.roleLocks, name)
+		lock.RLock()
+		role, err := b.StaticRole(s, name)
+		if err == nil && role != nil {
+			err = b.scheduleRotation(name, role)
+		}
+		lock.RUnlock()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rotateStaticRole rotates the credentials of the named static role if they
+// are due, and schedules the next rotation
+func (b *databaseBackend) rotateStaticRole(s logical.Storage, name string) error {
+	lock := locksutil.LockForKey(b.roleLocks, name)
+	lock.Lock()
+	defer lock.Unlock()
+
+	role, err := b.StaticRole(s, name)
+	if err != nil {
+		return err
+	}
+	if role == nil {
+		// The role has been deleted
+		return nil
+	}
+
+	// The role may have been rotated or updated since it was scheduled
+	if role.NextRotationTime().Before(time.Now()) {
+		if err := b.setStaticRoleCredentials(s, name, role); err != nil {
+			return err
+		}
+	}
+
+	return b.scheduleRotation(name, role)
+}
+
+// scheduleRotation schedules the next rotation of the role, replacing any
+// existing entry. The caller must hold the role's lock.
+func (b *databaseBackend) scheduleRotation(name string, role *staticRoleEntry) error {
+	return b.rotationSchedule.Schedule(name, role.NextRotationTime())
+}
+
+// setStaticRoleCredentials sets a new password for the role's database user
+// and stores it. The caller must hold the role's write lock.
+func (b *databaseBackend) setStaticRoleCredentials(s logical.Storage, name string, role *staticRoleEntry) error {
+	dbConfig, err := b.DatabaseConfig(s, role.DBName)
+	if err != nil {
+		return err
+	}
+	if !strutil.StrListContains(dbConfig.AllowedRoles, "*") && !strutil.StrListContains(dbConfig.AllowedRoles, name) {
+		return fmt.Errorf("%q is not an allowed role for database %q", name, role.DBName)
+	}
+
+	newPassword, err := b.generatePassword(s, dbConfig)
+	if err != nil {
+		return err
+	}
+
+	// Write the new password to the WAL before changing it, so that it
+	// can be recovered if we fail to store it below
+	walID, err := framework.PutWAL(s, walRotateStaticRole, &rotateStaticRoleWAL{
+		RoleName:    name,
+		Username:    role.Username,
+		OldPassword: role.Password,
+		NewPassword: newPassword,
+	})
+	if err != nil {
+		return fmt.Errorf("error writing WAL entry: %s", err)
+	}
+
+	// Whether or not the password was changed, the WAL entry is left in
+	// place on failure for the rollback to set the password again
+	if err := b.setStaticRolePassword(s, name, role, newPassword); err != nil {
+		return err
+	}
+
+	// The rotation is complete; if the WAL entry can't be removed the
+	// rollback will find that a newer password is already stored
+	if err := framework.DeleteWAL(s, walID); err != nil {
+		b.logger.Warn("database: failed to delete WAL entry for static role rotation", "role", name, "error", err)
+	}
+
+	return nil
+}
+
+// setStaticRolePassword sets the password of the role's database user and
+// stores it. The caller must hold the role's write lock.
+func (b *databaseBackend) setStaticRolePassword(s logical.Storage, name string, role *staticRoleEntry, password string) error {
+	// Grab the read lock
+	b.RLock()
+	var unlockFunc func() = b.RUnlock
+
+	// Get the Database object
+	db, ok := b.getDBObj(role.DBName)
+	if !ok {
+		// Upgrade lock
+		b.RUnlock()
+		b.Lock()
+		unlockFunc = b.Unlock
+
+		// Create a new DB object
+		var err error
+		db, err = b.createDBObj(s, role.DBName)
+		if err != nil {
+			unlockFunc()
+			return fmt.Errorf("cound not retrieve db with name: %s, got error: %s", role.DBName, err)
+		}
+	}
+
+	_, _, err := db.SetCredentials(role.Statements, dbplugin.StaticUserConfig{
+		Username: role.Username,
+		Password: password,
+	})
+	// Unlock
+	unlockFunc()
+	if err != nil {
+		b.closeIfShutdown(role.DBName, err)
+		return err
+	}
+
+	role.Password = password
+	role.LastVaultRotation = time.Now()
+
+	entry, err := logical.StorageEntryJSON(staticRolePath+name, role)
+	if err != nil {
+		return err
+	}
+	return s.Put(entry)
+}
@@ -0,0 +1,303 @@
+package kubernetes
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func getBackend(t *testing.T) (*backend, logical.Storage) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	b := Backend()
+	if err := b.Setup(config); err != nil {
+		t.Fatal(err)
+	}
+	return b, config.StorageView
+}
+
+func request(t *testing.T, b *backend, s logical.Storage, op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+	resp, err := b.HandleRequest(&logical.Request{
+		Operation: op,
+		Path:      path,
+		Storage:   s,
+		Data:      data,
+	})
+	if err != nil {
+		t.Fatalf("%s %s: err: %s", op, path, err)
+	}
+	return resp
+}
+
+func configure(t *testing.T, b *backend, s logical.Storage, stub *kubernetesStub) {
+	resp := request(t, b, s, logical.UpdateOperation, "config", map[string]interface{}{
+		"kubernetes_host":      stub.server.URL,
+		"service_account_jwt":  stubJWT,
+		"disable_local_ca_jwt": true,
+	})
+	if resp != nil && resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+}
+
+func TestBackend_config(t *testing.T) {
+	b, s := getBackend(t)
+
+	for _, data := range []map[string]interface{}{
+		{},
+		{"kubernetes_host": "192.168.99.100:8443"},
+		{"kubernetes_host": "https://192.168.99.100:8443", "kubernetes_ca_cert": "invalid"},
+	} {
+		resp := request(t, b, s, logical.UpdateOperation, "config", data)
+		if resp == nil || !resp.IsError() {
+			t.Fatalf("expected an error for %v, got: %#v", data, resp)
+		}
+	}
+
+	request(t, b, s, logical.UpdateOperation, "config", map[string]interface{}{
+		"kubernetes_host":     "https://192.168.99.100:8443",
+		"service_account_jwt": "secret",
+	})
+	resp := request(t, b, s, logical.ReadOperation, "config", nil)
+	if resp == nil || resp.Data["kubernetes_host"] != "https://192.168.99.100:8443" {
+		t.Fatalf("bad: %#v", resp)
+	}
+	if _, ok := resp.Data["service_account_jwt"]; ok {
+		t.Fatal("service_account_jwt should not be returned")
+	}
+}
+
+func TestBackend_roleValidation(t *testing.T) {
+	b, s := getBackend(t)
+
+	for _, data := range []map[string]interface{}{
+		{"service_account_name": "default"},
+		{"allowed_kubernetes_namespaces": "*"},
+		{"allowed_kubernetes_namespaces": "*", "service_account_name": "default", "kubernetes_role_name": "edit"},
+		{"allowed_kubernetes_namespaces": "*", "kubernetes_role_name": "edit", "kubernetes_role_type": "Group"},
+		{"allowed_kubernetes_namespaces": "*", "service_account_name": "default", "token_default_ttl": 60},
+		{"allowed_kubernetes_namespaces": "*", "service_account_name": "default", "token_default_ttl": 7200, "token_max_ttl": 3600},
+	} {
+		resp := request(t, b, s, logical.UpdateOperation, "roles/invalid", data)
+		if resp == nil || !resp.IsError() {
+			t.Fatalf("expected an error for %v, got: %#v", data, resp)
+		}
+	}
+
+	request(t, b, s, logical.UpdateOperation, "roles/editor", map[string]interface{}{
+		"allowed_kubernetes_namespaces": "dev,test",
+		"kubernetes_role_name":          "edit",
+		"kubernetes_role_type":          "clusterrole",
+		"token_default_ttl":             "1h",
+		"token_default_audiences":       "vault,api",
+	})
+	resp := request(t, b, s, logical.ReadOperation, "roles/editor", nil)
+	if resp == nil || resp.Data["kubernetes_role_type"] != "ClusterRole" || resp.Data["token_default_ttl"] != int64(3600) {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	// Switch to an existing service account
+	request(t, b, s, logical.UpdateOperation, "roles/editor", map[string]interface{}{
+		"kubernetes_role_name": "",
+		"service_account_name": "default",
+	})
+	resp = request(t, b, s, logical.ReadOperation, "roles/editor", nil)
+	if resp.Data["service_account_name"] != "default" || resp.Data["kubernetes_role_name"] != "" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	if namespaces := resp.Data["allowed_kubernetes_namespaces"].([]string); len(namespaces) != 2 {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	resp = request(t, b, s, logical.ListOperation, "roles/", nil)
+	if keys := resp.Data["keys"].([]string); len(keys) != 1 || keys[0] != "editor" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	request(t, b, s, logical.DeleteOperation, "roles/editor", nil)
+	if resp := request(t, b, s, logical.ReadOperation, "roles/editor", nil); resp != nil {
+		t.Fatalf("role should have been deleted: %#v", resp)
+	}
+}
+
+func TestBackend_existingServiceAccount(t *testing.T) {
+	stub := newKubernetesStub()
+	defer stub.Close()
+
+	b, s := getBackend(t)
+	configure(t, b, s, stub)
+
+	request(t, b, s, logical.UpdateOperation, "roles/reader", map[string]interface{}{
+		"allowed_kubernetes_namespaces": "dev",
+		"service_account_name":          "default",
+		"token_default_audiences":       "vault",
+	})
+
+	resp := request(t, b, s, logical.UpdateOperation, "creds/reader", map[string]interface{}{
+		"kubernetes_namespace": "prod",
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error for a namespace that isn't allowed, got: %#v", resp)
+	}
+	resp = request(t, b, s, logical.UpdateOperation, "creds/reader", map[string]interface{}{
+		"kubernetes_namespace": "dev",
+		"ttl":                  60,
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error for a ttl under 10 minutes, got: %#v", resp)
+	}
+
+	resp = request(t, b, s, logical.UpdateOperation, "creds/reader", map[string]interface{}{
+		"kubernetes_namespace": "dev",
+		"ttl":                  "30m",
+	})
+	if resp == nil || resp.IsError() || resp.Secret == nil {
+		t.Fatalf("bad: %#v", resp)
+	}
+	if resp.Data["service_account_name"] != "default" || resp.Data["service_account_namespace"] != "dev" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	if resp.Secret.Renewable {
+		t.Fatal("service account tokens should not be renewable")
+	}
+	if resp.Secret.TTL > 30*time.Minute || resp.Secret.TTL < 29*time.Minute {
+		t.Fatalf("bad ttl: %s", resp.Secret.TTL)
+	}
+
+	token := stub.Token(resp.Data["service_account_token"].(string))
+	if token == nil {
+		t.Fatal("token was not created")
+	}
+	if token.Spec.ExpirationSeconds != 1800 || len(token.Spec.Audiences) != 1 || token.Spec.Audiences[0] != "vault" {
+		t.Fatalf("bad token request: %#v", token.Spec)
+	}
+
+	// Revoking leaves the existing service account alone
+	_, err := b.HandleRequest(&logical.Request{
+		Operation: logical.RevokeOperation,
+		Storage:   s,
+		Secret:    resp.Secret,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if serviceAccounts, roleBindings := stub.Counts(); serviceAccounts != 0 || roleBindings != 0 {
+		t.Fatalf("unexpected objects: %d service accounts, %d role bindings", serviceAccounts, roleBindings)
+	}
+}
+
+func TestBackend_temporaryServiceAccount(t *testing.T) {
+	stub := newKubernetesStub()
+	defer stub.Close()
+
+	b, s := getBackend(t)
+	configure(t, b, s, stub)
+
+	request(t, b, s, logical.UpdateOperation, "roles/Deploy_Role", map[string]interface{}{
+		"allowed_kubernetes_namespaces": "*",
+		"kubernetes_role_name":          "edit",
+		"kubernetes_role_type":          "ClusterRole",
+		"token_max_ttl":                 "1h",
+	})
+
+	resp := request(t, b, s, logical.UpdateOperation, "creds/Deploy_Role", map[string]interface{}{
+		"kubernetes_namespace": "prod",
+		"ttl":                  "2h",
+		"audiences":            "api",
+	})
+	if resp == nil || resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+	if len(resp.Warnings) != 1 || resp.Secret.TTL > time.Hour {
+		t.Fatalf("ttl should have been capped: %#v", resp)
+	}
+
+	name := resp.Data["service_account_name"].(string)
+	if !strings.HasPrefix(name, "v-deploy-role-") {
+		t.Fatalf("bad service account name: %q", name)
+	}
+	sa := stub.ServiceAccount("prod", name)
+	if sa == nil || sa.Metadata.Labels[managedByLabel] != managedByValue {
+		t.Fatalf("bad service account: %#v", sa)
+	}
+	rb := stub.RoleBinding("prod", name)
+	if rb == nil {
+		t.Fatal("role binding was not created")
+	}
+	if rb.RoleRef.Kind != "ClusterRole" || rb.RoleRef.Name != "edit" || len(rb.Subjects) != 1 || rb.Subjects[0].Name != name || rb.Subjects[0].Namespace != "prod" {
+		t.Fatalf("bad role binding: %#v", rb)
+	}
+	if stub.Token(resp.Data["service_account_token"].(string)) == nil {
+		t.Fatal("token was not created")
+	}
+
+	_, err := b.HandleRequest(&logical.Request{
+		Operation: logical.RevokeOperation,
+		Storage:   s,
+		Secret:    resp.Secret,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if serviceAccounts, roleBindings := stub.Counts(); serviceAccounts != 0 || roleBindings != 0 {
+		t.Fatalf("objects should have been deleted: %d service accounts, %d role bindings", serviceAccounts, roleBindings)
+	}
+
+	// Revoking again is a no-op
+	_, err = b.HandleRequest(&logical.Request{
+		Operation: logical.RevokeOperation,
+		Storage:   s,
+		Secret:    resp.Secret,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The objects are cleaned up if the token can't be created
+	stub.failTokens = true
+	_, err = b.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "creds/Deploy_Role",
+		Storage:   s,
+		Data: map[string]interface{}{
+			"kubernetes_namespace": "prod",
+		},
+	})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if serviceAccounts, roleBindings := stub.Counts(); serviceAccounts != 0 || roleBindings != 0 {
+		t.Fatalf("objects should have been deleted: %d service accounts, %d role bindings", serviceAccounts, roleBindings)
+	}
+}
+
+func TestBackend_unauthorized(t *testing.T) {
+	stub := newKubernetesStub()
+	defer stub.Close()
+
+	b, s := getBackend(t)
+	request(t, b, s, logical.UpdateOperation, "config", map[string]interface{}{
+		"kubernetes_host":      stub.server.URL,
+		"service_account_jwt":  "invalid",
+		"disable_local_ca_jwt": true,
+	})
+	request(t, b, s, logical.UpdateOperation, "roles/reader", map[string]interface{}{
+		"allowed_kubernetes_namespaces": "dev",
+		"service_account_name":          "default",
+	})
+
+	_, err := b.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "creds/reader",
+		Storage:   s,
+		Data: map[string]interface{}{
+			"kubernetes_namespace": "dev",
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("expected an unauthorized error, got: %v", err)
+	}
+}
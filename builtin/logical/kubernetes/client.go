@@ -0,0 +1,261 @@
+package kubernetes
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-cleanhttp"
+)
+
+// The Kubernetes client libraries are far larger than the handful of API
+// calls the backend makes, so it talks to the API server with the minimal
+// client below.
+
+const (
+	rbacAPIGroup = "rbac.authorization.k8s.io"
+
+	// managedByLabel marks the objects created by Vault
+	managedByLabel = "app.kubernetes.io/managed-by"
+	managedByValue = "vault"
+)
+
+type objectMeta struct {
+	Name      string            `json:"name"`
+	Namespace string            `json:"namespace,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+}
+
+type serviceAccount struct {
+	APIVersion string     `json:"apiVersion"`
+	Kind       string     `json:"kind"`
+	Metadata   objectMeta `json:"metadata"`
+}
+
+type roleRef struct {
+	APIGroup string `json:"apiGroup"`
+	Kind     string `json:"kind"`
+	Name     string `json:"name"`
+}
+
+type subject struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+type roleBinding struct {
+	APIVersion string     `json:"apiVersion"`
+	Kind       string     `json:"kind"`
+	Metadata   objectMeta `json:"metadata"`
+	RoleRef    roleRef    `json:"roleRef"`
+	Subjects   []subject  `json:"subjects"`
+}
+
+type tokenRequestSpec struct {
+	Audiences         []string `json:"audiences,omitempty"`
+	ExpirationSeconds int64    `json:"expirationSeconds"`
+}
+
+type tokenRequestStatus struct {
+	Token               string    `json:"token"`
+	ExpirationTimestamp time.Time `json:"expirationTimestamp"`
+}
+
+type tokenRequest struct {
+	APIVersion string              `json:"apiVersion"`
+	Kind       string              `json:"kind"`
+	Spec       tokenRequestSpec    `json:"spec"`
+	Status     *tokenRequestStatus `json:"status,omitempty"`
+}
+
+// apiStatus is the body of the error responses of the API server
+type apiStatus struct {
+	Message string `json:"message"`
+}
+
+// apiError is an error response of the API server
+type apiError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("Kubernetes API returned %d: %s", e.StatusCode, e.Message)
+}
+
+// isNotFound returns whether the error is a response to a request for an
+// object that doesn't exist
+func isNotFound(err error) bool {
+	apiErr, ok := err.(*apiError)
+	return ok && apiErr.StatusCode == http.StatusNotFound
+}
+
+type apiClient struct {
+	host   string
+	jwt    string
+	client *http.Client
+}
+
+func newAPIClient(conf *configEntry) (*apiClient, error) {
+	httpClient := cleanhttp.DefaultClient()
+
+	caCert, jwt, err := conf.credentials()
+	if err != nil {
+		return nil, err
+	}
+	if caCert != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(caCert)) {
+			return nil, fmt.Errorf("failed to parse the Kubernetes CA certificate")
+		}
+		transport := cleanhttp.DefaultTransport()
+		transport.TLSClientConfig = &tls.Config{
+			RootCAs:    pool,
+			MinVersion: tls.VersionTLS12,
+		}
+		httpClient.Transport = transport
+	}
+
+	return &apiClient{
+		host:   strings.TrimSuffix(conf.Host, "/"),
+		jwt:    jwt,
+		client: httpClient,
+	}, nil
+}
+
+// do sends a request to the API server, with the body encoded from in, and
+// decodes the response into out, if given
+func (c *apiClient) do(method, path string, in, out interface{}) error {
+	var body bytes.Buffer
+	if in != nil {
+		if err := json.NewEncoder(&body).Encode(in); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, c.host+path, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if c.jwt != "" {
+		req.Header.Set("Authorization", "Bearer "+c.jwt)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := ioutil.ReadAll(resp.Body)
+		var status apiStatus
+		if err := json.Unmarshal(message, &status); err == nil && status.Message != "" {
+			return &apiError{StatusCode: resp.StatusCode, Message: status.Message}
+		}
+		return &apiError{StatusCode: resp.StatusCode, Message: string(bytes.TrimSpace(message))}
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func serviceAccountsPath(namespace string) string {
+	return "/api/v1/namespaces/" + url.PathEscape(namespace) + "/serviceaccounts"
+}
+
+func roleBindingsPath(namespace string) string {
+	return "/apis/" + rbacAPIGroup + "/v1/namespaces/" + url.PathEscape(namespace) + "/rolebindings"
+}
+
+// CreateServiceAccount creates a service account labeled as managed by Vault
+func (c *apiClient) CreateServiceAccount(namespace, name string) error {
+	return c.do("POST", serviceAccountsPath(namespace), &serviceAccount{
+		APIVersion: "v1",
+		Kind:       "ServiceAccount",
+		Metadata: objectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    map[string]string{managedByLabel: managedByValue},
+		},
+	}, nil)
+}
+
+// DeleteServiceAccount deletes the service account. Deleting a service account
+// that doesn't exist isn't an error.
+func (c *apiClient) DeleteServiceAccount(namespace, name string) error {
+	err := c.do("DELETE", serviceAccountsPath(namespace)+"/"+url.PathEscape(name), nil, nil)
+	if isNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// CreateRoleBinding binds the Role or ClusterRole to the service account in
+// the namespace
+func (c *apiClient) CreateRoleBinding(namespace, name, roleKind, roleName, serviceAccountName string) error {
+	return c.do("POST", roleBindingsPath(namespace), &roleBinding{
+		APIVersion: rbacAPIGroup + "/v1",
+		Kind:       "RoleBinding",
+		Metadata: objectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    map[string]string{managedByLabel: managedByValue},
+		},
+		RoleRef: roleRef{
+			APIGroup: rbacAPIGroup,
+			Kind:     roleKind,
+			Name:     roleName,
+		},
+		Subjects: []subject{
+			{
+				Kind:      "ServiceAccount",
+				Name:      serviceAccountName,
+				Namespace: namespace,
+			},
+		},
+	}, nil)
+}
+
+// DeleteRoleBinding deletes the role binding. Deleting a role binding that
+// doesn't exist isn't an error.
+func (c *apiClient) DeleteRoleBinding(namespace, name string) error {
+	err := c.do("DELETE", roleBindingsPath(namespace)+"/"+url.PathEscape(name), nil, nil)
+	if isNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// CreateToken requests a token of the service account with the TokenRequest
+// API
+func (c *apiClient) CreateToken(namespace, serviceAccountName string, ttl time.Duration, audiences []string) (*tokenRequestStatus, error) {
+	var result tokenRequest
+	err := c.do("POST", serviceAccountsPath(namespace)+"/"+url.PathEscape(serviceAccountName)+"/token", &tokenRequest{
+		APIVersion: "authentication.k8s.io/v1",
+		Kind:       "TokenRequest",
+		Spec: tokenRequestSpec{
+			Audiences:         audiences,
+			ExpirationSeconds: int64(ttl.Seconds()),
+		},
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status == nil || result.Status.Token == "" {
+		return nil, fmt.Errorf("Kubernetes API returned no token")
+	}
+	return result.Status, nil
+}
@@ -0,0 +1,188 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"time"
+)
+
+const stubJWT = "vault-service-account-jwt"
+
+var (
+	serviceAccountsRegex = regexp.MustCompile(`^/api/v1/namespaces/([^/]+)/serviceaccounts(?:/([^/]+))?$`)
+	tokenRequestRegex    = regexp.MustCompile(`^/api/v1/namespaces/([^/]+)/serviceaccounts/([^/]+)/token$`)
+	roleBindingsRegex    = regexp.MustCompile(`^/apis/rbac.authorization.k8s.io/v1/namespaces/([^/]+)/rolebindings(?:/([^/]+))?$`)
+)
+
+// kubernetesStub is a local stand-in for the parts of the Kubernetes API the
+// backend uses. It starts with the "default" service account in every
+// namespace, and keeps the objects and tokens it creates.
+type kubernetesStub struct {
+	sync.Mutex
+	server *httptest.Server
+
+	serviceAccounts map[string]*serviceAccount
+	roleBindings    map[string]*roleBinding
+	tokens          map[string]*tokenRequest
+
+	// failTokens makes token requests fail
+	failTokens bool
+}
+
+func newKubernetesStub() *kubernetesStub {
+	stub := &kubernetesStub{
+		serviceAccounts: make(map[string]*serviceAccount),
+		roleBindings:    make(map[string]*roleBinding),
+		tokens:          make(map[string]*tokenRequest),
+	}
+	stub.server = httptest.NewServer(http.HandlerFunc(stub.handle))
+	return stub
+}
+
+func (s *kubernetesStub) Close() {
+	s.server.Close()
+}
+
+func (s *kubernetesStub) ServiceAccount(namespace, name string) *serviceAccount {
+	s.Lock()
+	defer s.Unlock()
+	return s.serviceAccounts[namespace+"/"+name]
+}
+
+func (s *kubernetesStub) RoleBinding(namespace, name string) *roleBinding {
+	s.Lock()
+	defer s.Unlock()
+	return s.roleBindings[namespace+"/"+name]
+}
+
+func (s *kubernetesStub) Token(token string) *tokenRequest {
+	s.Lock()
+	defer s.Unlock()
+	return s.tokens[token]
+}
+
+func (s *kubernetesStub) Counts() (int, int) {
+	s.Lock()
+	defer s.Unlock()
+	return len(s.serviceAccounts), len(s.roleBindings)
+}
+
+func writeStatus(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(&apiStatus{Message: message})
+}
+
+func (s *kubernetesStub) handle(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer "+stubJWT {
+		writeStatus(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	switch {
+	case tokenRequestRegex.MatchString(r.URL.Path) && r.Method == "POST":
+		match := tokenRequestRegex.FindStringSubmatch(r.URL.Path)
+		namespace, name := match[1], match[2]
+		if name != "default" && s.serviceAccounts[namespace+"/"+name] == nil {
+			writeStatus(w, http.StatusNotFound, fmt.Sprintf("serviceaccounts %q not found", name))
+			return
+		}
+		if s.failTokens {
+			writeStatus(w, http.StatusInternalServerError, "token request failed")
+			return
+		}
+
+		var req tokenRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeStatus(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if req.Spec.ExpirationSeconds < 600 {
+			writeStatus(w, http.StatusUnprocessableEntity, "spec.expirationSeconds: Invalid value: may not specify a duration less than 10 minutes")
+			return
+		}
+
+		token := fmt.Sprintf("token-%s-%s-%d", namespace, name, len(s.tokens))
+		req.Status = &tokenRequestStatus{
+			Token:               token,
+			ExpirationTimestamp: time.Now().Add(time.Duration(req.Spec.ExpirationSeconds) * time.Second).UTC(),
+		}
+		s.tokens[token] = &req
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(&req)
+
+	case serviceAccountsRegex.MatchString(r.URL.Path):
+		match := serviceAccountsRegex.FindStringSubmatch(r.URL.Path)
+		namespace, name := match[1], match[2]
+		switch {
+		case r.Method == "POST" && name == "":
+			var sa serviceAccount
+			if err := json.NewDecoder(r.Body).Decode(&sa); err != nil {
+				writeStatus(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			key := namespace + "/" + sa.Metadata.Name
+			if _, ok := s.serviceAccounts[key]; ok {
+				writeStatus(w, http.StatusConflict, fmt.Sprintf("serviceaccounts %q already exists", sa.Metadata.Name))
+				return
+			}
+			s.serviceAccounts[key] = &sa
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(&sa)
+
+		case r.Method == "DELETE" && name != "":
+			key := namespace + "/" + name
+			if _, ok := s.serviceAccounts[key]; !ok {
+				writeStatus(w, http.StatusNotFound, fmt.Sprintf("serviceaccounts %q not found", name))
+				return
+			}
+			delete(s.serviceAccounts, key)
+			writeStatus(w, http.StatusOK, "")
+
+		default:
+			writeStatus(w, http.StatusMethodNotAllowed, "method not allowed")
+		}
+
+	case roleBindingsRegex.MatchString(r.URL.Path):
+		match := roleBindingsRegex.FindStringSubmatch(r.URL.Path)
+		namespace, name := match[1], match[2]
+		switch {
+		case r.Method == "POST" && name == "":
+			var rb roleBinding
+			if err := json.NewDecoder(r.Body).Decode(&rb); err != nil {
+				writeStatus(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			key := namespace + "/" + rb.Metadata.Name
+			if _, ok := s.roleBindings[key]; ok {
+				writeStatus(w, http.StatusConflict, fmt.Sprintf("rolebindings %q already exists", rb.Metadata.Name))
+				return
+			}
+			s.roleBindings[key] = &rb
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(&rb)
+
+		case r.Method == "DELETE" && name != "":
+			key := namespace + "/" + name
+			if _, ok := s.roleBindings[key]; !ok {
+				writeStatus(w, http.StatusNotFound, fmt.Sprintf("rolebindings %q not found", name))
+				return
+			}
+			delete(s.roleBindings, key)
+			writeStatus(w, http.StatusOK, "")
+
+		default:
+			writeStatus(w, http.StatusMethodNotAllowed, "method not allowed")
+		}
+
+	default:
+		writeStatus(w, http.StatusNotFound, "the server could not find the requested resource")
+	}
+}
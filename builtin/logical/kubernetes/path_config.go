@@ -0,0 +1,213 @@
+package kubernetes
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const configPath = "config"
+
+// When Vault runs in a pod, the CA certificate of the cluster and the token
+// of the pod's service account are mounted at these paths
+var (
+	localCACertPath = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+	localJWTPath    = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+)
+
+func pathConfig(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: configPath,
+		Fields: map[string]*framework.FieldSchema{
+			"kubernetes_host": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "URL of the Kubernetes API server (eg: https://192.168.99.100:8443)",
+			},
+
+			"kubernetes_ca_cert": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "PEM encoded CA certificate of the API server. Defaults to the CA certificate of the pod Vault runs in, if any, or the system's root certificates.",
+			},
+
+			"service_account_jwt": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Token Vault authenticates to the API server with. Defaults to the token of the service account of the pod Vault runs in, if any.",
+			},
+
+			"disable_local_ca_jwt": &framework.FieldSchema{
+				Type:        framework.TypeBool,
+				Description: "Don't default to the CA certificate and token of the pod Vault runs in",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathConfigRead,
+			logical.UpdateOperation: b.pathConfigWrite,
+		},
+
+		HelpSynopsis:    pathConfigHelpSyn,
+		HelpDescription: pathConfigHelpDesc,
+	}
+}
+
+type configEntry struct {
+	Host              string `json:"kubernetes_host"`
+	CACert            string `json:"kubernetes_ca_cert"`
+	ServiceAccountJWT string `json:"service_account_jwt"`
+	DisableLocalCAJWT bool   `json:"disable_local_ca_jwt"`
+}
+
+// credentials returns the CA certificate and token used to connect to the API
+// server, defaulting to the ones of the pod Vault runs in. They are read on
+// each use, since the token of the pod is rotated.
+func (c *configEntry) credentials() (string, string, error) {
+	caCert, jwt := c.CACert, c.ServiceAccountJWT
+	if c.DisableLocalCAJWT {
+		return caCert, jwt, nil
+	}
+
+	if caCert == "" {
+		local, err := readLocalFile(localCACertPath)
+		if err != nil {
+			return "", "", err
+		}
+		caCert = local
+	}
+	if jwt == "" {
+		local, err := readLocalFile(localJWTPath)
+		if err != nil {
+			return "", "", err
+		}
+		jwt = local
+	}
+
+	return caCert, jwt, nil
+}
+
+// readLocalFile returns the contents of the file, or an empty string if it
+// doesn't exist
+func readLocalFile(path string) (string, error) {
+	contents, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(contents), nil
+}
+
+// Config returns the configuration, or nil if it isn't set
+func (b *backend) Config(s logical.Storage) (*configEntry, error) {
+	entry, err := s.Get(configPath)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result configEntry
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// client returns a client of the configured API server, or an error response
+// if the backend isn't configured
+func (b *backend) client(s logical.Storage) (*apiClient, *logical.Response, error) {
+	conf, err := b.Config(s)
+	if err != nil {
+		return nil, nil, err
+	}
+	if conf == nil {
+		return nil, logical.ErrorResponse("the Kubernetes API server must be configured at \"config\" first"), nil
+	}
+
+	c, err := newAPIClient(conf)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return c, nil, nil
+}
+
+func (b *backend) pathConfigRead(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	conf, err := b.Config(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if conf == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"kubernetes_host":      conf.Host,
+			"kubernetes_ca_cert":   conf.CACert,
+			"disable_local_ca_jwt": conf.DisableLocalCAJWT,
+		},
+	}, nil
+}
+
+func (b *backend) pathConfigWrite(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	conf := &configEntry{
+		Host:              d.Get("kubernetes_host").(string),
+		CACert:            d.Get("kubernetes_ca_cert").(string),
+		ServiceAccountJWT: d.Get("service_account_jwt").(string),
+		DisableLocalCAJWT: d.Get("disable_local_ca_jwt").(bool),
+	}
+
+	if conf.Host == "" {
+		return logical.ErrorResponse("kubernetes_host is required"), nil
+	}
+	u, err := url.Parse(conf.Host)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return logical.ErrorResponse("kubernetes_host must be an http or https URL"), nil
+	}
+
+	if conf.CACert != "" {
+		block, _ := pem.Decode([]byte(conf.CACert))
+		if block == nil || block.Type != "CERTIFICATE" {
+			return logical.ErrorResponse("failed to decode PEM block in kubernetes_ca_cert"), nil
+		}
+		if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("failed to parse kubernetes_ca_cert: %s", err)), nil
+		}
+	}
+
+	entry, err := logical.StorageEntryJSON(configPath, conf)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(entry); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+const pathConfigHelpSyn = `
+Configure the Kubernetes API server the backend creates tokens with.
+`
+
+const pathConfigHelpDesc = `
+This path configures the URL of the Kubernetes API server, the CA certificate
+used to verify it, and the token Vault authenticates with. When Vault runs in a
+pod, the CA certificate and the token of the pod's service account are used by
+default, unless "disable_local_ca_jwt" is set.
+
+The service account Vault authenticates as must be allowed to create tokens of
+service accounts, and to create and delete the service accounts and role
+bindings of the roles that generate them.
+`
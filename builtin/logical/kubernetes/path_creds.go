@@ -0,0 +1,200 @@
+package kubernetes
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/helper/random"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+// invalidNameChars matches the characters of role names that can't be used
+// in the names of Kubernetes objects
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9-]`)
+
+func pathCreds(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "creds/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the role",
+			},
+
+			"kubernetes_namespace": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Namespace of the service account the token is created for",
+			},
+
+			"ttl": &framework.FieldSchema{
+				Type:        framework.TypeDurationSecond,
+				Description: "TTL of the token, of at least 10 minutes. Defaults to the role's token_default_ttl.",
+			},
+
+			"audiences": &framework.FieldSchema{
+				Type:        framework.TypeCommaStringSlice,
+				Description: "Audiences of the token. Defaults to the role's token_default_audiences.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathCredsCreate,
+		},
+
+		HelpSynopsis:    pathCredsHelpSyn,
+		HelpDescription: pathCredsHelpDesc,
+	}
+}
+
+// generatedName returns a new name for the temporary service account and
+// role binding of a token of the role
+func generatedName(roleName string) (string, error) {
+	g, err := random.NewStringGenerator(10, []random.CharsetRule{
+		{Charset: []rune(random.LowercaseCharset + random.NumericCharset), MinChars: 10},
+	})
+	if err != nil {
+		return "", err
+	}
+	suffix, err := g.Generate()
+	if err != nil {
+		return "", err
+	}
+
+	name := invalidNameChars.ReplaceAllString(strings.ToLower(roleName), "-")
+	if len(name) > 40 {
+		name = name[:40]
+	}
+
+	return fmt.Sprintf("v-%s-%s", name, suffix), nil
+}
+
+func (b *backend) pathCredsCreate(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	namespace := d.Get("kubernetes_namespace").(string)
+
+	role, err := b.Role(req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return logical.ErrorResponse(fmt.Sprintf("unknown role: %s", name)), nil
+	}
+
+	if namespace == "" {
+		return logical.ErrorResponse("kubernetes_namespace is required"), nil
+	}
+	if !role.namespaceAllowed(namespace) {
+		return logical.ErrorResponse(fmt.Sprintf("kubernetes_namespace %q is not allowed by role %q", namespace, name)), nil
+	}
+
+	// The TTL is capped by the role and the mount
+	var warnings []string
+	maxTTL := b.System().MaxLeaseTTL()
+	if role.MaxTTL > 0 && role.MaxTTL < maxTTL {
+		maxTTL = role.MaxTTL
+	}
+	ttl := time.Duration(d.Get("ttl").(int)) * time.Second
+	if ttl == 0 {
+		ttl = role.DefaultTTL
+	}
+	if ttl == 0 {
+		ttl = b.System().DefaultLeaseTTL()
+	}
+	if ttl > maxTTL {
+		warnings = append(warnings, fmt.Sprintf("ttl was capped to the maximum of %s", maxTTL))
+		ttl = maxTTL
+	}
+	if ttl < minTokenTTL {
+		return logical.ErrorResponse(fmt.Sprintf("ttl must be at least %s", minTokenTTL)), nil
+	}
+
+	audiences := d.Get("audiences").([]string)
+	if len(audiences) == 0 {
+		audiences = role.DefaultAudiences
+	}
+
+	c, errResp, err := b.client(req.Storage)
+	if errResp != nil || err != nil {
+		return errResp, err
+	}
+
+	serviceAccount := role.ServiceAccount
+	var roleBinding string
+	if serviceAccount == "" {
+		serviceAccount, err = generatedName(name)
+		if err != nil {
+			return nil, err
+		}
+		roleBinding = serviceAccount
+
+		if err := c.CreateServiceAccount(namespace, serviceAccount); err != nil {
+			return nil, fmt.Errorf("failed to create service account: %v", err)
+		}
+		if err := c.CreateRoleBinding(namespace, roleBinding, role.KubernetesRoleKind, role.KubernetesRole, serviceAccount); err != nil {
+			b.cleanup(c, namespace, serviceAccount, "")
+			return nil, fmt.Errorf("failed to create role binding: %v", err)
+		}
+	}
+
+	token, err := c.CreateToken(namespace, serviceAccount, ttl, audiences)
+	if err != nil {
+		if roleBinding != "" {
+			b.cleanup(c, namespace, serviceAccount, roleBinding)
+		}
+		return nil, fmt.Errorf("failed to create token: %v", err)
+	}
+
+	resp := b.Secret(secretTokenType).Response(map[string]interface{}{
+		"service_account_token":     token.Token,
+		"service_account_name":      serviceAccount,
+		"service_account_namespace": namespace,
+	}, map[string]interface{}{
+		"role":                      name,
+		"service_account_name":      serviceAccount,
+		"service_account_namespace": namespace,
+		"created_service_account":   roleBinding != "",
+		"role_binding_name":         roleBinding,
+	})
+
+	// The API server may shorten the requested expiration
+	resp.Secret.TTL = ttl
+	if !token.ExpirationTimestamp.IsZero() {
+		if remaining := time.Until(token.ExpirationTimestamp); remaining > 0 && remaining < ttl {
+			resp.Secret.TTL = remaining
+		}
+	}
+	resp.Warnings = warnings
+
+	return resp, nil
+}
+
+// cleanup deletes the temporary objects of a token whose creation failed
+func (b *backend) cleanup(c *apiClient, namespace, serviceAccount, roleBinding string) {
+	if roleBinding != "" {
+		if err := c.DeleteRoleBinding(namespace, roleBinding); err != nil {
+			b.Logger().Warn("kubernetes: failed to delete role binding", "namespace", namespace, "name", roleBinding, "error", err)
+		}
+	}
+	if err := c.DeleteServiceAccount(namespace, serviceAccount); err != nil {
+		b.Logger().Warn("kubernetes: failed to delete service account", "namespace", namespace, "name", serviceAccount, "error", err)
+	}
+}
+
+const pathCredsHelpSyn = `
+Create a Kubernetes service account token.
+`
+
+const pathCredsHelpDesc = `
+This path creates a token of the role's service account in the
+"kubernetes_namespace", with the TokenRequest API. Roles bound to a Role or
+ClusterRole first create a temporary service account and role binding, which
+are deleted when the lease is revoked.
+
+Tokens can't be renewed, as their expiration is fixed when they are created.
+Tokens of existing service accounts remain valid until they expire, even if
+the lease is revoked before.
+`
@@ -0,0 +1,255 @@
+package kubernetes
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const (
+	rolePath = "roles/"
+
+	// minTokenTTL is the shortest expiration the TokenRequest API accepts
+	minTokenTTL = 10 * time.Minute
+)
+
+func pathListRoles(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "roles/?$",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.pathRoleList,
+		},
+
+		HelpSynopsis:    pathRoleHelpSyn,
+		HelpDescription: pathRoleHelpDesc,
+	}
+}
+
+func pathRoles(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "roles/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the role",
+			},
+
+			"allowed_kubernetes_namespaces": &framework.FieldSchema{
+				Type:        framework.TypeCommaStringSlice,
+				Description: "Namespaces tokens can be created in, or \"*\" for all namespaces",
+			},
+
+			"service_account_name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Existing service account tokens are created for. Can't be used with kubernetes_role_name.",
+			},
+
+			"kubernetes_role_name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Role or ClusterRole bound to the temporary service accounts created for each token. Can't be used with service_account_name.",
+			},
+
+			"kubernetes_role_type": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Default:     "Role",
+				Description: "Kind of kubernetes_role_name: \"Role\" or \"ClusterRole\". Defaults to \"Role\".",
+			},
+
+			"token_default_ttl": &framework.FieldSchema{
+				Type:        framework.TypeDurationSecond,
+				Description: "Default TTL of the tokens. Defaults to the mount's default lease TTL.",
+			},
+
+			"token_max_ttl": &framework.FieldSchema{
+				Type:        framework.TypeDurationSecond,
+				Description: "Maximum TTL of the tokens. Defaults to the mount's maximum lease TTL.",
+			},
+
+			"token_default_audiences": &framework.FieldSchema{
+				Type:        framework.TypeCommaStringSlice,
+				Description: "Default audiences of the tokens. Defaults to the audience of the API server.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathRoleRead,
+			logical.UpdateOperation: b.pathRoleWrite,
+			logical.DeleteOperation: b.pathRoleDelete,
+		},
+
+		HelpSynopsis:    pathRoleHelpSyn,
+		HelpDescription: pathRoleHelpDesc,
+	}
+}
+
+type roleEntry struct {
+	AllowedNamespaces  []string      `json:"allowed_kubernetes_namespaces"`
+	ServiceAccount     string        `json:"service_account_name"`
+	KubernetesRole     string        `json:"kubernetes_role_name"`
+	KubernetesRoleKind string        `json:"kubernetes_role_type"`
+	DefaultTTL         time.Duration `json:"token_default_ttl"`
+	MaxTTL             time.Duration `json:"token_max_ttl"`
+	DefaultAudiences   []string      `json:"token_default_audiences"`
+}
+
+// namespaceAllowed returns whether tokens of the role can be created in the
+// namespace
+func (r *roleEntry) namespaceAllowed(namespace string) bool {
+	return strutil.StrListContains(r.AllowedNamespaces, "*") ||
+		strutil.StrListContains(r.AllowedNamespaces, namespace)
+}
+
+// Role returns the named role, or nil if it doesn't exist
+func (b *backend) Role(s logical.Storage, name string) (*roleEntry, error) {
+	entry, err := s.Get(rolePath + name)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result roleEntry
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (b *backend) pathRoleList(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	entries, err := req.Storage.List(rolePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(entries), nil
+}
+
+func (b *backend) pathRoleRead(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	role, err := b.Role(req.Storage, d.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"allowed_kubernetes_namespaces": role.AllowedNamespaces,
+			"service_account_name":          role.ServiceAccount,
+			"kubernetes_role_name":          role.KubernetesRole,
+			"kubernetes_role_type":          role.KubernetesRoleKind,
+			"token_default_ttl":             int64(role.DefaultTTL.Seconds()),
+			"token_max_ttl":                 int64(role.MaxTTL.Seconds()),
+			"token_default_audiences":       role.DefaultAudiences,
+		},
+	}, nil
+}
+
+func (b *backend) pathRoleWrite(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	role, err := b.Role(req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		role = &roleEntry{
+			KubernetesRoleKind: d.Get("kubernetes_role_type").(string),
+		}
+	}
+
+	if namespaces, ok := d.GetOk("allowed_kubernetes_namespaces"); ok {
+		role.AllowedNamespaces = strutil.RemoveDuplicates(namespaces.([]string), false)
+	}
+	if serviceAccount, ok := d.GetOk("service_account_name"); ok {
+		role.ServiceAccount = serviceAccount.(string)
+	}
+	if kubernetesRole, ok := d.GetOk("kubernetes_role_name"); ok {
+		role.KubernetesRole = kubernetesRole.(string)
+	}
+	if kind, ok := d.GetOk("kubernetes_role_type"); ok {
+		role.KubernetesRoleKind = kind.(string)
+	}
+	if ttl, ok := d.GetOk("token_default_ttl"); ok {
+		role.DefaultTTL = time.Duration(ttl.(int)) * time.Second
+	}
+	if maxTTL, ok := d.GetOk("token_max_ttl"); ok {
+		role.MaxTTL = time.Duration(maxTTL.(int)) * time.Second
+	}
+	if audiences, ok := d.GetOk("token_default_audiences"); ok {
+		role.DefaultAudiences = audiences.([]string)
+	}
+
+	if len(role.AllowedNamespaces) == 0 {
+		return logical.ErrorResponse("allowed_kubernetes_namespaces is required"), nil
+	}
+	if (role.ServiceAccount == "") == (role.KubernetesRole == "") {
+		return logical.ErrorResponse("exactly one of service_account_name and kubernetes_role_name is required"), nil
+	}
+
+	switch strings.ToLower(role.KubernetesRoleKind) {
+	case "role":
+		role.KubernetesRoleKind = "Role"
+	case "clusterrole":
+		role.KubernetesRoleKind = "ClusterRole"
+	default:
+		return logical.ErrorResponse("kubernetes_role_type must be \"Role\" or \"ClusterRole\""), nil
+	}
+
+	if role.DefaultTTL != 0 && role.DefaultTTL < minTokenTTL {
+		return logical.ErrorResponse(fmt.Sprintf("token_default_ttl must be at least %s", minTokenTTL)), nil
+	}
+	if role.MaxTTL != 0 && role.MaxTTL < minTokenTTL {
+		return logical.ErrorResponse(fmt.Sprintf("token_max_ttl must be at least %s", minTokenTTL)), nil
+	}
+	if role.MaxTTL != 0 && role.DefaultTTL > role.MaxTTL {
+		return logical.ErrorResponse("token_default_ttl must not be greater than token_max_ttl"), nil
+	}
+
+	entry, err := logical.StorageEntryJSON(rolePath+name, role)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(entry); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathRoleDelete(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	if err := req.Storage.Delete(rolePath + d.Get("name").(string)); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+const pathRoleHelpSyn = `
+Manage the roles that can create Kubernetes service account tokens.
+`
+
+const pathRoleHelpDesc = `
+This path lets you manage the roles of the backend. A role creates tokens in
+the "allowed_kubernetes_namespaces", either for the existing
+"service_account_name", or for a temporary service account created for each
+token and bound to the "kubernetes_role_name" Role or ClusterRole in the
+namespace. Temporary service accounts and their role bindings are deleted
+when the lease of the token is revoked.
+
+Tokens last "token_default_ttl", and can be requested for up to
+"token_max_ttl". The TokenRequest API doesn't accept TTLs shorter than 10
+minutes.
+`
@@ -0,0 +1,77 @@
+package kubernetes
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const secretTokenType = "service_account_token"
+
+// secretToken has no Renew callback: the expiration of service account tokens
+// is fixed by the TokenRequest API, so their leases aren't renewable.
+func secretToken(b *backend) *framework.Secret {
+	return &framework.Secret{
+		Type: secretTokenType,
+		Fields: map[string]*framework.FieldSchema{
+			"service_account_token": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Service account token",
+			},
+
+			"service_account_name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the service account of the token",
+			},
+
+			"service_account_namespace": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Namespace of the service account of the token",
+			},
+		},
+
+		Revoke: b.secretTokenRevoke,
+	}
+}
+
+func (b *backend) secretTokenRevoke(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	// Tokens of existing service accounts can't be revoked before they
+	// expire
+	created, _ := req.Secret.InternalData["created_service_account"].(bool)
+	if !created {
+		return nil, nil
+	}
+
+	namespace, ok := req.Secret.InternalData["service_account_namespace"].(string)
+	if !ok {
+		return nil, fmt.Errorf("secret is missing service_account_namespace internal data")
+	}
+	serviceAccount, ok := req.Secret.InternalData["service_account_name"].(string)
+	if !ok {
+		return nil, fmt.Errorf("secret is missing service_account_name internal data")
+	}
+	roleBinding, _ := req.Secret.InternalData["role_binding_name"].(string)
+
+	c, errResp, err := b.client(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if errResp != nil {
+		// Returning logical.ErrorResponse from revocation function is risky
+		return nil, fmt.Errorf("the Kubernetes API server is not configured")
+	}
+
+	// Deleting the service account invalidates its tokens
+	if roleBinding != "" {
+		if err := c.DeleteRoleBinding(namespace, roleBinding); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.DeleteServiceAccount(namespace, serviceAccount); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
@@ -37,6 +37,7 @@ func Backend(conf *logical.BackendConfig) (*backend, error) {
 			Unauthenticated: []string{
 				"verify",
 				"public_key",
+				"known_hosts",
 			},
 
 			LocalStorage: []string{
@@ -53,8 +54,10 @@ func Backend(conf *logical.BackendConfig) (*backend, error) {
 			pathLookup(&b),
 			pathVerify(&b),
 			pathConfigCA(&b),
+			pathConfigHostCA(&b),
 			pathSign(&b),
 			pathFetchPublicKey(&b),
+			pathFetchKnownHosts(&b),
 		},
 
 		Secrets: []*framework.Secret{
@@ -1,6 +1,11 @@
 package ssh
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
 	"fmt"
 	"reflect"
 	"testing"
@@ -604,6 +609,449 @@ func TestBackend_ValidPrincipalsValidatedForHostCertificates(t *testing.T) {
 	logicaltest.Test(t, testCase)
 }
 
+func TestBackend_HostCertificatesRequirePrincipals(t *testing.T) {
+	config := logical.TestBackendConfig()
+
+	b, err := Factory(config)
+	if err != nil {
+		t.Fatalf("Cannot create backend: %s", err)
+	}
+
+	testCase := logicaltest.TestCase{
+		Backend: b,
+		Steps: []logicaltest.TestStep{
+			configCaStep(),
+
+			// Host roles must restrict the domains they sign for
+			logicaltest.TestStep{
+				Operation: logical.CreateOperation,
+				Path:      "roles/nodomains",
+				Data: map[string]interface{}{
+					"key_type":                "ca",
+					"allow_host_certificates": true,
+				},
+				ErrorOk: true,
+				Check: func(resp *logical.Response) error {
+					if resp == nil || !resp.IsError() {
+						return errors.New("expected error creating a host role without allowed_domains")
+					}
+					return nil
+				},
+			},
+
+			createRoleStep("testing", map[string]interface{}{
+				"key_type":                "ca",
+				"allow_host_certificates": true,
+				"allowed_domains":         "Example.com",
+				"allow_bare_domains":      true,
+			}),
+
+			logicaltest.TestStep{
+				Operation: logical.UpdateOperation,
+				Path:      "sign/testing",
+				Data: map[string]interface{}{
+					"public_key": publicKey2,
+					"cert_type":  "host",
+				},
+				ErrorOk: true,
+				Check: func(resp *logical.Response) error {
+					if resp.Data["error"] != "valid_principals must be set for host certificates" {
+						return fmt.Errorf("expected error signing a host certificate without principals, got %#v", resp.Data)
+					}
+					return nil
+				},
+			},
+
+			logicaltest.TestStep{
+				Operation: logical.UpdateOperation,
+				Path:      "sign/testing",
+				Data: map[string]interface{}{
+					"public_key":       publicKey2,
+					"cert_type":        "host",
+					"valid_principals": "sub.example.com",
+				},
+				ErrorOk: true,
+				Check: func(resp *logical.Response) error {
+					if resp == nil || !resp.IsError() {
+						return errors.New("expected error signing a subdomain without allow_subdomains")
+					}
+					return nil
+				},
+			},
+
+			// Hostnames match regardless of case
+			signCertificateStep("testing", "vault-root-22608f5ef173aabf700797cb95c5641e792698ec6380e8e1eb55523e39aa5e51", ssh.HostCert, []string{"EXAMPLE.com"}, map[string]string{}, map[string]string{},
+				2*time.Hour, map[string]interface{}{
+					"public_key":       publicKey2,
+					"ttl":              "2h",
+					"cert_type":        "host",
+					"valid_principals": "EXAMPLE.com",
+				}),
+		},
+	}
+
+	logicaltest.Test(t, testCase)
+}
+
+func TestBackend_SeparateHostCA(t *testing.T) {
+	config := logical.TestBackendConfig()
+
+	b, err := Factory(config)
+	if err != nil {
+		t.Fatalf("Cannot create backend: %s", err)
+	}
+
+	var hostCAPublicKey string
+	parseSignedKey := func(resp *logical.Response) (*ssh.Certificate, error) {
+		signedKey := strings.TrimSpace(resp.Data["signed_key"].(string))
+		key, err := base64.StdEncoding.DecodeString(strings.Split(signedKey, " ")[1])
+		if err != nil {
+			return nil, err
+		}
+		parsedKey, err := ssh.ParsePublicKey(key)
+		if err != nil {
+			return nil, err
+		}
+		return parsedKey.(*ssh.Certificate), nil
+	}
+
+	testCase := logicaltest.TestCase{
+		Backend: b,
+		Steps: []logicaltest.TestStep{
+			configCaStep(),
+
+			// With only the user CA configured, it is served for hosts too
+			logicaltest.TestStep{
+				Operation:       logical.ReadOperation,
+				Path:            "known_hosts",
+				Unauthenticated: true,
+				Check: func(resp *logical.Response) error {
+					line := string(resp.Data["http_raw_body"].([]byte))
+					expected := "@cert-authority * " + strings.TrimSpace(publicKey) + "\n"
+					if line != expected {
+						return fmt.Errorf("bad known_hosts line: expected %q, got %q", expected, line)
+					}
+					return nil
+				},
+			},
+
+			logicaltest.TestStep{
+				Operation: logical.UpdateOperation,
+				Path:      "config/host_ca",
+				Check: func(resp *logical.Response) error {
+					hostCAPublicKey = resp.Data["public_key"].(string)
+					if hostCAPublicKey == "" || hostCAPublicKey == publicKey {
+						return fmt.Errorf("bad host CA public key: %q", hostCAPublicKey)
+					}
+					return nil
+				},
+			},
+
+			logicaltest.TestStep{
+				Operation: logical.ReadOperation,
+				Path:      "config/host_ca",
+				Check: func(resp *logical.Response) error {
+					if resp.Data["public_key"] != hostCAPublicKey {
+						return fmt.Errorf("bad host CA public key: %q", resp.Data["public_key"])
+					}
+					return nil
+				},
+			},
+
+			createRoleStep("hosts", map[string]interface{}{
+				"key_type":                "ca",
+				"allow_host_certificates": true,
+				"allowed_domains":         "example.com,example.org",
+				"allow_subdomains":        true,
+			}),
+			createRoleStep("morehosts", map[string]interface{}{
+				"key_type":                "ca",
+				"allow_host_certificates": true,
+				"allowed_domains":         "example.com",
+				"allow_bare_domains":      true,
+			}),
+			createRoleStep("users", map[string]interface{}{
+				"key_type":                "ca",
+				"allow_user_certificates": true,
+				"allowed_users":           "*",
+			}),
+
+			logicaltest.TestStep{
+				Operation:       logical.ReadOperation,
+				Path:            "known_hosts",
+				Unauthenticated: true,
+				Check: func(resp *logical.Response) error {
+					line := string(resp.Data["http_raw_body"].([]byte))
+					expected := "@cert-authority *.example.com,*.example.org,example.com " + strings.TrimSpace(hostCAPublicKey) + "\n"
+					if line != expected {
+						return fmt.Errorf("bad known_hosts line: expected %q, got %q", expected, line)
+					}
+					return nil
+				},
+			},
+
+			logicaltest.TestStep{
+				Operation: logical.UpdateOperation,
+				Path:      "sign/hosts",
+				Data: map[string]interface{}{
+					"public_key":       publicKey2,
+					"cert_type":        "host",
+					"valid_principals": "web.example.com",
+				},
+				Check: func(resp *logical.Response) error {
+					cert, err := parseSignedKey(resp)
+					if err != nil {
+						return err
+					}
+					if string(ssh.MarshalAuthorizedKey(cert.SignatureKey)) != hostCAPublicKey {
+						return fmt.Errorf("host certificate was not signed by the host CA")
+					}
+					return nil
+				},
+			},
+
+			logicaltest.TestStep{
+				Operation: logical.UpdateOperation,
+				Path:      "sign/users",
+				Data: map[string]interface{}{
+					"public_key":       publicKey2,
+					"valid_principals": "tuber",
+				},
+				Check: func(resp *logical.Response) error {
+					cert, err := parseSignedKey(resp)
+					if err != nil {
+						return err
+					}
+					publicSigningKey, err := getSigningPublicKey()
+					if err != nil {
+						return err
+					}
+					if !reflect.DeepEqual(cert.SignatureKey, publicSigningKey) {
+						return fmt.Errorf("user certificate was not signed by the user CA")
+					}
+					return nil
+				},
+			},
+
+			// Deleting the host CA leaves the user CA in place
+			logicaltest.TestStep{
+				Operation: logical.DeleteOperation,
+				Path:      "config/host_ca",
+			},
+			logicaltest.TestStep{
+				Operation:       logical.ReadOperation,
+				Path:            "public_key",
+				Unauthenticated: true,
+				Check: func(resp *logical.Response) error {
+					key := string(resp.Data["http_raw_body"].([]byte))
+					if key != publicKey {
+						return fmt.Errorf("public_key incorrect. Expected %v, actual %v", publicKey, key)
+					}
+					return nil
+				},
+			},
+		},
+	}
+
+	logicaltest.Test(t, testCase)
+}
+
+func TestBackend_AlgorithmSigner(t *testing.T) {
+	config := logical.TestBackendConfig()
+
+	b, err := Factory(config)
+	if err != nil {
+		t.Fatalf("Cannot create backend: %s", err)
+	}
+
+	signWithAlgorithm := func(algorithm string, hash crypto.Hash) logicaltest.TestStep {
+		return logicaltest.TestStep{
+			Operation: logical.UpdateOperation,
+			Path:      "sign/" + algorithm,
+			Data: map[string]interface{}{
+				"public_key":       publicKey2,
+				"valid_principals": "tuber",
+			},
+			Check: func(resp *logical.Response) error {
+				signedKey, err := parsePublicSSHKey(strings.TrimSpace(resp.Data["signed_key"].(string)))
+				if err != nil {
+					return err
+				}
+				cert := signedKey.(*ssh.Certificate)
+				if cert.Signature.Format != algorithm {
+					return fmt.Errorf("bad signature format: expected %s, got %s", algorithm, cert.Signature.Format)
+				}
+
+				// The signature covers the certificate without its trailing
+				// signature field
+				unsigned := *cert
+				unsigned.Signature = nil
+				signedBytes := unsigned.Marshal()
+				signedBytes = signedBytes[:len(signedBytes)-4]
+
+				h := hash.New()
+				h.Write(signedBytes)
+				caKey := cert.SignatureKey.(ssh.CryptoPublicKey).CryptoPublicKey().(*rsa.PublicKey)
+				return rsa.VerifyPKCS1v15(caKey, hash, h.Sum(nil), cert.Signature.Blob)
+			},
+		}
+	}
+
+	var steps []logicaltest.TestStep
+	steps = append(steps, configCaStep())
+	for _, algorithm := range []string{"ssh-rsa", "rsa-sha2-256", "rsa-sha2-512"} {
+		steps = append(steps, createRoleStep(algorithm, map[string]interface{}{
+			"key_type":                "ca",
+			"allow_user_certificates": true,
+			"allowed_users":           "tuber",
+			"algorithm_signer":        algorithm,
+		}))
+	}
+	steps = append(steps,
+		signWithAlgorithm("ssh-rsa", crypto.SHA1),
+		signWithAlgorithm("rsa-sha2-256", crypto.SHA256),
+		signWithAlgorithm("rsa-sha2-512", crypto.SHA512),
+
+		// New roles default to SHA-256
+		createRoleStep("default", map[string]interface{}{
+			"key_type":                "ca",
+			"allow_user_certificates": true,
+		}),
+		logicaltest.TestStep{
+			Operation: logical.ReadOperation,
+			Path:      "roles/default",
+			Check: func(resp *logical.Response) error {
+				if resp.Data["algorithm_signer"] != "rsa-sha2-256" {
+					return fmt.Errorf("bad default algorithm_signer: %v", resp.Data["algorithm_signer"])
+				}
+				return nil
+			},
+		},
+
+		logicaltest.TestStep{
+			Operation: logical.CreateOperation,
+			Path:      "roles/invalid",
+			Data: map[string]interface{}{
+				"key_type":                "ca",
+				"allow_user_certificates": true,
+				"algorithm_signer":        "rsa-sha1",
+			},
+			ErrorOk: true,
+			Check: func(resp *logical.Response) error {
+				if resp == nil || !resp.IsError() {
+					return errors.New("expected error for an invalid algorithm_signer")
+				}
+				return nil
+			},
+		},
+	)
+
+	logicaltest.Test(t, logicaltest.TestCase{
+		Backend: b,
+		Steps:   steps,
+	})
+}
+
+func TestBackend_AllowedUserKeyLengths(t *testing.T) {
+	config := logical.TestBackendConfig()
+
+	b, err := Factory(config)
+	if err != nil {
+		t.Fatalf("Cannot create backend: %s", err)
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecPublicKey, err := ssh.NewPublicKey(&ecKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signStep := func(role, key string, expectError bool) logicaltest.TestStep {
+		return logicaltest.TestStep{
+			Operation: logical.UpdateOperation,
+			Path:      "sign/" + role,
+			Data: map[string]interface{}{
+				"public_key":       key,
+				"valid_principals": "tuber",
+			},
+			ErrorOk: expectError,
+			Check: func(resp *logical.Response) error {
+				if resp.IsError() != expectError {
+					return fmt.Errorf("expected error: %t, got: %#v", expectError, resp.Data)
+				}
+				return nil
+			},
+		}
+	}
+
+	testCase := logicaltest.TestCase{
+		Backend: b,
+		Steps: []logicaltest.TestStep{
+			configCaStep(),
+
+			createRoleStep("weakrsa", map[string]interface{}{
+				"key_type":                "ca",
+				"allow_user_certificates": true,
+				"allowed_users":           "tuber",
+				"allowed_user_key_lengths": map[string]interface{}{
+					"rsa": 2048,
+				},
+			}),
+			createRoleStep("strong", map[string]interface{}{
+				"key_type":                "ca",
+				"allow_user_certificates": true,
+				"allowed_users":           "tuber",
+				"allowed_user_key_lengths": map[string]interface{}{
+					"rsa": "4096",
+					"ec":  256,
+				},
+			}),
+
+			logicaltest.TestStep{
+				Operation: logical.ReadOperation,
+				Path:      "roles/strong",
+				Check: func(resp *logical.Response) error {
+					expected := map[string]int{"rsa": 4096, "ec": 256}
+					if !reflect.DeepEqual(resp.Data["allowed_user_key_lengths"], expected) {
+						return fmt.Errorf("bad allowed_user_key_lengths: %#v", resp.Data["allowed_user_key_lengths"])
+					}
+					return nil
+				},
+			},
+
+			// publicKey2 is a 2048 bit RSA key
+			signStep("weakrsa", publicKey2, false),
+			signStep("weakrsa", string(ssh.MarshalAuthorizedKey(ecPublicKey)), true),
+			signStep("strong", publicKey2, true),
+			signStep("strong", string(ssh.MarshalAuthorizedKey(ecPublicKey)), false),
+
+			logicaltest.TestStep{
+				Operation: logical.CreateOperation,
+				Path:      "roles/invalid",
+				Data: map[string]interface{}{
+					"key_type":                "ca",
+					"allow_user_certificates": true,
+					"allowed_user_key_lengths": map[string]interface{}{
+						"rsa1": 2048,
+					},
+				},
+				ErrorOk: true,
+				Check: func(resp *logical.Response) error {
+					if resp == nil || !resp.IsError() {
+						return errors.New("expected error for an invalid key type")
+					}
+					return nil
+				},
+			},
+		},
+	}
+
+	logicaltest.Test(t, testCase)
+}
+
 func TestBackend_OptionsOverrideDefaults(t *testing.T) {
 	config := logical.TestBackendConfig()
 
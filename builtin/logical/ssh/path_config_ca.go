@@ -1,15 +1,22 @@
 package ssh
 
 import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
+	"encoding/binary"
 	"encoding/pem"
 	"fmt"
 
 	multierror "github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/vault/helper/errutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
+	"golang.org/x/crypto/ed25519"
 	"golang.org/x/crypto/ssh"
 )
 
@@ -20,6 +27,13 @@ const (
 	caPublicKeyStoragePathDeprecated  = "public_key"
 	caPrivateKeyStoragePath           = "config/ca_private_key"
 	caPrivateKeyStoragePathDeprecated = "config/ca_bundle"
+
+	// The host CA is optional; when it is not configured, host certificates
+	// are signed by the CA above
+	caHostPublicKey             = "host_ca_public_key"
+	caHostPrivateKey            = "host_ca_private_key"
+	caHostPublicKeyStoragePath  = "config/host_ca_public_key"
+	caHostPrivateKeyStoragePath = "config/host_ca_private_key"
 )
 
 type keyStorageEntry struct {
@@ -27,8 +41,22 @@ type keyStorageEntry struct {
 }
 
 func pathConfigCA(b *backend) *framework.Path {
+	return pathConfigCACommon(b, "config/ca")
+}
+
+func pathConfigHostCA(b *backend) *framework.Path {
+	path := pathConfigCACommon(b, "config/host_ca")
+	path.HelpSynopsis = `Set the SSH private key used for signing host certificates.`
+	path.HelpDescription += `
+
+When configured, this CA signs host certificates instead of the one configured
+at "config/ca", which then only signs user certificates.`
+	return path
+}
+
+func pathConfigCACommon(b *backend, pattern string) *framework.Path {
 	return &framework.Path{
-		Pattern: "config/ca",
+		Pattern: pattern,
 		Fields: map[string]*framework.FieldSchema{
 			"private_key": &framework.FieldSchema{
 				Type:        framework.TypeString,
@@ -43,6 +71,18 @@ func pathConfigCA(b *backend) *framework.Path {
 				Description: `Generate SSH key pair internally rather than use the private_key and public_key fields.`,
 				Default:     true,
 			},
+			"key_type": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `The type of key to generate; "rsa", "ec" or "ed25519".
+Only used when generating the signing key.`,
+				Default: "rsa",
+			},
+			"key_bits": &framework.FieldSchema{
+				Type: framework.TypeInt,
+				Description: `The number of bits of the generated key. For "rsa" keys
+this is 2048, 3072 or 4096 (the default); for "ec" keys the curve size,
+256 (the default), 384 or 521. Ignored for "ed25519" keys.`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -61,9 +101,19 @@ Read operations will return the public key, if already stored/generated.`,
 	}
 }
 
+// caKeyTypes returns the public and private key types, as accepted by caKey,
+// of the CA managed by the given config path
+func caKeyTypes(path string) (string, string) {
+	if path == "config/host_ca" {
+		return caHostPublicKey, caHostPrivateKey
+	}
+	return caPublicKey, caPrivateKey
+}
+
 func (b *backend) pathConfigCARead(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	publicKeyEntry, err := caKey(req.Storage, caPublicKey)
+	publicKeyType, _ := caKeyTypes(req.Path)
+	publicKeyEntry, err := caKey(req.Storage, publicKeyType)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read CA public key: %v", err)
 	}
@@ -83,26 +133,46 @@ func (b *backend) pathConfigCARead(
 
 func (b *backend) pathConfigCADelete(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	if err := req.Storage.Delete(caPrivateKeyStoragePath); err != nil {
+	publicKeyType, privateKeyType := caKeyTypes(req.Path)
+	publicKeyPath, _, err := caKeyStoragePaths(publicKeyType)
+	if err != nil {
 		return nil, err
 	}
-	if err := req.Storage.Delete(caPublicKeyStoragePath); err != nil {
+	privateKeyPath, _, err := caKeyStoragePaths(privateKeyType)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := req.Storage.Delete(privateKeyPath); err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Delete(publicKeyPath); err != nil {
 		return nil, err
 	}
 	return nil, nil
 }
 
-func caKey(storage logical.Storage, keyType string) (*keyStorageEntry, error) {
-	var path, deprecatedPath string
+// caKeyStoragePaths returns the storage path of the given CA key type, and
+// the older path it may still be stored at
+func caKeyStoragePaths(keyType string) (string, string, error) {
 	switch keyType {
 	case caPrivateKey:
-		path = caPrivateKeyStoragePath
-		deprecatedPath = caPrivateKeyStoragePathDeprecated
+		return caPrivateKeyStoragePath, caPrivateKeyStoragePathDeprecated, nil
 	case caPublicKey:
-		path = caPublicKeyStoragePath
-		deprecatedPath = caPublicKeyStoragePathDeprecated
+		return caPublicKeyStoragePath, caPublicKeyStoragePathDeprecated, nil
+	case caHostPrivateKey:
+		return caHostPrivateKeyStoragePath, "", nil
+	case caHostPublicKey:
+		return caHostPublicKeyStoragePath, "", nil
 	default:
-		return nil, fmt.Errorf("unrecognized key type %q", keyType)
+		return "", "", fmt.Errorf("unrecognized key type %q", keyType)
+	}
+}
+
+func caKey(storage logical.Storage, keyType string) (*keyStorageEntry, error) {
+	path, deprecatedPath, err := caKeyStoragePaths(keyType)
+	if err != nil {
+		return nil, err
 	}
 
 	entry, err := storage.Get(path)
@@ -110,7 +180,7 @@ func caKey(storage logical.Storage, keyType string) (*keyStorageEntry, error) {
 		return nil, fmt.Errorf("failed to read CA key of type %q: %v", keyType, err)
 	}
 
-	if entry == nil {
+	if entry == nil && deprecatedPath != "" {
 		// If the entry is not found, look at an older path. If found, upgrade
 		// it.
 		entry, err = storage.Get(deprecatedPath)
@@ -145,7 +215,16 @@ func caKey(storage logical.Storage, keyType string) (*keyStorageEntry, error) {
 }
 
 func (b *backend) pathConfigCAUpdate(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	var err error
+	publicKeyType, privateKeyType := caKeyTypes(req.Path)
+	publicKeyPath, _, err := caKeyStoragePaths(publicKeyType)
+	if err != nil {
+		return nil, err
+	}
+	privateKeyPath, _, err := caKeyStoragePaths(privateKeyType)
+	if err != nil {
+		return nil, err
+	}
+
 	publicKey := data.Get("public_key").(string)
 	privateKey := data.Get("private_key").(string)
 
@@ -171,16 +250,20 @@ func (b *backend) pathConfigCAUpdate(req *logical.Request, data *framework.Field
 			return logical.ErrorResponse("missing private_key"), nil
 		}
 
-		_, err := ssh.ParsePrivateKey([]byte(privateKey))
+		signer, err := ssh.ParsePrivateKey([]byte(privateKey))
 		if err != nil {
 			return logical.ErrorResponse(fmt.Sprintf("Unable to parse private_key as an SSH private key: %v", err)), nil
 		}
 
-		_, err = parsePublicSSHKey(publicKey)
+		parsedPublicKey, err := parsePublicSSHKey(publicKey)
 		if err != nil {
 			return logical.ErrorResponse(fmt.Sprintf("Unable to parse public_key as an SSH public key: %v", err)), nil
 		}
 
+		if !bytes.Equal(signer.PublicKey().Marshal(), parsedPublicKey.Marshal()) {
+			return logical.ErrorResponse("public_key does not match private_key"), nil
+		}
+
 	// not set and no public/private key provided so generate
 	case publicKey == "" && privateKey == "":
 		generateSigningKey = true
@@ -191,8 +274,11 @@ func (b *backend) pathConfigCAUpdate(req *logical.Request, data *framework.Field
 	}
 
 	if generateSigningKey {
-		publicKey, privateKey, err = generateSSHKeyPair()
+		publicKey, privateKey, err = generateSSHKeyPair(data.Get("key_type").(string), data.Get("key_bits").(int))
 		if err != nil {
+			if _, ok := err.(errutil.UserError); ok {
+				return logical.ErrorResponse(err.Error()), nil
+			}
 			return nil, err
 		}
 	}
@@ -201,12 +287,12 @@ func (b *backend) pathConfigCAUpdate(req *logical.Request, data *framework.Field
 		return nil, fmt.Errorf("failed to generate or parse the keys")
 	}
 
-	publicKeyEntry, err := caKey(req.Storage, caPublicKey)
+	publicKeyEntry, err := caKey(req.Storage, publicKeyType)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read CA public key: %v", err)
 	}
 
-	privateKeyEntry, err := caKey(req.Storage, caPrivateKey)
+	privateKeyEntry, err := caKey(req.Storage, privateKeyType)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read CA private key: %v", err)
 	}
@@ -215,7 +301,7 @@ func (b *backend) pathConfigCAUpdate(req *logical.Request, data *framework.Field
 		return nil, fmt.Errorf("keys are already configured; delete them before reconfiguring")
 	}
 
-	entry, err := logical.StorageEntryJSON(caPublicKeyStoragePath, &keyStorageEntry{
+	entry, err := logical.StorageEntryJSON(publicKeyPath, &keyStorageEntry{
 		Key: publicKey,
 	})
 	if err != nil {
@@ -228,7 +314,7 @@ func (b *backend) pathConfigCAUpdate(req *logical.Request, data *framework.Field
 		return nil, err
 	}
 
-	entry, err = logical.StorageEntryJSON(caPrivateKeyStoragePath, &keyStorageEntry{
+	entry, err = logical.StorageEntryJSON(privateKeyPath, &keyStorageEntry{
 		Key: privateKey,
 	})
 	if err != nil {
@@ -244,7 +330,7 @@ func (b *backend) pathConfigCAUpdate(req *logical.Request, data *framework.Field
 
 		// If storing private key fails, the corresponding public key should be
 		// removed
-		if delErr := req.Storage.Delete(caPublicKeyStoragePath); delErr != nil {
+		if delErr := req.Storage.Delete(publicKeyPath); delErr != nil {
 			mErr = multierror.Append(mErr, fmt.Errorf("failed to cleanup CA public key: %v", delErr))
 			return nil, mErr
 		}
@@ -265,22 +351,140 @@ func (b *backend) pathConfigCAUpdate(req *logical.Request, data *framework.Field
 	return nil, nil
 }
 
-func generateSSHKeyPair() (string, string, error) {
-	privateSeed, err := rsa.GenerateKey(rand.Reader, 4096)
+// generateSSHKeyPair generates a CA key pair of the given type, returning the
+// public key in authorized_keys format and the PEM-encoded private key
+func generateSSHKeyPair(keyType string, keyBits int) (string, string, error) {
+	var signer crypto.Signer
+	var privateBlock *pem.Block
+	var err error
+
+	switch keyType {
+	case "rsa":
+		switch keyBits {
+		case 0:
+			keyBits = 4096
+		case 2048, 3072, 4096:
+		default:
+			return "", "", errutil.UserError{Err: fmt.Sprintf("unsupported bit length for RSA key: %d", keyBits)}
+		}
+
+		privateKey, err := rsa.GenerateKey(rand.Reader, keyBits)
+		if err != nil {
+			return "", "", err
+		}
+		signer = privateKey
+		privateBlock = &pem.Block{
+			Type:  "RSA PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+		}
+
+	case "ec":
+		var curve elliptic.Curve
+		switch keyBits {
+		case 0, 256:
+			curve = elliptic.P256()
+		case 384:
+			curve = elliptic.P384()
+		case 521:
+			curve = elliptic.P521()
+		default:
+			return "", "", errutil.UserError{Err: fmt.Sprintf("unsupported bit length for EC key: %d", keyBits)}
+		}
+
+		privateKey, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			return "", "", err
+		}
+		marshaled, err := x509.MarshalECPrivateKey(privateKey)
+		if err != nil {
+			return "", "", err
+		}
+		signer = privateKey
+		privateBlock = &pem.Block{
+			Type:  "EC PRIVATE KEY",
+			Bytes: marshaled,
+		}
+
+	case "ed25519":
+		_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return "", "", err
+		}
+		signer = privateKey
+		privateBlock, err = marshalOpenSSHEd25519PrivateKey(privateKey)
+		if err != nil {
+			return "", "", err
+		}
+
+	default:
+		return "", "", errutil.UserError{Err: fmt.Sprintf("unknown key type %q", keyType)}
+	}
+
+	public, err := ssh.NewPublicKey(signer.Public())
 	if err != nil {
 		return "", "", err
 	}
 
-	privateBlock := &pem.Block{
-		Type:    "RSA PRIVATE KEY",
-		Headers: nil,
-		Bytes:   x509.MarshalPKCS1PrivateKey(privateSeed),
+	return string(ssh.MarshalAuthorizedKey(public)), string(pem.EncodeToMemory(privateBlock)), nil
+}
+
+// marshalOpenSSHEd25519PrivateKey encodes an Ed25519 private key in the
+// unencrypted "openssh-key-v1" format, which is the only PEM encoding of
+// Ed25519 keys understood by the SSH library and by OpenSSH itself.
+func marshalOpenSSHEd25519PrivateKey(key ed25519.PrivateKey) (*pem.Block, error) {
+	checkBytes := make([]byte, 4)
+	if _, err := rand.Read(checkBytes); err != nil {
+		return nil, err
+	}
+	check := binary.BigEndian.Uint32(checkBytes)
+
+	publicKey := key.Public().(ed25519.PublicKey)
+	privateBlock := struct {
+		Check1  uint32
+		Check2  uint32
+		Keytype string
+		Pub     []byte
+		Priv    []byte
+		Comment string
+		Pad     []byte `ssh:"rest"`
+	}{
+		Check1:  check,
+		Check2:  check,
+		Keytype: ssh.KeyAlgoED25519,
+		Pub:     publicKey,
+		Priv:    key,
+	}
+
+	// The private section is padded to the cipher block size, which is 8 for
+	// the "none" cipher
+	unpadded := len(ssh.Marshal(privateBlock))
+	for i := 0; (unpadded+i)%8 != 0; i++ {
+		privateBlock.Pad = append(privateBlock.Pad, byte(i+1))
 	}
 
-	public, err := ssh.NewPublicKey(&privateSeed.PublicKey)
+	sshPublicKey, err := ssh.NewPublicKey(publicKey)
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
 
-	return string(ssh.MarshalAuthorizedKey(public)), string(pem.EncodeToMemory(privateBlock)), nil
+	envelope := struct {
+		CipherName   string
+		KdfName      string
+		KdfOpts      string
+		NumKeys      uint32
+		PubKey       []byte
+		PrivKeyBlock []byte
+	}{
+		CipherName:   "none",
+		KdfName:      "none",
+		NumKeys:      1,
+		PubKey:       sshPublicKey.Marshal(),
+		PrivKeyBlock: ssh.Marshal(privateBlock),
+	}
+
+	magic := append([]byte("openssh-key-v1"), 0)
+	return &pem.Block{
+		Type:  "OPENSSH PRIVATE KEY",
+		Bytes: append(magic, ssh.Marshal(envelope)...),
+	}, nil
 }
@@ -1,9 +1,14 @@
 package ssh
 
 import (
+	"bytes"
+	"crypto/rand"
+	"strings"
 	"testing"
 
 	"github.com/hashicorp/vault/logical"
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/ssh"
 )
 
 func TestSSH_ConfigCAStorageUpgrade(t *testing.T) {
@@ -161,3 +166,140 @@ func TestSSH_ConfigCAUpdateDelete(t *testing.T) {
 		t.Fatalf("bad: err: %v, resp:%v", err, resp)
 	}
 }
+
+func TestSSH_ConfigCAKeyTypes(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	b, err := Factory(config)
+	if err != nil {
+		t.Fatalf("Cannot create backend: %s", err)
+	}
+
+	userKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	userPublicKey, err := ssh.NewPublicKey(userKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := b.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/testing",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"key_type":                "ca",
+			"allow_user_certificates": true,
+			"allowed_users":           "tuber",
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: err: %v, resp:%v", err, resp)
+	}
+
+	cases := []struct {
+		keyType       string
+		keyBits       int
+		publicKeyType string
+	}{
+		{"rsa", 2048, ssh.KeyAlgoRSA},
+		{"ec", 0, ssh.KeyAlgoECDSA256},
+		{"ec", 384, ssh.KeyAlgoECDSA384},
+		{"ec", 521, ssh.KeyAlgoECDSA521},
+		{"ed25519", 0, ssh.KeyAlgoED25519},
+	}
+
+	for _, tc := range cases {
+		caReq := &logical.Request{
+			Path:      "config/ca",
+			Operation: logical.UpdateOperation,
+			Storage:   config.StorageView,
+			Data: map[string]interface{}{
+				"key_type": tc.keyType,
+				"key_bits": tc.keyBits,
+			},
+		}
+		resp, err := b.HandleRequest(caReq)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("%s/%d: bad: err: %v, resp:%v", tc.keyType, tc.keyBits, err, resp)
+		}
+
+		caPublicKey, err := parsePublicSSHKey(strings.TrimSpace(resp.Data["public_key"].(string)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if caPublicKey.Type() != tc.publicKeyType {
+			t.Fatalf("%s/%d: bad CA key type %s", tc.keyType, tc.keyBits, caPublicKey.Type())
+		}
+
+		// The stored private key must be usable for signing
+		resp, err = b.HandleRequest(&logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "sign/testing",
+			Storage:   config.StorageView,
+			Data: map[string]interface{}{
+				"public_key":       string(ssh.MarshalAuthorizedKey(userPublicKey)),
+				"valid_principals": "tuber",
+			},
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("%s/%d: bad: err: %v, resp:%v", tc.keyType, tc.keyBits, err, resp)
+		}
+		signedKey, err := parsePublicSSHKey(strings.TrimSpace(resp.Data["signed_key"].(string)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert := signedKey.(*ssh.Certificate)
+		if !bytes.Equal(cert.SignatureKey.Marshal(), caPublicKey.Marshal()) {
+			t.Fatalf("%s/%d: certificate not signed by the CA", tc.keyType, tc.keyBits)
+		}
+		if tc.keyType != "rsa" {
+			if err := (&ssh.CertChecker{}).CheckCert("tuber", cert); err != nil {
+				t.Fatalf("%s/%d: %v", tc.keyType, tc.keyBits, err)
+			}
+		}
+
+		caReq.Operation = logical.DeleteOperation
+		resp, err = b.HandleRequest(caReq)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: err: %v, resp:%v", err, resp)
+		}
+	}
+
+	// Unsupported key types and sizes are rejected
+	for _, data := range []map[string]interface{}{
+		{"key_type": "dsa"},
+		{"key_type": "rsa", "key_bits": 1024},
+		{"key_type": "ec", "key_bits": 224},
+	} {
+		resp, err := b.HandleRequest(&logical.Request{
+			Path:      "config/ca",
+			Operation: logical.UpdateOperation,
+			Storage:   config.StorageView,
+			Data:      data,
+		})
+		if err != nil || resp == nil || !resp.IsError() {
+			t.Fatalf("%v: expected error response, got err: %v, resp: %v", data, err, resp)
+		}
+	}
+
+	// A public key that doesn't belong to the private key is rejected
+	otherPublicKey, _, err := generateSSHKeyPair("ed25519", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = b.HandleRequest(&logical.Request{
+		Path:      "config/ca",
+		Operation: logical.UpdateOperation,
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"public_key":  otherPublicKey,
+			"private_key": privateKey,
+		},
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error response, got err: %v, resp: %v", err, resp)
+	}
+}
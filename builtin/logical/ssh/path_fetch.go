@@ -1,6 +1,10 @@
 package ssh
 
 import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/vault/helper/strutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 )
@@ -37,3 +41,93 @@ func (b *backend) pathFetchPublicKey(req *logical.Request, data *framework.Field
 
 	return response, nil
 }
+
+func pathFetchKnownHosts(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: `known_hosts`,
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathFetchKnownHosts,
+		},
+
+		HelpSynopsis: `Retrieve a known_hosts entry trusting the host CA.`,
+		HelpDescription: `This returns an "@cert-authority" line, ready to be added to an SSH
+known_hosts file, that trusts the key signing host certificates for the
+hosts allowed by the roles of this backend.`,
+	}
+}
+
+func (b *backend) pathFetchKnownHosts(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	// Host certificates are signed by the user CA unless a host CA is
+	// configured
+	publicKeyEntry, err := caKey(req.Storage, caHostPublicKey)
+	if err != nil {
+		return nil, err
+	}
+	if publicKeyEntry == nil || publicKeyEntry.Key == "" {
+		publicKeyEntry, err = caKey(req.Storage, caPublicKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if publicKeyEntry == nil || publicKeyEntry.Key == "" {
+		return nil, nil
+	}
+
+	patterns, err := b.knownHostsPatterns(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	line := fmt.Sprintf("@cert-authority %s %s\n", strings.Join(patterns, ","), strings.TrimSpace(publicKeyEntry.Key))
+
+	response := &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPContentType: "text/plain",
+			logical.HTTPRawBody:     []byte(line),
+			logical.HTTPStatusCode:  200,
+		},
+	}
+
+	return response, nil
+}
+
+// knownHostsPatterns returns the known_hosts host patterns matching every
+// host that a role of this backend can sign a host certificate for. If no
+// role restricts the hosts, or no role allows host certificates yet, the
+// pattern matches all hosts.
+func (b *backend) knownHostsPatterns(s logical.Storage) ([]string, error) {
+	roleNames, err := s.List("roles/")
+	if err != nil {
+		return nil, err
+	}
+
+	var patterns []string
+	for _, roleName := range roleNames {
+		role, err := b.getRole(s, roleName)
+		if err != nil {
+			return nil, err
+		}
+		if role == nil || role.KeyType != KeyTypeCA || !role.AllowHostCertificates {
+			continue
+		}
+
+		if role.AllowedDomains == "*" {
+			return []string{"*"}, nil
+		}
+		for _, domain := range strutil.ParseStringSlice(role.AllowedDomains, ",") {
+			if role.AllowBareDomains {
+				patterns = append(patterns, domain)
+			}
+			if role.AllowSubdomains {
+				patterns = append(patterns, "*."+domain)
+			}
+		}
+	}
+
+	if len(patterns) == 0 {
+		return []string{"*"}, nil
+	}
+
+	return strutil.RemoveDuplicates(patterns, true), nil
+}
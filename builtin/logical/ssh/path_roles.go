@@ -2,6 +2,7 @@ package ssh
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"time"
@@ -18,6 +19,13 @@ const (
 	KeyTypeCA      = "ca"
 )
 
+// Signature algorithms that can be used with RSA CA keys
+const (
+	algorithmSignerRSA       = "ssh-rsa"
+	algorithmSignerRSASHA256 = "rsa-sha2-256"
+	algorithmSignerRSASHA512 = "rsa-sha2-512"
+)
+
 // Structure that represents a role in SSH backend. This is a common role structure
 // for both OTP and Dynamic roles. Not all the fields are mandatory for both type.
 // Some are applicable for one and not for other. It doesn't matter.
@@ -46,6 +54,8 @@ type sshRole struct {
 	AllowSubdomains        bool              `mapstructure:"allow_subdomains" json:"allow_subdomains"`
 	AllowUserKeyIDs        bool              `mapstructure:"allow_user_key_ids" json:"allow_user_key_ids"`
 	KeyIDFormat            string            `mapstructure:"key_id_format" json:"key_id_format"`
+	AlgorithmSigner        string            `mapstructure:"algorithm_signer" json:"algorithm_signer"`
+	AllowedUserKeyLengths  map[string]int    `mapstructure:"allowed_user_key_lengths" json:"allowed_user_key_lengths"`
 }
 
 func pathListRoles(b *backend) *framework.Path {
@@ -161,8 +171,10 @@ func pathRoles(b *backend) *framework.Path {
 				Type: framework.TypeString,
 				Description: `
 				[Not applicable for Dynamic type] [Not applicable for OTP type] [Optional for CA type]
-				If this option is not specified, client can request for a signed certificate for any
-				valid host. If only certain domains are allowed, then this list enforces it.
+				Comma separated list of domains for which host certificates can be signed,
+				or '*' to allow any host. Required when 'allow_host_certificates' is set.
+				Requested principals are matched against these domains according to
+				'allow_bare_domains' and 'allow_subdomains'.
 				`,
 			},
 			"key_option_specs": &framework.FieldSchema{
@@ -277,6 +289,25 @@ func pathRoles(b *backend) *framework.Path {
 				'{{public_key_hash}}' - A SHA256 checksum of the public key that is being signed.
 				`,
 			},
+			"algorithm_signer": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `
+				[Not applicable for Dynamic type] [Not applicable for OTP type] [Optional for CA type]
+				When the CA key is an RSA key, the signature algorithm used to sign certificates.
+				Either 'rsa-sha2-256' (the default), 'rsa-sha2-512' or 'ssh-rsa'. The latter uses
+				SHA-1 and is rejected by recent versions of OpenSSH. Not used for other key types.
+				`,
+				Default: algorithmSignerRSASHA256,
+			},
+			"allowed_user_key_lengths": &framework.FieldSchema{
+				Type: framework.TypeMap,
+				Description: `
+				[Not applicable for Dynamic type] [Not applicable for OTP type] [Optional for CA type]
+				If set, only keys of the listed types can be signed, and only if they are at least
+				as long as the given number of bits. Keys are 'rsa', 'dsa', 'ec' and 'ed25519'; for
+				'ec' keys the length is the curve size. Defaults to allowing any key.
+				`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -447,13 +478,38 @@ func (b *backend) createCARole(allowedUsers, defaultUser string, data *framework
 		AllowSubdomains:        data.Get("allow_subdomains").(bool),
 		AllowUserKeyIDs:        data.Get("allow_user_key_ids").(bool),
 		KeyIDFormat:            data.Get("key_id_format").(string),
+		AlgorithmSigner:        data.Get("algorithm_signer").(string),
 		KeyType:                KeyTypeCA,
 	}
 
+	switch role.AlgorithmSigner {
+	case algorithmSignerRSA, algorithmSignerRSASHA256, algorithmSignerRSASHA512:
+	default:
+		return nil, logical.ErrorResponse(fmt.Sprintf("Invalid algorithm_signer %q; must be one of %q, %q or %q",
+			role.AlgorithmSigner, algorithmSignerRSASHA256, algorithmSignerRSASHA512, algorithmSignerRSA))
+	}
+
+	allowedUserKeyLengths, err := parseAllowedUserKeyLengths(data.Get("allowed_user_key_lengths").(map[string]interface{}))
+	if err != nil {
+		return nil, logical.ErrorResponse(err.Error())
+	}
+	role.AllowedUserKeyLengths = allowedUserKeyLengths
+
 	if !role.AllowUserCertificates && !role.AllowHostCertificates {
 		return nil, logical.ErrorResponse("Either 'allow_user_certificates' or 'allow_host_certificates' must be set to 'true'")
 	}
 
+	// Host certificates are only issued for principals within the allowed
+	// domains, so a role that can never match any host is a mistake
+	if role.AllowHostCertificates && role.AllowedDomains != "*" {
+		if role.AllowedDomains == "" {
+			return nil, logical.ErrorResponse("'allowed_domains' must be set when 'allow_host_certificates' is 'true'")
+		}
+		if !role.AllowBareDomains && !role.AllowSubdomains {
+			return nil, logical.ErrorResponse("Either 'allow_bare_domains' or 'allow_subdomains' must be set to 'true' when 'allow_host_certificates' is 'true'")
+		}
+	}
+
 	defaultCriticalOptions := convertMapToStringValue(data.Get("default_critical_options").(map[string]interface{}))
 	defaultExtensions := convertMapToStringValue(data.Get("default_extensions").(map[string]interface{}))
 
@@ -503,6 +559,31 @@ func (b *backend) createCARole(allowedUsers, defaultUser string, data *framework
 	return role, nil
 }
 
+// parseAllowedUserKeyLengths validates the allowed_user_key_lengths map of a
+// role, which maps key types to their minimum length in bits
+func parseAllowedUserKeyLengths(raw map[string]interface{}) (map[string]int, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	lengths := make(map[string]int, len(raw))
+	for keyType, value := range convertMapToStringValue(raw) {
+		switch keyType {
+		case "rsa", "dsa", "ec", "ed25519":
+		default:
+			return nil, fmt.Errorf("Invalid key type %q in allowed_user_key_lengths; must be one of 'rsa', 'dsa', 'ec' or 'ed25519'", keyType)
+		}
+
+		length, err := strconv.Atoi(value)
+		if err != nil || length < 0 {
+			return nil, fmt.Errorf("Invalid minimum length %q for key type %q in allowed_user_key_lengths", value, keyType)
+		}
+		lengths[keyType] = length
+	}
+
+	return lengths, nil
+}
+
 func (b *backend) getRole(s logical.Storage, n string) (*sshRole, error) {
 	entry, err := s.Get("roles/" + n)
 	if err != nil {
@@ -569,6 +650,8 @@ func (b *backend) pathRoleRead(req *logical.Request, d *framework.FieldData) (*l
 				"key_type":                 role.KeyType,
 				"default_critical_options": role.DefaultCriticalOptions,
 				"default_extensions":       role.DefaultExtensions,
+				"algorithm_signer":         role.AlgorithmSigner,
+				"allowed_user_key_lengths": role.AllowedUserKeyLengths,
 			},
 		}, nil
 	} else {
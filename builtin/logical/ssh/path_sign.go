@@ -1,10 +1,15 @@
 package ssh
 
 import (
+	"crypto"
+	"crypto/dsa"
+	"crypto/ecdsa"
 	"crypto/rand"
+	"crypto/rsa"
 	"crypto/sha256"
 	"errors"
 	"fmt"
+	"io"
 	"strconv"
 	"strings"
 	"time"
@@ -14,6 +19,7 @@ import (
 	"github.com/hashicorp/vault/helper/strutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
+	"golang.org/x/crypto/ed25519"
 	"golang.org/x/crypto/ssh"
 )
 
@@ -108,6 +114,11 @@ func (b *backend) pathSignCertificate(req *logical.Request, data *framework.Fiel
 		return logical.ErrorResponse(fmt.Sprintf("failed to parse public_key as SSH key: %s", err)), nil
 	}
 
+	err = validateSignedKeyRequirements(userPublicKey, role)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
 	// Note that these various functions always return "user errors" so we pass
 	// them as 4xx values
 	keyId, err := b.calculateKeyId(data, req, role, userPublicKey)
@@ -126,6 +137,10 @@ func (b *backend) pathSignCertificate(req *logical.Request, data *framework.Fiel
 		if err != nil {
 			return logical.ErrorResponse(err.Error()), nil
 		}
+		// A host certificate without principals is valid for any host
+		if len(parsedPrincipals) == 0 {
+			return logical.ErrorResponse("valid_principals must be set for host certificates"), nil
+		}
 	} else {
 		parsedPrincipals, err = b.calculateValidPrincipals(data, role.DefaultUser, role.AllowedUsers, strutil.StrListContains)
 		if err != nil {
@@ -148,17 +163,9 @@ func (b *backend) pathSignCertificate(req *logical.Request, data *framework.Fiel
 		return logical.ErrorResponse(err.Error()), nil
 	}
 
-	privateKeyEntry, err := caKey(req.Storage, caPrivateKey)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read CA private key: %v", err)
-	}
-	if privateKeyEntry == nil || privateKeyEntry.Key == "" {
-		return nil, fmt.Errorf("failed to read CA private key")
-	}
-
-	signer, err := ssh.ParsePrivateKey([]byte(privateKeyEntry.Key))
+	signer, err := caSigner(req.Storage, certificateType, role.AlgorithmSigner)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse stored CA private key: %v", err)
+		return nil, err
 	}
 
 	cBundle := creationBundle{
@@ -193,6 +200,127 @@ func (b *backend) pathSignCertificate(req *logical.Request, data *framework.Fiel
 	return response, nil
 }
 
+// caSigner returns the CA key used to sign certificates of the given type.
+// Host certificates are signed by the host CA if one is configured, and by
+// the user CA otherwise. RSA keys sign with the given algorithm, falling back
+// to ssh-rsa for roles created before it was configurable.
+func caSigner(storage logical.Storage, certificateType uint32, algorithm string) (ssh.Signer, error) {
+	var privateKeyEntry *keyStorageEntry
+	var err error
+	if certificateType == ssh.HostCert {
+		privateKeyEntry, err = caKey(storage, caHostPrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read host CA private key: %v", err)
+		}
+	}
+	if privateKeyEntry == nil || privateKeyEntry.Key == "" {
+		privateKeyEntry, err = caKey(storage, caPrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA private key: %v", err)
+		}
+	}
+	if privateKeyEntry == nil || privateKeyEntry.Key == "" {
+		return nil, fmt.Errorf("failed to read CA private key")
+	}
+
+	privateKey, err := ssh.ParseRawPrivateKey([]byte(privateKeyEntry.Key))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse stored CA private key: %v", err)
+	}
+
+	if rsaKey, ok := privateKey.(*rsa.PrivateKey); ok {
+		switch algorithm {
+		case algorithmSignerRSASHA256:
+			return newRSASHA2Signer(rsaKey, algorithm, crypto.SHA256)
+		case algorithmSignerRSASHA512:
+			return newRSASHA2Signer(rsaKey, algorithm, crypto.SHA512)
+		}
+	}
+
+	signer, err := ssh.NewSignerFromKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse stored CA private key: %v", err)
+	}
+
+	return signer, nil
+}
+
+// rsaSHA2Signer signs with an RSA key using the SHA-2 based signature
+// algorithms defined in RFC 8332, which the SSH library does not offer
+type rsaSHA2Signer struct {
+	key       *rsa.PrivateKey
+	publicKey ssh.PublicKey
+	algorithm string
+	hash      crypto.Hash
+}
+
+func newRSASHA2Signer(key *rsa.PrivateKey, algorithm string, hash crypto.Hash) (ssh.Signer, error) {
+	publicKey, err := ssh.NewPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	return &rsaSHA2Signer{
+		key:       key,
+		publicKey: publicKey,
+		algorithm: algorithm,
+		hash:      hash,
+	}, nil
+}
+
+func (s *rsaSHA2Signer) PublicKey() ssh.PublicKey {
+	return s.publicKey
+}
+
+func (s *rsaSHA2Signer) Sign(rand io.Reader, data []byte) (*ssh.Signature, error) {
+	h := s.hash.New()
+	h.Write(data)
+	blob, err := rsa.SignPKCS1v15(rand, s.key, s.hash, h.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return &ssh.Signature{
+		Format: s.algorithm,
+		Blob:   blob,
+	}, nil
+}
+
+// validateSignedKeyRequirements checks the key to be signed against the key
+// types and minimum lengths allowed by the role
+func validateSignedKeyRequirements(publicKey ssh.PublicKey, role *sshRole) error {
+	if len(role.AllowedUserKeyLengths) == 0 {
+		return nil
+	}
+
+	var keyType string
+	var keyBits int
+	cryptoPublicKey, ok := publicKey.(ssh.CryptoPublicKey)
+	if !ok {
+		return fmt.Errorf("public_key type %s is not allowed by role", publicKey.Type())
+	}
+	switch key := cryptoPublicKey.CryptoPublicKey().(type) {
+	case *rsa.PublicKey:
+		keyType, keyBits = "rsa", key.N.BitLen()
+	case *dsa.PublicKey:
+		keyType, keyBits = "dsa", key.Parameters.P.BitLen()
+	case *ecdsa.PublicKey:
+		keyType, keyBits = "ec", key.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		keyType, keyBits = "ed25519", 256
+	default:
+		return fmt.Errorf("public_key type %s is not allowed by role", publicKey.Type())
+	}
+
+	minBits, ok := role.AllowedUserKeyLengths[keyType]
+	if !ok {
+		return fmt.Errorf("public_key type %s is not allowed by role", publicKey.Type())
+	}
+	if keyBits < minBits {
+		return fmt.Errorf("public_key is %d bits; role requires at least %d bits for %s keys", keyBits, minBits, keyType)
+	}
+
+	return nil
+}
+
 func (b *backend) calculateValidPrincipals(data *framework.FieldData, defaultPrincipal, principalsAllowedByRole string, validatePrincipal func([]string, string) bool) ([]string, error) {
 	validPrincipals := ""
 	validPrincipalsRaw, ok := data.GetOk("valid_principals")
@@ -229,7 +357,10 @@ func (b *backend) calculateValidPrincipals(data *framework.FieldData, defaultPri
 
 func validateValidPrincipalForHosts(role *sshRole) func([]string, string) bool {
 	return func(allowedPrincipals []string, validPrincipal string) bool {
+		// Hostnames are case-insensitive
+		validPrincipal = strings.ToLower(validPrincipal)
 		for _, allowedPrincipal := range allowedPrincipals {
+			allowedPrincipal = strings.ToLower(allowedPrincipal)
 			if allowedPrincipal == validPrincipal && role.AllowBareDomains {
 				return true
 			}
@@ -1,7 +1,9 @@
 package transit
 
 import (
+	"crypto/rsa"
 	"strings"
+	"sync"
 
 	"github.com/hashicorp/vault/helper/keysutil"
 	"github.com/hashicorp/vault/logical"
@@ -20,10 +22,13 @@ func Backend(conf *logical.BackendConfig) *backend {
 	var b backend
 	b.Backend = &framework.Backend{
 		Paths: []*framework.Path{
-			// Rotate/Config needs to come before Keys
+			// Rotate/Config/Trim needs to come before Keys
 			// as the handler is greedy
 			b.pathConfig(),
+			b.pathConfigBatch(),
 			b.pathRotate(),
+			b.pathTrim(),
+			b.pathImport(),
 			b.pathRewrap(),
 			b.pathKeys(),
 			b.pathListKeys(),
@@ -36,11 +41,15 @@ func Backend(conf *logical.BackendConfig) *backend {
 			b.pathHMAC(),
 			b.pathSign(),
 			b.pathVerify(),
+			b.pathWrappingKey(),
+			b.pathBackup(),
+			b.pathRestore(),
 		},
 
-		Secrets:     []*framework.Secret{},
-		Invalidate:  b.invalidate,
-		BackendType: logical.TypeLogical,
+		Secrets:      []*framework.Secret{},
+		Invalidate:   b.invalidate,
+		PeriodicFunc: b.periodicFunc,
+		BackendType:  logical.TypeLogical,
 	}
 
 	b.lm = keysutil.NewLockManager(conf.System.CachingDisabled())
@@ -51,6 +60,14 @@ func Backend(conf *logical.BackendConfig) *backend {
 type backend struct {
 	*framework.Backend
 	lm *keysutil.LockManager
+
+	// The cached key used to unwrap imported keys and restored backups
+	wrappingKey     *rsa.PrivateKey
+	wrappingKeyLock sync.RWMutex
+
+	// The cached batch limit of the mount; zero until loaded
+	maxBatchSize    int
+	batchConfigLock sync.RWMutex
 }
 
 func (b *backend) invalidate(key string) {
@@ -61,5 +78,13 @@ func (b *backend) invalidate(key string) {
 	case strings.HasPrefix(key, "policy/"):
 		name := strings.TrimPrefix(key, "policy/")
 		b.lm.InvalidatePolicy(name)
+	case key == wrappingKeyStoragePath:
+		b.wrappingKeyLock.Lock()
+		b.wrappingKey = nil
+		b.wrappingKeyLock.Unlock()
+	case key == batchConfigStoragePath:
+		b.batchConfigLock.Lock()
+		b.maxBatchSize = 0
+		b.batchConfigLock.Unlock()
 	}
 }
@@ -0,0 +1,78 @@
+package transit
+
+import (
+	"github.com/hashicorp/vault/helper/errutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func (b *backend) pathBackup() *framework.Path {
+	return &framework.Path{
+		Pattern: "backup/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the key",
+			},
+
+			"public_key": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `The PEM-encoded wrapping key of the Vault that
+will restore the backup, as returned by its wrapping_key
+endpoint. The backup is encrypted to this key.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathBackupWrite,
+		},
+
+		HelpSynopsis:    pathBackupHelpSyn,
+		HelpDescription: pathBackupHelpDesc,
+	}
+}
+
+func (b *backend) pathBackupWrite(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	publicKey := d.Get("public_key").(string)
+
+	if publicKey == "" {
+		return logical.ErrorResponse("missing public key to encrypt the backup to"), logical.ErrInvalidRequest
+	}
+
+	pub, err := parseWrappingPublicKey(publicKey)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+
+	backup, err := b.lm.BackupPolicy(req.Storage, name)
+	if err != nil {
+		switch err.(type) {
+		case errutil.UserError:
+			return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+		default:
+			return nil, err
+		}
+	}
+
+	wrapped, err := wrapKeyMaterial(pub, backup)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"backup": wrapped,
+		},
+	}, nil
+}
+
+const pathBackupHelpSyn = `Backup the named key`
+
+const pathBackupHelpDesc = `
+This path is used to back up the named key, including all of its versions and
+its configuration. The backup is encrypted to the given wrapping key so that
+it can only be restored by the Vault holding the matching private key. Only
+exportable keys can be backed up.
+`
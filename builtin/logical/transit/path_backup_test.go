@@ -0,0 +1,217 @@
+package transit
+
+import (
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestTransit_BackupRestore(t *testing.T) {
+	var resp *logical.Response
+	var err error
+
+	// Back up from one backend and restore into another, as would be done
+	// between clusters
+	srcB, srcS := createBackendWithStorage(t)
+	dstB, dstS := createBackendWithStorage(t)
+
+	handle := func(b *backend, req *logical.Request) *logical.Response {
+		resp, err := b.HandleRequest(req)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("err:%v resp:%#v", err, resp)
+		}
+		return resp
+	}
+
+	handle(srcB, &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "keys/foo",
+		Storage:   srcS,
+		Data: map[string]interface{}{
+			"exportable": true,
+		},
+	})
+	for i := 0; i < 3; i++ {
+		handle(srcB, &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "keys/foo/rotate",
+			Storage:   srcS,
+		})
+	}
+	// Move the older versions into the archive
+	handle(srcB, &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "keys/foo/config",
+		Storage:   srcS,
+		Data: map[string]interface{}{
+			"min_decryption_version": 3,
+			"deletion_allowed":       true,
+		},
+	})
+
+	plaintext := "dGhlIHF1aWNrIGJyb3duIGZveA=="
+	resp = handle(srcB, &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "encrypt/foo",
+		Storage:   srcS,
+		Data: map[string]interface{}{
+			"plaintext": plaintext,
+		},
+	})
+	ciphertext := resp.Data["ciphertext"].(string)
+
+	resp = handle(dstB, &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "wrapping_key",
+		Storage:   dstS,
+	})
+	dstPublicKey := resp.Data["public_key"].(string)
+
+	resp = handle(srcB, &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "backup/foo",
+		Storage:   srcS,
+		Data: map[string]interface{}{
+			"public_key": dstPublicKey,
+		},
+	})
+	backup := resp.Data["backup"].(string)
+
+	// The backup cannot be restored by a backend it was not encrypted to
+	resp, err = srcB.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "restore/bar",
+		Storage:   srcS,
+		Data: map[string]interface{}{
+			"backup": backup,
+		},
+	})
+	if err == nil || resp == nil || !resp.IsError() {
+		t.Fatal("expected an error restoring with the wrong wrapping key")
+	}
+
+	handle(dstB, &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "restore",
+		Storage:   dstS,
+		Data: map[string]interface{}{
+			"backup": backup,
+		},
+	})
+
+	resp = handle(dstB, &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "decrypt/foo",
+		Storage:   dstS,
+		Data: map[string]interface{}{
+			"ciphertext": ciphertext,
+		},
+	})
+	if resp.Data["plaintext"] != plaintext {
+		t.Fatalf("bad: plaintext; expected: %q, actual: %q", plaintext, resp.Data["plaintext"])
+	}
+
+	resp = handle(dstB, &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "keys/foo",
+		Storage:   dstS,
+	})
+	if resp.Data["latest_version"].(int) != 4 ||
+		resp.Data["min_decryption_version"].(int) != 3 ||
+		!resp.Data["deletion_allowed"].(bool) {
+		t.Fatalf("bad: restored key data %#v", resp.Data)
+	}
+
+	// Archived versions must have been restored too
+	handle(dstB, &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "keys/foo/config",
+		Storage:   dstS,
+		Data: map[string]interface{}{
+			"min_decryption_version": 1,
+		},
+	})
+	resp = handle(dstB, &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "export/encryption-key/foo/1",
+		Storage:   dstS,
+	})
+	dstV1 := resp.Data["keys"].(map[string]string)["1"]
+	handle(srcB, &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "keys/foo/config",
+		Storage:   srcS,
+		Data: map[string]interface{}{
+			"min_decryption_version": 1,
+		},
+	})
+	resp = handle(srcB, &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "export/encryption-key/foo/1",
+		Storage:   srcS,
+	})
+	if resp.Data["keys"].(map[string]string)["1"] != dstV1 {
+		t.Fatal("archived key version was not restored")
+	}
+
+	// Restoring over an existing key requires force
+	resp, err = dstB.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "restore/foo",
+		Storage:   dstS,
+		Data: map[string]interface{}{
+			"backup": backup,
+		},
+	})
+	if err == nil || resp == nil || !resp.IsError() {
+		t.Fatal("expected an error restoring over an existing key")
+	}
+	handle(dstB, &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "restore/foo",
+		Storage:   dstS,
+		Data: map[string]interface{}{
+			"backup": backup,
+			"force":  true,
+		},
+	})
+
+	// Keys can be restored under a different name
+	handle(dstB, &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "restore/bar",
+		Storage:   dstS,
+		Data: map[string]interface{}{
+			"backup": backup,
+		},
+	})
+	resp = handle(dstB, &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "decrypt/bar",
+		Storage:   dstS,
+		Data: map[string]interface{}{
+			"ciphertext": ciphertext,
+		},
+	})
+	if resp.Data["plaintext"] != plaintext {
+		t.Fatalf("bad: plaintext; expected: %q, actual: %q", plaintext, resp.Data["plaintext"])
+	}
+
+	// Keys that are not exportable cannot be backed up
+	handle(srcB, &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "keys/private",
+		Storage:   srcS,
+	})
+	resp, err = srcB.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "backup/private",
+		Storage:   srcS,
+		Data: map[string]interface{}{
+			"public_key": dstPublicKey,
+		},
+	})
+	if err == nil || resp == nil || !resp.IsError() {
+		t.Fatal("expected an error backing up a key that is not exportable")
+	}
+}
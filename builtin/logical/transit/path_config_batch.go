@@ -0,0 +1,145 @@
+package transit
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/vault/helper/jsonutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const (
+	batchConfigStoragePath = "config/batch"
+
+	// defaultMaxBatchSize is the maximum number of items that can be
+	// processed in a single batch request unless the mount configures
+	// a different limit
+	defaultMaxBatchSize = 1000
+)
+
+// batchConfig is the storage representation of the batch settings of the
+// mount
+type batchConfig struct {
+	MaxBatchSize int `json:"max_batch_size"`
+}
+
+func (b *backend) pathConfigBatch() *framework.Path {
+	return &framework.Path{
+		Pattern: "config/batch",
+		Fields: map[string]*framework.FieldSchema{
+			"max_batch_size": &framework.FieldSchema{
+				Type:        framework.TypeInt,
+				Default:     defaultMaxBatchSize,
+				Description: "Maximum number of items accepted in a single batch request",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathConfigBatchRead,
+			logical.UpdateOperation: b.pathConfigBatchWrite,
+		},
+
+		HelpSynopsis:    pathConfigBatchHelpSyn,
+		HelpDescription: pathConfigBatchHelpDesc,
+	}
+}
+
+func (b *backend) pathConfigBatchRead(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	maxBatchSize, err := b.getMaxBatchSize(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"max_batch_size": maxBatchSize,
+		},
+	}, nil
+}
+
+func (b *backend) pathConfigBatchWrite(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	maxBatchSize := d.Get("max_batch_size").(int)
+	if maxBatchSize < 1 {
+		return logical.ErrorResponse("max_batch_size must be at least 1"), logical.ErrInvalidRequest
+	}
+
+	entry, err := logical.StorageEntryJSON(batchConfigStoragePath, &batchConfig{
+		MaxBatchSize: maxBatchSize,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	b.batchConfigLock.Lock()
+	defer b.batchConfigLock.Unlock()
+
+	if err := req.Storage.Put(entry); err != nil {
+		return nil, err
+	}
+	b.maxBatchSize = maxBatchSize
+
+	return nil, nil
+}
+
+// getMaxBatchSize returns the maximum number of items accepted in a single
+// batch request, loading the configuration of the mount on first use
+func (b *backend) getMaxBatchSize(storage logical.Storage) (int, error) {
+	b.batchConfigLock.RLock()
+	maxBatchSize := b.maxBatchSize
+	b.batchConfigLock.RUnlock()
+	if maxBatchSize != 0 {
+		return maxBatchSize, nil
+	}
+
+	b.batchConfigLock.Lock()
+	defer b.batchConfigLock.Unlock()
+
+	// Check to make sure it hasn't been loaded since
+	if b.maxBatchSize != 0 {
+		return b.maxBatchSize, nil
+	}
+
+	raw, err := storage.Get(batchConfigStoragePath)
+	if err != nil {
+		return 0, err
+	}
+
+	maxBatchSize = defaultMaxBatchSize
+	if raw != nil {
+		var config batchConfig
+		if err := jsonutil.DecodeJSON(raw.Value, &config); err != nil {
+			return 0, err
+		}
+		maxBatchSize = config.MaxBatchSize
+	}
+
+	b.maxBatchSize = maxBatchSize
+	return maxBatchSize, nil
+}
+
+// checkBatchSize returns an error response if the number of items in a batch
+// request is not within the bounds configured for the mount
+func (b *backend) checkBatchSize(storage logical.Storage, n int) (*logical.Response, error) {
+	maxBatchSize, err := b.getMaxBatchSize(storage)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case n == 0:
+		return logical.ErrorResponse("missing batch input to process"), logical.ErrInvalidRequest
+	case n > maxBatchSize:
+		return logical.ErrorResponse(fmt.Sprintf("batch input contains %d items; at most %d items can be processed in a single batch", n, maxBatchSize)), logical.ErrInvalidRequest
+	}
+	return nil, nil
+}
+
+const pathConfigBatchHelpSyn = `Configure the batch settings of the backend`
+
+const pathConfigBatchHelpDesc = `
+This path is used to configure how many items the encrypt, decrypt,
+rewrap, hmac, sign and verify endpoints accept in a single batch
+request. The limit defaults to 1000 items.
+`
@@ -4,6 +4,7 @@ import (
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/vault/logical"
 )
@@ -221,3 +222,128 @@ func TestTransit_ConfigSettings(t *testing.T) {
 	testHMAC(3, true)
 	testHMAC(2, false)
 }
+
+func TestTransit_AutoRotate(t *testing.T) {
+	b, s := createBackendWithStorage(t)
+
+	doReq := func(req *logical.Request) (*logical.Response, error) {
+		req.Storage = s
+		return b.HandleRequest(req)
+	}
+
+	// Periods shorter than the minimum are rejected
+	resp, err := doReq(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "keys/aes",
+		Data: map[string]interface{}{
+			"auto_rotate_period": "10m",
+		},
+	})
+	if err == nil && (resp == nil || !resp.IsError()) {
+		t.Fatalf("expected error; resp:%#v", resp)
+	}
+
+	resp, err = doReq(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "keys/aes",
+		Data: map[string]interface{}{
+			"auto_rotate_period": "24h",
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	resp, err = doReq(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "keys/manual",
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	resp, err = doReq(&logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "keys/aes",
+	})
+	if err != nil || resp == nil {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	if resp.Data["auto_rotate_period"].(int64) != 86400 {
+		t.Fatalf("bad: auto_rotate_period: %#v", resp.Data["auto_rotate_period"])
+	}
+
+	latestVersion := func(name string) int {
+		resp, err := doReq(&logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "keys/" + name,
+		})
+		if err != nil || resp == nil {
+			t.Fatalf("err:%v resp:%#v", err, resp)
+		}
+		return resp.Data["latest_version"].(int)
+	}
+
+	// Nothing is due yet
+	if err := b.periodicFunc(&logical.Request{Storage: s}); err != nil {
+		t.Fatal(err)
+	}
+	if ver := latestVersion("aes"); ver != 1 {
+		t.Fatalf("bad: latest version %d", ver)
+	}
+
+	// Age the latest versions of both keys past the period
+	for _, name := range []string{"aes", "manual"} {
+		p, lock, err := b.lm.GetPolicyExclusive(s, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entry := p.Keys[p.LatestVersion]
+		entry.CreationTime = entry.CreationTime.Add(-25 * time.Hour)
+		p.Keys[p.LatestVersion] = entry
+		err = p.Persist(s)
+		lock.Unlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := b.periodicFunc(&logical.Request{Storage: s}); err != nil {
+		t.Fatal(err)
+	}
+	if ver := latestVersion("aes"); ver != 2 {
+		t.Fatalf("bad: latest version %d", ver)
+	}
+	if ver := latestVersion("manual"); ver != 1 {
+		t.Fatalf("bad: key without a rotation period was rotated to version %d", ver)
+	}
+
+	// The new version is fresh, so another run does nothing
+	if err := b.periodicFunc(&logical.Request{Storage: s}); err != nil {
+		t.Fatal(err)
+	}
+	if ver := latestVersion("aes"); ver != 2 {
+		t.Fatalf("bad: latest version %d", ver)
+	}
+
+	// Disabling through config stops rotation
+	resp, err = doReq(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "keys/aes/config",
+		Data: map[string]interface{}{
+			"auto_rotate_period": 0,
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	resp, err = doReq(&logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "keys/aes",
+	})
+	if err != nil || resp == nil {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	if resp.Data["auto_rotate_period"].(int64) != 0 {
+		t.Fatalf("bad: auto_rotate_period: %#v", resp.Data["auto_rotate_period"])
+	}
+}
@@ -179,3 +179,63 @@ func TestTransit_BatchDecryptionCase3(t *testing.T) {
 		}
 	}
 }
+
+func TestTransit_EncryptDecrypt_RSA(t *testing.T) {
+	var resp *logical.Response
+	var err error
+
+	b, s := createBackendWithStorage(t)
+
+	for _, keyType := range []string{"rsa-2048", "rsa-4096"} {
+		keyReq := &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "keys/" + keyType,
+			Storage:   s,
+			Data: map[string]interface{}{
+				"type": keyType,
+			},
+		}
+		resp, err = b.HandleRequest(keyReq)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("err:%v resp:%#v", err, resp)
+		}
+
+		plaintext := "dGhlIHF1aWNrIGJyb3duIGZveA=="
+		encReq := &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "encrypt/" + keyType,
+			Storage:   s,
+			Data: map[string]interface{}{
+				"plaintext": plaintext,
+			},
+		}
+		resp, err = b.HandleRequest(encReq)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("err:%v resp:%#v", err, resp)
+		}
+		ciphertext := resp.Data["ciphertext"].(string)
+
+		decReq := &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "decrypt/" + keyType,
+			Storage:   s,
+			Data: map[string]interface{}{
+				"ciphertext": ciphertext,
+			},
+		}
+		resp, err = b.HandleRequest(decReq)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("err:%v resp:%#v", err, resp)
+		}
+		if resp.Data["plaintext"] != plaintext {
+			t.Fatalf("bad: plaintext; expected: %q, actual: %q", plaintext, resp.Data["plaintext"])
+		}
+
+		// Tampered ciphertext must not decrypt
+		decReq.Data["ciphertext"] = ciphertext[:len(ciphertext)-4] + "AAAA"
+		resp, err = b.HandleRequest(decReq)
+		if err == nil {
+			t.Fatalf("expected an error decrypting tampered ciphertext")
+		}
+	}
+}
@@ -150,8 +150,8 @@ func (b *backend) pathEncryptWrite(
 			return nil, fmt.Errorf("failed to parse batch input: %v", err)
 		}
 
-		if len(batchInputItems) == 0 {
-			return logical.ErrorResponse("missing batch input to process"), logical.ErrInvalidRequest
+		if resp, err := b.checkBatchSize(req.Storage, len(batchInputItems)); resp != nil || err != nil {
+			return resp, err
 		}
 	} else {
 		valueRaw, ok := d.GetOk("plaintext")
@@ -226,7 +226,7 @@ func (b *backend) pathEncryptWrite(
 		switch keyType {
 		case "aes256-gcm96":
 			polReq.KeyType = keysutil.KeyType_AES256_GCM96
-		case "ecdsa-p256":
+		case "ecdsa-p256", "ed25519", "rsa-2048", "rsa-4096":
 			return logical.ErrorResponse(fmt.Sprintf("key type %v not supported for this operation", keyType)), logical.ErrInvalidRequest
 		default:
 			return logical.ErrorResponse(fmt.Sprintf("unknown key type %v", keyType)), logical.ErrInvalidRequest
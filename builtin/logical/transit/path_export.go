@@ -20,6 +20,7 @@ const (
 	exportTypeEncryptionKey = "encryption-key"
 	exportTypeSigningKey    = "signing-key"
 	exportTypeHMACKey       = "hmac-key"
+	exportTypePublicKey     = "public-key"
 )
 
 func (b *backend) pathExportKeys() *framework.Path {
@@ -28,7 +29,7 @@ func (b *backend) pathExportKeys() *framework.Path {
 		Fields: map[string]*framework.FieldSchema{
 			"type": &framework.FieldSchema{
 				Type:        framework.TypeString,
-				Description: "Type of key to export (encryption-key, signing-key, hmac-key)",
+				Description: "Type of key to export (encryption-key, signing-key, hmac-key, public-key)",
 			},
 			"name": &framework.FieldSchema{
 				Type:        framework.TypeString,
@@ -59,6 +60,7 @@ func (b *backend) pathPolicyExportRead(
 	case exportTypeEncryptionKey:
 	case exportTypeSigningKey:
 	case exportTypeHMACKey:
+	case exportTypePublicKey:
 	default:
 		return logical.ErrorResponse(fmt.Sprintf("invalid export type: %s", exportType)), logical.ErrInvalidRequest
 	}
@@ -74,7 +76,9 @@ func (b *backend) pathPolicyExportRead(
 		return nil, nil
 	}
 
-	if !p.Exportable {
+	// Public keys are not secret, so they can be exported regardless of
+	// whether the key is marked as exportable
+	if !p.Exportable && exportType != exportTypePublicKey {
 		return logical.ErrorResponse("key is not exportable"), nil
 	}
 
@@ -87,6 +91,12 @@ func (b *backend) pathPolicyExportRead(
 		if !p.Type.SigningSupported() {
 			return logical.ErrorResponse("signing not supported for the key"), logical.ErrInvalidRequest
 		}
+	case exportTypePublicKey:
+		switch p.Type {
+		case keysutil.KeyType_ECDSA_P256, keysutil.KeyType_ED25519, keysutil.KeyType_RSA2048, keysutil.KeyType_RSA4096:
+		default:
+			return logical.ErrorResponse("public key export not supported for the key"), logical.ErrInvalidRequest
+		}
 	}
 
 	retKeys := map[string]string{}
@@ -152,6 +162,9 @@ func getExportKey(policy *keysutil.Policy, key *keysutil.KeyEntry, exportType st
 		switch policy.Type {
 		case keysutil.KeyType_AES256_GCM96:
 			return strings.TrimSpace(base64.StdEncoding.EncodeToString(key.Key)), nil
+
+		case keysutil.KeyType_RSA2048, keysutil.KeyType_RSA4096:
+			return keyEntryToRSAPrivateKey(key)
 		}
 
 	case exportTypeSigningKey:
@@ -165,6 +178,15 @@ func getExportKey(policy *keysutil.Policy, key *keysutil.KeyEntry, exportType st
 
 		case keysutil.KeyType_ED25519:
 			return strings.TrimSpace(base64.StdEncoding.EncodeToString(key.Key)), nil
+
+		case keysutil.KeyType_RSA2048, keysutil.KeyType_RSA4096:
+			return keyEntryToRSAPrivateKey(key)
+		}
+
+	case exportTypePublicKey:
+		switch policy.Type {
+		case keysutil.KeyType_ECDSA_P256, keysutil.KeyType_ED25519, keysutil.KeyType_RSA2048, keysutil.KeyType_RSA4096:
+			return strings.TrimSpace(key.FormattedPublicKey), nil
 		}
 	}
 
@@ -199,9 +221,25 @@ func keyEntryToECPrivateKey(k *keysutil.KeyEntry, curve elliptic.Curve) (string,
 	return strings.TrimSpace(string(pem.EncodeToMemory(&block))), nil
 }
 
+func keyEntryToRSAPrivateKey(k *keysutil.KeyEntry) (string, error) {
+	if k == nil {
+		return "", errors.New("nil KeyEntry provided")
+	}
+	if k.RSAKey == nil {
+		return "", errors.New("no RSA key found in KeyEntry")
+	}
+
+	block := pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(k.RSAKey),
+	}
+	return strings.TrimSpace(string(pem.EncodeToMemory(&block))), nil
+}
+
 const pathExportHelpSyn = `Export named encryption or signing key`
 
 const pathExportHelpDesc = `
 This path is used to export the named keys that are configured as
-exportable.
+exportable. The public portion of asymmetric keys can be exported with
+the "public-key" type whether or not the key is exportable.
 `
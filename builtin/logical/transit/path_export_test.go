@@ -1,6 +1,8 @@
 package transit
 
 import (
+	"crypto/x509"
+	"encoding/pem"
 	"fmt"
 	"reflect"
 	"strconv"
@@ -16,6 +18,11 @@ func TestTransit_Export_KeyVersion_ExportsCorrectVersion(t *testing.T) {
 	verifyExportsCorrectVersion(t, "hmac-key", "aes256-gcm96")
 	verifyExportsCorrectVersion(t, "hmac-key", "ecdsa-p256")
 	verifyExportsCorrectVersion(t, "hmac-key", "ed25519")
+	verifyExportsCorrectVersion(t, "encryption-key", "rsa-2048")
+	verifyExportsCorrectVersion(t, "signing-key", "rsa-2048")
+	verifyExportsCorrectVersion(t, "public-key", "rsa-2048")
+	verifyExportsCorrectVersion(t, "public-key", "ecdsa-p256")
+	verifyExportsCorrectVersion(t, "public-key", "ed25519")
 }
 
 func verifyExportsCorrectVersion(t *testing.T, exportType, keyType string) {
@@ -259,6 +266,60 @@ func TestTransit_Export_KeysNotMarkedExportable_ReturnsError(t *testing.T) {
 	}
 }
 
+func TestTransit_Export_PublicKey_NotExportable(t *testing.T) {
+	var b *backend
+	sysView := logical.TestSystemView()
+	storage := &logical.InmemStorage{}
+
+	b = Backend(&logical.BackendConfig{
+		StorageView: storage,
+		System:      sysView,
+	})
+
+	req := &logical.Request{
+		Storage:   storage,
+		Operation: logical.UpdateOperation,
+		Path:      "keys/foo",
+	}
+	req.Data = map[string]interface{}{
+		"type": "rsa-2048",
+	}
+	_, err := b.HandleRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req = &logical.Request{
+		Storage:   storage,
+		Operation: logical.ReadOperation,
+		Path:      "export/public-key/foo/1",
+	}
+	rsp, err := b.HandleRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsp.IsError() {
+		t.Fatalf("bad: got error response: %#v", *rsp)
+	}
+	keys := rsp.Data["keys"].(map[string]string)
+	block, _ := pem.Decode([]byte(keys["1"]))
+	if block == nil || block.Type != "PUBLIC KEY" {
+		t.Fatalf("expected a PEM-encoded public key, got %q", keys["1"])
+	}
+	if _, err := x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+		t.Fatal(err)
+	}
+
+	req.Path = "export/signing-key/foo"
+	rsp, err = b.HandleRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rsp.IsError() {
+		t.Fatal("Key not marked as exportble but was exported.")
+	}
+}
+
 func TestTransit_Export_SigningDoesNotSupportSigning_ReturnsError(t *testing.T) {
 	var b *backend
 	sysView := logical.TestSystemView()
@@ -10,10 +10,36 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/hashicorp/vault/helper/keysutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
+	"github.com/mitchellh/mapstructure"
 )
 
+// batchRequestHMACItem represents a request item for batch HMAC generation
+// and verification
+type batchRequestHMACItem struct {
+	// The base64-encoded input data
+	Input string `json:"input" structs:"input" mapstructure:"input"`
+
+	// The HMAC to verify, including vault header/key version
+	HMAC string `json:"hmac" structs:"hmac" mapstructure:"hmac"`
+}
+
+// batchResponseHMACItem represents a response item for batch HMAC generation
+// and verification
+type batchResponseHMACItem struct {
+	// The generated HMAC for the corresponding batch request item
+	HMAC string `json:"hmac,omitempty" structs:"hmac" mapstructure:"hmac"`
+
+	// Whether the HMAC of the corresponding batch request item is valid
+	Valid bool `json:"valid,omitempty" structs:"valid" mapstructure:"valid"`
+
+	// Error, if set represents a failure encountered while processing a
+	// corresponding batch request item
+	Error string `json:"error,omitempty" structs:"error" mapstructure:"error"`
+}
+
 func (b *backend) pathHMAC() *framework.Path {
 	return &framework.Path{
 		Pattern: "hmac/" + framework.GenericNameRegex("name") + framework.OptionalParamRegex("urlalgorithm"),
@@ -67,15 +93,32 @@ func (b *backend) pathHMACWrite(
 	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 	name := d.Get("name").(string)
 	ver := d.Get("key_version").(int)
-	inputB64 := d.Get("input").(string)
 	algorithm := d.Get("urlalgorithm").(string)
 	if algorithm == "" {
 		algorithm = d.Get("algorithm").(string)
 	}
 
-	input, err := base64.StdEncoding.DecodeString(inputB64)
-	if err != nil {
-		return logical.ErrorResponse(fmt.Sprintf("unable to decode input as base64: %s", err)), logical.ErrInvalidRequest
+	batchInputRaw := d.Raw["batch_input"]
+	var batchInputItems []batchRequestHMACItem
+	if batchInputRaw != nil {
+		err := mapstructure.Decode(batchInputRaw, &batchInputItems)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse batch input: %v", err)
+		}
+
+		if resp, err := b.checkBatchSize(req.Storage, len(batchInputItems)); resp != nil || err != nil {
+			return resp, err
+		}
+	} else {
+		batchInputItems = []batchRequestHMACItem{
+			batchRequestHMACItem{
+				Input: d.Get("input").(string),
+			},
+		}
+	}
+
+	if _, err := hmacHashFunc(algorithm); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
 	}
 
 	// Get the policy
@@ -109,122 +152,181 @@ func (b *backend) pathHMACWrite(
 		return nil, fmt.Errorf("HMAC key value could not be computed")
 	}
 
-	var hf hash.Hash
-	switch algorithm {
-	case "sha2-224":
-		hf = hmac.New(sha256.New224, key)
-	case "sha2-256":
-		hf = hmac.New(sha256.New, key)
-	case "sha2-384":
-		hf = hmac.New(sha512.New384, key)
-	case "sha2-512":
-		hf = hmac.New(sha512.New, key)
-	default:
-		return logical.ErrorResponse(fmt.Sprintf("unsupported algorithm %s", algorithm)), nil
+	batchResponseItems := make([]batchResponseHMACItem, len(batchInputItems))
+	for i, item := range batchInputItems {
+		input, err := base64.StdEncoding.DecodeString(item.Input)
+		if err != nil {
+			batchResponseItems[i].Error = fmt.Sprintf("unable to decode input as base64: %s", err)
+			continue
+		}
+
+		retBytes, err := computeHMAC(algorithm, key, input)
+		if err != nil {
+			return nil, err
+		}
+
+		retStr := base64.StdEncoding.EncodeToString(retBytes)
+		batchResponseItems[i].HMAC = fmt.Sprintf("vault:v%s:%s", strconv.Itoa(ver), retStr)
 	}
-	hf.Write(input)
-	retBytes := hf.Sum(nil)
-
-	retStr := base64.StdEncoding.EncodeToString(retBytes)
-	retStr = fmt.Sprintf("vault:v%s:%s", strconv.Itoa(ver), retStr)
 
 	// Generate the response
-	resp := &logical.Response{
-		Data: map[string]interface{}{
-			"hmac": retStr,
-		},
+	resp := &logical.Response{}
+	if batchInputRaw != nil {
+		resp.Data = map[string]interface{}{
+			"batch_results": batchResponseItems,
+		}
+	} else {
+		if batchResponseItems[0].Error != "" {
+			return logical.ErrorResponse(batchResponseItems[0].Error), logical.ErrInvalidRequest
+		}
+		resp.Data = map[string]interface{}{
+			"hmac": batchResponseItems[0].HMAC,
+		}
 	}
 	return resp, nil
 }
 
 func (b *backend) pathHMACVerify(
-	req *logical.Request, d *framework.FieldData, verificationHMAC string) (*logical.Response, error) {
+	req *logical.Request, d *framework.FieldData, batchInputItems []batchRequestHMACItem) (*logical.Response, error) {
 
 	name := d.Get("name").(string)
-	inputB64 := d.Get("input").(string)
 	algorithm := d.Get("urlalgorithm").(string)
 	if algorithm == "" {
 		algorithm = d.Get("algorithm").(string)
 	}
 
-	input, err := base64.StdEncoding.DecodeString(inputB64)
+	batchInputRaw := d.Raw["batch_input"]
+	if batchInputRaw == nil {
+		batchInputItems = []batchRequestHMACItem{
+			batchRequestHMACItem{
+				Input: d.Get("input").(string),
+				HMAC:  d.Get("hmac").(string),
+			},
+		}
+	}
+
+	if _, err := hmacHashFunc(algorithm); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	// Get the policy
+	p, lock, err := b.lm.GetPolicyShared(req.Storage, name)
+	if lock != nil {
+		defer lock.RUnlock()
+	}
 	if err != nil {
-		return logical.ErrorResponse(fmt.Sprintf("unable to decode input as base64: %s", err)), logical.ErrInvalidRequest
+		return nil, err
+	}
+	if p == nil {
+		return logical.ErrorResponse("encryption key not found"), logical.ErrInvalidRequest
 	}
 
-	// Verify the prefix
-	if !strings.HasPrefix(verificationHMAC, "vault:v") {
-		return logical.ErrorResponse("invalid HMAC to verify: no prefix"), logical.ErrInvalidRequest
+	batchResponseItems := make([]batchResponseHMACItem, len(batchInputItems))
+	for i, item := range batchInputItems {
+		valid, err := verifyHMAC(p, algorithm, item)
+		if err != nil {
+			batchResponseItems[i].Error = err.Error()
+			continue
+		}
+		batchResponseItems[i].Valid = valid
 	}
 
-	splitVerificationHMAC := strings.SplitN(strings.TrimPrefix(verificationHMAC, "vault:v"), ":", 2)
-	if len(splitVerificationHMAC) != 2 {
-		return logical.ErrorResponse("invalid HMAC: wrong number of fields"), logical.ErrInvalidRequest
+	resp := &logical.Response{}
+	if batchInputRaw != nil {
+		resp.Data = map[string]interface{}{
+			"batch_results": batchResponseItems,
+		}
+	} else {
+		if batchResponseItems[0].Error != "" {
+			return logical.ErrorResponse(batchResponseItems[0].Error), logical.ErrInvalidRequest
+		}
+		resp.Data = map[string]interface{}{
+			"valid": batchResponseItems[0].Valid,
+		}
 	}
+	return resp, nil
+}
 
-	ver, err := strconv.Atoi(splitVerificationHMAC[0])
+// verifyHMAC checks the HMAC of a single item against the policy. The
+// returned errors are safe to be returned to the caller.
+func verifyHMAC(p *keysutil.Policy, algorithm string, item batchRequestHMACItem) (bool, error) {
+	input, err := base64.StdEncoding.DecodeString(item.Input)
 	if err != nil {
-		return logical.ErrorResponse("invalid HMAC: version number could not be decoded"), logical.ErrInvalidRequest
+		return false, fmt.Errorf("unable to decode input as base64: %s", err)
 	}
 
-	verBytes, err := base64.StdEncoding.DecodeString(splitVerificationHMAC[1])
-	if err != nil {
-		return logical.ErrorResponse(fmt.Sprintf("unable to decode verification HMAC as base64: %s", err)), logical.ErrInvalidRequest
+	// Verify the prefix
+	if !strings.HasPrefix(item.HMAC, "vault:v") {
+		return false, fmt.Errorf("invalid HMAC to verify: no prefix")
 	}
 
-	// Get the policy
-	p, lock, err := b.lm.GetPolicyShared(req.Storage, name)
-	if lock != nil {
-		defer lock.RUnlock()
+	splitVerificationHMAC := strings.SplitN(strings.TrimPrefix(item.HMAC, "vault:v"), ":", 2)
+	if len(splitVerificationHMAC) != 2 {
+		return false, fmt.Errorf("invalid HMAC: wrong number of fields")
 	}
+
+	ver, err := strconv.Atoi(splitVerificationHMAC[0])
 	if err != nil {
-		return nil, err
+		return false, fmt.Errorf("invalid HMAC: version number could not be decoded")
 	}
-	if p == nil {
-		return logical.ErrorResponse("encryption key not found"), logical.ErrInvalidRequest
+
+	verBytes, err := base64.StdEncoding.DecodeString(splitVerificationHMAC[1])
+	if err != nil {
+		return false, fmt.Errorf("unable to decode verification HMAC as base64: %s", err)
 	}
 
 	if ver > p.LatestVersion {
-		return logical.ErrorResponse("invalid HMAC: version is too new"), logical.ErrInvalidRequest
+		return false, fmt.Errorf("invalid HMAC: version is too new")
 	}
 
 	if p.MinDecryptionVersion > 0 && ver < p.MinDecryptionVersion {
-		return logical.ErrorResponse("cannot verify HMAC: version is too old (disallowed by policy)"), logical.ErrInvalidRequest
+		return false, fmt.Errorf("cannot verify HMAC: version is too old (disallowed by policy)")
 	}
 
 	key, err := p.HMACKey(ver)
 	if err != nil {
-		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+		return false, err
 	}
 	if key == nil {
-		return nil, fmt.Errorf("HMAC key value could not be computed")
+		return false, fmt.Errorf("HMAC key value could not be computed")
 	}
 
-	var hf hash.Hash
+	retBytes, err := computeHMAC(algorithm, key, input)
+	if err != nil {
+		return false, err
+	}
+
+	return hmac.Equal(retBytes, verBytes), nil
+}
+
+// hmacHashFunc returns the hash constructor for the given algorithm name
+func hmacHashFunc(algorithm string) (func() hash.Hash, error) {
 	switch algorithm {
 	case "sha2-224":
-		hf = hmac.New(sha256.New224, key)
+		return sha256.New224, nil
 	case "sha2-256":
-		hf = hmac.New(sha256.New, key)
+		return sha256.New, nil
 	case "sha2-384":
-		hf = hmac.New(sha512.New384, key)
+		return sha512.New384, nil
 	case "sha2-512":
-		hf = hmac.New(sha512.New, key)
-	default:
-		return logical.ErrorResponse(fmt.Sprintf("unsupported algorithm %s", algorithm)), nil
+		return sha512.New, nil
 	}
-	hf.Write(input)
-	retBytes := hf.Sum(nil)
+	return nil, fmt.Errorf("unsupported algorithm %s", algorithm)
+}
 
-	return &logical.Response{
-		Data: map[string]interface{}{
-			"valid": hmac.Equal(retBytes, verBytes),
-		},
-	}, nil
+func computeHMAC(algorithm string, key, input []byte) ([]byte, error) {
+	hashFunc, err := hmacHashFunc(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	hf := hmac.New(hashFunc, key)
+	hf.Write(input)
+	return hf.Sum(nil), nil
 }
 
 const pathHMACHelpSyn = `Generate an HMAC for input data using the named key`
 
 const pathHMACHelpDesc = `
-Generates an HMAC sum of the given algorithm and key against the given input data.
+Generates an HMAC sum of the given algorithm and key against the given input data,
+or against each item of a batch of input data.
 `
@@ -181,3 +181,97 @@ func TestTransit_HMAC(t *testing.T) {
 		t.Fatalf("expected invalid request error, got %v", err)
 	}
 }
+
+func TestTransit_BatchHMAC(t *testing.T) {
+	var resp *logical.Response
+	var err error
+
+	b, s := createBackendWithStorage(t)
+
+	resp, err = b.HandleRequest(&logical.Request{
+		Storage:   s,
+		Operation: logical.UpdateOperation,
+		Path:      "keys/foo",
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	// Set the HMAC key to something we control
+	p, lock, err := b.lm.GetPolicyShared(s, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock.RUnlock()
+	keyEntry := p.Keys[p.LatestVersion]
+	keyEntry.HMACKey = []byte("01234567890123456789012345678901")
+	p.Keys[p.LatestVersion] = keyEntry
+	if err = p.Persist(s); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err = b.HandleRequest(&logical.Request{
+		Storage:   s,
+		Operation: logical.UpdateOperation,
+		Path:      "hmac/foo/sha2-512",
+		Data: map[string]interface{}{
+			"batch_input": []interface{}{
+				map[string]interface{}{"input": "dGhlIHF1aWNrIGJyb3duIGZveA=="},
+				map[string]interface{}{"input": "not base64"},
+				map[string]interface{}{"input": "dGhlIHF1aWNrIGJyb3duIGZveA=="},
+			},
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	expected := "vault:v1:PSXLXvkvKF4CpU65e2bK1tGBZQpcpCEM32fq2iUoiTyQQCfBcGJJItQ+60tMwWXAPQrC290AzTrNJucGrr4GFA=="
+	results := resp.Data["batch_results"].([]batchResponseHMACItem)
+	if len(results) != 3 {
+		t.Fatalf("bad: expected 3 results, got %d", len(results))
+	}
+	if results[0].HMAC != expected || results[2].HMAC != expected {
+		t.Fatalf("bad: unexpected HMACs in results %#v", results)
+	}
+	if results[1].Error == "" || results[1].HMAC != "" {
+		t.Fatalf("bad: expected an error for the second item, got %#v", results[1])
+	}
+
+	// Verify a batch, one of which has been tampered with
+	resp, err = b.HandleRequest(&logical.Request{
+		Storage:   s,
+		Operation: logical.UpdateOperation,
+		Path:      "verify/foo/sha2-512",
+		Data: map[string]interface{}{
+			"batch_input": []interface{}{
+				map[string]interface{}{"input": "dGhlIHF1aWNrIGJyb3duIGZveA==", "hmac": expected},
+				map[string]interface{}{"input": "Zm9v", "hmac": expected},
+				map[string]interface{}{"input": "dGhlIHF1aWNrIGJyb3duIGZveA==", "hmac": "vault:v2:" + expected[9:]},
+			},
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	results = resp.Data["batch_results"].([]batchResponseHMACItem)
+	if !results[0].Valid || results[1].Valid || results[1].Error != "" || results[2].Error == "" {
+		t.Fatalf("bad: unexpected verification results %#v", results)
+	}
+
+	// Batches mixing HMACs and signatures are rejected
+	resp, err = b.HandleRequest(&logical.Request{
+		Storage:   s,
+		Operation: logical.UpdateOperation,
+		Path:      "verify/foo",
+		Data: map[string]interface{}{
+			"batch_input": []interface{}{
+				map[string]interface{}{"input": "dGhlIHF1aWNrIGJyb3duIGZveA==", "hmac": expected},
+				map[string]interface{}{"input": "dGhlIHF1aWNrIGJyb3duIGZveA==", "signature": expected},
+			},
+		},
+	})
+	if err == nil || resp == nil || !resp.IsError() {
+		t.Fatal("expected an error verifying a mixed batch")
+	}
+}
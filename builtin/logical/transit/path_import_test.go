@@ -0,0 +1,230 @@
+package transit
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	stded25519 "crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"testing"
+
+	"golang.org/x/crypto/ed25519"
+
+	uuid "github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/logical"
+)
+
+func getWrappingPublicKey(t *testing.T, b *backend, s logical.Storage) *rsa.PublicKey {
+	resp, err := b.HandleRequest(&logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "wrapping_key",
+		Storage:   s,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	pub, err := parseWrappingPublicKey(resp.Data["public_key"].(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pub
+}
+
+func TestTransit_Import(t *testing.T) {
+	var resp *logical.Response
+	var err error
+
+	b, s := createBackendWithStorage(t)
+	pub := getWrappingPublicKey(t, b, s)
+
+	// The wrapping key must be stable across reads
+	if pub.N.Cmp(getWrappingPublicKey(t, b, s).N) != 0 {
+		t.Fatal("wrapping key changed between reads")
+	}
+
+	importKey := func(name, keyType string, material []byte, extra map[string]interface{}) *logical.Response {
+		ciphertext, err := wrapKeyMaterial(pub, material)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data := map[string]interface{}{
+			"type":       keyType,
+			"ciphertext": ciphertext,
+			"exportable": true,
+		}
+		for k, v := range extra {
+			data[k] = v
+		}
+		resp, err := b.HandleRequest(&logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "keys/" + name + "/import",
+			Storage:   s,
+			Data:      data,
+		})
+		if err != nil && err != logical.ErrInvalidRequest {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	exportKey := func(exportType, name string) string {
+		resp, err := b.HandleRequest(&logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "export/" + exportType + "/" + name + "/1",
+			Storage:   s,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("err:%v resp:%#v", err, resp)
+		}
+		return resp.Data["keys"].(map[string]string)["1"]
+	}
+
+	// Symmetric key
+	aesKey, err := uuid.GenerateRandomBytes(32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp = importKey("aes", "aes256-gcm96", aesKey, nil)
+	if resp != nil && resp.IsError() {
+		t.Fatalf("bad: got error response: %#v", *resp)
+	}
+	if exportKey("encryption-key", "aes") != base64.StdEncoding.EncodeToString(aesKey) {
+		t.Fatal("exported key does not match imported key")
+	}
+
+	// Importing over an existing key is not allowed
+	resp = importKey("aes", "aes256-gcm96", aesKey, nil)
+	if resp == nil || !resp.IsError() {
+		t.Fatal("expected an error importing over an existing key")
+	}
+
+	// Wrong key sizes are rejected
+	resp = importKey("short", "aes256-gcm96", aesKey[:16], nil)
+	if resp == nil || !resp.IsError() {
+		t.Fatal("expected an error importing a short key")
+	}
+
+	// Imported keys cannot be rotated unless allowed
+	resp, err = b.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "keys/aes/rotate",
+		Storage:   s,
+	})
+	if err == nil || resp == nil || !resp.IsError() {
+		t.Fatal("expected an error rotating an imported key")
+	}
+
+	resp = importKey("aes-rotatable", "aes256-gcm96", aesKey, map[string]interface{}{
+		"allow_rotation": true,
+	})
+	if resp != nil && resp.IsError() {
+		t.Fatalf("bad: got error response: %#v", *resp)
+	}
+	resp, err = b.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "keys/aes-rotatable/rotate",
+		Storage:   s,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	resp, err = b.HandleRequest(&logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "keys/aes-rotatable",
+		Storage:   s,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	if !resp.Data["imported_key"].(bool) || resp.Data["latest_version"].(int) != 2 {
+		t.Fatalf("bad: key data %#v", resp.Data)
+	}
+
+	// Asymmetric keys
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for keyType, key := range map[string]crypto.Signer{
+		"ecdsa-p256": ecKey,
+		"rsa-2048":   rsaKey,
+	} {
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp = importKey(keyType, keyType, der, nil)
+		if resp != nil && resp.IsError() {
+			t.Fatalf("bad: got error response: %#v", *resp)
+		}
+
+		pubDer, err := x509.MarshalPKIXPublicKey(key.Public())
+		if err != nil {
+			t.Fatal(err)
+		}
+		block, _ := pem.Decode([]byte(exportKey("public-key", keyType)))
+		if block == nil || !bytes.Equal(block.Bytes, pubDer) {
+			t.Fatalf("%s: exported public key does not match imported key", keyType)
+		}
+	}
+
+	// RSA keys of the wrong size are rejected
+	der, err := x509.MarshalPKCS8PrivateKey(rsaKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp = importKey("rsa-wrong-size", "rsa-4096", der, nil)
+	if resp == nil || !resp.IsError() {
+		t.Fatal("expected an error importing an RSA key of the wrong size")
+	}
+
+	// ed25519 keys are marshaled using the standard library types, which
+	// share their layout with ours
+	der, err = x509.MarshalPKCS8PrivateKey(stded25519.PrivateKey(edKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp = importKey("ed25519", "ed25519", der, nil)
+	if resp != nil && resp.IsError() {
+		t.Fatalf("bad: got error response: %#v", *resp)
+	}
+	if exportKey("signing-key", "ed25519") != base64.StdEncoding.EncodeToString(edKey) {
+		t.Fatal("exported key does not match imported key")
+	}
+
+	// Material wrapped for a different key cannot be imported
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := wrapKeyMaterial(&otherKey.PublicKey, aesKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, _ = b.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "keys/other/import",
+		Storage:   s,
+		Data: map[string]interface{}{
+			"ciphertext": ciphertext,
+		},
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatal("expected an error importing material wrapped for another key")
+	}
+}
@@ -0,0 +1,75 @@
+package transit
+
+import (
+	"github.com/hashicorp/vault/helper/errutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func (b *backend) pathRestore() *framework.Path {
+	return &framework.Path{
+		Pattern: "restore" + framework.OptionalParamRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "If set, the name of the restored key; defaults to the name of the backed up key",
+			},
+
+			"backup": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "The encrypted backup, as returned by the backup endpoint",
+			},
+
+			"force": &framework.FieldSchema{
+				Type:        framework.TypeBool,
+				Description: "If set, an existing key with the same name is overwritten",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathRestoreWrite,
+		},
+
+		HelpSynopsis:    pathRestoreHelpSyn,
+		HelpDescription: pathRestoreHelpDesc,
+	}
+}
+
+func (b *backend) pathRestoreWrite(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	backup := d.Get("backup").(string)
+	force := d.Get("force").(bool)
+
+	if backup == "" {
+		return logical.ErrorResponse("missing backup to restore"), logical.ErrInvalidRequest
+	}
+
+	wrappingKey, err := b.getWrappingKey(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	keyData, err := unwrapKeyMaterial(wrappingKey, backup)
+	if err == nil {
+		err = b.lm.RestorePolicy(req.Storage, name, keyData, force)
+	}
+	if err != nil {
+		switch err.(type) {
+		case errutil.UserError:
+			return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+		default:
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
+
+const pathRestoreHelpSyn = `Restore the named key`
+
+const pathRestoreHelpDesc = `
+This path is used to restore a key from a backup created by the backup
+endpoint. The backup must have been encrypted to the wrapping key of this
+backend.
+`
@@ -62,8 +62,8 @@ func (b *backend) pathRewrapWrite(
 			return nil, fmt.Errorf("failed to parse batch input: %v", err)
 		}
 
-		if len(batchInputItems) == 0 {
-			return logical.ErrorResponse("missing batch input to process"), logical.ErrInvalidRequest
+		if resp, err := b.checkBatchSize(req.Storage, len(batchInputItems)); resp != nil || err != nil {
+			return resp, err
 		}
 	} else {
 		ciphertext := d.Get("ciphertext").(string)
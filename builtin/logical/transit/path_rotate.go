@@ -1,6 +1,11 @@
 package transit
 
 import (
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/vault/helper/errutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 )
@@ -42,8 +47,88 @@ func (b *backend) pathRotateWrite(
 
 	// Rotate the policy
 	err = p.Rotate(req.Storage)
+	if err != nil {
+		switch err.(type) {
+		case errutil.UserError:
+			return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+		default:
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
+
+// minAutoRotatePeriod is the shortest automatic rotation period that can be
+// configured on a key
+const minAutoRotatePeriod = time.Hour
+
+func validateAutoRotatePeriod(period time.Duration) error {
+	switch {
+	case period < 0:
+		return fmt.Errorf("auto rotate period cannot be negative")
+	case period > 0 && period < minAutoRotatePeriod:
+		return fmt.Errorf("auto rotate period must be 0 to disable or at least %s", minAutoRotatePeriod)
+	}
+	return nil
+}
+
+// periodicFunc is invoked by the RollbackManager, which only runs on the
+// active node, once a minute. It rotates every key whose automatic rotation
+// period has elapsed since its latest version was created.
+func (b *backend) periodicFunc(req *logical.Request) error {
+	names, err := req.Storage.List("policy/")
+	if err != nil {
+		return err
+	}
+
+	var errs *multierror.Error
+	for _, name := range names {
+		if err := b.autoRotateKey(req.Storage, name); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("failed to auto-rotate key %s: %v", name, err))
+		}
+	}
+
+	return errs.ErrorOrNil()
+}
+
+func (b *backend) autoRotateKey(storage logical.Storage, name string) error {
+	now := time.Now()
+
+	// Check with a shared lock first so that keys that don't need rotating
+	// don't block other requests
+	p, lock, err := b.lm.GetPolicyShared(storage, name)
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		return nil
+	}
+	needsRotation := p.NeedsAutoRotation(now)
+	lock.RUnlock()
+	if !needsRotation {
+		return nil
+	}
+
+	p, lock, err = b.lm.GetPolicyExclusive(storage, name)
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		return nil
+	}
+	defer lock.Unlock()
+
+	// The key may have been rotated while the lock was released
+	if !p.NeedsAutoRotation(now) {
+		return nil
+	}
+
+	if b.Logger().IsDebug() {
+		b.Logger().Debug("transit: automatically rotating key", "name", name)
+	}
 
-	return nil, err
+	return p.Rotate(storage)
 }
 
 const pathRotateHelpSyn = `Rotate named encryption key`
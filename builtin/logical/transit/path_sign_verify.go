@@ -1,17 +1,57 @@
 package transit
 
 import (
-	"crypto/sha256"
-	"crypto/sha512"
 	"encoding/base64"
 	"fmt"
-	"hash"
 
 	"github.com/hashicorp/vault/helper/errutil"
+	"github.com/hashicorp/vault/helper/keysutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
+	"github.com/mitchellh/mapstructure"
 )
 
+// batchRequestSignItem represents a request item for batch signing and
+// verification
+type batchRequestSignItem struct {
+	// The base64-encoded input data
+	Input string `json:"input" structs:"input" mapstructure:"input"`
+
+	// Context for key derivation. This is required for derived keys.
+	Context string `json:"context" structs:"context" mapstructure:"context"`
+
+	// The signature to verify, including vault header/key version
+	Signature string `json:"signature" structs:"signature" mapstructure:"signature"`
+
+	// The HMAC to verify; set when a batch of HMACs is verified instead
+	HMAC string `json:"hmac" structs:"hmac" mapstructure:"hmac"`
+}
+
+// batchResponseSignItem represents a response item for batch signing
+type batchResponseSignItem struct {
+	// The signature for the corresponding batch request item
+	Signature string `json:"signature,omitempty" structs:"signature" mapstructure:"signature"`
+
+	// The public key of a derived key used to create the signature
+	PublicKey []byte `json:"public_key,omitempty" structs:"public_key" mapstructure:"public_key"`
+
+	// Error, if set represents a failure encountered while processing a
+	// corresponding batch request item
+	Error string `json:"error,omitempty" structs:"error" mapstructure:"error"`
+}
+
+// batchResponseVerifyItem represents a response item for batch verification
+type batchResponseVerifyItem struct {
+	// Whether the signature of the corresponding batch request item is
+	// valid. It is always present so that an invalid signature is reported
+	// as false rather than left out.
+	Valid bool `json:"valid" structs:"valid" mapstructure:"valid"`
+
+	// Error, if set represents a failure encountered while processing a
+	// corresponding batch request item
+	Error string `json:"error,omitempty" structs:"error" mapstructure:"error"`
+}
+
 func (b *backend) pathSign() *framework.Path {
 	return &framework.Path{
 		Pattern: "sign/" + framework.GenericNameRegex("name") + framework.OptionalParamRegex("urlalgorithm"),
@@ -46,6 +86,17 @@ Defaults to "sha2-256". Not valid for all key types,
 including ed25519.`,
 			},
 
+			"signature_algorithm": &framework.FieldSchema{
+				Type:    framework.TypeString,
+				Default: "pss",
+				Description: `The signature algorithm to use for RSA keys. Valid values are:
+
+* pss
+* pkcs1v15
+
+Defaults to "pss". Ignored for key types other than RSA.`,
+			},
+
 			"urlalgorithm": &framework.FieldSchema{
 				Type:        framework.TypeString,
 				Description: `Hash algorithm to use (POST URL parameter)`,
@@ -115,6 +166,17 @@ derivation is enabled; currently only available with ed25519 keys.`,
 
 Defaults to "sha2-256". Not valid for all key types.`,
 			},
+
+			"signature_algorithm": &framework.FieldSchema{
+				Type:    framework.TypeString,
+				Default: "pss",
+				Description: `The signature algorithm to use for RSA keys. Valid values are:
+
+* pss
+* pkcs1v15
+
+Defaults to "pss". Ignored for key types other than RSA.`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -130,15 +192,30 @@ func (b *backend) pathSignWrite(
 	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 	name := d.Get("name").(string)
 	ver := d.Get("key_version").(int)
-	inputB64 := d.Get("input").(string)
 	algorithm := d.Get("urlalgorithm").(string)
 	if algorithm == "" {
 		algorithm = d.Get("algorithm").(string)
 	}
+	sigAlgorithm := d.Get("signature_algorithm").(string)
 
-	input, err := base64.StdEncoding.DecodeString(inputB64)
-	if err != nil {
-		return logical.ErrorResponse(fmt.Sprintf("unable to decode input as base64: %s", err)), logical.ErrInvalidRequest
+	batchInputRaw := d.Raw["batch_input"]
+	var batchInputItems []batchRequestSignItem
+	if batchInputRaw != nil {
+		err := mapstructure.Decode(batchInputRaw, &batchInputItems)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse batch input: %v", err)
+		}
+
+		if resp, err := b.checkBatchSize(req.Storage, len(batchInputItems)); resp != nil || err != nil {
+			return resp, err
+		}
+	} else {
+		batchInputItems = []batchRequestSignItem{
+			batchRequestSignItem{
+				Input:   d.Get("input").(string),
+				Context: d.Get("context").(string),
+			},
+		}
 	}
 
 	// Get the policy
@@ -157,50 +234,54 @@ func (b *backend) pathSignWrite(
 		return logical.ErrorResponse(fmt.Sprintf("key type %v does not support signing", p.Type)), logical.ErrInvalidRequest
 	}
 
-	contextRaw := d.Get("context").(string)
-	var context []byte
-	if len(contextRaw) != 0 {
-		context, err = base64.StdEncoding.DecodeString(contextRaw)
-		if err != nil {
-			return logical.ErrorResponse("failed to base64-decode context"), logical.ErrInvalidRequest
+	if p.Type.HashSignatureInput() {
+		if _, err := hmacHashFunc(algorithm); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
 		}
 	}
 
-	if p.Type.HashSignatureInput() {
-		var hf hash.Hash
-		switch algorithm {
-		case "sha2-224":
-			hf = sha256.New224()
-		case "sha2-256":
-			hf = sha256.New()
-		case "sha2-384":
-			hf = sha512.New384()
-		case "sha2-512":
-			hf = sha512.New()
-		default:
-			return logical.ErrorResponse(fmt.Sprintf("unsupported algorithm %s", algorithm)), nil
+	batchResponseItems := make([]batchResponseSignItem, len(batchInputItems))
+	for i, item := range batchInputItems {
+		input, context, err := decodeSignItem(p, algorithm, item)
+		if err != nil {
+			batchResponseItems[i].Error = err.Error()
+			continue
 		}
-		hf.Write(input)
-		input = hf.Sum(nil)
-	}
 
-	sig, err := p.Sign(ver, context, input)
-	if err != nil {
-		return nil, err
-	}
-	if sig == nil {
-		return nil, fmt.Errorf("signature could not be computed")
-	}
+		sig, err := p.Sign(ver, context, input, algorithm, sigAlgorithm)
+		if err != nil {
+			switch err.(type) {
+			case errutil.UserError:
+				batchResponseItems[i].Error = err.Error()
+				continue
+			default:
+				return nil, err
+			}
+		}
+		if sig == nil {
+			return nil, fmt.Errorf("signature could not be computed")
+		}
 
-	// Generate the response
-	resp := &logical.Response{
-		Data: map[string]interface{}{
-			"signature": sig.Signature,
-		},
+		batchResponseItems[i].Signature = sig.Signature
+		batchResponseItems[i].PublicKey = sig.PublicKey
 	}
 
-	if len(sig.PublicKey) > 0 {
-		resp.Data["public_key"] = sig.PublicKey
+	// Generate the response
+	resp := &logical.Response{}
+	if batchInputRaw != nil {
+		resp.Data = map[string]interface{}{
+			"batch_results": batchResponseItems,
+		}
+	} else {
+		if batchResponseItems[0].Error != "" {
+			return logical.ErrorResponse(batchResponseItems[0].Error), logical.ErrInvalidRequest
+		}
+		resp.Data = map[string]interface{}{
+			"signature": batchResponseItems[0].Signature,
+		}
+		if len(batchResponseItems[0].PublicKey) > 0 {
+			resp.Data["public_key"] = batchResponseItems[0].PublicKey
+		}
 	}
 
 	return resp, nil
@@ -209,30 +290,70 @@ func (b *backend) pathSignWrite(
 func (b *backend) pathVerifyWrite(
 	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 
-	sig := d.Get("signature").(string)
-	hmac := d.Get("hmac").(string)
-	switch {
-	case sig != "" && hmac != "":
-		return logical.ErrorResponse("provide one of 'signature' or 'hmac'"), logical.ErrInvalidRequest
+	batchInputRaw := d.Raw["batch_input"]
+	var batchInputItems []batchRequestSignItem
+	if batchInputRaw != nil {
+		err := mapstructure.Decode(batchInputRaw, &batchInputItems)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse batch input: %v", err)
+		}
+
+		if resp, err := b.checkBatchSize(req.Storage, len(batchInputItems)); resp != nil || err != nil {
+			return resp, err
+		}
 
-	case sig == "" && hmac == "":
-		return logical.ErrorResponse("neither a 'signature' nor an 'hmac' were given to verify"), logical.ErrInvalidRequest
+		// A batch verifies either signatures or HMACs, never a mix of both
+		var sigCount, hmacCount int
+		for _, item := range batchInputItems {
+			if item.Signature != "" {
+				sigCount++
+			}
+			if item.HMAC != "" {
+				hmacCount++
+			}
+		}
+		switch {
+		case hmacCount == len(batchInputItems) && sigCount == 0:
+			var hmacItems []batchRequestHMACItem
+			if err := mapstructure.Decode(batchInputRaw, &hmacItems); err != nil {
+				return nil, fmt.Errorf("failed to parse batch input: %v", err)
+			}
+			return b.pathHMACVerify(req, d, hmacItems)
+
+		case sigCount == len(batchInputItems) && hmacCount == 0:
+
+		default:
+			return logical.ErrorResponse("each batch input item must provide a 'signature', or each must provide an 'hmac'"), logical.ErrInvalidRequest
+		}
+	} else {
+		sig := d.Get("signature").(string)
+		hmac := d.Get("hmac").(string)
+		switch {
+		case sig != "" && hmac != "":
+			return logical.ErrorResponse("provide one of 'signature' or 'hmac'"), logical.ErrInvalidRequest
+
+		case sig == "" && hmac == "":
+			return logical.ErrorResponse("neither a 'signature' nor an 'hmac' were given to verify"), logical.ErrInvalidRequest
+
+		case hmac != "":
+			return b.pathHMACVerify(req, d, nil)
+		}
 
-	case hmac != "":
-		return b.pathHMACVerify(req, d, hmac)
+		batchInputItems = []batchRequestSignItem{
+			batchRequestSignItem{
+				Input:     d.Get("input").(string),
+				Context:   d.Get("context").(string),
+				Signature: sig,
+			},
+		}
 	}
 
 	name := d.Get("name").(string)
-	inputB64 := d.Get("input").(string)
 	algorithm := d.Get("urlalgorithm").(string)
 	if algorithm == "" {
 		algorithm = d.Get("algorithm").(string)
 	}
-
-	input, err := base64.StdEncoding.DecodeString(inputB64)
-	if err != nil {
-		return logical.ErrorResponse(fmt.Sprintf("unable to decode input as base64: %s", err)), logical.ErrInvalidRequest
-	}
+	sigAlgorithm := d.Get("signature_algorithm").(string)
 
 	// Get the policy
 	p, lock, err := b.lm.GetPolicyShared(req.Storage, name)
@@ -250,61 +371,88 @@ func (b *backend) pathVerifyWrite(
 		return logical.ErrorResponse(fmt.Sprintf("key type %v does not support verification", p.Type)), logical.ErrInvalidRequest
 	}
 
-	contextRaw := d.Get("context").(string)
-	var context []byte
-	if len(contextRaw) != 0 {
-		context, err = base64.StdEncoding.DecodeString(contextRaw)
+	if p.Type.HashSignatureInput() {
+		if _, err := hmacHashFunc(algorithm); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+	}
+
+	batchResponseItems := make([]batchResponseVerifyItem, len(batchInputItems))
+	for i, item := range batchInputItems {
+		input, context, err := decodeSignItem(p, algorithm, item)
 		if err != nil {
-			return logical.ErrorResponse("failed to base64-decode context"), logical.ErrInvalidRequest
+			batchResponseItems[i].Error = err.Error()
+			continue
 		}
+
+		valid, err := p.VerifySignature(context, input, item.Signature, algorithm, sigAlgorithm)
+		if err != nil {
+			switch err.(type) {
+			case errutil.UserError:
+				batchResponseItems[i].Error = err.Error()
+				continue
+			default:
+				return nil, err
+			}
+		}
+		batchResponseItems[i].Valid = valid
 	}
 
-	if p.Type.HashSignatureInput() {
-		var hf hash.Hash
-		switch algorithm {
-		case "sha2-224":
-			hf = sha256.New224()
-		case "sha2-256":
-			hf = sha256.New()
-		case "sha2-384":
-			hf = sha512.New384()
-		case "sha2-512":
-			hf = sha512.New()
-		default:
-			return logical.ErrorResponse(fmt.Sprintf("unsupported algorithm %s", algorithm)), nil
+	// Generate the response
+	resp := &logical.Response{}
+	if batchInputRaw != nil {
+		resp.Data = map[string]interface{}{
+			"batch_results": batchResponseItems,
+		}
+	} else {
+		if batchResponseItems[0].Error != "" {
+			return logical.ErrorResponse(batchResponseItems[0].Error), logical.ErrInvalidRequest
+		}
+		resp.Data = map[string]interface{}{
+			"valid": batchResponseItems[0].Valid,
 		}
-		hf.Write(input)
-		input = hf.Sum(nil)
 	}
+	return resp, nil
+}
 
-	valid, err := p.VerifySignature(context, input, sig)
+// decodeSignItem decodes the input and context of a sign or verify item and,
+// if the key type requires it, hashes the input with the given algorithm
+func decodeSignItem(p *keysutil.Policy, algorithm string, item batchRequestSignItem) ([]byte, []byte, error) {
+	input, err := base64.StdEncoding.DecodeString(item.Input)
 	if err != nil {
-		switch err.(type) {
-		case errutil.UserError:
-			return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
-		case errutil.InternalError:
-			return nil, err
-		default:
-			return nil, err
+		return nil, nil, fmt.Errorf("unable to decode input as base64: %s", err)
+	}
+
+	var context []byte
+	if len(item.Context) != 0 {
+		context, err = base64.StdEncoding.DecodeString(item.Context)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to base64-decode context")
 		}
 	}
 
-	// Generate the response
-	resp := &logical.Response{
-		Data: map[string]interface{}{
-			"valid": valid,
-		},
+	if p.Type.HashSignatureInput() {
+		hashFunc, err := hmacHashFunc(algorithm)
+		if err != nil {
+			return nil, nil, err
+		}
+		hf := hashFunc()
+		hf.Write(input)
+		input = hf.Sum(nil)
 	}
-	return resp, nil
+
+	return input, context, nil
 }
 
 const pathSignHelpSyn = `Generate a signature for input data using the named key`
 
 const pathSignHelpDesc = `
-Generates a signature of the input data using the named key and the given hash algorithm.
+Generates a signature of the input data, or of each item of a batch of input data,
+using the named key and the given hash algorithm. RSA keys additionally accept the signature algorithm to use, either PSS or PKCS#1v15.
 `
 const pathVerifyHelpSyn = `Verify a signature or HMAC for input data created using the named key`
 
 const pathVerifyHelpDesc = `
-Verifies a signature or HMAC of the input data using the named key and the given hash algorithm.
+Verifies a signature or HMAC of the input data, or of each item of a batch of input
+data, using the named key and the given hash algorithm.
 `
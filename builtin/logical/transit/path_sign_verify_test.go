@@ -1,7 +1,12 @@
 package transit
 
 import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/x509"
 	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
 	"strings"
 	"testing"
 
@@ -411,3 +416,339 @@ func TestTransit_SignVerify_ED25519(t *testing.T) {
 	verifyRequest(req, false, "bar", sig)
 	verifyRequest(req, true, "bar", v1sig)
 }
+
+func TestTransit_SignVerify_RSA(t *testing.T) {
+	var b *backend
+	sysView := logical.TestSystemView()
+	storage := &logical.InmemStorage{}
+
+	b = Backend(&logical.BackendConfig{
+		StorageView: storage,
+		System:      sysView,
+	})
+
+	// First create a key
+	req := &logical.Request{
+		Storage:   storage,
+		Operation: logical.UpdateOperation,
+		Path:      "keys/foo",
+		Data: map[string]interface{}{
+			"type": "rsa-2048",
+		},
+	}
+	_, err := b.HandleRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Fetch the public key so signatures can be checked independently
+	req = &logical.Request{
+		Storage:   storage,
+		Operation: logical.ReadOperation,
+		Path:      "keys/foo",
+	}
+	resp, err := b.HandleRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	val := resp.Data["keys"].(map[string]map[string]interface{})["1"]
+	var ak asymKey
+	if err := mapstructure.Decode(val, &ak); err != nil {
+		t.Fatal(err)
+	}
+	if ak.Name != "rsa-2048" {
+		t.Fatalf("bad key name %q", ak.Name)
+	}
+	block, _ := pem.Decode([]byte(ak.PublicKey))
+	if block == nil {
+		t.Fatalf("could not decode public key %q", ak.PublicKey)
+	}
+	pubRaw, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := pubRaw.(*rsa.PublicKey)
+
+	signRequest := func(req *logical.Request, errExpected bool) string {
+		req.Path = "sign/foo"
+		resp, err := b.HandleRequest(req)
+		if err != nil && !errExpected {
+			t.Fatal(err)
+		}
+		if resp == nil {
+			t.Fatal("expected non-nil response")
+		}
+		if errExpected {
+			if !resp.IsError() {
+				t.Fatalf("bad: should have gotten error response: %#v", *resp)
+			}
+			return ""
+		}
+		if resp.IsError() {
+			t.Fatalf("bad: got error response: %#v", *resp)
+		}
+		value, ok := resp.Data["signature"]
+		if !ok {
+			t.Fatalf("no signature key found in returned data, got resp data %#v", resp.Data)
+		}
+		return value.(string)
+	}
+
+	verifyRequest := func(req *logical.Request, sig string) bool {
+		req.Path = "verify/foo"
+		req.Data["signature"] = sig
+		resp, err := b.HandleRequest(req)
+		if err != nil {
+			t.Fatalf("got error: %v, sig was %v", err, sig)
+		}
+		if resp == nil {
+			t.Fatal("expected non-nil response")
+		}
+		if resp.IsError() {
+			t.Fatalf("bad: got error response: %#v", *resp)
+		}
+		delete(req.Data, "signature")
+		return resp.Data["valid"].(bool)
+	}
+
+	input := []byte("the quick brown fox")
+	hashes := map[string]crypto.Hash{
+		"sha2-224": crypto.SHA224,
+		"sha2-256": crypto.SHA256,
+		"sha2-384": crypto.SHA384,
+		"sha2-512": crypto.SHA512,
+	}
+
+	for algorithm, hashType := range hashes {
+		hf := hashType.New()
+		hf.Write(input)
+		digest := hf.Sum(nil)
+
+		for _, sigAlgorithm := range []string{"pss", "pkcs1v15"} {
+			req.Operation = logical.UpdateOperation
+			req.Data = map[string]interface{}{
+				"input":               base64.StdEncoding.EncodeToString(input),
+				"algorithm":           algorithm,
+				"signature_algorithm": sigAlgorithm,
+			}
+
+			sig := signRequest(req, false)
+			if !verifyRequest(req, sig) {
+				t.Fatalf("%s/%s: signature did not verify", algorithm, sigAlgorithm)
+			}
+
+			sigBytes, err := base64.StdEncoding.DecodeString(strings.Split(sig, ":")[2])
+			if err != nil {
+				t.Fatal(err)
+			}
+			switch sigAlgorithm {
+			case "pss":
+				err = rsa.VerifyPSS(pub, hashType, digest, sigBytes, nil)
+			case "pkcs1v15":
+				err = rsa.VerifyPKCS1v15(pub, hashType, digest, sigBytes)
+			}
+			if err != nil {
+				t.Fatalf("%s/%s: signature did not verify with public key: %v", algorithm, sigAlgorithm, err)
+			}
+
+			// A mismatched signature algorithm must not verify
+			if sigAlgorithm == "pss" {
+				req.Data["signature_algorithm"] = "pkcs1v15"
+			} else {
+				req.Data["signature_algorithm"] = "pss"
+			}
+			if verifyRequest(req, sig) {
+				t.Fatalf("%s/%s: signature verified with the wrong signature algorithm", algorithm, sigAlgorithm)
+			}
+		}
+	}
+
+	// Unknown signature algorithms are rejected
+	req.Data = map[string]interface{}{
+		"input":               base64.StdEncoding.EncodeToString(input),
+		"signature_algorithm": "foo",
+	}
+	signRequest(req, true)
+}
+
+func TestTransit_BatchSignVerify(t *testing.T) {
+	var resp *logical.Response
+	var err error
+
+	b, s := createBackendWithStorage(t)
+
+	for _, keyType := range []string{"ecdsa-p256", "ed25519"} {
+		resp, err = b.HandleRequest(&logical.Request{
+			Storage:   s,
+			Operation: logical.UpdateOperation,
+			Path:      "keys/" + keyType,
+			Data: map[string]interface{}{
+				"type": keyType,
+			},
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("err:%v resp:%#v", err, resp)
+		}
+
+		resp, err = b.HandleRequest(&logical.Request{
+			Storage:   s,
+			Operation: logical.UpdateOperation,
+			Path:      "sign/" + keyType,
+			Data: map[string]interface{}{
+				"batch_input": []interface{}{
+					map[string]interface{}{"input": "dGhlIHF1aWNrIGJyb3duIGZveA=="},
+					map[string]interface{}{"input": "not base64"},
+					map[string]interface{}{"input": "anVtcHMgb3ZlciB0aGUgbGF6eSBkb2c="},
+				},
+			},
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("err:%v resp:%#v", err, resp)
+		}
+		results := resp.Data["batch_results"].([]batchResponseSignItem)
+		if len(results) != 3 {
+			t.Fatalf("bad: expected 3 results, got %d", len(results))
+		}
+		if results[0].Signature == "" || results[2].Signature == "" {
+			t.Fatalf("bad: missing signatures in results %#v", results)
+		}
+		if results[1].Error == "" || results[1].Signature != "" {
+			t.Fatalf("bad: expected an error for the second item, got %#v", results[1])
+		}
+
+		// Swap the signatures of the two inputs so they no longer match
+		resp, err = b.HandleRequest(&logical.Request{
+			Storage:   s,
+			Operation: logical.UpdateOperation,
+			Path:      "verify/" + keyType,
+			Data: map[string]interface{}{
+				"batch_input": []interface{}{
+					map[string]interface{}{"input": "dGhlIHF1aWNrIGJyb3duIGZveA==", "signature": results[0].Signature},
+					map[string]interface{}{"input": "dGhlIHF1aWNrIGJyb3duIGZveA==", "signature": results[2].Signature},
+					map[string]interface{}{"input": "anVtcHMgb3ZlciB0aGUgbGF6eSBkb2c=", "signature": results[2].Signature},
+				},
+			},
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("err:%v resp:%#v", err, resp)
+		}
+		verifyResults := resp.Data["batch_results"].([]batchResponseVerifyItem)
+		if !verifyResults[0].Valid || !verifyResults[2].Valid {
+			t.Fatalf("%s: expected valid signatures, got %#v", keyType, verifyResults)
+		}
+		if verifyResults[1].Valid {
+			t.Fatalf("%s: expected an invalid signature, got %#v", keyType, verifyResults[1])
+		}
+
+		// An invalid signature is reported rather than left out
+		encoded, err := json.Marshal(verifyResults[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(encoded) != `{"valid":false}` {
+			t.Fatalf("%s: bad encoding of an invalid signature: %s", keyType, encoded)
+		}
+	}
+}
+
+func TestTransit_BatchSize(t *testing.T) {
+	b, s := createBackendWithStorage(t)
+
+	batchInput := make([]interface{}, defaultMaxBatchSize+1)
+	for i := range batchInput {
+		batchInput[i] = map[string]interface{}{"plaintext": "dGhlIHF1aWNrIGJyb3duIGZveA=="}
+	}
+
+	resp, err := b.HandleRequest(&logical.Request{
+		Storage:   s,
+		Operation: logical.CreateOperation,
+		Path:      "encrypt/foo",
+		Data: map[string]interface{}{
+			"batch_input": batchInput,
+		},
+	})
+	if err == nil || resp == nil || !resp.IsError() {
+		t.Fatal("expected an error for a batch that is too large")
+	}
+
+	resp, err = b.HandleRequest(&logical.Request{
+		Storage:   s,
+		Operation: logical.CreateOperation,
+		Path:      "encrypt/foo",
+		Data: map[string]interface{}{
+			"batch_input": batchInput[:defaultMaxBatchSize],
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	if len(resp.Data["batch_results"].([]BatchResponseItem)) != defaultMaxBatchSize {
+		t.Fatal("bad: unexpected number of results")
+	}
+
+	// Lower the limit of the mount and make sure it is enforced
+	resp, err = b.HandleRequest(&logical.Request{
+		Storage:   s,
+		Operation: logical.UpdateOperation,
+		Path:      "config/batch",
+		Data: map[string]interface{}{
+			"max_batch_size": 0,
+		},
+	})
+	if err == nil || resp == nil || !resp.IsError() {
+		t.Fatal("expected an error for a zero batch limit")
+	}
+
+	resp, err = b.HandleRequest(&logical.Request{
+		Storage:   s,
+		Operation: logical.UpdateOperation,
+		Path:      "config/batch",
+		Data: map[string]interface{}{
+			"max_batch_size": 2,
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	resp, err = b.HandleRequest(&logical.Request{
+		Storage:   s,
+		Operation: logical.ReadOperation,
+		Path:      "config/batch",
+	})
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	if resp.Data["max_batch_size"].(int) != 2 {
+		t.Fatalf("bad: max_batch_size: %v", resp.Data["max_batch_size"])
+	}
+
+	resp, err = b.HandleRequest(&logical.Request{
+		Storage:   s,
+		Operation: logical.CreateOperation,
+		Path:      "encrypt/foo",
+		Data: map[string]interface{}{
+			"batch_input": batchInput[:3],
+		},
+	})
+	if err == nil || resp == nil || !resp.IsError() {
+		t.Fatal("expected an error for a batch exceeding the configured limit")
+	}
+
+	// The limit is loaded from storage once the cached value is invalidated
+	b.invalidate(batchConfigStoragePath)
+	resp, err = b.HandleRequest(&logical.Request{
+		Storage:   s,
+		Operation: logical.CreateOperation,
+		Path:      "encrypt/foo",
+		Data: map[string]interface{}{
+			"batch_input": batchInput[:2],
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	if len(resp.Data["batch_results"].([]BatchResponseItem)) != 2 {
+		t.Fatal("bad: unexpected number of results")
+	}
+}
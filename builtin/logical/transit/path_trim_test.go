@@ -0,0 +1,144 @@
+package transit
+
+import (
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestTransit_Trim(t *testing.T) {
+	b, s := createBackendWithStorage(t)
+
+	doReq := func(req *logical.Request) *logical.Response {
+		req.Storage = s
+		resp, err := b.HandleRequest(req)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("err:%v resp:%#v", err, resp)
+		}
+		return resp
+	}
+	doErrReq := func(req *logical.Request) {
+		req.Storage = s
+		resp, err := b.HandleRequest(req)
+		if err == nil && (resp == nil || !resp.IsError()) {
+			t.Fatalf("expected error; resp:%#v", resp)
+		}
+	}
+
+	doReq(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "keys/aes",
+	})
+	for i := 0; i < 9; i++ {
+		doReq(&logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "keys/aes/rotate",
+		})
+	}
+
+	// Versions still in use for decryption cannot be trimmed
+	doErrReq(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "keys/aes/trim",
+		Data: map[string]interface{}{
+			"min_available_version": 2,
+		},
+	})
+	doErrReq(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "keys/aes/trim",
+		Data: map[string]interface{}{
+			"min_available_version": 0,
+		},
+	})
+
+	doReq(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "keys/aes/config",
+		Data: map[string]interface{}{
+			"min_decryption_version": 7,
+			"min_encryption_version": 9,
+		},
+	})
+
+	// Cannot trim past the minimum decryption version
+	doErrReq(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "keys/aes/trim",
+		Data: map[string]interface{}{
+			"min_available_version": 8,
+		},
+	})
+
+	doReq(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "keys/aes/trim",
+		Data: map[string]interface{}{
+			"min_available_version": 5,
+		},
+	})
+
+	resp := doReq(&logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "keys/aes",
+	})
+	if resp.Data["min_available_version"].(int) != 5 {
+		t.Fatalf("bad: min_available_version: %#v", resp.Data["min_available_version"])
+	}
+
+	// Check that the trimmed versions are gone from the archive
+	p, lock, err := b.lm.GetPolicyShared(s, "aes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive, err := p.LoadArchive(s)
+	lock.RUnlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 10; i++ {
+		if trimmed := archive.Keys[i].Key == nil; trimmed != (i < 5) {
+			t.Fatalf("bad: version %d trimmed: %t", i, trimmed)
+		}
+	}
+
+	// The minimum available version cannot be decreased, nor can the
+	// minimum decryption version be moved into the trimmed range
+	doErrReq(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "keys/aes/trim",
+		Data: map[string]interface{}{
+			"min_available_version": 4,
+		},
+	})
+	doErrReq(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "keys/aes/config",
+		Data: map[string]interface{}{
+			"min_decryption_version": 4,
+		},
+	})
+
+	// Moving the minimum decryption version back down to the minimum
+	// available version restores those versions from the archive
+	doReq(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "keys/aes/config",
+		Data: map[string]interface{}{
+			"min_decryption_version": 5,
+		},
+	})
+	p, lock, err = b.lm.GetPolicyShared(s, "aes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.RUnlock()
+	if len(p.Keys) != 6 {
+		t.Fatalf("bad: expected 6 key versions, got %d", len(p.Keys))
+	}
+	for i := 5; i <= 10; i++ {
+		if p.Keys[i].Key == nil {
+			t.Fatalf("bad: version %d missing", i)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package transit
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+
+	uuid "github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/helper/errutil"
+	"github.com/hashicorp/vault/helper/jsonutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const (
+	wrappingKeyStoragePath = "wrapping_key"
+	wrappingKeyBits        = 4096
+)
+
+// wrappingKeyEntry is the storage representation of the RSA key used to
+// unwrap key material sent to the backend
+type wrappingKeyEntry struct {
+	// PKCS#1, DER-encoded private key
+	Key []byte `json:"key"`
+}
+
+func (b *backend) pathWrappingKey() *framework.Path {
+	return &framework.Path{
+		Pattern: "wrapping_key",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathWrappingKeyRead,
+		},
+
+		HelpSynopsis:    pathWrappingKeyHelpSyn,
+		HelpDescription: pathWrappingKeyHelpDesc,
+	}
+}
+
+func (b *backend) pathWrappingKeyRead(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	key, err := b.getWrappingKey(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	derBytes, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling wrapping key: %v", err)
+	}
+	pemBytes := pem.EncodeToMemory(&pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: derBytes,
+	})
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"public_key": string(pemBytes),
+		},
+	}, nil
+}
+
+// getWrappingKey returns the wrapping key of the backend, generating and
+// storing it on first use
+func (b *backend) getWrappingKey(storage logical.Storage) (*rsa.PrivateKey, error) {
+	b.wrappingKeyLock.RLock()
+	key := b.wrappingKey
+	b.wrappingKeyLock.RUnlock()
+	if key != nil {
+		return key, nil
+	}
+
+	b.wrappingKeyLock.Lock()
+	defer b.wrappingKeyLock.Unlock()
+
+	// Check to make sure it hasn't been loaded since
+	if b.wrappingKey != nil {
+		return b.wrappingKey, nil
+	}
+
+	raw, err := storage.Get(wrappingKeyStoragePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if raw != nil {
+		var entry wrappingKeyEntry
+		if err := jsonutil.DecodeJSON(raw.Value, &entry); err != nil {
+			return nil, err
+		}
+		key, err = x509.ParsePKCS1PrivateKey(entry.Key)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing stored wrapping key: %v", err)
+		}
+	} else {
+		key, err = rsa.GenerateKey(rand.Reader, wrappingKeyBits)
+		if err != nil {
+			return nil, err
+		}
+		storageEntry, err := logical.StorageEntryJSON(wrappingKeyStoragePath, &wrappingKeyEntry{
+			Key: x509.MarshalPKCS1PrivateKey(key),
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := storage.Put(storageEntry); err != nil {
+			return nil, err
+		}
+	}
+
+	b.wrappingKey = key
+	return key, nil
+}
+
+// wrapKeyMaterial encrypts the given material for the holder of the private
+// half of pub. A random AES-256 key is encrypted with RSA-OAEP using SHA-256
+// and prepended to the material encrypted with that key using AES-GCM. The
+// result is base64-encoded.
+func wrapKeyMaterial(pub *rsa.PublicKey, material []byte) (string, error) {
+	ephemeralKey, err := uuid.GenerateRandomBytes(32)
+	if err != nil {
+		return "", err
+	}
+
+	wrappedKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, pub, ephemeralKey, nil)
+	if err != nil {
+		return "", err
+	}
+
+	aesCipher, err := aes.NewCipher(ephemeralKey)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(aesCipher)
+	if err != nil {
+		return "", err
+	}
+	nonce, err := uuid.GenerateRandomBytes(gcm.NonceSize())
+	if err != nil {
+		return "", err
+	}
+
+	out := append(wrappedKey, nonce...)
+	out = gcm.Seal(out, nonce, material, nil)
+
+	return base64.StdEncoding.EncodeToString(out), nil
+}
+
+// unwrapKeyMaterial reverses wrapKeyMaterial using the given private key
+func unwrapKeyMaterial(key *rsa.PrivateKey, ciphertext string) ([]byte, error) {
+	decoded, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return nil, errutil.UserError{Err: "failed to base64-decode ciphertext"}
+	}
+
+	keySize := key.PublicKey.N.BitLen() / 8
+	if len(decoded) < keySize {
+		return nil, errutil.UserError{Err: "invalid ciphertext: too short"}
+	}
+
+	ephemeralKey, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, key, decoded[:keySize], nil)
+	if err != nil {
+		return nil, errutil.UserError{Err: "invalid ciphertext: unable to unwrap the ephemeral key"}
+	}
+
+	aesCipher, err := aes.NewCipher(ephemeralKey)
+	if err != nil {
+		return nil, errutil.UserError{Err: fmt.Sprintf("invalid ephemeral key: %v", err)}
+	}
+	gcm, err := cipher.NewGCM(aesCipher)
+	if err != nil {
+		return nil, errutil.InternalError{Err: err.Error()}
+	}
+
+	rest := decoded[keySize:]
+	if len(rest) < gcm.NonceSize() {
+		return nil, errutil.UserError{Err: "invalid ciphertext: too short"}
+	}
+
+	material, err := gcm.Open(nil, rest[:gcm.NonceSize()], rest[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, errutil.UserError{Err: "invalid ciphertext: unable to decrypt the key material"}
+	}
+
+	return material, nil
+}
+
+// parseWrappingPublicKey parses a PEM-encoded RSA public key, as returned by
+// the wrapping_key endpoint
+func parseWrappingPublicKey(pemKey string) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(pemKey))
+	if block == nil {
+		return nil, errutil.UserError{Err: "failed to PEM-decode the public key"}
+	}
+
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, errutil.UserError{Err: fmt.Sprintf("failed to parse the public key: %v", err)}
+	}
+
+	rsaPub, ok := pub.(*rsa.PublicKey)
+	if !ok {
+		return nil, errutil.UserError{Err: "the public key is not an RSA key"}
+	}
+
+	return rsaPub, nil
+}
+
+const pathWrappingKeyHelpSyn = `Returns the public key to use for wrapping imported keys`
+
+const pathWrappingKeyHelpDesc = `
+This path is used to retrieve the RSA-4096 public key used to wrap key
+material for import and for restoring backups. The key material should be
+encrypted with an ephemeral AES-256 key using AES-GCM, and the ephemeral key
+encrypted with this public key using RSA-OAEP and SHA-256. The wrapped
+ephemeral key, the 12-byte GCM nonce and the encrypted key material are then
+concatenated and base64-encoded.
+`
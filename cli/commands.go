@@ -4,6 +4,8 @@ import (
 	"os"
 
 	auditFile "github.com/hashicorp/vault/builtin/audit/file"
+	auditHTTP "github.com/hashicorp/vault/builtin/audit/http"
+	auditKafka "github.com/hashicorp/vault/builtin/audit/kafka"
 	auditSocket "github.com/hashicorp/vault/builtin/audit/socket"
 	auditSyslog "github.com/hashicorp/vault/builtin/audit/syslog"
 	"github.com/hashicorp/vault/physical"
@@ -36,10 +38,12 @@ import (
 	physSwift "github.com/hashicorp/vault/physical/swift"
 	physZooKeeper "github.com/hashicorp/vault/physical/zookeeper"
 
+	"github.com/hashicorp/vault/builtin/logical/ad"
 	"github.com/hashicorp/vault/builtin/logical/aws"
 	"github.com/hashicorp/vault/builtin/logical/cassandra"
 	"github.com/hashicorp/vault/builtin/logical/consul"
 	"github.com/hashicorp/vault/builtin/logical/database"
+	"github.com/hashicorp/vault/builtin/logical/kubernetes"
 	"github.com/hashicorp/vault/builtin/logical/mongodb"
 	"github.com/hashicorp/vault/builtin/logical/mssql"
 	"github.com/hashicorp/vault/builtin/logical/mysql"
@@ -74,6 +78,17 @@ func Commands(metaPtr *meta.Meta) map[string]cli.CommandFactory {
 		}
 	}
 
+	authHandlers := map[string]command.AuthHandler{
+		"approle":  &credAppRole.CLIHandler{},
+		"github":   &credGitHub.CLIHandler{},
+		"userpass": &credUserpass.CLIHandler{DefaultMount: "userpass"},
+		"ldap":     &credLdap.CLIHandler{},
+		"okta":     &credOkta.CLIHandler{},
+		"cert":     &credCert.CLIHandler{},
+		"aws":      &credAws.CLIHandler{},
+		"radius":   &credUserpass.CLIHandler{DefaultMount: "radius"},
+	}
+
 	return map[string]cli.CommandFactory{
 		"init": func() (cli.Command, error) {
 			return &command.InitCommand{
@@ -87,6 +102,8 @@ func Commands(metaPtr *meta.Meta) map[string]cli.CommandFactory {
 					"file":   auditFile.Factory,
 					"syslog": auditSyslog.Factory,
 					"socket": auditSocket.Factory,
+					"http":   auditHTTP.Factory,
+					"kafka":  auditKafka.Factory,
 				},
 				CredentialBackends: map[string]logical.Factory{
 					"approle":  credAppRole.Factory,
@@ -101,6 +118,7 @@ func Commands(metaPtr *meta.Meta) map[string]cli.CommandFactory {
 					"plugin":   plugin.Factory,
 				},
 				LogicalBackends: map[string]logical.Factory{
+					"ad":         ad.Factory,
 					"aws":        aws.Factory,
 					"consul":     consul.Factory,
 					"postgresql": postgresql.Factory,
@@ -113,6 +131,7 @@ func Commands(metaPtr *meta.Meta) map[string]cli.CommandFactory {
 					"ssh":        ssh.Factory,
 					"rabbitmq":   rabbitmq.Factory,
 					"database":   database.Factory,
+					"kubernetes": kubernetes.Factory,
 					"totp":       totp.Factory,
 					"plugin":     plugin.Factory,
 				},
@@ -148,6 +167,14 @@ func Commands(metaPtr *meta.Meta) map[string]cli.CommandFactory {
 			return c, nil
 		},
 
+		"agent": func() (cli.Command, error) {
+			return &command.AgentCommand{
+				Meta:       *metaPtr,
+				Handlers:   authHandlers,
+				ShutdownCh: command.MakeShutdownCh(),
+			}, nil
+		},
+
 		"ssh": func() (cli.Command, error) {
 			return &command.SSHCommand{
 				Meta: *metaPtr,
@@ -162,16 +189,8 @@ func Commands(metaPtr *meta.Meta) map[string]cli.CommandFactory {
 
 		"auth": func() (cli.Command, error) {
 			return &command.AuthCommand{
-				Meta: *metaPtr,
-				Handlers: map[string]command.AuthHandler{
-					"github":   &credGitHub.CLIHandler{},
-					"userpass": &credUserpass.CLIHandler{DefaultMount: "userpass"},
-					"ldap":     &credLdap.CLIHandler{},
-					"okta":     &credOkta.CLIHandler{},
-					"cert":     &credCert.CLIHandler{},
-					"aws":      &credAws.CLIHandler{},
-					"radius":   &credUserpass.CLIHandler{DefaultMount: "radius"},
-				},
+				Meta:     *metaPtr,
+				Handlers: authHandlers,
 			}, nil
 		},
 
@@ -205,6 +224,12 @@ func Commands(metaPtr *meta.Meta) map[string]cli.CommandFactory {
 			}, nil
 		},
 
+		"audit-verify": func() (cli.Command, error) {
+			return &command.AuditVerifyCommand{
+				Meta: *metaPtr,
+			}, nil
+		},
+
 		"key-status": func() (cli.Command, error) {
 			return &command.KeyStatusCommand{
 				Meta: *metaPtr,
@@ -0,0 +1,436 @@
+package command
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	colorable "github.com/mattn/go-colorable"
+	log "github.com/mgutz/logxi/v1"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/command/agent/auth"
+	"github.com/hashicorp/vault/command/agent/cache"
+	"github.com/hashicorp/vault/command/agent/config"
+	"github.com/hashicorp/vault/command/agent/sink"
+	"github.com/hashicorp/vault/command/agent/template"
+	"github.com/hashicorp/vault/command/server"
+	"github.com/hashicorp/vault/helper/gated-writer"
+	"github.com/hashicorp/vault/helper/logformat"
+	"github.com/hashicorp/vault/meta"
+)
+
+// AgentCommand is a Command that runs the Vault agent, which keeps a token
+// for the configured auth method, writes it to sinks, and proxies and caches
+// requests to Vault.
+type AgentCommand struct {
+	meta.Meta
+
+	// Handlers are the credential providers auto-auth can use, the same as
+	// those of "vault auth"
+	Handlers map[string]AuthHandler
+
+	ShutdownCh chan struct{}
+
+	logGate *gatedwriter.Writer
+	logger  log.Logger
+
+	tokenLock sync.RWMutex
+	token     string
+}
+
+func (c *AgentCommand) Run(args []string) int {
+	var configPath, logLevel string
+	var exitAfterAuth bool
+	flags := c.Meta.FlagSet("agent", meta.FlagSetNone)
+	flags.StringVar(&configPath, "config", "", "")
+	flags.StringVar(&logLevel, "log-level", "info", "")
+	flags.BoolVar(&exitAfterAuth, "exit-after-auth", false, "")
+	flags.Usage = func() { c.Ui.Output(c.Help()) }
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	if configPath == "" {
+		c.Ui.Error("A configuration file must be specified with -config")
+		flags.Usage()
+		return 1
+	}
+
+	c.logGate = &gatedwriter.Writer{Writer: colorable.NewColorable(os.Stderr)}
+	var level int
+	switch strings.ToLower(strings.TrimSpace(logLevel)) {
+	case "trace":
+		level = log.LevelTrace
+	case "debug":
+		level = log.LevelDebug
+	case "info":
+		level = log.LevelInfo
+	case "notice":
+		level = log.LevelNotice
+	case "warn":
+		level = log.LevelWarn
+	case "err":
+		level = log.LevelError
+	default:
+		c.Ui.Error(fmt.Sprintf("Unknown log level %s", logLevel))
+		return 1
+	}
+	c.logger = logformat.NewVaultLoggerWithWriter(c.logGate, level)
+
+	conf, err := config.LoadConfig(configPath)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error loading configuration from %s: %s", configPath, err))
+		return 1
+	}
+	if conf.AutoAuth == nil && conf.Cache == nil {
+		c.Ui.Error("The configuration must contain an 'auto_auth' or a 'cache' block")
+		return 1
+	}
+	exitAfterAuth = exitAfterAuth || conf.ExitAfterAuth
+	if exitAfterAuth && conf.AutoAuth == nil {
+		c.Ui.Error("Exiting after authentication requires an 'auto_auth' block")
+		return 1
+	}
+	if exitAfterAuth && conf.Cache != nil {
+		c.Ui.Error("Exiting after authentication cannot be combined with a 'cache' block")
+		return 1
+	}
+
+	apiConfig, err := c.apiConfig(conf.Vault)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error configuring the Vault client: %s", err))
+		return 1
+	}
+	client, err := api.NewClient(apiConfig)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error creating the Vault client: %s", err))
+		return 1
+	}
+	client.ClearToken()
+
+	info := map[string]string{
+		"vault address": client.Address(),
+	}
+
+	stopCh := make(chan struct{})
+	var wg sync.WaitGroup
+
+	if conf.AutoAuth != nil {
+		method := conf.AutoAuth.Method
+		handler, ok := c.Handlers[method.Type]
+		if !ok {
+			c.Ui.Error(fmt.Sprintf("Unknown auto-auth method type: %s", method.Type))
+			return 1
+		}
+
+		sinks := make([]*sink.SinkConfig, 0, len(conf.AutoAuth.Sinks))
+		for _, sc := range conf.AutoAuth.Sinks {
+			var s sink.Sink
+			switch sc.Type {
+			case "file":
+				s, err = sink.NewFileSink(sc.Config)
+			default:
+				err = fmt.Errorf("unknown sink type")
+			}
+			if err != nil {
+				c.Ui.Error(fmt.Sprintf("Error creating sink of type %s: %s", sc.Type, err))
+				return 1
+			}
+			sinks = append(sinks, &sink.SinkConfig{
+				Sink:    s,
+				Client:  client,
+				WrapTTL: sc.WrapTTL,
+				DHType:  sc.DHType,
+				DHPath:  sc.DHPath,
+				AAD:     sc.AAD,
+			})
+		}
+
+		var ts *template.Server
+		if len(conf.Templates) > 0 {
+			ts, err = template.NewServer(&template.ServerConfig{
+				Client:    client,
+				Logger:    c.logger,
+				Templates: conf.Templates,
+			})
+			if err != nil {
+				c.Ui.Error(fmt.Sprintf("Error creating the template server: %s", err))
+				return 1
+			}
+		}
+
+		ah := auth.NewAuthHandler(&auth.AuthConfig{
+			Client:  client,
+			Logger:  c.logger,
+			Handler: handler,
+			Method:  method,
+		})
+
+		if exitAfterAuth {
+			return c.runOnce(ah, sinks, ts)
+		}
+
+		ss := sink.NewSinkServer(c.logger)
+		sinkCh := make(chan string)
+		outputs := []chan<- string{sinkCh}
+
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			ah.Run(stopCh)
+		}()
+		go func() {
+			defer wg.Done()
+			ss.Run(sinkCh, sinks, stopCh)
+		}()
+
+		if ts != nil {
+			templateCh := make(chan string)
+			outputs = append(outputs, templateCh)
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ts.Run(templateCh, stopCh)
+			}()
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.handleTokens(ah.OutputCh, outputs, stopCh)
+		}()
+
+		info["auto-auth method"] = method.Type
+		info["auto-auth sinks"] = fmt.Sprintf("%d", len(sinks))
+		info["templates"] = fmt.Sprintf("%d", len(conf.Templates))
+	}
+
+	var lns []net.Listener
+	if conf.Cache != nil {
+		proxy, err := cache.NewAPIProxy(apiConfig.HttpClient, client.Address())
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Error creating the proxy: %s", err))
+			return 1
+		}
+		lc := cache.NewLeaseCache(&cache.LeaseCacheConfig{
+			Proxier: proxy,
+			Client:  client,
+			Logger:  c.logger,
+		})
+
+		var tokenFunc func() string
+		if conf.Cache.UseAutoAuthToken {
+			tokenFunc = c.currentToken
+		}
+
+		srv := &http.Server{
+			Handler: cache.Handler(lc, c.logger, tokenFunc),
+		}
+		for i, lnConfig := range conf.Listeners {
+			ln, props, _, err := server.NewListener(lnConfig.Type, lnConfig.Config, c.logGate)
+			if err != nil {
+				c.Ui.Error(fmt.Sprintf("Error initializing listener of type %s: %s", lnConfig.Type, err))
+				return 1
+			}
+			lns = append(lns, ln)
+
+			propsList := make([]string, 0, len(props))
+			for k, v := range props {
+				propsList = append(propsList, fmt.Sprintf("%s: %q", k, v))
+			}
+			sort.Strings(propsList)
+			info[fmt.Sprintf("listener %d", i+1)] = fmt.Sprintf("%s (%s)", lnConfig.Type, strings.Join(propsList, ", "))
+
+			go srv.Serve(ln)
+		}
+		defer func() {
+			for _, ln := range lns {
+				ln.Close()
+			}
+		}()
+	}
+
+	if conf.PidFile != "" {
+		if err := ioutil.WriteFile(conf.PidFile, []byte(fmt.Sprintf("%d", os.Getpid())), 0644); err != nil {
+			c.Ui.Error(fmt.Sprintf("Error storing PID: %s", err))
+			return 1
+		}
+		defer os.Remove(conf.PidFile)
+	}
+
+	infoKeys := make([]string, 0, len(info))
+	for k := range info {
+		infoKeys = append(infoKeys, k)
+	}
+	sort.Strings(infoKeys)
+
+	c.Ui.Output("==> Vault agent configuration:\n")
+	for _, k := range infoKeys {
+		c.Ui.Output(fmt.Sprintf("%24s: %s", strings.Title(k), info[k]))
+	}
+	c.Ui.Output("")
+	c.Ui.Output("==> Vault agent started! Log data will stream in below:\n")
+	c.logGate.Flush()
+
+	<-c.ShutdownCh
+	c.Ui.Output("==> Vault agent shutdown triggered")
+	close(stopCh)
+	wg.Wait()
+
+	return 0
+}
+
+// runOnce logs in, writes the token to the sinks and renders the templates,
+// and then exits
+func (c *AgentCommand) runOnce(ah *auth.AuthHandler, sinks []*sink.SinkConfig, ts *template.Server) int {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go ah.Run(stopCh)
+
+	c.Ui.Output("==> Vault agent started! Exiting after authentication.\n")
+	c.logGate.Flush()
+
+	var token string
+	select {
+	case <-c.ShutdownCh:
+		c.Ui.Output("==> Vault agent shutdown triggered")
+		return 1
+	case token = <-ah.OutputCh:
+	}
+
+	if err := sink.WriteToken(sinks, token); err != nil {
+		c.Ui.Error(fmt.Sprintf("Error writing token: %s", err))
+		return 1
+	}
+	if ts != nil {
+		if err := ts.RenderOnce(token); err != nil {
+			c.Ui.Error(fmt.Sprintf("Error rendering templates: %s", err))
+			return 1
+		}
+	}
+
+	return 0
+}
+
+// handleTokens keeps the latest auto-auth token for the proxy and passes it
+// on to the sinks and templates
+func (c *AgentCommand) handleTokens(incoming <-chan string, outputs []chan<- string, stopCh <-chan struct{}) {
+	for {
+		select {
+		case <-stopCh:
+			return
+		case token := <-incoming:
+			c.tokenLock.Lock()
+			c.token = token
+			c.tokenLock.Unlock()
+
+			for _, output := range outputs {
+				select {
+				case <-stopCh:
+					return
+				case output <- token:
+				}
+			}
+		}
+	}
+}
+
+func (c *AgentCommand) currentToken() string {
+	c.tokenLock.RLock()
+	defer c.tokenLock.RUnlock()
+	return c.token
+}
+
+// apiConfig returns the client configuration, from the environment and the
+// vault block, which takes precedence
+func (c *AgentCommand) apiConfig(v *config.Vault) (*api.Config, error) {
+	apiConfig := api.DefaultConfig()
+	if err := apiConfig.ReadEnvironment(); err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return apiConfig, nil
+	}
+
+	if v.Address != "" {
+		apiConfig.Address = v.Address
+	}
+
+	if v.CACert != "" || v.CAPath != "" || v.ClientCert != "" || v.ClientKey != "" || v.TLSSkipVerify {
+		t := &api.TLSConfig{
+			CACert:     v.CACert,
+			CAPath:     v.CAPath,
+			ClientCert: v.ClientCert,
+			ClientKey:  v.ClientKey,
+			Insecure:   v.TLSSkipVerify,
+		}
+		if t.CACert == "" && t.CAPath == "" {
+			t.CACert = os.Getenv(api.EnvVaultCACert)
+			t.CAPath = os.Getenv(api.EnvVaultCAPath)
+		}
+		if t.ClientCert == "" && t.ClientKey == "" {
+			t.ClientCert = os.Getenv(api.EnvVaultClientCert)
+			t.ClientKey = os.Getenv(api.EnvVaultClientKey)
+		}
+		if err := apiConfig.ConfigureTLS(t); err != nil {
+			return nil, err
+		}
+	}
+
+	return apiConfig, nil
+}
+
+func (c *AgentCommand) Synopsis() string {
+	return "Start a Vault agent"
+}
+
+func (c *AgentCommand) Help() string {
+	helpText := `
+Usage: vault agent [options]
+
+  Start a Vault agent.
+
+  The agent logs in with the configured auth method, writes the token to
+  the configured sinks and keeps it renewed, logging in again when it can
+  no longer be renewed. Credentials of the auth method can be read from
+  files, such as "role_id_file" and "secret_id_file" for AppRole.
+
+  With a "cache" block, the agent also listens for requests, which it
+  forwards to Vault. Responses carrying a lease or a token are cached and
+  renewed, until they expire or their revocation is proxied. With
+  "use_auto_auth_token", requests without a token are sent with the
+  auto-auth token.
+
+  With "template" blocks, the agent renders Go templates to files with
+  the auto-auth token, and renders them again when the secrets they use
+  are renewed or rotated. The "secret" function reads a path, or writes to
+  it when given key=value pairs, and "pkiCert" issues a certificate:
+
+      {{ with secret "database/creds/app" }}{{ .Data.password }}{{ end }}
+      {{ with pkiCert "pki/issue/app" "common_name=app.example.com" }}{{ .Cert }}{{ end }}
+
+  Vault server options, such as the address and TLS settings, are read
+  from the "vault" block of the configuration, or from the environment.
+
+General Options:
+
+  -config=<path>          Path to the configuration file.
+
+  -exit-after-auth        Exit after logging in once, writing the token to
+                          the sinks and rendering the templates, such as in
+                          init containers. Can also be set with
+                          "exit_after_auth" in the configuration.
+
+  -log-level=info         Log verbosity. Defaults to "info", will be output to
+                          stderr. Supported values: "trace", "debug", "info",
+                          "warn", "err"
+`
+	return strings.TrimSpace(helpText)
+}
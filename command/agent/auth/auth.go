@@ -0,0 +1,233 @@
+package auth
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/command/agent/config"
+	"github.com/hashicorp/vault/helper/parseutil"
+	log "github.com/mgutz/logxi/v1"
+)
+
+const (
+	initialBackoff = 1 * time.Second
+	maxBackoff     = 5 * time.Minute
+)
+
+// Handler logs in with a credential provider and returns the token, like
+// the handlers of "vault auth" do.
+type Handler interface {
+	Auth(*api.Client, map[string]string) (string, error)
+}
+
+// AuthConfig is the configuration of an AuthHandler.
+type AuthConfig struct {
+	Client  *api.Client
+	Logger  log.Logger
+	Handler Handler
+	Method  *config.Method
+}
+
+// AuthHandler logs in with the configured method, keeps the token renewed,
+// and logs in again when renewal stops. Each new token is sent on OutputCh.
+type AuthHandler struct {
+	OutputCh chan string
+
+	client  *api.Client
+	logger  log.Logger
+	handler Handler
+	method  *config.Method
+}
+
+// NewAuthHandler returns an AuthHandler for the given configuration.
+func NewAuthHandler(conf *AuthConfig) *AuthHandler {
+	return &AuthHandler{
+		OutputCh: make(chan string),
+		client:   conf.Client,
+		logger:   conf.Logger,
+		handler:  conf.Handler,
+		method:   conf.Method,
+	}
+}
+
+// Run authenticates until the stop channel is closed. Failed logins are
+// retried with an exponential backoff.
+func (ah *AuthHandler) Run(stopCh <-chan struct{}) {
+	backoff := initialBackoff
+	for {
+		select {
+		case <-stopCh:
+			return
+		default:
+		}
+
+		token, secret, err := ah.authenticate()
+		if err != nil {
+			ah.logger.Error("agent/auth: error authenticating", "error", err, "backoff", backoff)
+			select {
+			case <-stopCh:
+				return
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+			if backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+			continue
+		}
+		backoff = initialBackoff
+
+		ah.logger.Info("agent/auth: authentication successful", "method", ah.method.Type)
+
+		select {
+		case <-stopCh:
+			return
+		case ah.OutputCh <- token:
+		}
+
+		if !ah.waitForRenewal(secret, stopCh) {
+			return
+		}
+	}
+}
+
+// waitForRenewal keeps the token renewed until renewal stops, or waits until
+// it expires if it is not renewable. It returns false if the stop channel was
+// closed.
+func (ah *AuthHandler) waitForRenewal(secret *api.Secret, stopCh <-chan struct{}) bool {
+	ttl := time.Duration(secret.Auth.LeaseDuration) * time.Second
+
+	if !secret.Auth.Renewable {
+		if ttl == 0 {
+			// Tokens without a TTL never need a new login
+			<-stopCh
+			return false
+		}
+
+		wait := ttl - api.DefaultRenewerGrace
+		if wait < 0 {
+			wait = 0
+		}
+		ah.logger.Info("agent/auth: token is not renewable, logging in again before it expires", "ttl", ttl)
+		select {
+		case <-stopCh:
+			return false
+		case <-time.After(wait):
+			return true
+		}
+	}
+
+	client, err := ah.client.Clone()
+	if err != nil {
+		ah.logger.Error("agent/auth: error creating renewal client", "error", err)
+		return true
+	}
+	client.SetToken(secret.Auth.ClientToken)
+
+	renewer, err := client.NewRenewer(&api.RenewerInput{
+		Secret: secret,
+	})
+	if err != nil {
+		ah.logger.Error("agent/auth: error creating renewer", "error", err)
+		return true
+	}
+	go renewer.Renew()
+	defer renewer.Stop()
+
+	for {
+		select {
+		case <-stopCh:
+			return false
+		case err := <-renewer.DoneCh():
+			if err != nil {
+				ah.logger.Error("agent/auth: error renewing token", "error", err)
+			}
+			ah.logger.Info("agent/auth: renewal stopped, logging in again")
+			return true
+		case <-renewer.RenewCh():
+			ah.logger.Debug("agent/auth: renewed token")
+		}
+	}
+}
+
+// authenticate logs in and looks up the TTL of the new token
+func (ah *AuthHandler) authenticate() (string, *api.Secret, error) {
+	data, err := ah.authData()
+	if err != nil {
+		return "", nil, err
+	}
+
+	client, err := ah.client.Clone()
+	if err != nil {
+		return "", nil, err
+	}
+	client.ClearToken()
+
+	token, err := ah.handler.Auth(client, data)
+	if err != nil {
+		return "", nil, err
+	}
+	if token == "" {
+		return "", nil, fmt.Errorf("credential provider returned an empty token")
+	}
+
+	client.SetToken(token)
+	lookup, err := client.Auth().Token().LookupSelf()
+	if err != nil {
+		return "", nil, fmt.Errorf("error looking up token: %s", err)
+	}
+	if lookup == nil || lookup.Data == nil {
+		return "", nil, fmt.Errorf("empty response looking up token")
+	}
+
+	secret := &api.Secret{
+		Auth: &api.SecretAuth{
+			ClientToken: token,
+		},
+	}
+	if raw, ok := lookup.Data["ttl"]; ok && raw != nil {
+		ttl, err := parseutil.ParseDurationSecond(raw)
+		if err != nil {
+			return "", nil, fmt.Errorf("error parsing token TTL: %s", err)
+		}
+		secret.Auth.LeaseDuration = int(ttl.Seconds())
+	}
+	if raw, ok := lookup.Data["renewable"]; ok && raw != nil {
+		renewable, err := parseutil.ParseBool(raw)
+		if err != nil {
+			return "", nil, fmt.Errorf("error parsing token renewability: %s", err)
+		}
+		secret.Auth.Renewable = renewable
+	}
+
+	return token, secret, nil
+}
+
+// authData returns the options passed to the credential provider. Options
+// ending in "_file" are read from the file on every login, so that
+// credentials such as secret IDs can be rotated, and passed without the
+// suffix.
+func (ah *AuthHandler) authData() (map[string]string, error) {
+	data := make(map[string]string, len(ah.method.Config)+1)
+	for k, v := range ah.method.Config {
+		value := fmt.Sprintf("%v", v)
+		if strings.HasSuffix(k, "_file") {
+			contents, err := ioutil.ReadFile(value)
+			if err != nil {
+				return nil, fmt.Errorf("error reading %q: %s", k, err)
+			}
+			k = strings.TrimSuffix(k, "_file")
+			value = strings.TrimSpace(string(contents))
+		}
+		data[k] = value
+	}
+
+	if mount := strings.TrimPrefix(ah.method.MountPath, "auth/"); mount != "" {
+		data["mount"] = mount
+	}
+
+	return data, nil
+}
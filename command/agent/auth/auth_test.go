@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/command/agent/config"
+	"github.com/hashicorp/vault/helper/logformat"
+	vaulthttp "github.com/hashicorp/vault/http"
+	"github.com/hashicorp/vault/vault"
+	log "github.com/mgutz/logxi/v1"
+)
+
+// testHandler creates a token with the root client, recording the data it
+// is called with
+type testHandler struct {
+	root      *api.Client
+	ttl       string
+	renewable bool
+	data      chan map[string]string
+}
+
+func (h *testHandler) Auth(c *api.Client, m map[string]string) (string, error) {
+	if c.Token() != "" {
+		return "", fmt.Errorf("login client has a token")
+	}
+	h.data <- m
+
+	secret, err := h.root.Auth().Token().Create(&api.TokenCreateRequest{
+		TTL:       h.ttl,
+		Renewable: &h.renewable,
+	})
+	if err != nil {
+		return "", err
+	}
+	return secret.Auth.ClientToken, nil
+}
+
+func TestAuthHandler(t *testing.T) {
+	core, _, rootToken := vault.TestCoreUnsealed(t)
+	ln, addr := vaulthttp.TestServer(t, core)
+	defer ln.Close()
+
+	apiConfig := api.DefaultConfig()
+	apiConfig.Address = addr
+	client, err := api.NewClient(apiConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetToken(rootToken)
+
+	dir, err := ioutil.TempDir("", "vault-agent-auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	secretIDPath := filepath.Join(dir, "secret_id")
+	if err := ioutil.WriteFile(secretIDPath, []byte("foo\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Non-renewable tokens expiring within the renewal grace period make
+	// the handler log in again right away
+	handler := &testHandler{
+		root: client,
+		ttl:  "10s",
+		data: make(chan map[string]string, 2),
+	}
+
+	ah := NewAuthHandler(&AuthConfig{
+		Client:  client,
+		Logger:  logformat.NewVaultLogger(log.LevelTrace),
+		Handler: handler,
+		Method:  testMethod(secretIDPath),
+	})
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go ah.Run(stopCh)
+
+	var tokens []string
+	for i := 0; i < 2; i++ {
+		select {
+		case token := <-ah.OutputCh:
+			tokens = append(tokens, token)
+		case <-time.After(10 * time.Second):
+			t.Fatal("timed out waiting for a token")
+		}
+
+		data := <-handler.data
+		if data["role_id"] != "bar" || data["secret_id"] != "foo" || data["mount"] != "approle-prod" {
+			t.Fatalf("bad: %#v", data)
+		}
+		if _, ok := data["secret_id_file"]; ok {
+			t.Fatalf("bad: %#v", data)
+		}
+	}
+
+	if tokens[0] == tokens[1] {
+		t.Fatal("expected a new token after the first one expired")
+	}
+	for _, token := range tokens {
+		if _, err := client.Auth().Token().Lookup(token); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestAuthHandler_renew(t *testing.T) {
+	core, _, rootToken := vault.TestCoreUnsealed(t)
+	ln, addr := vaulthttp.TestServer(t, core)
+	defer ln.Close()
+
+	apiConfig := api.DefaultConfig()
+	apiConfig.Address = addr
+	client, err := api.NewClient(apiConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetToken(rootToken)
+
+	handler := &testHandler{
+		root:      client,
+		ttl:       "1h",
+		renewable: true,
+		data:      make(chan map[string]string, 2),
+	}
+
+	ah := NewAuthHandler(&AuthConfig{
+		Client:  client,
+		Logger:  logformat.NewVaultLogger(log.LevelTrace),
+		Handler: handler,
+		Method:  &config.Method{Type: "test"},
+	})
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go ah.Run(stopCh)
+
+	select {
+	case <-ah.OutputCh:
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for a token")
+	}
+
+	// A renewable token is kept, so there is no second login
+	select {
+	case token := <-ah.OutputCh:
+		t.Fatalf("unexpected new token %q", token)
+	case <-time.After(2 * time.Second):
+	}
+}
+
+func TestAuthHandler_missingFile(t *testing.T) {
+	ah := NewAuthHandler(&AuthConfig{
+		Method: testMethod("/nonexistent/secret_id"),
+	})
+	if _, err := ah.authData(); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func testMethod(secretIDPath string) *config.Method {
+	return &config.Method{
+		Type:      "approle",
+		MountPath: "auth/approle-prod",
+		Config: map[string]interface{}{
+			"role_id":        "bar",
+			"secret_id_file": secretIDPath,
+		},
+	}
+}
@@ -0,0 +1,116 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/hashicorp/vault/helper/jsonutil"
+	log "github.com/mgutz/logxi/v1"
+)
+
+// maxRequestSize is the maximum size of a proxied request body, matching
+// that of the Vault server
+const maxRequestSize = 32 * 1024 * 1024
+
+// clearRequest is the body of a request to /agent/v1/cache-clear
+type clearRequest struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// Handler returns the handler of the agent listeners. Requests are sent to
+// Vault through the cache. If tokenFunc is not nil, requests without a token
+// are sent with the token it returns, which is the auto-auth token.
+func Handler(lc *LeaseCache, logger log.Logger, tokenFunc func() string) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/agent/v1/cache-clear", handleCacheClear(lc))
+	mux.Handle("/", handleProxy(lc, logger, tokenFunc))
+	return mux
+}
+
+func handleProxy(proxier Proxier, logger log.Logger, tokenFunc func() string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestSize))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, fmt.Errorf("error reading request: %s", err))
+			return
+		}
+
+		token := r.Header.Get("X-Vault-Token")
+		if token == "" && tokenFunc != nil {
+			token = tokenFunc()
+		}
+
+		resp, err := proxier.Send(&SendRequest{
+			Token:       token,
+			Request:     r,
+			RequestBody: body,
+		})
+		if err != nil {
+			logger.Error("agent/cache: error proxying request", "path", r.URL.Path, "error", err)
+			respondError(w, http.StatusBadGateway, fmt.Errorf("error proxying request: %s", err))
+			return
+		}
+
+		for k, v := range resp.Header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(resp.StatusCode)
+		w.Write(resp.Body)
+	})
+}
+
+// handleCacheClear evicts cache entries. The type is "all", "lease" with a
+// lease ID prefix as value, or "token" with a token as value.
+func handleCacheClear(lc *LeaseCache) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PUT" && r.Method != "POST" {
+			respondError(w, http.StatusMethodNotAllowed, nil)
+			return
+		}
+
+		var req clearRequest
+		if err := jsonutil.DecodeJSONFromReader(r.Body, &req); err != nil {
+			respondError(w, http.StatusBadRequest, fmt.Errorf("error parsing request: %s", err))
+			return
+		}
+
+		switch req.Type {
+		case "all":
+			lc.EvictAll()
+		case "lease":
+			if req.Value == "" {
+				respondError(w, http.StatusBadRequest, fmt.Errorf("'value' must be specified"))
+				return
+			}
+			lc.EvictLease(req.Value, true)
+		case "token":
+			if req.Value == "" {
+				respondError(w, http.StatusBadRequest, fmt.Errorf("'value' must be specified"))
+				return
+			}
+			lc.EvictToken(req.Value, true)
+		default:
+			respondError(w, http.StatusBadRequest, fmt.Errorf("invalid type %q", req.Type))
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func respondError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	resp := struct {
+		Errors []string `json:"errors"`
+	}{Errors: make([]string, 0, 1)}
+	if err != nil {
+		resp.Errors = append(resp.Errors, err.Error())
+	}
+
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,438 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/helper/jsonutil"
+	log "github.com/mgutz/logxi/v1"
+)
+
+// CacheHeader is set on proxied responses to HIT if the response was served
+// from the cache and to MISS otherwise
+const CacheHeader = "X-Cache"
+
+// cacheEntry is a cached response carrying a lease or a token
+type cacheEntry struct {
+	key string
+
+	// requestToken is the token of the request
+	requestToken string
+
+	// leaseID is the lease of a secret
+	leaseID string
+
+	// token and accessor are those of a token returned by a login or by
+	// the token store
+	token    string
+	accessor string
+
+	response *SendResponse
+
+	stopCh chan struct{}
+}
+
+// LeaseCache is a Proxier caching the responses carrying a lease or a
+// token. Cached leases and tokens are renewed until they cannot be renewed
+// any more, and are evicted when they expire or when their revocation is
+// proxied. Evicting a token evicts the responses requested with it and the
+// tokens created with it, which Vault revokes along with it.
+type LeaseCache struct {
+	proxier Proxier
+	client  *api.Client
+	logger  log.Logger
+
+	l       sync.RWMutex
+	entries map[string]*cacheEntry
+}
+
+// LeaseCacheConfig is the configuration of a LeaseCache.
+type LeaseCacheConfig struct {
+	// Proxier is where cache misses are sent
+	Proxier Proxier
+
+	// Client is used to renew the cached leases and tokens
+	Client *api.Client
+
+	Logger log.Logger
+}
+
+// NewLeaseCache returns a LeaseCache for the given configuration.
+func NewLeaseCache(conf *LeaseCacheConfig) *LeaseCache {
+	return &LeaseCache{
+		proxier: conf.Proxier,
+		client:  conf.Client,
+		logger:  conf.Logger,
+		entries: make(map[string]*cacheEntry),
+	}
+}
+
+func (lc *LeaseCache) Send(req *SendRequest) (*SendResponse, error) {
+	key := cacheKey(req)
+
+	lc.l.RLock()
+	entry, ok := lc.entries[key]
+	var cached *SendResponse
+	if ok {
+		cached = entry.response
+	}
+	lc.l.RUnlock()
+	if ok {
+		lc.logger.Debug("agent/cache: serving cached response", "path", req.Request.URL.Path)
+		return cached.withCacheHeader("HIT"), nil
+	}
+
+	resp, err := lc.proxier.Send(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		lc.handleRevocation(req, resp)
+		lc.cacheResponse(key, req, resp)
+	}
+
+	return resp.withCacheHeader("MISS"), nil
+}
+
+// cacheResponse caches the response if it carries a lease or a token
+func (lc *LeaseCache) cacheResponse(key string, req *SendRequest, resp *SendResponse) {
+	if len(resp.Body) == 0 {
+		return
+	}
+
+	secret, err := api.ParseSecret(bytes.NewReader(resp.Body))
+	if err != nil || secret == nil || secret.WrapInfo != nil {
+		return
+	}
+
+	entry := &cacheEntry{
+		key:          key,
+		requestToken: req.Token,
+		leaseID:      secret.LeaseID,
+		response:     resp,
+		stopCh:       make(chan struct{}),
+	}
+	if secret.Auth != nil {
+		entry.token = secret.Auth.ClientToken
+		entry.accessor = secret.Auth.Accessor
+	}
+	if entry.leaseID == "" && entry.token == "" {
+		return
+	}
+
+	lc.l.Lock()
+	if old, ok := lc.entries[key]; ok {
+		close(old.stopCh)
+	}
+	lc.entries[key] = entry
+	lc.l.Unlock()
+
+	go lc.renew(entry, secret)
+}
+
+// renew renews the lease or the token of the entry until it is evicted or
+// renewal stops, and evicts it when it stops or expires
+func (lc *LeaseCache) renew(entry *cacheEntry, secret *api.Secret) {
+	renewable, ttl := secret.Renewable, secret.LeaseDuration
+	token := entry.requestToken
+	if entry.token != "" {
+		renewable, ttl = secret.Auth.Renewable, secret.Auth.LeaseDuration
+		token = entry.token
+	}
+
+	if !renewable {
+		if ttl == 0 {
+			// Tokens without a TTL stay cached until they are revoked
+			<-entry.stopCh
+			return
+		}
+		select {
+		case <-entry.stopCh:
+		case <-time.After(time.Duration(ttl) * time.Second):
+			lc.evict(entry)
+		}
+		return
+	}
+
+	client, err := lc.client.Clone()
+	if err != nil {
+		lc.logger.Error("agent/cache: error creating renewal client", "error", err)
+		lc.evict(entry)
+		return
+	}
+	client.SetToken(token)
+
+	renewer, err := client.NewRenewer(&api.RenewerInput{
+		Secret: secret,
+	})
+	if err != nil {
+		lc.logger.Error("agent/cache: error creating renewer", "error", err)
+		lc.evict(entry)
+		return
+	}
+	go renewer.Renew()
+	defer renewer.Stop()
+
+	for {
+		select {
+		case <-entry.stopCh:
+			return
+		case err := <-renewer.DoneCh():
+			if err != nil {
+				lc.logger.Debug("agent/cache: renewal stopped", "error", err)
+			}
+			lc.evict(entry)
+			return
+		case renewal := <-renewer.RenewCh():
+			lc.updateLeaseDuration(entry, renewal.Secret)
+		}
+	}
+}
+
+// updateLeaseDuration sets the lease duration of the cached response to
+// that of the renewed lease or token, so that clients served from the cache
+// don't see the duration of the original response
+func (lc *LeaseCache) updateLeaseDuration(entry *cacheEntry, renewed *api.Secret) {
+	if renewed == nil {
+		return
+	}
+	ttl := renewed.LeaseDuration
+	if entry.token != "" {
+		if renewed.Auth == nil {
+			return
+		}
+		ttl = renewed.Auth.LeaseDuration
+	}
+
+	lc.l.Lock()
+	defer lc.l.Unlock()
+
+	if lc.entries[entry.key] != entry {
+		return
+	}
+	resp, err := entry.response.withLeaseDuration(ttl, entry.token != "")
+	if err != nil {
+		lc.logger.Error("agent/cache: error updating cached lease duration", "error", err)
+		return
+	}
+	entry.response = resp
+}
+
+// handleRevocation evicts the entries revoked by a successful request
+func (lc *LeaseCache) handleRevocation(req *SendRequest, resp *SendResponse) {
+	if req.Request.Method != "PUT" && req.Request.Method != "POST" {
+		return
+	}
+
+	path := strings.TrimPrefix(req.Request.URL.Path, "/v1/")
+	path = strings.TrimSuffix(path, "/")
+
+	var body map[string]interface{}
+	if len(req.RequestBody) > 0 {
+		jsonutil.DecodeJSON(req.RequestBody, &body)
+	}
+	bodyValue := func(name string) string {
+		s, _ := body[name].(string)
+		return s
+	}
+
+	if path == "sys/leases/revoke" || path == "sys/revoke" {
+		lc.EvictLease(bodyValue("lease_id"), false)
+		return
+	}
+	if prefix, ok := trimPathPrefix(path, "sys/leases/revoke-prefix/", "sys/revoke-prefix/", "sys/leases/revoke-force/", "sys/revoke-force/"); ok {
+		lc.EvictLease(prefix, true)
+		return
+	}
+	if leaseID, ok := trimPathPrefix(path, "sys/leases/revoke/", "sys/revoke/"); ok {
+		lc.EvictLease(leaseID, false)
+		return
+	}
+
+	switch {
+	case path == "auth/token/revoke":
+		lc.EvictToken(bodyValue("token"), true)
+	case path == "auth/token/revoke-orphan":
+		lc.EvictToken(bodyValue("token"), false)
+	case path == "auth/token/revoke-self":
+		lc.EvictToken(req.Token, true)
+	case path == "auth/token/revoke-accessor":
+		lc.EvictAccessor(bodyValue("accessor"))
+	}
+}
+
+// EvictLease evicts the entry of a lease, or the entries of the leases
+// with the given prefix.
+func (lc *LeaseCache) EvictLease(leaseID string, prefix bool) {
+	if leaseID == "" {
+		return
+	}
+
+	lc.l.Lock()
+	defer lc.l.Unlock()
+
+	for _, entry := range lc.entries {
+		if entry.leaseID == "" {
+			continue
+		}
+		if entry.leaseID == leaseID || (prefix && strings.HasPrefix(entry.leaseID, leaseID)) {
+			lc.evictLocked(entry)
+		}
+	}
+}
+
+// EvictToken evicts the entries of a token and the entries requested with
+// it. Unless orphaning, the tokens created with it are evicted as well.
+func (lc *LeaseCache) EvictToken(token string, children bool) {
+	if token == "" {
+		return
+	}
+
+	lc.l.Lock()
+	defer lc.l.Unlock()
+
+	lc.evictTokenLocked(token, children)
+}
+
+// EvictAccessor evicts the entries of the token with the given accessor, as
+// EvictToken does.
+func (lc *LeaseCache) EvictAccessor(accessor string) {
+	if accessor == "" {
+		return
+	}
+
+	lc.l.Lock()
+	defer lc.l.Unlock()
+
+	for _, entry := range lc.entries {
+		if entry.accessor == accessor {
+			lc.evictTokenLocked(entry.token, true)
+		}
+	}
+}
+
+// EvictAll evicts every entry.
+func (lc *LeaseCache) EvictAll() {
+	lc.l.Lock()
+	defer lc.l.Unlock()
+
+	for _, entry := range lc.entries {
+		lc.evictLocked(entry)
+	}
+}
+
+func (lc *LeaseCache) evictTokenLocked(token string, children bool) {
+	var childTokens []string
+	for _, entry := range lc.entries {
+		switch {
+		case entry.token == token:
+			lc.evictLocked(entry)
+		case entry.requestToken == token:
+			lc.evictLocked(entry)
+			if entry.token != "" && children {
+				childTokens = append(childTokens, entry.token)
+			}
+		}
+	}
+
+	for _, child := range childTokens {
+		lc.evictTokenLocked(child, true)
+	}
+}
+
+func (lc *LeaseCache) evict(entry *cacheEntry) {
+	lc.l.Lock()
+	defer lc.l.Unlock()
+
+	lc.evictLocked(entry)
+}
+
+func (lc *LeaseCache) evictLocked(entry *cacheEntry) {
+	if lc.entries[entry.key] != entry {
+		return
+	}
+	delete(lc.entries, entry.key)
+	close(entry.stopCh)
+}
+
+// withLeaseDuration returns a copy of the response with the lease duration
+// of its secret, or of its token if auth is set, replaced
+func (r *SendResponse) withLeaseDuration(ttl int, auth bool) (*SendResponse, error) {
+	var body map[string]interface{}
+	if err := jsonutil.DecodeJSON(r.Body, &body); err != nil {
+		return nil, err
+	}
+
+	target := body
+	if auth {
+		target, _ = body["auth"].(map[string]interface{})
+		if target == nil {
+			return nil, fmt.Errorf("response has no auth")
+		}
+	}
+	target["lease_duration"] = ttl
+
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make(http.Header, len(r.Header))
+	for k, v := range r.Header {
+		header[k] = v
+	}
+	header.Del("Content-Length")
+	return &SendResponse{
+		StatusCode: r.StatusCode,
+		Header:     header,
+		Body:       encoded,
+	}, nil
+}
+
+func (r *SendResponse) withCacheHeader(value string) *SendResponse {
+	header := make(http.Header, len(r.Header)+1)
+	for k, v := range r.Header {
+		header[k] = v
+	}
+	header.Set(CacheHeader, value)
+	return &SendResponse{
+		StatusCode: r.StatusCode,
+		Header:     header,
+		Body:       r.Body,
+	}
+}
+
+// cacheKey identifies a request by its method, path, query, body and token
+func cacheKey(req *SendRequest) string {
+	h := sha256.New()
+	for _, part := range []string{
+		req.Request.Method,
+		req.Request.URL.Path,
+		req.Request.URL.RawQuery,
+		string(req.RequestBody),
+		req.Token,
+	} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// trimPathPrefix returns the path without the first of the prefixes it has
+func trimPathPrefix(path string, prefixes ...string) (string, bool) {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(path, prefix) {
+			return strings.TrimPrefix(path, prefix), true
+		}
+	}
+	return "", false
+}
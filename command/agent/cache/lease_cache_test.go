@@ -0,0 +1,280 @@
+package cache
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/helper/logformat"
+	log "github.com/mgutz/logxi/v1"
+)
+
+// mockProxier returns the responses in order, recording the requests
+type mockProxier struct {
+	l         sync.Mutex
+	responses []*SendResponse
+	requests  []*SendRequest
+}
+
+func (p *mockProxier) Send(req *SendRequest) (*SendResponse, error) {
+	p.l.Lock()
+	defer p.l.Unlock()
+
+	p.requests = append(p.requests, req)
+	resp := p.responses[0]
+	p.responses = p.responses[1:]
+	return resp, nil
+}
+
+func jsonResponse(status int, body string) *SendResponse {
+	return &SendResponse{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       []byte(body),
+	}
+}
+
+func testLeaseCache(t *testing.T, responses ...*SendResponse) (*LeaseCache, *mockProxier) {
+	client, err := api.NewClient(api.DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proxier := &mockProxier{responses: responses}
+	return NewLeaseCache(&LeaseCacheConfig{
+		Proxier: proxier,
+		Client:  client,
+		Logger:  logformat.NewVaultLogger(log.LevelTrace),
+	}), proxier
+}
+
+func testSend(t *testing.T, lc *LeaseCache, method, path, body, token string) *SendResponse {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	resp, err := lc.Send(&SendRequest{
+		Token:       token,
+		Request:     r,
+		RequestBody: []byte(body),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func expectCache(t *testing.T, resp *SendResponse, expected string) {
+	if v := resp.Header.Get(CacheHeader); v != expected {
+		t.Fatalf("expected %s, got %q", expected, v)
+	}
+}
+
+func TestLeaseCache_lease(t *testing.T) {
+	lease := `{"lease_id": "database/creds/readonly/abcd", "lease_duration": 3600, "renewable": false, "data": {"username": "foo"}}`
+	lc, proxier := testLeaseCache(t,
+		jsonResponse(200, lease),
+		jsonResponse(204, ""),
+		jsonResponse(200, lease),
+		jsonResponse(200, `{"data": {"value": "bar"}}`),
+		jsonResponse(200, `{"data": {"value": "bar"}}`),
+	)
+
+	resp := testSend(t, lc, "GET", "/v1/database/creds/readonly", "", "token1")
+	expectCache(t, resp, "MISS")
+	resp = testSend(t, lc, "GET", "/v1/database/creds/readonly", "", "token1")
+	expectCache(t, resp, "HIT")
+	if !bytes.Equal(resp.Body, []byte(lease)) {
+		t.Fatalf("bad: %s", resp.Body)
+	}
+
+	// Requests with another token are not served from the cache of the
+	// first one
+	testSend(t, lc, "PUT", "/v1/sys/leases/revoke", `{"lease_id": "database/creds/readonly/abcd"}`, "token2")
+	resp = testSend(t, lc, "GET", "/v1/database/creds/readonly", "", "token1")
+	expectCache(t, resp, "MISS")
+
+	// Responses without a lease are not cached
+	testSend(t, lc, "GET", "/v1/secret/foo", "", "token1")
+	resp = testSend(t, lc, "GET", "/v1/secret/foo", "", "token1")
+	expectCache(t, resp, "MISS")
+
+	if len(proxier.requests) != 5 {
+		t.Fatalf("bad: %d requests", len(proxier.requests))
+	}
+	if len(proxier.responses) != 0 {
+		t.Fatalf("bad: %d responses left", len(proxier.responses))
+	}
+}
+
+func TestLeaseCache_renewal(t *testing.T) {
+	lc, _ := testLeaseCache(t,
+		jsonResponse(200, `{"lease_id": "aws/creds/a/1", "lease_duration": 3600, "data": {"access_key": "foo"}}`),
+		jsonResponse(200, `{"auth": {"client_token": "child", "accessor": "child-accessor", "lease_duration": 3600}}`),
+	)
+
+	testSend(t, lc, "GET", "/v1/aws/creds/a", "", "token1")
+	testSend(t, lc, "POST", "/v1/auth/token/create", "", "token1")
+
+	// Cached responses carry the lease duration of the latest renewal
+	var entries []*cacheEntry
+	lc.l.RLock()
+	for _, entry := range lc.entries {
+		entries = append(entries, entry)
+	}
+	lc.l.RUnlock()
+	for _, entry := range entries {
+		lc.updateLeaseDuration(entry, &api.Secret{
+			LeaseDuration: 7200,
+			Auth:          &api.SecretAuth{LeaseDuration: 7200},
+		})
+	}
+
+	for path, method := range map[string]string{
+		"/v1/aws/creds/a":       "GET",
+		"/v1/auth/token/create": "POST",
+	} {
+		resp := testSend(t, lc, method, path, "", "token1")
+		expectCache(t, resp, "HIT")
+
+		secret, err := api.ParseSecret(bytes.NewReader(resp.Body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ttl := secret.LeaseDuration
+		if secret.Auth != nil {
+			ttl = secret.Auth.LeaseDuration
+		}
+		if ttl != 7200 {
+			t.Fatalf("%s: bad lease duration: %s", path, resp.Body)
+		}
+	}
+}
+
+func TestLeaseCache_revokePrefix(t *testing.T) {
+	lc, _ := testLeaseCache(t,
+		jsonResponse(200, `{"lease_id": "aws/creds/a/1", "lease_duration": 3600}`),
+		jsonResponse(200, `{"lease_id": "aws/creds/b/2", "lease_duration": 3600}`),
+		jsonResponse(200, `{"lease_id": "database/creds/c/3", "lease_duration": 3600}`),
+		jsonResponse(204, ""),
+	)
+
+	testSend(t, lc, "GET", "/v1/aws/creds/a", "", "token1")
+	testSend(t, lc, "GET", "/v1/aws/creds/b", "", "token1")
+	testSend(t, lc, "GET", "/v1/database/creds/c", "", "token1")
+	testSend(t, lc, "PUT", "/v1/sys/leases/revoke-prefix/aws/", "", "root")
+
+	if len(lc.entries) != 1 {
+		t.Fatalf("bad: %d entries", len(lc.entries))
+	}
+	for _, entry := range lc.entries {
+		if entry.leaseID != "database/creds/c/3" {
+			t.Fatalf("bad: %s", entry.leaseID)
+		}
+	}
+}
+
+func TestLeaseCache_revokeToken(t *testing.T) {
+	lc, _ := testLeaseCache(t,
+		jsonResponse(200, `{"auth": {"client_token": "child", "accessor": "child-accessor", "lease_duration": 3600}}`),
+		jsonResponse(200, `{"lease_id": "aws/creds/a/1", "lease_duration": 3600}`),
+		jsonResponse(200, `{"lease_id": "aws/creds/a/2", "lease_duration": 3600}`),
+		jsonResponse(204, ""),
+		jsonResponse(200, `{"auth": {"client_token": "other", "accessor": "other-accessor", "lease_duration": 3600}}`),
+		jsonResponse(204, ""),
+	)
+
+	// The child token, created with the parent, is used for a secret
+	testSend(t, lc, "PUT", "/v1/auth/token/create", "", "parent")
+	testSend(t, lc, "GET", "/v1/aws/creds/a", "", "child")
+	testSend(t, lc, "GET", "/v1/aws/creds/a", "", "parent")
+	if len(lc.entries) != 3 {
+		t.Fatalf("bad: %d entries", len(lc.entries))
+	}
+
+	// Revoking the parent revokes everything created with both
+	testSend(t, lc, "PUT", "/v1/auth/token/revoke-self", "", "parent")
+	if len(lc.entries) != 0 {
+		t.Fatalf("bad: %d entries", len(lc.entries))
+	}
+
+	testSend(t, lc, "POST", "/v1/auth/approle/login", `{"role_id": "foo"}`, "")
+	if len(lc.entries) != 1 {
+		t.Fatalf("bad: %d entries", len(lc.entries))
+	}
+	testSend(t, lc, "POST", "/v1/auth/token/revoke-accessor", `{"accessor": "other-accessor"}`, "root")
+	if len(lc.entries) != 0 {
+		t.Fatalf("bad: %d entries", len(lc.entries))
+	}
+}
+
+func TestLeaseCache_expire(t *testing.T) {
+	lc, _ := testLeaseCache(t,
+		jsonResponse(200, `{"lease_id": "aws/creds/a/1", "lease_duration": 1}`),
+	)
+
+	testSend(t, lc, "GET", "/v1/aws/creds/a", "", "token1")
+
+	lc.l.RLock()
+	var entry *cacheEntry
+	for _, e := range lc.entries {
+		entry = e
+	}
+	lc.l.RUnlock()
+	if entry == nil {
+		t.Fatal("expected an entry")
+	}
+
+	<-entry.stopCh
+
+	lc.l.RLock()
+	defer lc.l.RUnlock()
+	if len(lc.entries) != 0 {
+		t.Fatalf("bad: %d entries", len(lc.entries))
+	}
+}
+
+func TestHandler(t *testing.T) {
+	lc, proxier := testLeaseCache(t,
+		jsonResponse(200, `{"lease_id": "aws/creds/a/1", "lease_duration": 3600}`),
+		jsonResponse(403, `{"errors": ["permission denied"]}`),
+	)
+	handler := Handler(lc, lc.logger, func() string { return "auto-auth" })
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/v1/aws/creds/a", nil))
+	if w.Code != 200 || w.Header().Get(CacheHeader) != "MISS" {
+		t.Fatalf("bad: %d %#v", w.Code, w.Header())
+	}
+	if token := proxier.requests[0].Token; token != "auto-auth" {
+		t.Fatalf("bad: %s", token)
+	}
+
+	// Errors are passed through
+	r := httptest.NewRequest("GET", "/v1/secret/foo", nil)
+	r.Header.Set("X-Vault-Token", "foo")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != 403 || !strings.Contains(w.Body.String(), "permission denied") {
+		t.Fatalf("bad: %d %s", w.Code, w.Body.String())
+	}
+	if token := proxier.requests[1].Token; token != "foo" {
+		t.Fatalf("bad: %s", token)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/agent/v1/cache-clear", strings.NewReader(`{"type": "all"}`)))
+	if w.Code != 204 {
+		t.Fatalf("bad: %d %s", w.Code, w.Body.String())
+	}
+	if len(lc.entries) != 0 {
+		t.Fatalf("bad: %d entries", len(lc.entries))
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/agent/v1/cache-clear", strings.NewReader(`{"type": "foo"}`)))
+	if w.Code != 400 {
+		t.Fatalf("bad: %d %s", w.Code, w.Body.String())
+	}
+}
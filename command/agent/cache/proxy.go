@@ -0,0 +1,125 @@
+package cache
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// SendRequest is a request forwarded to Vault.
+type SendRequest struct {
+	Token       string
+	Request     *http.Request
+	RequestBody []byte
+}
+
+// SendResponse is the response of Vault to a forwarded request.
+type SendResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// Proxier forwards requests to Vault.
+type Proxier interface {
+	Send(*SendRequest) (*SendResponse, error)
+}
+
+// hopHeaders are the headers that apply to a single connection and are not
+// forwarded
+var hopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// APIProxy forwards requests to a Vault server.
+type APIProxy struct {
+	client  *http.Client
+	address *url.URL
+}
+
+// NewAPIProxy returns an APIProxy sending requests to the given address with
+// the given client. The client should not follow redirects, since requests
+// redirected by standbys are sent again with their body.
+func NewAPIProxy(client *http.Client, address string) (*APIProxy, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %q: %s", address, err)
+	}
+	return &APIProxy{
+		client:  client,
+		address: u,
+	}, nil
+}
+
+func (ap *APIProxy) Send(req *SendRequest) (*SendResponse, error) {
+	u := *ap.address
+	u.Path = strings.TrimSuffix(ap.address.Path, "/") + req.Request.URL.Path
+	u.RawQuery = req.Request.URL.RawQuery
+
+	// Follow a single redirect, as the API client does
+	for redirects := 0; ; redirects++ {
+		r, err := http.NewRequest(req.Request.Method, u.String(), bytes.NewReader(req.RequestBody))
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range req.Request.Header {
+			r.Header[k] = v
+		}
+		for _, h := range hopHeaders {
+			r.Header.Del(h)
+		}
+		r.Header.Del("X-Vault-Token")
+		if req.Token != "" {
+			r.Header.Set("X-Vault-Token", req.Token)
+		}
+
+		resp, err := ap.client.Do(r)
+		if err != nil {
+			return nil, err
+		}
+
+		switch resp.StatusCode {
+		case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect:
+			if redirects > 0 {
+				break
+			}
+			loc, err := resp.Location()
+			resp.Body.Close()
+			if err != nil {
+				return nil, err
+			}
+			if u.Scheme == "https" && loc.Scheme != "https" {
+				return nil, fmt.Errorf("redirect would cause protocol downgrade")
+			}
+			u = *loc
+			continue
+		}
+
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		header := resp.Header
+		for _, h := range hopHeaders {
+			header.Del(h)
+		}
+
+		return &SendResponse{
+			StatusCode: resp.StatusCode,
+			Header:     header,
+			Body:       body,
+		}, nil
+	}
+}
@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/api"
+)
+
+func TestAPIProxy(t *testing.T) {
+	active := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.URL.Path != "/v1/secret/foo" || r.URL.RawQuery != "list=true" || string(body) != `{"foo":"bar"}` {
+			w.WriteHeader(400)
+			return
+		}
+		if r.Header.Get("X-Vault-Token") != "token1" || r.Header.Get("X-Vault-Wrap-TTL") != "5m" {
+			w.WriteHeader(403)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data": {"foo": "bar"}}`))
+	}))
+	defer active.Close()
+
+	standby := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, active.URL+r.URL.RequestURI(), http.StatusTemporaryRedirect)
+	}))
+	defer standby.Close()
+
+	config := api.DefaultConfig()
+	config.Address = standby.URL
+	if _, err := api.NewClient(config); err != nil {
+		t.Fatal(err)
+	}
+
+	proxy, err := NewAPIProxy(config.HttpClient, standby.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest("PUT", "/v1/secret/foo?list=true", nil)
+	r.Header.Set("X-Vault-Token", "ignored")
+	r.Header.Set("X-Vault-Wrap-TTL", "5m")
+	resp, err := proxy.Send(&SendRequest{
+		Token:       "token1",
+		Request:     r,
+		RequestBody: []byte(`{"foo":"bar"}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 || !strings.Contains(string(resp.Body), `"foo": "bar"`) {
+		t.Fatalf("bad: %d %s", resp.StatusCode, resp.Body)
+	}
+}
@@ -0,0 +1,515 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/ast"
+	"github.com/hashicorp/vault/helper/parseutil"
+)
+
+// Config is the configuration for the vault agent.
+type Config struct {
+	PidFile       string `hcl:"pid_file"`
+	ExitAfterAuth bool   `hcl:"exit_after_auth"`
+
+	Vault     *Vault      `hcl:"-"`
+	AutoAuth  *AutoAuth   `hcl:"-"`
+	Cache     *Cache      `hcl:"-"`
+	Listeners []*Listener `hcl:"-"`
+	Templates []*Template `hcl:"-"`
+}
+
+// Vault is the configuration of the connection to the Vault server. Unset
+// options fall back to the VAULT_* environment variables.
+type Vault struct {
+	Address       string `hcl:"address"`
+	CACert        string `hcl:"ca_cert"`
+	CAPath        string `hcl:"ca_path"`
+	ClientCert    string `hcl:"client_cert"`
+	ClientKey     string `hcl:"client_key"`
+	TLSSkipVerify bool   `hcl:"tls_skip_verify"`
+}
+
+// AutoAuth is the configuration of the authentication method the agent
+// logs in with, and of the sinks the token is written to.
+type AutoAuth struct {
+	Method *Method
+	Sinks  []*Sink
+}
+
+// Method is an authentication method. The configuration is passed to the
+// credential provider of the method type, as for "vault auth".
+type Method struct {
+	Type      string
+	MountPath string
+	Config    map[string]interface{}
+}
+
+// Sink is a destination the token is written to, optionally response-wrapped
+// or encrypted for the owner of a public key.
+type Sink struct {
+	Type    string
+	WrapTTL time.Duration
+	DHType  string
+	DHPath  string
+	AAD     string
+	Config  map[string]interface{}
+}
+
+// Cache is the configuration of the caching proxy.
+type Cache struct {
+	UseAutoAuthToken bool `hcl:"use_auto_auth_token"`
+}
+
+// Listener is the configuration of a listener of the caching proxy, with the
+// same options as the listeners of the server.
+type Listener struct {
+	Type   string
+	Config map[string]interface{}
+}
+
+// Template is a Go template rendered to a file with the auto-auth token.
+// The command is run whenever the rendered file changes.
+type Template struct {
+	Source      string      `hcl:"source"`
+	Contents    string      `hcl:"contents"`
+	Destination string      `hcl:"destination"`
+	Perms       os.FileMode `hcl:"-"`
+	Command     string      `hcl:"command"`
+}
+
+// LoadConfig loads the configuration from the given file.
+func LoadConfig(path string) (*Config, error) {
+	d, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseConfig(string(d))
+}
+
+// ParseConfig parses the configuration.
+func ParseConfig(d string) (*Config, error) {
+	obj, err := hcl.Parse(d)
+	if err != nil {
+		return nil, err
+	}
+
+	var result Config
+	if err := hcl.DecodeObject(&result, obj); err != nil {
+		return nil, err
+	}
+
+	list, ok := obj.Node.(*ast.ObjectList)
+	if !ok {
+		return nil, fmt.Errorf("error parsing: file doesn't contain a root object")
+	}
+
+	valid := []string{
+		"pid_file",
+		"exit_after_auth",
+		"vault",
+		"auto_auth",
+		"cache",
+		"listener",
+		"template",
+	}
+	if err := checkHCLKeys(list, valid); err != nil {
+		return nil, err
+	}
+
+	if o := list.Filter("vault"); len(o.Items) > 0 {
+		if err := parseVault(&result, o); err != nil {
+			return nil, fmt.Errorf("error parsing 'vault': %s", err)
+		}
+	}
+
+	if o := list.Filter("auto_auth"); len(o.Items) > 0 {
+		if err := parseAutoAuth(&result, o); err != nil {
+			return nil, fmt.Errorf("error parsing 'auto_auth': %s", err)
+		}
+	}
+
+	if o := list.Filter("cache"); len(o.Items) > 0 {
+		if err := parseCache(&result, o); err != nil {
+			return nil, fmt.Errorf("error parsing 'cache': %s", err)
+		}
+	}
+
+	if o := list.Filter("listener"); len(o.Items) > 0 {
+		if err := parseListeners(&result, o); err != nil {
+			return nil, fmt.Errorf("error parsing 'listener': %s", err)
+		}
+	}
+
+	if o := list.Filter("template"); len(o.Items) > 0 {
+		if err := parseTemplates(&result, o); err != nil {
+			return nil, fmt.Errorf("error parsing 'template': %s", err)
+		}
+	}
+
+	if result.Cache != nil && len(result.Listeners) == 0 {
+		return nil, fmt.Errorf("'cache' requires at least one 'listener'")
+	}
+	if result.Cache == nil && len(result.Listeners) > 0 {
+		return nil, fmt.Errorf("'listener' requires a 'cache' block")
+	}
+	if result.Cache != nil && result.Cache.UseAutoAuthToken && result.AutoAuth == nil {
+		return nil, fmt.Errorf("'use_auto_auth_token' requires an 'auto_auth' block")
+	}
+	if len(result.Templates) > 0 && result.AutoAuth == nil {
+		return nil, fmt.Errorf("'template' requires an 'auto_auth' block")
+	}
+	if result.ExitAfterAuth && result.AutoAuth == nil {
+		return nil, fmt.Errorf("'exit_after_auth' requires an 'auto_auth' block")
+	}
+
+	return &result, nil
+}
+
+func parseVault(result *Config, list *ast.ObjectList) error {
+	if len(list.Items) > 1 {
+		return fmt.Errorf("only one 'vault' block is permitted")
+	}
+
+	item := list.Items[0]
+
+	valid := []string{
+		"address",
+		"ca_cert",
+		"ca_path",
+		"client_cert",
+		"client_key",
+		"tls_skip_verify",
+	}
+	if err := checkHCLKeys(item.Val, valid); err != nil {
+		return multierror.Prefix(err, "vault:")
+	}
+
+	var v Vault
+	if err := hcl.DecodeObject(&v, item.Val); err != nil {
+		return multierror.Prefix(err, "vault:")
+	}
+
+	result.Vault = &v
+	return nil
+}
+
+func parseAutoAuth(result *Config, list *ast.ObjectList) error {
+	if len(list.Items) > 1 {
+		return fmt.Errorf("only one 'auto_auth' block is permitted")
+	}
+
+	item := list.Items[0]
+
+	valid := []string{
+		"method",
+		"sink",
+	}
+	if err := checkHCLKeys(item.Val, valid); err != nil {
+		return multierror.Prefix(err, "auto_auth:")
+	}
+
+	subs, ok := item.Val.(*ast.ObjectType)
+	if !ok {
+		return fmt.Errorf("auto_auth: could not parse")
+	}
+
+	var a AutoAuth
+
+	methods := subs.List.Filter("method")
+	if len(methods.Items) != 1 {
+		return fmt.Errorf("auto_auth: exactly one 'method' block is required")
+	}
+	method, err := parseMethod(methods.Items[0])
+	if err != nil {
+		return multierror.Prefix(err, "auto_auth.method:")
+	}
+	a.Method = method
+
+	for _, item := range subs.List.Filter("sink").Items {
+		sink, err := parseSink(item)
+		if err != nil {
+			return multierror.Prefix(err, "auto_auth.sink:")
+		}
+		a.Sinks = append(a.Sinks, sink)
+	}
+
+	result.AutoAuth = &a
+	return nil
+}
+
+func parseMethod(item *ast.ObjectItem) (*Method, error) {
+	if len(item.Keys) == 0 {
+		return nil, fmt.Errorf("method type must be specified")
+	}
+
+	valid := []string{
+		"mount_path",
+		"config",
+	}
+	if err := checkHCLKeys(item.Val, valid); err != nil {
+		return nil, err
+	}
+
+	var m struct {
+		MountPath string `hcl:"mount_path"`
+	}
+	if err := hcl.DecodeObject(&m, item.Val); err != nil {
+		return nil, err
+	}
+
+	config, err := parseSubConfig(item)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Method{
+		Type:      strings.ToLower(item.Keys[0].Token.Value().(string)),
+		MountPath: strings.Trim(m.MountPath, "/"),
+		Config:    config,
+	}, nil
+}
+
+func parseSink(item *ast.ObjectItem) (*Sink, error) {
+	if len(item.Keys) == 0 {
+		return nil, fmt.Errorf("sink type must be specified")
+	}
+
+	valid := []string{
+		"wrap_ttl",
+		"dh_type",
+		"dh_path",
+		"aad",
+		"config",
+	}
+	if err := checkHCLKeys(item.Val, valid); err != nil {
+		return nil, err
+	}
+
+	var s struct {
+		WrapTTLRaw interface{} `hcl:"wrap_ttl"`
+		DHType     string      `hcl:"dh_type"`
+		DHPath     string      `hcl:"dh_path"`
+		AAD        string      `hcl:"aad"`
+	}
+	if err := hcl.DecodeObject(&s, item.Val); err != nil {
+		return nil, err
+	}
+
+	sink := &Sink{
+		Type:   strings.ToLower(item.Keys[0].Token.Value().(string)),
+		DHType: s.DHType,
+		DHPath: s.DHPath,
+		AAD:    s.AAD,
+	}
+
+	if s.WrapTTLRaw != nil {
+		var err error
+		if sink.WrapTTL, err = parseutil.ParseDurationSecond(s.WrapTTLRaw); err != nil {
+			return nil, err
+		}
+	}
+
+	switch sink.DHType {
+	case "":
+		if sink.DHPath != "" || sink.AAD != "" {
+			return nil, fmt.Errorf("'dh_path' and 'aad' require 'dh_type'")
+		}
+	case "curve25519":
+		if sink.DHPath == "" {
+			return nil, fmt.Errorf("'dh_type' requires 'dh_path'")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported 'dh_type' %q, only curve25519 is supported", sink.DHType)
+	}
+
+	config, err := parseSubConfig(item)
+	if err != nil {
+		return nil, err
+	}
+	sink.Config = config
+
+	return sink, nil
+}
+
+// parseSubConfig returns the contents of the "config" block of an item
+func parseSubConfig(item *ast.ObjectItem) (map[string]interface{}, error) {
+	config := make(map[string]interface{})
+
+	subs, ok := item.Val.(*ast.ObjectType)
+	if !ok {
+		return config, nil
+	}
+
+	list := subs.List.Filter("config")
+	if len(list.Items) > 1 {
+		return nil, fmt.Errorf("only one 'config' block is permitted")
+	}
+	if len(list.Items) == 1 {
+		if err := hcl.DecodeObject(&config, list.Items[0].Val); err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
+func parseCache(result *Config, list *ast.ObjectList) error {
+	if len(list.Items) > 1 {
+		return fmt.Errorf("only one 'cache' block is permitted")
+	}
+
+	item := list.Items[0]
+
+	valid := []string{
+		"use_auto_auth_token",
+	}
+	if err := checkHCLKeys(item.Val, valid); err != nil {
+		return multierror.Prefix(err, "cache:")
+	}
+
+	var c Cache
+	if err := hcl.DecodeObject(&c, item.Val); err != nil {
+		return multierror.Prefix(err, "cache:")
+	}
+
+	result.Cache = &c
+	return nil
+}
+
+func parseListeners(result *Config, list *ast.ObjectList) error {
+	listeners := make([]*Listener, 0, len(list.Items))
+	for _, item := range list.Items {
+		key := "listener"
+		if len(item.Keys) > 0 {
+			key = item.Keys[0].Token.Value().(string)
+		}
+
+		valid := []string{
+			"address",
+			"tls_disable",
+			"tls_cert_file",
+			"tls_key_file",
+			"tls_min_version",
+			"tls_cipher_suites",
+			"tls_prefer_server_cipher_suites",
+			"tls_require_and_verify_client_cert",
+			"tls_client_ca_file",
+		}
+		if err := checkHCLKeys(item.Val, valid); err != nil {
+			return multierror.Prefix(err, fmt.Sprintf("listeners.%s:", key))
+		}
+
+		var m map[string]interface{}
+		if err := hcl.DecodeObject(&m, item.Val); err != nil {
+			return multierror.Prefix(err, fmt.Sprintf("listeners.%s:", key))
+		}
+
+		listeners = append(listeners, &Listener{
+			Type:   strings.ToLower(key),
+			Config: m,
+		})
+	}
+
+	result.Listeners = listeners
+	return nil
+}
+
+func parseTemplates(result *Config, list *ast.ObjectList) error {
+	templates := make([]*Template, 0, len(list.Items))
+	for i, item := range list.Items {
+		valid := []string{
+			"source",
+			"contents",
+			"destination",
+			"perms",
+			"command",
+		}
+		if err := checkHCLKeys(item.Val, valid); err != nil {
+			return multierror.Prefix(err, fmt.Sprintf("template.%d:", i))
+		}
+
+		var t struct {
+			Template `hcl:",squash"`
+			PermsRaw interface{} `hcl:"perms"`
+		}
+		if err := hcl.DecodeObject(&t, item.Val); err != nil {
+			return multierror.Prefix(err, fmt.Sprintf("template.%d:", i))
+		}
+		tmpl := t.Template
+
+		switch {
+		case tmpl.Destination == "":
+			return fmt.Errorf("template.%d: 'destination' must be specified", i)
+		case tmpl.Source == "" && tmpl.Contents == "":
+			return fmt.Errorf("template.%d: 'source' or 'contents' must be specified", i)
+		case tmpl.Source != "" && tmpl.Contents != "":
+			return fmt.Errorf("template.%d: only one of 'source' and 'contents' is permitted", i)
+		}
+
+		perms, err := parseFileMode(t.PermsRaw, 0644)
+		if err != nil {
+			return fmt.Errorf("template.%d: invalid 'perms': %s", i, err)
+		}
+		tmpl.Perms = perms
+
+		templates = append(templates, &tmpl)
+	}
+
+	result.Templates = templates
+	return nil
+}
+
+// parseFileMode parses a file mode. Numbers are taken as they are, so that
+// octal literals such as 0600 work, while strings are parsed as octal.
+func parseFileMode(raw interface{}, def os.FileMode) (os.FileMode, error) {
+	switch mode := raw.(type) {
+	case nil:
+		return def, nil
+	case int:
+		return os.FileMode(mode), nil
+	case int64:
+		return os.FileMode(mode), nil
+	case string:
+		m, err := strconv.ParseUint(mode, 8, 32)
+		if err != nil {
+			return 0, err
+		}
+		return os.FileMode(m), nil
+	}
+	return 0, fmt.Errorf("unexpected type %T", raw)
+}
+
+func checkHCLKeys(node ast.Node, valid []string) error {
+	var list *ast.ObjectList
+	switch n := node.(type) {
+	case *ast.ObjectList:
+		list = n
+	case *ast.ObjectType:
+		list = n.List
+	default:
+		return fmt.Errorf("cannot check HCL keys of type %T", n)
+	}
+
+	validMap := make(map[string]struct{}, len(valid))
+	for _, v := range valid {
+		validMap[v] = struct{}{}
+	}
+
+	var result error
+	for _, item := range list.Items {
+		key := item.Keys[0].Token.Value().(string)
+		if _, ok := validMap[key]; !ok {
+			result = multierror.Append(result, fmt.Errorf(
+				"invalid key '%s' on line %d", key, item.Assign.Line))
+		}
+	}
+
+	return result
+}
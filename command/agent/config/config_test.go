@@ -0,0 +1,188 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	config, err := LoadConfig("./test-fixtures/config.hcl")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := &Config{
+		PidFile: "./pidfile",
+
+		Vault: &Vault{
+			Address:       "https://vault.example.com:8200",
+			CACert:        "/etc/vault/ca.pem",
+			TLSSkipVerify: true,
+		},
+
+		AutoAuth: &AutoAuth{
+			Method: &Method{
+				Type:      "approle",
+				MountPath: "auth/approle-prod",
+				Config: map[string]interface{}{
+					"role_id_file":   "/etc/vault/role_id",
+					"secret_id_file": "/etc/vault/secret_id",
+				},
+			},
+			Sinks: []*Sink{
+				&Sink{
+					Type: "file",
+					Config: map[string]interface{}{
+						"path": "/tmp/token",
+						"mode": 0600,
+					},
+				},
+				&Sink{
+					Type:    "file",
+					WrapTTL: 5 * time.Minute,
+					DHType:  "curve25519",
+					DHPath:  "/etc/vault/dh.pub",
+					AAD:     "foo",
+					Config: map[string]interface{}{
+						"path": "/tmp/token-wrapped",
+					},
+				},
+			},
+		},
+
+		Cache: &Cache{
+			UseAutoAuthToken: true,
+		},
+
+		Listeners: []*Listener{
+			&Listener{
+				Type: "tcp",
+				Config: map[string]interface{}{
+					"address":     "127.0.0.1:8300",
+					"tls_disable": true,
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Fatalf("expected \n\n%#v\n\n to be \n\n%#v\n\n", config, expected)
+	}
+}
+
+func TestLoadConfig_template(t *testing.T) {
+	config, err := LoadConfig("./test-fixtures/config-template.hcl")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := &Config{
+		ExitAfterAuth: true,
+
+		AutoAuth: &AutoAuth{
+			Method: &Method{
+				Type:   "cert",
+				Config: map[string]interface{}{},
+			},
+		},
+
+		Templates: []*Template{
+			&Template{
+				Source:      "/etc/vault/templates/db.ctmpl",
+				Destination: "/etc/app/db.conf",
+				Perms:       0600,
+				Command:     "systemctl reload app",
+			},
+			&Template{
+				Contents:    `{{ with secret "secret/foo" }}{{ .Data.password }}{{ end }}`,
+				Destination: "/etc/app/password",
+				Perms:       0644,
+			},
+		},
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Fatalf("expected \n\n%#v\n\n to be \n\n%#v\n\n", config, expected)
+	}
+}
+
+func TestParseConfig_errors(t *testing.T) {
+	cases := map[string]struct {
+		config string
+		err    string
+	}{
+		"invalid key": {
+			`foo = "bar"`,
+			"invalid key 'foo'",
+		},
+		"no method": {
+			`auto_auth { sink "file" { config = { path = "/tmp/token" } } }`,
+			"exactly one 'method' block is required",
+		},
+		"two methods": {
+			`auto_auth {
+				method "approle" {}
+				method "cert" {}
+			}`,
+			"exactly one 'method' block is required",
+		},
+		"unsupported dh type": {
+			`auto_auth {
+				method "cert" {}
+				sink "file" { dh_type = "rsa" }
+			}`,
+			"unsupported 'dh_type'",
+		},
+		"dh path without type": {
+			`auto_auth {
+				method "cert" {}
+				sink "file" { dh_path = "/etc/vault/dh.pub" }
+			}`,
+			"require 'dh_type'",
+		},
+		"cache without listener": {
+			`cache {}`,
+			"'cache' requires at least one 'listener'",
+		},
+		"listener without cache": {
+			`listener "tcp" { address = "127.0.0.1:8300" }`,
+			"'listener' requires a 'cache' block",
+		},
+		"template without destination": {
+			`auto_auth { method "cert" {} }
+			template { contents = "foo" }`,
+			"'destination' must be specified",
+		},
+		"template with source and contents": {
+			`auto_auth { method "cert" {} }
+			template {
+				source = "/tmp/foo.ctmpl"
+				contents = "foo"
+				destination = "/tmp/foo"
+			}`,
+			"only one of 'source' and 'contents'",
+		},
+		"template without auto-auth": {
+			`template {
+				contents = "foo"
+				destination = "/tmp/foo"
+			}`,
+			"'template' requires an 'auto_auth' block",
+		},
+		"auto-auth token without auto-auth": {
+			`cache { use_auto_auth_token = true }
+			listener "tcp" { address = "127.0.0.1:8300" }`,
+			"'use_auto_auth_token' requires an 'auto_auth' block",
+		},
+	}
+
+	for name, tc := range cases {
+		_, err := ParseConfig(tc.config)
+		if err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+		if !strings.Contains(err.Error(), tc.err) {
+			t.Fatalf("%s: expected error containing %q, got: %s", name, tc.err, err)
+		}
+	}
+}
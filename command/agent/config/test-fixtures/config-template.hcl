@@ -0,0 +1,17 @@
+exit_after_auth = true
+
+auto_auth {
+	method "cert" {}
+}
+
+template {
+	source = "/etc/vault/templates/db.ctmpl"
+	destination = "/etc/app/db.conf"
+	perms = 0600
+	command = "systemctl reload app"
+}
+
+template {
+	contents = "{{ with secret \"secret/foo\" }}{{ .Data.password }}{{ end }}"
+	destination = "/etc/app/password"
+}
@@ -0,0 +1,43 @@
+pid_file = "./pidfile"
+
+vault {
+	address = "https://vault.example.com:8200"
+	ca_cert = "/etc/vault/ca.pem"
+	tls_skip_verify = true
+}
+
+auto_auth {
+	method "AppRole" {
+		mount_path = "auth/approle-prod/"
+		config = {
+			role_id_file = "/etc/vault/role_id"
+			secret_id_file = "/etc/vault/secret_id"
+		}
+	}
+
+	sink "file" {
+		config = {
+			path = "/tmp/token"
+			mode = 0600
+		}
+	}
+
+	sink "file" {
+		wrap_ttl = "5m"
+		dh_type = "curve25519"
+		dh_path = "/etc/vault/dh.pub"
+		aad = "foo"
+		config = {
+			path = "/tmp/token-wrapped"
+		}
+	}
+}
+
+cache {
+	use_auto_auth_token = true
+}
+
+listener "tcp" {
+	address = "127.0.0.1:8300"
+	tls_disable = true
+}
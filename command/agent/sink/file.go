@@ -0,0 +1,89 @@
+package sink
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+const defaultFileMode = 0640
+
+// FileSink writes tokens to a file. The file is replaced atomically, so that
+// readers never see a partially written token.
+type FileSink struct {
+	path string
+	mode os.FileMode
+}
+
+// NewFileSink returns a FileSink for the given configuration, which must
+// contain a path and may contain a file mode.
+func NewFileSink(conf map[string]interface{}) (*FileSink, error) {
+	var c struct {
+		Path string      `mapstructure:"path"`
+		Mode interface{} `mapstructure:"mode"`
+	}
+	if err := mapstructure.WeakDecode(conf, &c); err != nil {
+		return nil, err
+	}
+
+	if c.Path == "" {
+		return nil, fmt.Errorf("'path' must be specified")
+	}
+
+	f := &FileSink{
+		path: c.Path,
+		mode: defaultFileMode,
+	}
+
+	// Numbers are taken as they are, so that octal literals such as 0600
+	// work, while strings are parsed as octal
+	switch mode := c.Mode.(type) {
+	case nil:
+	case int:
+		f.mode = os.FileMode(mode)
+	case int64:
+		f.mode = os.FileMode(mode)
+	case string:
+		m, err := strconv.ParseUint(mode, 8, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid 'mode' %q: %s", mode, err)
+		}
+		f.mode = os.FileMode(m)
+	default:
+		return nil, fmt.Errorf("invalid 'mode' %v", mode)
+	}
+
+	return f, nil
+}
+
+func (f *FileSink) WriteToken(token string) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(f.path), "."+filepath.Base(f.path))
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	if _, err := tmp.WriteString(token); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Chmod(tmpPath, f.mode); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := os.Rename(tmpPath, f.path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
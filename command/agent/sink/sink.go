@@ -0,0 +1,174 @@
+package sink
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/helper/dhutil"
+	"github.com/hashicorp/vault/helper/jsonutil"
+	log "github.com/mgutz/logxi/v1"
+)
+
+const retryInterval = 5 * time.Second
+
+// Sink is a destination tokens are written to.
+type Sink interface {
+	WriteToken(string) error
+}
+
+// SinkConfig is a sink together with the transformations applied to the
+// token before it is written. With a wrap TTL, the token is response-wrapped
+// and the wrapping information is written. With a DH type, the token (or the
+// wrapping information) is encrypted for the owner of the public key at DH
+// path.
+type SinkConfig struct {
+	Sink
+	Client  *api.Client
+	WrapTTL time.Duration
+	DHType  string
+	DHPath  string
+	AAD     string
+}
+
+// SinkServer writes the tokens it receives to its sinks.
+type SinkServer struct {
+	logger log.Logger
+}
+
+// NewSinkServer returns a SinkServer.
+func NewSinkServer(logger log.Logger) *SinkServer {
+	return &SinkServer{
+		logger: logger,
+	}
+}
+
+// Run writes every token received on the incoming channel to the sinks
+// until the stop channel is closed. Sinks that fail are retried until they
+// succeed or a new token arrives.
+func (ss *SinkServer) Run(incoming <-chan string, sinks []*SinkConfig, stopCh <-chan struct{}) {
+	var token string
+	var pending []*SinkConfig
+	var retryCh <-chan time.Time
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		case token = <-incoming:
+			pending = sinks
+		case <-retryCh:
+		}
+
+		var failed []*SinkConfig
+		for _, sc := range pending {
+			if err := sc.write(token); err != nil {
+				ss.logger.Error("agent/sink: error writing token", "error", err)
+				failed = append(failed, sc)
+			}
+		}
+		pending = failed
+
+		retryCh = nil
+		if len(pending) > 0 {
+			retryCh = time.After(retryInterval)
+		}
+	}
+}
+
+// WriteToken writes the token to the sinks once, without retrying.
+func WriteToken(sinks []*SinkConfig, token string) error {
+	var result error
+	for _, sc := range sinks {
+		if err := sc.write(token); err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+	return result
+}
+
+// write transforms the token and writes it to the sink
+func (sc *SinkConfig) write(token string) error {
+	out := token
+
+	if sc.WrapTTL > 0 {
+		wrapped, err := sc.wrap(token)
+		if err != nil {
+			return fmt.Errorf("error wrapping token: %s", err)
+		}
+		out = wrapped
+	}
+
+	if sc.DHType != "" {
+		encrypted, err := sc.encrypt(out)
+		if err != nil {
+			return fmt.Errorf("error encrypting token: %s", err)
+		}
+		out = encrypted
+	}
+
+	return sc.WriteToken(out)
+}
+
+// wrap response-wraps the token and returns the wrapping information as JSON
+func (sc *SinkConfig) wrap(token string) (string, error) {
+	client, err := sc.Client.Clone()
+	if err != nil {
+		return "", err
+	}
+	client.SetToken(token)
+
+	wrapTTL := strconv.FormatInt(int64(sc.WrapTTL.Seconds()), 10)
+	client.SetWrappingLookupFunc(func(operation, path string) string {
+		return wrapTTL
+	})
+
+	secret, err := client.Logical().Write("sys/wrapping/wrap", map[string]interface{}{
+		"token": token,
+	})
+	if err != nil {
+		return "", err
+	}
+	if secret == nil || secret.WrapInfo == nil {
+		return "", fmt.Errorf("response was not wrapped")
+	}
+
+	b, err := json.Marshal(secret.WrapInfo)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// encrypt encrypts the value for the public key at the DH path, which is
+// read on every write so that the key can be rotated, and returns the
+// envelope as JSON
+func (sc *SinkConfig) encrypt(value string) (string, error) {
+	b, err := ioutil.ReadFile(sc.DHPath)
+	if err != nil {
+		return "", fmt.Errorf("error reading public key: %s", err)
+	}
+
+	var info dhutil.PublicKeyInfo
+	if err := jsonutil.DecodeJSON(b, &info); err != nil {
+		return "", fmt.Errorf("error decoding public key: %s", err)
+	}
+	if len(info.Curve25519PublicKey) == 0 {
+		return "", fmt.Errorf("no public key found at %q", sc.DHPath)
+	}
+
+	env, err := dhutil.Encrypt(info.Curve25519PublicKey, []byte(value), []byte(sc.AAD))
+	if err != nil {
+		return "", err
+	}
+
+	b, err = json.Marshal(env)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,174 @@
+package sink
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/helper/dhutil"
+	"github.com/hashicorp/vault/helper/logformat"
+	vaulthttp "github.com/hashicorp/vault/http"
+	"github.com/hashicorp/vault/vault"
+	log "github.com/mgutz/logxi/v1"
+)
+
+func TestFileSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-agent-sink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "token")
+	f, err := NewFileSink(map[string]interface{}{
+		"path": path,
+		"mode": "600",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, token := range []string{"foo", "bar"} {
+		if err := f.WriteToken(token); err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != token {
+			t.Fatalf("bad: %q", b)
+		}
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Fatalf("bad: %v", fi.Mode())
+	}
+
+	// Nothing but the token should be left in the directory
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("bad: %d files", len(files))
+	}
+
+	if _, err := NewFileSink(map[string]interface{}{}); err == nil {
+		t.Fatal("expected error without a path")
+	}
+	if _, err := NewFileSink(map[string]interface{}{"path": path, "mode": "999"}); err == nil {
+		t.Fatal("expected error with an invalid mode")
+	}
+}
+
+type testSink struct {
+	tokens chan string
+}
+
+func (s *testSink) WriteToken(token string) error {
+	s.tokens <- token
+	return nil
+}
+
+func TestSinkServer_encryptAndWrap(t *testing.T) {
+	core, _, rootToken := vault.TestCoreUnsealed(t)
+	ln, addr := vaulthttp.TestServer(t, core)
+	defer ln.Close()
+
+	config := api.DefaultConfig()
+	config.Address = addr
+	client, err := api.NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "vault-agent-sink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	pub, pri, err := dhutil.GeneratePublicPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(&dhutil.PublicKeyInfo{Curve25519PublicKey: pub})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dhPath := filepath.Join(dir, "dh.pub")
+	if err := ioutil.WriteFile(dhPath, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	plain := &testSink{tokens: make(chan string, 1)}
+	wrapped := &testSink{tokens: make(chan string, 1)}
+	encrypted := &testSink{tokens: make(chan string, 1)}
+	sinks := []*SinkConfig{
+		&SinkConfig{
+			Sink:   plain,
+			Client: client,
+		},
+		&SinkConfig{
+			Sink:    wrapped,
+			Client:  client,
+			WrapTTL: 5 * time.Minute,
+		},
+		&SinkConfig{
+			Sink:   encrypted,
+			Client: client,
+			DHType: "curve25519",
+			DHPath: dhPath,
+			AAD:    "foo",
+		},
+	}
+
+	incoming := make(chan string)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go NewSinkServer(logformat.NewVaultLogger(log.LevelTrace)).Run(incoming, sinks, stopCh)
+	incoming <- rootToken
+
+	if token := <-plain.tokens; token != rootToken {
+		t.Fatalf("bad: %q", token)
+	}
+
+	var wrapInfo api.SecretWrapInfo
+	if err := json.Unmarshal([]byte(<-wrapped.tokens), &wrapInfo); err != nil {
+		t.Fatal(err)
+	}
+	if wrapInfo.TTL != 300 || wrapInfo.CreationPath != "sys/wrapping/wrap" {
+		t.Fatalf("bad: %#v", wrapInfo)
+	}
+	secret, err := client.Logical().Unwrap(wrapInfo.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret.Data["token"] != rootToken {
+		t.Fatalf("bad: %#v", secret.Data)
+	}
+
+	var env dhutil.Envelope
+	if err := json.Unmarshal([]byte(<-encrypted.tokens), &env); err != nil {
+		t.Fatal(err)
+	}
+	out, err := dhutil.Decrypt(pri, &env, []byte("foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != rootToken {
+		t.Fatalf("bad: %q", out)
+	}
+	if _, err := dhutil.Decrypt(pri, &env, []byte("bar")); err == nil {
+		t.Fatal("expected error decrypting with the wrong AAD")
+	}
+}
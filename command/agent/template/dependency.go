@@ -0,0 +1,155 @@
+package template
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/mitchellh/mapstructure"
+)
+
+// dependency is a value fetched from Vault for the templates. It is kept
+// current until it is stopped: leases are renewed and the value is fetched
+// again when renewal stops, while values without a lease are fetched again
+// periodically.
+type dependency struct {
+	key   string
+	fetch func(*api.Client) (interface{}, *api.Secret, error)
+
+	// value is the result of the last fetch, and secret the secret it was
+	// read from
+	value  interface{}
+	secret *api.Secret
+
+	stopCh chan struct{}
+}
+
+// PKICertificate is the value returned by the pkiCert template function.
+type PKICertificate struct {
+	Cert         string
+	Key          string
+	KeyType      string
+	CA           string
+	CAChain      []string
+	SerialNumber string
+	Expiration   time.Time
+}
+
+// secretDependency reads the path, or writes the given key=value pairs to it
+func secretDependency(path string, args []string) (*dependency, error) {
+	data, err := parseArgs(args)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dependency{
+		key: dependencyKey("secret", path, args),
+		fetch: func(client *api.Client) (interface{}, *api.Secret, error) {
+			var secret *api.Secret
+			var err error
+			if len(data) == 0 {
+				secret, err = client.Logical().Read(path)
+			} else {
+				secret, err = client.Logical().Write(path, data)
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+			if secret == nil {
+				return nil, nil, fmt.Errorf("no secret exists at %s", path)
+			}
+			return secret, secret, nil
+		},
+	}, nil
+}
+
+// pkiCertDependency issues a certificate with the given key=value pairs
+func pkiCertDependency(path string, args []string) (*dependency, error) {
+	data, err := parseArgs(args)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dependency{
+		key: dependencyKey("pkiCert", path, args),
+		fetch: func(client *api.Client) (interface{}, *api.Secret, error) {
+			secret, err := client.Logical().Write(path, data)
+			if err != nil {
+				return nil, nil, err
+			}
+			if secret == nil || secret.Data == nil {
+				return nil, nil, fmt.Errorf("no certificate was issued by %s", path)
+			}
+
+			var resp struct {
+				Cert         string   `mapstructure:"certificate"`
+				Key          string   `mapstructure:"private_key"`
+				KeyType      string   `mapstructure:"private_key_type"`
+				CA           string   `mapstructure:"issuing_ca"`
+				CAChain      []string `mapstructure:"ca_chain"`
+				SerialNumber string   `mapstructure:"serial_number"`
+			}
+			if err := mapstructure.WeakDecode(secret.Data, &resp); err != nil {
+				return nil, nil, err
+			}
+			cert := PKICertificate{
+				Cert:         resp.Cert,
+				Key:          resp.Key,
+				KeyType:      resp.KeyType,
+				CA:           resp.CA,
+				CAChain:      resp.CAChain,
+				SerialNumber: resp.SerialNumber,
+			}
+
+			block, _ := pem.Decode([]byte(cert.Cert))
+			if block == nil {
+				return nil, nil, fmt.Errorf("certificate issued by %s is not PEM encoded", path)
+			}
+			parsed, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, nil, fmt.Errorf("error parsing certificate issued by %s: %s", path, err)
+			}
+			cert.Expiration = parsed.NotAfter
+
+			return &cert, secret, nil
+		},
+	}, nil
+}
+
+// refreshAfter returns how long the value can be used before it has to be
+// fetched again, when it cannot be renewed. Leases and certificates are
+// fetched again two thirds into their validity, and other secrets after the
+// static interval.
+func (d *dependency) refreshAfter(staticInterval time.Duration) time.Duration {
+	if cert, ok := d.value.(*PKICertificate); ok {
+		return time.Duration(float64(cert.Expiration.Sub(time.Now())) * 2 / 3)
+	}
+	if d.secret != nil && d.secret.LeaseID != "" && d.secret.LeaseDuration > 0 {
+		return time.Duration(d.secret.LeaseDuration) * time.Second * 2 / 3
+	}
+	return staticInterval
+}
+
+func (d *dependency) renewable() bool {
+	return d.secret != nil && d.secret.LeaseID != "" && d.secret.Renewable
+}
+
+// parseArgs parses key=value pairs
+func parseArgs(args []string) (map[string]interface{}, error) {
+	data := make(map[string]interface{}, len(args))
+	for _, arg := range args {
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid argument %q, expected key=value", arg)
+		}
+		data[parts[0]] = parts[1]
+	}
+	return data, nil
+}
+
+func dependencyKey(function, path string, args []string) string {
+	return function + "\x00" + path + "\x00" + strings.Join(args, "\x00")
+}
@@ -0,0 +1,367 @@
+package template
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/command/agent/config"
+	"github.com/hashicorp/vault/command/token"
+	log "github.com/mgutz/logxi/v1"
+)
+
+const (
+	// DefaultStaticSecretInterval is how often secrets without a lease are
+	// read again
+	DefaultStaticSecretInterval = 5 * time.Minute
+
+	retryInterval = 5 * time.Second
+	maxBackoff    = 1 * time.Minute
+)
+
+// ServerConfig is the configuration of a Server.
+type ServerConfig struct {
+	Client    *api.Client
+	Logger    log.Logger
+	Templates []*config.Template
+
+	// StaticSecretInterval is how often secrets without a lease are read
+	// again. Defaults to DefaultStaticSecretInterval.
+	StaticSecretInterval time.Duration
+}
+
+// Server renders templates to files with the token it is given. The
+// templates can use the following functions in addition to those of
+// text/template:
+//
+//	secret "path" ["key=value" ...]     reads the path, or writes the pairs
+//	                                    to it, and returns the *api.Secret
+//	pkiCert "path" ["key=value" ...]    issues a certificate, returning a
+//	                                    *PKICertificate
+//
+// Values are fetched once per token and kept current: leases are renewed,
+// and values are fetched again when renewal stops, two thirds into the
+// validity of leases and certificates that cannot be renewed, and
+// periodically for secrets without a lease. Templates are rendered again
+// whenever a value is fetched again, and files are only written, and their
+// commands run, when their contents change.
+type Server struct {
+	client         *api.Client
+	logger         log.Logger
+	templates      []*serverTemplate
+	staticInterval time.Duration
+
+	l        sync.Mutex
+	deps     map[string]*dependency
+	changeCh chan struct{}
+}
+
+type serverTemplate struct {
+	config *config.Template
+	tmpl   *template.Template
+}
+
+// NewServer returns a Server for the given configuration, parsing its
+// templates.
+func NewServer(conf *ServerConfig) (*Server, error) {
+	client, err := conf.Client.Clone()
+	if err != nil {
+		return nil, err
+	}
+	client.ClearToken()
+
+	s := &Server{
+		client:         client,
+		logger:         conf.Logger,
+		staticInterval: conf.StaticSecretInterval,
+		deps:           make(map[string]*dependency),
+		changeCh:       make(chan struct{}, 1),
+	}
+	if s.staticInterval == 0 {
+		s.staticInterval = DefaultStaticSecretInterval
+	}
+
+	funcs := template.FuncMap{
+		"secret":  s.secret,
+		"pkiCert": s.pkiCert,
+	}
+
+	for _, tc := range conf.Templates {
+		text := tc.Contents
+		if tc.Source != "" {
+			b, err := ioutil.ReadFile(tc.Source)
+			if err != nil {
+				return nil, fmt.Errorf("error reading template: %s", err)
+			}
+			text = string(b)
+		}
+
+		tmpl, err := template.New(tc.Destination).Funcs(funcs).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing template for %s: %s", tc.Destination, err)
+		}
+
+		s.templates = append(s.templates, &serverTemplate{
+			config: tc,
+			tmpl:   tmpl,
+		})
+	}
+
+	return s, nil
+}
+
+// Run renders the templates with every token received on the incoming
+// channel, and again whenever a value changes, until the stop channel is
+// closed. Failed renders are retried.
+func (s *Server) Run(incoming <-chan string, stopCh <-chan struct{}) {
+	defer s.stopDependencies()
+
+	var retryCh <-chan time.Time
+	for {
+		select {
+		case <-stopCh:
+			return
+		case token := <-incoming:
+			// Values fetched with the previous token may not outlive it
+			s.stopDependencies()
+			s.client.SetToken(token)
+		case <-s.changeCh:
+		case <-retryCh:
+		}
+
+		retryCh = nil
+		if err := s.render(); err != nil {
+			s.logger.Error("agent/template: error rendering templates", "error", err)
+			retryCh = time.After(retryInterval)
+		}
+	}
+}
+
+// RenderOnce renders the templates with the given token, without keeping
+// the values current.
+func (s *Server) RenderOnce(token string) error {
+	defer s.stopDependencies()
+
+	s.client.SetToken(token)
+	return s.render()
+}
+
+func (s *Server) render() error {
+	var result error
+	for _, t := range s.templates {
+		if err := s.renderTemplate(t); err != nil {
+			result = multierror.Append(result, fmt.Errorf("%s: %s", t.config.Destination, err))
+		}
+	}
+	return result
+}
+
+func (s *Server) renderTemplate(t *serverTemplate) error {
+	var buf bytes.Buffer
+	if err := t.tmpl.Execute(&buf, nil); err != nil {
+		return err
+	}
+
+	existing, err := ioutil.ReadFile(t.config.Destination)
+	if err == nil && bytes.Equal(existing, buf.Bytes()) {
+		return nil
+	}
+
+	if err := writeFile(t.config.Destination, buf.Bytes(), t.config.Perms); err != nil {
+		return err
+	}
+	s.logger.Info("agent/template: rendered template", "destination", t.config.Destination)
+
+	if t.config.Command == "" {
+		return nil
+	}
+
+	cmd, err := token.ExecScript(t.config.Command)
+	if err != nil {
+		return err
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("error running command %q: %s: %s", t.config.Command, err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+func (s *Server) secret(path string, args ...string) (*api.Secret, error) {
+	d, err := secretDependency(path, args)
+	if err != nil {
+		return nil, err
+	}
+	value, err := s.get(d)
+	if err != nil {
+		return nil, err
+	}
+	return value.(*api.Secret), nil
+}
+
+func (s *Server) pkiCert(path string, args ...string) (*PKICertificate, error) {
+	d, err := pkiCertDependency(path, args)
+	if err != nil {
+		return nil, err
+	}
+	value, err := s.get(d)
+	if err != nil {
+		return nil, err
+	}
+	return value.(*PKICertificate), nil
+}
+
+// get returns the value of the dependency, fetching it and starting to
+// keep it current if it is new
+func (s *Server) get(d *dependency) (interface{}, error) {
+	s.l.Lock()
+	existing, ok := s.deps[d.key]
+	if ok {
+		value := existing.value
+		s.l.Unlock()
+		return value, nil
+	}
+	s.l.Unlock()
+
+	value, secret, err := d.fetch(s.client)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := s.client.Clone()
+	if err != nil {
+		return nil, err
+	}
+	client.SetToken(s.client.Token())
+
+	d.value = value
+	d.secret = secret
+	d.stopCh = make(chan struct{})
+
+	s.l.Lock()
+	s.deps[d.key] = d
+	s.l.Unlock()
+
+	go s.watch(d, client)
+
+	return value, nil
+}
+
+// watch keeps the value of the dependency current until it is stopped
+func (s *Server) watch(d *dependency, client *api.Client) {
+	for {
+		s.l.Lock()
+		secret, renewable, wait := d.secret, d.renewable(), d.refreshAfter(s.staticInterval)
+		s.l.Unlock()
+
+		if renewable {
+			renewer, err := client.NewRenewer(&api.RenewerInput{
+				Secret: secret,
+			})
+			if err != nil {
+				s.logger.Error("agent/template: error creating renewer", "error", err)
+				return
+			}
+			go renewer.Renew()
+
+			stopped := false
+		RENEW:
+			for {
+				select {
+				case <-d.stopCh:
+					stopped = true
+					break RENEW
+				case err := <-renewer.DoneCh():
+					if err != nil {
+						s.logger.Warn("agent/template: error renewing lease", "lease_id", secret.LeaseID, "error", err)
+					}
+					break RENEW
+				case <-renewer.RenewCh():
+				}
+			}
+			renewer.Stop()
+			if stopped {
+				return
+			}
+		} else {
+			select {
+			case <-d.stopCh:
+				return
+			case <-time.After(wait):
+			}
+		}
+
+		backoff := retryInterval
+		for {
+			value, secret, err := d.fetch(client)
+			if err == nil {
+				s.l.Lock()
+				d.value = value
+				d.secret = secret
+				s.l.Unlock()
+				break
+			}
+
+			s.logger.Error("agent/template: error fetching value", "error", err, "backoff", backoff)
+			select {
+			case <-d.stopCh:
+				return
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+			if backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+		}
+
+		select {
+		case s.changeCh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (s *Server) stopDependencies() {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	for key, d := range s.deps {
+		close(d.stopCh)
+		delete(s.deps, key)
+	}
+}
+
+// writeFile replaces the file atomically
+func writeFile(path string, data []byte, perms os.FileMode) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Chmod(tmpPath, perms); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+package template
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/command/agent/config"
+	"github.com/hashicorp/vault/helper/logformat"
+	vaulthttp "github.com/hashicorp/vault/http"
+	"github.com/hashicorp/vault/vault"
+	log "github.com/mgutz/logxi/v1"
+)
+
+func testClient(t *testing.T) (*api.Client, func()) {
+	core, _, rootToken := vault.TestCoreUnsealed(t)
+	ln, addr := vaulthttp.TestServer(t, core)
+
+	apiConfig := api.DefaultConfig()
+	apiConfig.Address = addr
+	client, err := api.NewClient(apiConfig)
+	if err != nil {
+		ln.Close()
+		t.Fatal(err)
+	}
+	client.SetToken(rootToken)
+
+	return client, func() { ln.Close() }
+}
+
+func TestServer_RenderOnce(t *testing.T) {
+	client, closer := testClient(t)
+	defer closer()
+
+	if _, err := client.Logical().Write("secret/foo", map[string]interface{}{
+		"password": "bar",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "vault-agent-template")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sourcePath := filepath.Join(dir, "source.ctmpl")
+	if err := ioutil.WriteFile(sourcePath, []byte(`user={{ with secret "secret/foo" }}{{ .Data.password }}{{ end }}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ts, err := NewServer(&ServerConfig{
+		Client: client,
+		Logger: logformat.NewVaultLogger(log.LevelTrace),
+		Templates: []*config.Template{
+			&config.Template{
+				Source:      sourcePath,
+				Destination: filepath.Join(dir, "source"),
+				Perms:       0600,
+			},
+			&config.Template{
+				Contents:    `{{ with secret "secret/foo" }}{{ .Data.password }}{{ end }}`,
+				Destination: filepath.Join(dir, "contents"),
+				Perms:       0640,
+				Command:     "touch " + filepath.Join(dir, "touched"),
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The server clears the token of the client it is given
+	if err := ts.RenderOnce(client.Token()); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, expected := range map[string]string{"source": "user=bar", "contents": "bar"} {
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != expected {
+			t.Fatalf("%s: expected %q, got %q", name, expected, string(b))
+		}
+	}
+
+	fi, err := os.Stat(filepath.Join(dir, "contents"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0640 {
+		t.Fatalf("bad mode: %s", fi.Mode())
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "touched")); err != nil {
+		t.Fatalf("command was not run: %s", err)
+	}
+
+	// Unchanged contents do not run the command again
+	if err := os.Remove(filepath.Join(dir, "touched")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.RenderOnce(client.Token()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "touched")); !os.IsNotExist(err) {
+		t.Fatalf("command was run again: %v", err)
+	}
+}
+
+func TestServer_Run(t *testing.T) {
+	client, closer := testClient(t)
+	defer closer()
+
+	// Secrets from this mount have short leases that cannot be renewed, so
+	// they are fetched again two thirds into the lease
+	if err := client.Sys().Mount("static", &api.MountInput{
+		Type: "generic",
+		Config: api.MountConfigInput{
+			DefaultLeaseTTL: "1s",
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Logical().Write("static/foo", map[string]interface{}{
+		"password": "bar",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "vault-agent-template")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	destination := filepath.Join(dir, "password")
+	ts, err := NewServer(&ServerConfig{
+		Client: client,
+		Logger: logformat.NewVaultLogger(log.LevelTrace),
+		Templates: []*config.Template{
+			&config.Template{
+				Contents:    `{{ with secret "static/foo" }}{{ .Data.password }}{{ end }}`,
+				Destination: destination,
+				Perms:       0600,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	incoming := make(chan string)
+	stopCh := make(chan struct{})
+	doneCh := make(chan struct{})
+	go func() {
+		ts.Run(incoming, stopCh)
+		close(doneCh)
+	}()
+	defer func() {
+		close(stopCh)
+		<-doneCh
+	}()
+
+	waitForContents := func(expected string) {
+		var contents string
+		for i := 0; i < 50; i++ {
+			b, err := ioutil.ReadFile(destination)
+			if err == nil {
+				contents = string(b)
+				if contents == expected {
+					return
+				}
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		t.Fatalf("expected %q, got %q", expected, contents)
+	}
+
+	incoming <- client.Token()
+	waitForContents("bar")
+
+	// Rotated secrets are rendered again
+	if _, err := client.Logical().Write("static/foo", map[string]interface{}{
+		"password": "baz",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	waitForContents("baz")
+}
+
+func TestNewServer_errors(t *testing.T) {
+	client, err := api.NewClient(api.DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		template *config.Template
+		err      string
+	}{
+		"missing source": {
+			&config.Template{Source: "/nonexistent/foo.ctmpl", Destination: "/tmp/foo"},
+			"error reading template",
+		},
+		"invalid template": {
+			&config.Template{Contents: "{{ with }}", Destination: "/tmp/foo"},
+			"error parsing template",
+		},
+		"unknown function": {
+			&config.Template{Contents: `{{ foo "bar" }}`, Destination: "/tmp/foo"},
+			"error parsing template",
+		},
+	}
+
+	for name, tc := range cases {
+		_, err := NewServer(&ServerConfig{
+			Client:    client,
+			Logger:    logformat.NewVaultLogger(log.LevelTrace),
+			Templates: []*config.Template{tc.template},
+		})
+		if err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+		if !strings.Contains(err.Error(), tc.err) {
+			t.Fatalf("%s: expected error containing %q, got: %s", name, tc.err, err)
+		}
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	data, err := parseArgs([]string{"common_name=foo.example.com", "ttl=1h", "alt_names=a=b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data["common_name"] != "foo.example.com" || data["ttl"] != "1h" || data["alt_names"] != "a=b" {
+		t.Fatalf("bad: %#v", data)
+	}
+
+	if _, err := parseArgs([]string{"foo"}); err == nil {
+		t.Fatal("expected error")
+	}
+}
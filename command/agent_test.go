@@ -0,0 +1,263 @@
+package command
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/http"
+	"github.com/hashicorp/vault/meta"
+	"github.com/hashicorp/vault/vault"
+	"github.com/mitchellh/cli"
+)
+
+// testAgentAuthHandler logs in by creating a token with the root client
+type testAgentAuthHandler struct {
+	root *api.Client
+}
+
+func (h *testAgentAuthHandler) Auth(c *api.Client, m map[string]string) (string, error) {
+	if m["role"] != "foo" {
+		return "", fmt.Errorf("bad role %q", m["role"])
+	}
+	secret, err := h.root.Auth().Token().Create(&api.TokenCreateRequest{
+		TTL: "1h",
+	})
+	if err != nil {
+		return "", err
+	}
+	return secret.Auth.ClientToken, nil
+}
+
+func (h *testAgentAuthHandler) Help() string {
+	return ""
+}
+
+func TestAgent(t *testing.T) {
+	core, _, token := vault.TestCoreUnsealed(t)
+	ln, addr := http.TestServer(t, core)
+	defer ln.Close()
+
+	config := api.DefaultConfig()
+	config.Address = addr
+	client, err := api.NewClient(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	client.SetToken(token)
+
+	dir, err := ioutil.TempDir("", "vault-agent")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	sinkPath := filepath.Join(dir, "token")
+	configPath := filepath.Join(dir, "agent.hcl")
+	configData := fmt.Sprintf(`
+vault {
+	address = %q
+}
+
+auto_auth {
+	method "test" {
+		config = {
+			role = "foo"
+		}
+	}
+
+	sink "file" {
+		config = {
+			path = %q
+		}
+	}
+}
+`, addr, sinkPath)
+	if err := ioutil.WriteFile(configPath, []byte(configData), 0600); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	ui := new(cli.MockUi)
+	shutdownCh := make(chan struct{})
+	c := &AgentCommand{
+		Meta: meta.Meta{
+			Ui: ui,
+		},
+		Handlers: map[string]AuthHandler{
+			"test": &testAgentAuthHandler{root: client},
+		},
+		ShutdownCh: shutdownCh,
+	}
+
+	codeCh := make(chan int)
+	go func() {
+		codeCh <- c.Run([]string{"-config", configPath})
+	}()
+
+	var sinkToken string
+	for i := 0; i < 50 && sinkToken == ""; i++ {
+		time.Sleep(100 * time.Millisecond)
+		b, err := ioutil.ReadFile(sinkPath)
+		if err == nil {
+			sinkToken = string(b)
+		}
+	}
+	if sinkToken == "" {
+		t.Fatalf("no token written\n\n%s", ui.ErrorWriter.String())
+	}
+
+	secret, err := client.Auth().Token().Lookup(sinkToken)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if secret.Data["id"] != sinkToken {
+		t.Fatalf("bad: %#v", secret.Data)
+	}
+
+	close(shutdownCh)
+	select {
+	case code := <-codeCh:
+		if code != 0 {
+			t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("agent did not shut down")
+	}
+}
+
+func TestAgent_exitAfterAuth(t *testing.T) {
+	core, _, token := vault.TestCoreUnsealed(t)
+	ln, addr := http.TestServer(t, core)
+	defer ln.Close()
+
+	config := api.DefaultConfig()
+	config.Address = addr
+	client, err := api.NewClient(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	client.SetToken(token)
+
+	if _, err := client.Logical().Write("secret/foo", map[string]interface{}{
+		"password": "bar",
+	}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	dir, err := ioutil.TempDir("", "vault-agent")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	sinkPath := filepath.Join(dir, "token")
+	templatePath := filepath.Join(dir, "password")
+	configPath := filepath.Join(dir, "agent.hcl")
+	configData := fmt.Sprintf(`
+vault {
+	address = %q
+}
+
+auto_auth {
+	method "test" {
+		config = {
+			role = "foo"
+		}
+	}
+
+	sink "file" {
+		config = {
+			path = %q
+		}
+	}
+}
+
+template {
+	contents = "{{ with secret \"secret/foo\" }}{{ .Data.password }}{{ end }}"
+	destination = %q
+}
+`, addr, sinkPath, templatePath)
+	if err := ioutil.WriteFile(configPath, []byte(configData), 0600); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	ui := new(cli.MockUi)
+	c := &AgentCommand{
+		Meta: meta.Meta{
+			Ui: ui,
+		},
+		Handlers: map[string]AuthHandler{
+			"test": &testAgentAuthHandler{root: client},
+		},
+		ShutdownCh: make(chan struct{}),
+	}
+
+	if code := c.Run([]string{"-config", configPath, "-exit-after-auth"}); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	b, err := ioutil.ReadFile(sinkPath)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := client.Auth().Token().Lookup(string(b)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	b, err = ioutil.ReadFile(templatePath)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(b) != "bar" {
+		t.Fatalf("bad: %q", string(b))
+	}
+}
+
+func TestAgent_errors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-agent")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	configPath := filepath.Join(dir, "agent.hcl")
+	if err := ioutil.WriteFile(configPath, []byte(`auto_auth { method "foo" {} }`), 0600); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	cases := map[string]struct {
+		args []string
+		err  string
+	}{
+		"no config": {
+			nil,
+			"-config",
+		},
+		"unknown method": {
+			[]string{"-config", configPath},
+			"Unknown auto-auth method type: foo",
+		},
+	}
+
+	for name, tc := range cases {
+		ui := new(cli.MockUi)
+		c := &AgentCommand{
+			Meta: meta.Meta{
+				Ui: ui,
+			},
+			Handlers:   map[string]AuthHandler{},
+			ShutdownCh: make(chan struct{}),
+		}
+		if code := c.Run(tc.args); code != 1 {
+			t.Fatalf("%s: bad: %d", name, code)
+		}
+		if !strings.Contains(ui.ErrorWriter.String(), tc.err) {
+			t.Fatalf("%s: bad: %s", name, ui.ErrorWriter.String())
+		}
+	}
+}
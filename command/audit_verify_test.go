@@ -0,0 +1,107 @@
+package command
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/audit"
+	"github.com/hashicorp/vault/meta"
+	"github.com/mitchellh/cli"
+)
+
+func TestAuditVerify(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-test_audit_verify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	keyFile := filepath.Join(dir, "key")
+	if err := ioutil.WriteFile(keyFile, []byte("chain-key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Write a chained log split across a rotated, compressed file and the
+	// current file
+	chain := audit.NewHashChain([]byte("chain-key"), nil)
+	var rotated, current bytes.Buffer
+	for i, entry := range []string{
+		`{"type":"request"}`,
+		`{"type":"response"}`,
+		`{"type":"request"}`,
+	} {
+		linked, err := chain.Link([]byte(entry + "\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		chain.Commit(linked)
+		if i < 2 {
+			rotated.Write(linked)
+		} else {
+			current.Write(linked)
+		}
+	}
+
+	rotatedPath := filepath.Join(dir, "audit-2017-06-01T10-00-00.000.log.gz")
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write(rotated.Bytes())
+	gz.Close()
+	if err := ioutil.WriteFile(rotatedPath, compressed.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	currentPath := filepath.Join(dir, "audit.log")
+	if err := ioutil.WriteFile(currentPath, current.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(args ...string) (int, *cli.MockUi) {
+		ui := new(cli.MockUi)
+		c := &AuditVerifyCommand{
+			Meta: meta.Meta{
+				Ui: ui,
+			},
+		}
+		return c.Run(args), ui
+	}
+
+	code, ui := run("-key-file", keyFile, rotatedPath, currentPath)
+	if code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+	if !strings.Contains(ui.OutputWriter.String(), "Verified 3 entries in 2 file(s)") {
+		t.Fatalf("bad: %s", ui.OutputWriter.String())
+	}
+
+	// Files given out of order break the chain
+	code, ui = run("-key-file", keyFile, currentPath, rotatedPath)
+	if code != 2 {
+		t.Fatalf("expected reordered files to fail, got %d", code)
+	}
+
+	// Modifying an entry breaks the link from the entry after it
+	tampered := bytes.Replace(rotated.Bytes(), []byte("request"), []byte("response"), 1)
+	compressed.Reset()
+	gz = gzip.NewWriter(&compressed)
+	gz.Write(tampered)
+	gz.Close()
+	if err := ioutil.WriteFile(rotatedPath, compressed.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	code, ui = run("-key-file", keyFile, rotatedPath, currentPath)
+	if code != 2 {
+		t.Fatalf("expected a modified entry to fail, got %d", code)
+	}
+	if !strings.Contains(ui.ErrorWriter.String(), ".log.gz:2") {
+		t.Fatalf("expected the failing entry to be reported: %s", ui.ErrorWriter.String())
+	}
+
+	if code, _ := run(rotatedPath); code != 1 {
+		t.Fatalf("expected a missing -key-file to fail, got %d", code)
+	}
+}
@@ -53,6 +53,9 @@ func TestLoadConfigFile(t *testing.T) {
 			DisableHostname: false,
 			DogStatsDAddr:   "127.0.0.1:7254",
 			DogStatsDTags:   []string{"tag_1:val_1", "tag_2:val_2"},
+
+			PrometheusRetentionTime:    24 * time.Hour,
+			PrometheusRetentionTimeRaw: "24h",
 		},
 
 		DisableCache:    true,
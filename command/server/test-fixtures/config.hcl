@@ -23,6 +23,7 @@ telemetry {
     statsite_address = "foo"
     dogstatsd_addr = "127.0.0.1:7254"
     dogstatsd_tags = ["tag_1:val_1", "tag_2:val_2"]
+    prometheus_retention_time = "24h"
 }
 
 max_lease_ttl = "10h"
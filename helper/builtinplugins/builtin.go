@@ -2,11 +2,14 @@ package builtinplugins
 
 import (
 	"github.com/hashicorp/vault/plugins/database/cassandra"
+	"github.com/hashicorp/vault/plugins/database/elasticsearch"
 	"github.com/hashicorp/vault/plugins/database/hana"
 	"github.com/hashicorp/vault/plugins/database/mongodb"
 	"github.com/hashicorp/vault/plugins/database/mssql"
 	"github.com/hashicorp/vault/plugins/database/mysql"
 	"github.com/hashicorp/vault/plugins/database/postgresql"
+	"github.com/hashicorp/vault/plugins/database/redis"
+	"github.com/hashicorp/vault/plugins/database/redshift"
 )
 
 // BuiltinFactory is the func signature that should be returned by
@@ -21,11 +24,14 @@ var plugins = map[string]BuiltinFactory{
 	"mysql-rds-database-plugin":    mysql.New(mysql.LegacyMetadataLen, mysql.LegacyUsernameLen),
 	"mysql-legacy-database-plugin": mysql.New(mysql.LegacyMetadataLen, mysql.LegacyUsernameLen),
 
-	"postgresql-database-plugin": postgresql.New,
-	"mssql-database-plugin":      mssql.New,
-	"cassandra-database-plugin":  cassandra.New,
-	"mongodb-database-plugin":    mongodb.New,
-	"hana-database-plugin":       hana.New,
+	"postgresql-database-plugin":    postgresql.New,
+	"mssql-database-plugin":         mssql.New,
+	"cassandra-database-plugin":     cassandra.New,
+	"mongodb-database-plugin":       mongodb.New,
+	"hana-database-plugin":          hana.New,
+	"redis-database-plugin":         redis.New,
+	"elasticsearch-database-plugin": elasticsearch.New,
+	"redshift-database-plugin":      redshift.New,
 }
 
 // Get returns the BuiltinFactory func for a particular backend plugin
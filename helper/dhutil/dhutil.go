@@ -0,0 +1,127 @@
+// Package dhutil implements the Diffie-Hellman key exchange used to encrypt
+// tokens written by Vault Agent sinks, so that only the holder of the private
+// key can read them.
+package dhutil
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+// PublicKeyInfo is the format of the file holding the public key of the
+// recipient
+type PublicKeyInfo struct {
+	Curve25519PublicKey []byte `json:"curve25519_public_key"`
+}
+
+// Envelope is the format of an encrypted payload. The public key is the
+// ephemeral key of the sender, which the recipient combines with its private
+// key to derive the encryption key.
+type Envelope struct {
+	Curve25519PublicKey []byte `json:"curve25519_public_key"`
+	Nonce               []byte `json:"nonce"`
+	EncryptedPayload    []byte `json:"encrypted_payload"`
+}
+
+// GeneratePublicPrivateKey returns a new curve25519 key pair
+func GeneratePublicPrivateKey() ([]byte, []byte, error) {
+	var scalar, public [32]byte
+
+	if _, err := io.ReadFull(rand.Reader, scalar[:]); err != nil {
+		return nil, nil, err
+	}
+
+	curve25519.ScalarBaseMult(&public, &scalar)
+	return public[:], scalar[:], nil
+}
+
+// GenerateSharedKey returns the key shared by the owner of the private key
+// and the owner of the other public key, derived with HKDF-SHA256
+func GenerateSharedKey(ourPrivate, theirPublic []byte) ([]byte, error) {
+	if len(ourPrivate) != 32 {
+		return nil, fmt.Errorf("invalid private key length: %d", len(ourPrivate))
+	}
+	if len(theirPublic) != 32 {
+		return nil, fmt.Errorf("invalid public key length: %d", len(theirPublic))
+	}
+
+	var scalar, pub, secret [32]byte
+	copy(scalar[:], ourPrivate)
+	copy(pub[:], theirPublic)
+
+	curve25519.ScalarMult(&secret, &scalar, &pub)
+
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret[:], nil, []byte("vault-agent-dh")), key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Encrypt encrypts the plaintext for the owner of the public key, with
+// optional additional authenticated data
+func Encrypt(theirPublic, plaintext, aad []byte) (*Envelope, error) {
+	public, private, err := GeneratePublicPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := GenerateSharedKey(private, theirPublic)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return &Envelope{
+		Curve25519PublicKey: public,
+		Nonce:               nonce,
+		EncryptedPayload:    gcm.Seal(nil, nonce, plaintext, aad),
+	}, nil
+}
+
+// Decrypt decrypts an envelope with the private key it was encrypted for
+func Decrypt(ourPrivate []byte, env *Envelope, aad []byte) ([]byte, error) {
+	if env == nil {
+		return nil, errors.New("nil envelope")
+	}
+
+	key, err := GenerateSharedKey(ourPrivate, env.Curve25519PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(env.Nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("invalid nonce length: %d", len(env.Nonce))
+	}
+
+	return gcm.Open(nil, env.Nonce, env.EncryptedPayload, aad)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,41 @@
+package dhutil
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	public, private, err := GeneratePublicPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env, err := Encrypt(public, []byte("token"), []byte("aad"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(env.EncryptedPayload, []byte("token")) {
+		t.Fatal("payload not encrypted")
+	}
+
+	plaintext, err := Decrypt(private, env, []byte("aad"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(plaintext) != "token" {
+		t.Fatalf("bad: %q", plaintext)
+	}
+
+	if _, err := Decrypt(private, env, []byte("other")); err == nil {
+		t.Fatal("expected error with wrong aad")
+	}
+
+	_, otherPrivate, err := GeneratePublicPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Decrypt(otherPrivate, env, []byte("aad")); err == nil {
+		t.Fatal("expected error with wrong key")
+	}
+}
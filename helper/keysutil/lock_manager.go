@@ -1,10 +1,13 @@
 package keysutil
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"sync"
+	"time"
 
+	"github.com/hashicorp/vault/helper/errutil"
 	"github.com/hashicorp/vault/helper/jsonutil"
 	"github.com/hashicorp/vault/logical"
 )
@@ -41,6 +44,12 @@ type PolicyRequest struct {
 
 	// Whether to upsert
 	Upsert bool
+
+	// Whether an imported key may later be rotated
+	AllowImportedKeyRotation bool
+
+	// How often the key should be automatically rotated
+	AutoRotatePeriod time.Duration
 }
 
 type LockManager struct {
@@ -247,7 +256,7 @@ func (lm *LockManager) getPolicyCommon(req PolicyRequest, lockType bool) (*Polic
 				return nil, nil, false, fmt.Errorf("convergent encryption requires derivation to be enabled")
 			}
 
-		case KeyType_ECDSA_P256:
+		case KeyType_ECDSA_P256, KeyType_RSA2048, KeyType_RSA4096:
 			if req.Derived || req.Convergent {
 				lm.UnlockPolicy(lock, lockType)
 				return nil, nil, false, fmt.Errorf("key derivation and convergent encryption not supported for keys of type %v", req.KeyType)
@@ -265,10 +274,11 @@ func (lm *LockManager) getPolicyCommon(req PolicyRequest, lockType bool) (*Polic
 		}
 
 		p = &Policy{
-			Name:       req.Name,
-			Type:       req.KeyType,
-			Derived:    req.Derived,
-			Exportable: req.Exportable,
+			Name:             req.Name,
+			Type:             req.KeyType,
+			Derived:          req.Derived,
+			Exportable:       req.Exportable,
+			AutoRotatePeriod: req.AutoRotatePeriod,
 		}
 		if req.Derived {
 			p.KDF = Kdf_hkdf_sha256
@@ -377,6 +387,146 @@ func (lm *LockManager) DeletePolicy(storage logical.Storage, name string) error
 	return nil
 }
 
+// ImportPolicy creates a new policy using the given key material rather than
+// generating it. It fails if a policy with the requested name already exists.
+func (lm *LockManager) ImportPolicy(req PolicyRequest, key []byte) error {
+	if req.Derived || req.Convergent {
+		return errutil.UserError{Err: "key derivation and convergent encryption are not supported for imported keys"}
+	}
+
+	lm.cacheMutex.Lock()
+	lock := lm.policyLock(req.Name, exclusive)
+	defer lock.Unlock()
+	defer lm.cacheMutex.Unlock()
+
+	var p *Policy
+	var err error
+
+	if lm.CacheActive() {
+		p = lm.cache[req.Name]
+	}
+	if p == nil {
+		p, err = lm.getStoredPolicy(req.Storage, req.Name)
+		if err != nil {
+			return err
+		}
+	}
+	if p != nil {
+		return errutil.UserError{Err: fmt.Sprintf("key %s already exists", req.Name)}
+	}
+
+	p = &Policy{
+		Name:                     req.Name,
+		Type:                     req.KeyType,
+		Exportable:               req.Exportable,
+		AllowImportedKeyRotation: req.AllowImportedKeyRotation,
+	}
+
+	err = p.Import(req.Storage, key)
+	if err != nil {
+		return err
+	}
+
+	if lm.CacheActive() {
+		lm.cache[req.Name] = p
+	}
+
+	return nil
+}
+
+// BackupPolicy returns a JSON-encoded KeyData containing the named policy and
+// all of its archived key versions. Only exportable keys can be backed up.
+func (lm *LockManager) BackupPolicy(storage logical.Storage, name string) ([]byte, error) {
+	p, lock, err := lm.GetPolicyShared(storage, name)
+	if lock != nil {
+		defer lock.RUnlock()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, errutil.UserError{Err: fmt.Sprintf("key %s not found", name)}
+	}
+
+	if !p.Exportable {
+		return nil, errutil.UserError{Err: "backup is not allowed for keys that are not exportable"}
+	}
+
+	archive, err := p.LoadArchive(storage)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(&KeyData{
+		Policy:       p,
+		ArchivedKeys: archive,
+	})
+}
+
+// RestorePolicy stores the policy and archive contained in a backup created
+// by BackupPolicy. If name is empty, the name of the backed up policy is
+// used. An existing policy is only overwritten if force is set.
+func (lm *LockManager) RestorePolicy(storage logical.Storage, name string, backup []byte, force bool) error {
+	keyData := KeyData{
+		Policy: &Policy{
+			Keys: keyEntryMap{},
+		},
+	}
+	if err := jsonutil.DecodeJSON(backup, &keyData); err != nil {
+		return errutil.UserError{Err: fmt.Sprintf("failed to decode backup: %v", err)}
+	}
+	if keyData.Policy == nil || keyData.Policy.LatestVersion == 0 || keyData.ArchivedKeys == nil {
+		return errutil.UserError{Err: "backup does not contain a key"}
+	}
+	if name == "" {
+		name = keyData.Policy.Name
+	}
+	if name == "" {
+		return errutil.UserError{Err: "no name given for the restored key"}
+	}
+	keyData.Policy.Name = name
+
+	lm.cacheMutex.Lock()
+	lock := lm.policyLock(name, exclusive)
+	defer lock.Unlock()
+	defer lm.cacheMutex.Unlock()
+
+	if !force {
+		var p *Policy
+		var err error
+		if lm.CacheActive() {
+			p = lm.cache[name]
+		}
+		if p == nil {
+			p, err = lm.getStoredPolicy(storage, name)
+			if err != nil {
+				return err
+			}
+		}
+		if p != nil {
+			return errutil.UserError{Err: fmt.Sprintf("key %s already exists; set force to overwrite it", name)}
+		}
+	}
+
+	// Write the archive first so that persisting the policy validates it
+	// against the restored key versions
+	err := keyData.Policy.storeArchive(keyData.ArchivedKeys, storage)
+	if err != nil {
+		return err
+	}
+
+	err = keyData.Policy.Persist(storage)
+	if err != nil {
+		return err
+	}
+
+	if lm.CacheActive() {
+		lm.cache[name] = keyData.Policy
+	}
+
+	return nil
+}
+
 func (lm *LockManager) getStoredPolicy(storage logical.Storage, name string) (*Policy, error) {
 	// Check if the policy already exists
 	raw, err := storage.Get("policy/" + name)
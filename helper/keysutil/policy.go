@@ -9,6 +9,7 @@ import (
 	"crypto/elliptic"
 	"crypto/hmac"
 	"crypto/rand"
+	"crypto/rsa"
 	"crypto/sha256"
 	"crypto/x509"
 	"encoding/asn1"
@@ -44,6 +45,14 @@ const (
 	KeyType_AES256_GCM96 = iota
 	KeyType_ECDSA_P256
 	KeyType_ED25519
+	KeyType_RSA2048
+	KeyType_RSA4096
+)
+
+// The signature algorithms available for RSA keys
+const (
+	SignatureAlgorithm_RSA_PSS      = "pss"
+	SignatureAlgorithm_RSA_PKCS1v15 = "pkcs1v15"
 )
 
 const ErrTooOld = "ciphertext or signature version is disallowed by policy (too old)"
@@ -61,7 +70,7 @@ type KeyType int
 
 func (kt KeyType) EncryptionSupported() bool {
 	switch kt {
-	case KeyType_AES256_GCM96:
+	case KeyType_AES256_GCM96, KeyType_RSA2048, KeyType_RSA4096:
 		return true
 	}
 	return false
@@ -69,7 +78,7 @@ func (kt KeyType) EncryptionSupported() bool {
 
 func (kt KeyType) DecryptionSupported() bool {
 	switch kt {
-	case KeyType_AES256_GCM96:
+	case KeyType_AES256_GCM96, KeyType_RSA2048, KeyType_RSA4096:
 		return true
 	}
 	return false
@@ -77,7 +86,7 @@ func (kt KeyType) DecryptionSupported() bool {
 
 func (kt KeyType) SigningSupported() bool {
 	switch kt {
-	case KeyType_ECDSA_P256, KeyType_ED25519:
+	case KeyType_ECDSA_P256, KeyType_ED25519, KeyType_RSA2048, KeyType_RSA4096:
 		return true
 	}
 	return false
@@ -85,7 +94,7 @@ func (kt KeyType) SigningSupported() bool {
 
 func (kt KeyType) HashSignatureInput() bool {
 	switch kt {
-	case KeyType_ECDSA_P256:
+	case KeyType_ECDSA_P256, KeyType_RSA2048, KeyType_RSA4096:
 		return true
 	}
 	return false
@@ -107,11 +116,41 @@ func (kt KeyType) String() string {
 		return "ecdsa-p256"
 	case KeyType_ED25519:
 		return "ed25519"
+	case KeyType_RSA2048:
+		return "rsa-2048"
+	case KeyType_RSA4096:
+		return "rsa-4096"
 	}
 
 	return "[unknown]"
 }
 
+func (kt KeyType) rsaKeyBits() int {
+	switch kt {
+	case KeyType_RSA2048:
+		return 2048
+	case KeyType_RSA4096:
+		return 4096
+	}
+	return 0
+}
+
+// rsaHash returns the hash function matching the given transit hash algorithm
+// name, which is used when producing and verifying RSA signatures
+func rsaHash(algorithm string) (crypto.Hash, error) {
+	switch algorithm {
+	case "sha2-224":
+		return crypto.SHA224, nil
+	case "sha2-256":
+		return crypto.SHA256, nil
+	case "sha2-384":
+		return crypto.SHA384, nil
+	case "sha2-512":
+		return crypto.SHA512, nil
+	}
+	return 0, errutil.UserError{Err: fmt.Sprintf("unsupported hash algorithm %s", algorithm)}
+}
+
 // KeyEntry stores the key and metadata
 type KeyEntry struct {
 	// AES or some other kind that is a pure byte slice like ED25519
@@ -127,6 +166,8 @@ type KeyEntry struct {
 	EC_Y *big.Int `json:"ec_y"`
 	EC_D *big.Int `json:"ec_d"`
 
+	RSAKey *rsa.PrivateKey `json:"rsa_key"`
+
 	// The public key in an appropriate format for the type of key
 	FormattedPublicKey string `json:"public_key"`
 
@@ -201,6 +242,29 @@ type Policy struct {
 
 	// The type of key
 	Type KeyType `json:"type"`
+
+	// Whether the key material was imported rather than generated by Vault
+	Imported bool `json:"imported"`
+
+	// Whether an imported key may be rotated, which creates a new key version
+	// generated by Vault
+	AllowImportedKeyRotation bool `json:"allow_imported_key_rotation"`
+
+	// The minimum version of the key that still exists. Versions below this
+	// have been trimmed and are permanently deleted, including from the
+	// archive.
+	MinAvailableVersion int `json:"min_available_version"`
+
+	// How often the key should be automatically rotated; zero disables
+	// automatic rotation
+	AutoRotatePeriod time.Duration `json:"auto_rotate_period"`
+}
+
+// KeyData holds a policy along with its archived key versions; it is the
+// unit that is backed up and restored
+type KeyData struct {
+	Policy       *Policy       `json:"policy"`
+	ArchivedKeys *archivedKeys `json:"archived_keys"`
 }
 
 // ArchivedKeys stores old keys. This is used to keep the key loading time sane
@@ -255,7 +319,9 @@ func (p *Policy) handleArchiving(storage logical.Storage) error {
 	// that now need to be accessible back here.
 	//
 	// For safety, because there isn't really a good reason to, we never delete
-	// keys from the archive even when we move them back.
+	// keys from the archive even when we move them back. The only exception is
+	// versions below the minimum available version, which have been
+	// explicitly trimmed.
 
 	// Check if we have the latest minimum version in the current set of keys
 	_, keysContainsMinimum := p.Keys[p.MinDecryptionVersion]
@@ -277,6 +343,9 @@ func (p *Policy) handleArchiving(storage logical.Storage) error {
 	case p.MinDecryptionVersion > p.LatestVersion:
 		return fmt.Errorf("minimum decryption version of %d is greater than the latest version %d",
 			p.MinDecryptionVersion, p.LatestVersion)
+	case p.MinAvailableVersion > p.MinDecryptionVersion:
+		return fmt.Errorf("minimum available version of %d is greater than minimum decryption version %d",
+			p.MinAvailableVersion, p.MinDecryptionVersion)
 	}
 
 	archive, err := p.LoadArchive(storage)
@@ -313,6 +382,11 @@ func (p *Policy) handleArchiving(storage logical.Storage) error {
 		p.ArchiveVersion = i
 	}
 
+	// Scrub any trimmed versions from the archive
+	for i := 0; i < p.MinAvailableVersion; i++ {
+		archive.Keys[i] = KeyEntry{}
+	}
+
 	err = p.storeArchive(archive, storage)
 	if err != nil {
 		return err
@@ -521,7 +595,7 @@ func (p *Policy) Encrypt(ver int, context, nonce []byte, value string) (string,
 
 	// Guard against a potentially invalid key type
 	switch p.Type {
-	case KeyType_AES256_GCM96:
+	case KeyType_AES256_GCM96, KeyType_RSA2048, KeyType_RSA4096:
 	default:
 		return "", errutil.InternalError{Err: fmt.Sprintf("unsupported key type %v", p.Type)}
 	}
@@ -543,36 +617,52 @@ func (p *Policy) Encrypt(ver int, context, nonce []byte, value string) (string,
 		return "", errutil.UserError{Err: "requested version for encryption is less than the minimum encryption key version"}
 	}
 
-	// Derive the key that should be used
-	key, err := p.DeriveKey(context, ver)
-	if err != nil {
-		return "", err
-	}
-
-	// Guard against a potentially invalid key type
+	var full []byte
 	switch p.Type {
 	case KeyType_AES256_GCM96:
+		full, err = p.encryptAES(ver, context, nonce, plaintext)
+	case KeyType_RSA2048, KeyType_RSA4096:
+		full, err = p.encryptRSA(ver, plaintext)
 	default:
 		return "", errutil.InternalError{Err: fmt.Sprintf("unsupported key type %v", p.Type)}
 	}
+	if err != nil {
+		return "", err
+	}
+
+	// Convert to base64
+	encoded := base64.StdEncoding.EncodeToString(full)
+
+	// Prepend some information
+	encoded = "vault:v" + strconv.Itoa(ver) + ":" + encoded
+
+	return encoded, nil
+}
+
+func (p *Policy) encryptAES(ver int, context, nonce, plaintext []byte) ([]byte, error) {
+	// Derive the key that should be used
+	key, err := p.DeriveKey(context, ver)
+	if err != nil {
+		return nil, err
+	}
 
 	// Setup the cipher
 	aesCipher, err := aes.NewCipher(key)
 	if err != nil {
-		return "", errutil.InternalError{Err: err.Error()}
+		return nil, errutil.InternalError{Err: err.Error()}
 	}
 
 	// Setup the GCM AEAD
 	gcm, err := cipher.NewGCM(aesCipher)
 	if err != nil {
-		return "", errutil.InternalError{Err: err.Error()}
+		return nil, errutil.InternalError{Err: err.Error()}
 	}
 
 	if p.ConvergentEncryption {
 		switch p.ConvergentVersion {
 		case 1:
 			if len(nonce) != gcm.NonceSize() {
-				return "", errutil.UserError{Err: fmt.Sprintf("base64-decoded nonce must be %d bytes long when using convergent encryption with this key", gcm.NonceSize())}
+				return nil, errutil.UserError{Err: fmt.Sprintf("base64-decoded nonce must be %d bytes long when using convergent encryption with this key", gcm.NonceSize())}
 			}
 		default:
 			nonceHmac := hmac.New(sha256.New, context)
@@ -584,7 +674,7 @@ func (p *Policy) Encrypt(ver int, context, nonce []byte, value string) (string,
 		// Compute random nonce
 		nonce, err = uuid.GenerateRandomBytes(gcm.NonceSize())
 		if err != nil {
-			return "", errutil.InternalError{Err: err.Error()}
+			return nil, errutil.InternalError{Err: err.Error()}
 		}
 	}
 
@@ -597,13 +687,21 @@ func (p *Policy) Encrypt(ver int, context, nonce []byte, value string) (string,
 		full = append(nonce, out...)
 	}
 
-	// Convert to base64
-	encoded := base64.StdEncoding.EncodeToString(full)
+	return full, nil
+}
 
-	// Prepend some information
-	encoded = "vault:v" + strconv.Itoa(ver) + ":" + encoded
+func (p *Policy) encryptRSA(ver int, plaintext []byte) ([]byte, error) {
+	key := p.Keys[ver].RSAKey
+	if key == nil {
+		return nil, errutil.InternalError{Err: "RSA key not found for the given version"}
+	}
 
-	return encoded, nil
+	ciphertext, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, &key.PublicKey, plaintext, nil)
+	if err != nil {
+		return nil, errutil.UserError{Err: fmt.Sprintf("failed to RSA encrypt the plaintext: %v", err)}
+	}
+
+	return ciphertext, nil
 }
 
 func (p *Policy) Decrypt(context, nonce []byte, value string) (string, error) {
@@ -644,35 +742,45 @@ func (p *Policy) Decrypt(context, nonce []byte, value string) (string, error) {
 		return "", errutil.UserError{Err: ErrTooOld}
 	}
 
-	// Derive the key that should be used
-	key, err := p.DeriveKey(context, ver)
+	// Decode the base64
+	decoded, err := base64.StdEncoding.DecodeString(splitVerCiphertext[1])
 	if err != nil {
-		return "", err
+		return "", errutil.UserError{Err: "invalid ciphertext: could not decode base64"}
 	}
 
-	// Guard against a potentially invalid key type
+	var plain []byte
 	switch p.Type {
 	case KeyType_AES256_GCM96:
+		plain, err = p.decryptAES(ver, context, nonce, decoded)
+	case KeyType_RSA2048, KeyType_RSA4096:
+		plain, err = p.decryptRSA(ver, decoded)
 	default:
 		return "", errutil.InternalError{Err: fmt.Sprintf("unsupported key type %v", p.Type)}
 	}
+	if err != nil {
+		return "", err
+	}
 
-	// Decode the base64
-	decoded, err := base64.StdEncoding.DecodeString(splitVerCiphertext[1])
+	return base64.StdEncoding.EncodeToString(plain), nil
+}
+
+func (p *Policy) decryptAES(ver int, context, nonce, decoded []byte) ([]byte, error) {
+	// Derive the key that should be used
+	key, err := p.DeriveKey(context, ver)
 	if err != nil {
-		return "", errutil.UserError{Err: "invalid ciphertext: could not decode base64"}
+		return nil, err
 	}
 
 	// Setup the cipher
 	aesCipher, err := aes.NewCipher(key)
 	if err != nil {
-		return "", errutil.InternalError{Err: err.Error()}
+		return nil, errutil.InternalError{Err: err.Error()}
 	}
 
 	// Setup the GCM AEAD
 	gcm, err := cipher.NewGCM(aesCipher)
 	if err != nil {
-		return "", errutil.InternalError{Err: err.Error()}
+		return nil, errutil.InternalError{Err: err.Error()}
 	}
 
 	// Extract the nonce and ciphertext
@@ -680,6 +788,9 @@ func (p *Policy) Decrypt(context, nonce []byte, value string) (string, error) {
 	if p.ConvergentEncryption && p.ConvergentVersion < 2 {
 		ciphertext = decoded
 	} else {
+		if len(decoded) < gcm.NonceSize() {
+			return nil, errutil.UserError{Err: "invalid ciphertext: too short"}
+		}
 		nonce = decoded[:gcm.NonceSize()]
 		ciphertext = decoded[gcm.NonceSize():]
 	}
@@ -687,10 +798,24 @@ func (p *Policy) Decrypt(context, nonce []byte, value string) (string, error) {
 	// Verify and Decrypt
 	plain, err := gcm.Open(nil, nonce, ciphertext, nil)
 	if err != nil {
-		return "", errutil.UserError{Err: "invalid ciphertext: unable to decrypt"}
+		return nil, errutil.UserError{Err: "invalid ciphertext: unable to decrypt"}
 	}
 
-	return base64.StdEncoding.EncodeToString(plain), nil
+	return plain, nil
+}
+
+func (p *Policy) decryptRSA(ver int, decoded []byte) ([]byte, error) {
+	key := p.Keys[ver].RSAKey
+	if key == nil {
+		return nil, errutil.InternalError{Err: "RSA key not found for the given version"}
+	}
+
+	plain, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, key, decoded, nil)
+	if err != nil {
+		return nil, errutil.UserError{Err: "invalid ciphertext: unable to decrypt"}
+	}
+
+	return plain, nil
 }
 
 func (p *Policy) HMACKey(version int) ([]byte, error) {
@@ -708,7 +833,10 @@ func (p *Policy) HMACKey(version int) ([]byte, error) {
 	return p.Keys[version].HMACKey, nil
 }
 
-func (p *Policy) Sign(ver int, context, input []byte) (*SigningResult, error) {
+// Sign signs the given input with the requested key version. For RSA keys the
+// input must already be hashed with hashAlgorithm, and sigAlgorithm selects
+// between PSS and PKCS#1v15 signatures; both are ignored for other key types.
+func (p *Policy) Sign(ver int, context, input []byte, hashAlgorithm, sigAlgorithm string) (*SigningResult, error) {
 	if !p.Type.SigningSupported() {
 		return nil, fmt.Errorf("message signing not supported for key type %v", p.Type)
 	}
@@ -773,6 +901,29 @@ func (p *Policy) Sign(ver int, context, input []byte) (*SigningResult, error) {
 			return nil, err
 		}
 
+	case KeyType_RSA2048, KeyType_RSA4096:
+		key := p.Keys[ver].RSAKey
+		if key == nil {
+			return nil, errutil.InternalError{Err: "RSA key not found for the given version"}
+		}
+
+		hashType, err := rsaHash(hashAlgorithm)
+		if err != nil {
+			return nil, err
+		}
+
+		switch sigAlgorithm {
+		case SignatureAlgorithm_RSA_PSS, "":
+			sig, err = rsa.SignPSS(rand.Reader, key, hashType, input, nil)
+		case SignatureAlgorithm_RSA_PKCS1v15:
+			sig, err = rsa.SignPKCS1v15(rand.Reader, key, hashType, input)
+		default:
+			return nil, errutil.UserError{Err: fmt.Sprintf("unsupported signature algorithm %s", sigAlgorithm)}
+		}
+		if err != nil {
+			return nil, err
+		}
+
 	default:
 		return nil, fmt.Errorf("unsupported key type %v", p.Type)
 	}
@@ -788,7 +939,9 @@ func (p *Policy) Sign(ver int, context, input []byte) (*SigningResult, error) {
 	return res, nil
 }
 
-func (p *Policy) VerifySignature(context, input []byte, sig string) (bool, error) {
+// VerifySignature verifies a signature produced by Sign. hashAlgorithm and
+// sigAlgorithm must match the values used when signing with an RSA key.
+func (p *Policy) VerifySignature(context, input []byte, sig, hashAlgorithm, sigAlgorithm string) (bool, error) {
 	if !p.Type.SigningSupported() {
 		return false, errutil.UserError{Err: fmt.Sprintf("message verification not supported for key type %v", p.Type)}
 	}
@@ -857,6 +1010,28 @@ func (p *Policy) VerifySignature(context, input []byte, sig string) (bool, error
 
 		return ed25519.Verify(key.Public().(ed25519.PublicKey), input, sigBytes), nil
 
+	case KeyType_RSA2048, KeyType_RSA4096:
+		key := p.Keys[ver].RSAKey
+		if key == nil {
+			return false, errutil.InternalError{Err: "RSA key not found for the given version"}
+		}
+
+		hashType, err := rsaHash(hashAlgorithm)
+		if err != nil {
+			return false, err
+		}
+
+		switch sigAlgorithm {
+		case SignatureAlgorithm_RSA_PSS, "":
+			err = rsa.VerifyPSS(&key.PublicKey, hashType, input, sigBytes, nil)
+		case SignatureAlgorithm_RSA_PKCS1v15:
+			err = rsa.VerifyPKCS1v15(&key.PublicKey, hashType, input, sigBytes)
+		default:
+			return false, errutil.UserError{Err: fmt.Sprintf("unsupported signature algorithm %s", sigAlgorithm)}
+		}
+
+		return err == nil, nil
+
 	default:
 		return false, errutil.InternalError{Err: fmt.Sprintf("unsupported key type %v", p.Type)}
 	}
@@ -865,6 +1040,10 @@ func (p *Policy) VerifySignature(context, input []byte, sig string) (bool, error
 }
 
 func (p *Policy) Rotate(storage logical.Storage) error {
+	if p.Imported && !p.AllowImportedKeyRotation {
+		return errutil.UserError{Err: "imported key does not allow rotation"}
+	}
+
 	if p.Keys == nil {
 		// This is an initial key rotation when generating a new policy. We
 		// don't need to call migrate here because if we've called getPolicy to
@@ -902,19 +1081,11 @@ func (p *Policy) Rotate(storage logical.Storage) error {
 		entry.EC_D = privKey.D
 		entry.EC_X = privKey.X
 		entry.EC_Y = privKey.Y
-		derBytes, err := x509.MarshalPKIXPublicKey(privKey.Public())
+		pemBytes, err := encodePublicKeyPEM(privKey.Public())
 		if err != nil {
-			return fmt.Errorf("error marshaling public key: %s", err)
-		}
-		pemBlock := &pem.Block{
-			Type:  "PUBLIC KEY",
-			Bytes: derBytes,
-		}
-		pemBytes := pem.EncodeToMemory(pemBlock)
-		if pemBytes == nil || len(pemBytes) == 0 {
-			return fmt.Errorf("error PEM-encoding public key")
+			return err
 		}
-		entry.FormattedPublicKey = string(pemBytes)
+		entry.FormattedPublicKey = pemBytes
 
 	case KeyType_ED25519:
 		pub, pri, err := ed25519.GenerateKey(rand.Reader)
@@ -923,6 +1094,18 @@ func (p *Policy) Rotate(storage logical.Storage) error {
 		}
 		entry.Key = pri
 		entry.FormattedPublicKey = base64.StdEncoding.EncodeToString(pub)
+
+	case KeyType_RSA2048, KeyType_RSA4096:
+		privKey, err := rsa.GenerateKey(rand.Reader, p.Type.rsaKeyBits())
+		if err != nil {
+			return err
+		}
+		entry.RSAKey = privKey
+		pemBytes, err := encodePublicKeyPEM(privKey.Public())
+		if err != nil {
+			return err
+		}
+		entry.FormattedPublicKey = pemBytes
 	}
 
 	p.Keys[p.LatestVersion] = entry
@@ -937,6 +1120,161 @@ func (p *Policy) Rotate(storage logical.Storage) error {
 	return p.Persist(storage)
 }
 
+// NeedsAutoRotation returns whether the key has an automatic rotation period
+// set and the latest version is at least that old
+func (p *Policy) NeedsAutoRotation(now time.Time) bool {
+	if p.AutoRotatePeriod <= 0 {
+		return false
+	}
+	if p.Imported && !p.AllowImportedKeyRotation {
+		return false
+	}
+
+	latest, ok := p.Keys[p.LatestVersion]
+	if !ok {
+		return false
+	}
+	created := latest.CreationTime
+	if created.IsZero() {
+		created = time.Unix(latest.DeprecatedCreationTime, 0)
+	}
+
+	return !now.Before(created.Add(p.AutoRotatePeriod))
+}
+
+// Trim permanently deletes all key versions below minAvailableVersion, both
+// from the policy and from the archive. Trimmed versions can no longer be
+// used for any operation, so the minimum decryption and encryption versions
+// must already be at or above the new minimum.
+func (p *Policy) Trim(storage logical.Storage, minAvailableVersion int) error {
+	switch {
+	case minAvailableVersion < 1:
+		return errutil.UserError{Err: "minimum available version must be at least 1"}
+	case minAvailableVersion < p.MinAvailableVersion:
+		return errutil.UserError{Err: fmt.Sprintf("minimum available version cannot be decreased; versions below %d have already been trimmed", p.MinAvailableVersion)}
+	case minAvailableVersion > p.MinDecryptionVersion:
+		return errutil.UserError{Err: fmt.Sprintf("minimum available version of %d cannot be greater than minimum decryption version %d", minAvailableVersion, p.MinDecryptionVersion)}
+	case p.MinEncryptionVersion > 0 && minAvailableVersion > p.MinEncryptionVersion:
+		return errutil.UserError{Err: fmt.Sprintf("minimum available version of %d cannot be greater than minimum encryption version %d", minAvailableVersion, p.MinEncryptionVersion)}
+	}
+
+	p.MinAvailableVersion = minAvailableVersion
+	for i := range p.Keys {
+		if i < minAvailableVersion {
+			delete(p.Keys, i)
+		}
+	}
+
+	// Persist removes the trimmed versions from the archive
+	return p.Persist(storage)
+}
+
+// Import adds the given key material as the first version of a new policy.
+// Symmetric keys are given as raw bytes, asymmetric keys as a DER-encoded
+// PKCS#8 private key.
+func (p *Policy) Import(storage logical.Storage, key []byte) error {
+	if p.LatestVersion != 0 || len(p.Keys) != 0 {
+		return errutil.UserError{Err: "key material can only be imported into a new key"}
+	}
+
+	now := time.Now()
+	entry := KeyEntry{
+		CreationTime:           now,
+		DeprecatedCreationTime: now.Unix(),
+	}
+
+	hmacKey, err := uuid.GenerateRandomBytes(32)
+	if err != nil {
+		return err
+	}
+	entry.HMACKey = hmacKey
+
+	switch p.Type {
+	case KeyType_AES256_GCM96:
+		if len(key) != 32 {
+			return errutil.UserError{Err: fmt.Sprintf("invalid key size %d bytes for key type %v", len(key), p.Type)}
+		}
+		entry.Key = key
+
+	case KeyType_ECDSA_P256, KeyType_ED25519, KeyType_RSA2048, KeyType_RSA4096:
+		parsedKey, err := x509.ParsePKCS8PrivateKey(key)
+		if err != nil {
+			return errutil.UserError{Err: fmt.Sprintf("error parsing asymmetric key: %v", err)}
+		}
+
+		switch p.Type {
+		case KeyType_ECDSA_P256:
+			ecdsaKey, ok := parsedKey.(*ecdsa.PrivateKey)
+			if !ok || ecdsaKey.Curve != elliptic.P256() {
+				return errutil.UserError{Err: fmt.Sprintf("invalid key material for key type %v", p.Type)}
+			}
+			entry.EC_D = ecdsaKey.D
+			entry.EC_X = ecdsaKey.X
+			entry.EC_Y = ecdsaKey.Y
+			entry.FormattedPublicKey, err = encodePublicKeyPEM(ecdsaKey.Public())
+			if err != nil {
+				return err
+			}
+
+		case KeyType_ED25519:
+			seeded, ok := parsedKey.(interface {
+				Seed() []byte
+			})
+			if !ok {
+				return errutil.UserError{Err: fmt.Sprintf("invalid key material for key type %v", p.Type)}
+			}
+			// Regenerate the key from its seed, using the seed as the "random"
+			// input to the generation function
+			pub, pri, err := ed25519.GenerateKey(bytes.NewReader(seeded.Seed()))
+			if err != nil {
+				return errutil.UserError{Err: fmt.Sprintf("invalid key material for key type %v: %v", p.Type, err)}
+			}
+			entry.Key = pri
+			entry.FormattedPublicKey = base64.StdEncoding.EncodeToString(pub)
+
+		case KeyType_RSA2048, KeyType_RSA4096:
+			rsaKey, ok := parsedKey.(*rsa.PrivateKey)
+			if !ok || rsaKey.N.BitLen() != p.Type.rsaKeyBits() {
+				return errutil.UserError{Err: fmt.Sprintf("invalid key material for key type %v", p.Type)}
+			}
+			entry.RSAKey = rsaKey
+			entry.FormattedPublicKey, err = encodePublicKeyPEM(rsaKey.Public())
+			if err != nil {
+				return err
+			}
+		}
+
+	default:
+		return errutil.UserError{Err: fmt.Sprintf("import not supported for key type %v", p.Type)}
+	}
+
+	p.Keys = keyEntryMap{
+		1: entry,
+	}
+	p.LatestVersion = 1
+	p.MinDecryptionVersion = 1
+	p.Imported = true
+
+	return p.Persist(storage)
+}
+
+// encodePublicKeyPEM returns the PKIX, PEM-encoded form of the given public key
+func encodePublicKeyPEM(pub crypto.PublicKey) (string, error) {
+	derBytes, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling public key: %s", err)
+	}
+	pemBlock := &pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: derBytes,
+	}
+	pemBytes := pem.EncodeToMemory(pemBlock)
+	if pemBytes == nil || len(pemBytes) == 0 {
+		return "", fmt.Errorf("error PEM-encoding public key")
+	}
+	return string(pemBytes), nil
+}
+
 func (p *Policy) MigrateKeyToKeysMap() {
 	now := time.Now()
 	p.Keys = keyEntryMap{
@@ -1,6 +1,7 @@
 package keysutil
 
 import (
+	"crypto/sha256"
 	"reflect"
 	"testing"
 
@@ -349,3 +350,58 @@ func checkKeys(t *testing.T,
 		}
 	}
 }
+
+func Test_RSAKeyPersistence(t *testing.T) {
+	storage := &logical.InmemStorage{}
+	// Use a disabled cache so the policy is always read back from storage
+	lm := NewLockManager(true)
+	p, lock, _, err := lm.GetPolicyUpsert(PolicyRequest{
+		Storage: storage,
+		KeyType: KeyType_RSA2048,
+		Name:    "test",
+	})
+	if lock != nil {
+		lock.RUnlock()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p == nil {
+		t.Fatal("nil policy")
+	}
+
+	plaintext := "dGhlIHF1aWNrIGJyb3duIGZveA=="
+	ciphertext, err := p.Encrypt(0, nil, nil, plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := sha256.Sum256([]byte("the quick brown fox"))
+	sig, err := p.Sign(0, nil, input[:], "sha2-256", SignatureAlgorithm_RSA_PKCS1v15)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, lock, err = lm.GetPolicyShared(storage, "test")
+	if lock != nil {
+		defer lock.RUnlock()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decrypted, err := p.Decrypt(nil, nil, ciphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decrypted != plaintext {
+		t.Fatalf("bad: expected %q, got %q", plaintext, decrypted)
+	}
+
+	valid, err := p.VerifySignature(nil, input[:], sig.Signature, "sha2-256", SignatureAlgorithm_RSA_PKCS1v15)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !valid {
+		t.Fatal("signature did not verify after loading the key from storage")
+	}
+}
@@ -0,0 +1,138 @@
+// Package ldaputil holds the LDAP connection settings and dialing logic shared
+// by the backends talking to LDAP servers.
+package ldaputil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/go-ldap/ldap"
+	multierror "github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/vault/helper/tlsutil"
+	log "github.com/mgutz/logxi/v1"
+)
+
+// DialConfig holds the settings used to connect to LDAP servers
+type DialConfig struct {
+	// URL is a comma-separated list of LDAP URLs, tried in order
+	URL string
+
+	// Certificate is the PEM encoded CA certificate used to verify the
+	// server certificate, if any
+	Certificate string
+
+	InsecureTLS   bool
+	StartTLS      bool
+	TLSMinVersion string
+	TLSMaxVersion string
+
+	// Logger, if set, receives the errors for the URLs that failed before
+	// one succeeded
+	Logger log.Logger
+}
+
+// TLSConfig returns the TLS configuration for connecting to the given host
+func (c *DialConfig) TLSConfig(host string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName: host,
+	}
+
+	if c.TLSMinVersion != "" {
+		tlsMinVersion, ok := tlsutil.TLSLookup[c.TLSMinVersion]
+		if !ok {
+			return nil, fmt.Errorf("invalid 'tls_min_version' in config")
+		}
+		tlsConfig.MinVersion = tlsMinVersion
+	}
+
+	if c.TLSMaxVersion != "" {
+		tlsMaxVersion, ok := tlsutil.TLSLookup[c.TLSMaxVersion]
+		if !ok {
+			return nil, fmt.Errorf("invalid 'tls_max_version' in config")
+		}
+		tlsConfig.MaxVersion = tlsMaxVersion
+	}
+
+	if c.InsecureTLS {
+		tlsConfig.InsecureSkipVerify = true
+	}
+	if c.Certificate != "" {
+		caPool := x509.NewCertPool()
+		ok := caPool.AppendCertsFromPEM([]byte(c.Certificate))
+		if !ok {
+			return nil, fmt.Errorf("could not append CA certificate")
+		}
+		tlsConfig.RootCAs = caPool
+	}
+	return tlsConfig, nil
+}
+
+// Dial connects to the first of the configured URLs that accepts the
+// connection
+func (c *DialConfig) Dial() (*ldap.Conn, error) {
+	var retErr *multierror.Error
+	var conn *ldap.Conn
+	urls := strings.Split(c.URL, ",")
+	for _, uut := range urls {
+		u, err := url.Parse(uut)
+		if err != nil {
+			retErr = multierror.Append(retErr, fmt.Errorf("error parsing url %q: %s", uut, err.Error()))
+			continue
+		}
+		host, port, err := net.SplitHostPort(u.Host)
+		if err != nil {
+			host = u.Host
+		}
+
+		var tlsConfig *tls.Config
+		switch u.Scheme {
+		case "ldap":
+			if port == "" {
+				port = "389"
+			}
+			conn, err = ldap.Dial("tcp", net.JoinHostPort(host, port))
+			if err != nil {
+				break
+			}
+			if conn == nil {
+				err = fmt.Errorf("empty connection after dialing")
+				break
+			}
+			if c.StartTLS {
+				tlsConfig, err = c.TLSConfig(host)
+				if err != nil {
+					break
+				}
+				err = conn.StartTLS(tlsConfig)
+			}
+		case "ldaps":
+			if port == "" {
+				port = "636"
+			}
+			tlsConfig, err = c.TLSConfig(host)
+			if err != nil {
+				break
+			}
+			conn, err = ldap.DialTLS("tcp", net.JoinHostPort(host, port), tlsConfig)
+		default:
+			retErr = multierror.Append(retErr, fmt.Errorf("invalid LDAP scheme in url %q", net.JoinHostPort(host, port)))
+			continue
+		}
+		if err == nil {
+			if retErr != nil && c.Logger != nil {
+				if c.Logger.IsDebug() {
+					c.Logger.Debug("ldap: errors connecting to some hosts: %s", retErr.Error())
+				}
+			}
+			retErr = nil
+			break
+		}
+		retErr = multierror.Append(retErr, fmt.Errorf("error connecting to host %q: %s", uut, err.Error()))
+	}
+
+	return conn, retErr.ErrorOrNil()
+}
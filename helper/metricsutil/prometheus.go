@@ -0,0 +1,272 @@
+package metricsutil
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PrometheusContentType is the content type of the text exposition format
+const PrometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are the upper bounds of the histogram buckets samples are
+// counted in. Samples are timings in milliseconds.
+var DefaultBuckets = []float64{0.5, 1, 2.5, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Label returns a key part carrying a label. PrometheusSink exports it as a
+// label of the metric, while other sinks keep it as a part of the name, such
+// as "mount=secret". Since the other sinks join the parts of the key with
+// dots, the value is stripped of slashes at either end, and characters other
+// than letters, digits, underscores and dashes are replaced by underscores.
+func Label(name, value string) string {
+	value = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+			return r
+		}
+		return '_'
+	}, strings.Trim(value, "/"))
+	return name + "=" + value
+}
+
+type metricKind int
+
+const (
+	kindGauge metricKind = iota
+	kindCounter
+	kindHistogram
+)
+
+func (k metricKind) String() string {
+	switch k {
+	case kindCounter:
+		return "counter"
+	case kindHistogram:
+		return "histogram"
+	}
+	return "gauge"
+}
+
+// series is a metric with a set of labels
+type series struct {
+	kind    metricKind
+	name    string
+	labels  string
+	value   float64
+	buckets []uint64
+	count   uint64
+	updated time.Time
+}
+
+// PrometheusSink is a go-metrics MetricSink that keeps metrics in memory
+// and writes them in the Prometheus text exposition format. Gauges keep
+// their last value, counters accumulate, and samples are counted in
+// histogram buckets. Metrics that are not updated for the retention time
+// are dropped.
+type PrometheusSink struct {
+	retention time.Duration
+	hostname  string
+
+	l      sync.Mutex
+	series map[string]*series
+}
+
+// NewPrometheusSink returns a sink retaining metrics for the given time. The
+// hostname go-metrics inserts into gauge keys is removed, since it does not
+// identify the metric.
+func NewPrometheusSink(retention time.Duration, hostname string) *PrometheusSink {
+	return &PrometheusSink{
+		retention: retention,
+		hostname:  hostname,
+		series:    make(map[string]*series),
+	}
+}
+
+func (s *PrometheusSink) SetGauge(key []string, val float32) {
+	if len(key) > 1 && s.hostname != "" && key[1] == s.hostname {
+		key = append([]string{key[0]}, key[2:]...)
+	}
+	s.update(kindGauge, key, func(m *series) {
+		m.value = float64(val)
+	})
+}
+
+// EmitKey is ignored, since there is no Prometheus type for individual
+// key/value pairs
+func (s *PrometheusSink) EmitKey(key []string, val float32) {}
+
+func (s *PrometheusSink) IncrCounter(key []string, val float32) {
+	s.update(kindCounter, key, func(m *series) {
+		m.value += float64(val)
+	})
+}
+
+func (s *PrometheusSink) AddSample(key []string, val float32) {
+	s.update(kindHistogram, key, func(m *series) {
+		if m.buckets == nil {
+			m.buckets = make([]uint64, len(DefaultBuckets))
+		}
+		for i, bound := range DefaultBuckets {
+			if float64(val) <= bound {
+				m.buckets[i]++
+			}
+		}
+		m.count++
+		m.value += float64(val)
+	})
+}
+
+func (s *PrometheusSink) update(kind metricKind, key []string, f func(*series)) {
+	name, labels := flattenKey(key)
+	id := kind.String() + " " + name + labels
+
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	m, ok := s.series[id]
+	if !ok {
+		m = &series{
+			kind:   kind,
+			name:   name,
+			labels: labels,
+		}
+		s.series[id] = m
+	}
+	f(m)
+	m.updated = time.Now()
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format,
+// sorted by name
+func (s *PrometheusSink) WriteTo(w io.Writer) (int64, error) {
+	s.l.Lock()
+	var all []*series
+	cutoff := time.Now().Add(-s.retention)
+	for id, m := range s.series {
+		if s.retention > 0 && m.updated.Before(cutoff) {
+			delete(s.series, id)
+			continue
+		}
+		copied := *m
+		copied.buckets = append([]uint64(nil), m.buckets...)
+		all = append(all, &copied)
+	}
+	s.l.Unlock()
+
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].name != all[j].name {
+			return all[i].name < all[j].name
+		}
+		if all[i].kind != all[j].kind {
+			return all[i].kind < all[j].kind
+		}
+		return all[i].labels < all[j].labels
+	})
+
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	var family string
+	for _, m := range all {
+		if id := m.kind.String() + " " + m.name; id != family {
+			family = id
+			fmt.Fprintf(cw, "# TYPE %s %s\n", m.name, m.kind)
+		}
+
+		if m.kind != kindHistogram {
+			fmt.Fprintf(cw, "%s%s %s\n", m.name, m.labels, formatFloat(m.value))
+			continue
+		}
+
+		for i, bound := range DefaultBuckets {
+			fmt.Fprintf(cw, "%s_bucket%s %d\n", m.name, withLabel(m.labels, "le", formatFloat(bound)), m.buckets[i])
+		}
+		fmt.Fprintf(cw, "%s_bucket%s %d\n", m.name, withLabel(m.labels, "le", "+Inf"), m.count)
+		fmt.Fprintf(cw, "%s_sum%s %s\n", m.name, m.labels, formatFloat(m.value))
+		fmt.Fprintf(cw, "%s_count%s %d\n", m.name, m.labels, m.count)
+	}
+
+	if cw.err != nil {
+		return cw.n, cw.err
+	}
+	return cw.n, cw.w.Flush()
+}
+
+// flattenKey returns the metric name and the formatted labels of a key.
+// Parts carrying a label are sorted by label name.
+func flattenKey(key []string) (string, string) {
+	var parts, labels []string
+	for _, part := range key {
+		if idx := strings.Index(part, "="); idx > 0 {
+			labels = append(labels, sanitizeName(part[:idx])+`="`+labelValueEscaper.Replace(part[idx+1:])+`"`)
+			continue
+		}
+		parts = append(parts, part)
+	}
+
+	name := sanitizeName(strings.Join(parts, "_"))
+	if len(labels) == 0 {
+		return name, ""
+	}
+	sort.Strings(labels)
+	return name, "{" + strings.Join(labels, ",") + "}"
+}
+
+// sanitizeName replaces the characters that are not allowed in metric and
+// label names with underscores
+func sanitizeName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		}
+		return '_'
+	}, name)
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+	return name
+}
+
+// withLabel adds a label to formatted labels
+func withLabel(labels, name, value string) string {
+	label := name + `="` + value + `"`
+	if labels == "" {
+		return "{" + label + "}"
+	}
+	return labels[:len(labels)-1] + "," + label + "}"
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
@@ -0,0 +1,114 @@
+package metricsutil
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrometheusSink(t *testing.T) {
+	s := NewPrometheusSink(time.Hour, "host1")
+
+	s.SetGauge([]string{"vault", "host1", "core", "unsealed"}, 1)
+	s.SetGauge([]string{"vault", "expire", "leases", Label("mount", "secret/")}, 3)
+	s.SetGauge([]string{"vault", "expire", "leases", Label("mount", `a"b\`)}, 1)
+	s.SetGauge([]string{"vault", "expire", "leases", Label("mount", "secret/")}, 2)
+	s.IncrCounter([]string{"vault", "audit", "log_request_failure"}, 1)
+	s.IncrCounter([]string{"vault", "audit", "log_request_failure"}, 2)
+	s.AddSample([]string{"vault", "barrier", "get"}, 0.7)
+	s.AddSample([]string{"vault", "barrier", "get"}, 30)
+	s.AddSample([]string{"vault", "barrier", "get"}, 20000)
+	s.EmitKey([]string{"vault", "ignored"}, 1)
+
+	var buf bytes.Buffer
+	if _, err := s.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `# TYPE vault_audit_log_request_failure counter
+vault_audit_log_request_failure 3
+# TYPE vault_barrier_get histogram
+vault_barrier_get_bucket{le="0.5"} 0
+vault_barrier_get_bucket{le="1"} 1
+vault_barrier_get_bucket{le="2.5"} 1
+vault_barrier_get_bucket{le="5"} 1
+vault_barrier_get_bucket{le="10"} 1
+vault_barrier_get_bucket{le="25"} 1
+vault_barrier_get_bucket{le="50"} 2
+vault_barrier_get_bucket{le="100"} 2
+vault_barrier_get_bucket{le="250"} 2
+vault_barrier_get_bucket{le="500"} 2
+vault_barrier_get_bucket{le="1000"} 2
+vault_barrier_get_bucket{le="2500"} 2
+vault_barrier_get_bucket{le="5000"} 2
+vault_barrier_get_bucket{le="10000"} 2
+vault_barrier_get_bucket{le="+Inf"} 3
+`
+	if !strings.HasPrefix(buf.String(), expected) {
+		t.Fatalf("bad:\n%s\nexpected prefix:\n%s", buf.String(), expected)
+	}
+
+	for _, line := range []string{
+		"vault_barrier_get_count 3\n",
+		"# TYPE vault_core_unsealed gauge\nvault_core_unsealed 1\n",
+		"# TYPE vault_expire_leases gauge\nvault_expire_leases{mount=\"a_b_\"} 1\nvault_expire_leases{mount=\"secret\"} 2\n",
+	} {
+		if !strings.Contains(buf.String(), line) {
+			t.Fatalf("missing %q in:\n%s", line, buf.String())
+		}
+	}
+	if strings.Contains(buf.String(), "ignored") {
+		t.Fatalf("bad:\n%s", buf.String())
+	}
+}
+
+func TestPrometheusSink_retention(t *testing.T) {
+	s := NewPrometheusSink(time.Hour, "")
+	s.SetGauge([]string{"vault", "old"}, 1)
+	s.SetGauge([]string{"vault", "new"}, 1)
+	s.series["gauge vault_old"].updated = time.Now().Add(-2 * time.Hour)
+
+	var buf bytes.Buffer
+	if _, err := s.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "# TYPE vault_new gauge\nvault_new 1\n" {
+		t.Fatalf("bad:\n%s", buf.String())
+	}
+}
+
+func TestLabel(t *testing.T) {
+	cases := map[string]string{
+		"secret/":        "mount=secret",
+		"prod/aws.east/": "mount=prod_aws_east",
+		"my-kv":          "mount=my-kv",
+		`a"b=c d`:        "mount=a_b_c_d",
+	}
+
+	for value, expected := range cases {
+		if actual := Label("mount", value); actual != expected {
+			t.Fatalf("bad: %q: %q", value, actual)
+		}
+	}
+}
+
+func TestFlattenKey(t *testing.T) {
+	cases := []struct {
+		key    []string
+		name   string
+		labels string
+	}{
+		{[]string{"vault", "core", "seal-internal"}, "vault_core_seal_internal", ""},
+		{[]string{"vault", "route", "read", "secret/"}, "vault_route_read_secret_", ""},
+		{[]string{"vault", "token", Label("z", "1"), Label("auth", "auth/token/")}, "vault_token", `{auth="auth_token",z="1"}`},
+		{[]string{"1st"}, "_1st", ""},
+	}
+
+	for _, tc := range cases {
+		name, labels := flattenKey(tc.key)
+		if name != tc.name || labels != tc.labels {
+			t.Fatalf("bad: %v: %q %q", tc.key, name, labels)
+		}
+	}
+}
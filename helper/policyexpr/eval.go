@@ -0,0 +1,846 @@
+package policyexpr
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/helper/parseutil"
+)
+
+// maxEvalSteps bounds the work done by a single evaluation, so that
+// comprehensions over large inputs cannot stall the caller
+const maxEvalSteps = 100000
+
+// scope holds the variables of an evaluation; comprehensions add a scope
+// for their variable
+type scope struct {
+	name   string
+	value  interface{}
+	vars   map[string]interface{}
+	parent *scope
+}
+
+func (s *scope) lookup(name string) (interface{}, bool) {
+	for ; s != nil; s = s.parent {
+		if s.vars != nil {
+			v, ok := s.vars[name]
+			return v, ok
+		}
+		if s.name == name {
+			return s.value, true
+		}
+	}
+	return nil, false
+}
+
+type evaluator struct {
+	steps int
+}
+
+func (e *evaluator) eval(n node, s *scope) (interface{}, error) {
+	e.steps++
+	if e.steps > maxEvalSteps {
+		return nil, fmt.Errorf("evaluation exceeded %d steps", maxEvalSteps)
+	}
+
+	switch n := n.(type) {
+	case *literalNode:
+		return n.value, nil
+
+	case *identNode:
+		v, ok := s.lookup(n.name)
+		if !ok {
+			return nil, fmt.Errorf("undeclared reference to %q", n.name)
+		}
+		return v, nil
+
+	case *selectNode:
+		operand, err := e.eval(n.operand, s)
+		if err != nil {
+			return nil, err
+		}
+		m, ok := operand.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("cannot select field %q of %s", n.field, typeName(operand))
+		}
+		v, ok := m[n.field]
+		if !ok {
+			return nil, fmt.Errorf("no such key: %s", n.field)
+		}
+		return v, nil
+
+	case *hasNode:
+		operand, err := e.eval(n.operand.operand, s)
+		if err != nil {
+			return nil, err
+		}
+		m, ok := operand.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("has() cannot test field %q of %s", n.operand.field, typeName(operand))
+		}
+		_, ok = m[n.operand.field]
+		return ok, nil
+
+	case *indexNode:
+		operand, err := e.eval(n.operand, s)
+		if err != nil {
+			return nil, err
+		}
+		index, err := e.eval(n.index, s)
+		if err != nil {
+			return nil, err
+		}
+		return indexValue(operand, index)
+
+	case *listNode:
+		list := make([]interface{}, 0, len(n.elems))
+		for _, elem := range n.elems {
+			v, err := e.eval(elem, s)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+
+	case *unaryNode:
+		operand, err := e.eval(n.operand, s)
+		if err != nil {
+			return nil, err
+		}
+		return unary(n.op, operand)
+
+	case *binaryNode:
+		switch n.op {
+		case "&&", "||":
+			return e.logical(n, s)
+		}
+		left, err := e.eval(n.left, s)
+		if err != nil {
+			return nil, err
+		}
+		right, err := e.eval(n.right, s)
+		if err != nil {
+			return nil, err
+		}
+		return binary(n.op, left, right)
+
+	case *condNode:
+		cond, err := e.eval(n.cond, s)
+		if err != nil {
+			return nil, err
+		}
+		b, ok := cond.(bool)
+		if !ok {
+			return nil, fmt.Errorf("condition must be a bool, got %s", typeName(cond))
+		}
+		if b {
+			return e.eval(n.then, s)
+		}
+		return e.eval(n.otherwise, s)
+
+	case *callNode:
+		var target interface{}
+		if n.target != nil {
+			var err error
+			target, err = e.eval(n.target, s)
+			if err != nil {
+				return nil, err
+			}
+		}
+		args := make([]interface{}, 0, len(n.args))
+		for _, arg := range n.args {
+			v, err := e.eval(arg, s)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, v)
+		}
+		if n.target != nil {
+			return callMethod(n.name, target, args)
+		}
+		return callFunction(n.name, args)
+
+	case *comprehensionNode:
+		return e.comprehension(n, s)
+	}
+
+	return nil, fmt.Errorf("unknown expression node %T", n)
+}
+
+// logical evaluates && and ||, where an error on one side is absorbed if the
+// other side decides the result, as in CEL
+func (e *evaluator) logical(n *binaryNode, s *scope) (interface{}, error) {
+	decisive := n.op == "||"
+
+	left, leftErr := e.eval(n.left, s)
+	if leftErr == nil {
+		b, ok := left.(bool)
+		if !ok {
+			leftErr = fmt.Errorf("no matching overload for %q applied to (%s, ...)", n.op, typeName(left))
+		} else if b == decisive {
+			return b, nil
+		}
+	}
+
+	right, rightErr := e.eval(n.right, s)
+	if rightErr == nil {
+		b, ok := right.(bool)
+		if !ok {
+			rightErr = fmt.Errorf("no matching overload for %q applied to (..., %s)", n.op, typeName(right))
+		} else if b == decisive {
+			return b, nil
+		}
+	}
+
+	if leftErr != nil {
+		return nil, leftErr
+	}
+	if rightErr != nil {
+		return nil, rightErr
+	}
+	return !decisive, nil
+}
+
+func (e *evaluator) comprehension(n *comprehensionNode, s *scope) (interface{}, error) {
+	target, err := e.eval(n.target, s)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []interface{}
+	switch t := target.(type) {
+	case []interface{}:
+		items = t
+	case map[string]interface{}:
+		for _, key := range sortedKeys(t) {
+			items = append(items, key)
+		}
+	default:
+		return nil, fmt.Errorf("%s() cannot range over %s", n.macro, typeName(target))
+	}
+
+	var result []interface{}
+	count := 0
+	for _, item := range items {
+		v, err := e.eval(n.body, &scope{name: n.variable, value: item, parent: s})
+		if err != nil {
+			return nil, err
+		}
+
+		if n.macro == "map" {
+			result = append(result, v)
+			continue
+		}
+
+		b, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%s() requires a bool predicate, got %s", n.macro, typeName(v))
+		}
+		switch n.macro {
+		case "all":
+			if !b {
+				return false, nil
+			}
+		case "exists":
+			if b {
+				return true, nil
+			}
+		case "exists_one":
+			if b {
+				count++
+			}
+		case "filter":
+			if b {
+				result = append(result, item)
+			}
+		}
+	}
+
+	switch n.macro {
+	case "all":
+		return true, nil
+	case "exists":
+		return false, nil
+	case "exists_one":
+		return count == 1, nil
+	}
+	if result == nil {
+		result = []interface{}{}
+	}
+	return result, nil
+}
+
+func indexValue(operand, index interface{}) (interface{}, error) {
+	switch o := operand.(type) {
+	case []interface{}:
+		i, ok := index.(int64)
+		if !ok {
+			return nil, fmt.Errorf("list index must be an int, got %s", typeName(index))
+		}
+		if i < 0 || i >= int64(len(o)) {
+			return nil, fmt.Errorf("index %d out of range", i)
+		}
+		return o[i], nil
+
+	case map[string]interface{}:
+		key, ok := index.(string)
+		if !ok {
+			return nil, fmt.Errorf("map key must be a string, got %s", typeName(index))
+		}
+		v, ok := o[key]
+		if !ok {
+			return nil, fmt.Errorf("no such key: %s", key)
+		}
+		return v, nil
+	}
+	return nil, fmt.Errorf("cannot index %s", typeName(operand))
+}
+
+func unary(op string, operand interface{}) (interface{}, error) {
+	switch op {
+	case "!":
+		if b, ok := operand.(bool); ok {
+			return !b, nil
+		}
+	case "-":
+		switch v := operand.(type) {
+		case int64:
+			if v == math.MinInt64 {
+				return nil, fmt.Errorf("integer overflow")
+			}
+			return -v, nil
+		case float64:
+			return -v, nil
+		case time.Duration:
+			return -v, nil
+		}
+	}
+	return nil, fmt.Errorf("no matching overload for %q applied to (%s)", op, typeName(operand))
+}
+
+func binary(op string, left, right interface{}) (interface{}, error) {
+	switch op {
+	case "==":
+		return equal(left, right), nil
+	case "!=":
+		return !equal(left, right), nil
+	case "<", "<=", ">", ">=":
+		c, ok := compare(left, right)
+		if ok {
+			switch op {
+			case "<":
+				return c < 0, nil
+			case "<=":
+				return c <= 0, nil
+			case ">":
+				return c > 0, nil
+			default:
+				return c >= 0, nil
+			}
+		}
+	case "in":
+		switch r := right.(type) {
+		case []interface{}:
+			for _, elem := range r {
+				if equal(left, elem) {
+					return true, nil
+				}
+			}
+			return false, nil
+		case map[string]interface{}:
+			if key, ok := left.(string); ok {
+				_, ok := r[key]
+				return ok, nil
+			}
+		}
+	default:
+		if v, ok, err := arithmetic(op, left, right); ok || err != nil {
+			return v, err
+		}
+	}
+	return nil, fmt.Errorf("no matching overload for %q applied to (%s, %s)", op, typeName(left), typeName(right))
+}
+
+func arithmetic(op string, left, right interface{}) (interface{}, bool, error) {
+	switch l := left.(type) {
+	case int64:
+		if r, ok := right.(int64); ok {
+			v, err := intArithmetic(op, l, r)
+			return v, true, err
+		}
+	case string:
+		if r, ok := right.(string); ok && op == "+" {
+			return l + r, true, nil
+		}
+	case []interface{}:
+		if r, ok := right.([]interface{}); ok && op == "+" {
+			list := make([]interface{}, 0, len(l)+len(r))
+			return append(append(list, l...), r...), true, nil
+		}
+	case time.Time:
+		switch r := right.(type) {
+		case time.Duration:
+			switch op {
+			case "+":
+				return l.Add(r), true, nil
+			case "-":
+				return l.Add(-r), true, nil
+			}
+		case time.Time:
+			if op == "-" {
+				return l.Sub(r), true, nil
+			}
+		}
+	case time.Duration:
+		switch r := right.(type) {
+		case time.Duration:
+			switch op {
+			case "+":
+				return l + r, true, nil
+			case "-":
+				return l - r, true, nil
+			}
+		case time.Time:
+			if op == "+" {
+				return r.Add(l), true, nil
+			}
+		}
+	}
+
+	// Mixed numbers are computed as doubles
+	l, lok := toFloat(left)
+	r, rok := toFloat(right)
+	if !lok || !rok {
+		return nil, false, nil
+	}
+	switch op {
+	case "+":
+		return l + r, true, nil
+	case "-":
+		return l - r, true, nil
+	case "*":
+		return l * r, true, nil
+	case "/":
+		return l / r, true, nil
+	}
+	return nil, false, nil
+}
+
+func intArithmetic(op string, l, r int64) (interface{}, error) {
+	switch op {
+	case "+":
+		v := l + r
+		if (v > l) != (r > 0) {
+			return nil, fmt.Errorf("integer overflow")
+		}
+		return v, nil
+	case "-":
+		v := l - r
+		if (v < l) != (r > 0) {
+			return nil, fmt.Errorf("integer overflow")
+		}
+		return v, nil
+	case "*":
+		if l != 0 && ((l*r)/l != r || (l == -1 && r == math.MinInt64) || (r == -1 && l == math.MinInt64)) {
+			return nil, fmt.Errorf("integer overflow")
+		}
+		return l * r, nil
+	case "/", "%":
+		if r == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		if l == math.MinInt64 && r == -1 {
+			return nil, fmt.Errorf("integer overflow")
+		}
+		if op == "/" {
+			return l / r, nil
+		}
+		return l % r, nil
+	}
+	return nil, fmt.Errorf("no matching overload for %q applied to (int, int)", op)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+func equal(left, right interface{}) bool {
+	if l, ok := toFloat(left); ok {
+		r, ok := toFloat(right)
+		return ok && l == r
+	}
+
+	switch l := left.(type) {
+	case []interface{}:
+		r, ok := right.([]interface{})
+		if !ok || len(l) != len(r) {
+			return false
+		}
+		for i := range l {
+			if !equal(l[i], r[i]) {
+				return false
+			}
+		}
+		return true
+
+	case map[string]interface{}:
+		r, ok := right.(map[string]interface{})
+		if !ok || len(l) != len(r) {
+			return false
+		}
+		for key, lv := range l {
+			rv, ok := r[key]
+			if !ok || !equal(lv, rv) {
+				return false
+			}
+		}
+		return true
+
+	case time.Time:
+		r, ok := right.(time.Time)
+		return ok && l.Equal(r)
+	}
+
+	return left == right
+}
+
+// compare orders two values of the same type, returning false if they
+// cannot be ordered
+func compare(left, right interface{}) (int, bool) {
+	if l, ok := toFloat(left); ok {
+		r, ok := toFloat(right)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case l < r:
+			return -1, true
+		case l > r:
+			return 1, true
+		}
+		return 0, true
+	}
+
+	switch l := left.(type) {
+	case string:
+		if r, ok := right.(string); ok {
+			return strings.Compare(l, r), true
+		}
+	case bool:
+		if r, ok := right.(bool); ok {
+			switch {
+			case l == r:
+				return 0, true
+			case r:
+				return -1, true
+			}
+			return 1, true
+		}
+	case time.Duration:
+		if r, ok := right.(time.Duration); ok {
+			switch {
+			case l < r:
+				return -1, true
+			case l > r:
+				return 1, true
+			}
+			return 0, true
+		}
+	case time.Time:
+		if r, ok := right.(time.Time); ok {
+			switch {
+			case l.Before(r):
+				return -1, true
+			case l.After(r):
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+	return 0, false
+}
+
+func callFunction(name string, args []interface{}) (interface{}, error) {
+	switch name {
+	case "size":
+		if len(args) == 1 {
+			return size(args[0])
+		}
+
+	case "int":
+		if len(args) == 1 {
+			return toInt(args[0])
+		}
+
+	case "double":
+		if len(args) == 1 {
+			switch v := args[0].(type) {
+			case int64:
+				return float64(v), nil
+			case float64:
+				return v, nil
+			case string:
+				return strconv.ParseFloat(v, 64)
+			}
+		}
+
+	case "string":
+		if len(args) == 1 {
+			switch v := args[0].(type) {
+			case string:
+				return v, nil
+			case int64:
+				return strconv.FormatInt(v, 10), nil
+			case float64:
+				return strconv.FormatFloat(v, 'g', -1, 64), nil
+			case bool:
+				return strconv.FormatBool(v), nil
+			case time.Duration:
+				return v.String(), nil
+			case time.Time:
+				return v.UTC().Format(time.RFC3339Nano), nil
+			}
+		}
+
+	case "duration":
+		// Durations are parsed as in the rest of Vault, so "24h" and 86400
+		// are the same
+		if len(args) == 1 {
+			switch v := args[0].(type) {
+			case time.Duration:
+				return v, nil
+			case string, int64, float64:
+				return parseutil.ParseDurationSecond(v)
+			}
+		}
+
+	case "timestamp":
+		if len(args) == 1 {
+			switch v := args[0].(type) {
+			case time.Time:
+				return v, nil
+			case string:
+				return time.Parse(time.RFC3339, v)
+			case int64:
+				return time.Unix(v, 0).UTC(), nil
+			}
+		}
+
+	case "matches":
+		if len(args) == 2 {
+			return callMethod("matches", args[0], args[1:])
+		}
+
+	case "inCIDR":
+		if len(args) == 2 {
+			addr, aok := args[0].(string)
+			cidr, cok := args[1].(string)
+			if aok && cok {
+				return inCIDR(addr, cidr)
+			}
+		}
+
+	default:
+		return nil, fmt.Errorf("undeclared reference to function %q", name)
+	}
+
+	return nil, fmt.Errorf("no matching overload for %s(%s)", name, typeNames(args))
+}
+
+func callMethod(name string, target interface{}, args []interface{}) (interface{}, error) {
+	switch t := target.(type) {
+	case string:
+		if len(args) == 0 {
+			switch name {
+			case "size":
+				return size(t)
+			case "lowerAscii":
+				return strings.ToLower(t), nil
+			case "upperAscii":
+				return strings.ToUpper(t), nil
+			}
+			break
+		}
+		arg, ok := args[0].(string)
+		if len(args) != 1 || !ok {
+			break
+		}
+		switch name {
+		case "startsWith":
+			return strings.HasPrefix(t, arg), nil
+		case "endsWith":
+			return strings.HasSuffix(t, arg), nil
+		case "contains":
+			return strings.Contains(t, arg), nil
+		case "matches":
+			re, err := regexp.Compile(arg)
+			if err != nil {
+				return nil, fmt.Errorf("invalid regular expression %q: %s", arg, err)
+			}
+			return re.MatchString(t), nil
+		}
+
+	case []interface{}, map[string]interface{}:
+		if name == "size" && len(args) == 0 {
+			return size(t)
+		}
+
+	case time.Time:
+		if len(args) > 1 {
+			break
+		}
+		if len(args) == 1 {
+			tz, ok := args[0].(string)
+			if !ok {
+				break
+			}
+			loc, err := time.LoadLocation(tz)
+			if err != nil {
+				return nil, fmt.Errorf("invalid time zone %q", tz)
+			}
+			t = t.In(loc)
+		} else {
+			t = t.UTC()
+		}
+		// As in CEL, months and days of the month and year are zero-based,
+		// except for getDate
+		switch name {
+		case "getFullYear":
+			return int64(t.Year()), nil
+		case "getMonth":
+			return int64(t.Month()) - 1, nil
+		case "getDate":
+			return int64(t.Day()), nil
+		case "getDayOfMonth":
+			return int64(t.Day()) - 1, nil
+		case "getDayOfYear":
+			return int64(t.YearDay()) - 1, nil
+		case "getDayOfWeek":
+			return int64(t.Weekday()), nil
+		case "getHours":
+			return int64(t.Hour()), nil
+		case "getMinutes":
+			return int64(t.Minute()), nil
+		case "getSeconds":
+			return int64(t.Second()), nil
+		}
+
+	case time.Duration:
+		if len(args) != 0 {
+			break
+		}
+		switch name {
+		case "getHours":
+			return int64(t / time.Hour), nil
+		case "getMinutes":
+			return int64(t / time.Minute), nil
+		case "getSeconds":
+			return int64(t / time.Second), nil
+		}
+	}
+
+	return nil, fmt.Errorf("no matching overload for %s.%s(%s)", typeName(target), name, typeNames(args))
+}
+
+func size(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case string:
+		return int64(len([]rune(v))), nil
+	case []interface{}:
+		return int64(len(v)), nil
+	case map[string]interface{}:
+		return int64(len(v)), nil
+	}
+	return nil, fmt.Errorf("no matching overload for size(%s)", typeName(v))
+}
+
+func toInt(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case int64:
+		return v, nil
+	case float64:
+		if math.IsNaN(v) || v >= math.MaxInt64 || v < math.MinInt64 {
+			return nil, fmt.Errorf("integer overflow")
+		}
+		return int64(v), nil
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	case time.Time:
+		return v.Unix(), nil
+	case time.Duration:
+		return int64(v / time.Second), nil
+	}
+	return nil, fmt.Errorf("no matching overload for int(%s)", typeName(v))
+}
+
+func inCIDR(addr, cidr string) (interface{}, error) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CIDR %q", cidr)
+	}
+
+	// Addresses may carry a port
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false, nil
+	}
+	return network.Contains(ip), nil
+}
+
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "bool"
+	case int64:
+		return "int"
+	case float64:
+		return "double"
+	case string:
+		return "string"
+	case []interface{}:
+		return "list"
+	case map[string]interface{}:
+		return "map"
+	case time.Time:
+		return "timestamp"
+	case time.Duration:
+		return "duration"
+	}
+	return reflect.TypeOf(v).String()
+}
+
+func typeNames(args []interface{}) string {
+	names := make([]string, 0, len(args))
+	for _, arg := range args {
+		names = append(names, typeName(arg))
+	}
+	return strings.Join(names, ", ")
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
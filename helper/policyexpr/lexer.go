@@ -0,0 +1,145 @@
+package policyexpr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenType int
+
+const (
+	tokenEOF tokenType = iota
+	tokenIdent
+	tokenString
+	tokenInt
+	tokenFloat
+	tokenOperator
+)
+
+type token struct {
+	typ   tokenType
+	value string
+	pos   int
+}
+
+func (t *token) String() string {
+	if t.typ == tokenEOF {
+		return "end of expression"
+	}
+	return fmt.Sprintf("%q", t.value)
+}
+
+// operators are matched longest first
+var operators = []string{
+	"==", "!=", "<=", ">=", "&&", "||",
+	"<", ">", "+", "-", "*", "/", "%", "!",
+	"(", ")", "[", "]", ".", ",", "?", ":",
+}
+
+func tokenize(raw string) ([]*token, error) {
+	var tokens []*token
+	runes := []rune(raw)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case r == '"' || r == '\'':
+			start := i
+			value, n, err := unquote(runes[i:])
+			if err != nil {
+				return nil, fmt.Errorf("%s at offset %d", err, start)
+			}
+			tokens = append(tokens, &token{typ: tokenString, value: value, pos: start})
+			i += n
+
+		case unicode.IsDigit(r):
+			start := i
+			typ := tokenInt
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.' || runes[i] == 'e' || runes[i] == 'E') {
+				if runes[i] != '.' && runes[i] != 'e' && runes[i] != 'E' {
+					i++
+					continue
+				}
+				// A '.' not followed by a digit is a member access on a number
+				if runes[i] == '.' && (i+1 >= len(runes) || !unicode.IsDigit(runes[i+1])) {
+					break
+				}
+				typ = tokenFloat
+				if runes[i] != '.' && i+1 < len(runes) && (runes[i+1] == '-' || runes[i+1] == '+') {
+					i++
+				}
+				i++
+			}
+			value := string(runes[start:i])
+			if typ == tokenInt {
+				if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+					return nil, fmt.Errorf("invalid integer %q at offset %d", value, start)
+				}
+			} else if _, err := strconv.ParseFloat(value, 64); err != nil {
+				return nil, fmt.Errorf("invalid number %q at offset %d", value, start)
+			}
+			tokens = append(tokens, &token{typ: typ, value: value, pos: start})
+
+		case r == '_' || unicode.IsLetter(r):
+			start := i
+			for i < len(runes) && (runes[i] == '_' || unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i])) {
+				i++
+			}
+			tokens = append(tokens, &token{typ: tokenIdent, value: string(runes[start:i]), pos: start})
+
+		default:
+			matched := false
+			rest := string(runes[i:])
+			for _, op := range operators {
+				if strings.HasPrefix(rest, op) {
+					tokens = append(tokens, &token{typ: tokenOperator, value: op, pos: i})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected %q at offset %d", r, i)
+			}
+		}
+	}
+
+	return append(tokens, &token{typ: tokenEOF, pos: len(runes)}), nil
+}
+
+// unquote reads a single- or double-quoted string with backslash escapes,
+// returning its value and the number of runes read
+func unquote(runes []rune) (string, int, error) {
+	quote := runes[0]
+	var value []rune
+	for i := 1; i < len(runes); i++ {
+		switch runes[i] {
+		case quote:
+			return string(value), i + 1, nil
+		case '\\':
+			i++
+			if i >= len(runes) {
+				break
+			}
+			switch runes[i] {
+			case 'n':
+				value = append(value, '\n')
+			case 't':
+				value = append(value, '\t')
+			case 'r':
+				value = append(value, '\r')
+			case '\\', '"', '\'':
+				value = append(value, runes[i])
+			default:
+				return "", 0, fmt.Errorf("invalid escape \\%c", runes[i])
+			}
+		default:
+			value = append(value, runes[i])
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string")
+}
@@ -0,0 +1,505 @@
+package policyexpr
+
+import (
+	"fmt"
+	"strconv"
+)
+
+const (
+	// maxExpressionLength is the longest expression that is parsed
+	maxExpressionLength = 16 * 1024
+
+	// maxNestingDepth is how deeply expressions can be nested
+	maxNestingDepth = 64
+)
+
+type node interface{}
+
+type literalNode struct {
+	value interface{}
+}
+
+type identNode struct {
+	name string
+}
+
+type selectNode struct {
+	operand node
+	field   string
+}
+
+type indexNode struct {
+	operand node
+	index   node
+}
+
+type callNode struct {
+	// target is the receiver of method calls, such as "a" in
+	// a.startsWith("b"), and nil for function calls
+	target node
+	name   string
+	args   []node
+}
+
+type listNode struct {
+	elems []node
+}
+
+type unaryNode struct {
+	op      string
+	operand node
+}
+
+type binaryNode struct {
+	op          string
+	left, right node
+}
+
+type condNode struct {
+	cond, then, otherwise node
+}
+
+// hasNode is the has(a.b) macro, testing whether a map has a key
+type hasNode struct {
+	operand *selectNode
+}
+
+// comprehensionNode is one of the all, exists, exists_one, filter and map
+// macros, such as list.exists(x, x > 1)
+type comprehensionNode struct {
+	macro    string
+	target   node
+	variable string
+	body     node
+}
+
+var comprehensionMacros = map[string]bool{
+	"all":        true,
+	"exists":     true,
+	"exists_one": true,
+	"filter":     true,
+	"map":        true,
+}
+
+// reservedIdents cannot be used as variables
+var reservedIdents = map[string]bool{
+	"true":  true,
+	"false": true,
+	"null":  true,
+	"in":    true,
+}
+
+type parser struct {
+	tokens []*token
+	pos    int
+	depth  int
+}
+
+func parse(raw string) (node, error) {
+	if len(raw) > maxExpressionLength {
+		return nil, fmt.Errorf("expression is longer than %d bytes", maxExpressionLength)
+	}
+
+	tokens, err := tokenize(raw)
+	if err != nil {
+		return nil, err
+	}
+	if tokens[0].typ == tokenEOF {
+		return nil, fmt.Errorf("expression is empty")
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.typ != tokenEOF {
+		return nil, fmt.Errorf("unexpected %s at offset %d", tok, tok.pos)
+	}
+	return root, nil
+}
+
+func (p *parser) peek() *token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() *token {
+	tok := p.tokens[p.pos]
+	if tok.typ != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// accept consumes the next token if it is the given operator or keyword
+func (p *parser) accept(value string) bool {
+	tok := p.peek()
+	if (tok.typ == tokenOperator || tok.typ == tokenIdent) && tok.value == value {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(value string) error {
+	if !p.accept(value) {
+		tok := p.peek()
+		return fmt.Errorf("expected %q, got %s at offset %d", value, tok, tok.pos)
+	}
+	return nil
+}
+
+// parseExpr parses a conditional expression, the lowest precedence
+func (p *parser) parseExpr() (node, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxNestingDepth {
+		return nil, fmt.Errorf("expression is nested more than %d levels deep", maxNestingDepth)
+	}
+
+	cond, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.accept("?") {
+		return cond, nil
+	}
+
+	then, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	otherwise, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	return &condNode{cond: cond, then: then, otherwise: otherwise}, nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: "||", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseRelation()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("&&") {
+		right, err := p.parseRelation()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: "&&", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseRelation() (node, error) {
+	left, err := p.parseAddition()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var op string
+		for _, candidate := range []string{"==", "!=", "<=", ">=", "<", ">", "in"} {
+			if p.accept(candidate) {
+				op = candidate
+				break
+			}
+		}
+		if op == "" {
+			return left, nil
+		}
+
+		right, err := p.parseAddition()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: op, left: left, right: right}
+	}
+}
+
+func (p *parser) parseAddition() (node, error) {
+	left, err := p.parseMultiplication()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var op string
+		switch {
+		case p.accept("+"):
+			op = "+"
+		case p.accept("-"):
+			op = "-"
+		default:
+			return left, nil
+		}
+
+		right, err := p.parseMultiplication()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: op, left: left, right: right}
+	}
+}
+
+func (p *parser) parseMultiplication() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var op string
+		switch {
+		case p.accept("*"):
+			op = "*"
+		case p.accept("/"):
+			op = "/"
+		case p.accept("%"):
+			op = "%"
+		default:
+			return left, nil
+		}
+
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: op, left: left, right: right}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	var op string
+	switch {
+	case p.accept("!"):
+		op = "!"
+	case p.accept("-"):
+		op = "-"
+	default:
+		return p.parseMember()
+	}
+
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxNestingDepth {
+		return nil, fmt.Errorf("expression is nested more than %d levels deep", maxNestingDepth)
+	}
+
+	operand, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	// Fold negative number literals
+	if lit, ok := operand.(*literalNode); ok && op == "-" {
+		switch v := lit.value.(type) {
+		case int64:
+			return &literalNode{value: -v}, nil
+		case float64:
+			return &literalNode{value: -v}, nil
+		}
+	}
+	return &unaryNode{op: op, operand: operand}, nil
+}
+
+func (p *parser) parseMember() (node, error) {
+	operand, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		switch {
+		case p.accept("."):
+			tok := p.next()
+			if tok.typ != tokenIdent {
+				return nil, fmt.Errorf("expected a field name, got %s at offset %d", tok, tok.pos)
+			}
+			if !p.accept("(") {
+				operand = &selectNode{operand: operand, field: tok.value}
+				continue
+			}
+
+			if comprehensionMacros[tok.value] {
+				operand, err = p.parseComprehension(tok.value, operand)
+			} else {
+				var args []node
+				args, err = p.parseArgs()
+				operand = &callNode{target: operand, name: tok.value, args: args}
+			}
+			if err != nil {
+				return nil, err
+			}
+
+		case p.accept("["):
+			index, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			operand = &indexNode{operand: operand, index: index}
+
+		default:
+			return operand, nil
+		}
+	}
+}
+
+// parseComprehension parses the arguments of a macro such as
+// list.exists(x, x > 1), after the opening parenthesis
+func (p *parser) parseComprehension(macro string, target node) (node, error) {
+	tok := p.next()
+	if tok.typ != tokenIdent || reservedIdents[tok.value] {
+		return nil, fmt.Errorf("expected a variable name in %s(), got %s at offset %d", macro, tok, tok.pos)
+	}
+	if err := p.expect(","); err != nil {
+		return nil, err
+	}
+	body, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	return &comprehensionNode{macro: macro, target: target, variable: tok.value, body: body}, nil
+}
+
+// parseArgs parses a comma separated list of arguments, after the opening
+// parenthesis
+func (p *parser) parseArgs() ([]node, error) {
+	var args []node
+	if p.accept(")") {
+		return args, nil
+	}
+	for {
+		arg, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+
+		if p.accept(")") {
+			return args, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.next()
+	switch tok.typ {
+	case tokenString:
+		return &literalNode{value: tok.value}, nil
+
+	case tokenInt:
+		v, err := strconv.ParseInt(tok.value, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		return &literalNode{value: v}, nil
+
+	case tokenFloat:
+		v, err := strconv.ParseFloat(tok.value, 64)
+		if err != nil {
+			return nil, err
+		}
+		return &literalNode{value: v}, nil
+
+	case tokenIdent:
+		switch tok.value {
+		case "true":
+			return &literalNode{value: true}, nil
+		case "false":
+			return &literalNode{value: false}, nil
+		case "null":
+			return &literalNode{value: nil}, nil
+		case "in":
+			return nil, fmt.Errorf("unexpected %s at offset %d", tok, tok.pos)
+		}
+
+		if !p.accept("(") {
+			return &identNode{name: tok.value}, nil
+		}
+
+		if tok.value == "has" {
+			args, err := p.parseArgs()
+			if err != nil {
+				return nil, err
+			}
+			sel, ok := firstArg(args).(*selectNode)
+			if len(args) != 1 || !ok {
+				return nil, fmt.Errorf("has() requires a single field selection, such as has(a.b), at offset %d", tok.pos)
+			}
+			return &hasNode{operand: sel}, nil
+		}
+
+		args, err := p.parseArgs()
+		if err != nil {
+			return nil, err
+		}
+		return &callNode{name: tok.value, args: args}, nil
+
+	case tokenOperator:
+		switch tok.value {
+		case "(":
+			expr, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return expr, nil
+
+		case "[":
+			var elems []node
+			if p.accept("]") {
+				return &listNode{}, nil
+			}
+			for {
+				elem, err := p.parseExpr()
+				if err != nil {
+					return nil, err
+				}
+				elems = append(elems, elem)
+
+				if p.accept("]") {
+					return &listNode{elems: elems}, nil
+				}
+				if err := p.expect(","); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("unexpected %s at offset %d", tok, tok.pos)
+}
+
+func firstArg(args []node) node {
+	if len(args) == 0 {
+		return nil
+	}
+	return args[0]
+}
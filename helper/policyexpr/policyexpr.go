@@ -0,0 +1,228 @@
+// Package policyexpr implements a sandboxed expression language modelled on
+// the Common Expression Language (CEL), for policies that decide on the
+// contents of a request:
+//
+//	request.operation != "update" ||
+//	  duration(request.data.ttl) < duration("24h") &&
+//	  now.getDayOfWeek() >= 1 && now.getDayOfWeek() <= 5
+//
+// Values are null, bools, ints, doubles, strings, lists, maps with string
+// keys, timestamps and durations. Expressions support:
+//
+//	literals          true, false, null, 42, 1.5, "str", 'str', [1, 2]
+//	operators         ! - * / % + - < <= > >= == != in && || ?:
+//	selection         a.b, a["b"], list[0]
+//	macros            has(a.b), list.all(x, p), list.exists(x, p),
+//	                  list.exists_one(x, p), list.filter(x, p),
+//	                  list.map(x, e)
+//	functions         size, int, double, string, duration, timestamp,
+//	                  matches, inCIDR(ip, cidr)
+//	string methods    size, startsWith, endsWith, contains, matches,
+//	                  lowerAscii, upperAscii
+//	timestamp methods getFullYear, getMonth, getDate, getDayOfMonth,
+//	                  getDayOfYear, getDayOfWeek, getHours, getMinutes,
+//	                  getSeconds, each taking an optional time zone
+//	duration methods  getHours, getMinutes, getSeconds
+//
+// As in CEL, selecting a missing key is an error, which has() avoids, and
+// && and || absorb errors on one side when the other side decides the
+// result. Expressions cannot have side effects, and the size of expressions
+// and the work done evaluating them are bounded.
+package policyexpr
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Expression is a parsed expression.
+type Expression struct {
+	raw  string
+	root node
+}
+
+// Parse parses an expression.
+func Parse(raw string) (*Expression, error) {
+	root, err := parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	return &Expression{
+		raw:  raw,
+		root: root,
+	}, nil
+}
+
+// Eval evaluates the expression with the given variables. Variables are
+// converted to the values of the language: integers become ints, slices
+// lists, maps with string keys maps, and unknown types strings.
+func (e *Expression) Eval(vars map[string]interface{}) (interface{}, error) {
+	converted := make(map[string]interface{}, len(vars))
+	for name, v := range vars {
+		converted[name] = convert(v)
+	}
+
+	ev := &evaluator{}
+	return ev.eval(e.root, &scope{vars: converted})
+}
+
+// EvalBool evaluates the expression, which must result in a bool.
+func (e *Expression) EvalBool(vars map[string]interface{}) (bool, error) {
+	v, err := e.Eval(vars)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("expression resulted in %s, not bool", typeName(v))
+	}
+	return b, nil
+}
+
+func (e *Expression) String() string {
+	return e.raw
+}
+
+// Variables returns the sorted names of the variables the expression
+// refers to, so that callers can reject unknown variables before evaluating.
+func (e *Expression) Variables() []string {
+	found := make(map[string]bool)
+	variables(e.root, nil, found)
+
+	names := make([]string, 0, len(found))
+	for name := range found {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// variables adds the names of the variables referred to in n to found,
+// skipping those bound by enclosing comprehensions
+func variables(n node, bound *scope, found map[string]bool) {
+	switch n := n.(type) {
+	case *identNode:
+		if _, ok := bound.lookup(n.name); !ok {
+			found[n.name] = true
+		}
+	case *selectNode:
+		variables(n.operand, bound, found)
+	case *hasNode:
+		variables(n.operand.operand, bound, found)
+	case *indexNode:
+		variables(n.operand, bound, found)
+		variables(n.index, bound, found)
+	case *listNode:
+		for _, elem := range n.elems {
+			variables(elem, bound, found)
+		}
+	case *unaryNode:
+		variables(n.operand, bound, found)
+	case *binaryNode:
+		variables(n.left, bound, found)
+		variables(n.right, bound, found)
+	case *condNode:
+		variables(n.cond, bound, found)
+		variables(n.then, bound, found)
+		variables(n.otherwise, bound, found)
+	case *callNode:
+		if n.target != nil {
+			variables(n.target, bound, found)
+		}
+		for _, arg := range n.args {
+			variables(arg, bound, found)
+		}
+	case *comprehensionNode:
+		variables(n.target, bound, found)
+		variables(n.body, &scope{name: n.variable, parent: bound}, found)
+	}
+}
+
+// convert converts a Go value to a value of the language
+func convert(v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil, bool, int64, float64, string, time.Time, time.Duration:
+		return v
+	case int:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case float32:
+		return float64(v)
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v.String()
+	case []interface{}:
+		list := make([]interface{}, 0, len(v))
+		for _, elem := range v {
+			list = append(list, convert(elem))
+		}
+		return list
+	case []string:
+		list := make([]interface{}, 0, len(v))
+		for _, elem := range v {
+			list = append(list, elem)
+		}
+		return list
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, elem := range v {
+			m[key] = convert(elem)
+		}
+		return m
+	case map[string]string:
+		m := make(map[string]interface{}, len(v))
+		for key, elem := range v {
+			m[key] = elem
+		}
+		return m
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return nil
+		}
+		return convert(rv.Elem().Interface())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if rv.Uint() <= 1<<63-1 {
+			return int64(rv.Uint())
+		}
+		return strconv.FormatUint(rv.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	case reflect.String:
+		return rv.String()
+	case reflect.Bool:
+		return rv.Bool()
+	case reflect.Slice, reflect.Array:
+		list := make([]interface{}, 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			list = append(list, convert(rv.Index(i).Interface()))
+		}
+		return list
+	case reflect.Map:
+		if rv.Type().Key().Kind() == reflect.String {
+			m := make(map[string]interface{}, rv.Len())
+			for _, key := range rv.MapKeys() {
+				m[key.String()] = convert(rv.MapIndex(key).Interface())
+			}
+			return m
+		}
+	}
+	return fmt.Sprintf("%v", v)
+}
@@ -0,0 +1,368 @@
+package policyexpr
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testVars() map[string]interface{} {
+	return map[string]interface{}{
+		"request": map[string]interface{}{
+			"operation": "update",
+			"path":      "pki/issue/web",
+			"client_ip": "10.1.2.3",
+			"data": map[string]interface{}{
+				"common_name": "www.example.com",
+				"ttl":         "12h",
+				"alt_names":   []string{"a.example.com", "b.example.com"},
+				"count":       json.Number("3"),
+			},
+		},
+		"token": map[string]interface{}{
+			"policies": []string{"default", "pki"},
+			"metadata": map[string]string{"team": "web"},
+			"ttl":      time.Hour,
+		},
+		// A Wednesday
+		"now": time.Date(2017, 8, 16, 14, 30, 0, 0, time.UTC),
+	}
+}
+
+func TestExpression_EvalBool(t *testing.T) {
+	cases := []struct {
+		expr     string
+		expected bool
+	}{
+		{`true`, true},
+		{`!true`, false},
+		{`request.operation == "update"`, true},
+		{`request.operation != 'update'`, false},
+		{`request["path"].startsWith("pki/")`, true},
+		{`request.path.endsWith("/web")`, true},
+		{`request.path.contains("issue")`, true},
+		{`request.path.matches("^pki/issue/[a-z]+$")`, true},
+		{`matches(request.path, "^sys/")`, false},
+		{`"pki" in token.policies`, true},
+		{`"root" in token.policies`, false},
+		{`"team" in token.metadata`, true},
+		{`token.metadata.team == "web"`, true},
+		{`has(token.metadata.team)`, true},
+		{`has(token.metadata.owner)`, false},
+		{`has(token.metadata.owner) && token.metadata.owner == "bob"`, false},
+		{`duration(request.data.ttl) < duration("24h")`, true},
+		{`duration(request.data.ttl) <= duration(43200)`, true},
+		{`token.ttl > duration("30m")`, true},
+		{`token.ttl.getMinutes() == 60`, true},
+		{`request.data.count == 3`, true},
+		{`request.data.count + 1.5 == 4.5`, true},
+		{`request.data.count * 2 > 5 && request.data.count % 2 == 1`, true},
+		{`size(request.data.alt_names) == 2`, true},
+		{`request.data.alt_names.size() == 2 && request.path.size() == 13`, true},
+		{`request.data.alt_names.all(n, n.endsWith(".example.com"))`, true},
+		{`request.data.alt_names.exists(n, n.startsWith("b."))`, true},
+		{`request.data.alt_names.exists_one(n, n.contains("example"))`, false},
+		{`request.data.alt_names.filter(n, n.startsWith("a.")) == ["a.example.com"]`, true},
+		{`request.data.alt_names.map(n, n.size()) == [13, 13]`, true},
+		{`token.metadata.all(k, k == "team")`, true},
+		{`now.getDayOfWeek() >= 1 && now.getDayOfWeek() <= 5`, true},
+		{`now.getHours() == 14 && now.getMinutes() == 30`, true},
+		{`now.getHours("America/New_York") == 10`, true},
+		{`now.getFullYear() == 2017 && now.getMonth() == 7 && now.getDate() == 16`, true},
+		{`now.getDayOfMonth() == 15`, true},
+		{`now > timestamp("2017-01-01T00:00:00Z")`, true},
+		{`now - timestamp("2017-08-16T00:00:00Z") == duration("14h30m")`, true},
+		{`now + duration("10h") > timestamp("2017-08-17T00:00:00Z")`, true},
+		{`inCIDR(request.client_ip, "10.0.0.0/8")`, true},
+		{`inCIDR("192.168.1.1:8200", "10.0.0.0/8")`, false},
+		{`request.operation == "read" ? false : true`, true},
+		{`-1 < 0 && -(2) == -2 && 7 / 2 == 3`, true},
+		{`"a" + "b" == "ab" && [1] + [2] == [1, 2]`, true},
+		{`"abc" < "abd" && false < true`, true},
+		{`int("42") == 42 && double(1) == 1.0 && string(42) == "42"`, true},
+		{`1 == 1.0`, true},
+		{`null == null && request.data != null`, true},
+	}
+
+	for _, c := range cases {
+		e, err := Parse(c.expr)
+		if err != nil {
+			t.Fatalf("%s: err: %v", c.expr, err)
+		}
+		actual, err := e.EvalBool(testVars())
+		if err != nil {
+			t.Fatalf("%s: err: %v", c.expr, err)
+		}
+		if actual != c.expected {
+			t.Fatalf("%s: expected %t, got %t", c.expr, c.expected, actual)
+		}
+		if e.String() != c.expr {
+			t.Fatalf("bad: %s", e.String())
+		}
+	}
+}
+
+func TestExpression_Eval(t *testing.T) {
+	cases := []struct {
+		expr     string
+		expected interface{}
+	}{
+		{`1 + 2 * 3`, int64(7)},
+		{`(1 + 2) * 3`, int64(9)},
+		{`1.5e1`, float64(15)},
+		{`"a\"b"`, `a"b`},
+		{`[1, "a", true, null]`, []interface{}{int64(1), "a", true, nil}},
+		{`request.data.alt_names[1]`, "b.example.com"},
+		{`token.metadata`, map[string]interface{}{"team": "web"}},
+		{`"A".lowerAscii() + "b".upperAscii()`, "aB"},
+		{`duration("1h") + duration("30m")`, 90 * time.Minute},
+	}
+
+	for _, c := range cases {
+		e, err := Parse(c.expr)
+		if err != nil {
+			t.Fatalf("%s: err: %v", c.expr, err)
+		}
+		actual, err := e.Eval(testVars())
+		if err != nil {
+			t.Fatalf("%s: err: %v", c.expr, err)
+		}
+		if !reflect.DeepEqual(actual, c.expected) {
+			t.Fatalf("%s: expected %#v, got %#v", c.expr, c.expected, actual)
+		}
+	}
+}
+
+func TestExpression_errorAbsorption(t *testing.T) {
+	cases := []struct {
+		expr     string
+		expected bool
+		err      string
+	}{
+		// An error on either side is absorbed when the other side decides
+		{`token.metadata.owner == "bob" || true`, true, ""},
+		{`true || token.metadata.owner == "bob"`, true, ""},
+		{`token.metadata.owner == "bob" && false`, false, ""},
+		{`false && token.metadata.owner == "bob"`, false, ""},
+		{`request.path || true`, true, ""},
+		{`false && request.path`, false, ""},
+		{`1 / 0 == 1 || request.operation == "update"`, true, ""},
+		{`(foo || true) && (false || true)`, true, ""},
+		{`!(foo && false)`, true, ""},
+		{`request.data.alt_names.exists(n, n.foo || n.startsWith("a."))`, true, ""},
+
+		// Otherwise the error is returned, the left one first
+		{`token.metadata.owner == "bob" || false`, false, "no such key: owner"},
+		{`true && token.metadata.owner == "bob"`, false, "no such key: owner"},
+		{`foo || bar`, false, `undeclared reference to "foo"`},
+		{`foo && bar`, false, `undeclared reference to "foo"`},
+		{`false || bar`, false, `undeclared reference to "bar"`},
+		{`request.path || false`, false, `no matching overload for "||" applied to (string, ...)`},
+		{`true && 1`, false, `no matching overload for "&&" applied to (..., int)`},
+		{`(foo || true) && bar`, false, `undeclared reference to "bar"`},
+		{`!(foo || false)`, false, `undeclared reference to "foo"`},
+	}
+
+	for _, c := range cases {
+		e, err := Parse(c.expr)
+		if err != nil {
+			t.Fatalf("%s: err: %v", c.expr, err)
+		}
+		actual, err := e.EvalBool(testVars())
+		switch {
+		case c.err == "" && err != nil:
+			t.Fatalf("%s: err: %v", c.expr, err)
+		case c.err != "" && err == nil:
+			t.Fatalf("%s: expected error", c.expr)
+		case c.err != "" && !strings.Contains(err.Error(), c.err):
+			t.Fatalf("%s: expected error containing %q, got: %s", c.expr, c.err, err)
+		}
+		if actual != c.expected {
+			t.Fatalf("%s: expected %t, got %t", c.expr, c.expected, actual)
+		}
+	}
+}
+
+func TestParse_errors(t *testing.T) {
+	cases := map[string]string{
+		``:                             "expression is empty",
+		`a ==`:                         "unexpected end of expression",
+		`(a`:                           `expected ")"`,
+		`a b`:                          `unexpected "b"`,
+		`"abc`:                         "unterminated string",
+		`a & b`:                        `unexpected '&'`,
+		`has(a)`:                       "has() requires a single field selection",
+		`a.exists(1, true)`:            "expected a variable name",
+		`a.`:                           "expected a field name",
+		`99999999999999999999`:         "invalid integer",
+		`1.5e`:                         "invalid number",
+		`"a\q"`:                        `invalid escape \q`,
+		`a ? b`:                        `expected ":"`,
+		`a ? b :`:                      "unexpected end of expression",
+		`a[1`:                          `expected "]"`,
+		`[1, 2`:                        `expected ","`,
+		`[1 2]`:                        `expected ","`,
+		`f(1 2)`:                       `expected ","`,
+		`f(1,`:                         "unexpected end of expression",
+		`a.exists(x)`:                  `expected ","`,
+		`a.exists(x, true`:             `expected ")"`,
+		`a.exists(true, true)`:         "expected a variable name",
+		`a.1`:                          "expected a field name",
+		`in`:                           `unexpected "in"`,
+		`a in`:                         "unexpected end of expression",
+		`has(a.b, a.c)`:                "has() requires a single field selection",
+		`has()`:                        "has() requires a single field selection",
+		`)`:                            `unexpected ")"`,
+		`a = b`:                        `unexpected '='`,
+		`a | b`:                        `unexpected '|'`,
+		`a @ b`:                        `unexpected '@'`,
+		`a == b)`:                      `unexpected ")" at offset 6`,
+		strings.Repeat("-", 100) + "1": "nested more than",
+		strings.Repeat("[", 100):       "nested more than",
+		strings.Repeat("f(", 100):      "nested more than",
+		strings.Repeat("(", 100):       "nested more than",
+		strings.Repeat("!", 100):       "nested more than",
+		strings.Repeat("a", 20000):     "longer than",
+	}
+
+	for expr, expected := range cases {
+		_, err := Parse(expr)
+		if err == nil {
+			t.Fatalf("%.20s: expected error", expr)
+		}
+		if !strings.Contains(err.Error(), expected) {
+			t.Fatalf("%.20s: expected error containing %q, got: %s", expr, expected, err)
+		}
+	}
+}
+
+func TestExpression_evalErrors(t *testing.T) {
+	cases := map[string]string{
+		`foo`:                              `undeclared reference to "foo"`,
+		`token.metadata.owner`:             "no such key: owner",
+		`request.path.foo`:                 "cannot select field",
+		`request.path + 1`:                 "no matching overload",
+		`1 / 0`:                            "division by zero",
+		`9223372036854775807 + 1`:          "integer overflow",
+		`request.data.alt_names[5]`:        "out of range",
+		`unknown(1)`:                       "undeclared reference to function",
+		`request.path.matches("(")`:        "invalid regular expression",
+		`now.getHours("Nowhere/Special")`:  "invalid time zone",
+		`request.path`:                     "not bool",
+		`request.path ? true : false`:      "condition must be a bool",
+		`request.data.alt_names.all(n, n)`: "requires a bool predicate",
+		`[1, 2, 3, 4, 5, 6, 7, 8, 9, 10].all(a, [1, 2, 3, 4, 5, 6, 7, 8, 9, 10].all(b, [1, 2, 3, 4, 5, 6, 7, 8, 9, 10].all(c, [1, 2, 3, 4, 5, 6, 7, 8, 9, 10].all(d, [1, 2, 3, 4, 5, 6, 7, 8, 9, 10].all(e, true)))))`: "exceeded",
+	}
+
+	for expr, expected := range cases {
+		e, err := Parse(expr)
+		if err != nil {
+			t.Fatalf("%s: err: %v", expr, err)
+		}
+		_, err = e.EvalBool(testVars())
+		if err == nil {
+			t.Fatalf("%s: expected error", expr)
+		}
+		if !strings.Contains(err.Error(), expected) {
+			t.Fatalf("%s: expected error containing %q, got: %s", expr, expected, err)
+		}
+	}
+}
+
+func TestExpression_stepLimit(t *testing.T) {
+	// Each comprehension multiplies the work done by the ones it contains
+	nested := "true"
+	for i := 0; i < 5; i++ {
+		nested = fmt.Sprintf("[1, 2, 3, 4, 5, 6, 7, 8, 9, 10].all(x%d, %s)", i, nested)
+	}
+
+	cases := []string{
+		nested,
+		`[1, 2, 3, 4, 5, 6, 7, 8, 9, 10].map(a, [1, 2, 3, 4, 5, 6, 7, 8, 9, 10].map(b, [1, 2, 3, 4, 5, 6, 7, 8, 9, 10].map(c, [1, 2, 3, 4, 5, 6, 7, 8, 9, 10].map(d, [1, 2, 3, 4, 5, 6, 7, 8, 9, 10].map(e, e))))).size() > 0`,
+		// The limit is not absorbed by || and &&
+		"(" + nested + ") || true",
+		"false || (" + nested + ")",
+	}
+
+	for _, expr := range cases {
+		e, err := Parse(expr)
+		if err != nil {
+			t.Fatalf("%.40s: err: %v", expr, err)
+		}
+		_, err = e.EvalBool(testVars())
+		if err == nil || !strings.Contains(err.Error(), fmt.Sprintf("exceeded %d steps", maxEvalSteps)) {
+			t.Fatalf("%.40s: expected the step limit to be exceeded, got: %v", expr, err)
+		}
+	}
+
+	// Work just under the limit succeeds
+	e, err := Parse(`[1, 2, 3, 4, 5, 6, 7, 8, 9, 10].all(a, [1, 2, 3, 4, 5, 6, 7, 8, 9, 10].all(b, [1, 2, 3, 4, 5, 6, 7, 8, 9, 10].all(c, true)))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual, err := e.EvalBool(testVars()); err != nil || !actual {
+		t.Fatalf("expected true, got %t, err: %v", actual, err)
+	}
+}
+
+func TestExpression_Variables(t *testing.T) {
+	cases := map[string][]string{
+		`true`:                     []string{},
+		`a == b.c && d["e"].f(g)`:  []string{"a", "b", "d", "g"},
+		`has(a.b) ? c : [d, -e]`:   []string{"a", "c", "d", "e"},
+		`a.exists(x, x == b) && x`: []string{"a", "b", "x"},
+		`a.all(x, x.map(y, y + x + z).size() > 0)`:    []string{"a", "z"},
+		`size(a) > 0 && "v" in b && matches(c, "^d")`: []string{"a", "b", "c"},
+	}
+
+	for expr, expected := range cases {
+		e, err := Parse(expr)
+		if err != nil {
+			t.Fatalf("%s: err: %v", expr, err)
+		}
+		if actual := e.Variables(); !reflect.DeepEqual(actual, expected) {
+			t.Fatalf("%s: expected %v, got %v", expr, expected, actual)
+		}
+	}
+}
+
+// TestExpression_random parses and evaluates random token sequences, which
+// must fail with an error rather than panic or hang
+func TestExpression_random(t *testing.T) {
+	fragments := []string{
+		"request", "token", "now", "x", ".", "path", "data", "alt_names",
+		"[", "]", "(", ")", ",", "!", "-", "+", "*", "/", "%", "==", "!=",
+		"<", ">=", "&&", "||", "?", ":", "in", "has", "all", "exists",
+		"map", "size", "matches", "duration", "timestamp", "int", "string",
+		"0", "1", "-1", "1.5", "9223372036854775807", `"a"`, `"24h"`,
+		`"("`, "true", "false", "null",
+	}
+
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 20000; i++ {
+		var parts []string
+		for n := r.Intn(12) + 1; n > 0; n-- {
+			parts = append(parts, fragments[r.Intn(len(fragments))])
+		}
+		expr := strings.Join(parts, " ")
+
+		func() {
+			defer func() {
+				if v := recover(); v != nil {
+					t.Fatalf("%s: panic: %v", expr, v)
+				}
+			}()
+
+			e, err := Parse(expr)
+			if err != nil {
+				return
+			}
+			e.Eval(testVars())
+			e.Variables()
+		}()
+	}
+}
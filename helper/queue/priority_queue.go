@@ -0,0 +1,133 @@
+// Package queue provides a thread-safe priority queue of keyed items, where
+// the item with the lowest priority value is popped first. It is used to
+// schedule work, such as credential rotations, by using a Unix timestamp as
+// the priority, which Scheduler does for work that recurs.
+package queue
+
+import (
+	"container/heap"
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrEmpty is returned when popping from an empty queue
+	ErrEmpty = errors.New("queue is empty")
+
+	// ErrDuplicateItem is returned when pushing an item whose key is already
+	// in the queue
+	ErrDuplicateItem = errors.New("duplicate item")
+)
+
+// Item is an entry in a PriorityQueue
+type Item struct {
+	// Key uniquely identifies the item within the queue
+	Key string
+
+	// Value is an arbitrary value associated with the item
+	Value interface{}
+
+	// Priority determines the order in which items are popped; lower values
+	// are popped first
+	Priority int64
+
+	// index is maintained by the heap implementation
+	index int
+}
+
+// PriorityQueue is a min-heap of Items that can also be accessed by key
+type PriorityQueue struct {
+	data  itemHeap
+	items map[string]*Item
+	l     sync.RWMutex
+}
+
+// New returns an empty PriorityQueue
+func New() *PriorityQueue {
+	return &PriorityQueue{
+		items: make(map[string]*Item),
+	}
+}
+
+// Len returns the number of items in the queue
+func (pq *PriorityQueue) Len() int {
+	pq.l.RLock()
+	defer pq.l.RUnlock()
+
+	return pq.data.Len()
+}
+
+// Push adds an item to the queue. It returns ErrDuplicateItem if an item with
+// the same key is already queued.
+func (pq *PriorityQueue) Push(item *Item) error {
+	pq.l.Lock()
+	defer pq.l.Unlock()
+
+	if _, ok := pq.items[item.Key]; ok {
+		return ErrDuplicateItem
+	}
+
+	heap.Push(&pq.data, item)
+	pq.items[item.Key] = item
+	return nil
+}
+
+// Pop removes and returns the item with the lowest priority. It returns
+// ErrEmpty if there are no items in the queue.
+func (pq *PriorityQueue) Pop() (*Item, error) {
+	pq.l.Lock()
+	defer pq.l.Unlock()
+
+	if pq.data.Len() == 0 {
+		return nil, ErrEmpty
+	}
+
+	item := heap.Pop(&pq.data).(*Item)
+	delete(pq.items, item.Key)
+	return item, nil
+}
+
+// PopByKey removes and returns the item with the given key, or nil if no such
+// item is queued.
+func (pq *PriorityQueue) PopByKey(key string) *Item {
+	pq.l.Lock()
+	defer pq.l.Unlock()
+
+	item, ok := pq.items[key]
+	if !ok {
+		return nil
+	}
+
+	heap.Remove(&pq.data, item.index)
+	delete(pq.items, key)
+	return item
+}
+
+// itemHeap implements heap.Interface
+type itemHeap []*Item
+
+func (h itemHeap) Len() int { return len(h) }
+
+func (h itemHeap) Less(i, j int) bool { return h[i].Priority < h[j].Priority }
+
+func (h itemHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *itemHeap) Push(x interface{}) {
+	item := x.(*Item)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *itemHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	item.index = -1
+	*h = old[:n-1]
+	return item
+}
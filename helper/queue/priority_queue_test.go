@@ -0,0 +1,60 @@
+package queue
+
+import (
+	"testing"
+)
+
+func TestPriorityQueue(t *testing.T) {
+	pq := New()
+
+	if _, err := pq.Pop(); err != ErrEmpty {
+		t.Fatalf("expected ErrEmpty, got: %v", err)
+	}
+
+	for _, item := range []*Item{
+		{Key: "c", Priority: 30},
+		{Key: "a", Priority: 10},
+		{Key: "e", Priority: 50},
+		{Key: "b", Priority: 20},
+		{Key: "d", Priority: 40},
+	} {
+		if err := pq.Push(item); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := pq.Push(&Item{Key: "a", Priority: 5}); err != ErrDuplicateItem {
+		t.Fatalf("expected ErrDuplicateItem, got: %v", err)
+	}
+
+	if pq.Len() != 5 {
+		t.Fatalf("bad length: %d", pq.Len())
+	}
+
+	item := pq.PopByKey("d")
+	if item == nil || item.Priority != 40 {
+		t.Fatalf("bad item: %#v", item)
+	}
+	if item := pq.PopByKey("d"); item != nil {
+		t.Fatalf("expected no item, got: %#v", item)
+	}
+
+	for _, expected := range []string{"a", "b", "c", "e"} {
+		item, err := pq.Pop()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if item.Key != expected {
+			t.Fatalf("expected %s, got %s", expected, item.Key)
+		}
+	}
+
+	if pq.Len() != 0 {
+		t.Fatalf("bad length: %d", pq.Len())
+	}
+
+	// Popped keys can be pushed again
+	if err := pq.Push(&Item{Key: "a", Priority: 1}); err != nil {
+		t.Fatal(err)
+	}
+}
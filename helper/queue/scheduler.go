@@ -0,0 +1,96 @@
+package queue
+
+import (
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// Scheduler schedules recurring work, such as the rotation of credentials,
+// by key and the time it is next due. The work itself is done by the
+// functions passed to RunDue, which is meant to be called periodically.
+type Scheduler struct {
+	queue *PriorityQueue
+
+	// loaded is set once the load function given to RunDue has succeeded
+	loaded bool
+}
+
+// NewScheduler returns a Scheduler with nothing scheduled
+func NewScheduler() *Scheduler {
+	return &Scheduler{
+		queue: New(),
+	}
+}
+
+// Len returns the number of scheduled keys
+func (s *Scheduler) Len() int {
+	return s.queue.Len()
+}
+
+// Schedule schedules the key to be due at the given time, replacing any
+// existing entry for the key
+func (s *Scheduler) Schedule(key string, due time.Time) error {
+	s.queue.PopByKey(key)
+	return s.queue.Push(&Item{
+		Key:      key,
+		Priority: due.Unix(),
+	})
+}
+
+// Unschedule removes the key from the schedule
+func (s *Scheduler) Unschedule(key string) {
+	s.queue.PopByKey(key)
+}
+
+// RunDue calls run, in order, for every key that is due. Before the first
+// run, load is called to schedule the keys that were persisted; it is called
+// again on the next run if it fails. run is expected to schedule the key
+// again when it succeeds. Keys whose run fails stay scheduled and are retried
+// on the next call, and their errors are returned together.
+//
+// RunDue must not be called concurrently.
+func (s *Scheduler) RunDue(load func() error, run func(key string) error) error {
+	if !s.loaded {
+		if err := load(); err != nil {
+			return err
+		}
+		s.loaded = true
+	}
+
+	now := time.Now()
+
+	var errs *multierror.Error
+	var requeue []*Item
+	for {
+		item, err := s.queue.Pop()
+		if err == ErrEmpty {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if item.Priority > now.Unix() {
+			// The queue is ordered, so nothing else is due yet
+			requeue = append(requeue, item)
+			break
+		}
+
+		if err := run(item.Key); err != nil {
+			errs = multierror.Append(errs, err)
+
+			// Retry on the next run
+			requeue = append(requeue, item)
+		}
+	}
+
+	for _, item := range requeue {
+		// If the key was rescheduled in the meantime, keep the newer entry
+		if err := s.queue.Push(item); err != nil && err != ErrDuplicateItem {
+			errs = multierror.Append(errs, err)
+		}
+	}
+
+	return errs.ErrorOrNil()
+}
@@ -0,0 +1,65 @@
+package queue
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestScheduler_RunDue(t *testing.T) {
+	s := NewScheduler()
+
+	loads := 0
+	load := func() error {
+		loads++
+		if loads == 1 {
+			return errors.New("storage unavailable")
+		}
+		past := time.Now().Add(-time.Minute)
+		for _, key := range []string{"b", "a", "failing"} {
+			if err := s.Schedule(key, past); err != nil {
+				return err
+			}
+		}
+		return s.Schedule("later", time.Now().Add(time.Hour))
+	}
+
+	var ran []string
+	run := func(key string) error {
+		ran = append(ran, key)
+		if key == "failing" {
+			return errors.New("failed")
+		}
+		return s.Schedule(key, time.Now().Add(time.Hour))
+	}
+
+	// A failed load is retried on the next run
+	if err := s.RunDue(load, run); err == nil {
+		t.Fatal("expected the load error")
+	}
+	if err := s.RunDue(load, run); err == nil {
+		t.Fatal("expected the run error")
+	}
+	if loads != 2 {
+		t.Fatalf("bad number of loads: %d", loads)
+	}
+	ran = nil
+
+	// Only the failed key is still due
+	if err := s.RunDue(load, run); err == nil {
+		t.Fatal("expected the run error")
+	}
+	if loads != 2 || !reflect.DeepEqual(ran, []string{"failing"}) {
+		t.Fatalf("bad: loads %d, ran %v", loads, ran)
+	}
+
+	s.Unschedule("failing")
+	ran = nil
+	if err := s.RunDue(load, run); err != nil {
+		t.Fatal(err)
+	}
+	if len(ran) != 0 || s.Len() != 3 {
+		t.Fatalf("bad: ran %v, %d scheduled", ran, s.Len())
+	}
+}
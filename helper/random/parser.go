@@ -0,0 +1,110 @@
+package random
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/ast"
+)
+
+// ParsePolicy parses a password policy into a StringGenerator. Policies are
+// written in HCL and set the length of the generated strings and one or more
+// charset rules:
+//
+//   length = 20
+//
+//   rule "charset" {
+//     charset   = "abcdefghijklmnopqrstuvwxyz"
+//     min-chars = 1
+//   }
+//
+//   rule "charset" {
+//     charset   = "0123456789"
+//     min-chars = 2
+//   }
+func ParsePolicy(raw string) (*StringGenerator, error) {
+	root, err := hcl.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse password policy: %s", err)
+	}
+
+	list, ok := root.Node.(*ast.ObjectList)
+	if !ok {
+		return nil, fmt.Errorf("failed to parse password policy: does not contain a root object")
+	}
+
+	if err := checkHCLKeys(list, []string{"length", "rule"}); err != nil {
+		return nil, fmt.Errorf("failed to parse password policy: %s", err)
+	}
+
+	var policy struct {
+		Length int `hcl:"length"`
+	}
+	if err := hcl.DecodeObject(&policy, list); err != nil {
+		return nil, fmt.Errorf("failed to parse password policy: %s", err)
+	}
+
+	var rules []CharsetRule
+	for _, item := range list.Filter("rule").Items {
+		ruleType := ""
+		if len(item.Keys) > 0 {
+			ruleType = item.Keys[0].Token.Value().(string)
+		}
+		if ruleType != "charset" {
+			return nil, fmt.Errorf("failed to parse password policy: unknown rule type %q", ruleType)
+		}
+
+		if err := checkHCLKeys(item.Val, []string{"charset", "min-chars"}); err != nil {
+			return nil, fmt.Errorf("failed to parse password policy: rule %q: %s", ruleType, err)
+		}
+
+		var rule struct {
+			Charset  string `hcl:"charset"`
+			MinChars int    `hcl:"min-chars"`
+		}
+		if err := hcl.DecodeObject(&rule, item.Val); err != nil {
+			return nil, fmt.Errorf("failed to parse password policy: rule %q: %s", ruleType, err)
+		}
+
+		rules = append(rules, CharsetRule{
+			Charset:  []rune(rule.Charset),
+			MinChars: rule.MinChars,
+		})
+	}
+
+	g, err := NewStringGenerator(policy.Length, rules)
+	if err != nil {
+		return nil, fmt.Errorf("invalid password policy: %s", err)
+	}
+
+	return g, nil
+}
+
+func checkHCLKeys(node ast.Node, valid []string) error {
+	var list *ast.ObjectList
+	switch n := node.(type) {
+	case *ast.ObjectList:
+		list = n
+	case *ast.ObjectType:
+		list = n.List
+	default:
+		return fmt.Errorf("cannot check HCL keys of type %T", n)
+	}
+
+	validMap := make(map[string]struct{}, len(valid))
+	for _, v := range valid {
+		validMap[v] = struct{}{}
+	}
+
+	var result error
+	for _, item := range list.Items {
+		key := item.Keys[0].Token.Value().(string)
+		if _, ok := validMap[key]; !ok {
+			result = multierror.Append(result, fmt.Errorf(
+				"invalid key '%s' on line %d", key, item.Assign.Line))
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,163 @@
+package random
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+)
+
+const (
+	// LowercaseCharset is the set of lowercase ASCII letters
+	LowercaseCharset = "abcdefghijklmnopqrstuvwxyz"
+	// UppercaseCharset is the set of uppercase ASCII letters
+	UppercaseCharset = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	// NumericCharset is the set of ASCII digits
+	NumericCharset = "0123456789"
+	// AlphaNumericCharset is the set of ASCII letters and digits
+	AlphaNumericCharset = LowercaseCharset + UppercaseCharset + NumericCharset
+
+	// maxLength caps the length of generated strings
+	maxLength = 1024
+)
+
+// CharsetRule requires a generated string to contain at least MinChars
+// characters from Charset. The characters of every rule make up the set of
+// characters a string is generated from.
+type CharsetRule struct {
+	Charset  []rune
+	MinChars int
+}
+
+// StringGenerator generates random strings of a fixed length that satisfy a
+// set of charset rules.
+type StringGenerator struct {
+	Length int
+	Rules  []CharsetRule
+
+	// charset is the union of the rules' charsets
+	charset []rune
+}
+
+// NewStringGenerator returns a generator for strings of the given length
+// satisfying the rules.
+func NewStringGenerator(length int, rules []CharsetRule) (*StringGenerator, error) {
+	g := &StringGenerator{
+		Length: length,
+		Rules:  rules,
+	}
+	if err := g.validate(); err != nil {
+		return nil, err
+	}
+
+	return g, nil
+}
+
+func (g *StringGenerator) validate() error {
+	if g.Length <= 0 {
+		return errors.New("length must be greater than zero")
+	}
+	if g.Length > maxLength {
+		return fmt.Errorf("length must not be greater than %d", maxLength)
+	}
+	if len(g.Rules) == 0 {
+		return errors.New("at least one charset rule is required")
+	}
+
+	g.charset = nil
+	seen := make(map[rune]struct{})
+	var minChars int
+	for i, rule := range g.Rules {
+		if len(rule.Charset) == 0 {
+			return fmt.Errorf("rule %d: charset must not be empty", i)
+		}
+		if rule.MinChars < 0 {
+			return fmt.Errorf("rule %d: min-chars must not be negative", i)
+		}
+		minChars += rule.MinChars
+
+		for _, r := range rule.Charset {
+			if _, ok := seen[r]; !ok {
+				seen[r] = struct{}{}
+				g.charset = append(g.charset, r)
+			}
+		}
+	}
+	if minChars > g.Length {
+		return fmt.Errorf("the rules require %d characters, which is more than the length of %d", minChars, g.Length)
+	}
+
+	sort.Slice(g.charset, func(i, j int) bool { return g.charset[i] < g.charset[j] })
+
+	return nil
+}
+
+// Generate returns a random string that satisfies the generator's rules.
+func (g *StringGenerator) Generate() (string, error) {
+	if g.charset == nil {
+		if err := g.validate(); err != nil {
+			return "", err
+		}
+	}
+
+	// Satisfy each rule first, then fill the rest from the full charset and
+	// shuffle so that the required characters are not all at the start
+	result := make([]rune, 0, g.Length)
+	for _, rule := range g.Rules {
+		for i := 0; i < rule.MinChars; i++ {
+			r, err := randomRune(rule.Charset)
+			if err != nil {
+				return "", err
+			}
+			result = append(result, r)
+		}
+	}
+	for len(result) < g.Length {
+		r, err := randomRune(g.charset)
+		if err != nil {
+			return "", err
+		}
+		result = append(result, r)
+	}
+
+	for i := len(result) - 1; i > 0; i-- {
+		j, err := randomInt(i+1)
+		if err != nil {
+			return "", err
+		}
+		result[i], result[j] = result[j], result[i]
+	}
+
+	return string(result), nil
+}
+
+func randomRune(charset []rune) (rune, error) {
+	i, err := randomInt(len(charset))
+	if err != nil {
+		return 0, err
+	}
+	return charset[i], nil
+}
+
+// randomInt returns a uniformly distributed integer in [0, max)
+func randomInt(max int) (int, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(max)))
+	if err != nil {
+		return 0, err
+	}
+	return int(n.Int64()), nil
+}
+
+// AlphaNumeric returns a random string of the given length made up of ASCII
+// letters and digits.
+func AlphaNumeric(length int) (string, error) {
+	g, err := NewStringGenerator(length, []CharsetRule{
+		{Charset: []rune(AlphaNumericCharset)},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return g.Generate()
+}
@@ -0,0 +1,111 @@
+package random
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStringGenerator_Generate(t *testing.T) {
+	g, err := NewStringGenerator(12, []CharsetRule{
+		{Charset: []rune(LowercaseCharset), MinChars: 2},
+		{Charset: []rune(UppercaseCharset), MinChars: 3},
+		{Charset: []rune(NumericCharset), MinChars: 4},
+		{Charset: []rune("!@#")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count := func(s, charset string) int {
+		var n int
+		for _, r := range s {
+			if strings.ContainsRune(charset, r) {
+				n++
+			}
+		}
+		return n
+	}
+
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		s, err := g.Generate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(s) != 12 {
+			t.Fatalf("bad length: %q", s)
+		}
+		if count(s, LowercaseCharset) < 2 || count(s, UppercaseCharset) < 3 || count(s, NumericCharset) < 4 {
+			t.Fatalf("rules not satisfied: %q", s)
+		}
+		if count(s, AlphaNumericCharset+"!@#") != 12 {
+			t.Fatalf("unexpected characters: %q", s)
+		}
+		seen[s] = true
+	}
+	if len(seen) < 95 {
+		t.Fatalf("expected distinct strings, got %d of 100", len(seen))
+	}
+}
+
+func TestNewStringGenerator_Invalid(t *testing.T) {
+	cases := map[string]struct {
+		length int
+		rules  []CharsetRule
+	}{
+		"zero length": {0, []CharsetRule{{Charset: []rune("a")}}},
+		"too long":    {maxLength + 1, []CharsetRule{{Charset: []rune("a")}}},
+		"no rules":    {10, nil},
+		"empty rule":  {10, []CharsetRule{{MinChars: 1}}},
+		"negative":    {10, []CharsetRule{{Charset: []rune("a"), MinChars: -1}}},
+		"too many": {3, []CharsetRule{
+			{Charset: []rune("a"), MinChars: 2},
+			{Charset: []rune("b"), MinChars: 2},
+		}},
+	}
+
+	for name, tc := range cases {
+		if _, err := NewStringGenerator(tc.length, tc.rules); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	g, err := ParsePolicy(`
+length = 16
+
+rule "charset" {
+  charset   = "abcdefghijklmnopqrstuvwxyz"
+  min-chars = 1
+}
+
+rule "charset" {
+  charset   = "0123456789"
+  min-chars = 2
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if g.Length != 16 || len(g.Rules) != 2 {
+		t.Fatalf("bad generator: %#v", g)
+	}
+	if string(g.Rules[1].Charset) != NumericCharset || g.Rules[1].MinChars != 2 {
+		t.Fatalf("bad rule: %#v", g.Rules[1])
+	}
+
+	for name, raw := range map[string]string{
+		"syntax":       `length = `,
+		"unknown key":  "length = 10\nfoo = 1\nrule \"charset\" { charset = \"a\" }",
+		"unknown rule": "length = 10\nrule \"regex\" { charset = \"a\" }",
+		"rule key":     "length = 10\nrule \"charset\" { charset = \"a\"\nmax-chars = 1 }",
+		"no rules":     `length = 10`,
+		"infeasible":   "length = 1\nrule \"charset\" { charset = \"a\"\nmin-chars = 2 }",
+	} {
+		if _, err := ParsePolicy(raw); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
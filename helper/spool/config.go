@@ -0,0 +1,88 @@
+package spool
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/vault/helper/parseutil"
+)
+
+const (
+	// DefaultMaxBytes is the spool size used when spool_max_bytes is not set
+	DefaultMaxBytes = 64 * 1024 * 1024
+)
+
+// Config holds the spool and batching options of an audit device
+type Config struct {
+	// Dir is the directory the spool is persisted in
+	Dir string
+
+	// MaxBytes is the size of the spool
+	MaxBytes int64
+
+	// WriteTimeout is how long to wait for space in a full spool before
+	// failing the request
+	WriteTimeout time.Duration
+
+	Sender SenderConfig
+}
+
+// ParseConfig parses the spool_dir, spool_max_bytes, write_timeout,
+// batch_size, batch_interval and max_backoff options of an audit device.
+// spool_dir is required so that entries that were accepted but not yet
+// delivered are not lost if Vault stops.
+func ParseConfig(config map[string]string) (*Config, error) {
+	result := &Config{
+		Dir:      config["spool_dir"],
+		MaxBytes: DefaultMaxBytes,
+	}
+	if result.Dir == "" {
+		return nil, fmt.Errorf("spool_dir is required")
+	}
+
+	var err error
+	if raw, ok := config["spool_max_bytes"]; ok {
+		result.MaxBytes, err = strconv.ParseInt(raw, 10, 64)
+		if err != nil || result.MaxBytes < 1 {
+			return nil, fmt.Errorf("spool_max_bytes must be a positive integer")
+		}
+	}
+
+	writeTimeout, ok := config["write_timeout"]
+	if !ok {
+		writeTimeout = "2s"
+	}
+	result.WriteTimeout, err = parseutil.ParseDurationSecond(writeTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	result.Sender.BatchSize = 100
+	if raw, ok := config["batch_size"]; ok {
+		result.Sender.BatchSize, err = strconv.Atoi(raw)
+		if err != nil || result.Sender.BatchSize < 1 {
+			return nil, fmt.Errorf("batch_size must be a positive integer")
+		}
+	}
+
+	batchInterval, ok := config["batch_interval"]
+	if !ok {
+		batchInterval = "1s"
+	}
+	result.Sender.BatchInterval, err = parseutil.ParseDurationSecond(batchInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	maxBackoff, ok := config["max_backoff"]
+	if !ok {
+		maxBackoff = "30s"
+	}
+	result.Sender.MaxBackoff, err = parseutil.ParseDurationSecond(maxBackoff)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
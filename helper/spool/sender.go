@@ -0,0 +1,157 @@
+package spool
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SendFunc delivers a batch of records, returning an error if the batch
+// should be retried
+type SendFunc func(batch [][]byte) error
+
+// SenderConfig configures a Sender
+type SenderConfig struct {
+	// BatchSize is the largest number of records delivered at once
+	BatchSize int
+
+	// BatchInterval is how long records are held waiting for a batch to
+	// fill up before the partial batch is delivered
+	BatchInterval time.Duration
+
+	// MinBackoff and MaxBackoff bound the exponential backoff between
+	// retries of a failed batch
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// Sender delivers the records of a spool in batches from a background
+// goroutine. Batches that fail are retried with exponential backoff until
+// they are delivered or the sender is stopped, and stay in the spool until
+// then.
+type Sender struct {
+	spool  *Spool
+	send   SendFunc
+	config SenderConfig
+
+	lastErrLock sync.RWMutex
+	lastErr     error
+
+	stopCh chan struct{}
+	doneCh chan struct{}
+}
+
+// NewSender starts delivering the records of the spool using send
+func NewSender(s *Spool, send SendFunc, config SenderConfig) *Sender {
+	if config.BatchSize <= 0 {
+		config.BatchSize = 1
+	}
+	if config.MinBackoff <= 0 {
+		config.MinBackoff = 100 * time.Millisecond
+	}
+	if config.MaxBackoff < config.MinBackoff {
+		config.MaxBackoff = config.MinBackoff
+	}
+
+	sender := &Sender{
+		spool:  s,
+		send:   send,
+		config: config,
+		stopCh: make(chan struct{}),
+		doneCh: make(chan struct{}),
+	}
+	go sender.run()
+	return sender
+}
+
+// LastError returns the error of the most recent delivery attempt, or nil
+// if it succeeded
+func (s *Sender) LastError() error {
+	s.lastErrLock.RLock()
+	defer s.lastErrLock.RUnlock()
+	return s.lastErr
+}
+
+// Stop stops delivering records and waits for an attempt in progress to
+// finish
+func (s *Sender) Stop() {
+	select {
+	case <-s.stopCh:
+	default:
+		close(s.stopCh)
+	}
+	<-s.doneCh
+}
+
+func (s *Sender) run() {
+	defer close(s.doneCh)
+
+	backoff := s.config.MinBackoff
+	for {
+		if !s.waitForBatch() {
+			return
+		}
+
+		batch := s.spool.Peek(s.config.BatchSize)
+		err := s.send(batch)
+		if err == nil {
+			// The batch is removed from memory even if the removal could
+			// not be persisted, so it is not sent again unless Vault is
+			// restarted; the error is kept to be reported, and the next
+			// batches are sent after backing off as if delivery failed
+			err = s.spool.Remove(len(batch))
+			if err == ErrClosed {
+				return
+			}
+			if err != nil {
+				err = fmt.Errorf("failed to remove delivered records from the spool: %v", err)
+			}
+		}
+
+		s.lastErrLock.Lock()
+		s.lastErr = err
+		s.lastErrLock.Unlock()
+
+		if err == nil {
+			backoff = s.config.MinBackoff
+			continue
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-s.stopCh:
+			return
+		}
+		backoff *= 2
+		if backoff > s.config.MaxBackoff {
+			backoff = s.config.MaxBackoff
+		}
+	}
+}
+
+// waitForBatch waits until a full batch is queued, or until some records
+// have been queued for the batch interval. It returns false if the sender
+// was stopped.
+func (s *Sender) waitForBatch() bool {
+	var timer <-chan time.Time
+	for {
+		n := s.spool.Len()
+		if n >= s.config.BatchSize {
+			return true
+		}
+		if n > 0 && timer == nil {
+			if s.config.BatchInterval <= 0 {
+				return true
+			}
+			timer = time.After(s.config.BatchInterval)
+		}
+
+		select {
+		case <-s.spool.added:
+		case <-timer:
+			return true
+		case <-s.stopCh:
+			return false
+		}
+	}
+}
@@ -0,0 +1,405 @@
+// Package spool provides a bounded FIFO queue of records that is optionally
+// persisted to disk, along with a Sender that delivers the queued records in
+// batches. It lets audit devices that write to remote collectors accept
+// entries without waiting on the network, so that short collector outages do
+// not fail requests.
+package spool
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	dataFile   = "spool.dat"
+	offsetFile = "spool.offset"
+
+	// frameOverhead is the size of the length prefix written before each
+	// record in the data file
+	frameOverhead = 4
+)
+
+var (
+	// ErrFull is returned when a record could not be queued because the
+	// spool stayed full for the whole timeout
+	ErrFull = errors.New("spool is full")
+
+	// ErrClosed is returned when appending to a closed spool
+	ErrClosed = errors.New("spool is closed")
+)
+
+var (
+	// dirsInUse holds the absolute paths of the directories of the open
+	// spools, since two spools appending to the same data file would
+	// corrupt it
+	dirsInUse     = make(map[string]struct{})
+	dirsInUseLock sync.Mutex
+)
+
+// Spool is a bounded FIFO queue of records. Records are kept in memory and,
+// when the spool has a directory, appended to a data file within it so that
+// undelivered records survive a restart. Delivered records are removed by
+// advancing a read offset that is persisted next to the data file.
+type Spool struct {
+	sync.Mutex
+
+	dir      string
+	absDir   string
+	maxBytes int64
+
+	data   *os.File
+	offset int64
+
+	records [][]byte
+	pending int64
+
+	// added is signalled when a record is appended, and space is closed
+	// and replaced whenever records are removed
+	added  chan struct{}
+	space  chan struct{}
+	closed bool
+}
+
+// New returns a spool holding at most maxBytes of records. If dir is empty
+// the records are only held in memory; otherwise the directory is created if
+// needed and any records left in it by a previous spool are loaded. A
+// directory can only be used by one open spool at a time.
+func New(dir string, maxBytes int64) (*Spool, error) {
+	if maxBytes <= 0 {
+		return nil, fmt.Errorf("spool size must be positive")
+	}
+
+	s := &Spool{
+		dir:      dir,
+		maxBytes: maxBytes,
+		added:    make(chan struct{}, 1),
+		space:    make(chan struct{}),
+	}
+	if dir == "" {
+		return s, nil
+	}
+
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	dirsInUseLock.Lock()
+	if _, ok := dirsInUse[absDir]; ok {
+		dirsInUseLock.Unlock()
+		return nil, fmt.Errorf("spool directory %q is already in use by another audit device", dir)
+	}
+	dirsInUse[absDir] = struct{}{}
+	dirsInUseLock.Unlock()
+	s.absDir = absDir
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		s.releaseDir()
+		return nil, err
+	}
+	if err := s.load(); err != nil {
+		s.releaseDir()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// releaseDir allows the directory of the spool to be used by another spool
+func (s *Spool) releaseDir() {
+	if s.absDir == "" {
+		return
+	}
+	dirsInUseLock.Lock()
+	delete(dirsInUse, s.absDir)
+	dirsInUseLock.Unlock()
+	s.absDir = ""
+}
+
+// load reads the records that were not yet delivered from the data file and
+// opens it for appending
+func (s *Spool) load() error {
+	offsetRaw, err := ioutil.ReadFile(filepath.Join(s.dir, offsetFile))
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return err
+	default:
+		s.offset, err = strconv.ParseInt(strings.TrimSpace(string(offsetRaw)), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid spool offset: %v", err)
+		}
+	}
+
+	f, err := os.OpenFile(filepath.Join(s.dir, dataFile), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if s.offset > info.Size() {
+		s.offset = info.Size()
+	}
+
+	if _, err := f.Seek(s.offset, io.SeekStart); err != nil {
+		f.Close()
+		return err
+	}
+
+	// A record that was only partially written when the previous process
+	// stopped is discarded by truncating the file after the last complete
+	// record
+	end := s.offset
+	r := bufio.NewReader(f)
+	for {
+		var header [frameOverhead]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			break
+		}
+		record := make([]byte, binary.BigEndian.Uint32(header[:]))
+		if _, err := io.ReadFull(r, record); err != nil {
+			break
+		}
+		s.records = append(s.records, record)
+		s.pending += int64(frameOverhead + len(record))
+		end += int64(frameOverhead + len(record))
+	}
+
+	if err := f.Truncate(end); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Seek(end, io.SeekStart); err != nil {
+		f.Close()
+		return err
+	}
+
+	s.data = f
+	return nil
+}
+
+// Append queues a record. If the spool is full it waits up to timeout for
+// records to be removed, and returns ErrFull if they are not. When the spool
+// has a directory the record is synced to disk before Append returns.
+func (s *Spool) Append(record []byte, timeout time.Duration) error {
+	size := int64(frameOverhead + len(record))
+	if size > s.maxBytes {
+		return fmt.Errorf("record of %d bytes exceeds the spool size", len(record))
+	}
+
+	var timer <-chan time.Time
+	for {
+		s.Lock()
+		if s.closed {
+			s.Unlock()
+			return ErrClosed
+		}
+		if s.pending+size <= s.maxBytes {
+			break
+		}
+		space := s.space
+		s.Unlock()
+
+		if timer == nil {
+			timer = time.After(timeout)
+		}
+		select {
+		case <-space:
+		case <-timer:
+			return ErrFull
+		}
+	}
+	defer s.Unlock()
+
+	if s.data != nil {
+		frame := make([]byte, size)
+		binary.BigEndian.PutUint32(frame, uint32(len(record)))
+		copy(frame[frameOverhead:], record)
+		if err := s.writeFrame(frame); err != nil {
+			return err
+		}
+	}
+
+	// Keep a copy since the caller may reuse the buffer
+	s.records = append(s.records, append([]byte(nil), record...))
+	s.pending += size
+
+	select {
+	case s.added <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// writeFrame appends a frame to the data file and syncs it. If either fails
+// the file is truncated back to its previous end, so that a frame that was
+// not acknowledged is neither delivered later nor followed by other frames.
+// The lock must be held.
+func (s *Spool) writeFrame(frame []byte) error {
+	end, err := s.data.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.data.Write(frame)
+	if err == nil {
+		err = s.data.Sync()
+	}
+	if err != nil {
+		s.data.Truncate(end)
+		s.data.Seek(end, io.SeekStart)
+		return err
+	}
+
+	return nil
+}
+
+// Peek returns up to n of the oldest records without removing them
+func (s *Spool) Peek(n int) [][]byte {
+	s.Lock()
+	defer s.Unlock()
+
+	if n > len(s.records) {
+		n = len(s.records)
+	}
+	out := make([][]byte, n)
+	copy(out, s.records)
+	return out
+}
+
+// Len returns the number of queued records
+func (s *Spool) Len() int {
+	s.Lock()
+	defer s.Unlock()
+	return len(s.records)
+}
+
+// Remove removes the n oldest records, typically after they were returned
+// by Peek and delivered. The records are removed from memory even if
+// persisting the removal fails, in which case they are loaded again by the
+// next spool opened on the directory.
+func (s *Spool) Remove(n int) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.closed {
+		return ErrClosed
+	}
+	if n > len(s.records) {
+		n = len(s.records)
+	}
+	if n == 0 {
+		return nil
+	}
+
+	var removed int64
+	for _, record := range s.records[:n] {
+		removed += int64(frameOverhead + len(record))
+	}
+	s.records = append([][]byte(nil), s.records[n:]...)
+	s.pending -= removed
+
+	close(s.space)
+	s.space = make(chan struct{})
+
+	if s.data == nil {
+		return nil
+	}
+
+	s.offset += removed
+	switch {
+	case len(s.records) == 0:
+		// Everything was delivered, so start the file over
+		if err := s.data.Truncate(0); err != nil {
+			return err
+		}
+		if _, err := s.data.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		s.offset = 0
+
+	case s.offset > s.maxBytes:
+		// The collector never fully caught up, so rewrite the file with
+		// only the pending records to keep it from growing without bound
+		if err := s.compact(); err != nil {
+			return err
+		}
+	}
+
+	return ioutil.WriteFile(filepath.Join(s.dir, offsetFile), []byte(strconv.FormatInt(s.offset, 10)), 0600)
+}
+
+// compact replaces the data file with one holding only the pending records.
+// The lock must be held.
+func (s *Spool) compact() error {
+	tmpPath := filepath.Join(s.dir, dataFile+".tmp")
+	tmp, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(tmp)
+	for _, record := range s.records {
+		var header [frameOverhead]byte
+		binary.BigEndian.PutUint32(header[:], uint32(len(record)))
+		w.Write(header[:])
+		w.Write(record)
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	// Persist a zero offset before swapping the files so that a crash in
+	// between can only cause records to be delivered twice, never lost
+	if err := ioutil.WriteFile(filepath.Join(s.dir, offsetFile), []byte("0"), 0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := os.Rename(tmpPath, filepath.Join(s.dir, dataFile)); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	s.data.Close()
+	s.data = tmp
+	s.offset = 0
+	return nil
+}
+
+// Close closes the data file. Pending records stay in the spool directory
+// and are loaded by the next spool opened on it.
+func (s *Spool) Close() error {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	close(s.space)
+	s.releaseDir()
+
+	if s.data != nil {
+		return s.data.Close()
+	}
+	return nil
+}
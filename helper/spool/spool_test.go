@@ -0,0 +1,352 @@
+package spool
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSpool_memory(t *testing.T) {
+	s, err := New("", 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	for _, record := range []string{"one", "two", "three"} {
+		if err := s.Append([]byte(record), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 4 bytes of framing per record leave no room for another
+	if err := s.Append([]byte("four"), 10*time.Millisecond); err != ErrFull {
+		t.Fatalf("expected ErrFull, got: %v", err)
+	}
+
+	if got := s.Peek(2); !reflect.DeepEqual(got, [][]byte{[]byte("one"), []byte("two")}) {
+		t.Fatalf("bad: %q", got)
+	}
+	if err := s.Remove(2); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Peek(5); !reflect.DeepEqual(got, [][]byte{[]byte("three")}) {
+		t.Fatalf("bad: %q", got)
+	}
+
+	if _, err := New("", 0); err == nil {
+		t.Fatal("expected an error for an empty spool")
+	}
+	if err := s.Append(make([]byte, 21), 0); err == nil {
+		t.Fatal("expected an error for a record larger than the spool")
+	}
+}
+
+func TestSpool_appendWaitsForSpace(t *testing.T) {
+	s, err := New("", 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.Append([]byte("one"), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		s.Remove(1)
+	}()
+
+	if err := s.Append([]byte("two"), 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Peek(5); !reflect.DeepEqual(got, [][]byte{[]byte("two")}) {
+		t.Fatalf("bad: %q", got)
+	}
+}
+
+func TestSpool_persistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := New(dir, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, record := range []string{"one", "two", "three"} {
+		if err := s.Append([]byte(record), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Remove(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a record that was cut off by a crash
+	f, err := os.OpenFile(filepath.Join(dir, dataFile), os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{0, 0, 0, 9, 'p', 'a'})
+	f.Close()
+
+	s, err = New(dir, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Peek(5); !reflect.DeepEqual(got, [][]byte{[]byte("two"), []byte("three")}) {
+		t.Fatalf("bad: %q", got)
+	}
+
+	if err := s.Append([]byte("four"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Remove(3); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Append([]byte("five"), 0); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	s, err = New(dir, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if got := s.Peek(5); !reflect.DeepEqual(got, [][]byte{[]byte("five")}) {
+		t.Fatalf("bad: %q", got)
+	}
+}
+
+func TestSpool_compaction(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := New(dir, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Keep one record pending at all times so that the data file is never
+	// truncated, and only compaction bounds its size
+	if err := s.Append([]byte("record-0"), 0); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < 20; i++ {
+		if err := s.Append([]byte("record-"+string('a'+rune(i))), 0); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Remove(1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	info, err := os.Stat(filepath.Join(dir, dataFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() > 2*32 {
+		t.Fatalf("data file was not compacted, size is %d", info.Size())
+	}
+	s.Close()
+
+	s, err = New(dir, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if got := s.Peek(5); !reflect.DeepEqual(got, [][]byte{[]byte("record-t")}) {
+		t.Fatalf("bad: %q", got)
+	}
+}
+
+func TestSpool_dirInUse(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := New(dir, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(filepath.Join(dir, "."), 1024); err == nil {
+		t.Fatal("expected an error for a directory that is in use")
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	s, err = New(dir, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+}
+
+func TestParseConfig(t *testing.T) {
+	if _, err := ParseConfig(map[string]string{}); err == nil {
+		t.Fatal("expected an error without spool_dir")
+	}
+
+	config, err := ParseConfig(map[string]string{"spool_dir": "/var/spool/vault"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Dir != "/var/spool/vault" || config.MaxBytes != DefaultMaxBytes || config.Sender.BatchSize != 100 {
+		t.Fatalf("bad: %#v", config)
+	}
+}
+
+func TestSender(t *testing.T) {
+	s, err := New("", 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	var lock sync.Mutex
+	var batches [][]string
+	fail := true
+	sender := NewSender(s, func(batch [][]byte) error {
+		lock.Lock()
+		defer lock.Unlock()
+		if fail {
+			fail = false
+			return errors.New("collector unavailable")
+		}
+		var values []string
+		for _, record := range batch {
+			values = append(values, string(record))
+		}
+		batches = append(batches, values)
+		return nil
+	}, SenderConfig{
+		BatchSize:     2,
+		BatchInterval: 50 * time.Millisecond,
+		MinBackoff:    10 * time.Millisecond,
+	})
+	defer sender.Stop()
+
+	for _, record := range []string{"one", "two", "three"} {
+		if err := s.Append([]byte(record), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for s.Len() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	expected := [][]string{{"one", "two"}, {"three"}}
+	if !reflect.DeepEqual(batches, expected) {
+		t.Fatalf("bad: expected %v, got %v", expected, batches)
+	}
+	if err := sender.LastError(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestSender_removeError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := New(dir, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// The offset cannot be written while a directory is in its place
+	offsetPath := filepath.Join(dir, offsetFile)
+	if err := os.Mkdir(offsetPath, 0700); err != nil {
+		t.Fatal(err)
+	}
+	for _, record := range []string{"one", "two", "three"} {
+		if err := s.Append([]byte(record), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var lock sync.Mutex
+	var sent []string
+	sender := NewSender(s, func(batch [][]byte) error {
+		lock.Lock()
+		defer lock.Unlock()
+		for _, record := range batch {
+			sent = append(sent, string(record))
+		}
+		return nil
+	}, SenderConfig{
+		BatchSize:  2,
+		MinBackoff: 10 * time.Millisecond,
+	})
+	defer sender.Stop()
+
+	waitForSent := func(n int) {
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			lock.Lock()
+			done := len(sent) >= n
+			lock.Unlock()
+			if done {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("expected %d records to be sent", n)
+	}
+
+	// Delivery continues after a removal fails, and the error is reported
+	waitForSent(3)
+	err = sender.LastError()
+	if err == nil || !strings.Contains(err.Error(), "failed to remove delivered records") {
+		t.Fatalf("expected a removal error, got: %v", err)
+	}
+
+	// The error is cleared once a removal succeeds again
+	if err := os.Remove(offsetPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Append([]byte("four"), 0); err != nil {
+		t.Fatal(err)
+	}
+	waitForSent(4)
+	deadline := time.Now().Add(5 * time.Second)
+	for sender.LastError() != nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := sender.LastError(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	if expected := []string{"one", "two", "three", "four"}; !reflect.DeepEqual(sent, expected) {
+		t.Fatalf("bad: expected %v, got %v", expected, sent)
+	}
+}
@@ -0,0 +1,140 @@
+// Package template renders strings from Go text templates extended with
+// functions to shorten, transform and randomize values, such as the names of
+// generated database users.
+package template
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	uuid "github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/helper/random"
+)
+
+// StringTemplate renders a string from a template. The following functions
+// are available in addition to the builtin ones:
+//
+//   random N              a random string of N letters and digits
+//   truncate N S          the first N characters of S
+//   truncate_sha256 N S   S if it has at most N characters, otherwise its
+//                         first N-8 characters followed by the first 8 hex
+//                         characters of its SHA256 hash
+//   uppercase S, lowercase S
+//   replace OLD NEW S     S with every OLD replaced by NEW
+//   sha256 S              the hex encoded SHA256 hash of S
+//   base64 S              the base64 encoding of S
+//   unix_time             the current Unix time in seconds
+//   unix_time_millis      the current Unix time in milliseconds
+//   timestamp LAYOUT      the current UTC time in the given Go time layout
+//   uuid                  a random UUID
+//
+// For example:
+//
+//   v-{{.DisplayName | truncate 8}}-{{.RoleName | truncate 8}}-{{random 20}}-{{unix_time}}
+type StringTemplate struct {
+	raw  string
+	tmpl *template.Template
+}
+
+// NewTemplate parses the template
+func NewTemplate(raw string) (*StringTemplate, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, fmt.Errorf("template must not be empty")
+	}
+
+	tmpl, err := template.New("template").
+		Option("missingkey=error").
+		Funcs(funcs).
+		Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %s", err)
+	}
+
+	return &StringTemplate{
+		raw:  raw,
+		tmpl: tmpl,
+	}, nil
+}
+
+// String returns the template's source
+func (t *StringTemplate) String() string {
+	return t.raw
+}
+
+// Generate renders the template with the given data. Leading and trailing
+// whitespace is removed from the result.
+func (t *StringTemplate) Generate(data interface{}) (string, error) {
+	var buf bytes.Buffer
+	if err := t.tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render template: %s", err)
+	}
+
+	return strings.TrimSpace(buf.String()), nil
+}
+
+var funcs = template.FuncMap{
+	"random":           random.AlphaNumeric,
+	"truncate":         truncate,
+	"truncate_sha256":  truncateSHA256,
+	"uppercase":        strings.ToUpper,
+	"lowercase":        strings.ToLower,
+	"replace":          replace,
+	"sha256":           hashSHA256,
+	"base64":           encodeBase64,
+	"unix_time":        unixTime,
+	"unix_time_millis": unixTimeMillis,
+	"timestamp":        timestamp,
+	"uuid":             uuid.GenerateUUID,
+}
+
+func truncate(maxLen int, s string) (string, error) {
+	if maxLen < 0 {
+		return "", fmt.Errorf("truncate: length must not be negative")
+	}
+	if len(s) > maxLen {
+		return s[:maxLen], nil
+	}
+	return s, nil
+}
+
+func truncateSHA256(maxLen int, s string) (string, error) {
+	if maxLen <= 8 {
+		return "", fmt.Errorf("truncate_sha256: length must be greater than 8")
+	}
+	if len(s) <= maxLen {
+		return s, nil
+	}
+	return s[:maxLen-8] + hashSHA256(s)[:8], nil
+}
+
+func replace(old, new, s string) string {
+	return strings.Replace(s, old, new, -1)
+}
+
+func hashSHA256(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func encodeBase64(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
+
+func unixTime() string {
+	return strconv.FormatInt(time.Now().Unix(), 10)
+}
+
+func unixTimeMillis() string {
+	return strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10)
+}
+
+func timestamp(layout string) string {
+	return time.Now().UTC().Format(layout)
+}
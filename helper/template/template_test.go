@@ -0,0 +1,89 @@
+package template
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestStringTemplate_Generate(t *testing.T) {
+	data := struct {
+		DisplayName string
+		RoleName    string
+	}{
+		DisplayName: "token-with-a-long-display-name",
+		RoleName:    "Read.Only",
+	}
+
+	cases := []struct {
+		template string
+		expected *regexp.Regexp
+	}{
+		{`{{.RoleName}}`, regexp.MustCompile(`^Read\.Only$`)},
+		{`  {{.RoleName}}` + "\n", regexp.MustCompile(`^Read\.Only$`)},
+		{`{{.DisplayName | truncate 5}}`, regexp.MustCompile(`^token$`)},
+		{`{{.RoleName | truncate 20}}`, regexp.MustCompile(`^Read\.Only$`)},
+		{`{{.DisplayName | truncate_sha256 20}}`, regexp.MustCompile(`^token-with-a`)},
+		{`{{.RoleName | lowercase | replace "." "_"}}`, regexp.MustCompile(`^read_only$`)},
+		{`{{.RoleName | uppercase}}`, regexp.MustCompile(`^READ\.ONLY$`)},
+		{`{{random 10}}`, regexp.MustCompile(`^[a-zA-Z0-9]{10}$`)},
+		{`{{.RoleName | sha256}}`, regexp.MustCompile(`^[0-9a-f]{64}$`)},
+		{`{{.RoleName | base64}}`, regexp.MustCompile(`^UmVhZC5Pbmx5$`)},
+		{`{{unix_time}}`, regexp.MustCompile(`^[0-9]{10}$`)},
+		{`{{unix_time_millis}}`, regexp.MustCompile(`^[0-9]{13}$`)},
+		{`{{timestamp "2006"}}`, regexp.MustCompile(`^[0-9]{4}$`)},
+		{`{{uuid}}`, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)},
+		{
+			`v-{{.DisplayName | truncate 8}}-{{.RoleName | truncate 8}}-{{random 20}}-{{unix_time}}`,
+			regexp.MustCompile(`^v-token-wi-Read\.Onl-[a-zA-Z0-9]{20}-[0-9]{10}$`),
+		},
+	}
+
+	for _, tc := range cases {
+		tmpl, err := NewTemplate(tc.template)
+		if err != nil {
+			t.Fatalf("%q: %s", tc.template, err)
+		}
+		actual, err := tmpl.Generate(data)
+		if err != nil {
+			t.Fatalf("%q: %s", tc.template, err)
+		}
+		if !tc.expected.MatchString(actual) {
+			t.Fatalf("%q: bad result: %q", tc.template, actual)
+		}
+	}
+
+	// truncate_sha256 keeps the length and distinguishes long values
+	tmpl, _ := NewTemplate(`{{.DisplayName | truncate_sha256 20}}`)
+	actual, _ := tmpl.Generate(data)
+	if len(actual) != 20 || strings.HasSuffix(actual, "disp") {
+		t.Fatalf("bad result: %q", actual)
+	}
+}
+
+func TestStringTemplate_Invalid(t *testing.T) {
+	for _, raw := range []string{
+		``,
+		`{{.RoleName`,
+		`{{unknown_func}}`,
+	} {
+		if _, err := NewTemplate(raw); err == nil {
+			t.Fatalf("%q: expected error", raw)
+		}
+	}
+
+	data := map[string]string{"RoleName": "role"}
+	for _, raw := range []string{
+		`{{.Missing}}`,
+		`{{.RoleName | truncate_sha256 4}}`,
+		`{{.RoleName | truncate -1}}`,
+	} {
+		tmpl, err := NewTemplate(raw)
+		if err != nil {
+			t.Fatalf("%q: %s", raw, err)
+		}
+		if _, err := tmpl.Generate(data); err == nil {
+			t.Fatalf("%q: expected error", raw)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 
 	"github.com/hashicorp/errwrap"
@@ -32,6 +33,10 @@ const (
 	// not to use request forwarding
 	NoRequestForwardingHeaderName = "X-Vault-No-Request-Forwarding"
 
+	// PolicyOverrideHeaderName is the name of the header requesting that
+	// failed soft-mandatory expression policies are overridden
+	PolicyOverrideHeaderName = "X-Vault-Policy-Override"
+
 	// MaxRequestSize is the maximum accepted request size. This is to prevent
 	// a denial of service attack where no Content-Length is provided and the server
 	// is fed ever more data until it exhausts memory.
@@ -265,8 +270,16 @@ func respondStandby(core *vault.Core, w http.ResponseWriter, reqURL *url.URL) {
 
 // requestAuth adds the token to the logical.Request if it exists.
 func requestAuth(core *vault.Core, r *http.Request, req *logical.Request) *logical.Request {
-	// Attach the header value if we have it
-	if v := r.Header.Get(AuthHeaderName); v != "" {
+	// Attach the header value if we have it. For the metrics endpoint, a
+	// bearer token in the Authorization header is accepted too, since
+	// Prometheus cannot set other headers.
+	v := r.Header.Get(AuthHeaderName)
+	if v == "" && req.Path == "sys/metrics" {
+		if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+			v = strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+		}
+	}
+	if v != "" {
 		req.ClientToken = v
 
 		// Also attach the accessor if we have it. This doesn't fail if it
@@ -313,6 +326,23 @@ func requestWrapInfo(r *http.Request, req *logical.Request) (*logical.Request, e
 	return req, nil
 }
 
+// requestPolicyOverride sets the PolicyOverride flag of the logical.Request
+// if the policy override header is set
+func requestPolicyOverride(r *http.Request, req *logical.Request) (*logical.Request, error) {
+	raw := r.Header.Get(PolicyOverrideHeaderName)
+	if raw == "" {
+		return req, nil
+	}
+
+	override, err := strconv.ParseBool(raw)
+	if err != nil {
+		return req, err
+	}
+	req.PolicyOverride = override
+
+	return req, nil
+}
+
 func respondError(w http.ResponseWriter, status int, err error) {
 	logical.AdjustErrorStatusCode(&status, err)
 
@@ -236,6 +236,56 @@ func TestSysMounts_headerAuth(t *testing.T) {
 }
 
 // We use this test to verify header auth wrapping
+func TestSysMounts_bearerAuth(t *testing.T) {
+	core, _, token := vault.TestCoreUnsealed(t)
+	ln, addr := TestServer(t, core)
+	defer ln.Close()
+
+	// Bearer tokens are only accepted by the metrics endpoint
+	req, err := http.NewRequest("GET", addr+"/v1/sys/mounts", nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := cleanhttp.DefaultClient().Do(req)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != 400 {
+		t.Fatalf("bad: expected 400, got %d", resp.StatusCode)
+	}
+}
+
+func TestRequestAuth_bearer(t *testing.T) {
+	core, _, token := vault.TestCoreUnsealed(t)
+
+	cases := []struct {
+		path       string
+		authHeader string
+		expected   string
+	}{
+		{"sys/metrics", "Bearer " + token, token},
+		{"sys/metrics", "Basic " + token, ""},
+		{"sys/mounts", "Bearer " + token, ""},
+	}
+
+	for _, tc := range cases {
+		r, err := http.NewRequest("GET", "/v1/"+tc.path, nil)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		r.Header.Set("Authorization", tc.authHeader)
+
+		req := requestAuth(core, r, &logical.Request{Path: tc.path})
+		if req.ClientToken != tc.expected {
+			t.Fatalf("bad: %s %s: %q", tc.path, tc.authHeader, req.ClientToken)
+		}
+	}
+}
+
 func TestSysMounts_headerAuth_Wrapped(t *testing.T) {
 	core, _, token := vault.TestCoreUnsealed(t)
 	ln, addr := TestServer(t, core)
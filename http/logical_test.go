@@ -298,3 +298,30 @@ func TestLogical_ListSuffix(t *testing.T) {
 		t.Fatal("trailing slash not found on path")
 	}
 }
+
+func TestLogical_PolicyOverride(t *testing.T) {
+	core, _, _ := vault.TestCoreUnsealed(t)
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8200/v1/secret/foo", nil)
+	lreq, _, err := buildLogicalRequest(core, nil, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lreq.PolicyOverride {
+		t.Fatal("policy override set without the header")
+	}
+
+	req.Header.Set(PolicyOverrideHeaderName, "true")
+	lreq, _, err = buildLogicalRequest(core, nil, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !lreq.PolicyOverride {
+		t.Fatal("policy override not set")
+	}
+
+	req.Header.Set(PolicyOverrideHeaderName, "maybe")
+	_, status, err := buildLogicalRequest(core, nil, req)
+	if err == nil || status != http.StatusBadRequest {
+		t.Fatalf("expected bad request, got status %d, err: %v", status, err)
+	}
+}
@@ -24,7 +24,7 @@ const (
 // Cassandra is an implementation of Database interface
 type Cassandra struct {
 	connutil.ConnectionProducer
-	credsutil.CredentialsProducer
+	*credsutil.SQLCredentialsProducer
 }
 
 // New returns a new Cassandra instance
@@ -40,8 +40,8 @@ func New() (interface{}, error) {
 	}
 
 	dbType := &Cassandra{
-		ConnectionProducer:  connProducer,
-		CredentialsProducer: credsProducer,
+		ConnectionProducer:     connProducer,
+		SQLCredentialsProducer: credsProducer,
 	}
 
 	return dbType, nil
@@ -143,6 +143,11 @@ func (c *Cassandra) RenewUser(statements dbplugin.Statements, username string, e
 	return nil
 }
 
+// SetCredentials is not supported on Cassandra.
+func (c *Cassandra) SetCredentials(statements dbplugin.Statements, staticUser dbplugin.StaticUserConfig) (username string, password string, err error) {
+	return "", "", dbutil.ErrSetCredentialsUnsupported
+}
+
 // RevokeUser attempts to drop the specified user.
 func (c *Cassandra) RevokeUser(statements dbplugin.Statements, username string) error {
 	// Grab the lock
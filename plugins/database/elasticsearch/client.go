@@ -0,0 +1,99 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// client is a minimal client for the Elasticsearch security API
+type client struct {
+	baseURL  string
+	username string
+	password string
+	http     *http.Client
+}
+
+// user is the body of a create or update user request
+type user struct {
+	Password string   `json:"password"`
+	Roles    []string `json:"roles"`
+}
+
+// Authenticate returns an error unless the client's credentials are valid
+func (c *client) Authenticate() error {
+	return c.do(http.MethodGet, "/_security/_authenticate", nil, false)
+}
+
+// PutRole creates or updates a role
+func (c *client) PutRole(name string, definition map[string]interface{}) error {
+	return c.do(http.MethodPut, "/_security/role/"+url.PathEscape(name), definition, false)
+}
+
+// DeleteRole deletes a role, if it exists
+func (c *client) DeleteRole(name string) error {
+	return c.do(http.MethodDelete, "/_security/role/"+url.PathEscape(name), nil, true)
+}
+
+// PutUser creates or updates a user of the native realm
+func (c *client) PutUser(name string, u *user) error {
+	return c.do(http.MethodPut, "/_security/user/"+url.PathEscape(name), u, false)
+}
+
+// DeleteUser deletes a user of the native realm, if it exists
+func (c *client) DeleteUser(name string) error {
+	return c.do(http.MethodDelete, "/_security/user/"+url.PathEscape(name), nil, true)
+}
+
+// ChangePassword sets the password of an existing user of the native realm
+func (c *client) ChangePassword(name, password string) error {
+	body := map[string]string{
+		"password": password,
+	}
+	return c.do(http.MethodPost, "/_security/user/"+url.PathEscape(name)+"/_password", body, false)
+}
+
+func (c *client) do(method, path string, body interface{}, ignoreNotFound bool) error {
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, strings.TrimSuffix(c.baseURL, "/")+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(c.username, c.password)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusNotFound && ignoreNotFound:
+		return nil
+	default:
+		return fmt.Errorf("%s %s returned %d: %s", method, path, resp.StatusCode, bytes.TrimSpace(respBody))
+	}
+}
@@ -0,0 +1,139 @@
+package elasticsearch
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/helper/parseutil"
+	"github.com/hashicorp/vault/plugins/helper/database/connutil"
+	"github.com/mitchellh/mapstructure"
+)
+
+// elasticsearchConnectionProducer implements ConnectionProducer and provides
+// an interface for Elasticsearch clusters to make connections.
+type elasticsearchConnectionProducer struct {
+	URL               string      `json:"url" structs:"url" mapstructure:"url"`
+	Username          string      `json:"username" structs:"username" mapstructure:"username"`
+	Password          string      `json:"password" structs:"password" mapstructure:"password"`
+	CACert            string      `json:"ca_cert" structs:"ca_cert" mapstructure:"ca_cert"`
+	InsecureTLS       bool        `json:"insecure_tls" structs:"insecure_tls" mapstructure:"insecure_tls"`
+	ConnectTimeoutRaw interface{} `json:"connect_timeout" structs:"connect_timeout" mapstructure:"connect_timeout"`
+
+	connectTimeout time.Duration
+	tlsConfig      *tls.Config
+
+	Initialized bool
+	Type        string
+	client      *client
+	sync.Mutex
+}
+
+// Initialize parses connection configuration.
+func (c *elasticsearchConnectionProducer) Initialize(conf map[string]interface{}, verifyConnection bool) error {
+	c.Lock()
+	defer c.Unlock()
+
+	err := mapstructure.WeakDecode(conf, c)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case len(c.URL) == 0:
+		return fmt.Errorf("url cannot be empty")
+	case len(c.Username) == 0:
+		return fmt.Errorf("username cannot be empty")
+	case len(c.Password) == 0:
+		return fmt.Errorf("password cannot be empty")
+	}
+
+	if _, err := url.Parse(c.URL); err != nil {
+		return fmt.Errorf("invalid url: %s", err)
+	}
+
+	if c.ConnectTimeoutRaw == nil {
+		c.ConnectTimeoutRaw = "30s"
+	}
+	c.connectTimeout, err = parseutil.ParseDurationSecond(c.ConnectTimeoutRaw)
+	if err != nil {
+		return fmt.Errorf("invalid connect_timeout: %s", err)
+	}
+
+	c.tlsConfig = &tls.Config{
+		InsecureSkipVerify: c.InsecureTLS,
+	}
+	if len(c.CACert) != 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(c.CACert)) {
+			return fmt.Errorf("could not parse ca_cert")
+		}
+		c.tlsConfig.RootCAs = pool
+	}
+
+	// Drop any client created with the previous configuration
+	c.client = nil
+
+	// Set initialized to true at this point since all fields are set,
+	// and the connection can be established at a later time.
+	c.Initialized = true
+
+	if verifyConnection {
+		cl, err := c.Connection()
+		if err != nil {
+			return fmt.Errorf("error verifying connection: %s", err)
+		}
+
+		if err := cl.(*client).Authenticate(); err != nil {
+			return fmt.Errorf("error verifying connection: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// Connection returns the client used to make requests to the cluster.
+func (c *elasticsearchConnectionProducer) Connection() (interface{}, error) {
+	if !c.Initialized {
+		return nil, connutil.ErrNotInitialized
+	}
+
+	if c.client != nil {
+		return c.client, nil
+	}
+
+	c.client = &client{
+		baseURL:  c.URL,
+		username: c.Username,
+		password: c.Password,
+		http: &http.Client{
+			Timeout: c.connectTimeout,
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: c.tlsConfig,
+			},
+		},
+	}
+
+	return c.client, nil
+}
+
+// Close releases the client's idle connections.
+func (c *elasticsearchConnectionProducer) Close() error {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.client != nil {
+		if t, ok := c.client.http.Transport.(*http.Transport); ok {
+			t.CloseIdleConnections()
+		}
+	}
+
+	c.client = nil
+
+	return nil
+}
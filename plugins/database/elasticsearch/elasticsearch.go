@@ -0,0 +1,207 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+	"github.com/hashicorp/vault/plugins"
+	"github.com/hashicorp/vault/plugins/helper/database/connutil"
+	"github.com/hashicorp/vault/plugins/helper/database/credsutil"
+	"github.com/hashicorp/vault/plugins/helper/database/dbutil"
+)
+
+const elasticsearchTypeName = "elasticsearch"
+
+// Elasticsearch is an implementation of Database interface
+type Elasticsearch struct {
+	connutil.ConnectionProducer
+	*credsutil.SQLCredentialsProducer
+}
+
+// creationStatement is the format of the creation statements. A user is
+// given the listed existing roles, and a role of its own if a role definition
+// is provided.
+type creationStatement struct {
+	Roles          []string               `json:"elasticsearch_roles"`
+	RoleDefinition map[string]interface{} `json:"elasticsearch_role_definition"`
+}
+
+// New returns a new Elasticsearch instance
+func New() (interface{}, error) {
+	connProducer := &elasticsearchConnectionProducer{}
+	connProducer.Type = elasticsearchTypeName
+
+	credsProducer := &credsutil.SQLCredentialsProducer{
+		DisplayNameLen: 15,
+		RoleNameLen:    15,
+		UsernameLen:    100,
+		Separator:      "-",
+	}
+
+	dbType := &Elasticsearch{
+		ConnectionProducer:     connProducer,
+		SQLCredentialsProducer: credsProducer,
+	}
+	return dbType, nil
+}
+
+// Run instantiates an Elasticsearch object, and runs the RPC server for the
+// plugin
+func Run(apiTLSConfig *api.TLSConfig) error {
+	dbType, err := New()
+	if err != nil {
+		return err
+	}
+
+	plugins.Serve(dbType.(*Elasticsearch), apiTLSConfig)
+
+	return nil
+}
+
+// Type returns the TypeName for this backend
+func (e *Elasticsearch) Type() (string, error) {
+	return elasticsearchTypeName, nil
+}
+
+func (e *Elasticsearch) getConnection() (*client, error) {
+	cl, err := e.Connection()
+	if err != nil {
+		return nil, err
+	}
+
+	return cl.(*client), nil
+}
+
+// CreateUser creates a user in the native realm as instructed by the creation
+// statement. The creation statement is a JSON blob that lists existing roles
+// to assign to the user, and optionally defines a role that is created with
+// the same name as the user and deleted along with it. The role definition is
+// in the format of the Elasticsearch create role API:
+// https://www.elastic.co/guide/en/elasticsearch/reference/current/security-api-put-role.html
+//
+// JSON Example:
+//  { "elasticsearch_roles": ["monitoring_user"],
+//    "elasticsearch_role_definition": { "indices": [{ "names": ["logs-*"], "privileges": ["read"] }] } }
+func (e *Elasticsearch) CreateUser(statements dbplugin.Statements, usernameConfig dbplugin.UsernameConfig, expiration time.Time) (username string, password string, err error) {
+	// Grab the lock
+	e.Lock()
+	defer e.Unlock()
+
+	if statements.CreationStatements == "" {
+		return "", "", dbutil.ErrEmptyCreationStatement
+	}
+
+	var stmt creationStatement
+	if err := json.Unmarshal([]byte(statements.CreationStatements), &stmt); err != nil {
+		return "", "", err
+	}
+	if len(stmt.Roles) == 0 && stmt.RoleDefinition == nil {
+		return "", "", fmt.Errorf("elasticsearch_roles or elasticsearch_role_definition is required in creation statement")
+	}
+
+	cl, err := e.getConnection()
+	if err != nil {
+		return "", "", err
+	}
+
+	username, err = e.GenerateUsername(usernameConfig)
+	if err != nil {
+		return "", "", err
+	}
+
+	password, err = e.GeneratePassword()
+	if err != nil {
+		return "", "", err
+	}
+
+	roles := stmt.Roles
+	if stmt.RoleDefinition != nil {
+		if err := cl.PutRole(username, stmt.RoleDefinition); err != nil {
+			return "", "", err
+		}
+		roles = append(roles, username)
+	}
+
+	err = cl.PutUser(username, &user{
+		Password: password,
+		Roles:    roles,
+	})
+	if err != nil {
+		if stmt.RoleDefinition != nil {
+			// Don't leave the user's role behind
+			cl.DeleteRole(username)
+		}
+		return "", "", err
+	}
+
+	return username, password, nil
+}
+
+// RenewUser is not supported on Elasticsearch, so this is a no-op.
+func (e *Elasticsearch) RenewUser(statements dbplugin.Statements, username string, expiration time.Time) error {
+	// NOOP
+	return nil
+}
+
+// SetCredentials changes the password of an existing user of the native
+// realm, generating a new password if one is not provided.
+func (e *Elasticsearch) SetCredentials(statements dbplugin.Statements, staticUser dbplugin.StaticUserConfig) (username string, password string, err error) {
+	if staticUser.Username == "" {
+		return "", "", dbutil.ErrEmptyUsername
+	}
+
+	e.Lock()
+	defer e.Unlock()
+
+	password = staticUser.Password
+	if password == "" {
+		password, err = e.GeneratePassword()
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	cl, err := e.getConnection()
+	if err != nil {
+		return "", "", err
+	}
+
+	if err := cl.ChangePassword(staticUser.Username, password); err != nil {
+		return "", "", err
+	}
+
+	return staticUser.Username, password, nil
+}
+
+// RevokeUser deletes the user, and the role created along with it if there
+// is one. Revocation statements are not used.
+func (e *Elasticsearch) RevokeUser(statements dbplugin.Statements, username string) error {
+	e.Lock()
+	defer e.Unlock()
+
+	cl, err := e.getConnection()
+	if err != nil {
+		return err
+	}
+
+	if err := cl.DeleteUser(username); err != nil {
+		return err
+	}
+
+	var stmt creationStatement
+	if statements.CreationStatements != "" {
+		if err := json.Unmarshal([]byte(statements.CreationStatements), &stmt); err != nil {
+			return err
+		}
+	}
+	if stmt.RoleDefinition != nil {
+		if err := cl.DeleteRole(username); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
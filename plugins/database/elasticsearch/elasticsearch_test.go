@@ -0,0 +1,335 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+)
+
+const (
+	testElasticsearchRoles          = `{"elasticsearch_roles": ["monitoring_user"]}`
+	testElasticsearchRoleDefinition = `{"elasticsearch_role_definition": {"indices": [{"names": ["logs-*"], "privileges": ["read"]}]}}`
+)
+
+// testElasticsearchServer is an in-process stand-in for the security API of an
+// Elasticsearch cluster.
+type testElasticsearchServer struct {
+	sync.Mutex
+	users map[string]*user
+	roles map[string]map[string]interface{}
+}
+
+func prepareElasticsearchTestServer(t *testing.T) (cleanup func(), connectionDetails map[string]interface{}, server *testElasticsearchServer) {
+	server = &testElasticsearchServer{
+		users: map[string]*user{
+			"elastic": {Password: "secret", Roles: []string{"superuser"}},
+		},
+		roles: map[string]map[string]interface{}{
+			"superuser":       {},
+			"monitoring_user": {},
+		},
+	}
+	ts := httptest.NewServer(server)
+
+	connectionDetails = map[string]interface{}{
+		"url":      ts.URL,
+		"username": "elastic",
+		"password": "secret",
+	}
+
+	return ts.Close, connectionDetails, server
+}
+
+func (s *testElasticsearchServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+
+	username, password, ok := r.BasicAuth()
+	if u, exists := s.users[username]; !ok || !exists || u.Password != password {
+		http.Error(w, `{"error": "unable to authenticate user"}`, http.StatusUnauthorized)
+		return
+	}
+	if !strings.HasPrefix(r.URL.Path, "/_security/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/_security/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "_authenticate" && r.Method == http.MethodGet:
+		json.NewEncoder(w).Encode(map[string]interface{}{"username": username})
+
+	case len(parts) == 2 && parts[0] == "role" && r.Method == http.MethodPut:
+		var definition map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&definition); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.roles[parts[1]] = definition
+
+	case len(parts) == 2 && parts[0] == "role" && r.Method == http.MethodDelete:
+		if _, ok := s.roles[parts[1]]; !ok {
+			http.NotFound(w, r)
+			return
+		}
+		delete(s.roles, parts[1])
+
+	case len(parts) == 2 && parts[0] == "user" && r.Method == http.MethodPut:
+		var u user
+		if err := json.NewDecoder(r.Body).Decode(&u); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, role := range u.Roles {
+			if _, ok := s.roles[role]; !ok {
+				http.Error(w, "unknown role "+role, http.StatusBadRequest)
+				return
+			}
+		}
+		s.users[parts[1]] = &u
+
+	case len(parts) == 2 && parts[0] == "user" && r.Method == http.MethodDelete:
+		if _, ok := s.users[parts[1]]; !ok {
+			http.NotFound(w, r)
+			return
+		}
+		delete(s.users, parts[1])
+
+	case len(parts) == 3 && parts[0] == "user" && parts[2] == "_password" && r.Method == http.MethodPost:
+		u, ok := s.users[parts[1]]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		var body map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		u.Password = body["password"]
+
+	default:
+		http.Error(w, "unsupported request", http.StatusBadRequest)
+		return
+	}
+
+	w.Write([]byte("{}"))
+}
+
+func TestElasticsearch_Initialize(t *testing.T) {
+	cleanup, connectionDetails, _ := prepareElasticsearchTestServer(t)
+	defer cleanup()
+
+	dbRaw, err := New()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	db := dbRaw.(*Elasticsearch)
+	connProducer := db.ConnectionProducer.(*elasticsearchConnectionProducer)
+
+	err = db.Initialize(connectionDetails, true)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if !connProducer.Initialized {
+		t.Fatal("Database should be initalized")
+	}
+
+	err = db.Close()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	connectionDetails["password"] = "wrong"
+	if err := db.Initialize(connectionDetails, true); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestElasticsearch_CreateUser(t *testing.T) {
+	cleanup, connectionDetails, server := prepareElasticsearchTestServer(t)
+	defer cleanup()
+
+	dbRaw, _ := New()
+	db := dbRaw.(*Elasticsearch)
+	err := db.Initialize(connectionDetails, true)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer db.Close()
+
+	usernameConfig := dbplugin.UsernameConfig{
+		DisplayName: "test",
+		RoleName:    "test",
+	}
+
+	statements := dbplugin.Statements{}
+	_, _, err = db.CreateUser(statements, usernameConfig, time.Now().Add(time.Minute))
+	if err == nil {
+		t.Fatal("Expected error when no creation statement is provided")
+	}
+
+	statements.CreationStatements = `{}`
+	_, _, err = db.CreateUser(statements, usernameConfig, time.Now().Add(time.Minute))
+	if err == nil {
+		t.Fatal("Expected error when no roles are provided")
+	}
+
+	statements.CreationStatements = testElasticsearchRoles
+	username, password, err := db.CreateUser(statements, usernameConfig, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := testCredsExist(t, connectionDetails, username, password); err != nil {
+		t.Fatalf("Could not connect with new credentials: %s", err)
+	}
+
+	server.Lock()
+	roles := server.users[username].Roles
+	server.Unlock()
+	if len(roles) != 1 || roles[0] != "monitoring_user" {
+		t.Fatalf("bad roles: %v", roles)
+	}
+
+	// A role is created for the user from the role definition
+	statements.CreationStatements = testElasticsearchRoleDefinition
+	username, password, err = db.CreateUser(statements, usernameConfig, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := testCredsExist(t, connectionDetails, username, password); err != nil {
+		t.Fatalf("Could not connect with new credentials: %s", err)
+	}
+
+	server.Lock()
+	roles = server.users[username].Roles
+	_, roleExists := server.roles[username]
+	server.Unlock()
+	if len(roles) != 1 || roles[0] != username || !roleExists {
+		t.Fatalf("bad roles: %v", roles)
+	}
+}
+
+func TestElasticsearch_RevokeUser(t *testing.T) {
+	cleanup, connectionDetails, server := prepareElasticsearchTestServer(t)
+	defer cleanup()
+
+	dbRaw, _ := New()
+	db := dbRaw.(*Elasticsearch)
+	err := db.Initialize(connectionDetails, true)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer db.Close()
+
+	statements := dbplugin.Statements{
+		CreationStatements: testElasticsearchRoleDefinition,
+	}
+
+	usernameConfig := dbplugin.UsernameConfig{
+		DisplayName: "test",
+		RoleName:    "test",
+	}
+
+	username, password, err := db.CreateUser(statements, usernameConfig, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := testCredsExist(t, connectionDetails, username, password); err != nil {
+		t.Fatalf("Could not connect with new credentials: %s", err)
+	}
+
+	err = db.RevokeUser(statements, username)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err = testCredsExist(t, connectionDetails, username, password); err == nil {
+		t.Fatal("Credentials were not revoked")
+	}
+
+	server.Lock()
+	_, roleExists := server.roles[username]
+	server.Unlock()
+	if roleExists {
+		t.Fatal("role was not deleted")
+	}
+
+	// Revoking a user that no longer exists succeeds
+	if err := db.RevokeUser(statements, username); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestElasticsearch_SetCredentials(t *testing.T) {
+	cleanup, connectionDetails, _ := prepareElasticsearchTestServer(t)
+	defer cleanup()
+
+	dbRaw, _ := New()
+	db := dbRaw.(*Elasticsearch)
+	err := db.Initialize(connectionDetails, true)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer db.Close()
+
+	username, password, err := db.CreateUser(dbplugin.Statements{
+		CreationStatements: testElasticsearchRoles,
+	}, dbplugin.UsernameConfig{
+		DisplayName: "test",
+		RoleName:    "test",
+	}, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	_, newPassword, err := db.SetCredentials(dbplugin.Statements{}, dbplugin.StaticUserConfig{
+		Username: username,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if newPassword == "" || newPassword == password {
+		t.Fatalf("expected a new password, got %q", newPassword)
+	}
+
+	if err := testCredsExist(t, connectionDetails, username, newPassword); err != nil {
+		t.Fatalf("Could not connect with new credentials: %s", err)
+	}
+	if err := testCredsExist(t, connectionDetails, username, password); err == nil {
+		t.Fatal("old password should no longer work")
+	}
+
+	_, _, err = db.SetCredentials(dbplugin.Statements{}, dbplugin.StaticUserConfig{
+		Username: "unknown",
+		Password: "password",
+	})
+	if err == nil {
+		t.Fatal("expected error for unknown user")
+	}
+}
+
+func testCredsExist(t testing.TB, connectionDetails map[string]interface{}, username, password string) error {
+	details := make(map[string]interface{}, len(connectionDetails))
+	for k, v := range connectionDetails {
+		details[k] = v
+	}
+	details["username"] = username
+	details["password"] = password
+
+	dbRaw, _ := New()
+	db := dbRaw.(*Elasticsearch)
+	defer db.Close()
+
+	return db.Initialize(details, true)
+}
@@ -23,7 +23,7 @@ const (
 // HANA is an implementation of Database interface
 type HANA struct {
 	connutil.ConnectionProducer
-	credsutil.CredentialsProducer
+	*credsutil.SQLCredentialsProducer
 }
 
 // New implements builtinplugins.BuiltinFactory
@@ -39,8 +39,8 @@ func New() (interface{}, error) {
 	}
 
 	dbType := &HANA{
-		ConnectionProducer:  connProducer,
-		CredentialsProducer: credsProducer,
+		ConnectionProducer:     connProducer,
+		SQLCredentialsProducer: credsProducer,
 	}
 
 	return dbType, nil
@@ -192,6 +192,11 @@ func (h *HANA) RenewUser(statements dbplugin.Statements, username string, expira
 	return nil
 }
 
+// SetCredentials is not supported on HANA.
+func (h *HANA) SetCredentials(statements dbplugin.Statements, staticUser dbplugin.StaticUserConfig) (username string, password string, err error) {
+	return "", "", dbutil.ErrSetCredentialsUnsupported
+}
+
 // Revoking hana user will deactivate user and try to perform a soft drop
 func (h *HANA) RevokeUser(statements dbplugin.Statements, username string) error {
 	// default revoke will be a soft drop on user
@@ -21,7 +21,7 @@ const mongoDBTypeName = "mongodb"
 // MongoDB is an implementation of Database interface
 type MongoDB struct {
 	connutil.ConnectionProducer
-	credsutil.CredentialsProducer
+	*credsutil.SQLCredentialsProducer
 }
 
 // New returns a new MongoDB instance
@@ -37,8 +37,8 @@ func New() (interface{}, error) {
 	}
 
 	dbType := &MongoDB{
-		ConnectionProducer:  connProducer,
-		CredentialsProducer: credsProducer,
+		ConnectionProducer:     connProducer,
+		SQLCredentialsProducer: credsProducer,
 	}
 	return dbType, nil
 }
@@ -137,6 +137,11 @@ func (m *MongoDB) RenewUser(statements dbplugin.Statements, username string, exp
 	return nil
 }
 
+// SetCredentials is not supported on MongoDB.
+func (m *MongoDB) SetCredentials(statements dbplugin.Statements, staticUser dbplugin.StaticUserConfig) (username string, password string, err error) {
+	return "", "", dbutil.ErrSetCredentialsUnsupported
+}
+
 // RevokeUser drops the specified user from the authentication databse. If none is provided
 // in the revocation statement, the default "admin" authentication database will be assumed.
 func (m *MongoDB) RevokeUser(statements dbplugin.Statements, username string) error {
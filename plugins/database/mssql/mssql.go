@@ -16,12 +16,18 @@ import (
 	"github.com/hashicorp/vault/plugins/helper/database/dbutil"
 )
 
-const msSQLTypeName = "mssql"
+const (
+	msSQLTypeName = "mssql"
+
+	defaultMSSQLRotationStmts = `
+ALTER LOGIN [{{name}}] WITH PASSWORD = '{{password}}';
+`
+)
 
 // MSSQL is an implementation of Database interface
 type MSSQL struct {
 	connutil.ConnectionProducer
-	credsutil.CredentialsProducer
+	*credsutil.SQLCredentialsProducer
 }
 
 func New() (interface{}, error) {
@@ -36,8 +42,8 @@ func New() (interface{}, error) {
 	}
 
 	dbType := &MSSQL{
-		ConnectionProducer:  connProducer,
-		CredentialsProducer: credsProducer,
+		ConnectionProducer:     connProducer,
+		SQLCredentialsProducer: credsProducer,
 	}
 
 	return dbType, nil
@@ -143,6 +149,71 @@ func (m *MSSQL) RenewUser(statements dbplugin.Statements, username string, expir
 	return nil
 }
 
+// SetCredentials sets the password of an existing login, generating a new
+// password if one is not provided.
+func (m *MSSQL) SetCredentials(statements dbplugin.Statements, staticUser dbplugin.StaticUserConfig) (username string, password string, err error) {
+	if staticUser.Username == "" {
+		return "", "", dbutil.ErrEmptyUsername
+	}
+
+	// Grab the lock
+	m.Lock()
+	defer m.Unlock()
+
+	// Get the connection
+	db, err := m.getConnection()
+	if err != nil {
+		return "", "", err
+	}
+
+	rotationStmts := statements.RotationStatements
+	if rotationStmts == "" {
+		rotationStmts = defaultMSSQLRotationStmts
+	}
+
+	password = staticUser.Password
+	if password == "" {
+		password, err = m.GeneratePassword()
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	// Start a transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return "", "", err
+	}
+	defer tx.Rollback()
+
+	// Execute each query
+	for _, query := range strutil.ParseArbitraryStringSlice(rotationStmts, ";") {
+		query = strings.TrimSpace(query)
+		if len(query) == 0 {
+			continue
+		}
+
+		stmt, err := tx.Prepare(dbutil.QueryHelper(query, map[string]string{
+			"name":     staticUser.Username,
+			"password": password,
+		}))
+		if err != nil {
+			return "", "", err
+		}
+		defer stmt.Close()
+		if _, err := stmt.Exec(); err != nil {
+			return "", "", err
+		}
+	}
+
+	// Commit the transaction
+	if err := tx.Commit(); err != nil {
+		return "", "", err
+	}
+
+	return staticUser.Username, password, nil
+}
+
 // RevokeUser attempts to drop the specified user. It will first attempt to disable login,
 // then kill pending connections from that user, and finally drop the user and login from the
 // database instance.
@@ -165,6 +165,73 @@ func TestMSSQL_RevokeUser(t *testing.T) {
 	}
 }
 
+func TestMSSQL_SetCredentials(t *testing.T) {
+	if os.Getenv("MSSQL_URL") == "" || os.Getenv("VAULT_ACC") != "1" {
+		return
+	}
+	connURL := os.Getenv("MSSQL_URL")
+
+	connectionDetails := map[string]interface{}{
+		"connection_url": connURL,
+	}
+
+	dbRaw, _ := New()
+	db := dbRaw.(*MSSQL)
+	err := db.Initialize(connectionDetails, true)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	statements := dbplugin.Statements{
+		CreationStatements: testMSSQLRole,
+	}
+
+	usernameConfig := dbplugin.UsernameConfig{
+		DisplayName: "test",
+		RoleName:    "test",
+	}
+
+	username, oldPassword, err := db.CreateUser(statements, usernameConfig, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Test with no username
+	_, _, err = db.SetCredentials(statements, dbplugin.StaticUserConfig{})
+	if err == nil {
+		t.Fatal("Expected error when no username is provided")
+	}
+
+	// Test with a generated password and the default rotation statements
+	_, password, err := db.SetCredentials(statements, dbplugin.StaticUserConfig{Username: username})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if password == "" || password == oldPassword {
+		t.Fatalf("expected a new password, got: %q", password)
+	}
+
+	if err := testCredsExist(t, connURL, username, password); err != nil {
+		t.Fatalf("Could not connect with new credentials: %s", err)
+	}
+	if err := testCredsExist(t, connURL, username, oldPassword); err == nil {
+		t.Fatal("Should not be able to connect with the old credentials")
+	}
+
+	// Test with a provided password
+	_, password, err = db.SetCredentials(statements, dbplugin.StaticUserConfig{Username: username, Password: "A1a-providedpassword"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if password != "A1a-providedpassword" {
+		t.Fatalf("bad password: %s", password)
+	}
+
+	if err := testCredsExist(t, connURL, username, password); err != nil {
+		t.Fatalf("Could not connect with new credentials: %s", err)
+	}
+}
+
 func testCredsExist(t testing.TB, connURL, username, password string) error {
 	// Log in with the new creds
 	parts := strings.Split(connURL, "@")
@@ -20,6 +20,9 @@ const (
 		REVOKE ALL PRIVILEGES, GRANT OPTION FROM '{{name}}'@'%'; 
 		DROP USER '{{name}}'@'%'
 	`
+	defaultMysqlRotationStmts = `
+		ALTER USER '{{name}}'@'%' IDENTIFIED BY '{{password}}'
+	`
 	mySQLTypeName = "mysql"
 )
 
@@ -32,7 +35,7 @@ var (
 
 type MySQL struct {
 	connutil.ConnectionProducer
-	credsutil.CredentialsProducer
+	*credsutil.SQLCredentialsProducer
 }
 
 // New implements builtinplugins.BuiltinFactory
@@ -49,8 +52,8 @@ func New(metadataLen, usernameLen int) func() (interface{}, error) {
 		}
 
 		dbType := &MySQL{
-			ConnectionProducer:  connProducer,
-			CredentialsProducer: credsProducer,
+			ConnectionProducer:     connProducer,
+			SQLCredentialsProducer: credsProducer,
 		}
 
 		return dbType, nil
@@ -154,6 +157,68 @@ func (m *MySQL) RenewUser(statements dbplugin.Statements, username string, expir
 	return nil
 }
 
+// SetCredentials sets the password of an existing user, generating a new
+// password if one is not provided.
+func (m *MySQL) SetCredentials(statements dbplugin.Statements, staticUser dbplugin.StaticUserConfig) (username string, password string, err error) {
+	if staticUser.Username == "" {
+		return "", "", dbutil.ErrEmptyUsername
+	}
+
+	// Grab the lock
+	m.Lock()
+	defer m.Unlock()
+
+	// Get the connection
+	db, err := m.getConnection()
+	if err != nil {
+		return "", "", err
+	}
+
+	rotationStmts := statements.RotationStatements
+	if rotationStmts == "" {
+		rotationStmts = defaultMysqlRotationStmts
+	}
+
+	password = staticUser.Password
+	if password == "" {
+		password, err = m.GeneratePassword()
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	// Start a transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return "", "", err
+	}
+	defer tx.Rollback()
+
+	for _, query := range strutil.ParseArbitraryStringSlice(rotationStmts, ";") {
+		query = strings.TrimSpace(query)
+		if len(query) == 0 {
+			continue
+		}
+
+		// This is not a prepared statement because account management
+		// statements are not supported in the prepared statement protocol
+		query = dbutil.QueryHelper(query, map[string]string{
+			"name":     staticUser.Username,
+			"password": password,
+		})
+		if _, err := tx.Exec(query); err != nil {
+			return "", "", err
+		}
+	}
+
+	// Commit the transaction
+	if err := tx.Commit(); err != nil {
+		return "", "", err
+	}
+
+	return staticUser.Username, password, nil
+}
+
 func (m *MySQL) RevokeUser(statements dbplugin.Statements, username string) error {
 	// Grab the read lock
 	m.Lock()
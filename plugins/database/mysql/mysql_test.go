@@ -206,6 +206,73 @@ func TestMySQL_RevokeUser(t *testing.T) {
 	}
 }
 
+func TestMySQL_SetCredentials(t *testing.T) {
+	cleanup, connURL := prepareMySQLTestContainer(t)
+	defer cleanup()
+
+	connectionDetails := map[string]interface{}{
+		"connection_url": connURL,
+	}
+
+	f := New(MetadataLen, UsernameLen)
+	dbRaw, _ := f()
+	db := dbRaw.(*MySQL)
+
+	err := db.Initialize(connectionDetails, true)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	statements := dbplugin.Statements{
+		CreationStatements: testMySQLRoleWildCard,
+	}
+
+	usernameConfig := dbplugin.UsernameConfig{
+		DisplayName: "test",
+		RoleName:    "test",
+	}
+
+	username, oldPassword, err := db.CreateUser(statements, usernameConfig, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Test with no username
+	_, _, err = db.SetCredentials(statements, dbplugin.StaticUserConfig{})
+	if err == nil {
+		t.Fatal("Expected error when no username is provided")
+	}
+
+	// Test with a generated password and the default rotation statements
+	_, password, err := db.SetCredentials(statements, dbplugin.StaticUserConfig{Username: username})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if password == "" || password == oldPassword {
+		t.Fatalf("expected a new password, got: %q", password)
+	}
+
+	if err := testCredsExist(t, connURL, username, password); err != nil {
+		t.Fatalf("Could not connect with new credentials: %s", err)
+	}
+	if err := testCredsExist(t, connURL, username, oldPassword); err == nil {
+		t.Fatal("Should not be able to connect with the old credentials")
+	}
+
+	// Test with a provided password
+	_, password, err = db.SetCredentials(statements, dbplugin.StaticUserConfig{Username: username, Password: "A1a-providedpassword"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if password != "A1a-providedpassword" {
+		t.Fatalf("bad password: %s", password)
+	}
+
+	if err := testCredsExist(t, connURL, username, password); err != nil {
+		t.Fatalf("Could not connect with new credentials: %s", err)
+	}
+}
+
 func testCredsExist(t testing.TB, connURL, username, password string) error {
 	// Log in with the new creds
 	connURL = strings.Replace(connURL, "root:secret", fmt.Sprintf("%s:%s", username, password), 1)
@@ -21,6 +21,9 @@ const (
 	postgreSQLTypeName      string = "postgres"
 	defaultPostgresRenewSQL        = `
 ALTER ROLE "{{name}}" VALID UNTIL '{{expiration}}';
+`
+	defaultPostgresRotationSQL = `
+ALTER ROLE "{{name}}" WITH PASSWORD '{{password}}';
 `
 )
 
@@ -37,8 +40,8 @@ func New() (interface{}, error) {
 	}
 
 	dbType := &PostgreSQL{
-		ConnectionProducer:  connProducer,
-		CredentialsProducer: credsProducer,
+		ConnectionProducer:     connProducer,
+		SQLCredentialsProducer: credsProducer,
 	}
 
 	return dbType, nil
@@ -58,7 +61,7 @@ func Run(apiTLSConfig *api.TLSConfig) error {
 
 type PostgreSQL struct {
 	connutil.ConnectionProducer
-	credsutil.CredentialsProducer
+	*credsutil.SQLCredentialsProducer
 }
 
 func (p *PostgreSQL) Type() (string, error) {
@@ -201,6 +204,68 @@ func (p *PostgreSQL) RenewUser(statements dbplugin.Statements, username string,
 	return nil
 }
 
+// SetCredentials sets the password of an existing role, generating a new
+// password if one is not provided.
+func (p *PostgreSQL) SetCredentials(statements dbplugin.Statements, staticUser dbplugin.StaticUserConfig) (username string, password string, err error) {
+	if staticUser.Username == "" {
+		return "", "", dbutil.ErrEmptyUsername
+	}
+
+	rotationStmts := statements.RotationStatements
+	if rotationStmts == "" {
+		rotationStmts = defaultPostgresRotationSQL
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	password = staticUser.Password
+	if password == "" {
+		password, err = p.GeneratePassword()
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	db, err := p.getConnection()
+	if err != nil {
+		return "", "", err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return "", "", err
+	}
+	defer func() {
+		tx.Rollback()
+	}()
+
+	for _, query := range strutil.ParseArbitraryStringSlice(rotationStmts, ";") {
+		query = strings.TrimSpace(query)
+		if len(query) == 0 {
+			continue
+		}
+		stmt, err := tx.Prepare(dbutil.QueryHelper(query, map[string]string{
+			"name":     staticUser.Username,
+			"password": password,
+		}))
+		if err != nil {
+			return "", "", err
+		}
+
+		defer stmt.Close()
+		if _, err := stmt.Exec(); err != nil {
+			return "", "", err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", "", err
+	}
+
+	return staticUser.Username, password, nil
+}
+
 func (p *PostgreSQL) RevokeUser(statements dbplugin.Statements, username string) error {
 	// Grab the lock
 	p.Lock()
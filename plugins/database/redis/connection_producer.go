@@ -0,0 +1,159 @@
+package redis
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/helper/parseutil"
+	"github.com/hashicorp/vault/plugins/helper/database/connutil"
+	"github.com/mitchellh/mapstructure"
+)
+
+// redisConnectionProducer implements ConnectionProducer and provides an
+// interface for Redis databases to make connections.
+type redisConnectionProducer struct {
+	Host              string      `json:"host" structs:"host" mapstructure:"host"`
+	Port              int         `json:"port" structs:"port" mapstructure:"port"`
+	Username          string      `json:"username" structs:"username" mapstructure:"username"`
+	Password          string      `json:"password" structs:"password" mapstructure:"password"`
+	TLS               bool        `json:"tls" structs:"tls" mapstructure:"tls"`
+	InsecureTLS       bool        `json:"insecure_tls" structs:"insecure_tls" mapstructure:"insecure_tls"`
+	CACert            string      `json:"ca_cert" structs:"ca_cert" mapstructure:"ca_cert"`
+	ConnectTimeoutRaw interface{} `json:"connect_timeout" structs:"connect_timeout" mapstructure:"connect_timeout"`
+	PersistenceMode   string      `json:"persistence_mode" structs:"persistence_mode" mapstructure:"persistence_mode"`
+
+	connectTimeout time.Duration
+	tlsConfig      *tls.Config
+
+	Initialized bool
+	Type        string
+	client      *client
+	sync.Mutex
+}
+
+// Initialize parses connection configuration.
+func (c *redisConnectionProducer) Initialize(conf map[string]interface{}, verifyConnection bool) error {
+	c.Lock()
+	defer c.Unlock()
+
+	err := mapstructure.WeakDecode(conf, c)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case len(c.Host) == 0:
+		return fmt.Errorf("host cannot be empty")
+	case c.Port == 0:
+		return fmt.Errorf("port cannot be empty")
+	case len(c.Username) == 0:
+		return fmt.Errorf("username cannot be empty")
+	case len(c.Password) == 0:
+		return fmt.Errorf("password cannot be empty")
+	}
+
+	switch c.PersistenceMode {
+	case "", persistenceModeACLFile, persistenceModeConfig:
+	default:
+		return fmt.Errorf("invalid persistence_mode %q, must be one of %q or %q", c.PersistenceMode, persistenceModeACLFile, persistenceModeConfig)
+	}
+
+	if c.ConnectTimeoutRaw == nil {
+		c.ConnectTimeoutRaw = "5s"
+	}
+	c.connectTimeout, err = parseutil.ParseDurationSecond(c.ConnectTimeoutRaw)
+	if err != nil {
+		return fmt.Errorf("invalid connect_timeout: %s", err)
+	}
+
+	c.tlsConfig = nil
+	if c.TLS {
+		c.tlsConfig = &tls.Config{
+			ServerName:         c.Host,
+			InsecureSkipVerify: c.InsecureTLS,
+		}
+		if len(c.CACert) != 0 {
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM([]byte(c.CACert)) {
+				return fmt.Errorf("could not parse ca_cert")
+			}
+			c.tlsConfig.RootCAs = pool
+		}
+	}
+
+	// Set initialized to true at this point since all fields are set,
+	// and the connection can be established at a later time.
+	c.Initialized = true
+
+	if verifyConnection {
+		conn, err := c.Connection()
+		if err != nil {
+			return fmt.Errorf("error verifying connection: %s", err)
+		}
+
+		if _, err := conn.(*client).Do("PING"); err != nil {
+			return fmt.Errorf("error verifying connection: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// Connection returns an authenticated client, dialing a new connection if
+// there is none or the previous one failed.
+func (c *redisConnectionProducer) Connection() (interface{}, error) {
+	if !c.Initialized {
+		return nil, connutil.ErrNotInitialized
+	}
+
+	if c.client != nil {
+		if !c.client.broken {
+			return c.client, nil
+		}
+		c.client.Close()
+		c.client = nil
+	}
+
+	addr := net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+	dialer := &net.Dialer{Timeout: c.connectTimeout}
+
+	var conn net.Conn
+	var err error
+	if c.tlsConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, c.tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cl := newClient(conn, c.connectTimeout)
+	if _, err := cl.Do("AUTH", c.Username, c.Password); err != nil {
+		cl.Close()
+		return nil, err
+	}
+
+	c.client = cl
+
+	return c.client, nil
+}
+
+// Close terminates the database connection.
+func (c *redisConnectionProducer) Close() error {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.client != nil {
+		c.client.Close()
+	}
+
+	c.client = nil
+
+	return nil
+}
@@ -0,0 +1,209 @@
+package redis
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+	"github.com/hashicorp/vault/plugins"
+	"github.com/hashicorp/vault/plugins/helper/database/connutil"
+	"github.com/hashicorp/vault/plugins/helper/database/credsutil"
+	"github.com/hashicorp/vault/plugins/helper/database/dbutil"
+)
+
+const (
+	redisTypeName = "redis"
+
+	// persistenceModeACLFile saves the ACL users to the server's ACL file
+	// after every change
+	persistenceModeACLFile = "ACLFILE"
+
+	// persistenceModeConfig rewrites the server's configuration file after
+	// every change
+	persistenceModeConfig = "CONFIG"
+)
+
+// Redis is an implementation of Database interface
+type Redis struct {
+	connutil.ConnectionProducer
+	*credsutil.SQLCredentialsProducer
+}
+
+// New returns a new Redis instance
+func New() (interface{}, error) {
+	connProducer := &redisConnectionProducer{}
+	connProducer.Type = redisTypeName
+
+	credsProducer := &credsutil.SQLCredentialsProducer{
+		DisplayNameLen: 15,
+		RoleNameLen:    15,
+		UsernameLen:    100,
+		Separator:      "-",
+	}
+
+	dbType := &Redis{
+		ConnectionProducer:     connProducer,
+		SQLCredentialsProducer: credsProducer,
+	}
+	return dbType, nil
+}
+
+// Run instantiates a Redis object, and runs the RPC server for the plugin
+func Run(apiTLSConfig *api.TLSConfig) error {
+	dbType, err := New()
+	if err != nil {
+		return err
+	}
+
+	plugins.Serve(dbType.(*Redis), apiTLSConfig)
+
+	return nil
+}
+
+// Type returns the TypeName for this backend
+func (r *Redis) Type() (string, error) {
+	return redisTypeName, nil
+}
+
+func (r *Redis) getConnection() (*client, error) {
+	cl, err := r.Connection()
+	if err != nil {
+		return nil, err
+	}
+
+	return cl.(*client), nil
+}
+
+// CreateUser creates an ACL user with the rules given in the creation
+// statement, which is a JSON array of ACL rules as accepted by the ACL SETUSER
+// command. The user is enabled and can only authenticate with the generated
+// password.
+//
+// JSON Example:
+//  ["~cache:*", "+@read"]
+func (r *Redis) CreateUser(statements dbplugin.Statements, usernameConfig dbplugin.UsernameConfig, expiration time.Time) (username string, password string, err error) {
+	// Grab the lock
+	r.Lock()
+	defer r.Unlock()
+
+	if statements.CreationStatements == "" {
+		return "", "", dbutil.ErrEmptyCreationStatement
+	}
+
+	var rules []string
+	if err := json.Unmarshal([]byte(statements.CreationStatements), &rules); err != nil {
+		return "", "", fmt.Errorf("creation statements must be a JSON array of ACL rules: %s", err)
+	}
+
+	cl, err := r.getConnection()
+	if err != nil {
+		return "", "", err
+	}
+
+	username, err = r.GenerateUsername(usernameConfig)
+	if err != nil {
+		return "", "", err
+	}
+
+	password, err = r.GeneratePassword()
+	if err != nil {
+		return "", "", err
+	}
+
+	args := []string{"ACL", "SETUSER", username, "reset", "on", ">" + password}
+	args = append(args, rules...)
+	if _, err := cl.Do(args...); err != nil {
+		return "", "", err
+	}
+
+	if err := r.persist(cl); err != nil {
+		return "", "", err
+	}
+
+	return username, password, nil
+}
+
+// RenewUser is not supported on Redis, so this is a no-op.
+func (r *Redis) RenewUser(statements dbplugin.Statements, username string, expiration time.Time) error {
+	// NOOP
+	return nil
+}
+
+// SetCredentials replaces the passwords of an existing ACL user, generating a
+// new password if one is not provided. Its ACL rules are left unchanged.
+func (r *Redis) SetCredentials(statements dbplugin.Statements, staticUser dbplugin.StaticUserConfig) (username string, password string, err error) {
+	if staticUser.Username == "" {
+		return "", "", dbutil.ErrEmptyUsername
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	password = staticUser.Password
+	if password == "" {
+		password, err = r.GeneratePassword()
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	cl, err := r.getConnection()
+	if err != nil {
+		return "", "", err
+	}
+
+	// ACL SETUSER creates users that don't exist, so check first
+	user, err := cl.Do("ACL", "GETUSER", staticUser.Username)
+	if err != nil {
+		return "", "", err
+	}
+	if user == nil {
+		return "", "", fmt.Errorf("user %q does not exist", staticUser.Username)
+	}
+
+	if _, err := cl.Do("ACL", "SETUSER", staticUser.Username, "resetpass", ">"+password); err != nil {
+		return "", "", err
+	}
+
+	if err := r.persist(cl); err != nil {
+		return "", "", err
+	}
+
+	return staticUser.Username, password, nil
+}
+
+// RevokeUser deletes the ACL user. Revocation statements are not used.
+func (r *Redis) RevokeUser(statements dbplugin.Statements, username string) error {
+	r.Lock()
+	defer r.Unlock()
+
+	cl, err := r.getConnection()
+	if err != nil {
+		return err
+	}
+
+	// Deleting a user that doesn't exist is not an error
+	if _, err := cl.Do("ACL", "DELUSER", username); err != nil {
+		return err
+	}
+
+	return r.persist(cl)
+}
+
+// persist saves the ACL users according to the configured persistence mode
+func (r *Redis) persist(cl *client) error {
+	var err error
+	switch r.ConnectionProducer.(*redisConnectionProducer).PersistenceMode {
+	case persistenceModeACLFile:
+		_, err = cl.Do("ACL", "SAVE")
+	case persistenceModeConfig:
+		_, err = cl.Do("CONFIG", "REWRITE")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to persist ACL users: %s", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,388 @@
+package redis
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+)
+
+const testRedisRole = `["~*", "+@read"]`
+
+// testRedisServer is an in-process stand-in for a Redis server that supports
+// the ACL commands used by the plugin.
+type testRedisServer struct {
+	sync.Mutex
+	ln    net.Listener
+	users map[string]*testRedisUser
+	saves int
+}
+
+type testRedisUser struct {
+	enabled   bool
+	passwords []string
+	rules     []string
+}
+
+func prepareRedisTestServer(t *testing.T) (cleanup func(), connectionDetails map[string]interface{}, server *testRedisServer) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server = &testRedisServer{
+		ln: ln,
+		users: map[string]*testRedisUser{
+			"default": {enabled: true, passwords: []string{"secret"}, rules: []string{"+@all"}},
+		},
+	}
+	go server.serve()
+
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	connectionDetails = map[string]interface{}{
+		"host":     host,
+		"port":     port,
+		"username": "default",
+		"password": "secret",
+	}
+
+	return func() { ln.Close() }, connectionDetails, server
+}
+
+func (s *testRedisServer) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *testRedisServer) handle(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	authenticated := false
+	for {
+		req, err := readReply(r)
+		if err != nil {
+			return
+		}
+		values, ok := req.([]interface{})
+		if !ok || len(values) == 0 {
+			return
+		}
+		args := make([]string, len(values))
+		for i, v := range values {
+			args[i] = v.(string)
+		}
+
+		var reply string
+		if strings.ToUpper(args[0]) == "AUTH" {
+			if len(args) == 3 && s.authenticate(args[1], args[2]) {
+				authenticated = true
+				reply = "+OK\r\n"
+			} else {
+				reply = "-WRONGPASS invalid username-password pair\r\n"
+			}
+		} else if !authenticated {
+			reply = "-NOAUTH Authentication required.\r\n"
+		} else {
+			reply = s.command(args)
+		}
+
+		if _, err := conn.Write([]byte(reply)); err != nil {
+			return
+		}
+	}
+}
+
+func (s *testRedisServer) authenticate(username, password string) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	user, ok := s.users[username]
+	if !ok || !user.enabled {
+		return false
+	}
+	for _, p := range user.passwords {
+		if p == password {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *testRedisServer) command(args []string) string {
+	s.Lock()
+	defer s.Unlock()
+
+	cmd := strings.ToUpper(strings.Join(args[:min(2, len(args))], " "))
+	switch {
+	case cmd == "PING" || strings.HasPrefix(cmd, "PING "):
+		return "+PONG\r\n"
+	case cmd == "ACL SAVE":
+		s.saves++
+		return "+OK\r\n"
+	case cmd == "ACL GETUSER" && len(args) == 3:
+		user, ok := s.users[args[2]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		flag := "off"
+		if user.enabled {
+			flag = "on"
+		}
+		return fmt.Sprintf("*2\r\n$5\r\nflags\r\n*1\r\n$%d\r\n%s\r\n", len(flag), flag)
+	case cmd == "ACL DELUSER":
+		var deleted int
+		for _, name := range args[2:] {
+			if _, ok := s.users[name]; ok {
+				delete(s.users, name)
+				deleted++
+			}
+		}
+		return ":" + strconv.Itoa(deleted) + "\r\n"
+	case cmd == "ACL SETUSER" && len(args) >= 3:
+		user, ok := s.users[args[2]]
+		if !ok {
+			user = &testRedisUser{}
+			s.users[args[2]] = user
+		}
+		for _, rule := range args[3:] {
+			switch {
+			case rule == "reset":
+				*user = testRedisUser{}
+			case rule == "resetpass":
+				user.passwords = nil
+			case rule == "on":
+				user.enabled = true
+			case rule == "off":
+				user.enabled = false
+			case strings.HasPrefix(rule, ">"):
+				user.passwords = append(user.passwords, rule[1:])
+			default:
+				user.rules = append(user.rules, rule)
+			}
+		}
+		return "+OK\r\n"
+	default:
+		return fmt.Sprintf("-ERR unknown command '%s'\r\n", args[0])
+	}
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func TestRedis_Initialize(t *testing.T) {
+	cleanup, connectionDetails, _ := prepareRedisTestServer(t)
+	defer cleanup()
+
+	dbRaw, err := New()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	db := dbRaw.(*Redis)
+	connProducer := db.ConnectionProducer.(*redisConnectionProducer)
+
+	err = db.Initialize(connectionDetails, true)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if !connProducer.Initialized {
+		t.Fatal("Database should be initalized")
+	}
+
+	err = db.Close()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Wrong credentials
+	connectionDetails["password"] = "wrong"
+	if err := db.Initialize(connectionDetails, true); err == nil {
+		t.Fatal("expected error")
+	}
+
+	// Invalid persistence mode
+	connectionDetails["password"] = "secret"
+	connectionDetails["persistence_mode"] = "NONE"
+	if err := db.Initialize(connectionDetails, false); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestRedis_CreateUser(t *testing.T) {
+	cleanup, connectionDetails, server := prepareRedisTestServer(t)
+	defer cleanup()
+
+	connectionDetails["persistence_mode"] = "ACLFILE"
+
+	dbRaw, _ := New()
+	db := dbRaw.(*Redis)
+	err := db.Initialize(connectionDetails, true)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer db.Close()
+
+	usernameConfig := dbplugin.UsernameConfig{
+		DisplayName: "test",
+		RoleName:    "test",
+	}
+
+	statements := dbplugin.Statements{}
+	_, _, err = db.CreateUser(statements, usernameConfig, time.Now().Add(time.Minute))
+	if err == nil {
+		t.Fatal("Expected error when no creation statement is provided")
+	}
+
+	statements.CreationStatements = `{"roles": []}`
+	_, _, err = db.CreateUser(statements, usernameConfig, time.Now().Add(time.Minute))
+	if err == nil {
+		t.Fatal("Expected error when the creation statement is not an array")
+	}
+
+	statements.CreationStatements = testRedisRole
+	username, password, err := db.CreateUser(statements, usernameConfig, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := testCredsExist(t, connectionDetails, username, password); err != nil {
+		t.Fatalf("Could not connect with new credentials: %s", err)
+	}
+
+	server.Lock()
+	rules := server.users[username].rules
+	saves := server.saves
+	server.Unlock()
+	if strings.Join(rules, " ") != "~* +@read" {
+		t.Fatalf("bad rules: %v", rules)
+	}
+	if saves != 1 {
+		t.Fatalf("expected the ACL users to be saved, got %d saves", saves)
+	}
+}
+
+func TestRedis_RevokeUser(t *testing.T) {
+	cleanup, connectionDetails, _ := prepareRedisTestServer(t)
+	defer cleanup()
+
+	dbRaw, _ := New()
+	db := dbRaw.(*Redis)
+	err := db.Initialize(connectionDetails, true)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer db.Close()
+
+	statements := dbplugin.Statements{
+		CreationStatements: testRedisRole,
+	}
+
+	usernameConfig := dbplugin.UsernameConfig{
+		DisplayName: "test",
+		RoleName:    "test",
+	}
+
+	username, password, err := db.CreateUser(statements, usernameConfig, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := testCredsExist(t, connectionDetails, username, password); err != nil {
+		t.Fatalf("Could not connect with new credentials: %s", err)
+	}
+
+	// Test default revocation statement
+	err = db.RevokeUser(statements, username)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err = testCredsExist(t, connectionDetails, username, password); err == nil {
+		t.Fatal("Credentials were not revoked")
+	}
+
+	// Revoking a user that no longer exists succeeds
+	if err := db.RevokeUser(statements, username); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestRedis_SetCredentials(t *testing.T) {
+	cleanup, connectionDetails, _ := prepareRedisTestServer(t)
+	defer cleanup()
+
+	dbRaw, _ := New()
+	db := dbRaw.(*Redis)
+	err := db.Initialize(connectionDetails, true)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer db.Close()
+
+	username, password, err := db.CreateUser(dbplugin.Statements{
+		CreationStatements: testRedisRole,
+	}, dbplugin.UsernameConfig{
+		DisplayName: "test",
+		RoleName:    "test",
+	}, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	_, newPassword, err := db.SetCredentials(dbplugin.Statements{}, dbplugin.StaticUserConfig{
+		Username: username,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if newPassword == "" || newPassword == password {
+		t.Fatalf("expected a new password, got %q", newPassword)
+	}
+
+	if err := testCredsExist(t, connectionDetails, username, newPassword); err != nil {
+		t.Fatalf("Could not connect with new credentials: %s", err)
+	}
+	if err := testCredsExist(t, connectionDetails, username, password); err == nil {
+		t.Fatal("old password should no longer work")
+	}
+
+	// Users are not created by rotating their password
+	_, _, err = db.SetCredentials(dbplugin.Statements{}, dbplugin.StaticUserConfig{
+		Username: "unknown",
+		Password: "password",
+	})
+	if err == nil {
+		t.Fatal("expected error for unknown user")
+	}
+}
+
+func testCredsExist(t testing.TB, connectionDetails map[string]interface{}, username, password string) error {
+	details := make(map[string]interface{}, len(connectionDetails))
+	for k, v := range connectionDetails {
+		details[k] = v
+	}
+	details["username"] = username
+	details["password"] = password
+
+	dbRaw, _ := New()
+	db := dbRaw.(*Redis)
+	defer db.Close()
+
+	return db.Initialize(details, true)
+}
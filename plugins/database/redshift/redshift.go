@@ -0,0 +1,333 @@
+package redshift
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/plugins"
+	"github.com/hashicorp/vault/plugins/helper/database/connutil"
+	"github.com/hashicorp/vault/plugins/helper/database/credsutil"
+	"github.com/hashicorp/vault/plugins/helper/database/dbutil"
+	"github.com/lib/pq"
+)
+
+const (
+	redshiftTypeName = "redshift"
+
+	// Redshift speaks the PostgreSQL protocol, so the postgres driver is used
+	sqlDriverName = "postgres"
+
+	defaultRedshiftRenewSQL = `
+ALTER USER "{{name}}" VALID UNTIL '{{expiration}}';
+`
+	defaultRedshiftRotationSQL = `
+ALTER USER "{{name}}" WITH PASSWORD '{{password}}';
+`
+)
+
+// New implements builtinplugins.BuiltinFactory
+func New() (interface{}, error) {
+	connProducer := &connutil.SQLConnectionProducer{}
+	connProducer.Type = sqlDriverName
+
+	credsProducer := &credsutil.SQLCredentialsProducer{
+		DisplayNameLen: 8,
+		RoleNameLen:    8,
+		UsernameLen:    63,
+		Separator:      "-",
+	}
+
+	dbType := &Redshift{
+		ConnectionProducer:     connProducer,
+		SQLCredentialsProducer: credsProducer,
+	}
+
+	return dbType, nil
+}
+
+// Run instantiates a Redshift object, and runs the RPC server for the plugin
+func Run(apiTLSConfig *api.TLSConfig) error {
+	dbType, err := New()
+	if err != nil {
+		return err
+	}
+
+	plugins.Serve(dbType.(*Redshift), apiTLSConfig)
+
+	return nil
+}
+
+type Redshift struct {
+	connutil.ConnectionProducer
+	*credsutil.SQLCredentialsProducer
+}
+
+func (r *Redshift) Type() (string, error) {
+	return redshiftTypeName, nil
+}
+
+// GenerateUsername generates a lowercase username, since Redshift folds user
+// names to lowercase even when they are quoted.
+func (r *Redshift) GenerateUsername(usernameConfig dbplugin.UsernameConfig) (string, error) {
+	username, err := r.SQLCredentialsProducer.GenerateUsername(usernameConfig)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.ToLower(username), nil
+}
+
+func (r *Redshift) getConnection() (*sql.DB, error) {
+	db, err := r.Connection()
+	if err != nil {
+		return nil, err
+	}
+
+	return db.(*sql.DB), nil
+}
+
+// executeStatements runs the statements in a transaction. The statements are
+// executed directly rather than prepared, since all values are templated into
+// them.
+func (r *Redshift) executeStatements(stmts string, data map[string]string) error {
+	db, err := r.getConnection()
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		tx.Rollback()
+	}()
+
+	for _, query := range strutil.ParseArbitraryStringSlice(stmts, ";") {
+		query = strings.TrimSpace(query)
+		if len(query) == 0 {
+			continue
+		}
+
+		if _, err := tx.Exec(dbutil.QueryHelper(query, data)); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *Redshift) CreateUser(statements dbplugin.Statements, usernameConfig dbplugin.UsernameConfig, expiration time.Time) (username string, password string, err error) {
+	if statements.CreationStatements == "" {
+		return "", "", dbutil.ErrEmptyCreationStatement
+	}
+
+	// Grab the lock
+	r.Lock()
+	defer r.Unlock()
+
+	username, err = r.GenerateUsername(usernameConfig)
+	if err != nil {
+		return "", "", err
+	}
+
+	password, err = r.GeneratePassword()
+	if err != nil {
+		return "", "", err
+	}
+
+	expirationStr, err := r.GenerateExpiration(expiration)
+	if err != nil {
+		return "", "", err
+	}
+
+	err = r.executeStatements(statements.CreationStatements, map[string]string{
+		"name":       username,
+		"password":   password,
+		"expiration": expirationStr,
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	return username, password, nil
+}
+
+func (r *Redshift) RenewUser(statements dbplugin.Statements, username string, expiration time.Time) error {
+	r.Lock()
+	defer r.Unlock()
+
+	renewStmts := statements.RenewStatements
+	if renewStmts == "" {
+		renewStmts = defaultRedshiftRenewSQL
+	}
+
+	expirationStr, err := r.GenerateExpiration(expiration)
+	if err != nil {
+		return err
+	}
+
+	return r.executeStatements(renewStmts, map[string]string{
+		"name":       username,
+		"expiration": expirationStr,
+	})
+}
+
+// SetCredentials sets the password of an existing user, generating a new
+// password if one is not provided.
+func (r *Redshift) SetCredentials(statements dbplugin.Statements, staticUser dbplugin.StaticUserConfig) (username string, password string, err error) {
+	if staticUser.Username == "" {
+		return "", "", dbutil.ErrEmptyUsername
+	}
+
+	rotationStmts := statements.RotationStatements
+	if rotationStmts == "" {
+		rotationStmts = defaultRedshiftRotationSQL
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	password = staticUser.Password
+	if password == "" {
+		password, err = r.GeneratePassword()
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	err = r.executeStatements(rotationStmts, map[string]string{
+		"name":     staticUser.Username,
+		"password": password,
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	return staticUser.Username, password, nil
+}
+
+func (r *Redshift) RevokeUser(statements dbplugin.Statements, username string) error {
+	// Grab the lock
+	r.Lock()
+	defer r.Unlock()
+
+	if statements.RevocationStatements == "" {
+		return r.defaultRevokeUser(username)
+	}
+
+	return r.executeStatements(statements.RevocationStatements, map[string]string{
+		"name": username,
+	})
+}
+
+func (r *Redshift) defaultRevokeUser(username string) error {
+	db, err := r.getConnection()
+	if err != nil {
+		return err
+	}
+
+	// Check if the user exists
+	var exists bool
+	err = db.QueryRow(fmt.Sprintf(
+		"SELECT exists (SELECT usename FROM pg_user WHERE usename=%s);",
+		quoteLiteral(username))).Scan(&exists)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	if exists == false {
+		return nil
+	}
+
+	// Query for permissions; we need to revoke permissions before we can drop
+	// the user. Redshift doesn't have the role_column_grants view, so look at
+	// table privileges instead.
+	// This isn't done in a transaction because even if we fail along the way,
+	// we want to remove as much access as possible
+	rows, err := db.Query(fmt.Sprintf(
+		"SELECT DISTINCT table_schema FROM information_schema.table_privileges WHERE grantee=%s;",
+		quoteLiteral(username)))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	const initialNumRevocations = 16
+	revocationStmts := make([]string, 0, initialNumRevocations)
+	for rows.Next() {
+		var schema string
+		err = rows.Scan(&schema)
+		if err != nil {
+			// keep going; remove as many permissions as possible right now
+			continue
+		}
+		revocationStmts = append(revocationStmts, fmt.Sprintf(
+			`REVOKE ALL PRIVILEGES ON ALL TABLES IN SCHEMA %s FROM %s;`,
+			pq.QuoteIdentifier(schema),
+			pq.QuoteIdentifier(username)))
+
+		revocationStmts = append(revocationStmts, fmt.Sprintf(
+			`REVOKE USAGE ON SCHEMA %s FROM %s;`,
+			pq.QuoteIdentifier(schema),
+			pq.QuoteIdentifier(username)))
+	}
+
+	// for good measure, revoke all privileges and usage on schema public
+	revocationStmts = append(revocationStmts, fmt.Sprintf(
+		`REVOKE ALL PRIVILEGES ON ALL TABLES IN SCHEMA public FROM %s;`,
+		pq.QuoteIdentifier(username)))
+
+	revocationStmts = append(revocationStmts, fmt.Sprintf(
+		"REVOKE USAGE ON SCHEMA public FROM %s;",
+		pq.QuoteIdentifier(username)))
+
+	// get the current database name so we can revoke the database
+	// privileges of this user; Redshift has no CONNECT privilege
+	var dbname sql.NullString
+	if err := db.QueryRow("SELECT current_database();").Scan(&dbname); err != nil {
+		return err
+	}
+
+	if dbname.Valid {
+		revocationStmts = append(revocationStmts, fmt.Sprintf(
+			`REVOKE ALL PRIVILEGES ON DATABASE %s FROM %s;`,
+			pq.QuoteIdentifier(dbname.String),
+			pq.QuoteIdentifier(username)))
+	}
+
+	// again, here, we do not stop on error, as we want to remove as
+	// many permissions as possible right now
+	var lastStmtError error
+	for _, query := range revocationStmts {
+		if _, err := db.Exec(query); err != nil {
+			lastStmtError = err
+		}
+	}
+
+	// can't drop if not all privileges are revoked
+	if rows.Err() != nil {
+		return fmt.Errorf("could not generate revocation statements for all rows: %s", rows.Err())
+	}
+	if lastStmtError != nil {
+		return fmt.Errorf("could not perform all revocation statements: %s", lastStmtError)
+	}
+
+	// Drop this user
+	if _, err := db.Exec(fmt.Sprintf(
+		`DROP USER IF EXISTS %s;`, pq.QuoteIdentifier(username))); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// quoteLiteral quotes a string for use as a literal in a statement
+func quoteLiteral(literal string) string {
+	return "'" + strings.Replace(literal, "'", "''", -1) + "'"
+}
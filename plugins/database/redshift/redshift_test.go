@@ -0,0 +1,604 @@
+package redshift
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+	"github.com/hashicorp/vault/plugins/helper/database/connutil"
+)
+
+const testRedshiftRole = `
+CREATE USER "{{name}}" WITH PASSWORD '{{password}}' VALID UNTIL '{{expiration}}';
+GRANT SELECT ON ALL TABLES IN SCHEMA public TO "{{name}}";
+`
+
+const testRedshiftRevocationSQL = `
+REVOKE ALL PRIVILEGES ON ALL TABLES IN SCHEMA public FROM "{{name}}";
+DROP USER IF EXISTS "{{name}}";
+`
+
+// testRedshiftServer is an in-process stand-in for a Redshift cluster. It
+// speaks enough of the PostgreSQL protocol for the postgres driver to
+// authenticate with cleartext passwords and run simple queries, and
+// understands the statements used by the plugin and these tests.
+type testRedshiftServer struct {
+	sync.Mutex
+	ln    net.Listener
+	users map[string]*testRedshiftUser
+
+	// statements records every statement run, in order
+	statements []string
+}
+
+type testRedshiftUser struct {
+	password   string
+	validUntil string
+	schemas    []string
+}
+
+var (
+	createUserRe       = regexp.MustCompile(`^CREATE USER "([^"]+)" WITH PASSWORD '([^']*)'(?: VALID UNTIL '([^']*)')?$`)
+	alterPasswordRe    = regexp.MustCompile(`^ALTER USER "([^"]+)" WITH PASSWORD '([^']*)'$`)
+	alterValidUntilRe  = regexp.MustCompile(`^ALTER USER "([^"]+)" VALID UNTIL '([^']*)'$`)
+	dropUserRe         = regexp.MustCompile(`^DROP USER IF EXISTS "([^"]+)"$`)
+	grantRe            = regexp.MustCompile(`^GRANT .* IN SCHEMA (\w+) TO "([^"]+)"$`)
+	revokeRe           = regexp.MustCompile(`^REVOKE .* FROM "([^"]+)"$`)
+	userExistsRe       = regexp.MustCompile(`^SELECT exists \(SELECT usename FROM pg_user WHERE usename='([^']*)'\)$`)
+	tablePrivilegesRe  = regexp.MustCompile(`^SELECT DISTINCT table_schema FROM information_schema.table_privileges WHERE grantee='([^']*)'$`)
+	currentDatabaseSQL = "SELECT current_database()"
+)
+
+func prepareRedshiftTestServer(t *testing.T) (cleanup func(), connURL string, server *testRedshiftServer) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server = &testRedshiftServer{
+		ln: ln,
+		users: map[string]*testRedshiftUser{
+			"admin": {password: "secret"},
+		},
+	}
+	go server.serve()
+
+	connURL = fmt.Sprintf("postgres://{{username}}:{{password}}@%s/dev?sslmode=disable", ln.Addr())
+
+	return func() { ln.Close() }, connURL, server
+}
+
+func (s *testRedshiftServer) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+// pgMessage builds a backend message
+func pgMessage(typ byte, parts ...interface{}) []byte {
+	var body []byte
+	for _, part := range parts {
+		switch p := part.(type) {
+		case byte:
+			body = append(body, p)
+		case int16:
+			body = append(body, byte(p>>8), byte(p))
+		case int32:
+			body = append(body, byte(p>>24), byte(p>>16), byte(p>>8), byte(p))
+		case string:
+			body = append(body, p...)
+			body = append(body, 0)
+		case []byte:
+			body = append(body, p...)
+		}
+	}
+	msg := make([]byte, 5, len(body)+5)
+	msg[0] = typ
+	binary.BigEndian.PutUint32(msg[1:], uint32(len(body)+4))
+	return append(msg, body...)
+}
+
+func pgError(code, message string) []byte {
+	return pgMessage('E', byte('S'), "ERROR", byte('C'), code, byte('M'), message, byte(0))
+}
+
+func readCString(b []byte) (string, []byte) {
+	i := strings.IndexByte(string(b), 0)
+	if i < 0 {
+		return string(b), nil
+	}
+	return string(b[:i]), b[i+1:]
+}
+
+func (s *testRedshiftServer) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	// Startup
+	var params map[string]string
+	for params == nil {
+		var length int32
+		if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+			return
+		}
+		body := make([]byte, length-4)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return
+		}
+		if binary.BigEndian.Uint32(body) == 80877103 {
+			// SSL is not supported
+			conn.Write([]byte{'N'})
+			continue
+		}
+		params = make(map[string]string)
+		for rest := body[4:]; len(rest) > 1; {
+			var k, v string
+			k, rest = readCString(rest)
+			v, rest = readCString(rest)
+			params[k] = v
+		}
+	}
+
+	// Cleartext password authentication
+	conn.Write(pgMessage('R', int32(3)))
+	typ, body, err := readPGMessage(r)
+	if err != nil || typ != 'p' {
+		return
+	}
+	password, _ := readCString(body)
+	if !s.authenticate(params["user"], password) {
+		conn.Write(pgMessage('E', byte('S'), "FATAL", byte('C'), "28P01", byte('M'), "password authentication failed for user "+params["user"], byte(0)))
+		return
+	}
+	conn.Write(pgMessage('R', int32(0)))
+
+	status := byte('I')
+	conn.Write(pgMessage('Z', status))
+
+	for {
+		typ, body, err := readPGMessage(r)
+		if err != nil || typ == 'X' {
+			return
+		}
+		if typ != 'Q' {
+			conn.Write(pgError("0A000", fmt.Sprintf("unsupported message %q", typ)))
+			conn.Write(pgMessage('Z', status))
+			continue
+		}
+
+		query, _ := readCString(body)
+		query = strings.TrimSuffix(strings.TrimSpace(query), ";")
+
+		var resp []byte
+		switch {
+		case query == "BEGIN" || strings.HasPrefix(query, "BEGIN "):
+			resp, status = pgMessage('C', "BEGIN"), 'T'
+		case query == "COMMIT":
+			if status == 'E' {
+				resp = pgMessage('C', "ROLLBACK")
+			} else {
+				resp = pgMessage('C', "COMMIT")
+			}
+			status = 'I'
+		case query == "ROLLBACK":
+			resp, status = pgMessage('C', "ROLLBACK"), 'I'
+		default:
+			resp = s.execute(query)
+			if resp[0] == 'E' && status == 'T' {
+				status = 'E'
+			}
+		}
+
+		conn.Write(resp)
+		conn.Write(pgMessage('Z', status))
+	}
+}
+
+func readPGMessage(r *bufio.Reader) (byte, []byte, error) {
+	typ, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	var length int32
+	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+		return 0, nil, err
+	}
+	body := make([]byte, length-4)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return typ, body, nil
+}
+
+func (s *testRedshiftServer) authenticate(username, password string) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	user, ok := s.users[username]
+	return ok && user.password == password
+}
+
+// execute runs a statement and returns the response messages
+func (s *testRedshiftServer) execute(query string) []byte {
+	s.Lock()
+	defer s.Unlock()
+
+	s.statements = append(s.statements, query)
+
+	if m := createUserRe.FindStringSubmatch(query); m != nil {
+		if _, ok := s.users[m[1]]; ok {
+			return pgError("42710", fmt.Sprintf("user %q already exists", m[1]))
+		}
+		s.users[m[1]] = &testRedshiftUser{password: m[2], validUntil: m[3]}
+		return pgMessage('C', "CREATE USER")
+	}
+	if m := alterPasswordRe.FindStringSubmatch(query); m != nil {
+		user, ok := s.users[m[1]]
+		if !ok {
+			return pgError("42704", fmt.Sprintf("user %q does not exist", m[1]))
+		}
+		user.password = m[2]
+		return pgMessage('C', "ALTER USER")
+	}
+	if m := alterValidUntilRe.FindStringSubmatch(query); m != nil {
+		user, ok := s.users[m[1]]
+		if !ok {
+			return pgError("42704", fmt.Sprintf("user %q does not exist", m[1]))
+		}
+		user.validUntil = m[2]
+		return pgMessage('C', "ALTER USER")
+	}
+	if m := dropUserRe.FindStringSubmatch(query); m != nil {
+		delete(s.users, m[1])
+		return pgMessage('C', "DROP USER")
+	}
+	if m := grantRe.FindStringSubmatch(query); m != nil {
+		user, ok := s.users[m[2]]
+		if !ok {
+			return pgError("42704", fmt.Sprintf("user %q does not exist", m[2]))
+		}
+		user.schemas = append(user.schemas, m[1])
+		return pgMessage('C', "GRANT")
+	}
+	if m := revokeRe.FindStringSubmatch(query); m != nil {
+		if user, ok := s.users[m[1]]; ok {
+			user.schemas = nil
+		}
+		return pgMessage('C', "REVOKE")
+	}
+	if m := userExistsRe.FindStringSubmatch(query); m != nil {
+		_, ok := s.users[m[1]]
+		value := "f"
+		if ok {
+			value = "t"
+		}
+		return rowsResponse("exists", 16, value)
+	}
+	if m := tablePrivilegesRe.FindStringSubmatch(query); m != nil {
+		var schemas []string
+		if user, ok := s.users[m[1]]; ok {
+			schemas = user.schemas
+		}
+		return rowsResponse("table_schema", 25, schemas...)
+	}
+	if query == currentDatabaseSQL {
+		return rowsResponse("current_database", 25, "dev")
+	}
+
+	return pgError("42601", "syntax error at or near "+query)
+}
+
+// rowsResponse returns a single column result set with the given text values
+func rowsResponse(column string, typeOID int32, values ...string) []byte {
+	resp := pgMessage('T', int16(1), column, int32(0), int16(0), typeOID, int16(-1), int32(-1), int16(0))
+	for _, v := range values {
+		resp = append(resp, pgMessage('D', int16(1), int32(len(v)), []byte(v))...)
+	}
+	return append(resp, pgMessage('C', fmt.Sprintf("SELECT %d", len(values)))...)
+}
+
+func (s *testRedshiftServer) user(name string) *testRedshiftUser {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.users[name]
+}
+
+func TestRedshift_Initialize(t *testing.T) {
+	cleanup, connURL, _ := prepareRedshiftTestServer(t)
+	defer cleanup()
+
+	connectionDetails := map[string]interface{}{
+		"connection_url":       connURL,
+		"username":             "admin",
+		"password":             "secret",
+		"max_open_connections": 5,
+	}
+
+	dbRaw, _ := New()
+	db := dbRaw.(*Redshift)
+
+	connProducer := db.ConnectionProducer.(*connutil.SQLConnectionProducer)
+
+	err := db.Initialize(connectionDetails, true)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if !connProducer.Initialized {
+		t.Fatal("Database should be initalized")
+	}
+
+	err = db.Close()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	connectionDetails["password"] = "wrong"
+	if err := db.Initialize(connectionDetails, true); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestRedshift_CreateUser(t *testing.T) {
+	cleanup, connURL, server := prepareRedshiftTestServer(t)
+	defer cleanup()
+
+	connectionDetails := map[string]interface{}{
+		"connection_url": connURL,
+		"username":       "admin",
+		"password":       "secret",
+	}
+
+	dbRaw, _ := New()
+	db := dbRaw.(*Redshift)
+	err := db.Initialize(connectionDetails, true)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer db.Close()
+
+	usernameConfig := dbplugin.UsernameConfig{
+		DisplayName: "Test",
+		RoleName:    "Test",
+	}
+
+	// Test with no configured Creation Statement
+	_, _, err = db.CreateUser(dbplugin.Statements{}, usernameConfig, time.Now().Add(time.Minute))
+	if err == nil {
+		t.Fatal("Expected error when no creation statement is provided")
+	}
+
+	statements := dbplugin.Statements{
+		CreationStatements: testRedshiftRole,
+	}
+
+	username, password, err := db.CreateUser(statements, usernameConfig, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if username != strings.ToLower(username) {
+		t.Fatalf("username should be lowercase: %s", username)
+	}
+
+	if err = testCredsExist(t, connURL, username, password); err != nil {
+		t.Fatalf("Could not connect with new credentials: %s", err)
+	}
+
+	if user := server.user(username); user.validUntil == "" || len(user.schemas) != 1 {
+		t.Fatalf("bad user: %#v", user)
+	}
+
+	// A failing statement rolls back the transaction
+	statements.CreationStatements = `CREATE USER "{{name}}" WITH PASSWORD '{{password}}'; SELECT nonsense`
+	if _, _, err := db.CreateUser(statements, usernameConfig, time.Now().Add(time.Minute)); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestRedshift_RenewUser(t *testing.T) {
+	cleanup, connURL, server := prepareRedshiftTestServer(t)
+	defer cleanup()
+
+	connectionDetails := map[string]interface{}{
+		"connection_url": connURL,
+		"username":       "admin",
+		"password":       "secret",
+	}
+
+	dbRaw, _ := New()
+	db := dbRaw.(*Redshift)
+	err := db.Initialize(connectionDetails, true)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer db.Close()
+
+	statements := dbplugin.Statements{
+		CreationStatements: testRedshiftRole,
+	}
+
+	usernameConfig := dbplugin.UsernameConfig{
+		DisplayName: "test",
+		RoleName:    "test",
+	}
+
+	username, _, err := db.CreateUser(statements, usernameConfig, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expiration := time.Now().Add(time.Hour)
+	err = db.RenewUser(statements, username, expiration)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected, _ := db.GenerateExpiration(expiration)
+	if user := server.user(username); user.validUntil != expected {
+		t.Fatalf("expected valid until %q, got %q", expected, user.validUntil)
+	}
+}
+
+func TestRedshift_RevokeUser(t *testing.T) {
+	cleanup, connURL, server := prepareRedshiftTestServer(t)
+	defer cleanup()
+
+	connectionDetails := map[string]interface{}{
+		"connection_url": connURL,
+		"username":       "admin",
+		"password":       "secret",
+	}
+
+	dbRaw, _ := New()
+	db := dbRaw.(*Redshift)
+	err := db.Initialize(connectionDetails, true)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer db.Close()
+
+	statements := dbplugin.Statements{
+		CreationStatements: testRedshiftRole,
+	}
+
+	usernameConfig := dbplugin.UsernameConfig{
+		DisplayName: "test",
+		RoleName:    "test",
+	}
+
+	username, password, err := db.CreateUser(statements, usernameConfig, time.Now().Add(2*time.Second))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err = testCredsExist(t, connURL, username, password); err != nil {
+		t.Fatalf("Could not connect with new credentials: %s", err)
+	}
+
+	// Test default revoke statements
+	err = db.RevokeUser(statements, username)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := testCredsExist(t, connURL, username, password); err == nil {
+		t.Fatal("Credentials were not revoked")
+	}
+
+	server.Lock()
+	var revoked bool
+	for _, stmt := range server.statements {
+		if stmt == fmt.Sprintf(`REVOKE ALL PRIVILEGES ON DATABASE "dev" FROM "%s"`, username) {
+			revoked = true
+		}
+	}
+	server.Unlock()
+	if !revoked {
+		t.Fatal("database privileges were not revoked")
+	}
+
+	// Revoking a user that no longer exists succeeds
+	if err := db.RevokeUser(statements, username); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	username, password, err = db.CreateUser(statements, usernameConfig, time.Now().Add(2*time.Second))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err = testCredsExist(t, connURL, username, password); err != nil {
+		t.Fatalf("Could not connect with new credentials: %s", err)
+	}
+
+	// Test custom revoke statements
+	statements.RevocationStatements = testRedshiftRevocationSQL
+	err = db.RevokeUser(statements, username)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := testCredsExist(t, connURL, username, password); err == nil {
+		t.Fatal("Credentials were not revoked")
+	}
+}
+
+func TestRedshift_SetCredentials(t *testing.T) {
+	cleanup, connURL, _ := prepareRedshiftTestServer(t)
+	defer cleanup()
+
+	connectionDetails := map[string]interface{}{
+		"connection_url": connURL,
+		"username":       "admin",
+		"password":       "secret",
+	}
+
+	dbRaw, _ := New()
+	db := dbRaw.(*Redshift)
+	err := db.Initialize(connectionDetails, true)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer db.Close()
+
+	username, password, err := db.CreateUser(dbplugin.Statements{
+		CreationStatements: testRedshiftRole,
+	}, dbplugin.UsernameConfig{
+		DisplayName: "test",
+		RoleName:    "test",
+	}, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	_, newPassword, err := db.SetCredentials(dbplugin.Statements{}, dbplugin.StaticUserConfig{
+		Username: username,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if newPassword == "" || newPassword == password {
+		t.Fatalf("expected a new password, got %q", newPassword)
+	}
+
+	if err := testCredsExist(t, connURL, username, newPassword); err != nil {
+		t.Fatalf("Could not connect with new credentials: %s", err)
+	}
+	if err := testCredsExist(t, connURL, username, password); err == nil {
+		t.Fatal("old password should no longer work")
+	}
+
+	_, _, err = db.SetCredentials(dbplugin.Statements{}, dbplugin.StaticUserConfig{
+		Username: "unknown",
+		Password: "password",
+	})
+	if err == nil {
+		t.Fatal("expected error for unknown user")
+	}
+}
+
+func testCredsExist(t testing.TB, connURL, username, password string) error {
+	// Log in with the new creds
+	connURL = strings.Replace(connURL, "{{username}}:{{password}}", fmt.Sprintf("%s:%s", username, password), 1)
+	db, err := sql.Open("postgres", connURL)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.Ping()
+}
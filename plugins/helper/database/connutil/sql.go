@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/hashicorp/vault/helper/parseutil"
+	"github.com/hashicorp/vault/plugins/helper/database/dbutil"
 	"github.com/mitchellh/mapstructure"
 )
 
@@ -17,6 +18,8 @@ type SQLConnectionProducer struct {
 	MaxOpenConnections       int         `json:"max_open_connections" structs:"max_open_connections" mapstructure:"max_open_connections"`
 	MaxIdleConnections       int         `json:"max_idle_connections" structs:"max_idle_connections" mapstructure:"max_idle_connections"`
 	MaxConnectionLifetimeRaw interface{} `json:"max_connection_lifetime" structs:"max_connection_lifetime" mapstructure:"max_connection_lifetime"`
+	Username                 string      `json:"username" structs:"username" mapstructure:"username"`
+	Password                 string      `json:"password" structs:"password" mapstructure:"password"`
 
 	Type                  string
 	maxConnectionLifetime time.Duration
@@ -95,8 +98,12 @@ func (c *SQLConnectionProducer) Connection() (interface{}, error) {
 		dbType = "sqlserver"
 	}
 
-	// Otherwise, attempt to make connection
-	conn := c.ConnectionURL
+	// Otherwise, attempt to make connection. The credentials can be set
+	// separately from the URL so that Vault is able to rotate them.
+	conn := dbutil.QueryHelper(c.ConnectionURL, map[string]string{
+		"username": c.Username,
+		"password": c.Password,
+	})
 
 	// Ensure timezone is set to UTC for all the conenctions
 	if strings.HasPrefix(conn, "postgres://") || strings.HasPrefix(conn, "postgresql://") {
@@ -1,8 +1,11 @@
 package credsutil
 
 import (
+	"regexp"
 	"strings"
 	"testing"
+
+	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
 )
 
 func TestRandomAlphaNumeric(t *testing.T) {
@@ -26,3 +29,79 @@ func TestRandomAlphaNumeric(t *testing.T) {
 		t.Fatalf("Expected %s to contain %s", s, reqStr)
 	}
 }
+
+func TestSQLCredentialsProducer_Configure(t *testing.T) {
+	scp := &SQLCredentialsProducer{
+		DisplayNameLen: 10,
+		RoleNameLen:    10,
+		UsernameLen:    40,
+		Separator:      "-",
+	}
+	usernameConfig := dbplugin.UsernameConfig{
+		DisplayName: "token-display-name",
+		RoleName:    "readonly",
+	}
+
+	err := scp.Configure(map[string]interface{}{
+		"username_template": `{{.RoleName}}_{{.DisplayName | truncate 5}}_{{random 8}}`,
+		"password_policy":   "length = 12\nrule \"charset\" {\n  charset = \"xyz\"\n}",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	username, err := scp.GenerateUsername(usernameConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !regexp.MustCompile(`^readonly_token_[a-zA-Z0-9]{8}$`).MatchString(username) {
+		t.Fatalf("bad username: %q", username)
+	}
+
+	password, err := scp.GeneratePassword()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !regexp.MustCompile(`^[xyz]{12}$`).MatchString(password) {
+		t.Fatalf("bad password: %q", password)
+	}
+
+	// Templated usernames are never truncated
+	err = scp.Configure(map[string]interface{}{
+		"username_template": `{{.DisplayName}}-{{random 30}}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := scp.GenerateUsername(usernameConfig); err == nil {
+		t.Fatal("expected error for a username that is too long")
+	}
+
+	// Without settings the default formats are used
+	if err := scp.Configure(map[string]interface{}{}); err != nil {
+		t.Fatal(err)
+	}
+	username, err = scp.GenerateUsername(usernameConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(username, "v-token-disp-readonly-") {
+		t.Fatalf("bad username: %q", username)
+	}
+	password, err = scp.GeneratePassword()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(password) != 20 {
+		t.Fatalf("bad password: %q", password)
+	}
+
+	for _, conf := range []map[string]interface{}{
+		{"username_template": `{{.RoleName`},
+		{"password_policy": `length = 10`},
+	} {
+		if err := scp.Configure(conf); err == nil {
+			t.Fatalf("%v: expected error", conf)
+		}
+	}
+}
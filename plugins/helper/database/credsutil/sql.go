@@ -6,6 +6,8 @@ import (
 	"time"
 
 	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+	"github.com/hashicorp/vault/helper/random"
+	"github.com/hashicorp/vault/helper/template"
 )
 
 // SQLCredentialsProducer implements CredentialsProducer and provides a generic credentials producer for most sql database types.
@@ -14,9 +16,56 @@ type SQLCredentialsProducer struct {
 	RoleNameLen    int
 	UsernameLen    int
 	Separator      string
+
+	usernameTemplate *template.StringTemplate
+	passwordPolicy   *random.StringGenerator
+}
+
+// Configure sets the template usernames are generated from and the policy
+// passwords are generated with. The "username_template" setting is a template
+// rendered with the DisplayName and RoleName of the username config, see the
+// template helper for the available functions. The "password_policy" setting
+// is the definition of a password policy, see random.ParsePolicy. If either is
+// not set, the producer's default format is used.
+func (scp *SQLCredentialsProducer) Configure(conf map[string]interface{}) error {
+	scp.usernameTemplate = nil
+	if raw, _ := conf["username_template"].(string); raw != "" {
+		tmpl, err := template.NewTemplate(raw)
+		if err != nil {
+			return fmt.Errorf("invalid username_template: %s", err)
+		}
+		scp.usernameTemplate = tmpl
+	}
+
+	scp.passwordPolicy = nil
+	if raw, _ := conf["password_policy"].(string); raw != "" {
+		policy, err := random.ParsePolicy(raw)
+		if err != nil {
+			return err
+		}
+		scp.passwordPolicy = policy
+	}
+
+	return nil
 }
 
 func (scp *SQLCredentialsProducer) GenerateUsername(config dbplugin.UsernameConfig) (string, error) {
+	if scp.usernameTemplate != nil {
+		username, err := scp.usernameTemplate.Generate(config)
+		if err != nil {
+			return "", err
+		}
+		if username == "" {
+			return "", fmt.Errorf("username_template produced an empty username")
+		}
+		// Templated usernames are not truncated, as that could make them
+		// collide; the template should truncate its values instead
+		if scp.UsernameLen > 0 && len(username) > scp.UsernameLen {
+			return "", fmt.Errorf("username %q generated from username_template is longer than the maximum of %d characters", username, scp.UsernameLen)
+		}
+		return username, nil
+	}
+
 	displayName := config.DisplayName
 	if scp.DisplayNameLen > 0 && len(displayName) > scp.DisplayNameLen {
 		displayName = displayName[:scp.DisplayNameLen]
@@ -40,6 +89,10 @@ func (scp *SQLCredentialsProducer) GenerateUsername(config dbplugin.UsernameConf
 }
 
 func (scp *SQLCredentialsProducer) GeneratePassword() (string, error) {
+	if scp.passwordPolicy != nil {
+		return scp.passwordPolicy.Generate()
+	}
+
 	password, err := RandomAlphaNumeric(20)
 	if err != nil {
 		return "", err
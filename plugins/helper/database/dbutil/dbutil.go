@@ -7,7 +7,9 @@ import (
 )
 
 var (
-	ErrEmptyCreationStatement = errors.New("empty creation statements")
+	ErrEmptyCreationStatement    = errors.New("empty creation statements")
+	ErrEmptyUsername             = errors.New("empty username")
+	ErrSetCredentialsUnsupported = errors.New("setting credentials of existing users is not supported by this plugin")
 )
 
 // Query templates a query for us.
@@ -19,17 +19,30 @@ type ACL struct {
 	// globRules contains the path policies that glob
 	globRules *radix.Tree
 
+	// wildcardRules contains the path policies that have '+' segments, keyed
+	// by their prefix followed by '*' if they glob
+	wildcardRules *radix.Tree
+
 	// root is enabled if the "root" named policy is present.
 	root bool
 }
 
 // New is used to construct a policy based ACL from a set of policies.
+// Templated paths are not granted.
 func NewACL(policies []*Policy) (*ACL, error) {
+	return NewTemplatedACL(policies, nil)
+}
+
+// NewTemplatedACL is used to construct a policy based ACL from a set of
+// policies, rendering templated paths with the given data. Paths whose
+// templates cannot be resolved are not granted.
+func NewTemplatedACL(policies []*Policy, templateData *ACLTemplateData) (*ACL, error) {
 	// Initialize
 	a := &ACL{
-		exactRules: radix.New(),
-		globRules:  radix.New(),
-		root:       false,
+		exactRules:    radix.New(),
+		globRules:     radix.New(),
+		wildcardRules: radix.New(),
+		root:          false,
 	}
 
 	// Inject each policy
@@ -43,20 +56,35 @@ func NewACL(policies []*Policy) (*ACL, error) {
 			a.root = true
 		}
 		for _, pc := range policy.Paths {
+			prefix := pc.Prefix
+			if pc.Templated {
+				var ok bool
+				prefix, ok = renderPathTemplate(pc.Prefix, templateData)
+				if !ok {
+					continue
+				}
+			}
+
 			// Check which tree to use
 			tree := a.exactRules
-			if pc.Glob {
+			switch {
+			case pc.HasSegmentWildcards:
+				tree = a.wildcardRules
+				if pc.Glob {
+					prefix += "*"
+				}
+			case pc.Glob:
 				tree = a.globRules
 			}
 
 			// Check for an existing policy
-			raw, ok := tree.Get(pc.Prefix)
+			raw, ok := tree.Get(prefix)
 			if !ok {
 				clonedPerms, err := pc.Permissions.Clone()
 				if err != nil {
 					return nil, errwrap.Wrapf("error cloning ACL permissions: {{err}}", err)
 				}
-				tree.Insert(pc.Prefix, clonedPerms)
+				tree.Insert(prefix, clonedPerms)
 				continue
 			}
 
@@ -74,6 +102,7 @@ func NewACL(policies []*Policy) (*ACL, error) {
 				existingPerms.CapabilitiesBitmap = DenyCapabilityInt
 				existingPerms.AllowedParameters = nil
 				existingPerms.DeniedParameters = nil
+				existingPerms.RequiredParameters = nil
 				goto INSERT
 
 			default:
@@ -140,8 +169,15 @@ func NewACL(policies []*Policy) (*ACL, error) {
 				}
 			}
 
+			// A write must satisfy the required parameters of every policy
+			for _, key := range pc.Permissions.RequiredParameters {
+				if !strutil.StrListContains(existingPerms.RequiredParameters, key) {
+					existingPerms.RequiredParameters = append(existingPerms.RequiredParameters, key)
+				}
+			}
+
 		INSERT:
-			tree.Insert(pc.Prefix, existingPerms)
+			tree.Insert(prefix, existingPerms)
 
 		}
 	}
@@ -154,26 +190,13 @@ func (a *ACL) Capabilities(path string) (pathCapabilities []string) {
 		return []string{RootCapability}
 	}
 
-	// Find an exact matching rule, look for glob if no match
-	var capabilities uint32
-	raw, ok := a.exactRules.Get(path)
-
-	if ok {
-		perm := raw.(*Permissions)
-		capabilities = perm.CapabilitiesBitmap
-		goto CHECK
-	}
-
-	// Find a glob rule, default deny if no match
-	_, raw, ok = a.globRules.LongestPrefix(path)
-	if !ok {
+	// Find the matching rule, default deny if no match
+	perm := a.matchingPermissions(path)
+	if perm == nil {
 		return []string{DenyCapability}
-	} else {
-		perm := raw.(*Permissions)
-		capabilities = perm.CapabilitiesBitmap
 	}
+	capabilities := perm.CapabilitiesBitmap
 
-CHECK:
 	if capabilities&SudoCapabilityInt > 0 {
 		pathCapabilities = append(pathCapabilities, SudoCapability)
 	}
@@ -217,27 +240,13 @@ func (a *ACL) AllowOperation(req *logical.Request) (bool, bool) {
 		return true, false
 	}
 
-	var permissions *Permissions
-
-	// Find an exact matching rule, look for glob if no match
-	var capabilities uint32
-	raw, ok := a.exactRules.Get(path)
-	if ok {
-		permissions = raw.(*Permissions)
-		capabilities = permissions.CapabilitiesBitmap
-		goto CHECK
-	}
-
-	// Find a glob rule, default deny if no match
-	_, raw, ok = a.globRules.LongestPrefix(path)
-	if !ok {
+	// Find the matching rule, default deny if no match
+	permissions := a.matchingPermissions(path)
+	if permissions == nil {
 		return false, false
-	} else {
-		permissions = raw.(*Permissions)
-		capabilities = permissions.CapabilitiesBitmap
 	}
+	capabilities := permissions.CapabilitiesBitmap
 
-CHECK:
 	// Check if the minimum permissions are met
 	// If "deny" has been explicitly set, only deny will be in the map, so we
 	// only need to check for the existence of other values
@@ -289,6 +298,12 @@ CHECK:
 	// Only check parameter permissions for operations that can modify
 	// parameters.
 	if op == logical.UpdateOperation || op == logical.CreateOperation {
+		for _, parameter := range permissions.RequiredParameters {
+			if !dataHasParameter(req.Data, parameter) {
+				return false, sudo
+			}
+		}
+
 		// If there are no data fields, allow
 		if len(req.Data) == 0 {
 			return true, sudo
@@ -342,6 +357,146 @@ CHECK:
 	return true, sudo
 }
 
+// matchingPermissions returns the permissions of the most specific rule
+// matching the path, or nil if no rule matches. Rules are chosen in this
+// order:
+//
+//  1. A rule without wildcards or globs matching the path exactly.
+//  2. Among the glob rule with the longest matching prefix and the rules
+//     with '+' segments matching the path, the rule whose first '+' or '*'
+//     comes last in the rule. Ties are broken by preferring, in order, a
+//     rule that does not end in '*', the rule with fewer '+' segments, the
+//     longer rule, and the lexicographically smaller rule.
+func (a *ACL) matchingPermissions(path string) *Permissions {
+	if raw, ok := a.exactRules.Get(path); ok {
+		return raw.(*Permissions)
+	}
+
+	var best string
+	var bestPerms *Permissions
+	consider := func(pattern string, perms *Permissions) {
+		if bestPerms == nil || morePreciseRule(pattern, best) {
+			best = pattern
+			bestPerms = perms
+		}
+	}
+
+	if prefix, raw, ok := a.globRules.LongestPrefix(path); ok {
+		consider(prefix+"*", raw.(*Permissions))
+	}
+
+	a.wildcardRules.Walk(func(pattern string, raw interface{}) bool {
+		if matchesWildcardRule(pattern, path) {
+			consider(pattern, raw.(*Permissions))
+		}
+		return false
+	})
+
+	return bestPerms
+}
+
+// hasSegmentWildcards returns whether the policy path has '+' segments
+func hasSegmentWildcards(path string) bool {
+	for _, segment := range strings.Split(path, "/") {
+		if segment == "+" {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesWildcardRule returns whether the rule, in which each '+' segment
+// matches any single segment and a trailing '*' matches any suffix, matches
+// the path
+func matchesWildcardRule(pattern, path string) bool {
+	glob := strings.HasSuffix(pattern, "*")
+	patternSegments := strings.Split(strings.TrimSuffix(pattern, "*"), "/")
+	pathSegments := strings.Split(path, "/")
+
+	if len(pathSegments) < len(patternSegments) ||
+		!glob && len(pathSegments) != len(patternSegments) {
+		return false
+	}
+
+	last := len(patternSegments) - 1
+	for i, segment := range patternSegments {
+		switch {
+		case segment == "+":
+		case i == last && glob:
+			// The glob matches the rest of the path, which may span several
+			// segments
+			if !strings.HasPrefix(strings.Join(pathSegments[i:], "/"), segment) {
+				return false
+			}
+		case segment != pathSegments[i]:
+			return false
+		}
+	}
+	return true
+}
+
+// morePreciseRule returns whether rule a takes precedence over rule b for a
+// path both match
+func morePreciseRule(a, b string) bool {
+	aWildcard, bWildcard := firstWildcard(a), firstWildcard(b)
+	if aWildcard != bWildcard {
+		return aWildcard > bWildcard
+	}
+
+	aGlob, bGlob := strings.HasSuffix(a, "*"), strings.HasSuffix(b, "*")
+	if aGlob != bGlob {
+		return bGlob
+	}
+
+	aSegments, bSegments := countSegmentWildcards(a), countSegmentWildcards(b)
+	if aSegments != bSegments {
+		return aSegments < bSegments
+	}
+
+	if len(a) != len(b) {
+		return len(a) > len(b)
+	}
+
+	return a < b
+}
+
+// firstWildcard returns the position of the first '+' segment or trailing
+// '*' of the rule
+func firstWildcard(pattern string) int {
+	pos := 0
+	for _, segment := range strings.Split(pattern, "/") {
+		if segment == "+" {
+			return pos
+		}
+		pos += len(segment) + 1
+	}
+	if strings.HasSuffix(pattern, "*") {
+		return len(pattern) - 1
+	}
+	return len(pattern)
+}
+
+func countSegmentWildcards(pattern string) int {
+	count := 0
+	for _, segment := range strings.Split(pattern, "/") {
+		if segment == "+" {
+			count++
+		}
+	}
+	return count
+}
+
+// dataHasParameter returns whether the request data has the parameter,
+// which is compared case-insensitively
+func dataHasParameter(data map[string]interface{}, parameter string) bool {
+	for key := range data {
+		if strings.ToLower(key) == parameter {
+			return true
+		}
+	}
+	return false
+}
+
 func valueInParameterList(v interface{}, list []interface{}) bool {
 	// Empty list is equivalent to the item always existing in the list
 	if len(list) == 0 {
@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/hashicorp/vault/helper/strutil"
 	"github.com/hashicorp/vault/logical"
 )
 
@@ -341,6 +342,279 @@ func TestACL_AllowOperation(t *testing.T) {
 	}
 }
 
+func TestACL_TemplatedForgedMetadata(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+
+	policy, _ := Parse(templatedCreatorPolicy)
+	if err := c.policyStore.SetPolicy(policy); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	parent := &TokenEntry{
+		ID:       "bobtoken",
+		Path:     "auth/userpass/login/bob",
+		Policies: []string{"templated-creator"},
+		Meta:     map[string]string{"user": "bob"},
+	}
+	if err := c.tokenStore.create(parent); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Bob creates a child token claiming to be alice
+	resp, err := c.HandleRequest(&logical.Request{
+		Operation:   logical.UpdateOperation,
+		Path:        "auth/token/create",
+		ClientToken: parent.ID,
+		Data: map[string]interface{}{
+			"policies": []string{"templated-creator"},
+			"meta":     map[string]string{"user": "alice"},
+		},
+	})
+	if err != nil || resp == nil || resp.Auth == nil {
+		t.Fatalf("bad: resp: %#v, err: %v", resp, err)
+	}
+	child := resp.Auth.ClientToken
+
+	for token, paths := range map[string]map[string]bool{
+		parent.ID: {
+			"secret/users/bob/foo":   true,
+			"secret/users/alice/foo": false,
+		},
+		child: {
+			"secret/users/bob/foo":   false,
+			"secret/users/alice/foo": false,
+		},
+	} {
+		for path, allowed := range paths {
+			capabilities, err := c.Capabilities(token, path)
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			if strutil.StrListContains(capabilities, "read") != allowed {
+				t.Fatalf("bad: %s: expected allowed %v, got: %v", path, allowed, capabilities)
+			}
+		}
+	}
+}
+
+func TestACL_Templated(t *testing.T) {
+	policy, err := Parse(templatedPolicy)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	type tcase struct {
+		path         string
+		op           logical.Operation
+		templateData *ACLTemplateData
+		allowed      bool
+	}
+
+	bob := &ACLTemplateData{
+		TokenMetadata: map[string]string{"user": "bob", "team": "ops"},
+		MountAccessor: "auth_userpass_1234",
+	}
+
+	tcases := []tcase{
+		{"secret/users/bob/foo", logical.ReadOperation, bob, true},
+		{"secret/users/bob", logical.ReadOperation, bob, false},
+		{"secret/users/alice/foo", logical.ReadOperation, bob, false},
+		{"secret/teams/ops", logical.UpdateOperation, bob, true},
+		{"secret/teams/ops/foo", logical.UpdateOperation, bob, false},
+		{"secret/mounts/auth_userpass_1234/bob", logical.ReadOperation, bob, true},
+		{"secret/static", logical.ReadOperation, bob, true},
+
+		// Without template data, only static paths are granted
+		{"secret/users/bob/foo", logical.ReadOperation, nil, false},
+		{"secret/static", logical.ReadOperation, nil, true},
+
+		// Missing metadata drops the path
+		{"secret/users/bob/foo", logical.ReadOperation, &ACLTemplateData{}, false},
+		{"secret/users//foo", logical.ReadOperation, &ACLTemplateData{}, false},
+
+		// Values that would widen the path drop it
+		{"secret/users/a/b/foo", logical.ReadOperation, &ACLTemplateData{
+			TokenMetadata: map[string]string{"user": "a/b"},
+		}, false},
+		{"secret/users/a*/foo", logical.ReadOperation, &ACLTemplateData{
+			TokenMetadata: map[string]string{"user": "a*"},
+		}, false},
+		{"secret/users/../foo", logical.ReadOperation, &ACLTemplateData{
+			TokenMetadata: map[string]string{"user": ".."},
+		}, false},
+	}
+
+	for _, tc := range tcases {
+		acl, err := NewTemplatedACL([]*Policy{policy}, tc.templateData)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		request := &logical.Request{Path: tc.path, Operation: tc.op}
+		allowed, _ := acl.AllowOperation(request)
+		if allowed != tc.allowed {
+			t.Fatalf("bad: case %#v: %v", tc, allowed)
+		}
+	}
+
+	// NewACL does not grant templated paths
+	acl, err := NewACL([]*Policy{policy})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if allowed, _ := acl.AllowOperation(&logical.Request{
+		Path:      "secret/users/bob/foo",
+		Operation: logical.ReadOperation,
+	}); allowed {
+		t.Fatal("templated path was granted")
+	}
+}
+
+func TestACL_SegmentWildcards(t *testing.T) {
+	policy, err := Parse(segmentWildcardPolicy)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	acl, err := NewACL([]*Policy{policy})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	type tcase struct {
+		path     string
+		expected []string
+	}
+	tcases := []tcase{
+		// A '+' matches exactly one segment
+		{"apps/foo/config", []string{"read"}},
+		{"apps/foo/bar/config", []string{"deny"}},
+		{"apps/config", []string{"deny"}},
+		{"apps/foo/configs", []string{"deny"}},
+
+		// '+' combined with a trailing glob
+		{"apps/foo/logs/today", []string{"list"}},
+		{"apps/foo/logs", []string{"deny"}},
+
+		// Exact rules always win
+		{"apps/admin/config", []string{"deny"}},
+
+		// The rule whose first wildcard comes last wins: "apps/prod/+"
+		// over "apps/+/config"
+		{"apps/prod/config", []string{"update"}},
+
+		// A rule without a trailing glob wins over one with the first
+		// wildcard at the same position: "users/+/profile" over "users/*"
+		{"users/bob/profile", []string{"read"}},
+		{"users/bob/other", []string{"list"}},
+
+		// Fewer '+' segments win: "teams/+/members/alice" over
+		// "teams/+/+/alice"
+		{"teams/ops/members/alice", []string{"update"}},
+		{"teams/ops/admins/alice", []string{"read"}},
+
+		// A glob whose first wildcard comes after the '+' wins:
+		// "data/shared/config*" over "data/+/config"
+		{"data/shared/config", []string{"list"}},
+		{"data/other/config", []string{"read"}},
+	}
+
+	for _, tc := range tcases {
+		actual := acl.Capabilities(tc.path)
+		if !reflect.DeepEqual(actual, tc.expected) {
+			t.Fatalf("bad: path %s: got %#v, expected %#v", tc.path, actual, tc.expected)
+		}
+	}
+
+	// Rules with '+' segments are merged across policies
+	other, err := Parse(`
+name = "other"
+path "apps/+/config" {
+	capabilities = ["list"]
+}
+`)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	acl, err = NewACL([]*Policy{policy, other})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	actual := acl.Capabilities("apps/foo/config")
+	expected := []string{"read", "list"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: got %#v, expected %#v", actual, expected)
+	}
+}
+
+func TestACL_RequiredParameters(t *testing.T) {
+	policy, err := Parse(requiredParametersPolicy)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	other, err := Parse(`
+name = "other"
+path "secret/foo" {
+	capabilities = ["update"]
+	required_parameters = ["team"]
+}
+`)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	type tcase struct {
+		policies   []*Policy
+		path       string
+		parameters []string
+		allowed    bool
+	}
+	tcases := []tcase{
+		{[]*Policy{policy}, "secret/foo", []string{"owner", "ttl"}, true},
+		{[]*Policy{policy}, "secret/foo", []string{"OWNER", "ttl", "extra"}, true},
+		{[]*Policy{policy}, "secret/foo", []string{"owner"}, false},
+		{[]*Policy{policy}, "secret/foo", nil, false},
+		{[]*Policy{policy}, "secret/bar", nil, true},
+
+		// Required parameters of merged policies all apply
+		{[]*Policy{policy, other}, "secret/foo", []string{"owner", "ttl"}, false},
+		{[]*Policy{policy, other}, "secret/foo", []string{"owner", "ttl", "team"}, true},
+
+		// Allowed parameters still apply
+		{[]*Policy{policy}, "secret/baz", []string{"owner"}, true},
+		{[]*Policy{policy}, "secret/baz", []string{"owner", "extra"}, false},
+	}
+
+	for _, tc := range tcases {
+		acl, err := NewACL(tc.policies)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		request := &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      tc.path,
+			Data:      make(map[string]interface{}),
+		}
+		for _, parameter := range tc.parameters {
+			request.Data[parameter] = "value"
+		}
+		allowed, _ := acl.AllowOperation(request)
+		if allowed != tc.allowed {
+			t.Fatalf("bad: case %#v: %v", tc, allowed)
+		}
+	}
+
+	// Reads are not affected
+	acl, err := NewACL([]*Policy{policy})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if allowed, _ := acl.AllowOperation(&logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "secret/foo",
+	}); !allowed {
+		t.Fatal("read was denied")
+	}
+}
+
 func TestACL_ValuePermissions(t *testing.T) {
 	policy, err := Parse(valuePermissionsPolicy)
 	if err != nil {
@@ -763,3 +1037,84 @@ path "test/star" {
 	}
 }
 `
+
+var templatedPolicy = `
+name = "templated"
+path "secret/users/{{token.metadata.user}}/*" {
+	capabilities = ["read"]
+}
+path "secret/teams/{{ token.metadata.team }}" {
+	capabilities = ["update"]
+}
+path "secret/mounts/{{auth.mount_accessor}}/{{token.metadata.user}}" {
+	capabilities = ["read"]
+}
+path "secret/accessors/{{auth.mount_accessor}}" {
+	capabilities = ["read"]
+}
+path "secret/static" {
+	capabilities = ["read"]
+}
+`
+
+var templatedCreatorPolicy = `
+name = "templated-creator"
+path "secret/users/{{token.metadata.user}}/*" {
+	capabilities = ["read"]
+}
+path "auth/token/create" {
+	capabilities = ["update"]
+}
+`
+
+var segmentWildcardPolicy = `
+name = "wildcards"
+path "apps/+/config" {
+	capabilities = ["read"]
+}
+path "apps/+/logs/*" {
+	capabilities = ["list"]
+}
+path "apps/admin/config" {
+	capabilities = ["deny"]
+}
+path "apps/prod/+" {
+	capabilities = ["update"]
+}
+path "users/*" {
+	capabilities = ["list"]
+}
+path "users/+/profile" {
+	capabilities = ["read"]
+}
+path "teams/+/+/alice" {
+	capabilities = ["read"]
+}
+path "teams/+/members/alice" {
+	capabilities = ["update"]
+}
+path "data/+/config" {
+	capabilities = ["read"]
+}
+path "data/shared/config*" {
+	capabilities = ["list"]
+}
+`
+
+var requiredParametersPolicy = `
+name = "required"
+path "secret/foo" {
+	capabilities = ["update", "read"]
+	required_parameters = ["Owner", "ttl"]
+}
+path "secret/bar" {
+	capabilities = ["update"]
+}
+path "secret/baz" {
+	capabilities = ["update"]
+	required_parameters = ["owner"]
+	allowed_parameters = {
+		"owner" = []
+	}
+}
+`
@@ -4,6 +4,7 @@ import (
 	"crypto/sha256"
 	"errors"
 	"fmt"
+	"io"
 	"strings"
 	"sync"
 	"time"
@@ -89,6 +90,11 @@ func (c *Core) enableAudit(entry *MountEntry) error {
 	viewPath := auditBarrierPrefix + entry.UUID + "/"
 	view := NewBarrierView(c.barrier, viewPath)
 
+	filter, err := auditFilter(entry)
+	if err != nil {
+		return err
+	}
+
 	// Lookup the new backend
 	backend, err := c.newAuditBackend(entry, view, entry.Options)
 	if err != nil {
@@ -101,13 +107,14 @@ func (c *Core) enableAudit(entry *MountEntry) error {
 	newTable := c.audit.shallowClone()
 	newTable.Entries = append(newTable.Entries, entry)
 	if err := c.persistAudit(newTable, entry.Local); err != nil {
+		closeAuditBackend(backend)
 		return errors.New("failed to update audit table")
 	}
 
 	c.audit = newTable
 
 	// Register the backend
-	c.auditBroker.Register(entry.Path, backend, view)
+	c.auditBroker.Register(entry.Path, backend, view, filter)
 	if c.logger.IsInfo() {
 		c.logger.Info("core: enabled audit backend", "path", entry.Path, "type", entry.Type)
 	}
@@ -306,6 +313,7 @@ func (c *Core) persistAudit(table *MountTable, localOnly bool) error {
 // initialize the audit backends
 func (c *Core) setupAudits() error {
 	broker := NewAuditBroker(c.logger)
+	broker.router = c.router
 
 	c.auditLock.Lock()
 	defer c.auditLock.Unlock()
@@ -317,6 +325,14 @@ func (c *Core) setupAudits() error {
 		viewPath := auditBarrierPrefix + entry.UUID + "/"
 		view := NewBarrierView(c.barrier, viewPath)
 
+		// Fail rather than skip the backend, which would leave the requests
+		// it should receive unaudited
+		filter, err := auditFilter(entry)
+		if err != nil {
+			c.logger.Error("core: failed to parse audit filter", "path", entry.Path, "error", err)
+			return fmt.Errorf("failed to set up audit backend %q: %v", entry.Path, err)
+		}
+
 		// Initialize the backend
 		backend, err := c.newAuditBackend(entry, view, entry.Options)
 		if err != nil {
@@ -329,7 +345,7 @@ func (c *Core) setupAudits() error {
 		}
 
 		// Mount the backend
-		broker.Register(entry.Path, backend, view)
+		broker.Register(entry.Path, backend, view, filter)
 
 		successCount += 1
 	}
@@ -353,6 +369,9 @@ func (c *Core) teardownAudits() error {
 			c.removeAuditReloadFunc(entry)
 		}
 	}
+	if c.auditBroker != nil {
+		c.auditBroker.closeBackends()
+	}
 
 	c.audit = nil
 	c.auditBroker = nil
@@ -424,6 +443,21 @@ func (c *Core) newAuditBackend(entry *MountEntry, view logical.Storage, conf map
 	return be, err
 }
 
+// auditFilter returns the filter of the audit backend, or nil if it logs
+// every request and response
+func auditFilter(entry *MountEntry) (*audit.Filter, error) {
+	raw := strings.TrimSpace(entry.Options["filter"])
+	if raw == "" {
+		return nil, nil
+	}
+
+	filter, err := audit.ParseFilter(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter: %v", err)
+	}
+	return filter, nil
+}
+
 // defaultAuditTable creates a default audit table
 func defaultAuditTable() *MountTable {
 	table := &MountTable{
@@ -435,6 +469,9 @@ func defaultAuditTable() *MountTable {
 type backendEntry struct {
 	backend audit.Backend
 	view    *BarrierView
+
+	// filter selects the requests and responses the backend logs, if set
+	filter *audit.Filter
 }
 
 // AuditBroker is used to provide a single ingest interface to auditable
@@ -443,6 +480,10 @@ type AuditBroker struct {
 	sync.RWMutex
 	backends map[string]backendEntry
 	logger   log.Logger
+
+	// router resolves the mount of requests that haven't been routed yet,
+	// for filters
+	router *Router
 }
 
 // NewAuditBroker creates a new audit broker
@@ -454,13 +495,15 @@ func NewAuditBroker(log log.Logger) *AuditBroker {
 	return b
 }
 
-// Register is used to add new audit backend to the broker
-func (a *AuditBroker) Register(name string, b audit.Backend, v *BarrierView) {
+// Register is used to add new audit backend to the broker. If filter is set,
+// the backend only logs the requests and responses matching it.
+func (a *AuditBroker) Register(name string, b audit.Backend, v *BarrierView, filter *audit.Filter) {
 	a.Lock()
 	defer a.Unlock()
 	a.backends[name] = backendEntry{
 		backend: b,
 		view:    v,
+		filter:  filter,
 	}
 }
 
@@ -468,9 +511,30 @@ func (a *AuditBroker) Register(name string, b audit.Backend, v *BarrierView) {
 func (a *AuditBroker) Deregister(name string) {
 	a.Lock()
 	defer a.Unlock()
+	if be, ok := a.backends[name]; ok {
+		closeAuditBackend(be.backend)
+	}
 	delete(a.backends, name)
 }
 
+// closeBackends releases the resources of all the backends, before the
+// broker is discarded
+func (a *AuditBroker) closeBackends() {
+	a.Lock()
+	defer a.Unlock()
+	for _, be := range a.backends {
+		closeAuditBackend(be.backend)
+	}
+}
+
+// closeAuditBackend closes backends that hold resources beyond the audit
+// table entry, such as the background sender of a spooling backend
+func closeAuditBackend(b audit.Backend) {
+	if closer, ok := b.(io.Closer); ok {
+		closer.Close()
+	}
+}
+
 // IsRegistered is used to check if a given audit backend is registered
 func (a *AuditBroker) IsRegistered(name string) bool {
 	a.RLock()
@@ -525,9 +589,27 @@ func (a *AuditBroker) LogRequest(auth *logical.Auth, req *logical.Request, heade
 		req.Headers = headers
 	}()
 
-	// Ensure at least one backend logs
+	var in *audit.FilterInput
+
+	// Ensure at least one backend logs, if any backend's filter selects the
+	// request
+	anyReceived := false
 	anyLogged := false
 	for name, be := range a.backends {
+		if be.filter != nil {
+			if in == nil {
+				in = a.filterInput(auth, req, outerErr != nil)
+			}
+			matched, fErr := be.filter.Evaluate(in)
+			if fErr != nil {
+				a.logger.Warn("audit: failed to evaluate backend filter", "backend", name, "path", req.Path, "error", fErr)
+			}
+			if !matched {
+				continue
+			}
+		}
+		anyReceived = true
+
 		req.Headers = nil
 		transHeaders, thErr := headersConfig.ApplyConfig(headers, be.backend.GetHash)
 		if thErr != nil {
@@ -545,7 +627,7 @@ func (a *AuditBroker) LogRequest(auth *logical.Auth, req *logical.Request, heade
 			anyLogged = true
 		}
 	}
-	if !anyLogged && len(a.backends) > 0 {
+	if !anyLogged && anyReceived {
 		retErr = multierror.Append(retErr, fmt.Errorf("no audit backend succeeded in logging the request"))
 	}
 
@@ -580,9 +662,27 @@ func (a *AuditBroker) LogResponse(auth *logical.Auth, req *logical.Request,
 		req.Headers = headers
 	}()
 
-	// Ensure at least one backend logs
+	var in *audit.FilterInput
+
+	// Ensure at least one backend logs, if any backend's filter selects the
+	// response
+	anyReceived := false
 	anyLogged := false
 	for name, be := range a.backends {
+		if be.filter != nil {
+			if in == nil {
+				in = a.filterInput(auth, req, err != nil || resp.IsError())
+			}
+			matched, fErr := be.filter.Evaluate(in)
+			if fErr != nil {
+				a.logger.Warn("audit: failed to evaluate backend filter", "backend", name, "path", req.Path, "error", fErr)
+			}
+			if !matched {
+				continue
+			}
+		}
+		anyReceived = true
+
 		req.Headers = nil
 		transHeaders, thErr := headersConfig.ApplyConfig(headers, be.backend.GetHash)
 		if thErr != nil {
@@ -600,13 +700,41 @@ func (a *AuditBroker) LogResponse(auth *logical.Auth, req *logical.Request,
 			anyLogged = true
 		}
 	}
-	if !anyLogged && len(a.backends) > 0 {
+	if !anyLogged && anyReceived {
 		retErr = multierror.Append(retErr, fmt.Errorf("no audit backend succeeded in logging the response"))
 	}
 
 	return retErr.ErrorOrNil()
 }
 
+// filterInput returns the properties of the request filters select on
+func (a *AuditBroker) filterInput(auth *logical.Auth, req *logical.Request, isError bool) *audit.FilterInput {
+	in := &audit.FilterInput{
+		MountPoint: req.MountPoint,
+		MountType:  req.MountType,
+		Operation:  string(req.Operation),
+		Path:       req.Path,
+		Error:      isError,
+
+		// Every request is in the root namespace, whose path is empty
+		Namespace: "",
+	}
+
+	// Requests are logged before they are routed
+	if in.MountPoint == "" && a.router != nil {
+		in.MountPoint = a.router.MatchingMount(req.Path)
+		if entry := a.router.MatchingMountEntry(req.Path); entry != nil {
+			in.MountType = entry.Type
+		}
+	}
+
+	if auth != nil {
+		in.Policies = auth.Policies
+	}
+
+	return in
+}
+
 func (a *AuditBroker) Invalidate(key string) {
 	// For now we ignore the key as this would only apply to salts. We just
 	// sort of brute force it on each one.
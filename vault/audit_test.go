@@ -150,6 +150,76 @@ func TestCore_EnableAudit(t *testing.T) {
 	}
 }
 
+func TestCore_EnableAudit_Filter(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+	c.auditBackends["noop"] = func(config *audit.BackendConfig) (audit.Backend, error) {
+		return &NoopAudit{
+			Config: config,
+		}, nil
+	}
+
+	me := &MountEntry{
+		Table: auditTableType,
+		Path:  "foo",
+		Type:  "noop",
+		Options: map[string]string{
+			"filter": `mount_type = "transit"`,
+		},
+	}
+	err := c.enableAudit(me)
+	if err == nil || !strings.Contains(err.Error(), "invalid filter") {
+		t.Fatalf("expected an invalid filter error, got: %v", err)
+	}
+	if c.auditBroker.IsRegistered("foo/") {
+		t.Fatalf("audit backend should not be registered")
+	}
+
+	me.Options["filter"] = `mount_type == "transit"`
+	if err := c.enableAudit(me); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !c.auditBroker.IsRegistered("foo/") {
+		t.Fatalf("missing audit backend")
+	}
+}
+
+func TestCore_SetupAudits_InvalidFilter(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+	c.auditBackends["noop"] = func(config *audit.BackendConfig) (audit.Backend, error) {
+		return &NoopAudit{
+			Config: config,
+		}, nil
+	}
+
+	c.audit = &MountTable{
+		Type: auditTableType,
+		Entries: []*MountEntry{
+			&MountEntry{
+				Table: auditTableType,
+				Path:  "noop/",
+				Type:  "noop",
+				UUID:  "abcd",
+			},
+			&MountEntry{
+				Table: auditTableType,
+				Path:  "filtered/",
+				Type:  "noop",
+				UUID:  "bcde",
+				Options: map[string]string{
+					"filter": `mount_type = "transit"`,
+				},
+			},
+		},
+	}
+
+	// A backend whose filter can't be parsed fails the setup instead of
+	// being skipped
+	err := c.setupAudits()
+	if err == nil || !strings.Contains(err.Error(), "invalid filter") {
+		t.Fatalf("expected an invalid filter error, got: %v", err)
+	}
+}
+
 func TestCore_EnableAudit_MixedFailures(t *testing.T) {
 	c, _, _ := TestCoreUnsealed(t)
 	c.auditBackends["noop"] = func(config *audit.BackendConfig) (audit.Backend, error) {
@@ -348,6 +418,53 @@ func TestCore_DisableAudit(t *testing.T) {
 	}
 }
 
+// closingAudit is a NoopAudit that records when it is closed
+type closingAudit struct {
+	NoopAudit
+	closed int
+}
+
+func (n *closingAudit) Close() error {
+	n.closed++
+	return nil
+}
+
+func TestCore_DisableAudit_Close(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+	var backends []*closingAudit
+	c.auditBackends["noop"] = func(config *audit.BackendConfig) (audit.Backend, error) {
+		b := &closingAudit{}
+		b.Config = config
+		backends = append(backends, b)
+		return b, nil
+	}
+
+	for _, path := range []string{"foo", "bar"} {
+		err := c.enableAudit(&MountEntry{
+			Table: auditTableType,
+			Path:  path,
+			Type:  "noop",
+		})
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	if _, err := c.disableAudit("foo"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if backends[0].closed != 1 || backends[1].closed != 0 {
+		t.Fatalf("expected only the disabled backend to be closed")
+	}
+
+	if err := c.Seal(root); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if backends[0].closed != 1 || backends[1].closed != 1 {
+		t.Fatalf("expected the remaining backend to be closed on seal")
+	}
+}
+
 func TestCore_DefaultAuditTable(t *testing.T) {
 	c, keys, _ := TestCoreUnsealed(t)
 	verifyDefaultAuditTable(t, c.audit)
@@ -401,8 +518,8 @@ func TestAuditBroker_LogRequest(t *testing.T) {
 	b := NewAuditBroker(l)
 	a1 := &NoopAudit{}
 	a2 := &NoopAudit{}
-	b.Register("foo", a1, nil)
-	b.Register("bar", a2, nil)
+	b.Register("foo", a1, nil, nil)
+	b.Register("bar", a2, nil, nil)
 
 	auth := &logical.Auth{
 		ClientToken: "foo",
@@ -478,8 +595,8 @@ func TestAuditBroker_LogResponse(t *testing.T) {
 	b := NewAuditBroker(l)
 	a1 := &NoopAudit{}
 	a2 := &NoopAudit{}
-	b.Register("foo", a1, nil)
-	b.Register("bar", a2, nil)
+	b.Register("foo", a1, nil, nil)
+	b.Register("bar", a2, nil, nil)
 
 	auth := &logical.Auth{
 		NumUses:     10,
@@ -565,6 +682,89 @@ func TestAuditBroker_LogResponse(t *testing.T) {
 	}
 }
 
+func TestAuditBroker_Filter(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+	logger := logformat.NewVaultLogger(log.LevelTrace)
+	b := NewAuditBroker(logger)
+	b.router = c.router
+
+	transitOnly, err := audit.ParseFilter(`mount_type == "generic" && operation == "update"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	noHealth, err := audit.ParseFilter(`path != "sys/health" && !("noisy" in policies)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	errorsOnly, err := audit.ParseFilter(`error`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a1 := &NoopAudit{}
+	a2 := &NoopAudit{}
+	a3 := &NoopAudit{}
+	b.Register("foo", a1, nil, transitOnly)
+	b.Register("bar", a2, nil, noHealth)
+	b.Register("baz", a3, nil, errorsOnly)
+
+	auth := &logical.Auth{
+		ClientToken: "foo",
+		Policies:    []string{"dev"},
+	}
+	headersConf := &AuditedHeadersConfig{}
+
+	// The mount of requests is resolved before they are routed
+	req := &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "secret/foo",
+	}
+	if err := b.LogRequest(auth, req, headersConf, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(a1.Req) != 1 || len(a2.Req) != 1 || len(a3.Req) != 0 {
+		t.Fatalf("bad: %d %d %d", len(a1.Req), len(a2.Req), len(a3.Req))
+	}
+	if req.MountPoint != "" || req.MountType != "" {
+		t.Fatalf("request should not be modified: %#v", req)
+	}
+
+	req = &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "sys/health",
+	}
+	if err := b.LogRequest(auth, req, headersConf, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(a1.Req) != 1 || len(a2.Req) != 1 || len(a3.Req) != 0 {
+		t.Fatalf("bad: %d %d %d", len(a1.Req), len(a2.Req), len(a3.Req))
+	}
+
+	// Responses are matched on the error status
+	resp := logical.ErrorResponse("permission denied")
+	if err := b.LogResponse(auth, req, resp, headersConf, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(a1.Resp) != 0 || len(a2.Resp) != 0 || len(a3.Resp) != 1 {
+		t.Fatalf("bad: %d %d %d", len(a1.Resp), len(a2.Resp), len(a3.Resp))
+	}
+
+	// Failures only matter if a backend received the request
+	a1.ReqErr = fmt.Errorf("failed")
+	a2.ReqErr = fmt.Errorf("failed")
+	req = &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "secret/foo",
+	}
+	if err := b.LogRequest(&logical.Auth{Policies: []string{"noisy"}}, req, headersConf, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	err = b.LogRequest(auth, req, headersConf, nil)
+	if err == nil || !strings.Contains(err.Error(), "no audit backend succeeded in logging the request") {
+		t.Fatalf("err: %v", err)
+	}
+}
+
 func TestAuditBroker_AuditHeaders(t *testing.T) {
 	logger := logformat.NewVaultLogger(log.LevelTrace)
 	b := NewAuditBroker(logger)
@@ -572,8 +772,8 @@ func TestAuditBroker_AuditHeaders(t *testing.T) {
 	view := NewBarrierView(barrier, "headers/")
 	a1 := &NoopAudit{}
 	a2 := &NoopAudit{}
-	b.Register("foo", a1, nil)
-	b.Register("bar", a2, nil)
+	b.Register("foo", a1, nil, nil)
+	b.Register("bar", a2, nil, nil)
 
 	auth := &logical.Auth{
 		ClientToken: "foo",
@@ -3,9 +3,13 @@ package vault
 import (
 	"bytes"
 	"encoding/json"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/armon/go-metrics"
 	"github.com/hashicorp/vault/helper/logformat"
+	"github.com/hashicorp/vault/helper/metricsutil"
 	"github.com/hashicorp/vault/physical"
 	"github.com/hashicorp/vault/physical/inmem"
 	log "github.com/mgutz/logxi/v1"
@@ -45,6 +49,44 @@ func TestAESGCMBarrier_Basic(t *testing.T) {
 	testBarrier(t, b)
 }
 
+func TestAESGCMBarrier_metrics(t *testing.T) {
+	sink := metricsutil.NewPrometheusSink(time.Hour, "")
+	conf := metrics.DefaultConfig("vault")
+	conf.EnableHostname = false
+	conf.EnableRuntimeMetrics = false
+	metrics.NewGlobal(conf, sink)
+	defer metrics.NewGlobal(conf, &metrics.BlackholeSink{})
+
+	_, b, _ := mockBarrier(t)
+	if err := b.Put(&Entry{Key: "test", Value: []byte("test")}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := b.Get("test"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := b.List(""); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := b.Delete("test"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := sink.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// Operations are timed by the barrier and by the storage backend
+	for _, layer := range []string{"barrier", "inmem"} {
+		for _, op := range []string{"get", "put", "delete", "list"} {
+			name := "# TYPE vault_" + layer + "_" + op + " histogram\n"
+			if !strings.Contains(buf.String(), name) {
+				t.Fatalf("missing %q in:\n%s", name, buf.String())
+			}
+		}
+	}
+}
+
 func TestAESGCMBarrier_Rotate(t *testing.T) {
 	inm, err := inmem.NewInmem(nil, logger)
 	if err != nil {
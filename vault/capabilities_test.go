@@ -43,3 +43,71 @@ func TestCapabilities(t *testing.T) {
 		t.Fatalf("bad: got\n%#v\nexpected\n%#v\n", actual, expected)
 	}
 }
+
+func TestCapabilities_templated(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+
+	policy, _ := Parse(templatedPolicy)
+	if err := c.policyStore.SetPolicy(policy); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	ent := &TokenEntry{
+		ID:       "templatedtoken",
+		Path:     "auth/userpass/login/bob",
+		Policies: []string{"templated"},
+		Meta:     map[string]string{"user": "bob"},
+	}
+	if err := c.tokenStore.create(ent); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	actual, err := c.Capabilities("templatedtoken", "secret/users/bob/foo")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := []string{"read"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: got\n%#v\nexpected\n%#v\n", actual, expected)
+	}
+
+	actual, err = c.Capabilities("templatedtoken", "secret/users/alice/foo")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected = []string{"deny"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: got\n%#v\nexpected\n%#v\n", actual, expected)
+	}
+
+	// The metadata of tokens created by the token store is not used, but
+	// their mount accessor is
+	ent = &TokenEntry{
+		ID:       "tokenstoretoken",
+		Path:     "auth/token/create",
+		Policies: []string{"templated"},
+		Meta:     map[string]string{"user": "bob"},
+	}
+	if err := c.tokenStore.create(ent); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	actual, err = c.Capabilities("tokenstoretoken", "secret/users/bob/foo")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected = []string{"deny"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: got\n%#v\nexpected\n%#v\n", actual, expected)
+	}
+
+	accessor := c.router.MatchingMountEntry("auth/token/").Accessor
+	actual, err = c.Capabilities("tokenstoretoken", "secret/accessors/"+accessor)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected = []string{"read"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: got\n%#v\nexpected\n%#v\n", actual, expected)
+	}
+}
@@ -28,6 +28,7 @@ import (
 	"github.com/hashicorp/vault/helper/errutil"
 	"github.com/hashicorp/vault/helper/jsonutil"
 	"github.com/hashicorp/vault/helper/logformat"
+	"github.com/hashicorp/vault/helper/metricsutil"
 	"github.com/hashicorp/vault/helper/mlock"
 	"github.com/hashicorp/vault/helper/reload"
 	"github.com/hashicorp/vault/logical"
@@ -56,6 +57,10 @@ const (
 	// leaderCheckInterval is how often a standby checks for a new leader
 	leaderCheckInterval = 2500 * time.Millisecond
 
+	// mountMetricsInterval is how often the number of leases and tokens of
+	// each mount is emitted
+	mountMetricsInterval = 10 * time.Second
+
 	// keyRotateCheckInterval is how often a standby checks for a key
 	// rotation taking place.
 	keyRotateCheckInterval = 30 * time.Second
@@ -252,6 +257,10 @@ type Core struct {
 	// policy store is used to manage named ACL policies
 	policyStore *PolicyStore
 
+	// expression policy store is used to manage expression policies, which
+	// are evaluated after the ACL allows a request
+	expressionPolicyStore *ExpressionPolicyStore
+
 	// token store is used to manage authentication tokens
 	tokenStore *TokenStore
 
@@ -349,6 +358,9 @@ type Core struct {
 
 	enableMlock bool
 
+	// metricsSink backs the sys/metrics endpoint, which is disabled if nil
+	metricsSink *metricsutil.PrometheusSink
+
 	// This can be used to trigger operations to stop running when Vault is
 	// going to be shut down, stepped down, or sealed
 	requestContext           context.Context
@@ -401,6 +413,9 @@ type CoreConfig struct {
 
 	ReloadFuncs     *map[string][]reload.ReloadFunc
 	ReloadFuncsLock *sync.RWMutex
+
+	// MetricsSink backs the sys/metrics endpoint, which is disabled if nil
+	MetricsSink *metricsutil.PrometheusSink
 }
 
 // NewCore is used to construct a new core
@@ -457,7 +472,9 @@ func NewCore(conf *CoreConfig) (*Core, error) {
 		clusterListenerShutdownSuccessCh: make(chan struct{}),
 		clusterPeerClusterAddrsCache:     cache.New(3*heartbeatInterval, time.Second),
 		enableMlock:                      !conf.DisableMlock,
+		metricsSink:                      conf.MetricsSink,
 	}
+	metrics.SetGauge([]string{"core", "unsealed"}, 0)
 
 	c.corsConfig = &CORSConfig{core: c}
 	// Load CORS config and provide a value for the core field.
@@ -635,7 +652,7 @@ func (c *Core) fetchACLandTokenEntry(req *logical.Request) (*ACL, *TokenEntry, e
 	}
 
 	// Construct the corresponding ACL object
-	acl, err := c.policyStore.ACL(te.Policies...)
+	acl, err := c.policyStore.ACL(c.aclTemplateData(te), te.Policies...)
 	if err != nil {
 		c.logger.Error("core: failed to construct ACL", "error", err)
 		return nil, nil, ErrInternalError
@@ -712,6 +729,14 @@ func (c *Core) checkToken(req *logical.Request) (*logical.Auth, *TokenEntry, err
 		return auth, te, logical.ErrPermissionDenied
 	}
 
+	// Expression policies refine what the ACL allows, and do not apply to
+	// root tokens
+	if !acl.root {
+		if err := c.checkExpressionPolicies(req, te); err != nil {
+			return auth, te, err
+		}
+	}
+
 	return auth, te, nil
 }
 
@@ -997,6 +1022,7 @@ func (c *Core) unsealInternal(masterKey []byte) (bool, error) {
 
 	// Success!
 	c.sealed = false
+	metrics.SetGauge([]string{"core", "unsealed"}, 1)
 	if c.ha != nil {
 		sd, ok := c.ha.(physical.ServiceDiscovery)
 		if ok {
@@ -1255,6 +1281,7 @@ func (c *Core) sealInternal() error {
 
 	// Enable that we are sealed to prevent further transactions
 	c.sealed = true
+	metrics.SetGauge([]string{"core", "unsealed"}, 0)
 
 	c.logger.Debug("core: marked as sealed")
 
@@ -1354,6 +1381,9 @@ func (c *Core) postUnseal() (retErr error) {
 	if err := c.setupPolicyStore(); err != nil {
 		return err
 	}
+	if err := c.setupExpressionPolicyStore(); err != nil {
+		return err
+	}
 	if err := c.loadCORSConfig(); err != nil {
 		return err
 	}
@@ -1422,6 +1452,9 @@ func (c *Core) preSeal() error {
 	if err := c.teardownPolicyStore(); err != nil {
 		result = multierror.Append(result, errwrap.Wrapf("error tearing down policy store: {{err}}", err))
 	}
+	if err := c.teardownExpressionPolicyStore(); err != nil {
+		result = multierror.Append(result, errwrap.Wrapf("error tearing down expression policy store: {{err}}", err))
+	}
 	if err := c.stopRollback(); err != nil {
 		result = multierror.Append(result, errwrap.Wrapf("error stopping rollback: {{err}}", err))
 	}
@@ -1856,16 +1889,28 @@ func (c *Core) clearLeader(uuid string) error {
 	return err
 }
 
-// emitMetrics is used to periodically expose metrics while runnig
+// emitMetrics is used to periodically expose metrics while runnig. The
+// metrics of each mount are more expensive to collect, so they are emitted
+// less often.
 func (c *Core) emitMetrics(stopCh chan struct{}) {
+	mountTicker := time.NewTicker(mountMetricsInterval)
+	defer mountTicker.Stop()
+
 	for {
 		select {
 		case <-time.After(time.Second):
 			c.metricsMutex.Lock()
+			metrics.SetGauge([]string{"core", "unsealed"}, 1)
 			if c.expiration != nil {
 				c.expiration.emitMetrics()
 			}
 			c.metricsMutex.Unlock()
+		case <-mountTicker.C:
+			c.metricsMutex.Lock()
+			if c.expiration != nil {
+				c.expiration.emitMountMetrics()
+			}
+			c.metricsMutex.Unlock()
 		case <-stopCh:
 			return
 		}
@@ -18,6 +18,7 @@ import (
 	"github.com/hashicorp/vault/helper/consts"
 	"github.com/hashicorp/vault/helper/jsonutil"
 	"github.com/hashicorp/vault/helper/locksutil"
+	"github.com/hashicorp/vault/helper/metricsutil"
 	"github.com/hashicorp/vault/logical"
 )
 
@@ -62,6 +63,10 @@ type ExpirationManager struct {
 	pending     map[string]*time.Timer
 	pendingLock sync.Mutex
 
+	// mountCounts holds the last emitted number of pending leases of each
+	// mount, so that mounts without leases left are reset to zero
+	mountCounts map[string]int
+
 	tidyLock int64
 }
 
@@ -1041,6 +1046,45 @@ func (m *ExpirationManager) emitMetrics() {
 	metrics.SetGauge([]string{"expire", "num_leases"}, float32(num))
 }
 
+// emitMountMetrics is invoked periodically to emit the number of leases of
+// each secret backend and the number of tokens of each credential backend.
+// Tokens without a TTL, such as root tokens, are not counted since they have
+// no lease.
+func (m *ExpirationManager) emitMountMetrics() {
+	// Copy the lease IDs to avoid blocking leases while matching mounts
+	m.pendingLock.Lock()
+	leaseIDs := make([]string, 0, len(m.pending))
+	for leaseID := range m.pending {
+		leaseIDs = append(leaseIDs, leaseID)
+	}
+	m.pendingLock.Unlock()
+
+	counts := make(map[string]int)
+	for _, leaseID := range leaseIDs {
+		if mount := m.router.MatchingMount(leaseID); mount != "" {
+			counts[mount]++
+		}
+	}
+
+	for mount := range m.mountCounts {
+		if _, ok := counts[mount]; !ok {
+			m.emitMountCount(mount, 0)
+		}
+	}
+	for mount, count := range counts {
+		m.emitMountCount(mount, count)
+	}
+	m.mountCounts = counts
+}
+
+func (m *ExpirationManager) emitMountCount(mount string, count int) {
+	if strings.HasPrefix(mount, credentialRoutePrefix) {
+		metrics.SetGauge([]string{"token", "count", metricsutil.Label("auth", mount)}, float32(count))
+		return
+	}
+	metrics.SetGauge([]string{"expire", "leases", metricsutil.Label("mount", mount)}, float32(count))
+}
+
 // leaseEntry is used to structure the values the expiration
 // manager stores. This is used to handle renew and revocation.
 type leaseEntry struct {
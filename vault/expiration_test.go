@@ -1,6 +1,7 @@
 package vault
 
 import (
+	"bytes"
 	"fmt"
 	"reflect"
 	"sort"
@@ -9,8 +10,10 @@ import (
 	"testing"
 	"time"
 
+	"github.com/armon/go-metrics"
 	"github.com/hashicorp/go-uuid"
 	"github.com/hashicorp/vault/helper/logformat"
+	"github.com/hashicorp/vault/helper/metricsutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 	"github.com/hashicorp/vault/physical"
@@ -453,6 +456,96 @@ func TestExpiration_Register(t *testing.T) {
 	}
 }
 
+func TestExpiration_emitMountMetrics(t *testing.T) {
+	sink := metricsutil.NewPrometheusSink(time.Hour, "")
+	conf := metrics.DefaultConfig("vault")
+	conf.EnableHostname = false
+	conf.EnableRuntimeMetrics = false
+	metrics.NewGlobal(conf, sink)
+	defer metrics.NewGlobal(conf, &metrics.BlackholeSink{})
+
+	c, ts, _, _ := TestCoreWithTokenStore(t)
+	exp := ts.expiration
+	noop := &NoopBackend{}
+	view := NewBarrierView(c.barrier, "logical/")
+	meUUID, err := uuid.GenerateUUID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = exp.router.Mount(noop, "prod/aws/", &MountEntry{Path: "prod/aws/", Type: "noop", UUID: meUUID, Accessor: "noop-accessor"}, view)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root, err := exp.tokenStore.rootToken()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	var ids []string
+	for i := 0; i < 2; i++ {
+		req := &logical.Request{
+			Operation:   logical.ReadOperation,
+			Path:        "prod/aws/foo",
+			ClientToken: root.ID,
+		}
+		resp := &logical.Response{
+			Secret: &logical.Secret{
+				LeaseOptions: logical.LeaseOptions{
+					TTL: time.Hour,
+				},
+			},
+		}
+		id, err := exp.Register(req, resp)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	auth := &logical.Auth{
+		ClientToken: root.ID,
+		LeaseOptions: logical.LeaseOptions{
+			TTL: time.Hour,
+		},
+	}
+	if err := exp.RegisterAuth("auth/token/create", auth); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	expect := func(expected ...string) {
+		exp.emitMountMetrics()
+
+		var buf bytes.Buffer
+		if _, err := sink.WriteTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+		for _, line := range expected {
+			if !strings.Contains(buf.String(), line+"\n") {
+				t.Fatalf("missing %q in:\n%s", line, buf.String())
+			}
+		}
+	}
+
+	expect(
+		`vault_expire_leases{mount="prod_aws"} 2`,
+		`vault_token_count{auth="auth_token"} 1`,
+	)
+
+	// Mounts without leases left are reset to zero
+	exp.pendingLock.Lock()
+	for _, id := range ids {
+		exp.pending[id].Stop()
+		delete(exp.pending, id)
+	}
+	exp.pendingLock.Unlock()
+
+	expect(
+		`vault_expire_leases{mount="prod_aws"} 0`,
+		`vault_token_count{auth="auth_token"} 1`,
+	)
+}
+
 func TestExpiration_RegisterAuth(t *testing.T) {
 	exp := mockExpiration(t)
 	root, err := exp.tokenStore.rootToken()
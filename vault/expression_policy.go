@@ -0,0 +1,196 @@
+package vault
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/helper/policyexpr"
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+)
+
+const (
+	// EnforcementAdvisory logs failures of an expression policy, but allows
+	// the request
+	EnforcementAdvisory = "advisory"
+
+	// EnforcementSoftMandatory denies requests failing an expression policy
+	// unless the request sets the policy override flag
+	EnforcementSoftMandatory = "soft-mandatory"
+
+	// EnforcementHardMandatory denies requests failing an expression policy
+	EnforcementHardMandatory = "hard-mandatory"
+)
+
+var enforcementLevels = []string{
+	EnforcementAdvisory,
+	EnforcementSoftMandatory,
+	EnforcementHardMandatory,
+}
+
+// ExpressionPolicy is a policy written in the expression language of the
+// policyexpr package. Expression policies are evaluated after the ACL allows
+// a request, and allow it only when the expression is true. A policy applies
+// to requests on any of its paths, and to requests made with tokens that
+// carry a policy of the same name.
+type ExpressionPolicy struct {
+	Name             string   `json:"name"`
+	Expression       string   `json:"expression"`
+	EnforcementLevel string   `json:"enforcement_level"`
+	Paths            []string `json:"paths"`
+
+	expr *policyexpr.Expression
+}
+
+// compile validates the policy and parses its expression
+func (p *ExpressionPolicy) compile() error {
+	if p.EnforcementLevel == "" {
+		p.EnforcementLevel = EnforcementHardMandatory
+	}
+	if !strutil.StrListContains(enforcementLevels, p.EnforcementLevel) {
+		return fmt.Errorf("invalid enforcement level %q, must be one of: %s",
+			p.EnforcementLevel, strings.Join(enforcementLevels, ", "))
+	}
+
+	for i, path := range p.Paths {
+		path = strings.TrimPrefix(strings.TrimSpace(path), "/")
+		if path == "" {
+			return fmt.Errorf("paths cannot be empty")
+		}
+		if strings.Contains(strings.TrimSuffix(path, "*"), "*") {
+			return fmt.Errorf("path %q: the glob can only be the last character", path)
+		}
+		p.Paths[i] = path
+	}
+
+	expr, err := policyexpr.Parse(p.Expression)
+	if err != nil {
+		return errwrap.Wrapf("failed to parse expression: {{err}}", err)
+	}
+	p.expr = expr
+	return nil
+}
+
+// appliesTo returns whether the policy applies to a request on the path made
+// with a token carrying the given policies
+func (p *ExpressionPolicy) appliesTo(path string, policies []string) bool {
+	if strutil.StrListContains(policies, p.Name) {
+		return true
+	}
+	for _, pattern := range p.Paths {
+		if matchesWildcardRule(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkExpressionPolicies evaluates the expression policies that apply to a
+// request the ACL has allowed. Failed advisory policies are logged, and any
+// failed mandatory policy denies the request unless it is soft-mandatory and
+// the request sets the policy override flag.
+func (c *Core) checkExpressionPolicies(req *logical.Request, te *TokenEntry) error {
+	if c.expressionPolicyStore == nil {
+		return nil
+	}
+
+	policies := c.expressionPolicyStore.applicable(req.Path, te.Policies)
+	if len(policies) == 0 {
+		return nil
+	}
+	defer metrics.MeasureSince([]string{"core", "check_expression_policies"}, time.Now())
+
+	vars := c.expressionPolicyVars(req, te)
+
+	var denied []string
+	for _, policy := range policies {
+		passed, err := policy.expr.EvalBool(vars)
+		if err != nil {
+			c.logger.Warn("core: failed to evaluate expression policy", "policy", policy.Name, "path", req.Path, "error", err)
+		}
+		if passed {
+			continue
+		}
+
+		switch {
+		case policy.EnforcementLevel == EnforcementAdvisory:
+			c.logger.Warn("core: advisory expression policy failed", "policy", policy.Name, "path", req.Path)
+		case policy.EnforcementLevel == EnforcementSoftMandatory && req.PolicyOverride:
+			c.logger.Warn("core: overriding failed soft-mandatory expression policy", "policy", policy.Name, "path", req.Path)
+		default:
+			denied = append(denied, policy.Name)
+		}
+	}
+
+	if len(denied) > 0 {
+		return errwrap.Wrap(fmt.Errorf("permission denied by expression policies: %s", strings.Join(denied, ", ")), logical.ErrPermissionDenied)
+	}
+	return nil
+}
+
+// expressionPolicyVars returns the variables expression policies are
+// evaluated with
+func (c *Core) expressionPolicyVars(req *logical.Request, te *TokenEntry) map[string]interface{} {
+	data := req.Data
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+
+	var clientIP string
+	if req.Connection != nil {
+		clientIP = req.Connection.RemoteAddr
+		if host, _, err := net.SplitHostPort(clientIP); err == nil {
+			clientIP = host
+		}
+	}
+
+	var wrapTTL time.Duration
+	if req.WrapInfo != nil {
+		wrapTTL = req.WrapInfo.TTL
+	}
+
+	var mountPoint, mountType, mountAccessor string
+	if entry := c.router.MatchingMountEntry(req.Path); entry != nil {
+		mountPoint = c.router.MatchingMount(req.Path)
+		mountType = entry.Type
+		mountAccessor = entry.Accessor
+	}
+
+	metadata := te.Meta
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+
+	return map[string]interface{}{
+		"request": map[string]interface{}{
+			"id":              req.ID,
+			"operation":       string(req.Operation),
+			"path":            req.Path,
+			"data":            data,
+			"mount_point":     mountPoint,
+			"mount_type":      mountType,
+			"mount_accessor":  mountAccessor,
+			"client_ip":       clientIP,
+			"wrap_ttl":        wrapTTL,
+			"policy_override": req.PolicyOverride,
+		},
+		"token": map[string]interface{}{
+			"accessor":         te.Accessor,
+			"policies":         te.Policies,
+			"metadata":         metadata,
+			"display_name":     te.DisplayName,
+			"path":             te.Path,
+			"role":             te.Role,
+			"creation_time":    time.Unix(te.CreationTime, 0).UTC(),
+			"ttl":              te.TTL,
+			"explicit_max_ttl": te.ExplicitMaxTTL,
+			"period":           te.Period,
+			"num_uses":         te.NumUses,
+		},
+		"now": time.Now().UTC(),
+	}
+}
@@ -0,0 +1,194 @@
+package vault
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/vault/logical"
+)
+
+const (
+	// expressionPolicySubPath is the sub-path used for the expression policy
+	// store view. This is nested under the system view.
+	expressionPolicySubPath = "expression-policy/"
+)
+
+// ExpressionPolicyStore is used to provide durable storage of expression
+// policies. All policies are kept in memory, since every request has to be
+// matched against their paths.
+type ExpressionPolicyStore struct {
+	view *BarrierView
+
+	l        sync.RWMutex
+	policies map[string]*ExpressionPolicy
+}
+
+// NewExpressionPolicyStore creates a new ExpressionPolicyStore that is backed
+// using a given view
+func NewExpressionPolicyStore(view *BarrierView) *ExpressionPolicyStore {
+	return &ExpressionPolicyStore{
+		view:     view,
+		policies: make(map[string]*ExpressionPolicy),
+	}
+}
+
+// setupExpressionPolicyStore is used to initialize the expression policy
+// store when the vault is being unsealed
+func (c *Core) setupExpressionPolicyStore() error {
+	// Create a sub-view
+	view := c.systemBarrierView.SubView(expressionPolicySubPath)
+
+	c.expressionPolicyStore = NewExpressionPolicyStore(view)
+	return c.expressionPolicyStore.load()
+}
+
+// teardownExpressionPolicyStore is used to reverse
+// setupExpressionPolicyStore
+func (c *Core) teardownExpressionPolicyStore() error {
+	c.expressionPolicyStore = nil
+	return nil
+}
+
+// load reads all the policies from storage
+func (ps *ExpressionPolicyStore) load() error {
+	names, err := logical.CollectKeys(ps.view)
+	if err != nil {
+		return fmt.Errorf("failed to list expression policies: %v", err)
+	}
+
+	policies := make(map[string]*ExpressionPolicy, len(names))
+	for _, name := range names {
+		policy, err := ps.read(name)
+		if err != nil {
+			return err
+		}
+		if policy != nil {
+			policies[name] = policy
+		}
+	}
+
+	ps.l.Lock()
+	ps.policies = policies
+	ps.l.Unlock()
+	return nil
+}
+
+// read reads the named policy from storage
+func (ps *ExpressionPolicyStore) read(name string) (*ExpressionPolicy, error) {
+	out, err := ps.view.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read expression policy: %v", err)
+	}
+	if out == nil {
+		return nil, nil
+	}
+
+	policy := new(ExpressionPolicy)
+	if err := out.DecodeJSON(policy); err != nil {
+		return nil, fmt.Errorf("failed to decode expression policy: %v", err)
+	}
+	policy.Name = name
+	if err := policy.compile(); err != nil {
+		return nil, fmt.Errorf("failed to compile expression policy %q: %v", name, err)
+	}
+	return policy, nil
+}
+
+func (ps *ExpressionPolicyStore) invalidate(name string) {
+	// This may come with a prefixed "/" due to joining the file path
+	name = strings.TrimPrefix(name, "/")
+
+	policy, err := ps.read(name)
+
+	ps.l.Lock()
+	defer ps.l.Unlock()
+	if err != nil || policy == nil {
+		delete(ps.policies, name)
+		return
+	}
+	ps.policies[name] = policy
+}
+
+// SetPolicy is used to create or update the given policy
+func (ps *ExpressionPolicyStore) SetPolicy(p *ExpressionPolicy) error {
+	defer metrics.MeasureSince([]string{"expression_policy", "set_policy"}, time.Now())
+	if p.Name == "" {
+		return fmt.Errorf("policy name missing")
+	}
+	if p.Name == "root" {
+		return fmt.Errorf("cannot use root as an expression policy name")
+	}
+	if err := p.compile(); err != nil {
+		return err
+	}
+
+	entry, err := logical.StorageEntryJSON(p.Name, p)
+	if err != nil {
+		return fmt.Errorf("failed to create entry: %v", err)
+	}
+	if err := ps.view.Put(entry); err != nil {
+		return fmt.Errorf("failed to persist expression policy: %v", err)
+	}
+
+	ps.l.Lock()
+	ps.policies[p.Name] = p
+	ps.l.Unlock()
+	return nil
+}
+
+// GetPolicy is used to fetch the named policy
+func (ps *ExpressionPolicyStore) GetPolicy(name string) (*ExpressionPolicy, error) {
+	defer metrics.MeasureSince([]string{"expression_policy", "get_policy"}, time.Now())
+	ps.l.RLock()
+	defer ps.l.RUnlock()
+	return ps.policies[name], nil
+}
+
+// ListPolicies is used to list the available policies
+func (ps *ExpressionPolicyStore) ListPolicies() ([]string, error) {
+	defer metrics.MeasureSince([]string{"expression_policy", "list_policies"}, time.Now())
+	ps.l.RLock()
+	defer ps.l.RUnlock()
+
+	names := make([]string, 0, len(ps.policies))
+	for name := range ps.policies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// DeletePolicy is used to delete the named policy
+func (ps *ExpressionPolicyStore) DeletePolicy(name string) error {
+	defer metrics.MeasureSince([]string{"expression_policy", "delete_policy"}, time.Now())
+	if err := ps.view.Delete(name); err != nil {
+		return fmt.Errorf("failed to delete expression policy: %v", err)
+	}
+
+	ps.l.Lock()
+	delete(ps.policies, name)
+	ps.l.Unlock()
+	return nil
+}
+
+// applicable returns the policies that apply to a request on the path made
+// with a token carrying the given policies, sorted by name
+func (ps *ExpressionPolicyStore) applicable(path string, policies []string) []*ExpressionPolicy {
+	ps.l.RLock()
+	defer ps.l.RUnlock()
+
+	var result []*ExpressionPolicy
+	for _, policy := range ps.policies {
+		if policy.appliesTo(path, policies) {
+			result = append(result, policy)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
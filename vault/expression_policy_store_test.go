@@ -0,0 +1,155 @@
+package vault
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func mockExpressionPolicyStore(t *testing.T) *ExpressionPolicyStore {
+	_, barrier, _ := mockBarrier(t)
+	view := NewBarrierView(barrier, "foo/")
+	return NewExpressionPolicyStore(view)
+}
+
+func TestExpressionPolicyStore_CRUD(t *testing.T) {
+	ps := mockExpressionPolicyStore(t)
+
+	policy := &ExpressionPolicy{
+		Name:       "business-hours",
+		Expression: `now.getDayOfWeek() >= 1 && now.getDayOfWeek() <= 5`,
+		Paths:      []string{"/secret/*", "pki/issue/+"},
+	}
+	if err := ps.SetPolicy(policy); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	out, err := ps.GetPolicy("business-hours")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if out == nil || out.EnforcementLevel != EnforcementHardMandatory ||
+		!reflect.DeepEqual(out.Paths, []string{"secret/*", "pki/issue/+"}) {
+		t.Fatalf("bad: %#v", out)
+	}
+
+	// A new store reads the policy from storage
+	reloaded := NewExpressionPolicyStore(ps.view)
+	if err := reloaded.load(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	out, err = reloaded.GetPolicy("business-hours")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if out == nil || out.expr == nil || out.Expression != policy.Expression {
+		t.Fatalf("bad: %#v", out)
+	}
+
+	names, err := ps.ListPolicies()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(names, []string{"business-hours"}) {
+		t.Fatalf("bad: %v", names)
+	}
+
+	if err := ps.DeletePolicy("business-hours"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	out, err = ps.GetPolicy("business-hours")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if out != nil {
+		t.Fatalf("bad: %#v", out)
+	}
+
+	// Invalidation drops the deleted policy from the other store
+	reloaded.invalidate("business-hours")
+	names, err = reloaded.ListPolicies()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(names) != 0 {
+		t.Fatalf("bad: %v", names)
+	}
+}
+
+func TestExpressionPolicyStore_invalid(t *testing.T) {
+	ps := mockExpressionPolicyStore(t)
+
+	cases := map[string]*ExpressionPolicy{
+		"policy name missing": &ExpressionPolicy{
+			Expression: "true",
+		},
+		"cannot use root": &ExpressionPolicy{
+			Name:       "root",
+			Expression: "true",
+		},
+		"invalid enforcement level": &ExpressionPolicy{
+			Name:             "foo",
+			Expression:       "true",
+			EnforcementLevel: "mandatory",
+		},
+		"paths cannot be empty": &ExpressionPolicy{
+			Name:       "foo",
+			Expression: "true",
+			Paths:      []string{""},
+		},
+		"glob can only be the last character": &ExpressionPolicy{
+			Name:       "foo",
+			Expression: "true",
+			Paths:      []string{"secret/*/foo"},
+		},
+		"failed to parse expression": &ExpressionPolicy{
+			Name:       "foo",
+			Expression: "request.path ==",
+		},
+	}
+
+	for expected, policy := range cases {
+		err := ps.SetPolicy(policy)
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Fatalf("expected error containing %q, got: %v", expected, err)
+		}
+	}
+}
+
+func TestExpressionPolicyStore_applicable(t *testing.T) {
+	ps := mockExpressionPolicyStore(t)
+
+	for _, policy := range []*ExpressionPolicy{
+		{Name: "secrets", Expression: "true", Paths: []string{"secret/*"}},
+		{Name: "pki", Expression: "true", Paths: []string{"pki/issue/+"}},
+		{Name: "exact", Expression: "true", Paths: []string{"secret/foo"}},
+		{Name: "attached", Expression: "true"},
+	} {
+		if err := ps.SetPolicy(policy); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	cases := []struct {
+		path     string
+		policies []string
+		expected []string
+	}{
+		{"secret/foo", nil, []string{"exact", "secrets"}},
+		{"secret/foo/bar", []string{"default"}, []string{"secrets"}},
+		{"pki/issue/web", nil, []string{"pki"}},
+		{"pki/issue/web/extra", nil, nil},
+		{"sys/mounts", []string{"default", "attached"}, []string{"attached"}},
+		{"sys/mounts", []string{"default"}, nil},
+	}
+
+	for _, c := range cases {
+		var actual []string
+		for _, policy := range ps.applicable(c.path, c.policies) {
+			actual = append(actual, policy.Name)
+		}
+		if !reflect.DeepEqual(actual, c.expected) {
+			t.Fatalf("%s %v: expected %v, got %v", c.path, c.policies, c.expected, actual)
+		}
+	}
+}
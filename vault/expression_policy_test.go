@@ -0,0 +1,139 @@
+package vault
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestCore_ExpressionPolicies(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+
+	req := &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "sys/policy/test",
+		Data: map[string]interface{}{
+			"rules": `path "secret/*" { policy = "write" }`,
+		},
+		ClientToken: root,
+	}
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	testCoreMakeToken(t, c, root, "child", "", []string{"test"})
+
+	write := func(token, path string, override bool) (*logical.Response, error) {
+		req := &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      path,
+			Data: map[string]interface{}{
+				"foo": "bar",
+			},
+			ClientToken:    token,
+			PolicyOverride: override,
+			Connection: &logical.Connection{
+				RemoteAddr: "10.1.2.3:51234",
+			},
+		}
+		return c.HandleRequest(req)
+	}
+	setPolicy := func(name, level string, paths []string) {
+		err := c.expressionPolicyStore.SetPolicy(&ExpressionPolicy{
+			Name:             name,
+			Expression:       `request.data.foo == "baz" || request.path.endsWith("/ok")`,
+			EnforcementLevel: level,
+			Paths:            paths,
+		})
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	denied := func(err error) bool {
+		return err != nil && errwrap.Contains(err, logical.ErrPermissionDenied.Error())
+	}
+
+	// Advisory policies only log
+	setPolicy("check", EnforcementAdvisory, []string{"secret/*"})
+	if _, err := write("child", "secret/foo", false); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Soft-mandatory policies can be overridden
+	setPolicy("check", EnforcementSoftMandatory, []string{"secret/*"})
+	if _, err := write("child", "secret/foo", false); !denied(err) {
+		t.Fatalf("expected permission denied, got: %v", err)
+	}
+	if _, err := write("child", "secret/foo", true); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Hard-mandatory policies cannot
+	setPolicy("check", EnforcementHardMandatory, []string{"secret/*"})
+	resp, err := write("child", "secret/foo", true)
+	if !denied(err) {
+		t.Fatalf("expected permission denied, got: %v", err)
+	}
+	if resp == nil || !strings.Contains(resp.Data["error"].(string), "expression policies: check") {
+		t.Fatalf("expected the failed policy to be named, got: %#v", resp)
+	}
+	if _, err := write("child", "secret/ok", false); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Root tokens are not subject to expression policies
+	if _, err := write(root, "secret/foo", false); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Policies attached to tokens apply on any path
+	setPolicy("check", EnforcementHardMandatory, nil)
+	if _, err := write("child", "secret/foo", false); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	testCoreMakeToken(t, c, root, "attached", "", []string{"test", "check"})
+	if _, err := write("attached", "secret/foo", false); !denied(err) {
+		t.Fatalf("expected permission denied, got: %v", err)
+	}
+}
+
+func TestCore_ExpressionPolicies_vars(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+	testCoreMakeToken(t, c, root, "child", "", []string{"default"})
+
+	err := c.expressionPolicyStore.SetPolicy(&ExpressionPolicy{
+		Name: "vars",
+		Expression: `request.operation == "read" &&
+			request.path == "auth/token/lookup-self" &&
+			request.mount_point == "auth/token/" &&
+			request.mount_type == "token" &&
+			inCIDR(request.client_ip, "10.0.0.0/8") &&
+			!request.policy_override &&
+			"default" in token.policies &&
+			token.path == "auth/token/create" &&
+			token.ttl > duration("1h") &&
+			token.creation_time <= now`,
+		Paths: []string{"auth/token/lookup-self"},
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	req := &logical.Request{
+		Operation:   logical.ReadOperation,
+		Path:        "auth/token/lookup-self",
+		ClientToken: "child",
+		Connection: &logical.Connection{
+			RemoteAddr: "10.1.2.3:51234",
+		},
+	}
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	req.Connection.RemoteAddr = "192.168.1.1:51234"
+	if _, err := c.HandleRequest(req); err == nil || !errwrap.Contains(err, logical.ErrPermissionDenied.Error()) {
+		t.Fatalf("expected permission denied, got: %v", err)
+	}
+}
@@ -1,6 +1,7 @@
 package vault
 
 import (
+	"bytes"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
@@ -11,6 +12,7 @@ import (
 
 	"github.com/fatih/structs"
 	"github.com/hashicorp/vault/helper/consts"
+	"github.com/hashicorp/vault/helper/metricsutil"
 	"github.com/hashicorp/vault/helper/parseutil"
 	"github.com/hashicorp/vault/helper/wrapping"
 	"github.com/hashicorp/vault/logical"
@@ -549,6 +551,51 @@ func NewSystemBackend(core *Core) *SystemBackend {
 				HelpDescription: strings.TrimSpace(sysHelp["policy"][1]),
 			},
 
+			&framework.Path{
+				Pattern: "expression-policy/?$",
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation: b.handleExpressionPolicyList,
+					logical.ListOperation: b.handleExpressionPolicyList,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["expression-policy-list"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["expression-policy-list"][1]),
+			},
+
+			&framework.Path{
+				Pattern: "expression-policy/(?P<name>.+)",
+
+				Fields: map[string]*framework.FieldSchema{
+					"name": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["expression-policy-name"][0]),
+					},
+					"expression": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["expression-policy-expression"][0]),
+					},
+					"enforcement_level": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Default:     EnforcementHardMandatory,
+						Description: strings.TrimSpace(sysHelp["expression-policy-enforcement-level"][0]),
+					},
+					"paths": &framework.FieldSchema{
+						Type:        framework.TypeCommaStringSlice,
+						Description: strings.TrimSpace(sysHelp["expression-policy-paths"][0]),
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.handleExpressionPolicyRead,
+					logical.UpdateOperation: b.handleExpressionPolicySet,
+					logical.DeleteOperation: b.handleExpressionPolicyDelete,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["expression-policy"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["expression-policy"][1]),
+			},
+
 			&framework.Path{
 				Pattern:         "seal-status$",
 				HelpSynopsis:    strings.TrimSpace(sysHelp["seal-status"][0]),
@@ -666,6 +713,17 @@ func NewSystemBackend(core *Core) *SystemBackend {
 				HelpDescription: strings.TrimSpace(sysHelp["key-status"][1]),
 			},
 
+			&framework.Path{
+				Pattern: "metrics$",
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation: b.handleMetrics,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["metrics"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["metrics"][1]),
+			},
+
 			&framework.Path{
 				Pattern: "rotate$",
 
@@ -921,6 +979,12 @@ func (b *SystemBackend) invalidate(key string) {
 		if b.Core.policyStore != nil {
 			b.Core.policyStore.invalidate(strings.TrimPrefix(key, policySubPath))
 		}
+	case strings.HasPrefix(key, expressionPolicySubPath):
+		b.Core.stateLock.RLock()
+		defer b.Core.stateLock.RUnlock()
+		if b.Core.expressionPolicyStore != nil {
+			b.Core.expressionPolicyStore.invalidate(strings.TrimPrefix(key, expressionPolicySubPath))
+		}
 	}
 }
 
@@ -1887,6 +1951,76 @@ func (b *SystemBackend) handlePolicyDelete(
 	return nil, nil
 }
 
+// handleExpressionPolicyList handles the "expression-policy" endpoint to
+// list the expression policies
+func (b *SystemBackend) handleExpressionPolicyList(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	policies, err := b.Core.expressionPolicyStore.ListPolicies()
+	if err != nil {
+		return handleError(err)
+	}
+	return logical.ListResponse(policies), nil
+}
+
+// handleExpressionPolicyRead handles the "expression-policy/<name>" endpoint
+// to read an expression policy
+func (b *SystemBackend) handleExpressionPolicyRead(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := strings.ToLower(data.Get("name").(string))
+
+	policy, err := b.Core.expressionPolicyStore.GetPolicy(name)
+	if err != nil {
+		return handleError(err)
+	}
+	if policy == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"name":              policy.Name,
+			"expression":        policy.Expression,
+			"enforcement_level": policy.EnforcementLevel,
+			"paths":             policy.Paths,
+		},
+	}, nil
+}
+
+// handleExpressionPolicySet handles the "expression-policy/<name>" endpoint
+// to set an expression policy
+func (b *SystemBackend) handleExpressionPolicySet(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := strings.ToLower(data.Get("name").(string))
+
+	expression := data.Get("expression").(string)
+	if expression == "" {
+		return logical.ErrorResponse("'expression' parameter not supplied"), nil
+	}
+
+	policy := &ExpressionPolicy{
+		Name:             name,
+		Expression:       expression,
+		EnforcementLevel: data.Get("enforcement_level").(string),
+		Paths:            data.Get("paths").([]string),
+	}
+	if err := b.Core.expressionPolicyStore.SetPolicy(policy); err != nil {
+		return handleError(err)
+	}
+	return nil, nil
+}
+
+// handleExpressionPolicyDelete handles the "expression-policy/<name>"
+// endpoint to delete an expression policy
+func (b *SystemBackend) handleExpressionPolicyDelete(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := strings.ToLower(data.Get("name").(string))
+
+	if err := b.Core.expressionPolicyStore.DeletePolicy(name); err != nil {
+		return handleError(err)
+	}
+	return nil, nil
+}
+
 // handleAuditTable handles the "audit" endpoint to provide the audit table
 func (b *SystemBackend) handleAuditTable(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
@@ -2081,6 +2215,28 @@ func (b *SystemBackend) handleKeyStatus(
 	return resp, nil
 }
 
+// handleMetrics returns the metrics in the Prometheus text format
+func (b *SystemBackend) handleMetrics(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	if b.Core.metricsSink == nil {
+		return logical.ErrorResponse("metrics are not enabled; set prometheus_retention_time in the telemetry configuration"), nil
+	}
+
+	var buf bytes.Buffer
+	if _, err := b.Core.metricsSink.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPStatusCode:  200,
+			logical.HTTPContentType: metricsutil.PrometheusContentType,
+			logical.HTTPRawBody:     buf.Bytes(),
+		},
+	}
+	return resp, nil
+}
+
 // handleRotate is used to trigger a key rotation
 func (b *SystemBackend) handleRotate(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
@@ -2711,6 +2867,60 @@ or delete a policy.
 		"",
 	},
 
+	"expression-policy-list": {
+		`List the configured expression policies.`,
+		`
+This path responds to the following HTTP methods.
+
+    LIST /
+        List the names of the configured expression policies.
+
+    GET /<name>
+        Retrieve the named expression policy.
+
+    PUT /<name>
+        Add or update an expression policy.
+
+    DELETE /<name>
+        Delete the expression policy with the given name.
+		`,
+	},
+
+	"expression-policy": {
+		`Read, Modify, or Delete an expression policy.`,
+		`
+Expression policies are evaluated after the access control policies allow a
+request, and allow it only if their expression is true. A policy applies to
+requests on any of its paths, and to requests made with tokens that carry a
+policy of the same name. Expressions can use the "request", "token" and "now"
+variables.
+		`,
+	},
+
+	"expression-policy-name": {
+		`The name of the expression policy. Example: "business-hours"`,
+		"",
+	},
+
+	"expression-policy-expression": {
+		`The expression deciding whether a request is allowed. Example:
+'now.getDayOfWeek() >= 1 && now.getDayOfWeek() <= 5'`,
+		"",
+	},
+
+	"expression-policy-enforcement-level": {
+		`What happens when the expression is false: "advisory" logs the failure,
+"soft-mandatory" denies the request unless it sets the policy override flag,
+and "hard-mandatory" denies the request. Defaults to "hard-mandatory".`,
+		"",
+	},
+
+	"expression-policy-paths": {
+		`Comma-separated list of paths the policy applies to. Paths can end in a
+"*" glob and use "+" to match a single path segment.`,
+		"",
+	},
+
 	"audit-hash": {
 		"The hash of the given string via the given audit backend",
 		"",
@@ -2766,6 +2976,15 @@ Enable a new audit backend or disable an existing backend.
 		`,
 	},
 
+	"metrics": {
+		"Export the metrics of the server in the Prometheus text format.",
+		`
+		Returns the metrics recorded since the server started, in the text
+		exposition format scraped by Prometheus. This endpoint is only enabled
+		if the prometheus_retention_time telemetry option is set.
+		`,
+	},
+
 	"rotate": {
 		"Rotates the backend encryption key used to persist data.",
 		`
@@ -15,6 +15,7 @@ import (
 	"github.com/fatih/structs"
 	"github.com/hashicorp/vault/audit"
 	"github.com/hashicorp/vault/helper/builtinplugins"
+	"github.com/hashicorp/vault/helper/metricsutil"
 	"github.com/hashicorp/vault/helper/pluginutil"
 	"github.com/hashicorp/vault/helper/salt"
 	"github.com/hashicorp/vault/logical"
@@ -1294,6 +1295,85 @@ func TestSystemBackend_policyCRUD(t *testing.T) {
 	}
 }
 
+func TestSystemBackend_expressionPolicyCRUD(t *testing.T) {
+	b := testSystemBackend(t)
+
+	// Create the policy
+	req := logical.TestRequest(t, logical.UpdateOperation, "expression-policy/Foo")
+	req.Data["expression"] = `request.data.ttl == "1h"`
+	req.Data["enforcement_level"] = "soft-mandatory"
+	req.Data["paths"] = "secret/*,pki/issue/+"
+	resp, err := b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v %#v", err, resp)
+	}
+	if resp != nil {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	// Read the policy
+	req = logical.TestRequest(t, logical.ReadOperation, "expression-policy/foo")
+	resp, err = b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	exp := map[string]interface{}{
+		"name":              "foo",
+		"expression":        `request.data.ttl == "1h"`,
+		"enforcement_level": "soft-mandatory",
+		"paths":             []string{"secret/*", "pki/issue/+"},
+	}
+	if !reflect.DeepEqual(resp.Data, exp) {
+		t.Fatalf("got: %#v expect: %#v", resp.Data, exp)
+	}
+
+	// List the policies
+	req = logical.TestRequest(t, logical.ListOperation, "expression-policy/")
+	resp, err = b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	exp = map[string]interface{}{
+		"keys": []string{"foo"},
+	}
+	if !reflect.DeepEqual(resp.Data, exp) {
+		t.Fatalf("got: %#v expect: %#v", resp.Data, exp)
+	}
+
+	// Invalid expressions are rejected
+	req = logical.TestRequest(t, logical.UpdateOperation, "expression-policy/bar")
+	req.Data["expression"] = `request.data.ttl ==`
+	resp, err = b.HandleRequest(req)
+	if err != logical.ErrInvalidRequest {
+		t.Fatalf("err: %v", err)
+	}
+	if resp == nil || !strings.Contains(resp.Data["error"].(string), "failed to parse expression") {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	// Delete the policy
+	req = logical.TestRequest(t, logical.DeleteOperation, "expression-policy/foo")
+	resp, err = b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp != nil {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	// Read the policy (deleted)
+	req = logical.TestRequest(t, logical.ReadOperation, "expression-policy/foo")
+	resp, err = b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp != nil {
+		t.Fatalf("bad: %#v", resp)
+	}
+}
+
 func TestSystemBackend_enableAudit(t *testing.T) {
 	c, b, _ := testCoreSystemBackend(t)
 	c.auditBackends["noop"] = func(config *audit.BackendConfig) (audit.Backend, error) {
@@ -1556,6 +1636,38 @@ func TestSystemBackend_keyStatus(t *testing.T) {
 	}
 }
 
+func TestSystemBackend_metrics(t *testing.T) {
+	c, b, _ := testCoreSystemBackend(t)
+
+	req := logical.TestRequest(t, logical.ReadOperation, "metrics")
+	resp, err := b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error while disabled, got: %#v", resp)
+	}
+
+	c.metricsSink = metricsutil.NewPrometheusSink(time.Hour, "")
+	c.metricsSink.SetGauge([]string{"vault", "core", "unsealed"}, 1)
+
+	resp, err = b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if resp.Data[logical.HTTPStatusCode] != 200 {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	if resp.Data[logical.HTTPContentType] != metricsutil.PrometheusContentType {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	body := string(resp.Data[logical.HTTPRawBody].([]byte))
+	if body != "# TYPE vault_core_unsealed gauge\nvault_core_unsealed 1\n" {
+		t.Fatalf("bad: %q", body)
+	}
+}
+
 func TestSystemBackend_rotate(t *testing.T) {
 	b := testSystemBackend(t)
 
@@ -69,12 +69,21 @@ type PathCapabilities struct {
 	Glob         bool
 	Capabilities []string
 
+	// Templated is set if the prefix contains templates, such as
+	// {{token.metadata.user}}, which are rendered for each token
+	Templated bool
+
+	// HasSegmentWildcards is set if the prefix contains '+' segments, each
+	// of which matches any single path segment
+	HasSegmentWildcards bool
+
 	// These keys are used at the top level to make the HCL nicer; we store in
 	// the Permissions object though
-	MinWrappingTTLHCL    interface{}              `hcl:"min_wrapping_ttl"`
-	MaxWrappingTTLHCL    interface{}              `hcl:"max_wrapping_ttl"`
-	AllowedParametersHCL map[string][]interface{} `hcl:"allowed_parameters"`
-	DeniedParametersHCL  map[string][]interface{} `hcl:"denied_parameters"`
+	MinWrappingTTLHCL     interface{}              `hcl:"min_wrapping_ttl"`
+	MaxWrappingTTLHCL     interface{}              `hcl:"max_wrapping_ttl"`
+	AllowedParametersHCL  map[string][]interface{} `hcl:"allowed_parameters"`
+	DeniedParametersHCL   map[string][]interface{} `hcl:"denied_parameters"`
+	RequiredParametersHCL []string                 `hcl:"required_parameters"`
 }
 
 type Permissions struct {
@@ -83,6 +92,7 @@ type Permissions struct {
 	MaxWrappingTTL     time.Duration
 	AllowedParameters  map[string][]interface{}
 	DeniedParameters   map[string][]interface{}
+	RequiredParameters []string
 }
 
 func (p *Permissions) Clone() (*Permissions, error) {
@@ -116,6 +126,11 @@ func (p *Permissions) Clone() (*Permissions, error) {
 		ret.DeniedParameters = clonedDenied.(map[string][]interface{})
 	}
 
+	if p.RequiredParameters != nil {
+		ret.RequiredParameters = make([]string, len(p.RequiredParameters))
+		copy(ret.RequiredParameters, p.RequiredParameters)
+	}
+
 	return ret, nil
 }
 
@@ -172,6 +187,7 @@ func parsePaths(result *Policy, list *ast.ObjectList) error {
 			"capabilities",
 			"allowed_parameters",
 			"denied_parameters",
+			"required_parameters",
 			"min_wrapping_ttl",
 			"max_wrapping_ttl",
 		}
@@ -200,6 +216,17 @@ func parsePaths(result *Policy, list *ast.ObjectList) error {
 			pc.Glob = true
 		}
 
+		if isTemplatedPath(pc.Prefix) {
+			if err := validatePathTemplate(pc.Prefix); err != nil {
+				return fmt.Errorf("path %q: %s", key, err)
+			}
+			pc.Templated = true
+		}
+
+		if hasSegmentWildcards(pc.Prefix) {
+			pc.HasSegmentWildcards = true
+		}
+
 		// Map old-style policies into capabilities
 		if len(pc.Policy) > 0 {
 			switch pc.Policy {
@@ -245,6 +272,12 @@ func parsePaths(result *Policy, list *ast.ObjectList) error {
 				pc.Permissions.DeniedParameters[strings.ToLower(key)] = val
 			}
 		}
+		if pc.RequiredParametersHCL != nil {
+			pc.Permissions.RequiredParameters = make([]string, 0, len(pc.RequiredParametersHCL))
+			for _, key := range pc.RequiredParametersHCL {
+				pc.Permissions.RequiredParameters = append(pc.Permissions.RequiredParameters, strings.ToLower(key))
+			}
+		}
 		if pc.MinWrappingTTLHCL != nil {
 			dur, err := parseutil.ParseDurationSecond(pc.MinWrappingTTLHCL)
 			if err != nil {
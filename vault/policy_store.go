@@ -338,8 +338,9 @@ func (ps *PolicyStore) DeletePolicy(name string) error {
 }
 
 // ACL is used to return an ACL which is built using the
-// named policies.
-func (ps *PolicyStore) ACL(names ...string) (*ACL, error) {
+// named policies. Templated paths are rendered with the template data,
+// which may be nil.
+func (ps *PolicyStore) ACL(templateData *ACLTemplateData, names ...string) (*ACL, error) {
 	// Fetch the policies
 	var policy []*Policy
 	for _, name := range names {
@@ -351,7 +352,7 @@ func (ps *PolicyStore) ACL(names ...string) (*ACL, error) {
 	}
 
 	// Construct the ACL
-	acl, err := NewACL(policy)
+	acl, err := NewTemplatedACL(policy, templateData)
 	if err != nil {
 		return nil, fmt.Errorf("failed to construct ACL: %v", err)
 	}
@@ -0,0 +1,101 @@
+package vault
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	pathTemplateTokenMetadataPrefix = "token.metadata."
+	pathTemplateMountAccessor       = "auth.mount_accessor"
+)
+
+// pathTemplateRe matches a template such as {{token.metadata.user}} in a
+// policy path
+var pathTemplateRe = regexp.MustCompile(`\{\{\s*([^{}\s]*)\s*\}\}`)
+
+// ACLTemplateData holds the values templated policy paths are rendered with
+// for a token.
+type ACLTemplateData struct {
+	// TokenMetadata is the metadata of the token
+	TokenMetadata map[string]string
+
+	// MountAccessor is the accessor of the auth backend the token was
+	// created by
+	MountAccessor string
+}
+
+// aclTemplateData returns the template data of the token. The metadata of
+// tokens created by the token store is set by whoever creates them, so it is
+// left out; otherwise the holder of a templated policy could create a child
+// token with forged metadata to be granted the paths of another user.
+func (c *Core) aclTemplateData(te *TokenEntry) *ACLTemplateData {
+	data := &ACLTemplateData{}
+	if !strings.HasPrefix(te.Path, "auth/token/") {
+		data.TokenMetadata = te.Meta
+	}
+	if me := c.router.MatchingMountEntry(te.Path); me != nil {
+		data.MountAccessor = me.Accessor
+	}
+	return data
+}
+
+// isTemplatedPath returns whether the policy path contains templates
+func isTemplatedPath(path string) bool {
+	return strings.Contains(path, "{{")
+}
+
+// validatePathTemplate checks that the templates in the policy path are
+// well-formed and supported
+func validatePathTemplate(path string) error {
+	for _, match := range pathTemplateRe.FindAllStringSubmatch(path, -1) {
+		name := match[1]
+		switch {
+		case name == pathTemplateMountAccessor:
+		case strings.HasPrefix(name, pathTemplateTokenMetadataPrefix) &&
+			len(name) > len(pathTemplateTokenMetadataPrefix):
+		default:
+			return fmt.Errorf("unsupported template %q", match[0])
+		}
+	}
+
+	// Anything left is an unterminated or malformed template
+	rest := pathTemplateRe.ReplaceAllString(path, "")
+	if strings.Contains(rest, "{{") || strings.Contains(rest, "}}") {
+		return fmt.Errorf("malformed template")
+	}
+	return nil
+}
+
+// renderPathTemplate renders the templates in the policy path. The second
+// return value is false if any template cannot be resolved, in which case the
+// path must not be granted. Values that are empty or that could widen the
+// path, such as those containing a '/' or a glob, cannot be resolved.
+func renderPathTemplate(path string, data *ACLTemplateData) (string, bool) {
+	if data == nil {
+		return "", false
+	}
+
+	ok := true
+	rendered := pathTemplateRe.ReplaceAllStringFunc(path, func(match string) string {
+		name := pathTemplateRe.FindStringSubmatch(match)[1]
+
+		var value string
+		switch {
+		case name == pathTemplateMountAccessor:
+			value = data.MountAccessor
+		case strings.HasPrefix(name, pathTemplateTokenMetadataPrefix):
+			value = data.TokenMetadata[strings.TrimPrefix(name, pathTemplateTokenMetadataPrefix)]
+		}
+
+		if value == "" || value == "." || value == ".." || strings.ContainsAny(value, "/*+{}") {
+			ok = false
+		}
+		return value
+	})
+	if !ok {
+		return "", false
+	}
+	return rendered, true
+}
@@ -1,6 +1,7 @@
 package vault
 
 import (
+	"fmt"
 	"reflect"
 	"strings"
 	"testing"
@@ -85,6 +86,12 @@ path "test/types" {
 		"bool" = [false]
 	}
 }
+
+# Check that '+' segments and required_parameters are parsed
+path "apps/+/config" {
+	capabilities = ["update"]
+	required_parameters = ["Owner", "ttl"]
+}
 `)
 
 func TestPolicy_Parse(t *testing.T) {
@@ -225,6 +232,20 @@ func TestPolicy_Parse(t *testing.T) {
 			},
 			Glob: false,
 		},
+		&PathCapabilities{
+			Prefix: "apps/+/config",
+			Policy: "",
+			Capabilities: []string{
+				"update",
+			},
+			RequiredParametersHCL: []string{"Owner", "ttl"},
+			Permissions: &Permissions{
+				CapabilitiesBitmap: UpdateCapabilityInt,
+				RequiredParameters: []string{"owner", "ttl"},
+			},
+			Glob:                false,
+			HasSegmentWildcards: true,
+		},
 	}
 	if !reflect.DeepEqual(p.Paths, expect) {
 		t.Errorf("expected \n\n%#v\n\n to be \n\n%#v\n\n", p.Paths, expect)
@@ -312,3 +333,43 @@ path "/" {
 		t.Errorf("bad error: %s", err)
 	}
 }
+
+func TestPolicy_ParseTemplated(t *testing.T) {
+	p, err := Parse(strings.TrimSpace(`
+path "secret/users/{{token.metadata.user}}/*" {
+	capabilities = ["read"]
+}
+path "secret/static" {
+	capabilities = ["read"]
+}
+`))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if p.Paths[0].Prefix != "secret/users/{{token.metadata.user}}/" || !p.Paths[0].Glob || !p.Paths[0].Templated {
+		t.Fatalf("bad: %#v", p.Paths[0])
+	}
+	if p.Paths[1].Templated {
+		t.Fatalf("bad: %#v", p.Paths[1])
+	}
+}
+
+func TestPolicy_ParseBadTemplate(t *testing.T) {
+	cases := map[string]string{
+		"secret/{{identity.entity.name}}": `unsupported template "{{identity.entity.name}}"`,
+		"secret/{{token.metadata.}}":      `unsupported template "{{token.metadata.}}"`,
+		"secret/{{token.metadata.user":    "malformed template",
+		"secret/{{token.{{metadata}}}}":   `unsupported template "{{metadata}}"`,
+	}
+
+	for path, expected := range cases {
+		_, err := Parse(fmt.Sprintf(`path %q { capabilities = ["read"] }`, path))
+		if err == nil {
+			t.Fatalf("%s: expected error", path)
+		}
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("%s: bad error: %s", path, err)
+		}
+	}
+}
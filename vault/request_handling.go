@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/armon/go-metrics"
+	"github.com/hashicorp/errwrap"
 	"github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/vault/helper/consts"
 	"github.com/hashicorp/vault/helper/jsonutil"
@@ -157,9 +158,12 @@ func (c *Core) handleRequest(req *logical.Request) (retResp *logical.Response, r
 		// If it is an internal error we return that, otherwise we
 		// return invalid request so that the status codes can be correct
 		var errType error
-		switch ctErr {
-		case ErrInternalError, logical.ErrPermissionDenied:
+		switch {
+		case ctErr == ErrInternalError, ctErr == logical.ErrPermissionDenied:
 			errType = ctErr
+		case errwrap.Contains(ctErr, logical.ErrPermissionDenied.Error()):
+			// Denials with more detail, such as by expression policies
+			errType = logical.ErrPermissionDenied
 		default:
 			errType = logical.ErrInvalidRequest
 		}